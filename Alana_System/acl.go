@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"alana_system/yamlite"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Controle de acesso aos trechos (ACL)
+// ==============================
+
+// defaultACLPath é lido quando ALANA_ACL não está definida
+const defaultACLPath = "config/acl.yaml"
+
+// Redação das respostas quando a busca acha trechos que o chamador não pode
+// ver (accessPolicy.redaction)
+const (
+	// redactNotice responde com os trechos permitidos e avisa que há fontes
+	// restritas (padrão)
+	redactNotice = "notice"
+	// redactSilent responde com os permitidos, sem aviso
+	redactSilent = "silent"
+	// redactDeny não responde: a pergunta toca conteúdo restrito
+	redactDeny = "deny"
+)
+
+const restrictedNotice = "Observação: há outras fontes relevantes para esta pergunta às quais você não tem acesso; " +
+	"a resposta usa apenas as fontes permitidas."
+
+const restrictedReply = "As fontes relevantes para esta pergunta são restritas e você não tem acesso a elas."
+
+// accessPolicy liga o controle de acesso do serve. Os trechos com o campo
+// acl no payload (lista de grupos, gravada pelo meta da ingestão ou pelos
+// conectores) só são vistos por chamadores de algum desses grupos; trechos
+// sem acl são públicos. O grupo do chamador vem da chave de API:
+//
+//	redaction: notice     # notice (padrão), silent ou deny
+//	keys:
+//	  <sha256 da chave, em hex>: [financeiro, diretoria]
+//
+// As chaves ficam no arquivo só como hash (echo -n "$CHAVE" | sha256sum).
+// Pedidos sem chave, ou com uma chave fora da lista, veem só os públicos.
+type accessPolicy struct {
+	redaction string
+	keys      map[string][]string
+}
+
+// aclPath lê ALANA_ACL (padrão config/acl.yaml)
+func aclPath() string {
+	return envOr("ALANA_ACL", defaultACLPath)
+}
+
+// loadAccessPolicy lê o arquivo da política (inexistente = nil, sem
+// controle de acesso: todos os trechos são visíveis)
+func loadAccessPolicy(path string) (*accessPolicy, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc, err := yamlite.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	p := &accessPolicy{redaction: fieldString(doc, "redaction"), keys: map[string][]string{}}
+	switch p.redaction {
+	case "":
+		p.redaction = redactNotice
+	case redactNotice, redactSilent, redactDeny:
+	default:
+		return nil, fmt.Errorf("%s: redaction deve ser %s, %s ou %s, veio %q", path, redactNotice, redactSilent, redactDeny, p.redaction)
+	}
+	keys, ok := doc["keys"].(map[string]any)
+	if !ok && doc["keys"] != nil {
+		return nil, fmt.Errorf("%s: keys: esperado um mapa (hash da chave → grupos)", path)
+	}
+	for hash := range keys {
+		if len(hash) != sha256.Size*2 {
+			return nil, fmt.Errorf("%s: keys: %q não é um SHA-256 em hex", path, hash)
+		}
+		groups, err := stringList(keys, hash)
+		if err != nil {
+			return nil, fmt.Errorf("%s: keys: %w", path, err)
+		}
+		p.keys[strings.ToLower(hash)] = groups
+	}
+	return p, nil
+}
+
+// viewer monta o chamador de um pedido. Sem política, devolve nil: nil vê
+// tudo (é o caso dos comandos da linha de comando).
+func (p *accessPolicy) viewer(apiKey string) *viewer {
+	if p == nil {
+		return nil
+	}
+	v := &viewer{redaction: p.redaction, withheld: map[string]bool{}}
+	if apiKey != "" {
+		sum := sha256.Sum256([]byte(apiKey))
+		v.groups = p.keys[hex.EncodeToString(sum[:])]
+	}
+	return v
+}
+
+// viewer é o chamador de um pedido: os grupos dele e os trechos retidos ao
+// longo do pedido (um relatório faz várias buscas)
+type viewer struct {
+	groups    []string
+	redaction string
+
+	mu       sync.Mutex
+	withheld map[string]bool
+}
+
+// allows diz se o chamador pode ver um trecho com essa acl
+func (v *viewer) allows(acl []string) bool {
+	if v == nil || len(acl) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(acl, func(g string) bool { return slices.Contains(v.groups, g) })
+}
+
+// permitted tira dos resultados os trechos que o chamador não pode ver e os
+// anota como retidos. A busca não é refeita: com trechos retidos, a resposta
+// sai com menos de TopK fontes.
+func (v *viewer) permitted(results []SearchResult) []SearchResult {
+	if v == nil {
+		return results
+	}
+	out := results[:0:0]
+	for _, r := range results {
+		if v.allows(r.ACL) {
+			out = append(out, r)
+			continue
+		}
+		v.mu.Lock()
+		v.withheld[r.ID] = true
+		v.mu.Unlock()
+	}
+	return out
+}
+
+// condition é a ACL do chamador como condição do Qdrant: trechos sem acl ou
+// com algum dos grupos dele. nil vê tudo (sem condição). Ao contrário de
+// permitted, não anota nada como retido: o Qdrant nem devolve os outros.
+func (v *viewer) condition() *qdrant.Condition {
+	if v == nil {
+		return nil
+	}
+	should := []*qdrant.Condition{qdrant.NewIsEmpty("acl")}
+	if len(v.groups) > 0 {
+		should = append(should, qdrant.NewMatchKeywords("acl", v.groups...))
+	}
+	return qdrant.NewFilterAsCondition(&qdrant.Filter{Should: should})
+}
+
+// restricted diz se algum trecho foi retido no pedido
+func (v *viewer) restricted() bool {
+	if v == nil {
+		return false
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.withheld) > 0
+}
+
+// denies diz se a pergunta deve ser recusada (redaction deny com trechos
+// retidos)
+func (v *viewer) denies() bool {
+	return v.restricted() && v.redaction == redactDeny
+}
+
+// notice é o aviso acrescentado à resposta (vazio = nenhum)
+func (v *viewer) notice() string {
+	if !v.restricted() || v.redaction != redactNotice {
+		return ""
+	}
+	return "\n\n> " + restrictedNotice
+}
+
+// annotate marca a resposta que teve trechos retidos e, com redaction
+// notice, acrescenta o aviso ao texto
+func (v *viewer) annotate(a Answer) Answer {
+	if !v.restricted() || v.redaction == redactSilent {
+		return a
+	}
+	a.Restricted = true
+	a.Text = strings.TrimRight(a.Text, "\n") + v.notice()
+	return a
+}
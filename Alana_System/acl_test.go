@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// A condição da ACL no Qdrant: sem política não filtra; sem grupos, só os
+// trechos sem acl; com grupos, também os de algum dos grupos
+func TestViewerCondition(t *testing.T) {
+	var none *viewer
+	if c := none.condition(); c != nil {
+		t.Fatalf("viewer nil filtrou: %v", c)
+	}
+
+	policy := &accessPolicy{redaction: redactNotice, keys: map[string][]string{sha256Hex("chave-fin"): {"financeiro", "diretoria"}}}
+	cases := []struct {
+		key    string
+		groups []string
+	}{
+		{"", nil},
+		{"chave-desconhecida", nil},
+		{"chave-fin", []string{"financeiro", "diretoria"}},
+	}
+	for _, tc := range cases {
+		should := policy.viewer(tc.key).condition().GetFilter().GetShould()
+		if len(should) == 0 || should[0].GetIsEmpty().GetKey() != "acl" {
+			t.Fatalf("chave %q: sem a condição de acl vazia: %v", tc.key, should)
+		}
+		if tc.groups == nil {
+			if len(should) != 1 {
+				t.Errorf("chave %q: condições a mais: %v", tc.key, should)
+			}
+			continue
+		}
+		if len(should) != 2 {
+			t.Fatalf("chave %q: %v", tc.key, should)
+		}
+		match := should[1].GetField()
+		if match.GetKey() != "acl" || !slices.Equal(match.GetMatch().GetKeywords().GetStrings(), tc.groups) {
+			t.Errorf("chave %q: match %v, esperado acl em %v", tc.key, match, tc.groups)
+		}
+	}
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestLoadAccessPolicy(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	if p, err := loadAccessPolicy(filepath.Join(dir, "nao-existe.yaml")); p != nil || err != nil {
+		t.Errorf("arquivo inexistente: %v, %v", p, err)
+	}
+
+	fin := sha256Hex("chave-fin")
+	path := write("acl.yaml", "redaction: deny\nkeys:\n  "+strings.ToUpper(fin)+": [financeiro, diretoria]\n  "+sha256Hex("chave-rh")+":\n    - rh\n")
+	p, err := loadAccessPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.redaction != redactDeny || !slices.Equal(p.keys[fin], []string{"financeiro", "diretoria"}) || !slices.Equal(p.keys[sha256Hex("chave-rh")], []string{"rh"}) {
+		t.Errorf("política: %+v", p)
+	}
+	if v := p.viewer("chave-fin"); !slices.Equal(v.groups, []string{"financeiro", "diretoria"}) || v.redaction != redactDeny {
+		t.Errorf("viewer da chave-fin: %+v", v)
+	}
+
+	if p, err := loadAccessPolicy(write("padrao.yaml", "keys: {}\n")); err != nil || p.redaction != redactNotice {
+		t.Errorf("redaction padrão: %v, %v", p, err)
+	}
+	for name, content := range map[string]string{
+		"redaction.yaml": "redaction: hide\n",
+		"hash.yaml":      "keys:\n  abc123: [rh]\n",
+		"keys.yaml":      "keys: [rh]\n",
+	} {
+		if _, err := loadAccessPolicy(write(name, content)); err == nil {
+			t.Errorf("%s aceito", name)
+		}
+	}
+}
+
+func TestViewerAllows(t *testing.T) {
+	policy := &accessPolicy{redaction: redactNotice, keys: map[string][]string{sha256Hex("chave-fin"): {"financeiro", "diretoria"}}}
+	fin, anon := policy.viewer("chave-fin"), policy.viewer("")
+	var all *viewer
+
+	cases := []struct {
+		name string
+		v    *viewer
+		acl  []string
+		want bool
+	}{
+		{"sem política vê restrito", all, []string{"rh"}, true},
+		{"trecho sem acl é público", anon, nil, true},
+		{"trecho com acl vazia é público", anon, []string{}, true},
+		{"anônimo não vê restrito", anon, []string{"financeiro"}, false},
+		{"grupo em comum", fin, []string{"rh", "diretoria"}, true},
+		{"nenhum grupo em comum", fin, []string{"rh", "juridico"}, false},
+		{"grupo diferente na caixa", fin, []string{"Financeiro"}, false},
+		{"chave desconhecida é anônima", policy.viewer("chave-outra"), []string{"financeiro"}, false},
+	}
+	for _, tc := range cases {
+		if got := tc.v.allows(tc.acl); got != tc.want {
+			t.Errorf("%s: allows(%v) = %v", tc.name, tc.acl, got)
+		}
+	}
+}
+
+func TestViewerPermitted(t *testing.T) {
+	results := []SearchResult{
+		{ID: "publico"},
+		{ID: "fin", ACL: []string{"financeiro"}},
+		{ID: "rh", ACL: []string{"rh"}},
+		{ID: "fin-rh", ACL: []string{"rh", "financeiro"}},
+	}
+	ids := func(rs []SearchResult) []string {
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+	answer := Answer{Text: "Resposta.\n"}
+
+	for _, redaction := range []string{redactNotice, redactSilent, redactDeny} {
+		policy := &accessPolicy{redaction: redaction, keys: map[string][]string{sha256Hex("chave-fin"): {"financeiro"}}}
+
+		v := policy.viewer("chave-fin")
+		if got := ids(v.permitted(results)); !slices.Equal(got, []string{"publico", "fin", "fin-rh"}) {
+			t.Errorf("%s: permitidos %v", redaction, got)
+		}
+		if !v.restricted() || v.denies() != (redaction == redactDeny) {
+			t.Errorf("%s: restricted %v denies %v", redaction, v.restricted(), v.denies())
+		}
+		a := v.annotate(answer)
+		switch redaction {
+		case redactSilent:
+			if a.Restricted || a.Text != answer.Text {
+				t.Errorf("silent anotou a resposta: %+v", a)
+			}
+		case redactNotice:
+			if !a.Restricted || a.Text != "Resposta.\n\n> "+restrictedNotice {
+				t.Errorf("notice: %+v", a)
+			}
+		case redactDeny:
+			if !a.Restricted || strings.Contains(a.Text, restrictedNotice) {
+				t.Errorf("deny: %+v", a)
+			}
+		}
+
+		// Só trechos visíveis: nada retido, nada anotado
+		clean := policy.viewer("chave-fin")
+		clean.permitted(results[:2])
+		if clean.restricted() || clean.denies() || clean.annotate(answer).Text != answer.Text {
+			t.Errorf("%s: sem trechos retidos e ainda assim restrito", redaction)
+		}
+	}
+
+	var all *viewer
+	if got := all.permitted(results); len(got) != len(results) || all.restricted() {
+		t.Errorf("viewer nil filtrou: %v", ids(got))
+	}
+}
+
+// Respostas com trechos retidos não entram no cache semântico (o aviso e a
+// recusa dependem de quem perguntou), e as que entram só voltam para
+// chamadores com os mesmos grupos
+func TestRestrictedAnswersNotCachedSemantic(t *testing.T) {
+	cache, err := openSemanticCache(context.Background(), "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &AlanaEngine{collection: "alana_knowledge_base", semantic: cache}
+	policy := &accessPolicy{redaction: redactNotice, keys: map[string][]string{
+		sha256Hex("chave-fin"): {"financeiro"},
+		sha256Hex("chave-rh"):  {"rh"},
+	}}
+	embedded := &embeddedQuery{text: "Qual o orçamento de 2026?", vector: []float32{1, 0, 0}, target: e}
+	results := []SearchResult{{ID: "publico"}, {ID: "fin", ACL: []string{"financeiro"}}}
+	ctx := context.Background()
+
+	for _, redaction := range []string{redactNotice, redactSilent, redactDeny} {
+		policy.redaction = redaction
+		rh := askOptions{Viewer: policy.viewer("chave-rh")}
+		visible := rh.Viewer.permitted(results)
+		e.cacheSemantic(ctx, embedded, rh, rh.Viewer.annotate(Answer{Text: "Só o público.", Sources: visible}))
+		if n := cache.order.Len(); n != 0 {
+			t.Fatalf("%s: resposta restrita no cache semântico (%d entradas)", redaction, n)
+		}
+	}
+
+	fin := askOptions{Viewer: policy.viewer("chave-fin")}
+	visible := fin.Viewer.permitted(results)
+	e.cacheSemantic(ctx, embedded, fin, fin.Viewer.annotate(Answer{Text: "Orçamento de R$ 1 mi.", Sources: visible}))
+	if entry, _ := cache.lookup(semanticScope(e.collection, fin), embedded.vector); entry == nil || entry.Text != "Orçamento de R$ 1 mi." {
+		t.Fatalf("resposta sem restrição não foi guardada: %+v", entry)
+	}
+	for _, key := range []string{"chave-rh", "", "chave-outra"} {
+		other := askOptions{Viewer: policy.viewer(key)}
+		if entry, _ := cache.lookup(semanticScope(e.collection, other), embedded.vector); entry != nil {
+			t.Errorf("chave %q viu a resposta do financeiro: %q", key, entry.Text)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"embed"
+	"io/fs"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"alana_system/manifest"
+)
+
+// ==============================
+// Painel /admin
+// ==============================
+
+const (
+	// recentQueriesLen é quantas perguntas o painel mostra
+	recentQueriesLen = 50
+	// recentFailuresLen é quantas ingestões com falha o painel mostra
+	recentFailuresLen = 10
+	// adminQuestionRunes corta a pergunta exibida no painel
+	adminQuestionRunes = 120
+)
+
+//go:embed admin
+var adminFiles embed.FS
+
+// adminKeys são as chaves que abrem o painel (ALANA_ADMIN_KEYS, separadas
+// por vírgula). Sem nenhuma, o painel fica desligado.
+func adminKeysFromEnv() []string {
+	return splitList(os.Getenv("ALANA_ADMIN_KEYS"))
+}
+
+// adminHandler serve o painel somente leitura: GET /admin/ (página embutida)
+// e GET /admin/status (JSON que a página consulta a cada poucos segundos).
+//
+// A chave vai em "Authorization: Bearer", X-API-Key ou como senha do HTTP
+// Basic, que é o que o navegador pede ao abrir a página.
+func (s *server) adminHandler() http.Handler {
+	static, _ := fs.Sub(adminFiles, "admin")
+	mux := http.NewServeMux()
+	mux.Handle("GET /admin/", http.StripPrefix("/admin/", http.FileServerFS(static)))
+	mux.HandleFunc("GET /admin/status", s.handleAdminStatus)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.adminKeys) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		if !s.isAdmin(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="alana admin", charset="UTF-8"`)
+			writeError(w, http.StatusUnauthorized, "chave de admin inválida")
+			return
+		}
+		// A página carrega só o próprio script e estilo
+		w.Header().Set("Content-Security-Policy",
+			"default-src 'none'; script-src 'self'; style-src 'self'; connect-src 'self'; frame-ancestors 'none'")
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) isAdmin(r *http.Request) bool {
+	key := requestAPIKey(r)
+	if _, password, ok := r.BasicAuth(); ok && key == "" {
+		key = password
+	}
+	if key == "" {
+		return false
+	}
+	for _, k := range s.adminKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// ==============================
+// Perguntas recentes
+// ==============================
+
+// recentQuery é uma pergunta atendida pelo serve, para o painel
+type recentQuery struct {
+	Time      time.Time `json:"time"`
+	Endpoint  string    `json:"endpoint"`
+	Question  string    `json:"question"`
+	LatencyMS int64     `json:"latency_ms"`
+	BudgetMS  int64     `json:"budget_ms,omitempty"`
+	Sources   int       `json:"sources"`
+	Truncated bool      `json:"truncated,omitempty"`
+	Failed    bool      `json:"failed,omitempty"`
+}
+
+// recentQueries guarda as últimas perguntas em memória (buffer circular)
+type recentQueries struct {
+	mu    sync.Mutex
+	items []recentQuery
+	next  int
+}
+
+func (q *recentQueries) add(endpoint, question string, opts askOptions, answer Answer, elapsed time.Duration, err error) {
+	if utf8.RuneCountInString(question) > adminQuestionRunes {
+		question = string([]rune(question)[:adminQuestionRunes]) + "…"
+	}
+	item := recentQuery{
+		Time:      time.Now().UTC(),
+		Endpoint:  endpoint,
+		Question:  question,
+		LatencyMS: elapsed.Milliseconds(),
+		BudgetMS:  opts.Budget.Milliseconds(),
+		Sources:   len(answer.Sources),
+		Truncated: answer.Truncated,
+		Failed:    err != nil,
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) < recentQueriesLen {
+		q.items = append(q.items, item)
+		return
+	}
+	q.items[q.next] = item
+	q.next = (q.next + 1) % recentQueriesLen
+}
+
+// list devolve as perguntas da mais nova para a mais antiga
+func (q *recentQueries) list() []recentQuery {
+	q.mu.Lock()
+	out := slices.Concat(q.items[q.next:], q.items[:q.next])
+	q.mu.Unlock()
+	slices.Reverse(out)
+	return out
+}
+
+// ==============================
+// Status
+// ==============================
+
+type adminStatus struct {
+	Time      time.Time            `json:"time"`
+	Jobs      []manifest.Document  `json:"jobs"`
+	Failures  []manifest.Document  `json:"failures"`
+	Queue     []providerHostStatus `json:"queue"`
+	Queries   []recentQuery        `json:"queries"`
+	Health    []dependencyHealth   `json:"health"`
+	Draining  bool                 `json:"draining"`
+	Documents int                  `json:"documents"`
+}
+
+// handleAdminStatus implementa GET /admin/status. Ingestões em andamento e
+// falhas vêm do manifesto; a fila e a cota, do cliente dos provedores; a
+// saúde, das mesmas verificações do `alana doctor`.
+func (s *server) handleAdminStatus(w http.ResponseWriter, r *http.Request) {
+	docs, err := s.manifest.List(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "manifesto indisponível")
+		return
+	}
+
+	status := adminStatus{
+		Time:      time.Now().UTC(),
+		Jobs:      []manifest.Document{},
+		Failures:  []manifest.Document{},
+		Queue:     providerHTTP.Transport.(*adaptiveTransport).status(),
+		Queries:   s.recent.list(),
+		Draining:  s.draining.Load(),
+		Documents: len(docs),
+	}
+	for _, d := range docs {
+		switch d.Status {
+		case manifest.StatusIngesting:
+			status.Jobs = append(status.Jobs, d)
+		case manifest.StatusFailed:
+			status.Failures = append(status.Failures, d)
+		}
+	}
+	byUpdate := func(a, b manifest.Document) int { return cmp.Compare(b.UpdatedAt.UnixNano(), a.UpdatedAt.UnixNano()) }
+	slices.SortFunc(status.Jobs, byUpdate)
+	slices.SortFunc(status.Failures, byUpdate)
+	status.Failures = status.Failures[:min(len(status.Failures), recentFailuresLen)]
+
+	status.Health, _ = s.dependencyHealth(r.Context())
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,89 @@
+// Painel somente leitura: consulta /admin/status a cada poucos segundos.
+// A autenticação (HTTP Basic) é reaproveitada pelo navegador no fetch.
+"use strict";
+
+const REFRESH_MS = 5000;
+
+function cell(text, className) {
+  const td = document.createElement("td");
+  td.textContent = text;
+  if (className) td.className = className;
+  return td;
+}
+
+function fill(id, rows, empty, columns) {
+  const body = document.getElementById(id);
+  body.replaceChildren();
+  if (rows.length === 0) {
+    const tr = document.createElement("tr");
+    const td = cell(empty, "empty");
+    td.colSpan = columns;
+    tr.append(td);
+    body.append(tr);
+    return;
+  }
+  for (const cells of rows) {
+    const tr = document.createElement("tr");
+    tr.append(...cells);
+    body.append(tr);
+  }
+}
+
+function time(iso) {
+  return new Date(iso).toLocaleTimeString("pt-BR");
+}
+
+function ms(value) {
+  return value >= 1000 ? (value / 1000).toFixed(1) + " s" : value + " ms";
+}
+
+function render(status) {
+  document.getElementById("updated").textContent =
+    (status.draining ? "drenando · " : "") + status.documents + " documentos · " + time(status.time);
+
+  const health = document.getElementById("health");
+  health.replaceChildren(...status.health.map((h) => {
+    const li = document.createElement("li");
+    li.className = h.status;
+    li.textContent = h.name + ": " + h.detail;
+    return li;
+  }));
+
+  document.getElementById("jobs-count").textContent = status.jobs.length || "";
+  fill("jobs", status.jobs.map((d) => [
+    cell(d.source), cell(d.version), cell(time(d.updated_at)),
+  ]), "nenhuma ingestão em andamento", 3);
+
+  fill("queue", status.queue.map((h) => [
+    cell(h.host), cell(h.inflight), cell(h.waiting, h.waiting > 0 ? "warn" : ""),
+    cell(h.concurrency), cell(h.paused_ms ? ms(h.paused_ms) : "—", h.paused_ms ? "warn" : ""),
+    cell((h.remaining_requests || "—") + " / " + (h.remaining_tokens || "—")),
+  ]), "nenhuma chamada a provedores ainda", 6);
+
+  fill("queries", status.queries.map((q) => {
+    const overBudget = q.budget_ms && q.latency_ms > q.budget_ms;
+    return [
+      cell(time(q.time)), cell(q.endpoint), cell(q.question, q.failed ? "fail" : ""),
+      cell(ms(q.latency_ms), overBudget || q.truncated ? "warn" : ""),
+      cell(q.budget_ms ? Math.round(100 * q.latency_ms / q.budget_ms) + "% de " + ms(q.budget_ms) : "—"),
+      cell(q.failed ? "erro" : q.sources),
+    ];
+  }), "nenhuma pergunta desde o início do serve", 6);
+
+  fill("failures", status.failures.map((d) => [
+    cell(d.source), cell(d.error || "", "fail"), cell(time(d.updated_at)),
+  ]), "nenhuma falha", 3);
+}
+
+async function refresh() {
+  try {
+    const resp = await fetch("status", { cache: "no-store" });
+    if (!resp.ok) throw new Error("HTTP " + resp.status);
+    render(await resp.json());
+  } catch (err) {
+    document.getElementById("updated").textContent = "sem conexão (" + err.message + ")";
+  }
+  setTimeout(refresh, REFRESH_MS);
+}
+
+refresh();
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Uso da collection
+// ==============================
+
+const (
+	usageLogPath = "./data/usage.log"
+	// usageLogMaxFile é o tamanho antes de rotacionar; o relatório lê também
+	// a geração anterior
+	usageLogMaxFile = 100 << 20
+)
+
+// Tipos de evento de uso
+const (
+	// usageRetrieved: trechos devolvidos pela busca vetorial (antes do re-ranking)
+	usageRetrieved = "retrieved"
+	// usageCited: trechos usados como fontes de uma resposta
+	usageCited = "cited"
+)
+
+// newUsageLog abre o log de quais trechos cada pergunta recuperou e citou,
+// usado pelo `alana analytics` para apontar documentos que ninguém usa
+func newUsageLog() *jsonlLog {
+	return &jsonlLog{path: usageLogPath, maxBytes: usageLogMaxFile}
+}
+
+type usageEvent struct {
+	Time   time.Time    `json:"time"`
+	Kind   string       `json:"kind"`
+	Chunks []usageChunk `json:"chunks"`
+}
+
+type usageChunk struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+}
+
+// recordUsage grava um evento de uso; falhas só vão para o log
+func (e *AlanaEngine) recordUsage(kind string, results []SearchResult) {
+	if e.usage == nil || len(results) == 0 {
+		return
+	}
+	ev := usageEvent{Time: time.Now().UTC(), Kind: kind}
+	for _, r := range results {
+		ev.Chunks = append(ev.Chunks, usageChunk{ID: r.ID, Source: r.Source})
+	}
+	if err := e.usage.append(ev); err != nil {
+		engineLog.Error("Erro ao gravar uso", "err", err)
+	}
+}
+
+// docUsage agrega o uso de um documento no período
+type docUsage struct {
+	Source    string
+	Chunks    int
+	Used      int // trechos do documento recuperados ao menos uma vez
+	Retrieved int
+	Cited     int
+	LastSeen  time.Time
+}
+
+// runAnalytics implementa `alana analytics`:
+//
+//	analytics top-docs [-since D] [-n N]   documentos mais citados e recuperados
+//	analytics unused [-since D]            documentos nunca recuperados
+//	analytics dead-weight [-since D]       % de trechos e documentos nunca recuperados
+//
+// Só conta o uso dos trechos que ainda existem na collection: trechos
+// reingeridos com conteúdo novo ganham outro ID e recomeçam do zero.
+func runAnalytics(ctx context.Context, engine *AlanaEngine, args []string) error {
+	const usage = "uso: analytics top-docs [-since D] [-n N] | analytics unused [-since D] | analytics dead-weight [-since D]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	fs := flag.NewFlagSet("analytics "+args[0], flag.ContinueOnError)
+	since := fs.Duration("since", 0, "considera só o uso desse período (0 = todo o histórico)")
+	n := fs.Int("n", 20, "quantos documentos listar (top-docs)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var from time.Time
+	if *since > 0 {
+		from = time.Now().Add(-*since)
+	}
+
+	docs, chunks, err := engine.usageByDocument(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "top-docs":
+		top := slices.SortedFunc(maps.Values(docs), func(a, b *docUsage) int {
+			return cmp.Or(cmp.Compare(b.Cited, a.Cited), cmp.Compare(b.Retrieved, a.Retrieved), cmp.Compare(a.Source, b.Source))
+		})
+		fmt.Printf("%-40s %8s %10s %14s  %s\n", "DOCUMENTO", "CITADO", "RECUPERADO", "TRECHOS USADOS", "ÚLTIMO USO")
+		for _, d := range top[:min(*n, len(top))] {
+			if d.Retrieved == 0 {
+				break
+			}
+			fmt.Printf("%-40s %8d %10d %14s  %s\n", d.Source, d.Cited, d.Retrieved,
+				fmt.Sprintf("%d/%d", d.Used, d.Chunks), d.LastSeen.Local().Format("2006-01-02 15:04"))
+		}
+		return nil
+
+	case "unused":
+		unused := 0
+		for _, d := range slices.SortedFunc(maps.Values(docs), func(a, b *docUsage) int { return cmp.Compare(a.Source, b.Source) }) {
+			if d.Retrieved == 0 {
+				fmt.Printf("%s (%d trechos)\n", d.Source, d.Chunks)
+				unused++
+			}
+		}
+		fmt.Printf("\n%d de %d documentos nunca recuperados\n", unused, len(docs))
+		return nil
+
+	case "dead-weight":
+		var used, unusedDocs int
+		for _, d := range docs {
+			used += d.Used
+			if d.Retrieved == 0 {
+				unusedDocs++
+			}
+		}
+		fmt.Printf("Trechos nunca recuperados:     %d de %d (%.1f%%)\n", chunks-used, chunks, percent(chunks-used, chunks))
+		fmt.Printf("Documentos nunca recuperados:  %d de %d (%.1f%%)\n", unusedDocs, len(docs), percent(unusedDocs, len(docs)))
+		return nil
+	}
+
+	return errors.New(usage)
+}
+
+// usageByDocument cruza o log de uso (a partir de from) com os trechos
+// visíveis da collection. Devolve o uso por documento e o total de trechos.
+func (e *AlanaEngine) usageByDocument(ctx context.Context, from time.Time) (map[string]*docUsage, int, error) {
+	docs := map[string]*docUsage{}
+	chunkDoc := map[string]*docUsage{}
+	total := 0
+
+	err := e.scrollPoints(ctx, false, func(points []*qdrant.RetrievedPoint) error {
+		for _, p := range points {
+			source := p.GetPayload()["file_name"].GetStringValue()
+			d, ok := docs[source]
+			if !ok {
+				d = &docUsage{Source: source}
+				docs[source] = d
+			}
+			d.Chunks++
+			chunkDoc[pointIDString(p.GetId())] = d
+			total++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	seen := map[string]bool{}
+	err = readUsage(usageLogPath, func(ev usageEvent) {
+		if ev.Time.Before(from) {
+			return
+		}
+		for _, c := range ev.Chunks {
+			d, ok := chunkDoc[c.ID]
+			if !ok {
+				continue
+			}
+			switch ev.Kind {
+			case usageRetrieved:
+				d.Retrieved++
+				if !seen[c.ID] {
+					seen[c.ID] = true
+					d.Used++
+				}
+			case usageCited:
+				d.Cited++
+			}
+			if ev.Time.After(d.LastSeen) {
+				d.LastSeen = ev.Time
+			}
+		}
+	})
+	return docs, total, err
+}
+
+// readUsage percorre o log de uso, da geração rotacionada para a atual
+func readUsage(logPath string, fn func(usageEvent)) error {
+	for _, path := range []string{logPath + ".1", logPath} {
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64<<10), 4<<20)
+		for sc.Scan() {
+			var ev usageEvent
+			if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+				continue // linha truncada por crash
+			}
+			fn(ev)
+		}
+		err = sc.Err()
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func percent(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(whole)
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ==============================
+// Pipeline de resposta
+// ==============================
+
+// truncatedNotice encerra respostas cortadas pelo orçamento de latência
+const truncatedNotice = "[Resposta truncada por limite de tempo]"
+
+// abstainReply é a resposta quando nenhum trecho passa do corte de abstenção
+const abstainReply = "Não encontrei nos documentos indexados informação suficiente para responder a essa pergunta. " +
+	"Tente reformular ou dar mais detalhes."
+
+// askOptions ajusta uma pergunta individual. TopK, ScoreThreshold, Rerank e
+// PromptTemplate vêm do registro de collections (ver collectionRegistry.askOptions).
+type askOptions struct {
+	TopK           uint64
+	ScoreThreshold float32
+	// Rerank busca rerankCandidates×TopK trechos e reordena com o
+	// cross-encoder do sidecar antes de cortar em TopK
+	Rerank bool
+	// Hybrid soma a busca por palavra-chave à vetorial (ver hybridSearch)
+	// nas collections que têm o vetor esparso
+	Hybrid bool
+	// Filter restringe a busca pelos metadados dos documentos
+	Filter SearchFilter
+	// Rewrite reescreve a pergunta antes da busca (rewriteHyDE ou
+	// rewriteMulti, ver rewriteQuery) e combina os resultados; vazio desliga
+	Rewrite string
+	// Viewer é o chamador do pedido: os trechos que ele não pode ver saem
+	// dos resultados (ver accessPolicy); nil vê tudo
+	Viewer *viewer
+	// WithVectors traz o vetor denso dos trechos (ver SearchOptions); os que
+	// só a busca por palavra-chave achou vêm sem
+	WithVectors bool
+	// Pin são documentos (file_name) cujos trechos mais parecidos com a
+	// pergunta entram sempre, antes dos outros, se couberem no orçamento:
+	// não passam pelo score_threshold nem pelo filtro de fontes
+	Pin []string
+	// Clarify devolve uma pergunta de esclarecimento em vez de responder
+	// quando a busca é ambígua
+	Clarify bool
+	Cutoffs scoreCutoffs
+	// Draft gera um rascunho com esse modelo enquanto o principal responde
+	// (só com streaming, ver draftThenRefine)
+	Draft *generationOverride
+	// History são os turnos anteriores da conversa (ver ChatSession); vão
+	// condensados para o início do contexto e ajudam a busca
+	History []ChatTurn
+	// UserID liga a memória de conversas (ver chatMemory): turnos de outras
+	// sessões do usuário parecidos com a pergunta entram no contexto.
+	// SessionID é a conversa atual, cujos turnos já vêm em History.
+	UserID    string
+	SessionID string
+	// PromptTemplate substitui o prompt padrão do sidecar (vazio = padrão)
+	PromptTemplate string
+	// TokenLimit é o orçamento do contexto; zero usa o limite do modelo
+	TokenLimit int
+	Override   generationOverride
+	// Cache diz se a resposta pode vir do cache de respostas (ver
+	// answerCacheKey) e se a gerada vai para ele
+	Cache cacheMode
+	// Budget é o orçamento de latência da pergunta inteira. Se a geração
+	// passar do prazo, a resposta parcial é devolvida com truncatedNotice em
+	// vez de erro. Zero desliga o corte.
+	Budget time.Duration
+
+	// embedded é o vetor da pergunta já calculado pelo cache semântico
+	embedded *embeddedQuery
+}
+
+func defaultAskOptions() askOptions {
+	return askOptions{TopK: 5, ScoreThreshold: defaultScoreThreshold, Hybrid: true, Cutoffs: defaultCutoffs}
+}
+
+// searchOptions são as opções da busca vetorial da pergunta, com topK
+// candidatos
+func (o askOptions) searchOptions(topK uint64) SearchOptions {
+	return SearchOptions{TopK: topK, ScoreThreshold: o.ScoreThreshold, Filter: o.Filter, WithVectors: o.WithVectors}
+}
+
+// scoreCutoffs são os cortes calibrados sobre o score do scorer em uso:
+// similaridade vetorial ou, com re-ranking, relevância do cross-encoder (ver
+// runCalibrate). Zero desliga cada corte.
+type scoreCutoffs struct {
+	// Abstain: com o melhor trecho abaixo disso, a pergunta não é respondida
+	Abstain float32
+	// Rerank descarta trechos com relevância abaixo disso
+	Rerank float32
+	// RerankAbstain é o Abstain quando há re-ranking
+	RerankAbstain float32
+}
+
+// abstains diz se a busca não achou nada bom o bastante para responder.
+// results vêm ordenados pelo scorer em uso (maior primeiro), depois dos
+// fixados: com um documento fixado, o usuário já escolheu a base da resposta.
+func (o askOptions) abstains(results []SearchResult) bool {
+	cutoff := o.Cutoffs.Abstain
+	if o.Rerank {
+		cutoff = o.Cutoffs.RerankAbstain
+	}
+	switch {
+	case cutoff == 0:
+		return false
+	case len(results) > 0 && results[0].Pinned:
+		return false
+	case len(results) == 0:
+		return true
+	case results[0].Degraded && !o.Rerank:
+		// O BM25 do índice local não é comparável ao corte de similaridade
+		return false
+	case o.Rerank:
+		return results[0].Relevance < cutoff
+	}
+	return results[0].Score < cutoff
+}
+
+// Answer é o resultado de uma pergunta: resposta do LLM e trechos usados
+type Answer struct {
+	Text      string
+	Sources   []SearchResult
+	Truncated bool
+	// Clarification indica que Text é uma pergunta de esclarecimento, não a
+	// resposta (Sources são os trechos ambíguos)
+	Clarification bool
+	// Abstained indica que nenhum trecho passou do corte de abstenção e Text
+	// é abstainReply
+	Abstained bool
+	// Cached indica que Text veio do cache de respostas, sem gerar
+	Cached bool
+	// Degraded indica que os trechos vieram do índice local, só por
+	// palavra-chave, porque o Qdrant estava fora do ar
+	Degraded bool
+	// Restricted indica que a busca achou trechos que o chamador não pode
+	// ver e que ficaram fora da resposta (ver viewer.annotate)
+	Restricted bool
+	// EmptyCorpus indica que a collection não existe ou não tem trechos e
+	// Text é emptyCorpusReply
+	EmptyCorpus bool
+}
+
+// answerStream recebe a resposta aos pedaços (ver AskStream)
+type answerStream struct {
+	// Sources recebe os trechos escolhidos antes de a geração começar
+	Sources func([]SearchResult)
+	// Token recebe cada pedaço do texto gerado, na ordem
+	Token func(string)
+	// Correction recebe o texto final quando ele substitui o rascunho
+	// transmitido em Token (ver askOptions.Draft)
+	Correction func(string)
+}
+
+// Ask executa embedding → busca → contexto → geração para uma pergunta.
+// Conversa fiada ("oi", "valeu", "o que você faz?") é respondida por template,
+// sem embedding, busca nem LLM (ver classifySmallTalk).
+func (e *AlanaEngine) Ask(ctx context.Context, question string, opts askOptions) (Answer, error) {
+	return e.AskStream(ctx, question, opts, nil)
+}
+
+// AskStream é o Ask com a resposta entregue aos pedaços: as fontes assim que
+// a busca termina e cada token assim que o sidecar o gera. Respostas de
+// template (conversa fiada, esclarecimento) chegam num único pedaço. A
+// Answer devolvida é a mesma do Ask. stream nil equivale ao Ask.
+func (e *AlanaEngine) AskStream(ctx context.Context, question string, opts askOptions, stream *answerStream) (Answer, error) {
+	ctx, sp := startSpan(ctx, "ask", spanInternal)
+	sp.set("alana.collection", e.collection)
+	sp.set("alana.top_k", int(opts.TopK))
+	answer, err := e.askStream(ctx, question, opts, stream)
+	answer.Degraded = degradedResults(answer.Sources)
+	if notice := opts.Viewer.notice(); err == nil && notice != "" && stream != nil && stream.Token != nil {
+		stream.Token(notice)
+	}
+	if err == nil {
+		answer = opts.Viewer.annotate(answer)
+	}
+	sp.set("alana.sources", len(answer.Sources))
+	sp.set("alana.abstained", answer.Abstained)
+	sp.set("alana.empty_corpus", answer.EmptyCorpus)
+	sp.set("alana.truncated", answer.Truncated)
+	sp.set("alana.cached", answer.Cached)
+	sp.finish(err)
+	return answer, err
+}
+
+func (e *AlanaEngine) askStream(ctx context.Context, question string, opts askOptions, stream *answerStream) (Answer, error) {
+	if kind := classifySmallTalk(question); kind != smallTalkNone {
+		answer := Answer{Text: smallTalkReplies[kind]}
+		stream.send(answer)
+		return answer, nil
+	}
+
+	start := time.Now()
+
+	// Pergunta parecida com uma já respondida: a resposta dela serve
+	var embedded *embeddedQuery
+	if e.semantic != nil && opts.cacheable() {
+		var cached *semanticEntry
+		cached, embedded = e.semanticAnswerFor(ctx, question, opts)
+		if cached != nil {
+			answer := Answer{Text: cached.Text, Sources: cached.Sources, Cached: true}
+			stream.send(answer)
+			e.recordUsage(usageCited, answer.Sources)
+			return answer, nil
+		}
+		opts.embedded = embedded
+	}
+
+	results, err := e.retrieve(ctx, followUpQuery(opts.History, question), opts)
+	if err != nil {
+		// Collection que ainda não foi criada: a ingestão nunca rodou
+		if answer, ok := e.emptyCorpusAnswer(ctx); ok {
+			stream.send(answer)
+			return answer, nil
+		}
+		return Answer{}, err
+	}
+	if opts.Viewer.denies() {
+		answer := Answer{Text: restrictedReply, Restricted: true}
+		stream.send(answer)
+		return answer, nil
+	}
+	if len(results) == 0 {
+		answer := e.noResultsAnswer(ctx)
+		stream.send(answer)
+		return answer, nil
+	}
+	if opts.abstains(results) {
+		answer := Answer{Text: abstainReply, Abstained: true}
+		stream.send(answer)
+		return answer, nil
+	}
+	if opts.Clarify && len(opts.Pin) == 0 {
+		if sources, ok := ambiguousSources(results); ok {
+			answer := Answer{Text: clarifyingQuestion(sources), Sources: results, Clarification: true}
+			stream.send(answer)
+			return answer, nil
+		}
+	}
+
+	// Mesma pergunta sobre os mesmos trechos: a resposta já gerada serve
+	var cacheKey string
+	if e.answers != nil && opts.cacheable() && !degradedResults(results) {
+		cacheKey = answerCacheKey(question, e.collection, opts, results)
+		if opts.Cache == cacheUse {
+			if cached := e.cachedAnswerFor(ctx, cacheKey); cached != nil {
+				answer := Answer{Text: cached.Text, Sources: results, Cached: true}
+				stream.send(answer)
+				e.recordUsage(usageCited, answer.Sources)
+				return answer, nil
+			}
+		}
+	}
+
+	tokenLimit := opts.TokenLimit
+	if tokenLimit == 0 {
+		tokenLimit = e.models.contextTokenLimit(opts.Override.Model)
+	}
+	// O histórico sai do mesmo orçamento, contado no tokenizer do modelo. A
+	// memória é opcional: se falhar, a pergunta é respondida sem ela.
+	memCtx, sp := startSpan(ctx, "memory.recall", spanInternal)
+	recalled, err := e.recallTurns(memCtx, opts.UserID, opts.SessionID, question)
+	sp.set("alana.turns", len(recalled))
+	sp.finish(err)
+	if err != nil {
+		engineLog.WarnContext(ctx, "Memória de conversas indisponível", "err", err)
+	}
+	history := recalledHistory(recalled) + condensedHistory(opts.History)
+	if tokenLimit > 0 {
+		tokenLimit = max(tokenLimit-e.models.countTokens(opts.Override.Model, history), 1)
+	}
+	_, sp = startSpan(ctx, "assemble", spanInternal)
+	contextText := history + e.AssembleContext(results, tokenLimit, opts.Override.Model)
+	sp.set("alana.context_chars", len(contextText))
+	sp.finish(nil)
+
+	genCtx, sp := startSpan(ctx, "generate", spanInternal)
+
+	var answer Answer
+	switch {
+	case opts.Budget > 0 || stream != nil:
+		var deadline time.Time
+		if opts.Budget > 0 {
+			deadline = start.Add(opts.Budget)
+		}
+		var onToken func(string)
+		if stream != nil {
+			if stream.Sources != nil {
+				stream.Sources(results)
+			}
+			onToken = stream.Token
+		}
+		if stream != nil && opts.Draft != nil {
+			answer, err = draftThenRefine(genCtx, question, contextText, results, opts, deadline, stream)
+			break
+		}
+		answer, err = generateWithinBudget(genCtx, question, contextText, results, opts, deadline, onToken)
+	default:
+		var text string
+		text, err = getAnswerWith(genCtx, question, contextText, opts.Override, opts.PromptTemplate)
+		if err != nil {
+			err = fmt.Errorf("generate: %w", err)
+		}
+		answer = Answer{Text: text, Sources: results}
+	}
+	sp.set("alana.answer_chars", len(answer.Text))
+	sp.finish(err)
+	if err != nil {
+		return Answer{}, err
+	}
+
+	if cacheKey != "" {
+		e.cacheAnswer(ctx, cacheKey, question, answer)
+	}
+	if embedded != nil {
+		e.cacheSemantic(ctx, embedded, opts, answer)
+	}
+	e.recordUsage(usageCited, answer.Sources)
+	return answer, nil
+}
+
+// send entrega uma resposta pronta (de template) de uma vez
+func (s *answerStream) send(a Answer) {
+	if s == nil {
+		return
+	}
+	if s.Sources != nil {
+		s.Sources(a.Sources)
+	}
+	if s.Token != nil {
+		s.Token(a.Text)
+	}
+}
+
+// retrieve executa embedding → busca (→ re-ranking) sem gerar resposta. Com
+// o Qdrant fora do ar e um índice local carregado, a busca cai nele (ver
+// retrieveLocal) e os trechos voltam com Degraded. Os trechos que
+// opts.Viewer não pode ver ficam de fora.
+func (e *AlanaEngine) retrieve(ctx context.Context, question string, opts askOptions) ([]SearchResult, error) {
+	results, err := e.retrieveVector(ctx, question, opts)
+	if err != nil && e.local != nil && qdrantUnavailable(ctx, err) {
+		if idx := e.local.index.Load(); idx != nil {
+			engineLog.WarnContext(ctx, "Qdrant indisponível; usando o índice local só por palavra-chave", "err", err)
+			results, err = e.retrieveLocal(ctx, idx, question, opts)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return opts.Viewer.permitted(results), nil
+}
+
+// degradedResults diz se os trechos vieram do índice local
+func degradedResults(results []SearchResult) bool {
+	return len(results) > 0 && results[0].Degraded
+}
+
+// retrieveVector é o retrieve no Qdrant: (reescrita →) embedding → busca
+// vetorial (e híbrida) → re-ranking → documentos fixados
+func (e *AlanaEngine) retrieveVector(ctx context.Context, question string, opts askOptions) ([]SearchResult, error) {
+	candidates := opts.TopK
+	if opts.Rerank {
+		candidates *= rerankCandidates
+	}
+
+	// Com a reescrita, cada consulta expandida é buscada como a pergunta e as
+	// listas são combinadas; o re-ranking e os fixados usam a pergunta original
+	queries := []string{question}
+	if opts.Rewrite != "" {
+		queries = append(queries, e.rewriteQuery(ctx, question, opts)...)
+	}
+	// Com collections_by_type, cada consulta é buscada em cada collection
+	// escolhida (ver searchRouted), e todas as listas são combinadas juntas
+	var vector []float32
+	var target *AlanaEngine
+	lists := make([][]SearchResult, 0, len(queries))
+	for i, query := range queries {
+		v, t, results, err := e.searchRouted(ctx, query, candidates, opts)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			vector, target = v, t
+		}
+		lists = append(lists, results...)
+	}
+	results := lists[0]
+	if len(lists) > 1 {
+		results = fuseResults(lists, candidates)
+	}
+
+	var err error
+	e.recordUsage(usageRetrieved, results)
+	if opts.Rerank {
+		rerankCtx, sp := startSpan(ctx, "rerank", spanInternal)
+		sp.set("alana.candidates", len(results))
+		results, err = rerankResults(rerankCtx, e.reranker, question, results, opts.TopK, opts.Cutoffs.Rerank)
+		sp.finish(err)
+		if err != nil {
+			return nil, fmt.Errorf("rerank: %w", err)
+		}
+	}
+	if len(opts.Pin) > 0 {
+		pinCtx, sp := startSpan(ctx, "pin", spanInternal)
+		sp.set("alana.pinned_documents", len(opts.Pin))
+		results, err = target.pinDocuments(pinCtx, vector, results, opts)
+		sp.finish(err)
+		if err != nil {
+			return nil, fmt.Errorf("pin: %w", err)
+		}
+	}
+	return results, nil
+}
+
+// searchQuery gera o vetor de query e busca os candidates trechos mais
+// parecidos (vetorial e, se ligada, por palavra-chave). Devolve também o
+// vetor e o engine que o buscou (ver embedQuery).
+func (e *AlanaEngine) searchQuery(ctx context.Context, query string, candidates uint64, opts askOptions) ([]float32, *AlanaEngine, []SearchResult, error) {
+	var vector []float32
+	var target *AlanaEngine
+	if q := opts.embedded; q != nil && q.text == query {
+		vector, target = q.vector, q.target
+	} else {
+		embedCtx, sp := startSpan(ctx, "embed", spanInternal)
+		var err error
+		vector, target, err = e.embedQuery(embedCtx, query)
+		sp.finish(err)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("embedding: %w", err)
+		}
+	}
+
+	searchCtx, sp := startSpan(ctx, "search", spanInternal)
+	sp.set("alana.collection", target.collection)
+	sp.set("alana.limit", int(candidates))
+	sp.set("alana.hybrid", opts.Hybrid)
+	results, err := target.Search(searchCtx, vector, opts.searchOptions(candidates))
+	if err == nil && opts.Hybrid {
+		var hybrid bool
+		if hybrid, err = target.hasSparseVector(searchCtx); err == nil && hybrid {
+			results, err = target.hybridSearch(searchCtx, query, vector, results, candidates, opts.Filter)
+		}
+	}
+	sp.set("alana.results", len(results))
+	sp.finish(err)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("search: %w", err)
+	}
+	return vector, target, results, nil
+}
+
+// pinDocuments busca os até TopK trechos dos documentos fixados mais
+// parecidos com a pergunta, sem corte de score, e os põe antes dos
+// resultados (sem repetir os que já vieram). Os outros filtros continuam
+// valendo; o de fontes é trocado pelos fixados.
+func (e *AlanaEngine) pinDocuments(ctx context.Context, vector []float32, results []SearchResult, opts askOptions) ([]SearchResult, error) {
+	filter := opts.Filter
+	filter.Sources, filter.Exclude = opts.Pin, nil
+	pinned, err := e.Search(ctx, vector, SearchOptions{TopK: opts.TopK, Filter: filter, WithVectors: opts.WithVectors})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(pinned))
+	for i := range pinned {
+		pinned[i].Pinned = true
+		seen[pinned[i].ID] = true
+	}
+	for _, r := range results {
+		if !seen[r.ID] {
+			pinned = append(pinned, r)
+		}
+	}
+	return pinned, nil
+}
+
+// generateWithinBudget gera em streaming até o prazo (zero = sem prazo),
+// repassando cada pedaço a onToken (opcional). Estourar o prazo não é erro: o
+// que já foi gerado é devolvido, marcado como truncado.
+func generateWithinBudget(
+	ctx context.Context,
+	question, contextText string,
+	results []SearchResult,
+	opts askOptions,
+	deadline time.Time,
+	onToken func(string),
+) (Answer, error) {
+
+	genCtx, cancel := ctx, context.CancelFunc(func() {})
+	if !deadline.IsZero() {
+		genCtx, cancel = context.WithDeadline(ctx, deadline)
+	}
+	defer cancel()
+
+	var b strings.Builder
+	err := getAnswerStream(genCtx, question, contextText, opts.Override, opts.PromptTemplate, func(token string) {
+		b.WriteString(token)
+		if onToken != nil {
+			onToken(token)
+		}
+	})
+
+	switch {
+	case err == nil:
+		return Answer{Text: strings.TrimSpace(b.String()), Sources: results}, nil
+	case ctx.Err() == nil && errors.Is(genCtx.Err(), context.DeadlineExceeded):
+		text := strings.TrimSpace(b.String())
+		if text != "" {
+			text += "\n\n"
+		}
+		return Answer{Text: text + truncatedNotice, Sources: results, Truncated: true}, nil
+	default:
+		return Answer{}, fmt.Errorf("generate: %w", err)
+	}
+}
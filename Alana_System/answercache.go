@@ -0,0 +1,230 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==============================
+// Cache de respostas
+// ==============================
+
+const (
+	// maxMemoryCachedAnswers limita o cache em memória; cheio, descarta a
+	// resposta mais antiga
+	maxMemoryCachedAnswers = 1000
+	// defaultAnswerCacheTTL é a validade quando ALANA_ANSWER_CACHE_TTL não
+	// está definida
+	defaultAnswerCacheTTL = 7 * 24 * time.Hour
+)
+
+// cacheMode diz como uma pergunta usa o cache de respostas
+type cacheMode int
+
+const (
+	// cacheUse devolve a resposta do cache se houver e guarda a gerada
+	cacheUse cacheMode = iota
+	// cacheRefresh gera de novo e substitui a do cache (ver runWarmup)
+	cacheRefresh
+	// cacheBypass nem lê nem grava
+	cacheBypass
+)
+
+// cachedAnswer é o texto gerado para uma pergunta. As fontes não são
+// guardadas: a chave já inclui os trechos recuperados (ver answerCacheKey),
+// então elas são as da busca que encontrou a resposta.
+type cachedAnswer struct {
+	Question string    `json:"question"`
+	Text     string    `json:"text"`
+	Created  time.Time `json:"created"`
+}
+
+// answerCache guarda as respostas geradas pela chave de answerCacheKey
+type answerCache interface {
+	// Get devolve nil se a chave não está no cache
+	Get(ctx context.Context, key string) (*cachedAnswer, error)
+	Put(ctx context.Context, key string, a *cachedAnswer) error
+}
+
+// openAnswerCache abre o cache de ALANA_ANSWER_CACHE: vazio (desligado),
+// "memory" (perde as respostas no restart) ou "file:<dir>" (um JSON por
+// resposta, compartilhado entre o serve e o `alana warmup`). Respostas mais
+// velhas que ttl são ignoradas.
+func openAnswerCache(spec string, ttl time.Duration) (answerCache, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "":
+		return nil, nil
+	case "memory":
+		return &memoryAnswerCache{ttl: ttl, answers: map[string]*cachedAnswer{}}, nil
+	case "file":
+		if arg == "" {
+			return nil, errors.New("answer cache: file precisa de um diretório")
+		}
+		if err := os.MkdirAll(arg, 0o755); err != nil {
+			return nil, err
+		}
+		return fileAnswerCache{dir: arg, ttl: ttl}, nil
+	}
+	return nil, fmt.Errorf("answer cache: backend desconhecido %q", kind)
+}
+
+// answerCacheTTLFromEnv lê ALANA_ANSWER_CACHE_TTL (ex: 24h; padrão 7 dias)
+func answerCacheTTLFromEnv() time.Duration {
+	raw := os.Getenv("ALANA_ANSWER_CACHE_TTL")
+	if raw == "" {
+		return defaultAnswerCacheTTL
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		engineLog.Warn("ALANA_ANSWER_CACHE_TTL inválido, usando o padrão", "value", raw, "ttl", defaultAnswerCacheTTL)
+		return defaultAnswerCacheTTL
+	}
+	return d
+}
+
+// answerCacheKey identifica uma resposta: a pergunta normalizada, a
+// collection, o que muda a geração (prompt, guardrails, provedor e modelo) e
+// os IDs dos trechos recuperados, na ordem. Como o ID do trecho inclui o hash do
+// conteúdo (ver chunkid), uma re-ingestão que muda os documentos muda a
+// chave, e a resposta antiga deixa de ser usada sozinha.
+func answerCacheKey(question, collection string, opts askOptions, results []SearchResult) string {
+	h := sha256.New()
+	fields := []string{
+		normalizeQuestion(question),
+		collection,
+		opts.PromptTemplate,
+		promptGuardrails.fingerprint(),
+		cmp.Or(opts.Override.Provider, defaultGenerationProvider),
+		opts.Override.Model,
+	}
+	for _, r := range results {
+		fields = append(fields, r.ID)
+	}
+	for _, f := range fields {
+		h.Write([]byte(f))
+		h.Write([]byte{0x1f})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeQuestion ignora caixa, espaços repetidos e a pontuação final
+func normalizeQuestion(q string) string {
+	q = strings.Join(strings.Fields(strings.ToLower(q)), " ")
+	return strings.TrimRight(q, "?!.… ")
+}
+
+// cacheable diz se a resposta pode vir do cache ou ir para ele: sem
+// histórico nem memória de conversa (o contexto muda a resposta) e sem
+// rascunho (o streaming em duas fases não combina com resposta pronta)
+func (o askOptions) cacheable() bool {
+	return o.Cache != cacheBypass && len(o.History) == 0 && o.UserID == "" && o.Draft == nil
+}
+
+// cachedAnswerFor procura a resposta no cache. Erros só vão para o log: sem
+// cache, a pergunta é respondida normalmente.
+func (e *AlanaEngine) cachedAnswerFor(ctx context.Context, key string) *cachedAnswer {
+	cached, err := e.answers.Get(ctx, key)
+	if err != nil {
+		engineLog.WarnContext(ctx, "Cache de respostas indisponível", "err", err)
+		return nil
+	}
+	return cached
+}
+
+// cacheAnswer guarda a resposta gerada; respostas cortadas pelo orçamento
+// de tempo não entram
+func (e *AlanaEngine) cacheAnswer(ctx context.Context, key, question string, a Answer) {
+	if a.Truncated {
+		return
+	}
+	if err := e.answers.Put(ctx, key, &cachedAnswer{Question: question, Text: a.Text, Created: time.Now().UTC()}); err != nil {
+		engineLog.WarnContext(ctx, "Erro ao gravar no cache de respostas", "err", err)
+	}
+}
+
+// memoryAnswerCache guarda as respostas no processo
+type memoryAnswerCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	answers map[string]*cachedAnswer
+}
+
+func (m *memoryAnswerCache) Get(_ context.Context, key string) (*cachedAnswer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a, ok := m.answers[key]
+	if !ok || time.Since(a.Created) > m.ttl {
+		return nil, nil
+	}
+	clone := *a
+	return &clone, nil
+}
+
+func (m *memoryAnswerCache) Put(_ context.Context, key string, a *cachedAnswer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.answers[key]; !ok && len(m.answers) >= maxMemoryCachedAnswers {
+		var oldest string
+		for k, other := range m.answers {
+			if oldest == "" || other.Created.Before(m.answers[oldest].Created) {
+				oldest = k
+			}
+		}
+		delete(m.answers, oldest)
+	}
+	clone := *a
+	m.answers[key] = &clone
+	return nil
+}
+
+// fileAnswerCache grava um JSON por resposta, com a chave como nome. Os
+// expirados ficam no disco até serem sobrescritos.
+type fileAnswerCache struct {
+	dir string
+	ttl time.Duration
+}
+
+func (f fileAnswerCache) path(key string) string {
+	return filepath.Join(f.dir, key+".json")
+}
+
+func (f fileAnswerCache) Get(_ context.Context, key string) (*cachedAnswer, error) {
+	data, err := os.ReadFile(f.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var a cachedAnswer
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("resposta %s: %w", key, err)
+	}
+	if time.Since(a.Created) > f.ttl {
+		return nil, nil
+	}
+	return &a, nil
+}
+
+func (f fileAnswerCache) Put(_ context.Context, key string, a *cachedAnswer) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	path := f.path(key)
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,414 @@
+// Package assemble monta o contexto enviado ao LLM a partir dos trechos
+// recuperados. Context é uma função pura: a mesma entrada com as mesmas
+// opções produz sempre o mesmo texto, byte a byte. A estrutura padrão é
+// coberta por golden files (testdata/*.golden); mudá-la é uma quebra de
+// compatibilidade do prompt e deve vir com os goldens atualizados
+// (go test ./assemble -update).
+package assemble
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Chunk é um trecho recuperado
+type Chunk struct {
+	ID     string
+	Source string
+	Title  string
+	Text   string
+	Page   int
+	Score  float32
+	// Pinned coloca o trecho antes dos demais em qualquer Order e o escolhe
+	// primeiro no BudgetSentence: fixado pelo usuário, ele só fica de fora
+	// se não couber
+	Pinned bool
+}
+
+// Order define a ordem dos trechos no contexto
+type Order int
+
+const (
+	// OrderRetrieval mantém a ordem recebida (a do ranking da busca)
+	OrderRetrieval Order = iota
+	// OrderScore ordena por score decrescente; empates pelo ID
+	OrderScore
+	// OrderDocument agrupa por documento: Source, página e ID
+	OrderDocument
+)
+
+// Budget define o que fazer com um trecho que não cabe no limite
+type Budget int
+
+const (
+	// BudgetStop para no primeiro trecho que não cabe e anexa TruncationNotice
+	BudgetStop Budget = iota
+	// BudgetSkip pula os trechos que não cabem, tenta os seguintes e anexa
+	// TruncationNotice no final se algum ficou de fora
+	BudgetSkip
+	// BudgetTrim corta o trecho que não cabe no espaço restante e para
+	BudgetTrim
+	// BudgetSentence escolhe os trechos por score (maior primeiro) até o
+	// limite. O primeiro que não cabe é cortado no fim da última frase que
+	// cabe, seguido de TrimMarker; se sobraria menos de minTrimFraction do
+	// texto, é descartado inteiro. Os escolhidos saem na ordem de Order e
+	// TruncationNotice fecha o contexto se algo foi cortado ou descartado.
+	BudgetSentence
+)
+
+// minTrimFraction é a menor fração de um trecho que vale a pena manter no
+// BudgetSentence; abaixo disso o trecho sai inteiro
+const minTrimFraction = 0.2
+
+// Options controla a montagem. O valor zero não é útil; parta de DefaultOptions.
+type Options struct {
+	Order  Order
+	Budget Budget
+
+	// TokenLimit é o orçamento do contexto em tokens; CharsPerToken converte
+	// para caracteres (bytes do texto). TokenLimit zero desliga o limite.
+	TokenLimit    int
+	CharsPerToken int
+	// Tokenizer, se definido, mede o orçamento em tokens do modelo em vez
+	// da aproximação por CharsPerToken
+	Tokenizer Tokenizer
+
+	// Header abre o contexto; BlockFormat formata cada trecho com os verbos
+	// (rótulo, página, score, texto); Separator vai depois de cada bloco.
+	Header           string
+	BlockFormat      string
+	Separator        string
+	TruncationNotice string
+	// TrimMarker marca o fim de um trecho cortado (BudgetSentence)
+	TrimMarker string
+}
+
+// Tokenizer conta os tokens de um texto no vocabulário do modelo
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// DefaultOptions reproduz o formato histórico do Alana
+func DefaultOptions(tokenLimit int) Options {
+	return Options{
+		Order:            OrderRetrieval,
+		Budget:           BudgetStop,
+		TokenLimit:       tokenLimit,
+		CharsPerToken:    3,
+		Header:           "Contexto recuperado dos documentos:\n\n",
+		BlockFormat:      "--- [%s/Pág %d | Score %.2f] ---\n%s",
+		Separator:        "\n\n",
+		TruncationNotice: "[Contexto truncado por limite de tokens]",
+		TrimMarker:       "(truncado)",
+	}
+}
+
+// Context monta o contexto. Não altera chunks.
+func Context(chunks []Chunk, opts Options) string {
+	ordered := order(chunks, opts.Order)
+	m := newMeter(opts)
+
+	if opts.Budget == BudgetSentence {
+		return sentenceBudget(ordered, opts, m)
+	}
+
+	var b strings.Builder
+	b.Grow(capacity(ordered, opts, m.byteLimit()))
+	b.WriteString(opts.Header)
+	used := m.cost(opts.Header)
+
+	// block é reaproveitado entre os trechos: formatar direto em bytes evita
+	// uma string nova por trecho só para medir se ele cabe
+	var block []byte
+	skipped := false
+	for _, c := range ordered {
+		block = appendBlock(block[:0], opts, c, c.Text)
+		if n := m.costBytes(block); m.fits(used, n) {
+			b.Write(block)
+			used += n
+			continue
+		}
+
+		switch opts.Budget {
+		case BudgetSkip:
+			skipped = true
+			continue
+		case BudgetTrim:
+			prefix := fmt.Sprintf(opts.BlockFormat, Label(c), c.Page, c.Score, "")
+			room := m.limit - used - m.cost(prefix) - m.cost(opts.Separator) - m.cost(opts.TruncationNotice)
+			if room > 0 {
+				b.WriteString(prefix)
+				b.WriteString(m.prefix(c.Text, room))
+				b.WriteString(opts.Separator)
+			}
+		}
+		b.WriteString(opts.TruncationNotice)
+		return b.String()
+	}
+
+	if skipped {
+		b.WriteString(opts.TruncationNotice)
+	}
+	return b.String()
+}
+
+// sentenceBudget implementa BudgetSentence. Cada trecho é formatado uma única
+// vez, num buffer só (blocks); a saída copia os blocos escolhidos de lá.
+func sentenceBudget(ordered []Chunk, opts Options, m meter) string {
+	byScore := make([]int, len(ordered))
+	for i := range byScore {
+		byScore[i] = i
+	}
+	slices.SortStableFunc(byScore, func(a, b int) int {
+		return cmp.Or(pinnedFirst(ordered[a], ordered[b]), cmp.Compare(ordered[b].Score, ordered[a].Score))
+	})
+
+	// chosen[i] diz se ordered[i] entra inteiro; trimmed é o bloco do trecho
+	// cortado (trimmedAt é o seu índice, -1 se nenhum)
+	blocks := make([]byte, 0, capacity(ordered, opts, m.byteLimit()))
+	spans := make([][2]int, len(ordered))
+	chosen := make([]bool, len(ordered))
+	trimmed, trimmedAt := "", -1
+	used := m.cost(opts.Header)
+	cut := false
+	for _, i := range byScore {
+		c := ordered[i]
+		start := len(blocks)
+		blocks = appendBlock(blocks, opts, c, c.Text)
+		if n := m.costBytes(blocks[start:]); m.fits(used, n) {
+			spans[i] = [2]int{start, len(blocks)}
+			chosen[i] = true
+			used += n
+			continue
+		}
+		blocks = blocks[:start]
+
+		cut = true
+		suffix := " " + opts.TrimMarker
+		room := m.limit - used - m.costBytes(appendBlock(nil, opts, c, "")) - m.cost(suffix) - m.cost(opts.TruncationNotice)
+		if kept := m.sentencePrefix(c.Text, room); kept != "" && float64(len(kept)) >= minTrimFraction*float64(len(c.Text)) {
+			trimmed, trimmedAt = string(appendBlock(nil, opts, c, kept+suffix)), i
+		}
+		break
+	}
+
+	var b strings.Builder
+	b.Grow(len(opts.Header) + len(blocks) + len(trimmed) + len(opts.TruncationNotice))
+	b.WriteString(opts.Header)
+	for i := range ordered {
+		switch {
+		case chosen[i]:
+			b.Write(blocks[spans[i][0]:spans[i][1]])
+		case i == trimmedAt:
+			b.WriteString(trimmed)
+		}
+	}
+	if cut {
+		b.WriteString(opts.TruncationNotice)
+	}
+	return b.String()
+}
+
+// appendBlock acrescenta a dst o bloco de c com o texto informado, já com o
+// separador
+func appendBlock(dst []byte, opts Options, c Chunk, text string) []byte {
+	dst = fmt.Appendf(dst, opts.BlockFormat, Label(c), c.Page, c.Score, text)
+	return append(dst, opts.Separator...)
+}
+
+// meter mede o orçamento: em bytes, com o limite convertido por
+// CharsPerToken, ou em tokens do Tokenizer
+type meter struct {
+	// limit é o orçamento na unidade do meter (-1 = sem limite)
+	limit int
+	tok   Tokenizer
+}
+
+func newMeter(opts Options) meter {
+	m := meter{limit: -1, tok: opts.Tokenizer}
+	switch {
+	case opts.TokenLimit <= 0:
+	case m.tok != nil:
+		m.limit = opts.TokenLimit
+	default:
+		m.limit = opts.TokenLimit * max(opts.CharsPerToken, 1)
+	}
+	return m
+}
+
+func (m meter) cost(s string) int {
+	if m.tok != nil {
+		return m.tok.Count(s)
+	}
+	return len(s)
+}
+
+func (m meter) costBytes(b []byte) int {
+	if m.tok != nil {
+		return m.tok.Count(string(b))
+	}
+	return len(b)
+}
+
+func (m meter) fits(used, n int) bool { return m.limit < 0 || used+n <= m.limit }
+
+// byteLimit é o limite em bytes para reservar memória (-1 = desconhecido)
+func (m meter) byteLimit() int {
+	if m.tok != nil {
+		return -1
+	}
+	return m.limit
+}
+
+// prefix corta s no maior prefixo que custa até n, sem partir um caractere
+// UTF-8
+func (m meter) prefix(s string, n int) string {
+	if m.tok == nil {
+		return truncateBytes(s, n)
+	}
+	if m.cost(s) <= n {
+		return s
+	}
+	// Busca binária no tamanho em bytes: o custo cresce com o prefixo
+	lo, hi := 0, len(s)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if m.cost(truncateBytes(s, mid)) <= n {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return truncateBytes(s, lo)
+}
+
+// sentencePrefix é o sentencePrefix com o orçamento na unidade do meter
+func (m meter) sentencePrefix(s string, n int) string {
+	if m.tok == nil {
+		return sentencePrefix(s, n)
+	}
+	if n <= 0 {
+		return ""
+	}
+	if m.cost(s) <= n {
+		return s
+	}
+	ends := sentenceEnds(s)
+	best := ""
+	lo, hi := 0, len(ends)-1
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		if kept := ends[mid]; m.cost(kept) <= n {
+			best, lo = kept, mid+1
+		} else {
+			hi = mid - 1
+		}
+	}
+	return best
+}
+
+// sentenceEnds são os prefixos de s que terminam no fim de uma frase, pelas
+// mesmas regras de sentencePrefix, do menor para o maior
+func sentenceEnds(s string) []string {
+	var ends []string
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\n':
+			if kept := strings.TrimSpace(s[:i]); kept != "" {
+				ends = append(ends, kept)
+			}
+		case '.', '!', '?':
+			if i+1 < len(s) && (s[i+1] == ' ' || s[i+1] == '\n') {
+				ends = append(ends, s[:i+1])
+			}
+		}
+	}
+	return ends
+}
+
+// blockOverhead estima os bytes que BlockFormat acrescenta além dos verbos
+// (página e score formatados)
+const blockOverhead = 16
+
+// capacity estima o tamanho do contexto para reservar a memória de uma vez:
+// todos os blocos, limitado ao orçamento de caracteres
+func capacity(chunks []Chunk, opts Options, charLimit int) int {
+	n := len(opts.Header) + len(opts.TruncationNotice)
+	for _, c := range chunks {
+		n += len(opts.BlockFormat) + blockOverhead + len(Label(c)) + len(c.Text) + len(opts.Separator)
+		if charLimit >= 0 && n >= charLimit+len(opts.TruncationNotice) {
+			return charLimit + len(opts.TruncationNotice)
+		}
+	}
+	return n
+}
+
+// sentencePrefix devolve o maior prefixo de s com até n bytes que termina
+// no fim de uma frase (., ! ou ? seguido de espaço, ou quebra de linha).
+// Devolve "" se nenhuma frase inteira cabe.
+func sentencePrefix(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	for i := n - 1; i >= 0; i-- {
+		switch s[i] {
+		case '\n':
+			return strings.TrimSpace(s[:i])
+		case '.', '!', '?':
+			if i+1 < len(s) && (s[i+1] == ' ' || s[i+1] == '\n') {
+				return s[:i+1]
+			}
+		}
+	}
+	return ""
+}
+
+// Label é o rótulo do trecho no cabeçalho do bloco, o nome pelo qual o
+// modelo o cita
+func Label(c Chunk) string {
+	if c.Title != "" {
+		return c.Title
+	}
+	return "Fonte"
+}
+
+func order(chunks []Chunk, o Order) []Chunk {
+	out := slices.Clone(chunks)
+	switch o {
+	case OrderScore:
+		slices.SortStableFunc(out, func(a, b Chunk) int {
+			return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.ID, b.ID))
+		})
+	case OrderDocument:
+		slices.SortStableFunc(out, func(a, b Chunk) int {
+			return cmp.Or(cmp.Compare(a.Source, b.Source), cmp.Compare(a.Page, b.Page), cmp.Compare(a.ID, b.ID))
+		})
+	}
+	slices.SortStableFunc(out, pinnedFirst)
+	return out
+}
+
+// pinnedFirst ordena os trechos fixados antes dos outros
+func pinnedFirst(a, b Chunk) int {
+	switch {
+	case a.Pinned == b.Pinned:
+		return 0
+	case a.Pinned:
+		return -1
+	}
+	return 1
+}
+
+// truncateBytes corta s em até n bytes sem partir um caractere UTF-8
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
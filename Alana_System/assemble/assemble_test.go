@@ -0,0 +1,146 @@
+package assemble
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "regrava os golden files em testdata/")
+
+var fixture = []Chunk{
+	{ID: "c3", Source: "politica.pdf", Title: "Política de reembolso", Text: "O reembolso é feito em até 30 dias.", Page: 2, Score: 0.71},
+	{ID: "a1", Source: "manual.pdf", Title: "", Text: "Pedidos são enviados em 48 horas úteis.", Page: 7, Score: 0.83},
+	{ID: "b2", Source: "politica.pdf", Title: "Política de reembolso", Text: "Produtos com defeito têm troca garantida por 90 dias após a entrega.", Page: 1, Score: 0.71},
+	{ID: "d4", Source: "faq.md", Title: "FAQ", Text: "Atendimento de segunda a sexta, das 9h às 18h.", Page: 0, Score: 0.42},
+}
+
+// longFixture tem trechos com várias frases, para o corte por frase
+var longFixture = []Chunk{
+	{ID: "e5", Source: "garantia.pdf", Title: "Garantia", Text: "A garantia legal é de 90 dias. A garantia estendida pode ser contratada na compra. Ela cobre defeitos de fabricação por mais 12 meses.", Page: 3, Score: 0.64},
+	{ID: "f6", Source: "entrega.pdf", Title: "Entrega", Text: "O frete é grátis acima de R$ 200. Entregas expressas chegam no dia seguinte.", Page: 1, Score: 0.88},
+	{ID: "g7", Source: "faq.md", Title: "FAQ", Text: "Trocas exigem a nota fiscal. O produto deve estar na embalagem original, sem sinais de uso, com todos os acessórios e manuais.", Page: 0, Score: 0.31},
+}
+
+func TestContextGolden(t *testing.T) {
+	custom := DefaultOptions(0)
+	custom.Header = "<contexto>\n"
+	custom.BlockFormat = "<trecho fonte=%q pagina=\"%d\" score=\"%.3f\">\n%s\n</trecho>"
+	custom.Separator = "\n"
+
+	cases := []struct {
+		name   string
+		opts   Options
+		chunks []Chunk // nil = fixture
+	}{
+		{"default", DefaultOptions(512), nil},
+		{"unlimited", DefaultOptions(0), nil},
+		{"order_score", withOrder(DefaultOptions(0), OrderScore), nil},
+		{"order_document", withOrder(DefaultOptions(0), OrderDocument), nil},
+		// 110 tokens: cabem os dois primeiros trechos e o quarto, mas não o terceiro
+		{"budget_stop", DefaultOptions(110), nil},
+		{"budget_skip", withBudget(DefaultOptions(110), BudgetSkip), nil},
+		{"budget_trim", withBudget(DefaultOptions(110), BudgetTrim), nil},
+		{"custom_format", custom, nil},
+		// 110 tokens: o terceiro por score (b2) não cabe e menos de 20% dele caberia
+		{"budget_sentence_drop", withBudget(DefaultOptions(110), BudgetSentence), nil},
+		// 100 tokens: o trecho de menor score que cabe em parte é cortado no fim da frase
+		{"budget_sentence_trim", withBudget(DefaultOptions(100), BudgetSentence), longFixture},
+		// 55 palavras: o orçamento medido pelo Tokenizer, não por CharsPerToken
+		{"tokenizer_sentence", withTokenizer(withBudget(DefaultOptions(55), BudgetSentence), wordTokenizer{}), longFixture},
+		{"tokenizer_trim", withTokenizer(withBudget(DefaultOptions(30), BudgetTrim), wordTokenizer{}), longFixture},
+		// d4, o de menor score, fixado: abre o contexto e entra antes de b2
+		{"pinned_order_score", withOrder(DefaultOptions(110), OrderScore), pinned(fixture, "d4")},
+		{"pinned_budget_sentence", withBudget(DefaultOptions(110), BudgetSentence), pinned(fixture, "d4")},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			chunks := tc.chunks
+			if chunks == nil {
+				chunks = fixture
+			}
+			got := Context(chunks, tc.opts)
+			path := filepath.Join("testdata", tc.name+".golden")
+
+			if *update {
+				if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (rode go test ./assemble -update para criar)", err)
+			}
+			if got != string(want) {
+				t.Errorf("contexto diferente de %s:\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+			}
+		})
+	}
+}
+
+func TestContextDoesNotMutateInput(t *testing.T) {
+	in := append([]Chunk(nil), fixture...)
+	Context(in, withOrder(DefaultOptions(0), OrderScore))
+	for i := range in {
+		if in[i] != fixture[i] {
+			t.Fatalf("chunk %d alterado: %+v", i, in[i])
+		}
+	}
+}
+
+func TestTrimKeepsUTF8(t *testing.T) {
+	opts := withBudget(DefaultOptions(0), BudgetTrim)
+	opts.TokenLimit, opts.CharsPerToken = 1, 90
+	got := Context([]Chunk{{ID: "x", Text: "ção ção ção ção ção ção ção ção ção ção ção ção"}}, opts)
+	if len(got) > 90 {
+		t.Errorf("contexto com %d bytes excede o limite de 90", len(got))
+	}
+	if !validUTF8(got) {
+		t.Errorf("contexto com UTF-8 inválido: %q", got)
+	}
+}
+
+func validUTF8(s string) bool {
+	for _, r := range s {
+		if r == '\uFFFD' {
+			return false
+		}
+	}
+	return true
+}
+
+func withOrder(o Options, order Order) Options {
+	o.Order = order
+	return o
+}
+
+func withBudget(o Options, b Budget) Options {
+	o.Budget = b
+	return o
+}
+
+func withTokenizer(o Options, t Tokenizer) Options {
+	o.Tokenizer = t
+	return o
+}
+
+// pinned devolve uma cópia dos trechos com os IDs fixados
+func pinned(chunks []Chunk, ids ...string) []Chunk {
+	out := slices.Clone(chunks)
+	for i := range out {
+		out[i].Pinned = slices.Contains(ids, out[i].ID)
+	}
+	return out
+}
+
+// wordTokenizer conta uma palavra por token, para os goldens não dependerem
+// de um vocabulário BPE
+type wordTokenizer struct{}
+
+func (wordTokenizer) Count(s string) int { return len(strings.Fields(s)) }
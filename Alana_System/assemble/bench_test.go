@@ -0,0 +1,81 @@
+package assemble
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// syntheticChunks gera n trechos de ~800 bytes com várias frases, como os do
+// chunker da ingestão, com scores e fontes variados
+func syntheticChunks(n int) []Chunk {
+	sentence := "A política de reembolso cobre produtos com defeito em até trinta dias. "
+	chunks := make([]Chunk, n)
+	for i := range chunks {
+		chunks[i] = Chunk{
+			ID:     fmt.Sprintf("chunk-%05d", i),
+			Source: fmt.Sprintf("doc-%03d.pdf", i%97),
+			Title:  fmt.Sprintf("Documento %d", i%97),
+			Text:   strings.Repeat(sentence, 11),
+			Page:   i % 40,
+			Score:  float32((i*7919)%1000) / 1000,
+		}
+	}
+	return chunks
+}
+
+// Orçamento de desempenho: alocações por trecho numa chamada de Context.
+// Alocações são determinísticas (tempo não é); passar do teto indica uma
+// regressão no caminho quente, como formatar o mesmo bloco mais de uma vez. As
+// 3 que sobram são os argumentos de BlockFormat passados ao fmt.
+const allocsPerChunk = 3
+
+func TestContextAllocBudget(t *testing.T) {
+	const n = 1000
+	chunks := syntheticChunks(n)
+	for _, budget := range []Budget{BudgetStop, BudgetSkip, BudgetTrim, BudgetSentence} {
+		opts := withBudget(DefaultOptions(0), budget)
+		allocs := testing.AllocsPerRun(5, func() { Context(chunks, opts) })
+		if limit := float64(allocsPerChunk*n + 16); allocs > limit {
+			t.Errorf("budget %d: %.0f alocações por chamada, orçamento %.0f", budget, allocs, limit)
+		}
+	}
+}
+
+func BenchmarkContext(b *testing.B) {
+	budgets := []struct {
+		name   string
+		budget Budget
+	}{
+		{"stop", BudgetStop},
+		{"skip", BudgetSkip},
+		{"trim", BudgetTrim},
+		{"sentence", BudgetSentence},
+	}
+	for _, n := range []int{10, 100, 1000} {
+		chunks := syntheticChunks(n)
+		for _, bb := range budgets {
+			// Limite de metade do total: exercita o caminho de corte
+			opts := withBudget(DefaultOptions(n*800/3/2), bb.budget)
+			b.Run(fmt.Sprintf("%s/%d", bb.name, n), func(b *testing.B) {
+				b.ReportAllocs()
+				for b.Loop() {
+					Context(chunks, opts)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkContextOrder(b *testing.B) {
+	chunks := syntheticChunks(1000)
+	for _, o := range []Order{OrderScore, OrderDocument} {
+		opts := withOrder(DefaultOptions(0), o)
+		b.Run(fmt.Sprint(o), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				Context(chunks, opts)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==============================
+// Perguntas em lote
+// ==============================
+
+// defaultBatchParallel é quantas perguntas do lote rodam ao mesmo tempo
+const defaultBatchParallel = 4
+
+// batchRecord é uma linha do JSONL de saída do `alana ask -file`
+type batchRecord struct {
+	// Line é a linha da pergunta no arquivo de entrada (a partir de 1)
+	Line          int        `json:"line"`
+	Question      string     `json:"question"`
+	Answer        string     `json:"answer,omitempty"`
+	LatencyMS     int64      `json:"latency_ms"`
+	Sources       []Citation `json:"sources,omitempty"`
+	Abstained     bool       `json:"abstained,omitempty"`
+	EmptyCorpus   bool       `json:"empty_corpus,omitempty"`
+	Clarification bool       `json:"clarification,omitempty"`
+	Truncated     bool       `json:"truncated,omitempty"`
+	Cached        bool       `json:"cached,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// batchQuestion é uma pergunta do arquivo de entrada com a sua linha
+type batchQuestion struct {
+	Line     int
+	Question string
+}
+
+// readBatchQuestions lê uma pergunta por linha; linhas vazias e comentários
+// (#) são ignorados. "-" lê da entrada padrão.
+func readBatchQuestions(path string) ([]batchQuestion, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var questions []batchQuestion
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		q := strings.TrimSpace(sc.Text())
+		if q == "" || strings.HasPrefix(q, "#") {
+			continue
+		}
+		questions = append(questions, batchQuestion{Line: n, Question: q})
+	}
+	return questions, sc.Err()
+}
+
+// orderedWriter escreve os registros na ordem da entrada, à medida que os
+// anteriores ficam prontos: um lote interrompido deixa um prefixo válido
+type orderedWriter struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	next    int
+	pending map[int]batchRecord
+}
+
+func (w *orderedWriter) put(i int, rec batchRecord) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending[i] = rec
+	for {
+		rec, ok := w.pending[w.next]
+		if !ok {
+			return nil
+		}
+		delete(w.pending, w.next)
+		w.next++
+		if err := w.enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// runAsk implementa `alana ask [-profile p] <pergunta...>` e o modo em lote
+// `alana ask -file perguntas.txt -out respostas.jsonl [-parallel 4]`: o
+// pipeline completo (busca, contexto e geração) sobre cada pergunta do
+// arquivo, com no máximo -parallel ao mesmo tempo, e uma linha JSON por
+// pergunta com a resposta, a latência e as fontes, na ordem da entrada. Para
+// avaliar a base, o cache de respostas fica de fora (-cache o liga).
+func runAsk(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("ask", flag.ContinueOnError)
+	file := fs.String("file", "", "arquivo com uma pergunta por linha (- = entrada padrão)")
+	out := fs.String("out", "", "JSONL de saída do lote (vazio = saída padrão)")
+	parallel := fs.Int("parallel", defaultBatchParallel, "perguntas do lote respondidas ao mesmo tempo")
+	profile := fs.String("profile", "", "perfil de config/collections.yaml")
+	useCache := fs.Bool("cache", false, "usa o cache de respostas no lote")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*file == "") == (fs.NArg() == 0) {
+		return errors.New("uso: ask [-profile P] <pergunta...> | ask -file perguntas.txt [-out respostas.jsonl] [-parallel N] [-cache]")
+	}
+	if *parallel < 1 {
+		return fmt.Errorf("-parallel precisa ser ao menos 1, veio %d", *parallel)
+	}
+
+	opts, err := engine.collections.askOptions(engine.collection, *profile, retrievalSettings{})
+	if err != nil {
+		return err
+	}
+	opts.TokenLimit = engine.models.contextTokenLimit("")
+
+	if *file == "" {
+		answer, err := engine.Ask(ctx, strings.Join(fs.Args(), " "), opts)
+		if err != nil {
+			return err
+		}
+		fmt.Println(answer.Footnoted())
+		return nil
+	}
+
+	// Sem alguém para responder, esclarecimento vira resposta
+	opts.Clarify = false
+	if !*useCache {
+		opts.Cache = cacheBypass
+	}
+	questions, err := readBatchQuestions(*file)
+	if err != nil {
+		return err
+	}
+	if len(questions) == 0 {
+		return fmt.Errorf("nenhuma pergunta em %s", *file)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	writer := &orderedWriter{enc: json.NewEncoder(w), pending: map[int]batchRecord{}}
+	writer.enc.SetEscapeHTML(false)
+
+	start := time.Now()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failed   int
+		writeErr error
+	)
+	slots := make(chan struct{}, *parallel)
+	for i, q := range questions {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			rec := askBatchQuestion(ctx, engine, q, opts)
+			mu.Lock()
+			if rec.Error != "" {
+				failed++
+				cliLog.Error("Pergunta do lote falhou", "line", q.Line, "err", rec.Error)
+			}
+			mu.Unlock()
+			if err := writer.put(i, rec); err != nil {
+				mu.Lock()
+				if writeErr == nil {
+					writeErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if writeErr != nil {
+		return writeErr
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if *out != "" {
+		cliLog.Info("Respostas gravadas", "n", len(questions), "file", *out, "elapsed", time.Since(start).Round(time.Millisecond))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d de %d perguntas falharam", failed, len(questions))
+	}
+	return nil
+}
+
+// askBatchQuestion responde uma pergunta do lote; o erro vai no registro
+func askBatchQuestion(ctx context.Context, engine *AlanaEngine, q batchQuestion, opts askOptions) batchRecord {
+	rec := batchRecord{Line: q.Line, Question: q.Question}
+	start := time.Now()
+	answer, err := engine.Ask(ctx, q.Question, opts)
+	rec.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		rec.Error = redactForLog(err.Error())
+		return rec
+	}
+	rec.Answer, rec.Sources = answer.Citations()
+	rec.Abstained = answer.Abstained
+	rec.EmptyCorpus = answer.EmptyCorpus
+	rec.Clarification = answer.Clarification
+	rec.Truncated = answer.Truncated
+	rec.Cached = answer.Cached
+	return rec
+}
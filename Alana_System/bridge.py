@@ -16,14 +16,27 @@ import logging
 src_path = Path(__file__).resolve().parent / 'src'
 sys.path.insert(0, str(src_path))
 
-from fastapi import FastAPI
+import base64
+import json
+import os
+import tempfile
+import threading
+
+from fastapi import FastAPI, HTTPException, Request
+from fastapi.responses import StreamingResponse
+from starlette.concurrency import run_in_threadpool
 from pydantic import BaseModel
-from typing import List
+from typing import Dict, List, Optional
 from sentence_transformers import CrossEncoder
 
 try:
+    from alana_system.api import protocol
     from alana_system.embeddings.embedder import TextEmbedder
+    from alana_system.embeddings.embedding_cache import open_embedding_cache
     from alana_system.inference.llm_engine import LLMEngine
+    from alana_system.ingestion.cleaner import TextCleaner
+    from alana_system.ingestion.text_extractor import PageText
+    from alana_system.preprocessing.chunker import TextChunker
 except ImportError as e:
     logging.error(f"Erro ao importar módulos do Alana System: {e}")
     logging.error("Verifique se o 'src_path' está correto e se o ambiente virtual está ativo.")
@@ -48,15 +61,20 @@ logger.info("Iniciando o Python Sidecar para o Alana System...")
 # --- Configurações ---
 # Use as mesmas configurações do seu script run_search.py
 MODEL_PATH = "models/Meta-Llama-3-8B-Instruct-Q4_K_M.gguf"
+EMBEDDING_MODEL = "paraphrase-multilingual-MiniLM-L12-v2"
 EMBEDDER_DEVICE = "cuda" # "cuda" para GPU, "cpu" para CPU
 RERANKER_DEVICE = "cuda" # "cuda" para GPU, "cpu" para CPU
 LLM_GPU_LAYERS = -1      # -1 para usar o máximo da GPU, 0 para CPU
+WHISPER_MODEL = "small"  # Carregado só no primeiro pedido de transcrição
 
 # --- Carregamento dos Modelos ---
 # Os modelos são carregados uma única vez na inicialização do servidor.
 try:
     logger.info("Carregando modelo de embedding...")
-    embedder = TextEmbedder(device=EMBEDDER_DEVICE)
+    # Vetores de trechos já vistos (ALANA_EMBED_CACHE), para as notas que o
+    # orchestrator re-ingere; vale para todos os modelos (a chave inclui o nome)
+    embed_cache = open_embedding_cache()
+    embedder = TextEmbedder(model_name=EMBEDDING_MODEL, device=EMBEDDER_DEVICE, cache=embed_cache)
     logger.info("✅ Modelo de embedding carregado.")
 except Exception as e:
     logger.exception("❌ Falha crítica ao carregar o TextEmbedder.")
@@ -86,6 +104,79 @@ except Exception as e:
     sys.exit(1)
 
 
+# Mesmos parâmetros de chunking do run_ingestion.py
+text_cleaner = TextCleaner()
+text_chunker = TextChunker(max_chars=800, overlap_chars=200)
+
+
+# --- Modelos alternativos (override por pedido) ---
+# Carregados sob demanda a partir de models/ e mantidos em memória.
+MODELS_DIR = Path("models")
+_extra_llms: Dict[str, LLMEngine] = {}
+_extra_llms_lock = threading.Lock()
+
+
+def get_llm(model: Optional[str]) -> LLMEngine:
+    """Devolve o LLM padrão ou o modelo pedido, restrito a arquivos em models/."""
+    if not model or Path(model).name == Path(MODEL_PATH).name:
+        return llm
+
+    path = MODELS_DIR / Path(model).name
+    if not path.is_file():
+        raise HTTPException(status_code=400, detail=f"Modelo não encontrado: {path.name}")
+
+    with _extra_llms_lock:
+        if path.name not in _extra_llms:
+            logger.info(f"Carregando modelo alternativo: {path}")
+            _extra_llms[path.name] = LLMEngine(model_path=str(path), n_gpu_layers=LLM_GPU_LAYERS)
+        return _extra_llms[path.name]
+
+
+# --- Embedders por collection ---
+# Cada collection pode declarar o próprio modelo de embedding
+# (config/collections.yaml); o orquestrador Go manda o nome em /embed e /chunk.
+_extra_embedders: Dict[str, TextEmbedder] = {}
+_extra_embedders_lock = threading.Lock()
+
+
+def get_embedder(model: Optional[str]) -> TextEmbedder:
+    """Devolve o embedder padrão ou carrega (uma vez) o modelo pedido."""
+    if not model or model == EMBEDDING_MODEL:
+        return embedder
+
+    with _extra_embedders_lock:
+        if model not in _extra_embedders:
+            logger.info(f"Carregando modelo de embedding alternativo: {model}")
+            try:
+                _extra_embedders[model] = TextEmbedder(model_name=model, device=EMBEDDER_DEVICE, cache=embed_cache)
+            except Exception as e:
+                raise HTTPException(status_code=400, detail=f"Modelo de embedding indisponível: {model} ({e})")
+        return _extra_embedders[model]
+
+
+# --- Transcrição (perguntas por voz) ---
+_transcriber = None
+_transcriber_lock = threading.Lock()
+
+
+def get_transcriber():
+    """Carrega o Whisper sob demanda; a maioria dos deploys não usa voz."""
+    global _transcriber
+    with _transcriber_lock:
+        if _transcriber is None:
+            from alana_system.ingestion.audio_transcriber import AudioTranscriber
+            _transcriber = AudioTranscriber(model_size=WHISPER_MODEL, device=EMBEDDER_DEVICE)
+        return _transcriber
+
+
+def transcribe_bytes(audio: bytes, suffix: str) -> str:
+    with tempfile.NamedTemporaryFile(suffix=suffix) as tmp:
+        tmp.write(audio)
+        tmp.flush()
+        pages = get_transcriber().transcribe(Path(tmp.name))
+    return " ".join(p.text for p in pages).strip()
+
+
 # =========================================================
 # API SERVER (FastAPI)
 # =========================================================
@@ -95,12 +186,95 @@ app = FastAPI(
     version="1.1.0" # Versão atualizada
 )
 
+
+def setup_tracing(app: FastAPI) -> None:
+    """
+    Liga o OpenTelemetry se houver coletor configurado (as mesmas variáveis
+    OTEL_EXPORTER_OTLP_* do orquestrador Go). Os spans do sidecar continuam o
+    trace do Go pelo cabeçalho traceparent, então embed, rerank e generate
+    aparecem dentro da pergunta no Jaeger/Tempo. Sem os pacotes opentelemetry
+    instalados, o sidecar roda sem tracing.
+    """
+    if not (os.environ.get("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") or os.environ.get("OTEL_EXPORTER_OTLP_ENDPOINT")):
+        return
+    try:
+        from opentelemetry import trace
+        from opentelemetry.exporter.otlp.proto.http.trace_exporter import OTLPSpanExporter
+        from opentelemetry.instrumentation.fastapi import FastAPIInstrumentor
+        from opentelemetry.sdk.resources import Resource
+        from opentelemetry.sdk.trace import TracerProvider
+        from opentelemetry.sdk.trace.export import BatchSpanProcessor
+    except ImportError:
+        logger.warning("OTEL_EXPORTER_OTLP_* definido, mas os pacotes opentelemetry não estão instalados; tracing desligado.")
+        return
+
+    service = os.environ.get("OTEL_SERVICE_NAME", "alana-sidecar")
+    provider = TracerProvider(resource=Resource.create({"service.name": service}))
+    # O exportador lê endpoint e cabeçalhos das variáveis OTEL_EXPORTER_OTLP_*
+    provider.add_span_processor(BatchSpanProcessor(OTLPSpanExporter()))
+    trace.set_tracer_provider(provider)
+    FastAPIInstrumentor.instrument_app(app, excluded_urls="health")
+    logger.info(f"🔭 Tracing OpenTelemetry ligado ({service})")
+
+
+setup_tracing(app)
+
+
+@app.middleware("http")
+async def protocol_version(request: Request, call_next):
+    """
+    Devolve a versão do contrato usada na resposta (ver
+    alana_system/api/protocol.py): a maior entre as que o orquestrador
+    anunciou e as que o sidecar entende.
+    """
+    response = await call_next(request)
+    response.headers[protocol.PROTOCOL_HEADER] = str(protocol.negotiate(request.headers.get(protocol.PROTOCOL_HEADER)))
+    return response
+
+
+def llm_name(model: Optional[str]) -> str:
+    """Nome do modelo que atende o pedido, como em get_llm."""
+    return Path(model).name if model else Path(MODEL_PATH).name
+
 # --- Definição dos Schemas (Contratos da API) ---
+# Formatos binários dos vetores (ver vecenc.go): base64 de floats
+# little-endian em "data", no lugar da lista JSON. Sem encoding, a resposta
+# continua sendo a lista.
+VECTOR_ENCODINGS = {"float32": "<f4", "float16": "<f2"}
+
 class EmbedRequest(BaseModel):
     text: str
+    # Modelo de embedding da collection (vazio = EMBEDDING_MODEL)
+    model: Optional[str] = None
+    # float32 ou float16 (vazio = lista JSON)
+    encoding: Optional[str] = None
 
 class EmbedResponse(BaseModel):
-    vector: list[float]
+    vector: Optional[list[float]] = None
+    data: Optional[str] = None
+    dtype: Optional[str] = None
+
+class EmbedBatchRequest(BaseModel):
+    # Trechos de documento (não perguntas), vetorizados como no embed_chunks
+    texts: List[str]
+    model: Optional[str] = None
+    encoding: Optional[str] = None
+
+class EmbedBatchResponse(BaseModel):
+    vectors: Optional[List[list[float]]] = None
+    # Com encoding, os vetores vão concatenados em data, dim componentes cada
+    data: Optional[str] = None
+    dtype: Optional[str] = None
+    dim: Optional[int] = None
+    # Modelo que gerou os vetores (proveniência dos chunks do orchestrator)
+    model: Optional[str] = None
+
+def encode_vectors(vectors, encoding: str) -> str:
+    """Codifica um vetor (ou uma matriz, linha a linha) no formato binário."""
+    if encoding not in VECTOR_ENCODINGS:
+        raise HTTPException(status_code=400, detail=f"encoding desconhecido: {encoding!r} (use float32 ou float16)")
+    raw = vectors.astype(VECTOR_ENCODINGS[encoding], copy=False).tobytes()
+    return base64.b64encode(raw).decode("ascii")
 
 class RerankRequest(BaseModel):
     query: str
@@ -112,18 +286,71 @@ class RerankResponse(BaseModel):
 class GenerateRequest(BaseModel):
     query: str
     context: str
+    # Override por pedido (já autorizado pelo orquestrador Go)
+    provider: Optional[str] = None
+    model: Optional[str] = None
+    # Template do prompt com {context} e {question} (padrão da collection)
+    prompt_template: Optional[str] = None
+    # Opções da seção generation.sidecar do config (None = padrão do motor)
+    temperature: Optional[float] = None
+    max_tokens: Optional[int] = None
+
+class GenerateUsage(BaseModel):
+    prompt_tokens: int
+    completion_tokens: int
 
 class GenerateResponse(BaseModel):
     answer: str
+    # A partir do contrato v2 (ver protocol.py)
+    model: Optional[str] = None
+    usage: Optional[GenerateUsage] = None
+
+class TranscribeResponse(BaseModel):
+    text: str
+
+class ChunkRequest(BaseModel):
+    # Nome do documento: entra no ID estável dos chunks, como o caminho em data/raw
+    source: str
+    text: str
+    model: Optional[str] = None
+
+class ChunkItem(BaseModel):
+    chunk_id: str
+    page_number: int
+    text: str
+    vector: list[float]
+
+class ChunkResponse(BaseModel):
+    chunks: List[ChunkItem]
 
 # --- Endpoints da API ---
 @app.post("/embed", response_model=EmbedResponse)
-async def get_embedding(req: EmbedRequest):
+def get_embedding(req: EmbedRequest):
     """Gera o embedding vetorial para um texto."""
     logger.info(f"Recebido pedido de embedding para texto: '{req.text[:50]}...'")
-    vector = embedder.embed_query(req.text)
+    vector = get_embedder(req.model).embed_query(req.text)
+    if req.encoding:
+        return {"data": encode_vectors(vector, req.encoding), "dtype": req.encoding}
     return {"vector": vector.tolist()}
 
+@app.post("/embed/batch", response_model=EmbedBatchResponse)
+def get_embeddings(req: EmbedBatchRequest):
+    """
+    Vetoriza vários trechos de uma vez, como o embed_chunks da ingestão. Usado
+    pelo orchestrator Go, que faz o chunking das notas sem o processor.py.
+    """
+    logger.info(f"Recebido pedido de embedding em lote | {len(req.texts)} trechos")
+    embedder = get_embedder(req.model)
+    vectors = embedder.encode_documents(req.texts)
+    if req.encoding:
+        return {
+            "data": encode_vectors(vectors, req.encoding),
+            "dtype": req.encoding,
+            "dim": int(vectors.shape[1]) if len(vectors) else 0,
+            "model": embedder.model_name,
+        }
+    return {"vectors": vectors.tolist(), "model": embedder.model_name}
+
 @app.post("/rerank", response_model=RerankResponse)
 async def rerank_documents(req: RerankRequest):
     """
@@ -137,18 +364,97 @@ async def rerank_documents(req: RerankRequest):
     logger.info(f"Re-ranking concluído para {len(req.documents)} documentos.")
     return {"scores": scores.tolist()}
 
-@app.post("/generate", response_model=GenerateResponse)
-async def generate_answer(req: GenerateRequest):
+@app.post("/generate", response_model=GenerateResponse, response_model_exclude_none=True)
+async def generate_answer(req: GenerateRequest, request: Request):
     """Gera uma resposta com base em uma query e um contexto."""
     logger.info(f"Recebido pedido de geração para query: '{req.query[:50]}...'")
-    answer = llm.generate_answer(query=req.query, context_text=req.context)
-    return {"answer": answer}
+    if req.provider not in (None, "sidecar"):
+        raise HTTPException(status_code=400, detail=f"Provedor não suportado: {req.provider}")
+    engine = get_llm(req.model)
+    answer = engine.generate_answer(
+        query=req.query,
+        context_text=req.context,
+        prompt_template=req.prompt_template,
+        temperature=req.temperature,
+        max_tokens=req.max_tokens,
+    )
+    version = protocol.negotiate(request.headers.get(protocol.PROTOCOL_HEADER))
+    return protocol.shape({"answer": answer, "model": llm_name(req.model)}, version)
+
+@app.post("/generate/stream")
+def generate_answer_stream(req: GenerateRequest, request: Request):
+    """
+    Gera a resposta em streaming (NDJSON): uma linha {"token": ...} por pedaço
+    e {"done": true} no final. Usado pelo orquestrador para cortar a geração
+    quando o orçamento de latência acaba.
+    """
+    logger.info(f"Recebido pedido de geração (stream) para query: '{req.query[:50]}...'")
+    if req.provider not in (None, "sidecar"):
+        raise HTTPException(status_code=400, detail=f"Provedor não suportado: {req.provider}")
+    engine = get_llm(req.model)
+    version = protocol.negotiate(request.headers.get(protocol.PROTOCOL_HEADER))
+
+    def lines():
+        for piece in engine.generate_stream(
+            query=req.query,
+            context_text=req.context,
+            prompt_template=req.prompt_template,
+            temperature=req.temperature,
+            max_tokens=req.max_tokens,
+        ):
+            yield json.dumps({"token": piece}) + "\n"
+        yield json.dumps(protocol.shape({"done": True, "model": llm_name(req.model)}, version)) + "\n"
+
+    return StreamingResponse(lines(), media_type="application/x-ndjson")
+
+@app.post("/transcribe", response_model=TranscribeResponse)
+async def transcribe_audio(request: Request, filename: str = "audio.wav"):
+    """
+    Transcreve o áudio enviado no corpo (bytes crus). O nome do arquivo só
+    serve para o Whisper/ffmpeg reconhecer o formato pela extensão.
+    """
+    audio = await request.body()
+    if not audio:
+        raise HTTPException(status_code=400, detail="Corpo vazio")
+    logger.info(f"Recebido pedido de transcrição | {len(audio)} bytes")
+    text = await run_in_threadpool(transcribe_bytes, audio, Path(filename).suffix or ".wav")
+    return {"text": text}
+
+@app.post("/chunk", response_model=ChunkResponse)
+def chunk_text(req: ChunkRequest):
+    """
+    Limpa, divide e vetoriza um texto cru com os mesmos parâmetros do
+    run_ingestion.py. Usado pelo IngestText do orquestrador Go, que grava os
+    pontos no Qdrant sem passar por arquivos em data/raw.
+    """
+    logger.info(f"Recebido pedido de chunking | source={req.source} | {len(req.text)} caracteres")
+    pages = text_cleaner.clean_pages([PageText(page_number=1, text=req.text, char_count=len(req.text))])
+    chunks = text_chunker.chunk_pages(pages, req.source)
+    embedded = get_embedder(req.model).embed_chunks(chunks) if chunks else []
+    return {
+        "chunks": [
+            {
+                "chunk_id": c.chunk_id,
+                "page_number": c.page_number,
+                "text": c.text,
+                "vector": c.embedding.tolist(),
+            }
+            for c in embedded
+        ]
+    }
 
 @app.get("/health")
 async def health_check():
     """Verifica se o servidor e os modelos estão operacionais."""
-    # Uma verificação simples; poderia ser estendida para testar os modelos
-    return {"status": "ok", "message": "Alana Sidecar está operacional."}
+    # Os nomes e a dimensão dos modelos são conferidos pelo `alana doctor`
+    return {
+        "status": "ok",
+        "message": "Alana Sidecar está operacional.",
+        "embedding_model": EMBEDDING_MODEL,
+        "embedding_dim": embedder.model.get_sentence_embedding_dimension(),
+        "llm_model": Path(MODEL_PATH).name,
+        "protocol": protocol.health(),
+    }
 
 
 logger.info("🚀 Servidor FastAPI pronto para receber requisições em http://localhost:8000")
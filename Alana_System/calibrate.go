@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"alana_system/chunkid"
+	"alana_system/config"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Calibração dos cortes de score
+// ==============================
+
+// Scorers que a calibração sabe ajustar
+const (
+	scorerVector = "vector"
+	scorerRerank = "rerank"
+)
+
+// calibrationPair é uma linha do arquivo de pares rotulados (JSONL). O trecho
+// vem por chunk_id (lido da collection) ou direto em text.
+//
+//	{"query": "prazo de garantia", "chunk_id": "manual.pdf#p3#c2", "relevant": true}
+//	{"query": "prazo de garantia", "text": "A empresa foi fundada em...", "relevant": false}
+type calibrationPair struct {
+	Query    string `json:"query"`
+	ChunkID  string `json:"chunk_id,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Relevant bool   `json:"relevant"`
+}
+
+// scoredPair é um par já pontuado pelo scorer
+type scoredPair struct {
+	query    string
+	score    float32
+	relevant bool
+}
+
+// runCalibrate implementa `alana calibrate -pairs <arquivo.jsonl>
+// [-scorer vector|rerank] [-write]`: pontua os pares rotulados com o scorer
+// e ajusta os dois cortes dele, o de relevância (trechos abaixo são
+// descartados) e o de abstenção (se o melhor trecho fica abaixo, a pergunta
+// não é respondida). Com -write, grava os cortes em config/alana.yaml (ou
+// ALANA_CONFIG).
+func runCalibrate(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("calibrate", flag.ContinueOnError)
+	pairsPath := fs.String("pairs", "", "arquivo JSONL com {query, chunk_id|text, relevant}")
+	scorer := fs.String("scorer", "", "vector (similaridade) ou rerank (cross-encoder); padrão: o da collection")
+	write := fs.Bool("write", false, "grava os cortes no arquivo de configuração")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *pairsPath == "" {
+		return errors.New("uso: calibrate -pairs <arquivo.jsonl> [-scorer vector|rerank] [-write]")
+	}
+	if *scorer == "" {
+		*scorer = scorerVector
+		if opts, _ := engine.collections.askOptions(engine.collection, "", retrievalSettings{}); opts.Rerank {
+			*scorer = scorerRerank
+		}
+	}
+	if *scorer != scorerVector && *scorer != scorerRerank {
+		return fmt.Errorf("scorer desconhecido %q (use vector ou rerank)", *scorer)
+	}
+
+	pairs, err := loadCalibrationPairs(*pairsPath)
+	if err != nil {
+		return err
+	}
+
+	var scored []scoredPair
+	queries := 0
+	for query, group := range groupPairs(pairs) {
+		var scores []float32
+		if *scorer == scorerRerank {
+			scores, err = engine.rerankPairScores(ctx, query, group)
+		} else {
+			scores, err = engine.vectorPairScores(ctx, query, group)
+		}
+		if err != nil {
+			return fmt.Errorf("%q: %w", query, err)
+		}
+		for i, p := range group {
+			scored = append(scored, scoredPair{query: query, score: scores[i], relevant: p.Relevant})
+		}
+		queries++
+	}
+
+	relevanceCut, precision, recall, f1 := fitRelevance(scored)
+	abstainCut, accuracy := fitAbstain(scored)
+
+	relevanceKey, abstainKey := "score_threshold", "abstain_threshold"
+	current := [2]float32{defaultScoreThreshold, defaultCutoffs.Abstain}
+	if *scorer == scorerRerank {
+		relevanceKey, abstainKey = "rerank_threshold", "rerank_abstain_threshold"
+		current = [2]float32{defaultCutoffs.Rerank, defaultCutoffs.RerankAbstain}
+	}
+
+	fmt.Printf("Calibração (%s): %d pares, %d perguntas\n", *scorer, len(scored), queries)
+	fmt.Printf("   %-26s %.3f (atual %.3f) | precisão %.0f%% recall %.0f%% F1 %.2f\n",
+		relevanceKey, relevanceCut, current[0], 100*precision, 100*recall, f1)
+	fmt.Printf("   %-26s %.3f (atual %.3f) | %.0f%% das perguntas com a decisão certa\n",
+		abstainKey, abstainCut, current[1], 100*accuracy)
+
+	path, _ := config.Path()
+	if !*write {
+		fmt.Printf("   use -write para gravar em %s\n", path)
+		return nil
+	}
+	err = config.Set(path, map[string]string{
+		relevanceKey: fmt.Sprintf("%.3f", relevanceCut),
+		abstainKey:   fmt.Sprintf("%.3f", abstainCut),
+	})
+	if err != nil {
+		return err
+	}
+	cliLog.Info("Cortes gravados", "file", path)
+	return nil
+}
+
+// loadCalibrationPairs lê o JSONL; ao contrário dos logs, uma linha inválida
+// é erro, porque o arquivo é escrito à mão
+func loadCalibrationPairs(path string) ([]calibrationPair, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var pairs []calibrationPair
+	relevant := 0
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 4<<20)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var p calibrationPair
+		if err := json.Unmarshal([]byte(line), &p); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		if strings.TrimSpace(p.Query) == "" || (p.ChunkID == "") == (p.Text == "") {
+			return nil, fmt.Errorf("%s:%d: cada par precisa de query e de chunk_id ou text (um dos dois)", path, n)
+		}
+		if p.Relevant {
+			relevant++
+		}
+		pairs = append(pairs, p)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if relevant == 0 || relevant == len(pairs) {
+		return nil, fmt.Errorf("%s: são precisos pares relevantes e não relevantes (%d de %d relevantes)", path, relevant, len(pairs))
+	}
+	return pairs, nil
+}
+
+// groupPairs agrupa os pares por pergunta, para pontuar cada uma de uma vez
+func groupPairs(pairs []calibrationPair) map[string][]calibrationPair {
+	groups := map[string][]calibrationPair{}
+	for _, p := range pairs {
+		groups[p.Query] = append(groups[p.Query], p)
+	}
+	return groups
+}
+
+// vectorPairScores devolve a similaridade de cada par como a busca a
+// calcularia: trechos por chunk_id são pontuados pelo próprio Qdrant; textos
+// avulsos são embutidos com o mesmo modelo e comparados pelo cosseno.
+// Similaridades negativas viram 0, o piso dos cortes.
+func (e *AlanaEngine) vectorPairScores(ctx context.Context, query string, pairs []calibrationPair) ([]float32, error) {
+	vector, target, err := e.embedQuery(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("embedding: %w", err)
+	}
+
+	var ids []string
+	for _, p := range pairs {
+		if p.ChunkID != "" {
+			ids = append(ids, p.ChunkID)
+		}
+	}
+	byID, err := target.scoreChunks(ctx, vector, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make([]float32, len(pairs))
+	for i, p := range pairs {
+		if p.ChunkID != "" {
+			score, ok := byID[chunkid.PointID(p.ChunkID)]
+			if !ok {
+				return nil, fmt.Errorf("chunk %s não encontrado em %s", p.ChunkID, target.collection)
+			}
+			scores[i] = max(score, 0)
+			continue
+		}
+		passage, _, err := target.embedQuery(ctx, p.Text)
+		if err != nil {
+			return nil, fmt.Errorf("embedding: %w", err)
+		}
+		scores[i] = max(cosine(vector, passage), 0)
+	}
+	return scores, nil
+}
+
+// scoreChunks pontua o vetor contra os pontos informados (visíveis ou não),
+// devolvendo o score por UUID do ponto
+func (e *AlanaEngine) scoreChunks(ctx context.Context, vector []float32, chunkIDs []string) (map[string]float32, error) {
+	ids := make([]*qdrant.PointId, len(chunkIDs))
+	for i, c := range chunkIDs {
+		ids[i] = qdrant.NewID(chunkid.PointID(c))
+	}
+	return e.scorePoints(ctx, vector, ids)
+}
+
+// scorePoints é o scoreChunks a partir dos IDs dos pontos
+func (e *AlanaEngine) scorePoints(ctx context.Context, vector []float32, ids []*qdrant.PointId) (map[string]float32, error) {
+	scores := map[string]float32{}
+	if len(ids) == 0 {
+		return scores, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	limit := uint64(len(ids))
+	points, err := e.client.Query(ctx, &qdrant.QueryPoints{
+		CollectionName: e.collection,
+		Query:          qdrant.NewQuery(vector...),
+		Filter:         &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewHasID(ids...)}},
+		Limit:          &limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("qdrant query failed: %w", err)
+	}
+	for _, p := range points {
+		scores[pointIDString(p.GetId())] = p.GetScore()
+	}
+	return scores, nil
+}
+
+// rerankPairScores devolve a relevância do cross-encoder (0..1) de cada par
+func (e *AlanaEngine) rerankPairScores(ctx context.Context, query string, pairs []calibrationPair) ([]float32, error) {
+	var ids []string
+	for _, p := range pairs {
+		if p.ChunkID != "" {
+			ids = append(ids, p.ChunkID)
+		}
+	}
+	texts, err := e.chunkTexts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]string, len(pairs))
+	for i, p := range pairs {
+		docs[i] = p.Text
+		if p.ChunkID != "" {
+			text, ok := texts[chunkid.PointID(p.ChunkID)]
+			if !ok {
+				return nil, fmt.Errorf("chunk %s não encontrado em %s", p.ChunkID, e.collection)
+			}
+			docs[i] = text
+		}
+	}
+
+	scores, err := e.reranker.Relevance(ctx, query, docs)
+	if err != nil {
+		return nil, fmt.Errorf("rerank: %w", err)
+	}
+	return scores, nil
+}
+
+// chunkTexts lê o texto dos chunks (do payload ou do text store), por UUID
+// do ponto
+func (e *AlanaEngine) chunkTexts(ctx context.Context, chunkIDs []string) (map[string]string, error) {
+	texts := map[string]string{}
+	if len(chunkIDs) == 0 {
+		return texts, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	ids := make([]*qdrant.PointId, len(chunkIDs))
+	for i, c := range chunkIDs {
+		ids[i] = qdrant.NewID(chunkid.PointID(c))
+	}
+	points, err := e.client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: e.collection,
+		Ids:            ids,
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("qdrant get failed: %w", err)
+	}
+
+	results := make([]SearchResult, 0, len(points))
+	for _, p := range points {
+		results = append(results, resultFromPayload(p.GetId(), p.GetPayload(), 0))
+	}
+	if err := e.loadTexts(ctx, results); err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		texts[r.ID] = r.Text
+	}
+	return texts, nil
+}
+
+func cosine(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return float32(dot / math.Sqrt(na*nb))
+}
+
+// fitRelevance escolhe o corte que maximiza o F1 de "score ≥ corte" contra os
+// rótulos. No empate fica o corte mais alto (menos trechos irrelevantes).
+func fitRelevance(pairs []scoredPair) (cutoff float32, precision, recall, f1 float64) {
+	best := -1.0
+	for _, c := range pairs {
+		var tp, fp, fn float64
+		for _, p := range pairs {
+			switch {
+			case p.score >= c.score && p.relevant:
+				tp++
+			case p.score >= c.score:
+				fp++
+			case p.relevant:
+				fn++
+			}
+		}
+		if tp == 0 {
+			continue
+		}
+		prec, rec := tp/(tp+fp), tp/(tp+fn)
+		score := 2 * prec * rec / (prec + rec)
+		if score > best || (score == best && c.score > cutoff) {
+			best, cutoff, precision, recall, f1 = score, c.score, prec, rec, score
+		}
+	}
+	return cutoff, precision, recall, f1
+}
+
+// fitAbstain escolhe o corte de abstenção que maximiza as decisões certas
+// por pergunta: responder quando o melhor trecho é relevante e abster-se
+// quando não é. No empate fica o corte mais baixo (abstém-se menos).
+func fitAbstain(pairs []scoredPair) (cutoff float32, accuracy float64) {
+	type top struct {
+		score    float32
+		relevant bool
+	}
+	tops := map[string]top{}
+	for _, p := range pairs {
+		t, ok := tops[p.query]
+		if !ok || p.score > t.score || (p.score == t.score && p.relevant) {
+			tops[p.query] = top{p.score, p.relevant}
+		}
+	}
+
+	candidates := []float32{0}
+	for _, t := range tops {
+		candidates = append(candidates, t.score)
+	}
+	best := -1
+	for _, c := range candidates {
+		right := 0
+		for _, t := range tops {
+			if (t.score >= c) == t.relevant {
+				right++
+			}
+		}
+		if right > best || (right == best && c < cutoff) {
+			best, cutoff = right, c
+		}
+	}
+	return cutoff, float64(best) / float64(len(tops))
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"alana_system/yamlite"
+)
+
+// ==============================
+// Perguntas canário (saúde da busca)
+// ==============================
+
+const (
+	// defaultCanariesPath é lido quando ALANA_CANARIES não está definida
+	defaultCanariesPath = "config/canaries.yaml"
+	// defaultCanaryInterval é a frequência das canárias no serve
+	defaultCanaryInterval = 15 * time.Minute
+)
+
+// canaryConfig são perguntas cuja resposta tem fonte conhecida: se o
+// documento esperado sumir do top_k, o índice foi corrompido ou uma
+// re-ingestão estragou os trechos. O webhook (genérico ou do Slack) recebe
+// os alertas:
+//
+//	alert_webhook: https://hooks.slack.com/services/...
+//	canaries:
+//	  - name: garantia
+//	    question: Qual o prazo de garantia do produto?
+//	    expect: manuais/garantia.pdf
+//	    top_k: 3
+//	  - question: Como pedir reembolso?
+//	    expect: politicas/reembolso.md
+//	    profile: preciso
+//
+// expect é a fonte do documento (ou o ID de um trecho); top_k e profile são
+// opcionais (padrão: as opções da collection). Sem name, a pergunta é o nome.
+type canaryConfig struct {
+	AlertWebhook string
+	Canaries     []canary
+}
+
+type canary struct {
+	Name     string
+	Question string
+	Expect   string
+	TopK     uint64
+	Profile  string
+}
+
+// canariesPath lê ALANA_CANARIES (padrão config/canaries.yaml)
+func canariesPath() string {
+	return envOr("ALANA_CANARIES", defaultCanariesPath)
+}
+
+// canaryIntervalFromEnv lê ALANA_CANARY_INTERVAL (ex: 5m; 0 desliga no serve)
+func canaryIntervalFromEnv() time.Duration {
+	raw := os.Getenv("ALANA_CANARY_INTERVAL")
+	if raw == "" {
+		return defaultCanaryInterval
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		monitorLog.Warn("ALANA_CANARY_INTERVAL inválido, usando o padrão", "value", raw, "interval", defaultCanaryInterval)
+		return defaultCanaryInterval
+	}
+	return d
+}
+
+// loadCanaries lê o arquivo de canárias (inexistente = nenhuma canária)
+func loadCanaries(path string) (*canaryConfig, error) {
+	cfg := &canaryConfig{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc, err := yamlite.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if hook, ok := doc["alert_webhook"].(string); ok && hook != "" {
+		if err := validateWebhook(hook); err != nil {
+			return nil, fmt.Errorf("%s: alert_webhook: %w", path, err)
+		}
+		cfg.AlertWebhook = hook
+	}
+	items, ok := doc["canaries"].([]any)
+	if !ok && doc["canaries"] != nil {
+		return nil, fmt.Errorf("%s: canaries: esperada uma lista", path)
+	}
+	names := map[string]bool{}
+	for i, raw := range items {
+		fields, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: canaries[%d]: esperado um mapa (question, expect)", path, i)
+		}
+		c := canary{
+			Name:     fieldString(fields, "name"),
+			Question: fieldString(fields, "question"),
+			Expect:   fieldString(fields, "expect"),
+			Profile:  fieldString(fields, "profile"),
+		}
+		if c.Question == "" || c.Expect == "" {
+			return nil, fmt.Errorf("%s: canaries[%d]: question e expect são obrigatórios", path, i)
+		}
+		if c.Name == "" {
+			c.Name = c.Question
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("%s: canaries[%d]: nome repetido %q", path, i, c.Name)
+		}
+		names[c.Name] = true
+		if raw := fieldString(fields, "top_k"); raw != "" {
+			c.TopK, err = strconv.ParseUint(raw, 10, 64)
+			if err != nil || c.TopK == 0 {
+				return nil, fmt.Errorf("%s: canaries[%d].top_k: esperado inteiro positivo, veio %q", path, i, raw)
+			}
+		}
+		cfg.Canaries = append(cfg.Canaries, c)
+	}
+	return cfg, nil
+}
+
+// fieldString lê um escalar do yamlite como texto (vazio se ausente)
+func fieldString(fields map[string]any, key string) string {
+	if fields[key] == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprint(fields[key]))
+}
+
+// canaryResult é o resultado de uma canária numa rodada
+type canaryResult struct {
+	Name     string `json:"name"`
+	Question string `json:"question"`
+	Expect   string `json:"expect"`
+	// Rank é a posição do documento esperado (1 = primeiro; 0 = ausente)
+	Rank int `json:"rank"`
+	// Top é a fonte do primeiro trecho, o que veio no lugar do esperado
+	Top   string `json:"top,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func (r canaryResult) passed() bool {
+	return r.Error == "" && r.Rank > 0
+}
+
+// checkCanary faz só a busca (sem gerar) e procura o documento esperado
+func (e *AlanaEngine) checkCanary(ctx context.Context, c canary) canaryResult {
+	res := canaryResult{Name: c.Name, Question: c.Question, Expect: c.Expect}
+	settings := retrievalSettings{}
+	if c.TopK > 0 {
+		settings.TopK = &c.TopK
+	}
+	opts, err := e.collections.askOptions(e.collection, c.Profile, settings)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	results, err := e.retrieve(ctx, c.Question, opts)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	if len(results) > 0 {
+		res.Top = results[0].Source
+	}
+	for i, r := range results {
+		if r.Source == c.Expect || r.ID == c.Expect {
+			res.Rank = i + 1
+			break
+		}
+	}
+	return res
+}
+
+// checkCanaries roda todas as canárias em sequência (são poucas e não devem
+// competir com as perguntas dos usuários)
+func (e *AlanaEngine) checkCanaries(ctx context.Context, cfg *canaryConfig) []canaryResult {
+	results := make([]canaryResult, 0, len(cfg.Canaries))
+	for _, c := range cfg.Canaries {
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, e.checkCanary(ctx, c))
+	}
+	return results
+}
+
+// ------------------------------
+// Alertas
+// ------------------------------
+
+// canaryAlert é o corpo enviado aos webhooks genéricos. Webhooks do Slack
+// recebem só {"text": ...}.
+type canaryAlert struct {
+	canaryResult
+	// Status é "failing" (documento sumiu ou a busca falhou) ou "recovered"
+	Status string    `json:"status"`
+	Time   time.Time `json:"time"`
+}
+
+func sendCanaryAlert(ctx context.Context, hook string, r canaryResult, now time.Time) error {
+	a := canaryAlert{canaryResult: r, Status: "recovered", Time: now.UTC()}
+	a.Error = redactForLog(a.Error)
+	if !r.passed() {
+		a.Status = "failing"
+	}
+	var payload any = a
+	if isSlackWebhook(hook) {
+		payload = map[string]string{"text": canaryText(r)}
+	}
+	return postWebhook(ctx, hook, payload)
+}
+
+// canaryText descreve o alerta em mrkdwn do Slack
+func canaryText(r canaryResult) string {
+	switch {
+	case r.passed():
+		return fmt.Sprintf(":white_check_mark: Canária *%s* voltou a encontrar %s", r.Name, r.Expect)
+	case r.Error != "":
+		return fmt.Sprintf(":rotating_light: Canária *%s* falhou: %s", r.Name, truncateRunes(redactForLog(r.Error), 500))
+	case r.Top == "":
+		return fmt.Sprintf(":rotating_light: Canária *%s*: a busca não trouxe nada (esperado %s)", r.Name, r.Expect)
+	}
+	return fmt.Sprintf(":rotating_light: Canária *%s*: %s sumiu da busca (primeiro resultado: %s)", r.Name, r.Expect, r.Top)
+}
+
+// canaryMonitor roda as canárias e avisa uma vez quando uma começa a falhar
+// e outra quando ela volta. Guarda a última rodada para o /metrics.
+type canaryMonitor struct {
+	engine *AlanaEngine
+	// failing só é usado pelo loop
+	failing map[string]bool
+
+	mu      sync.Mutex
+	last    []canaryResult
+	lastRun time.Time
+}
+
+func newCanaryMonitor(engine *AlanaEngine) *canaryMonitor {
+	return &canaryMonitor{engine: engine, failing: map[string]bool{}}
+}
+
+// check relê o arquivo (pode mudar sem reiniciar o serve), roda as canárias
+// e envia os alertas das que mudaram de situação. Um alerta que falha é
+// reenviado na próxima rodada.
+func (m *canaryMonitor) check(ctx context.Context, now time.Time) error {
+	cfg, err := loadCanaries(canariesPath())
+	if err != nil {
+		return err
+	}
+	results := m.engine.checkCanaries(ctx, cfg)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	m.mu.Lock()
+	m.last, m.lastRun = results, now
+	m.mu.Unlock()
+
+	for _, r := range results {
+		failed := !r.passed()
+		if failed == m.failing[r.Name] {
+			continue
+		}
+		if failed {
+			monitorLog.Error("Canária falhou: documento esperado fora do resultado", "canary", r.Name, "expect", r.Expect, "top", r.Top, "err", r.Error)
+		} else {
+			monitorLog.Info("Canária voltou a encontrar o documento esperado", "canary", r.Name, "expect", r.Expect)
+		}
+		if cfg.AlertWebhook != "" {
+			if err := sendCanaryAlert(ctx, cfg.AlertWebhook, r, now); err != nil {
+				monitorLog.Error("Erro ao notificar", "webhook", redactWebhook(cfg.AlertWebhook), "err", err)
+				continue
+			}
+		}
+		m.failing[r.Name] = failed
+	}
+	return nil
+}
+
+// snapshot devolve a última rodada (nil antes da primeira)
+func (m *canaryMonitor) snapshot() ([]canaryResult, time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last, m.lastRun
+}
+
+// canaryLoop roda as canárias a cada interval até ctx acabar
+func canaryLoop(ctx context.Context, m *canaryMonitor, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := m.check(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			monitorLog.Warn("Erro ao rodar as canárias", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runCanary implementa `alana canary [-alert]`: roda as canárias uma vez
+// e falha se alguma não encontrar o documento esperado (para o cron, o CI
+// depois de uma re-ingestão ou quem não roda o serve). Com -alert, avisa o
+// alert_webhook de cada canária que falhou.
+func runCanary(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("canary", flag.ContinueOnError)
+	alert := fs.Bool("alert", false, "envia o alerta das canárias que falharam ao alert_webhook")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := loadCanaries(canariesPath())
+	if err != nil {
+		return err
+	}
+	if len(cfg.Canaries) == 0 {
+		fmt.Printf("Nenhuma canária em %s\n", canariesPath())
+		return nil
+	}
+
+	now := time.Now()
+	failed := 0
+	for _, r := range engine.checkCanaries(ctx, cfg) {
+		switch {
+		case r.passed():
+			fmt.Printf("ok     %-40s %s em #%d\n", r.Name, r.Expect, r.Rank)
+		case r.Error != "":
+			fmt.Printf("erro   %-40s %s\n", r.Name, truncateRunes(r.Error, 200))
+		default:
+			fmt.Printf("falha  %-40s %s fora do resultado (primeiro: %s)\n", r.Name, r.Expect, cmp.Or(r.Top, "nenhum"))
+		}
+		if r.passed() {
+			continue
+		}
+		failed++
+		if *alert && cfg.AlertWebhook != "" {
+			if err := sendCanaryAlert(ctx, cfg.AlertWebhook, r, now); err != nil {
+				monitorLog.Error("Erro ao notificar", "webhook", redactWebhook(cfg.AlertWebhook), "err", err)
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d de %d canárias falharam", failed, len(cfg.Canaries))
+	}
+	return nil
+}
+
+// writeCanaryMetrics acrescenta a última rodada das canárias ao /metrics
+// (nada antes da primeira rodada)
+func writeCanaryMetrics(b *strings.Builder, m *canaryMonitor) {
+	results, lastRun := m.snapshot()
+	if lastRun.IsZero() {
+		return
+	}
+	fmt.Fprint(b, "# HELP alana_canary_last_run_timestamp_seconds Fim da última rodada das canárias.\n# TYPE alana_canary_last_run_timestamp_seconds gauge\n")
+	fmt.Fprintf(b, "alana_canary_last_run_timestamp_seconds %d\n", lastRun.Unix())
+	gauge := func(name, help string, value func(canaryResult) float64) {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, r := range results {
+			fmt.Fprintf(b, "%s{canary=\"%s\"} %g\n", name, promLabel(r.Name), value(r))
+		}
+	}
+	gauge("alana_canary_passing", "1 se a canária encontrou o documento esperado.",
+		func(r canaryResult) float64 { return boolGauge(r.passed()) })
+	gauge("alana_canary_rank", "Posição do documento esperado (0 = ausente).",
+		func(r canaryResult) float64 { return float64(r.Rank) })
+}
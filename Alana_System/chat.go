@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"alana_system/chunkid"
+	"alana_system/render"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Conversa (várias perguntas seguidas)
+// ==============================
+
+const (
+	// chatHistoryTurns é quantos turnos anteriores entram no contexto
+	chatHistoryTurns = 4
+	// chatAnswerRunes corta cada resposta anterior no histórico condensado
+	chatAnswerRunes = 600
+	// maxChatTurns limita os turnos guardados por sessão (os mais antigos saem)
+	maxChatTurns = 50
+	// maxMemoryChatSessions limita as sessões do store em memória
+	maxMemoryChatSessions = 10_000
+)
+
+// ChatTurn é uma pergunta respondida numa conversa
+type ChatTurn struct {
+	Question string    `json:"question"`
+	Answer   string    `json:"answer"`
+	Chunks   []string  `json:"chunks,omitempty"` // IDs dos trechos usados
+	Time     time.Time `json:"time"`
+}
+
+// ChatSession é o histórico de uma conversa. As perguntas seguintes recebem
+// os últimos turnos condensados (ver condensedHistory), então "e no Brasil?"
+// é entendida a partir da pergunta anterior.
+type ChatSession struct {
+	ID      string     `json:"id"`
+	Turns   []ChatTurn `json:"turns"`
+	Updated time.Time  `json:"updated"`
+}
+
+// Add registra a resposta como um novo turno. Esclarecimentos não entram: a
+// pergunta esclarecida chega no turno seguinte.
+func (s *ChatSession) Add(question string, a Answer) {
+	if a.Clarification {
+		return
+	}
+	chunks := make([]string, len(a.Sources))
+	for i, r := range a.Sources {
+		chunks[i] = r.ID
+	}
+	now := time.Now().UTC()
+	s.Turns = append(s.Turns, ChatTurn{Question: question, Answer: a.Text, Chunks: chunks, Time: now})
+	if n := len(s.Turns); n > maxChatTurns {
+		s.Turns = s.Turns[n-maxChatTurns:]
+	}
+	s.Updated = now
+}
+
+// History devolve os turnos que entram na próxima pergunta
+func (s *ChatSession) History() []ChatTurn {
+	if s == nil {
+		return nil
+	}
+	return s.Turns[max(0, len(s.Turns)-chatHistoryTurns):]
+}
+
+// condensedHistory escreve os turnos anteriores para o início do contexto,
+// com as respostas cortadas em chatAnswerRunes
+func condensedHistory(turns []ChatTurn) string {
+	if len(turns) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Conversa anterior (use para entender a pergunta atual):\n\n")
+	for _, t := range turns {
+		answer := oneLine(t.Answer)
+		if utf8.RuneCountInString(answer) > chatAnswerRunes {
+			answer = string([]rune(answer)[:chatAnswerRunes]) + "…"
+		}
+		fmt.Fprintf(&b, "Usuário: %s\nAlana: %s\n\n", oneLine(t.Question), answer)
+	}
+	return b.String()
+}
+
+// followUpQuery é o texto usado na busca: a pergunta anterior junto com a
+// atual, para que uma continuação curta ("e no Brasil?") encontre os trechos
+// do assunto em andamento
+func followUpQuery(turns []ChatTurn, question string) string {
+	if len(turns) == 0 {
+		return question
+	}
+	return turns[len(turns)-1].Question + "\n" + question
+}
+
+// ==============================
+// Persistência das sessões
+// ==============================
+
+// chatStore guarda as sessões. Load devolve nil, nil para uma sessão nova.
+type chatStore interface {
+	Load(ctx context.Context, id string) (*ChatSession, error)
+	Save(ctx context.Context, s *ChatSession) error
+	Close() error
+}
+
+// openChatStore abre o store de ALANA_CHAT_STORE: vazio ou "memory" (perde as
+// conversas no restart), "file:<dir>" (um JSON por sessão) ou
+// "qdrant:<collection>" (um ponto sem vetor por sessão, na mesma instância
+// do Qdrant da base)
+func openChatStore(ctx context.Context, spec string, client *qdrant.Client) (chatStore, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "", "memory":
+		return &memoryChatStore{sessions: map[string]*ChatSession{}}, nil
+	case "file":
+		if arg == "" {
+			return nil, errors.New("chat store: file precisa de um diretório")
+		}
+		if err := os.MkdirAll(arg, 0o755); err != nil {
+			return nil, err
+		}
+		return fileChatStore{dir: arg}, nil
+	case "qdrant":
+		if arg == "" {
+			return nil, errors.New("chat store: qdrant precisa de uma collection")
+		}
+		return openQdrantChatStore(ctx, client, arg)
+	}
+	return nil, fmt.Errorf("chat store: backend desconhecido %q", kind)
+}
+
+// memoryChatStore guarda as sessões no processo; cheio, descarta a sessão
+// parada há mais tempo
+type memoryChatStore struct {
+	mu       sync.Mutex
+	sessions map[string]*ChatSession
+}
+
+func (m *memoryChatStore) Load(_ context.Context, id string) (*ChatSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+	clone := *s
+	clone.Turns = append([]ChatTurn(nil), s.Turns...)
+	return &clone, nil
+}
+
+func (m *memoryChatStore) Save(_ context.Context, s *ChatSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.sessions[s.ID]; !ok && len(m.sessions) >= maxMemoryChatSessions {
+		var oldest string
+		for id, other := range m.sessions {
+			if oldest == "" || other.Updated.Before(m.sessions[oldest].Updated) {
+				oldest = id
+			}
+		}
+		delete(m.sessions, oldest)
+	}
+	clone := *s
+	clone.Turns = append([]ChatTurn(nil), s.Turns...)
+	m.sessions[s.ID] = &clone
+	return nil
+}
+
+func (*memoryChatStore) Close() error { return nil }
+
+// fileChatStore grava um JSON por sessão. O nome do arquivo é o hash do ID,
+// que vem do cliente e não pode virar caminho.
+type fileChatStore struct {
+	dir string
+}
+
+func (f fileChatStore) path(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:16])+".json")
+}
+
+func (f fileChatStore) Load(_ context.Context, id string) (*ChatSession, error) {
+	data, err := os.ReadFile(f.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s ChatSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("sessão %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+func (f fileChatStore) Save(_ context.Context, s *ChatSession) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	path := f.path(s.ID)
+	if err := os.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (fileChatStore) Close() error { return nil }
+
+// qdrantChatStore guarda cada sessão como payload de um ponto sem vetor,
+// numa collection própria criada no primeiro uso
+type qdrantChatStore struct {
+	client     *qdrant.Client
+	collection string
+}
+
+func openQdrantChatStore(ctx context.Context, client *qdrant.Client, collection string) (*qdrantChatStore, error) {
+	exists, err := client.CollectionExists(ctx, collection)
+	if err != nil {
+		return nil, fmt.Errorf("chat store: %w", err)
+	}
+	if !exists {
+		err := client.CreateCollection(ctx, &qdrant.CreateCollection{
+			CollectionName: collection,
+			VectorsConfig:  qdrant.NewVectorsConfigMap(map[string]*qdrant.VectorParams{}),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("chat store: criar %s: %w", collection, err)
+		}
+	}
+	return &qdrantChatStore{client: client, collection: collection}, nil
+}
+
+// pointID deriva o UUID do ponto do ID da sessão, como nos chunks
+func (q *qdrantChatStore) pointID(id string) *qdrant.PointId {
+	return qdrant.NewID(chunkid.PointID("chat:" + id))
+}
+
+func (q *qdrantChatStore) Load(ctx context.Context, id string) (*ChatSession, error) {
+	points, err := q.client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: q.collection,
+		Ids:            []*qdrant.PointId{q.pointID(id)},
+		WithPayload:    qdrant.NewWithPayloadInclude("session"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("qdrant get failed: %w", err)
+	}
+	if len(points) == 0 {
+		return nil, nil
+	}
+	var s ChatSession
+	if err := json.Unmarshal([]byte(points[0].GetPayload()["session"].GetStringValue()), &s); err != nil {
+		return nil, fmt.Errorf("sessão %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+func (q *qdrantChatStore) Save(ctx context.Context, s *ChatSession) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	wait := true
+	_, err = q.client.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: q.collection,
+		Wait:           &wait,
+		Points: []*qdrant.PointStruct{{
+			Id:      q.pointID(s.ID),
+			Vectors: qdrant.NewVectorsMap(map[string]*qdrant.Vector{}),
+			Payload: qdrant.NewValueMap(map[string]any{
+				"session":    string(data),
+				"session_id": s.ID,
+				"updated":    s.Updated.Format(time.RFC3339),
+			}),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("qdrant upsert failed: %w", err)
+	}
+	return nil
+}
+
+// Close não fecha o cliente: ele é o mesmo do motor
+func (*qdrantChatStore) Close() error { return nil }
+
+// ==============================
+// `alana chat`
+// ==============================
+
+// runChat implementa `alana chat [-session ID] [-user USER]`: uma conversa no
+// terminal, uma pergunta por linha. Com -session e ALANA_CHAT_STORE
+// persistente, a conversa continua de onde parou; com -user, lembra as
+// outras conversas do usuário (ver chatMemory).
+func runChat(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("chat", flag.ContinueOnError)
+	sessionID := fs.String("session", "", "ID da conversa (vazio = nova)")
+	profile := fs.String("profile", "", "perfil de config/collections.yaml")
+	userID := fs.String("user", "", "usuário da memória de conversas (vazio = sem memória)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	store, err := openChatStore(ctx, os.Getenv("ALANA_CHAT_STORE"), engine.client)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if *sessionID == "" {
+		*sessionID = newSessionID()
+	}
+	session, err := store.Load(ctx, *sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		session = &ChatSession{ID: *sessionID}
+	}
+	cliLog.Info("Conversa aberta; linha vazia ou Ctrl+D encerra", "session", session.ID, "turns", len(session.Turns))
+
+	opts, err := engine.collections.askOptions(engine.collection, *profile, retrievalSettings{})
+	if err != nil {
+		return err
+	}
+	opts.TokenLimit = engine.models.contextTokenLimit("")
+	opts.UserID, opts.SessionID = *userID, session.ID
+
+	sc := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("\n> ")
+		if !sc.Scan() {
+			break
+		}
+		question := strings.TrimSpace(sc.Text())
+		if question == "" {
+			break
+		}
+
+		opts.History = session.History()
+		answer, err := engine.AskStream(ctx, question, opts, &answerStream{Token: func(t string) { fmt.Print(t) }})
+		fmt.Println()
+		if err != nil {
+			return err
+		}
+		// Os tokens já saíram como o modelo escreveu; as notas vêm depois
+		if notes := answer.Footnotes(); notes != "" {
+			fmt.Printf("\n%s", render.Answer(notes, render.Plain, nil))
+		}
+		session.Add(question, answer)
+		engine.rememberAnswer(ctx, *userID, session.ID, question, answer)
+		if err := store.Save(ctx, session); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Uma chave que reusa o session_id de outra recebe uma conversa vazia e não
+// consome o esclarecimento pendente da outra
+func TestChatSessionsScopedByAPIKey(t *testing.T) {
+	s := &server{chats: &memoryChatStore{sessions: map[string]*ChatSession{}}}
+	withKey := func(key string) *http.Request {
+		r := httptest.NewRequest("POST", "/ask", nil)
+		r.Header.Set("Authorization", "Bearer "+key)
+		return r
+	}
+
+	a := withKey("chave-a")
+	session, err := s.loadChat(a, "conversa-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Add("qual o prazo?", Answer{Text: "30 dias"})
+	if err := s.chats.Save(a.Context(), session); err != nil {
+		t.Fatal(err)
+	}
+
+	b := withKey("chave-b")
+	other, err := s.loadChat(b, "conversa-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other.History()) != 0 {
+		t.Fatalf("chave B leu o histórico da chave A: %+v", other.History())
+	}
+	again, err := s.loadChat(a, "conversa-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again.History()) != 1 {
+		t.Fatalf("chave A perdeu o histórico: %+v", again.History())
+	}
+
+	s.clarify.put(sessionKey("chave-a", "conversa-1"), "qual contrato?")
+	if _, ok := s.clarify.take(sessionKey("chave-b", "conversa-1")); ok {
+		t.Fatal("chave B consumiu o esclarecimento da chave A")
+	}
+	if q, ok := s.clarify.take(sessionKey("chave-a", "conversa-1")); !ok || q != "qual contrato?" {
+		t.Fatalf("take = %q, %v", q, ok)
+	}
+}
@@ -0,0 +1,284 @@
+// Package chunker divide texto em chunks em Go, como o TextChunker do
+// Python (src/alana_system/preprocessing/chunker.py): parágrafos inteiros
+// agrupados até MaxChars, com os últimos parágrafos repetidos no chunk
+// seguinte (até OverlapChars) e parágrafos gigantes (transcrição, OCR) fatiados
+// com sobreposição. Tamanhos são em caracteres (runas), como o len() do
+// Python, e os IDs são os de alana_system/chunkid.
+//
+// Com Sentences, o fatiamento dos parágrafos gigantes prefere terminar no fim
+// de uma frase em vez de no último espaço. É a única diferença em relação ao
+// Python, e por isso vem desligada: sem ela, o mesmo texto gera os mesmos
+// chunks e IDs nos dois lados (ver testdata/parity.json). Ligá-la muda os
+// IDs dos documentos com parágrafos gigantes.
+package chunker
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"alana_system/chunkid"
+)
+
+// Options são os tamanhos do chunking
+type Options struct {
+	// MaxChars é o tamanho máximo de um chunk
+	MaxChars int
+	// OverlapChars é quanto do fim de um chunk se repete no início do próximo
+	OverlapChars int
+	// MinChars descarta chunks menores (cabeçalhos soltos, numeração)
+	MinChars int
+	// Sentences faz os parágrafos gigantes serem cortados no fim de frase
+	Sentences bool
+}
+
+// DefaultOptions são os tamanhos do run_ingestion.py, sem Sentences
+func DefaultOptions() Options {
+	return Options{MaxChars: 800, OverlapChars: 200, MinChars: 50}
+}
+
+// Validate confere os tamanhos
+func (o Options) Validate() error {
+	switch {
+	case o.MaxChars <= 0:
+		return errors.New("chunker: MaxChars deve ser positivo")
+	case o.OverlapChars < 0 || o.OverlapChars >= o.MaxChars:
+		return errors.New("chunker: OverlapChars deve estar em [0, MaxChars)")
+	case o.MinChars < 0:
+		return errors.New("chunker: MinChars não pode ser negativo")
+	}
+	return nil
+}
+
+// Page é uma página (ou seção) de texto já limpo
+type Page struct {
+	Number int
+	Text   string
+}
+
+// Chunk é um trecho pronto para vetorizar
+type Chunk struct {
+	// ID é o chunk_id estável (ver chunkid.New)
+	ID   string
+	Page int
+	Text string
+}
+
+// Split divide as páginas do documento source (caminho relativo a data/raw,
+// ver chunkid.Source). O índice do ID conta os chunks do documento inteiro.
+func Split(pages []Page, source string, opts Options) ([]Chunk, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	var chunks []Chunk
+	for _, page := range pages {
+		for _, text := range opts.splitPage(page.Text) {
+			chunks = append(chunks, Chunk{
+				ID:   chunkid.New(source, len(chunks), text),
+				Page: page.Number,
+				Text: text,
+			})
+		}
+	}
+	return chunks, nil
+}
+
+func runeLen(s string) int {
+	return len([]rune(s))
+}
+
+// splitPage é o _chunk_single_page do Python
+func (o Options) splitPage(text string) []string {
+	if runeLen(text) <= o.MaxChars {
+		if runeLen(text) >= o.MinChars {
+			return []string{text}
+		}
+		return nil
+	}
+
+	var chunks []string
+	commit := func(paragraphs []string) bool {
+		if len(paragraphs) == 0 {
+			return false
+		}
+		block := strings.Join(paragraphs, "\n\n")
+		if runeLen(block) < o.MinChars {
+			return false
+		}
+		chunks = append(chunks, block)
+		return true
+	}
+
+	paragraphs := splitParagraphs(text)
+	var current []string
+	currentLen := 0
+	for i := 0; i < len(paragraphs); {
+		para := paragraphs[i]
+		paraLen := runeLen(para)
+
+		// Parágrafo gigante: salva o que estiver pendente e fatia
+		if paraLen > o.MaxChars {
+			if len(current) > 0 {
+				commit(current)
+				current, currentLen = nil, 0
+			}
+			chunks = append(chunks, o.splitByLimit(para)...)
+			i++
+			continue
+		}
+
+		added := paraLen
+		if len(current) > 0 {
+			added += 2
+		}
+		if currentLen+added <= o.MaxChars {
+			current = append(current, para)
+			currentLen += added
+			i++
+			continue
+		}
+		committed := commit(current)
+		previous := current
+		current, currentLen = nil, 0
+		if committed {
+			// A sobreposição só fica se o parágrafo ainda couber depois dela;
+			// senão ela viraria um chunk sozinha, repetidamente
+			if kept, keptLen := o.overlap(previous); keptLen+2+paraLen <= o.MaxChars {
+				current, currentLen = kept, keptLen
+			}
+		}
+	}
+	commit(current)
+	return chunks
+}
+
+func splitParagraphs(text string) []string {
+	var out []string
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// overlap mantém os parágrafos inteiros do fim do chunk que cabem em
+// OverlapChars
+func (o Options) overlap(paragraphs []string) ([]string, int) {
+	var kept []string
+	length := 0
+	for i := len(paragraphs) - 1; i >= 0; i-- {
+		l := runeLen(paragraphs[i])
+		if len(kept) > 0 {
+			l += 2
+		}
+		if length+l > o.OverlapChars {
+			break
+		}
+		kept = append([]string{paragraphs[i]}, kept...)
+		length += l
+	}
+	return kept, length
+}
+
+// splitByLimit fatia um texto contínuo em blocos de até MaxChars com
+// sobreposição de OverlapChars, cortando no fim de frase (com Sentences) ou no
+// último espaço
+func (o Options) splitByLimit(text string) []string {
+	runes := []rune(text)
+	var blocks []string
+	for start := 0; start < len(runes); {
+		end := min(start+o.MaxChars, len(runes))
+		if end < len(runes) {
+			end = o.cut(runes, start, end)
+		}
+
+		if block := strings.TrimSpace(string(runes[start:end])); block != "" {
+			blocks = append(blocks, block)
+		}
+
+		// Sem avançar (bloco final menor que a sobreposição), segue do fim
+		next := end - o.OverlapChars
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+	return blocks
+}
+
+// cut escolhe onde terminar o bloco [start, end)
+func (o Options) cut(runes []rune, start, end int) int {
+	if o.Sentences {
+		// Só vale um fim de frase na segunda metade do bloco, para não gerar
+		// blocos curtos demais
+		for i := end - 1; i > start+(end-start)/2; i-- {
+			if isSentenceEnd(runes[i-1]) && unicode.IsSpace(runes[i]) {
+				return i
+			}
+		}
+	}
+	for i := end - 1; i > start; i-- {
+		if runes[i] == ' ' {
+			return i
+		}
+	}
+	return end
+}
+
+func isSentenceEnd(r rune) bool {
+	return r == '.' || r == '!' || r == '?' || r == '…'
+}
+
+// ==============================
+// Limpeza
+// ==============================
+
+var (
+	spaceRun       = regexp.MustCompile(`[ \t]+`)
+	blankLines     = regexp.MustCompile(`\n{3,}`)
+	hyphenatedWord = regexp.MustCompile(`-\n([\p{L}\p{N}_])`)
+)
+
+// Clean é o TextCleaner do Python (src/alana_system/ingestion/cleaner.py):
+// junta espaços, remove a hifenização de fim de linha e une as linhas
+// quebradas de um mesmo parágrafo
+func Clean(text string) string {
+	if text == "" {
+		return ""
+	}
+	text = spaceRun.ReplaceAllString(text, " ")
+	text = blankLines.ReplaceAllString(text, "\n\n")
+	text = hyphenatedWord.ReplaceAllString(text, "$1")
+
+	var lines []string
+	var buffer string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if buffer != "" {
+				lines = append(lines, buffer)
+				buffer = ""
+			}
+			lines = append(lines, "")
+			continue
+		}
+		if buffer != "" {
+			buffer += " " + line
+		} else {
+			buffer = line
+		}
+	}
+	if buffer != "" {
+		lines = append(lines, buffer)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// NormalizeNote é a normalização do NoteExtractor: sem BOM, sem espaços nas
+// pontas e com quebras de linha Unix
+func NormalizeNote(text string) string {
+	text = strings.TrimSpace(strings.TrimLeft(text, "\ufeff"))
+	return strings.ReplaceAll(text, "\r\n", "\n")
+}
@@ -0,0 +1,56 @@
+package chunker
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testdata/parity.json foi gerado pelo TextChunker(800, 200, 50) do Python
+// (tests/test_chunker.py confere o mesmo arquivo): com DefaultOptions, o
+// caminho Go e o Python precisam gerar os mesmos chunks e IDs, senão trocar
+// de caminho re-identifica os chunks e deixa os vetores antigos órfãos
+func TestSplitMatchesPython(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "parity.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var docs []struct {
+		Source string `json:"source"`
+		Pages  []struct {
+			Number int    `json:"number"`
+			Text   string `json:"text"`
+		} `json:"pages"`
+		Chunks []struct {
+			ID   string `json:"id"`
+			Page int    `json:"page"`
+			Text string `json:"text"`
+		} `json:"chunks"`
+	}
+	if err := json.Unmarshal(data, &docs); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, doc := range docs {
+		t.Run(doc.Source, func(t *testing.T) {
+			pages := make([]Page, len(doc.Pages))
+			for i, p := range doc.Pages {
+				pages[i] = Page{Number: p.Number, Text: p.Text}
+			}
+			chunks, err := Split(pages, doc.Source, DefaultOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chunks) != len(doc.Chunks) {
+				t.Fatalf("%d chunks, o Python gerou %d", len(chunks), len(doc.Chunks))
+			}
+			for i, want := range doc.Chunks {
+				got := chunks[i]
+				if got.ID != want.ID || got.Page != want.Page || got.Text != want.Text {
+					t.Errorf("chunk %d:\n  go:     %s p%d %q\n  python: %s p%d %q", i, got.ID, got.Page, got.Text, want.ID, want.Page, want.Text)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Package chunkid define o esquema de IDs estáveis dos chunks.
+//
+// O chunk_id (gravado como original_id no payload) é o SHA-256 de
+// "origem \x1f índice \x1f hash do conteúdo", em que origem é o caminho do
+// documento relativo à pasta de dados brutos (sempre com "/") e índice é a
+// posição do chunk dentro do documento. O ID do ponto no Qdrant é o uuid5 do
+// chunk_id no namespace DNS, exatamente como faz o vector_store.py.
+//
+// Assim a reingestão do mesmo conteúdo sobrescreve os mesmos pontos em vez de
+// criar duplicatas, e ferramentas externas conseguem referenciar um chunk
+// sem consultar o Qdrant.
+package chunkid
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Namespace é o uuid.NAMESPACE_DNS do Python
+var Namespace = [16]byte{
+	0x6b, 0xa7, 0xb8, 0x10, 0x9d, 0xad, 0x11, 0xd1,
+	0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8,
+}
+
+const separator = "\x1f"
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ContentHash devolve o SHA-256 (hex) do texto do chunk
+func ContentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// New devolve o chunk_id do chunk de posição index do documento source
+func New(source string, index int, text string) string {
+	key := strings.Join([]string{source, strconv.Itoa(index), ContentHash(text)}, separator)
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// PointID converte um chunk_id no UUID do ponto. UUIDs são devolvidos como
+// estão, então a função aceita qualquer uma das duas formas.
+func PointID(chunkID string) string {
+	if uuidPattern.MatchString(chunkID) {
+		return strings.ToLower(chunkID)
+	}
+	return uuid5(Namespace, chunkID)
+}
+
+// Source normaliza o caminho do documento para a forma usada no ID:
+// relativo a rawDir e com "/" em qualquer sistema operacional. Caminhos fora
+// de rawDir ficam apenas limpos.
+func Source(rawDir, path string) string {
+	if rel, err := filepath.Rel(rawDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// uuid5 replica o uuid.uuid5 do Python (SHA-1, RFC 4122)
+func uuid5(namespace [16]byte, name string) string {
+	h := sha1.New()
+	h.Write(namespace[:])
+	h.Write([]byte(name))
+	sum := h.Sum(nil)
+
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
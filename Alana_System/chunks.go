@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"alana_system/chunkid"
+	"alana_system/manifest"
+	"alana_system/schema"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Inspeção de chunks
+// ==============================
+
+var errChunkNotFound = errors.New("chunk não encontrado")
+
+// chunkInspection é a resposta de GET /v1/chunks/{id}: o chunk, a
+// proveniência gravada na ingestão e a entrada do documento no manifesto
+type chunkInspection struct {
+	ChunkID       string `json:"chunk_id"`
+	PointID       string `json:"point_id"`
+	Collection    string `json:"collection"`
+	FileName      string `json:"file_name"`
+	Page          int    `json:"page_number"`
+	Text          string `json:"text"`
+	IngestVersion string `json:"ingest_version,omitempty"`
+	Staging       bool   `json:"staging"`
+	// ACL são os grupos que podem ver o trecho (vazio = público)
+	ACL []string `json:"acl,omitempty"`
+	// Provenance é nil nos chunks gravados antes do registro de proveniência
+	Provenance *manifest.Provenance `json:"provenance,omitempty"`
+	// Payload são os demais campos do payload (metadados, links, tags...)
+	Payload map[string]any `json:"payload"`
+	// Document é a entrada do manifesto (sem os chunk_ids); nil para textos
+	// gravados sem arquivo (IngestText, conectores)
+	Document *manifest.Document `json:"document,omitempty"`
+}
+
+// inspectChunk lê um chunk pelo chunk_id (original_id) ou pelo UUID do ponto
+func (e *AlanaEngine) inspectChunk(ctx context.Context, docs manifest.Store, id string) (chunkInspection, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	points, err := e.client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: e.collection,
+		Ids:            []*qdrant.PointId{qdrant.NewID(chunkid.PointID(id))},
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return chunkInspection{}, fmt.Errorf("qdrant get failed: %w", err)
+	}
+	if len(points) == 0 {
+		return chunkInspection{}, errChunkNotFound
+	}
+	return e.describeChunk(ctx, docs, points[0].GetId(), points[0].GetPayload())
+}
+
+// describeChunk monta a inspeção a partir do ponto lido do Qdrant, buscando
+// o texto no text store se ele não está no payload
+func (e *AlanaEngine) describeChunk(ctx context.Context, docs manifest.Store, id *qdrant.PointId, payload map[string]*qdrant.Value) (chunkInspection, error) {
+	results := []SearchResult{resultFromPayload(id, payload, 0)}
+	if err := e.loadTexts(ctx, results); err != nil {
+		return chunkInspection{}, err
+	}
+	result := results[0]
+	out := chunkInspection{
+		ChunkID:       payload["original_id"].GetStringValue(),
+		PointID:       result.ID,
+		Collection:    e.collection,
+		FileName:      result.Source,
+		Page:          result.Page,
+		Text:          result.Text,
+		IngestVersion: payload["ingest_version"].GetStringValue(),
+		Staging:       payload["staging"].GetBoolValue(),
+		ACL:           result.ACL,
+		Payload:       map[string]any{},
+	}
+	for key, v := range payload {
+		switch key {
+		case "original_id", "file_name", "page_number", "text", "text_codec", "text_offloaded", "ingest_version", "staging", "acl", schema.ProvenanceField:
+		default:
+			out.Payload[key] = schema.Plain(v)
+		}
+	}
+
+	if v, ok := payload[schema.ProvenanceField]; ok {
+		data, err := json.Marshal(schema.Plain(v))
+		if err != nil {
+			return chunkInspection{}, err
+		}
+		var p manifest.Provenance
+		if err := json.Unmarshal(data, &p); err != nil {
+			engineLog.WarnContext(ctx, "Proveniência ilegível", "point", out.PointID, "err", err)
+		} else {
+			out.Provenance = &p
+		}
+	}
+
+	// O manifesto é indexado pela fonte (chunkid.Source), que só a
+	// proveniência guarda; sem ela, tenta o nome do arquivo (documentos na
+	// raiz de data/raw)
+	source := out.FileName
+	if out.Provenance != nil && out.Provenance.Source != "" {
+		source = out.Provenance.Source
+	}
+	if !schema.Direct(payload["content_type"].GetStringValue()) && source != "" {
+		doc, found, err := docs.Get(ctx, source)
+		if err != nil {
+			engineLog.WarnContext(ctx, "Erro ao ler o manifesto", "source", source, "err", err)
+		} else if found {
+			doc.ChunkIDs = nil
+			out.Document = &doc
+		}
+	}
+	return out, nil
+}
+
+// handleChunk implementa GET /v1/chunks/{id}: rastreia um trecho citado
+// numa resposta até o arquivo, o extrator, o chunking e o modelo de
+// embedding que o produziram
+func (s *server) handleChunk(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := validateText("id", id, true, maxNameRunes); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	chunk, err := s.engine.inspectChunk(r.Context(), s.manifest, id)
+	// Um trecho restrito é "não encontrado": a resposta não revela que existe
+	if err == nil && !s.access.viewer(requestAPIKey(r)).allows(chunk.ACL) {
+		err = errChunkNotFound
+	}
+	if errors.Is(err, errChunkNotFound) {
+		writeError(w, http.StatusNotFound, "chunk não encontrado")
+		return
+	}
+	if err != nil {
+		serverLog.ErrorContext(r.Context(), "Erro ao inspecionar o chunk", "chunk", id, "err", err)
+		writeError(w, http.StatusBadGateway, "falha ao ler o chunk")
+		return
+	}
+	writeJSON(w, http.StatusOK, chunk)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"alana_system/manifest"
+	"alana_system/textstore"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Com o texto fora do Qdrant (text_offloaded), /v1/chunks busca o texto no
+// text store
+func TestDescribeChunkOffloadedText(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	const id = "5f0c6a52-3c1e-4c3e-9a57-8f0d2f6b1e01"
+	if err := os.MkdirAll(filepath.Join(dir, id[:2]), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, id[:2], id+".txt"), []byte("texto guardado fora do Qdrant"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	texts, err := textstore.Open(ctx, "file:"+dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := manifest.Open(ctx, "json:"+filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &AlanaEngine{collection: "alana_knowledge", texts: texts}
+	payload := qdrant.NewValueMap(map[string]any{
+		"original_id":    "notes/a.md#0",
+		"file_name":      "a.md",
+		"page_number":    1,
+		"text_offloaded": true,
+	})
+	chunk, err := e.describeChunk(ctx, docs, qdrant.NewID(id), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chunk.Text != "texto guardado fora do Qdrant" {
+		t.Errorf("Text = %q", chunk.Text)
+	}
+	if chunk.PointID != id || chunk.ChunkID != "notes/a.md#0" {
+		t.Errorf("ids = %q, %q", chunk.PointID, chunk.ChunkID)
+	}
+}
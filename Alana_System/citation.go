@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"alana_system/assemble"
+	"alana_system/render"
+)
+
+// ==============================
+// Citações
+// ==============================
+
+// Citation é a referência estruturada a um trecho usado na resposta, para o
+// usuário conferir de onde veio cada afirmação
+type Citation struct {
+	// N é o número da nota ([N] no texto): a posição do trecho em
+	// Answer.Sources, a partir de 1
+	N      int     `json:"n"`
+	ID     string  `json:"id"`
+	Source string  `json:"source"`
+	Title  string  `json:"title,omitempty"`
+	Page   int     `json:"page"`
+	Score  float32 `json:"score"`
+	// Cited indica que o texto da resposta cita o trecho explicitamente
+	Cited bool `json:"cited"`
+}
+
+// citationLabels são os rótulos com que os trechos aparecem no contexto (ver
+// assemble.DefaultOptions), os mesmos que o modelo usa para citar
+func citationLabels(results []SearchResult) []string {
+	labels := make([]string, len(results))
+	for i, c := range assembleChunks(results) {
+		labels[i] = fmt.Sprintf("%s/Pág %d", assemble.Label(c), c.Page)
+	}
+	return labels
+}
+
+// Citations normaliza as citações do texto para [n] e devolve o texto junto
+// com uma Citation por trecho de Sources, na mesma ordem
+func (a Answer) Citations() (string, []Citation) {
+	text, cited := render.Footnotes(a.Text, citationLabels(a.Sources))
+	citations := make([]Citation, len(a.Sources))
+	for i, r := range a.Sources {
+		citations[i] = Citation{N: i + 1, ID: r.ID, Source: r.Source, Title: r.Title, Page: r.Page, Score: r.Score}
+	}
+	for _, n := range cited {
+		citations[n-1].Cited = true
+	}
+	return text, citations
+}
+
+// Footnoted devolve a resposta com as citações como [n] e, ao fim, as notas
+// de rodapé (ver Footnotes)
+func (a Answer) Footnoted() string {
+	notes := a.Footnotes()
+	if notes == "" {
+		return a.Text
+	}
+	text, _ := a.Citations()
+	return strings.TrimRight(text, "\n") + "\n\n" + notes
+}
+
+// Footnotes são as notas com arquivo, página e score de cada fonte. Entram
+// as fontes citadas; se o modelo não citou nenhuma, todas as usadas no
+// contexto. Esclarecimentos e abstenções não têm notas.
+func (a Answer) Footnotes() string {
+	if a.Clarification || a.Abstained || len(a.Sources) == 0 {
+		return ""
+	}
+	_, citations := a.Citations()
+	return footnotes(citations)
+}
+
+// footnotes lista as notas em markdown (uma lista, para sobreviver à
+// conversão em HTML e texto puro)
+func footnotes(citations []Citation) string {
+	anyCited := false
+	for _, c := range citations {
+		anyCited = anyCited || c.Cited
+	}
+
+	var b strings.Builder
+	b.WriteString("**Fontes**\n\n")
+	for _, c := range citations {
+		if anyCited && !c.Cited {
+			continue
+		}
+		name := c.Source
+		if c.Title != "" && c.Title != c.Source {
+			name = fmt.Sprintf("%s (%s)", c.Title, c.Source)
+		}
+		if c.Page > 0 {
+			fmt.Fprintf(&b, "- [%d] %s, pág. %d — score %.2f\n", c.N, name, c.Page, c.Score)
+		} else {
+			fmt.Fprintf(&b, "- [%d] %s — score %.2f\n", c.N, name, c.Score)
+		}
+	}
+	return b.String()
+}
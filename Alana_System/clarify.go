@@ -0,0 +1,138 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==============================
+// Pedido de esclarecimento
+// ==============================
+
+const (
+	// Uma busca é ambígua quando nenhum trecho se destaca (maior score baixo,
+	// scores próximos) e os trechos vêm de vários documentos diferentes
+	clarifyMaxTopScore = 0.55
+	clarifyMaxSpread   = 0.08
+	clarifyMinSources  = 3
+	// clarifyMaxOptions é quantos documentos o esclarecimento sugere
+	clarifyMaxOptions = 3
+
+	// clarifySessionTTL é quanto tempo o esclarecimento espera a resposta
+	clarifySessionTTL = 10 * time.Minute
+	// maxClarifySessions limita as sessões pendentes em memória
+	maxClarifySessions = 10_000
+)
+
+// ambiguousSources devolve os documentos entre os quais a pergunta ficou
+// dividida, ou false se a busca não parece ambígua. results vêm em ordem de
+// score (maior primeiro).
+func ambiguousSources(results []SearchResult) ([]string, bool) {
+	if len(results) < clarifyMinSources {
+		return nil, false
+	}
+	top, last := results[0].Score, results[len(results)-1].Score
+	if top >= clarifyMaxTopScore || top-last > clarifyMaxSpread {
+		return nil, false
+	}
+
+	var sources []string
+	seen := map[string]bool{}
+	for _, r := range results {
+		name := r.Title
+		if name == "" {
+			name = r.Source
+		}
+		if !seen[name] {
+			seen[name] = true
+			sources = append(sources, name)
+		}
+	}
+	if len(sources) < clarifyMinSources {
+		return nil, false
+	}
+	return sources[:min(len(sources), clarifyMaxOptions)], true
+}
+
+// clarifyingQuestion monta a pergunta de esclarecimento
+func clarifyingQuestion(sources []string) string {
+	quoted := make([]string, len(sources))
+	for i, s := range sources {
+		quoted[i] = "“" + s + "”"
+	}
+	options := strings.Join(quoted[:len(quoted)-1], ", ") + " ou " + quoted[len(quoted)-1]
+	return fmt.Sprintf("Sua pergunta pode se referir a assuntos diferentes. "+
+		"Você quer saber sobre %s? Se puder, dê mais detalhes.", options)
+}
+
+// clarifiedQuestion junta a pergunta original e a resposta ao esclarecimento
+func clarifiedQuestion(original, reply string) string {
+	return original + "\n(Esclarecimento: " + strings.TrimSpace(reply) + ")"
+}
+
+// ==============================
+// Sessões
+// ==============================
+
+// pendingClarification é a pergunta que aguarda a resposta ao esclarecimento
+type pendingClarification struct {
+	Question string
+	Expires  time.Time
+}
+
+// clarifySessions guarda, por sessão, a pergunta à espera de esclarecimento.
+// Fica em memória: um restart do serve só faz a próxima mensagem ser tratada
+// como pergunta nova.
+type clarifySessions struct {
+	mu      sync.Mutex
+	pending map[string]pendingClarification
+}
+
+// put registra a pergunta pendente; devolve false se não couber mais sessões
+func (c *clarifySessions) put(id, question string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.pending == nil {
+		c.pending = map[string]pendingClarification{}
+	}
+	if len(c.pending) >= maxClarifySessions {
+		for k, p := range c.pending {
+			if now.After(p.Expires) {
+				delete(c.pending, k)
+			}
+		}
+		if len(c.pending) >= maxClarifySessions {
+			return false
+		}
+	}
+	c.pending[id] = pendingClarification{Question: question, Expires: now.Add(clarifySessionTTL)}
+	return true
+}
+
+// take devolve e apaga a pergunta pendente da sessão (uma única rodada)
+func (c *clarifySessions) take(id string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p, ok := c.pending[id]
+	if !ok {
+		return "", false
+	}
+	delete(c.pending, id)
+	if time.Now().After(p.Expires) {
+		return "", false
+	}
+	return p.Question, true
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"alana_system/lexical"
+	"alana_system/manifest"
+	"alana_system/vecenc"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// alana collections
+// ==============================
+
+// collectionSpec é o formato do vetor denso de uma collection
+type collectionSpec struct {
+	dim      uint64
+	datatype string
+}
+
+// runCollections implementa `alana collections <ação> [flags] [NOME]`, a
+// administração das collections do Qdrant sem curl. NOME é a collection
+// configurada quando omitido.
+//
+//	list                     collections com pontos, dimensão e validade
+//	create [-dim N] [-datatype float32|float16]
+//	                         cria como o ingestor (vetor denso COSINE, vetor
+//	                         esparso da busca híbrida, índices de payload);
+//	                         sem -dim, a dimensão vem do embedder da collection
+//	snapshot                 pede um snapshot ao Qdrant
+//	drop -yes                apaga a collection e as entradas dela no manifesto
+//	recreate -yes            apaga e cria de novo, vazia, com o mesmo formato
+func runCollections(ctx context.Context, engine *AlanaEngine, args []string) error {
+	if len(args) == 0 {
+		return errors.New("uso: alana collections <list|create|snapshot|drop|recreate> [flags] [NOME]")
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("collections "+action, flag.ContinueOnError)
+	dim := fs.Uint64("dim", 0, "dimensão do vetor (create; 0 = a do embedder da collection)")
+	datatype := fs.String("datatype", vecenc.Float32, "datatype do vetor no Qdrant: float32 ou float16 (create)")
+	yes := fs.Bool("yes", false, "confirma drop e recreate")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("uso: alana collections %s [flags] [NOME]", action)
+	}
+	name := engine.collection
+	if fs.NArg() == 1 {
+		name = fs.Arg(0)
+	}
+
+	switch action {
+	case "list":
+		return listCollections(ctx, engine)
+	case "snapshot":
+		return snapshotCollection(ctx, engine, name)
+	case "create":
+		if *datatype != vecenc.Float32 && *datatype != vecenc.Float16 {
+			return fmt.Errorf("-datatype %q: use %s ou %s", *datatype, vecenc.Float32, vecenc.Float16)
+		}
+		if err := engine.writable(); err != nil {
+			return err
+		}
+		spec := collectionSpec{dim: *dim, datatype: *datatype}
+		if spec.dim == 0 {
+			n, err := embedderDim(ctx, engine, name)
+			if err != nil {
+				return fmt.Errorf("dimensão do embedder (ou informe -dim): %w", err)
+			}
+			spec.dim = n
+		}
+		return createCollection(ctx, engine, name, spec)
+	case "drop", "recreate":
+		if err := engine.writable(); err != nil {
+			return err
+		}
+		if err := engine.confirmDestructive("collections " + action); err != nil {
+			return err
+		}
+		if !*yes {
+			return fmt.Errorf("%s apaga todos os pontos de %s: confirme com -yes", action, name)
+		}
+		spec, err := describeCollection(ctx, engine, name)
+		if err != nil {
+			return err
+		}
+		if err := dropCollection(ctx, engine, name); err != nil {
+			return err
+		}
+		if action == "recreate" {
+			return createCollection(ctx, engine, name, spec)
+		}
+		return nil
+	default:
+		return fmt.Errorf("ação desconhecida %q (use list, create, snapshot, drop ou recreate)", action)
+	}
+}
+
+// listCollections mostra as collections do Qdrant; as efêmeras (ver
+// `orchestrator -ttl`) com a validade do manifesto
+func listCollections(ctx context.Context, engine *AlanaEngine) error {
+	names, err := engine.client.ListCollections(ctx)
+	if err != nil {
+		return err
+	}
+	slices.Sort(names)
+
+	docs, err := manifest.Open(ctx, os.Getenv("ALANA_MANIFEST"))
+	if err != nil {
+		return err
+	}
+	defer docs.Close()
+	ephemeral, err := docs.ListCollections(ctx)
+	if err != nil {
+		return err
+	}
+	expires := map[string]time.Time{}
+	for _, c := range ephemeral {
+		expires[c.Name] = c.ExpiresAt
+	}
+
+	if len(names) == 0 {
+		fmt.Println("Nenhuma collection no Qdrant (crie com `alana collections create`)")
+		return nil
+	}
+	fmt.Printf("  %-30s %10s %6s %-8s %-7s %s\n", "COLLECTION", "PONTOS", "DIM", "TIPO", "ESTADO", "EXPIRA")
+	for _, name := range names {
+		info, err := engine.client.GetCollectionInfo(ctx, name)
+		if err != nil {
+			return fmt.Errorf("collection %s: %w", name, err)
+		}
+		marker := " "
+		if name == engine.collection {
+			marker = "*"
+		}
+		expiry := "-"
+		if t, ok := expires[name]; ok {
+			expiry = t.Local().Format(time.DateTime)
+		}
+		fmt.Printf("%s %-30s %10d %6d %-8s %-7s %s\n", marker, name, info.GetPointsCount(), collectionDim(info),
+			collectionDatatype(info), collectionStatus(info), expiry)
+	}
+	return nil
+}
+
+// createCollection cria a collection no formato do ingestor (ver
+// pointStore.ensureCollection no orchestrator)
+func createCollection(ctx context.Context, engine *AlanaEngine, name string, spec collectionSpec) error {
+	exists, err := engine.client.CollectionExists(ctx, name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("a collection %s já existe (use recreate para esvaziá-la)", name)
+	}
+
+	datatype := qdrant.Datatype_Float32
+	if spec.datatype == vecenc.Float16 {
+		datatype = qdrant.Datatype_Float16
+	}
+	err = engine.client.CreateCollection(ctx, &qdrant.CreateCollection{
+		CollectionName: name,
+		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
+			Size:     spec.dim,
+			Distance: qdrant.Distance_Cosine,
+			Datatype: datatype.Enum(),
+		}),
+		SparseVectorsConfig: qdrant.NewSparseVectorsConfig(map[string]*qdrant.SparseVectorParams{
+			lexical.VectorName: {Modifier: qdrant.Modifier_Idf.Enum()},
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("qdrant create collection failed: %w", err)
+	}
+	target := engine.withCollection(name)
+	if err := target.ensureFieldIndex(ctx, "text", qdrant.FieldType_FieldTypeText); err != nil {
+		return err
+	}
+	if err := target.ensureFieldIndex(ctx, "file_name", qdrant.FieldType_FieldTypeKeyword); err != nil {
+		return err
+	}
+	cliLog.Info("Collection criada", "collection", name, "dim", spec.dim, "datatype", spec.datatype, "distance", "cosine")
+	return nil
+}
+
+// dropCollection apaga a collection no Qdrant e, como o reaper, as entradas
+// dela no manifesto: sem isso, a próxima ingestão pularia os arquivos
+// "inalterados" e a collection recriada ficaria vazia
+func dropCollection(ctx context.Context, engine *AlanaEngine, name string) error {
+	if err := engine.client.DeleteCollection(ctx, name); err != nil {
+		return fmt.Errorf("apagar collection %s: %w", name, err)
+	}
+	engine.forgetCollection(name)
+
+	docs, err := manifest.Open(ctx, os.Getenv("ALANA_MANIFEST"))
+	if err != nil {
+		return err
+	}
+	defer docs.Close()
+	if err := docs.DeleteCollection(ctx, name); err != nil {
+		return fmt.Errorf("remover %s do manifesto: %w", name, err)
+	}
+	cliLog.Info("Collection apagada", "collection", name)
+	return nil
+}
+
+// snapshotCollection pede um snapshot ao Qdrant. O arquivo fica no servidor
+// do Qdrant (storage/snapshots), de onde pode ser baixado ou restaurado.
+func snapshotCollection(ctx context.Context, engine *AlanaEngine, name string) error {
+	cliLog.Info("Criando snapshot", "collection", name)
+	snap, err := engine.client.CreateSnapshot(ctx, name)
+	if err != nil {
+		return fmt.Errorf("snapshot de %s: %w", name, err)
+	}
+	cliLog.Info("Snapshot criado", "snapshot", snap.GetName(), "mb", fmt.Sprintf("%.1f", float64(snap.GetSize())/(1<<20)))
+
+	all, err := engine.client.ListSnapshots(ctx, name)
+	if err == nil && len(all) > 1 {
+		fmt.Printf("   %d snapshots de %s no Qdrant\n", len(all), name)
+	}
+	return nil
+}
+
+// describeCollection lê o formato do vetor de uma collection existente
+func describeCollection(ctx context.Context, engine *AlanaEngine, name string) (collectionSpec, error) {
+	info, err := engine.client.GetCollectionInfo(ctx, name)
+	if err != nil {
+		return collectionSpec{}, fmt.Errorf("collection %s: %w", name, err)
+	}
+	return collectionSpec{dim: collectionDim(info), datatype: collectionDatatype(info)}, nil
+}
+
+// embedderDim pergunta a dimensão ao embedder configurado para a collection
+func embedderDim(ctx context.Context, engine *AlanaEngine, name string) (uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, engine.timeout)
+	defer cancel()
+	vector, err := engine.collections.embedding(name).embedder().Embed(ctx, "alana collections", vecenc.Float32)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(len(vector)), nil
+}
+
+func collectionDatatype(info *qdrant.CollectionInfo) string {
+	if info.GetConfig().GetParams().GetVectorsConfig().GetParams().GetDatatype() == qdrant.Datatype_Float16 {
+		return vecenc.Float16
+	}
+	return vecenc.Float32
+}
+
+func collectionStatus(info *qdrant.CollectionInfo) string {
+	switch info.GetStatus() {
+	case qdrant.CollectionStatus_Green:
+		return "green"
+	case qdrant.CollectionStatus_Yellow:
+		return "yellow"
+	case qdrant.CollectionStatus_Red:
+		return "red"
+	case qdrant.CollectionStatus_Grey:
+		return "grey"
+	}
+	return "?"
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"alana_system/config"
+	"alana_system/yamlite"
+)
+
+// ==============================
+// Registro de collections
+// ==============================
+
+const collectionsConfigPath = "config/collections.yaml"
+
+// defaultScoreThreshold é a similaridade mínima padrão da busca vetorial
+// (score_threshold / ALANA_SCORE_THRESHOLD, aplicado pelo main)
+var defaultScoreThreshold = config.Default().ScoreThreshold
+
+// defaultCutoffs são os cortes de abstenção e de re-ranking padrão
+// (config.Load, ajustados por `alana calibrate`)
+var defaultCutoffs scoreCutoffs
+
+var errUnknownProfile = errors.New("perfil desconhecido")
+
+// retrievalSettings é uma camada de ajustes de recuperação e prompt. Campos
+// nil não definem nada e herdam da camada anterior.
+type retrievalSettings struct {
+	// PromptTemplate usa os marcadores {context} e {question}
+	PromptTemplate *string
+	TopK           *uint64
+	ScoreThreshold *float32
+	Rerank         *bool
+	Hybrid         *bool
+	// Clarify pergunta de volta quando a busca é ambígua (ver ambiguousSources)
+	Clarify *bool
+	// Rewrite é a reescrita da pergunta antes da busca (hyde, multi ou off)
+	Rewrite *string
+}
+
+// collectionEmbedding é o embedder de uma collection: o provedor (ver
+// config.EmbeddingProviders), a base da API e o nome do modelo. Vazio =
+// padrão do provedor (ver collectionEmbedding.embedder).
+type collectionEmbedding struct {
+	Provider string
+	URL      string
+	Model    string
+}
+
+// collectionRegistry guarda os padrões de cada collection e os perfis
+// nomeados que um pedido pode escolher.
+type collectionRegistry struct {
+	collections map[string]retrievalSettings
+	profiles    map[string]retrievalSettings
+	embeddings  map[string]collectionEmbedding
+}
+
+func newCollectionRegistry() *collectionRegistry {
+	return &collectionRegistry{
+		collections: map[string]retrievalSettings{},
+		profiles:    map[string]retrievalSettings{},
+		embeddings:  map[string]collectionEmbedding{},
+	}
+}
+
+// embedding devolve o embedder da collection, com o provedor do config (e,
+// se for o sidecar, o sidecar padrão) se a collection não declarar outro
+func (r *collectionRegistry) embedding(collection string) collectionEmbedding {
+	emb := r.embeddings[collection]
+	if emb.Provider == "" {
+		emb.Provider = defaultEmbeddingProvider
+	}
+	if emb.URL == "" && emb.Provider == "sidecar" {
+		emb.URL = sidecarURL
+	}
+	return emb
+}
+
+// load aplica config/collections.yaml, se existir:
+//
+//	collections:
+//	  alana_knowledge_base:
+//	    prompt_template: "Contexto: {context}\n\nPergunta: {question}\nResposta:"
+//	    top_k: 5
+//	    score_threshold: 0.3
+//	    rerank: false
+//	    hybrid: true          # busca por palavra-chave junto (se a collection tiver o vetor bm25)
+//	    clarify: true
+//	    rewrite: hyde         # reescreve a pergunta antes da busca: hyde, multi ou off
+//	    embedding_model: intfloat/multilingual-e5-base   # carregado pelo sidecar sob demanda
+//	    embedding_url: http://127.0.0.1:8001              # outro sidecar (opcional)
+//	    embedding_provider: ollama                        # sidecar, openai ou ollama (padrão: embedding_provider do config)
+//	profiles:
+//	  preciso:
+//	    top_k: 3
+//	    score_threshold: 0.5
+//	    rerank: true
+func (r *collectionRegistry) load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	doc, err := yamlite.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, section := range []struct {
+		key  string
+		into map[string]retrievalSettings
+	}{
+		{"collections", r.collections},
+		{"profiles", r.profiles},
+	} {
+		entries, _ := doc[section.key].(map[string]any)
+		for name, raw := range entries {
+			fields, ok := raw.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%s: %s.%s must be a mapping", path, section.key, name)
+			}
+			var s retrievalSettings
+			for key, value := range fields {
+				if section.key == "collections" {
+					handled, err := r.setEmbedding(name, key, value)
+					if err != nil {
+						return fmt.Errorf("%s: %s.%s.%s: %w", path, section.key, name, key, err)
+					}
+					if handled {
+						continue
+					}
+				}
+				if err := s.set(key, value); err != nil {
+					return fmt.Errorf("%s: %s.%s.%s: %w", path, section.key, name, key, err)
+				}
+			}
+			section.into[name] = s
+		}
+	}
+	return nil
+}
+
+// setEmbedding lê embedding_provider, embedding_model e embedding_url, que só
+// valem para collections (o modelo do vetor é da collection, não do perfil)
+func (r *collectionRegistry) setEmbedding(collection, key string, value any) (bool, error) {
+	if key != "embedding_provider" && key != "embedding_model" && key != "embedding_url" {
+		return false, nil
+	}
+	v, ok := value.(string)
+	if !ok || v == "" {
+		return true, errors.New("must be a non-empty string")
+	}
+	emb := r.embeddings[collection]
+	switch key {
+	case "embedding_provider":
+		if !slices.Contains(config.EmbeddingProviders, v) {
+			return true, fmt.Errorf("unknown provider %q (use %s)", v, strings.Join(config.EmbeddingProviders, ", "))
+		}
+		emb.Provider = v
+	case "embedding_model":
+		emb.Model = v
+	default:
+		emb.URL = strings.TrimSuffix(v, "/")
+	}
+	r.embeddings[collection] = emb
+	return true, nil
+}
+
+func (s *retrievalSettings) set(key string, value any) error {
+	switch key {
+	case "prompt_template":
+		v, ok := value.(string)
+		if !ok || v == "" {
+			return errors.New("must be a non-empty string")
+		}
+		s.PromptTemplate = &v
+	case "top_k":
+		n, ok := value.(int64)
+		if !ok || n <= 0 {
+			return errors.New("must be a positive integer")
+		}
+		k := uint64(n)
+		s.TopK = &k
+	case "score_threshold":
+		var f float64
+		switch v := value.(type) {
+		case int64:
+			f = float64(v)
+		case float64:
+			f = v
+		default:
+			return errors.New("must be a number")
+		}
+		if f < 0 || f > 1 {
+			return errors.New("must be between 0 and 1")
+		}
+		t := float32(f)
+		s.ScoreThreshold = &t
+	case "rerank":
+		v, ok := value.(bool)
+		if !ok {
+			return errors.New("must be a boolean")
+		}
+		s.Rerank = &v
+	case "hybrid":
+		v, ok := value.(bool)
+		if !ok {
+			return errors.New("must be a boolean")
+		}
+		s.Hybrid = &v
+	case "clarify":
+		v, ok := value.(bool)
+		if !ok {
+			return errors.New("must be a boolean")
+		}
+		s.Clarify = &v
+	case "rewrite":
+		v, ok := value.(string)
+		if !ok {
+			return errors.New("must be a string")
+		}
+		if _, err := parseRewriteMode(v); err != nil {
+			return err
+		}
+		s.Rewrite = &v
+	default:
+		return errors.New("unknown field")
+	}
+	return nil
+}
+
+// apply sobrepõe a camada às opções; campos nil mantêm o valor atual
+func (s retrievalSettings) apply(opts *askOptions) {
+	if s.PromptTemplate != nil {
+		opts.PromptTemplate = *s.PromptTemplate
+	}
+	if s.TopK != nil {
+		opts.TopK = *s.TopK
+	}
+	if s.ScoreThreshold != nil {
+		opts.ScoreThreshold = *s.ScoreThreshold
+	}
+	if s.Rerank != nil {
+		opts.Rerank = *s.Rerank
+	}
+	if s.Hybrid != nil {
+		opts.Hybrid = *s.Hybrid
+	}
+	if s.Clarify != nil {
+		opts.Clarify = *s.Clarify
+	}
+	if s.Rewrite != nil {
+		opts.Rewrite, _ = parseRewriteMode(*s.Rewrite) // já validado
+	}
+}
+
+// askOptions resolve as opções de uma pergunta, da menor para a maior
+// precedência:
+//
+//  1. padrões embutidos (defaultAskOptions)
+//  2. padrões da collection (collections.<nome>)
+//  3. perfil escolhido no pedido (profiles.<nome>)
+//  4. ajustes do próprio pedido
+func (r *collectionRegistry) askOptions(collection, profile string, request retrievalSettings) (askOptions, error) {
+	opts := defaultAskOptions()
+	r.collections[collection].apply(&opts)
+
+	if profile != "" {
+		p, ok := r.profiles[profile]
+		if !ok {
+			return askOptions{}, fmt.Errorf("%w: %s", errUnknownProfile, profile)
+		}
+		p.apply(&opts)
+	}
+
+	request.apply(&opts)
+	return opts, nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import "context"
+
+// ==============================
+// Subcomandos
+// ==============================
+
+// command é um subcomando do binário (ex: `alana similar <chunk-id>`).
+// Recebe os argumentos que vêm depois do nome do subcomando.
+type command func(ctx context.Context, engine *AlanaEngine, args []string) error
+
+var commands = map[string]command{
+	"similar":         runSimilar,
+	"topics":          runTopics,
+	"conflicts":       runConflicts,
+	"migrate-payload": runMigratePayload,
+	"serve":           runServe,
+	"doctor":          runDoctor,
+	"gc":              runGC,
+	"analytics":       runAnalytics,
+	"saved":           runSaved,
+	"export":          runExport,
+	"calibrate":       runCalibrate,
+	"chat":            runChat,
+	"reap":            runReap,
+	"memory":          runMemory,
+	"sync":            runSync,
+	"freshness":       runFreshness,
+	"canary":          runCanary,
+	"warmup":          runWarmup,
+	"ask":             runAsk,
+	"guardrails":      runGuardrails,
+	"collections":     runCollections,
+	"local-index":     runLocalIndex,
+}
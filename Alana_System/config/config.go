@@ -0,0 +1,697 @@
+// Package config carrega os endereços e ajustes que o motor de busca e o
+// orchestrator compartilham: sidecar Python, provedor de embedding, Qdrant,
+// collection, limiar de similaridade e número de workers da ingestão.
+//
+// A ordem é padrões → arquivo YAML (config/alana.yaml, ou ALANA_CONFIG) →
+// variáveis de ambiente. O resultado é validado antes de ser usado.
+//
+//	sidecar_url: http://127.0.0.1:8000
+//	embedding_provider: sidecar   # sidecar, openai ou ollama
+//	generation_provider: sidecar  # sidecar, openai, ollama ou anthropic
+//	qdrant_addr: 127.0.0.1:6334
+//	collection: alana_knowledge_base
+//	score_threshold: 0.3
+//	abstain_threshold: 0
+//	rerank_threshold: 0
+//	rerank_abstain_threshold: 0
+//	workers: 4
+//
+// A seção collections_by_type separa os documentos por tipo: a ingestão grava
+// cada tipo listado na sua collection (os outros ficam em collection) e a
+// busca procura nelas também, cada uma com os ajustes dela em
+// collections.yaml. As variáveis ALANA_COLLECTION_PDF, ALANA_COLLECTION_AUDIO e
+// ALANA_COLLECTION_NOTE sobrepõem cada tipo:
+//
+//	collections_by_type:
+//	  pdf: alana_pdfs
+//	  audio: alana_audio
+//	  note: alana_notes
+//
+// A seção generation ajusta cada provedor de geração; model é o padrão
+// quando o pedido não escolhe um, e url troca a base da API (ex: um servidor
+// compatível com a OpenAI):
+//
+//	generation:
+//	  openai:
+//	    model: gpt-4o-mini
+//	    temperature: 0.2
+//	    max_tokens: 1024
+//	  anthropic:
+//	    model: claude-3-5-haiku-latest
+//	    max_tokens: 1024
+//
+// Os limiares podem ser ajustados a partir de pares rotulados com
+// `alana calibrate -write`, que grava no arquivo com Set.
+//
+// O mesmo arquivo pode descrever vários ambientes. O escolhido com --env (ou
+// ALANA_ENV) sobrepõe as chaves da raiz; vars define as variáveis de ambiente
+// dos backends configurados por elas (text store, manifesto...). Um ambiente
+// protected (padrão para prod/production) recusa os comandos destrutivos sem
+// confirmação explícita e não sobe com ajustes inseguros (ver
+// productionInterlocks).
+//
+//	environments:
+//	  dev:
+//	    qdrant_addr: 127.0.0.1:6334
+//	  prod:
+//	    qdrant_addr: qdrant.prod.interno:6334
+//	    sidecar_url: http://sidecar.prod.interno:8000
+//	    vars:
+//	      ALANA_TEXT_STORE: postgres:postgres://alana@db.prod.interno/alana
+//	      ALANA_CHAT_STORE: qdrant:alana_chats
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"alana_system/yamlite"
+)
+
+// DefaultPath é o arquivo lido quando ALANA_CONFIG não está definida
+const DefaultPath = "config/alana.yaml"
+
+// maxWorkers limita os processos Python simultâneos da ingestão
+const maxWorkers = 64
+
+// EmbeddingProviders são os provedores de embedding aceitos em
+// embedding_provider: o sidecar Python, a API de embeddings da OpenAI (ou
+// compatível) e o /api/embeddings do Ollama
+var EmbeddingProviders = []string{"sidecar", "openai", "ollama"}
+
+// GenerationProviders são os provedores de geração aceitos em
+// generation_provider e na seção generation: o sidecar Python, o
+// /chat/completions da OpenAI (ou compatível), o /api/chat do Ollama e a
+// Messages API da Anthropic
+var GenerationProviders = []string{"sidecar", "openai", "ollama", "anthropic"}
+
+// DocumentTypes são os tipos aceitos em collections_by_type: os content_type
+// dos arquivos ingeridos pelo orchestrator (ver schema.ContentType)
+var DocumentTypes = []string{"pdf", "audio", "note"}
+
+// maxTemperature é o maior valor aceito pelas APIs de geração
+const maxTemperature = 2
+
+// collectionName segue as regras de nome do Qdrant
+var collectionName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,255}$`)
+
+// Config são os ajustes compartilhados entre o motor e o orchestrator
+type Config struct {
+	// SidecarURL é a base do bridge.py (/embed, /generate, /rerank...)
+	SidecarURL string
+	// EmbeddingProvider gera os vetores das perguntas e do IngestText (ver
+	// EmbeddingProviders); collections.yaml pode trocá-lo por collection
+	EmbeddingProvider string
+	// GenerationProvider gera as respostas quando o pedido não escolhe outro
+	// provedor (ver GenerationProviders)
+	GenerationProvider string
+	// Generation são as opções de cada provedor de geração, pelo nome
+	Generation map[string]GenerationOptions
+	// QdrantAddr é o host:porta gRPC do Qdrant
+	QdrantAddr string
+	// Collection é a collection principal da base de conhecimento
+	Collection string
+	// CollectionsByType é a collection de cada tipo de documento (ver
+	// DocumentTypes) que não fica na principal. Vazio = tudo em Collection.
+	CollectionsByType map[string]string
+	// ScoreThreshold é a similaridade mínima padrão da busca vetorial
+	ScoreThreshold float32
+	// AbstainThreshold: se o trecho mais similar ficar abaixo disso, a
+	// pergunta não é respondida (0 = sempre responde)
+	AbstainThreshold float32
+	// RerankThreshold descarta trechos com relevância do cross-encoder
+	// (0..1) abaixo disso; RerankAbstainThreshold é o corte de abstenção
+	// com re-ranking. 0 desliga cada um.
+	RerankThreshold        float32
+	RerankAbstainThreshold float32
+	// Workers é quantos arquivos a ingestão processa ao mesmo tempo
+	Workers int
+
+	// Env é o ambiente escolhido (vazio = só a raiz do arquivo)
+	Env string
+	// Protected marca o ambiente em que comandos destrutivos pedem
+	// confirmação (ver Config.Confirm)
+	Protected bool
+	// Vars são as variáveis de ambiente do ambiente escolhido (ver ExportVars)
+	Vars map[string]string
+}
+
+// GenerationOptions ajusta um provedor de geração; os campos zerados ficam
+// com o padrão do provedor
+type GenerationOptions struct {
+	// Model é o modelo usado quando o pedido não escolhe um
+	Model string
+	// URL troca a base da API do provedor
+	URL string
+	// Temperature é a temperatura de amostragem (nil = padrão do provedor)
+	Temperature *float64
+	// MaxTokens limita o tamanho da resposta (0 = padrão do provedor)
+	MaxTokens int
+}
+
+// Default devolve os valores usados quando nada é configurado.
+// AJUSTE: 127.0.0.1 em vez de localhost para evitar o ::1 no Windows.
+func Default() Config {
+	return Config{
+		SidecarURL:         "http://127.0.0.1:8000",
+		EmbeddingProvider:  "sidecar",
+		GenerationProvider: "sidecar",
+		QdrantAddr:         "127.0.0.1:6334",
+		Collection:         "alana_knowledge_base",
+		ScoreThreshold:     0.3,
+		Workers:            4,
+	}
+}
+
+// Load lê o arquivo de ALANA_CONFIG (ou DefaultPath, se existir) com o
+// ambiente env (vazio = ALANA_ENV), aplica as variáveis de ambiente e valida o
+// resultado
+func Load(env string) (Config, error) {
+	cfg := Default()
+	if env == "" {
+		env = strings.TrimSpace(os.Getenv("ALANA_ENV"))
+	}
+	cfg.Env = env
+
+	path, explicit := Path()
+	if err := cfg.loadFile(path, explicit || env != ""); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.checkProtected(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Path devolve o arquivo de configuração e se ele foi pedido explicitamente
+// (ALANA_CONFIG)
+func Path() (string, bool) {
+	if path, ok := os.LookupEnv("ALANA_CONFIG"); ok {
+		return path, true
+	}
+	return DefaultPath, false
+}
+
+// loadFile aplica o YAML; sem o arquivo padrão, fica tudo como está. Um
+// arquivo pedido explicitamente (ou com um ambiente escolhido) precisa existir.
+func (c *Config) loadFile(path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return c.applyYAML(path, data)
+}
+
+func (c *Config) applyYAML(path string, data []byte) error {
+	doc, err := yamlite.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	envs, err := environments(doc["environments"])
+	if err != nil {
+		return fmt.Errorf("%s: environments: %w", path, err)
+	}
+	delete(doc, "environments")
+	if err := c.applyKeys(doc); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if c.Env == "" {
+		return nil
+	}
+	section, ok := envs[c.Env]
+	if !ok {
+		return fmt.Errorf("%s: ambiente %q não existe (disponíveis: %s)",
+			path, c.Env, strings.Join(slices.Sorted(maps.Keys(envs)), ", "))
+	}
+	if err := c.applyEnvironment(section); err != nil {
+		return fmt.Errorf("%s: environments.%s: %w", path, c.Env, err)
+	}
+	return nil
+}
+
+func (c *Config) applyKeys(doc map[string]any) error {
+	for key, value := range doc {
+		if key == "generation" {
+			if err := c.applyGeneration(value); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			continue
+		}
+		if key == "collections_by_type" {
+			if err := c.applyCollectionsByType(value); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			continue
+		}
+		if err := c.set(key, fmt.Sprint(value)); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// applyGeneration lê a seção generation: um mapa de provedor → opções. Num
+// ambiente, as opções de um provedor sobrepõem as da raiz campo a campo.
+func (c *Config) applyGeneration(value any) error {
+	providers, ok := value.(map[string]any)
+	if !ok {
+		return errors.New("esperado um mapa de provedores")
+	}
+	if c.Generation == nil {
+		c.Generation = map[string]GenerationOptions{}
+	} else {
+		c.Generation = maps.Clone(c.Generation)
+	}
+	for name, section := range providers {
+		fields, ok := section.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: esperado um mapa de opções", name)
+		}
+		opts := c.Generation[name]
+		for key, v := range fields {
+			if err := opts.set(key, fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("%s.%s: %w", name, key, err)
+			}
+		}
+		c.Generation[name] = opts
+	}
+	return nil
+}
+
+// applyCollectionsByType lê a seção collections_by_type: um mapa de tipo →
+// collection. Num ambiente, os tipos listados sobrepõem os da raiz.
+func (c *Config) applyCollectionsByType(value any) error {
+	types, ok := value.(map[string]any)
+	if !ok {
+		return errors.New("esperado um mapa tipo: collection")
+	}
+	c.CollectionsByType = maps.Clone(c.CollectionsByType)
+	if c.CollectionsByType == nil {
+		c.CollectionsByType = map[string]string{}
+	}
+	for docType, collection := range types {
+		c.CollectionsByType[docType] = fmt.Sprint(collection)
+	}
+	return nil
+}
+
+// TypeCollection é a collection onde ficam os documentos do tipo (pdf, audio,
+// note): a de CollectionsByType, ou a principal
+func (c Config) TypeCollection(docType string) string {
+	if collection := c.CollectionsByType[docType]; collection != "" {
+		return collection
+	}
+	return c.Collection
+}
+
+func (o *GenerationOptions) set(key, value string) error {
+	switch key {
+	case "model":
+		o.Model = value
+	case "url":
+		o.URL = strings.TrimRight(value, "/")
+	case "temperature":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("número inválido %q", value)
+		}
+		o.Temperature = &f
+	case "max_tokens":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("inteiro inválido %q", value)
+		}
+		o.MaxTokens = n
+	default:
+		return errors.New("chave desconhecida")
+	}
+	return nil
+}
+
+// environments lê a seção environments: um mapa de ambiente → chaves
+func environments(value any) (map[string]map[string]any, error) {
+	envs := map[string]map[string]any{}
+	if value == nil {
+		return envs, nil
+	}
+	m, ok := value.(map[string]any)
+	if !ok {
+		return nil, errors.New("esperado um mapa de ambientes")
+	}
+	for name, section := range m {
+		keys, ok := section.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%s: esperado um mapa de chaves", name)
+		}
+		envs[name] = keys
+	}
+	return envs, nil
+}
+
+// applyEnvironment sobrepõe as chaves do ambiente escolhido. protected e vars
+// só existem dentro de um ambiente.
+func (c *Config) applyEnvironment(section map[string]any) error {
+	c.Protected = c.Env == "prod" || c.Env == "production"
+	keys := maps.Clone(section)
+
+	if v, ok := keys["protected"]; ok {
+		protected, ok := v.(bool)
+		if !ok {
+			return errors.New("protected: esperado true ou false")
+		}
+		c.Protected = protected
+		delete(keys, "protected")
+	}
+	if v, ok := keys["vars"]; ok {
+		vars, ok := v.(map[string]any)
+		if !ok {
+			return errors.New("vars: esperado um mapa NOME: valor")
+		}
+		c.Vars = map[string]string{}
+		for name, value := range vars {
+			if slices.ContainsFunc(envKeys, func(e struct{ env, key string }) bool { return e.env == name }) || typeCollectionEnv(name) {
+				return fmt.Errorf("vars: %s tem chave própria no arquivo", name)
+			}
+			c.Vars[name] = fmt.Sprint(value)
+		}
+		delete(keys, "vars")
+	}
+	return c.applyKeys(keys)
+}
+
+// ExportVars define as variáveis de Vars que ainda não estão no ambiente do
+// processo: quem as lê (text store, manifesto, processos Python) continua
+// lendo do ambiente, e uma variável definida explicitamente vence o arquivo.
+func (c Config) ExportVars() {
+	for name, value := range c.Vars {
+		if _, ok := os.LookupEnv(name); !ok {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+// Confirm recusa uma operação destrutiva num ambiente protegido sem a
+// confirmação explícita de quem executa (ex: --yes-prod)
+func (c Config) Confirm(operation string, confirmed bool) error {
+	if !c.Protected || confirmed {
+		return nil
+	}
+	return fmt.Errorf("%s no ambiente protegido %q: confirme com --yes-prod", operation, c.Env)
+}
+
+// minProductionKeyBytes é o tamanho mínimo das chaves de admin e das
+// privilegiadas num ambiente protegido
+const minProductionKeyBytes = 16
+
+// productionInterlocks são as variáveis que um ambiente protegido recusa na
+// subida: ajustes de depuração e de teste que vazam dados ou abrem a API
+var productionInterlocks = []struct {
+	env    string
+	reason string
+	unsafe func(value string) bool
+}{
+	{"ALANA_PROVIDER_LOG", "grava prompts e respostas completos em disco", func(v string) bool {
+		on, _ := strconv.ParseBool(v)
+		return on
+	}},
+	{"ALANA_FAULTS", "injeta falhas nas chamadas ao sidecar e ao Qdrant", func(v string) bool {
+		return v != ""
+	}},
+	{"ALANA_CORS_ORIGINS", `"*" libera a API para qualquer site`, func(v string) bool {
+		return slices.Contains(splitList(v), "*")
+	}},
+	{"ALANA_ADMIN_KEYS", fmt.Sprintf("chave com menos de %d bytes", minProductionKeyBytes), shortKey},
+	{"ALANA_PRIVILEGED_KEYS", fmt.Sprintf("chave com menos de %d bytes", minProductionKeyBytes), shortKey},
+}
+
+func shortKey(v string) bool {
+	return slices.ContainsFunc(splitList(v), func(key string) bool { return len(key) < minProductionKeyBytes })
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// checkProtected recusa um ambiente protegido com algum dos
+// productionInterlocks, no processo ou nas vars do arquivo (que ExportVars
+// ainda vai definir). Não há confirmação: para subir, corrija a variável ou
+// marque o ambiente com protected: false.
+func (c Config) checkProtected() error {
+	if !c.Protected {
+		return nil
+	}
+	var errs []error
+	for _, lock := range productionInterlocks {
+		value, ok := os.LookupEnv(lock.env)
+		if !ok {
+			value = c.Vars[lock.env]
+		}
+		if lock.unsafe(strings.TrimSpace(value)) {
+			errs = append(errs, fmt.Errorf("%s: %s", lock.env, lock.reason))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("ambiente protegido %q recusado: %w", c.Env, err)
+	}
+	return nil
+}
+
+// envKeys liga cada variável de ambiente à chave equivalente do YAML
+var envKeys = []struct{ env, key string }{
+	{"ALANA_SIDECAR_URL", "sidecar_url"},
+	{"ALANA_EMBEDDING_PROVIDER", "embedding_provider"},
+	{"ALANA_GENERATION_PROVIDER", "generation_provider"},
+	{"ALANA_QDRANT_ADDR", "qdrant_addr"},
+	{"ALANA_COLLECTION", "collection"},
+	{"ALANA_SCORE_THRESHOLD", "score_threshold"},
+	{"ALANA_ABSTAIN_THRESHOLD", "abstain_threshold"},
+	{"ALANA_RERANK_THRESHOLD", "rerank_threshold"},
+	{"ALANA_RERANK_ABSTAIN_THRESHOLD", "rerank_abstain_threshold"},
+	{"ALANA_WORKERS", "workers"},
+}
+
+func (c *Config) loadEnv() error {
+	for _, e := range envKeys {
+		value := strings.TrimSpace(os.Getenv(e.env))
+		if value == "" {
+			continue
+		}
+		if err := c.set(e.key, value); err != nil {
+			return fmt.Errorf("%s: %w", e.env, err)
+		}
+	}
+	for _, docType := range DocumentTypes {
+		value := strings.TrimSpace(os.Getenv(typeCollectionVar(docType)))
+		if value == "" {
+			continue
+		}
+		c.CollectionsByType = maps.Clone(c.CollectionsByType)
+		if c.CollectionsByType == nil {
+			c.CollectionsByType = map[string]string{}
+		}
+		c.CollectionsByType[docType] = value
+	}
+	return nil
+}
+
+// typeCollectionVar é a variável de ambiente da collection do tipo
+// (ALANA_COLLECTION_PDF...)
+func typeCollectionVar(docType string) string {
+	return "ALANA_COLLECTION_" + strings.ToUpper(docType)
+}
+
+func typeCollectionEnv(name string) bool {
+	return slices.ContainsFunc(DocumentTypes, func(t string) bool { return typeCollectionVar(t) == name })
+}
+
+func (c *Config) set(key, value string) error {
+	switch key {
+	case "sidecar_url":
+		c.SidecarURL = strings.TrimRight(value, "/")
+	case "embedding_provider":
+		c.EmbeddingProvider = value
+	case "generation_provider":
+		c.GenerationProvider = value
+	case "qdrant_addr":
+		c.QdrantAddr = value
+	case "collection":
+		c.Collection = value
+	case "score_threshold", "abstain_threshold", "rerank_threshold", "rerank_abstain_threshold":
+		f, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return fmt.Errorf("número inválido %q", value)
+		}
+		*c.threshold(key) = float32(f)
+	case "workers":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("inteiro inválido %q", value)
+		}
+		c.Workers = n
+	default:
+		return errors.New("chave desconhecida")
+	}
+	return nil
+}
+
+// thresholdKeys são as chaves dos limiares, todos em [0, 1]
+var thresholdKeys = []string{"score_threshold", "abstain_threshold", "rerank_threshold", "rerank_abstain_threshold"}
+
+func (c *Config) threshold(key string) *float32 {
+	switch key {
+	case "abstain_threshold":
+		return &c.AbstainThreshold
+	case "rerank_threshold":
+		return &c.RerankThreshold
+	case "rerank_abstain_threshold":
+		return &c.RerankAbstainThreshold
+	}
+	return &c.ScoreThreshold
+}
+
+// Validate confere cada campo e junta todos os problemas num único erro
+func (c Config) Validate() error {
+	var errs []error
+	if u, err := url.Parse(c.SidecarURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("sidecar_url: esperado http(s)://host:porta, recebido %q", c.SidecarURL))
+	}
+	if !slices.Contains(EmbeddingProviders, c.EmbeddingProvider) {
+		errs = append(errs, fmt.Errorf("embedding_provider: %q desconhecido (use %s)", c.EmbeddingProvider, strings.Join(EmbeddingProviders, ", ")))
+	}
+	if !slices.Contains(GenerationProviders, c.GenerationProvider) {
+		errs = append(errs, fmt.Errorf("generation_provider: %q desconhecido (use %s)", c.GenerationProvider, strings.Join(GenerationProviders, ", ")))
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.Generation)) {
+		errs = append(errs, c.Generation[name].validate(name)...)
+	}
+	if _, _, err := c.QdrantHostPort(); err != nil {
+		errs = append(errs, fmt.Errorf("qdrant_addr: %w", err))
+	}
+	if !collectionName.MatchString(c.Collection) {
+		errs = append(errs, fmt.Errorf("collection: nome inválido %q (letras, dígitos, _ e -)", c.Collection))
+	}
+	for _, docType := range slices.Sorted(maps.Keys(c.CollectionsByType)) {
+		collection := c.CollectionsByType[docType]
+		if !slices.Contains(DocumentTypes, docType) {
+			errs = append(errs, fmt.Errorf("collections_by_type.%s: tipo desconhecido (use %s)", docType, strings.Join(DocumentTypes, ", ")))
+		}
+		if !collectionName.MatchString(collection) {
+			errs = append(errs, fmt.Errorf("collections_by_type.%s: nome inválido %q (letras, dígitos, _ e -)", docType, collection))
+		}
+	}
+	for _, key := range thresholdKeys {
+		if v := *c.threshold(key); v < 0 || v > 1 {
+			errs = append(errs, fmt.Errorf("%s: %v fora de [0, 1]", key, v))
+		}
+	}
+	if c.Workers < 1 || c.Workers > maxWorkers {
+		errs = append(errs, fmt.Errorf("workers: %d fora de [1, %d]", c.Workers, maxWorkers))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("configuração inválida: %w", err)
+	}
+	return nil
+}
+
+func (o GenerationOptions) validate(name string) []error {
+	var errs []error
+	if !slices.Contains(GenerationProviders, name) {
+		errs = append(errs, fmt.Errorf("generation.%s: provedor desconhecido (use %s)", name, strings.Join(GenerationProviders, ", ")))
+	}
+	if o.URL != "" {
+		if u, err := url.Parse(o.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("generation.%s.url: esperado http(s)://host, recebido %q", name, o.URL))
+		}
+	}
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > maxTemperature) {
+		errs = append(errs, fmt.Errorf("generation.%s.temperature: %v fora de [0, %d]", name, *o.Temperature, maxTemperature))
+	}
+	if o.MaxTokens < 0 {
+		errs = append(errs, fmt.Errorf("generation.%s.max_tokens: %d negativo", name, o.MaxTokens))
+	}
+	return errs
+}
+
+// QdrantHostPort separa QdrantAddr para o qdrant.Config
+func (c Config) QdrantHostPort() (string, int, error) {
+	host, portStr, err := net.SplitHostPort(c.QdrantAddr)
+	if err != nil {
+		return "", 0, fmt.Errorf("esperado host:porta, recebido %q", c.QdrantAddr)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("porta inválida %q", portStr)
+	}
+	if host == "" {
+		return "", 0, fmt.Errorf("host vazio em %q", c.QdrantAddr)
+	}
+	return host, port, nil
+}
+
+// Set grava as chaves no arquivo YAML, trocando a linha de cada chave que já
+// existe e acrescentando as demais no fim. Comentários e outras chaves ficam
+// como estão. O arquivo é criado se não existir; o resultado é validado antes
+// de ser gravado.
+func Set(path string, values map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	var lines []string
+	if text := strings.TrimRight(string(data), "\n"); text != "" {
+		lines = strings.Split(text, "\n")
+	}
+	done := map[string]bool{}
+	for i, line := range lines {
+		key, _, ok := strings.Cut(line, ":")
+		if value, set := values[key]; ok && set {
+			lines[i] = key + ": " + value
+			done[key] = true
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		if !done[key] {
+			lines = append(lines, key+": "+values[key])
+		}
+	}
+	out := []byte(strings.Join(lines, "\n") + "\n")
+
+	cfg := Default()
+	if err := cfg.applyYAML(path, out); err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, out, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
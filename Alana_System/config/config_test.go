@@ -0,0 +1,143 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const environmentsYAML = `collection: alana_knowledge_base
+environments:
+  dev:
+    qdrant_addr: 127.0.0.1:6334
+  prod:
+    qdrant_addr: qdrant.prod.interno:6334
+  production:
+    qdrant_addr: qdrant.prod.interno:6334
+  prod-aberto:
+    protected: false
+  staging-protegido:
+    protected: true
+  prod-com-vars:
+    protected: true
+    vars:
+      ALANA_PROVIDER_LOG: "true"
+`
+
+// unsetEnv apaga as variáveis durante o teste (t.Setenv as restaura no fim)
+func unsetEnv(t *testing.T, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+// withConfig aponta ALANA_CONFIG para um arquivo com os ambientes e limpa as
+// variáveis que o Load lê
+func withConfig(t *testing.T) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "alana.yaml")
+	if err := os.WriteFile(path, []byte(environmentsYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ALANA_CONFIG", path)
+	unsetEnv(t, "ALANA_ENV")
+	for _, e := range envKeys {
+		unsetEnv(t, e.env)
+	}
+	for _, lock := range productionInterlocks {
+		unsetEnv(t, lock.env)
+	}
+}
+
+// Cada variável travada impede a subida de prod com o valor inseguro e
+// passa com o valor seguro
+func TestProductionInterlocks(t *testing.T) {
+	cases := []struct {
+		env          string
+		unsafe, safe string
+	}{
+		{"ALANA_PROVIDER_LOG", "1", "false"},
+		{"ALANA_PROVIDER_LOG", "true", "0"},
+		{"ALANA_FAULTS", "sidecar:error=0.1", ""},
+		{"ALANA_CORS_ORIGINS", "*", "https://app.example.com"},
+		{"ALANA_CORS_ORIGINS", "https://app.example.com, *", "https://app.example.com,https://admin.example.com"},
+		{"ALANA_ADMIN_KEYS", "curta", strings.Repeat("a", minProductionKeyBytes)},
+		{"ALANA_ADMIN_KEYS", strings.Repeat("a", 32) + ",curta", strings.Repeat("a", 32) + "," + strings.Repeat("b", 16)},
+		{"ALANA_PRIVILEGED_KEYS", strings.Repeat("k", minProductionKeyBytes-1), strings.Repeat("k", minProductionKeyBytes)},
+	}
+	covered := map[string]bool{}
+	for _, tc := range cases {
+		covered[tc.env] = true
+		t.Run(tc.env+"="+tc.unsafe, func(t *testing.T) {
+			withConfig(t)
+
+			t.Setenv(tc.env, tc.unsafe)
+			for _, env := range []string{"prod", "production", "staging-protegido"} {
+				_, err := Load(env)
+				if err == nil || !strings.Contains(err.Error(), tc.env) {
+					t.Errorf("%s subiu com %s=%q: %v", env, tc.env, tc.unsafe, err)
+				}
+			}
+			// Fora dos ambientes protegidos, o mesmo valor é aceito
+			for _, env := range []string{"", "dev", "prod-aberto"} {
+				if _, err := Load(env); err != nil {
+					t.Errorf("ambiente %q recusado com %s=%q: %v", env, tc.env, tc.unsafe, err)
+				}
+			}
+
+			t.Setenv(tc.env, tc.safe)
+			if _, err := Load("prod"); err != nil {
+				t.Errorf("prod recusado com %s=%q: %v", tc.env, tc.safe, err)
+			}
+		})
+	}
+	for _, lock := range productionInterlocks {
+		if !covered[lock.env] {
+			t.Errorf("%s sem caso de teste", lock.env)
+		}
+	}
+}
+
+// As vars do arquivo também são conferidas, e a variável do processo vence
+func TestProductionInterlockFileVars(t *testing.T) {
+	withConfig(t)
+	if _, err := Load("prod-com-vars"); err == nil || !strings.Contains(err.Error(), "ALANA_PROVIDER_LOG") {
+		t.Errorf("prod com ALANA_PROVIDER_LOG nas vars subiu: %v", err)
+	}
+	t.Setenv("ALANA_PROVIDER_LOG", "false")
+	if _, err := Load("prod-com-vars"); err != nil {
+		t.Errorf("a variável do processo não venceu as vars: %v", err)
+	}
+}
+
+// Todos os problemas vêm num erro só
+func TestProductionInterlockJoinsErrors(t *testing.T) {
+	withConfig(t)
+	t.Setenv("ALANA_FAULTS", "qdrant:error=1")
+	t.Setenv("ALANA_CORS_ORIGINS", "*")
+	_, err := Load("prod")
+	if err == nil || !strings.Contains(err.Error(), "ALANA_FAULTS") || !strings.Contains(err.Error(), "ALANA_CORS_ORIGINS") {
+		t.Errorf("erro sem as duas variáveis: %v", err)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	withConfig(t)
+	prod, err := Load("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prod.Protected || prod.Confirm("gc -delete", false) == nil || prod.Confirm("gc -delete", true) != nil {
+		t.Errorf("prod: protected %v", prod.Protected)
+	}
+	dev, err := Load("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev.Protected || dev.Confirm("gc -delete", false) != nil {
+		t.Errorf("dev protegido")
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Duplicatas e contradições entre documentos
+// ==============================
+
+const conflictPrompt = "Compare o Trecho A e o Trecho B do contexto, que vêm de documentos diferentes. " +
+	"Responda na primeira linha com uma única palavra: CONCORDAM, CONTRADIZEM ou INDEPENDENTES. " +
+	"Na segunda linha, justifique em uma frase."
+
+type pairVerdict string
+
+const (
+	verdictAgree       pairVerdict = "CONCORDAM"
+	verdictContradict  pairVerdict = "CONTRADIZEM"
+	verdictIndependent pairVerdict = "INDEPENDENTES"
+)
+
+// chunkPair é um par de chunks muito parecidos vindos de documentos diferentes
+type chunkPair struct {
+	A, B      SearchResult
+	Score     float32
+	Verdict   pairVerdict
+	Rationale string
+}
+
+// runConflicts implementa `alana conflicts`: encontra pares de chunks muito
+// similares de documentos diferentes e pergunta ao LLM se eles concordam ou se
+// contradizem, gerando um relatório de governança da base.
+func runConflicts(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("conflicts", flag.ContinueOnError)
+	threshold := fs.Float64("threshold", 0.85, "similaridade mínima para comparar dois chunks")
+	neighbors := fs.Uint64("neighbors", 3, "vizinhos avaliados por chunk")
+	maxPairs := fs.Int("max-pairs", 50, "máximo de pares enviados ao LLM")
+	outPath := fs.String("out", "", "grava o relatório em Markdown (padrão: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cliLog.Info("Procurando pares similares entre documentos")
+	pairs, err := engine.crossDocumentPairs(ctx, float32(*threshold), *neighbors)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("   OK | %d pares acima de %.2f\n\n", len(pairs), *threshold)
+
+	if len(pairs) > *maxPairs {
+		pairs = pairs[:*maxPairs]
+	}
+
+	cliLog.Info("Avaliando pares com o LLM")
+	for i, p := range pairs {
+		verdict, rationale, err := judgePair(ctx, p.A.Text, p.B.Text)
+		if err != nil {
+			return fmt.Errorf("judge pair %d: %w", i, err)
+		}
+		pairs[i].Verdict = verdict
+		pairs[i].Rationale = rationale
+		fmt.Printf("   [%d/%d] %s x %s → %s\n", i+1, len(pairs), p.A.Source, p.B.Source, verdict)
+	}
+
+	report := conflictsReport(pairs, *threshold)
+	if *outPath == "" {
+		fmt.Println()
+		fmt.Println(report)
+		return nil
+	}
+
+	if err := os.WriteFile(*outPath, []byte(report), 0o644); err != nil {
+		return err
+	}
+	cliLog.Info("Relatório gravado", "file", *outPath)
+	return nil
+}
+
+// crossDocumentPairs busca, para cada chunk, os vizinhos de outros documentos
+// acima do limiar. Os pares são deduplicados e ordenados pela similaridade.
+func (e *AlanaEngine) crossDocumentPairs(ctx context.Context, threshold float32, neighbors uint64) ([]chunkPair, error) {
+	seen := map[string]bool{}
+	var pairs []chunkPair
+
+	err := e.scrollPoints(ctx, true, func(points []*qdrant.RetrievedPoint) error {
+		for _, p := range points {
+			vector := denseVector(p.GetVectors())
+			if len(vector) == 0 {
+				continue
+			}
+			a := resultFromPayload(p.GetId(), p.GetPayload(), 0)
+
+			queryCtx, cancel := context.WithTimeout(ctx, e.timeout)
+			hits, err := e.client.Query(queryCtx, &qdrant.QueryPoints{
+				CollectionName: e.collection,
+				Query:          qdrant.NewQueryDense(vector),
+				Filter:         visibleFilter(qdrant.NewMatchKeyword("file_name", a.Source)),
+				ScoreThreshold: &threshold,
+				Limit:          &neighbors,
+				WithPayload:    qdrant.NewWithPayload(true),
+			})
+			cancel()
+			if err != nil {
+				return fmt.Errorf("qdrant query failed: %w", err)
+			}
+
+			for _, hit := range hits {
+				b := resultFromPayload(hit.GetId(), hit.GetPayload(), hit.GetScore())
+				key := a.ID + "|" + b.ID
+				if b.ID < a.ID {
+					key = b.ID + "|" + a.ID
+				}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				pairs = append(pairs, chunkPair{A: a, B: b, Score: b.Score})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Só os trechos que formaram pares precisam do texto (text_offloaded)
+	chunks := make([]SearchResult, 0, 2*len(pairs))
+	for _, p := range pairs {
+		chunks = append(chunks, p.A, p.B)
+	}
+	if err := e.loadTexts(ctx, chunks); err != nil {
+		return nil, err
+	}
+	for i := range pairs {
+		pairs[i].A, pairs[i].B = chunks[2*i], chunks[2*i+1]
+	}
+
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Score > pairs[j].Score })
+	return pairs, nil
+}
+
+// judgePair pergunta ao LLM se os dois trechos concordam ou se contradizem
+func judgePair(ctx context.Context, a, b string) (pairVerdict, string, error) {
+	contextText := fmt.Sprintf("--- Trecho A ---\n%s\n\n--- Trecho B ---\n%s\n", truncateRunes(a, 800), truncateRunes(b, 800))
+
+	answer, err := getAnswer(ctx, conflictPrompt, contextText)
+	if err != nil {
+		return "", "", err
+	}
+
+	first, rest, _ := strings.Cut(strings.TrimSpace(answer), "\n")
+	upper := strings.ToUpper(first)
+
+	verdict := verdictIndependent
+	switch {
+	case strings.Contains(upper, string(verdictContradict)):
+		verdict = verdictContradict
+	case strings.Contains(upper, string(verdictAgree)):
+		verdict = verdictAgree
+	}
+
+	return verdict, strings.TrimSpace(rest), nil
+}
+
+// conflictsReport monta o relatório de governança, contradições primeiro
+func conflictsReport(pairs []chunkPair, threshold float64) string {
+	var b strings.Builder
+	b.WriteString("# Relatório de duplicatas e contradições\n\n")
+	fmt.Fprintf(&b, "%d pares de documentos diferentes com similaridade ≥ %.2f.\n\n", len(pairs), threshold)
+
+	sections := []struct {
+		title   string
+		verdict pairVerdict
+	}{
+		{"Contradições", verdictContradict},
+		{"Duplicatas / concordâncias", verdictAgree},
+		{"Similares sem relação direta", verdictIndependent},
+	}
+
+	for _, s := range sections {
+		var group []chunkPair
+		for _, p := range pairs {
+			if p.Verdict == s.verdict {
+				group = append(group, p)
+			}
+		}
+
+		fmt.Fprintf(&b, "## %s (%d)\n\n", s.title, len(group))
+		for _, p := range group {
+			fmt.Fprintf(&b, "### %s (pág %d) × %s (pág %d) — score %.2f\n\n", p.A.Source, p.A.Page, p.B.Source, p.B.Page, p.Score)
+			if p.Rationale != "" {
+				fmt.Fprintf(&b, "%s\n\n", p.Rationale)
+			}
+			fmt.Fprintf(&b, "> **A** (`%s`): %s\n>\n> **B** (`%s`): %s\n\n",
+				p.A.ID, oneLine(truncateRunes(p.A.Text, 300)),
+				p.B.ID, oneLine(truncateRunes(p.B.Text, 300)),
+			)
+		}
+	}
+
+	return b.String()
+}
+
+// oneLine junta as quebras de linha para caber numa citação Markdown
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Conectores (fontes externas)
+// ==============================
+
+// defaultConnectorState é onde `alana sync` guarda os cursores
+const defaultConnectorState = "./data/connectors.json"
+
+// connectorItem é um documento de uma fonte externa, gravado pelo
+// IngestText com DocID no lugar do nome do arquivo
+type connectorItem struct {
+	// DocID identifica o item nas fontes (ex: github:dono/repo#12); uma nova
+	// versão do item substitui a anterior
+	DocID       string
+	Title       string
+	Text        string
+	URL         string
+	Author      string
+	Tags        []string
+	ContentType string
+	// Fields são os metadados filtráveis do item (ver connectorFields), ex:
+	// status, assignee, product; valores vazios ficam fora do payload
+	Fields    map[string]string
+	CreatedAt time.Time
+	// UpdatedAt avança o cursor da sincronização incremental. Itens sem data
+	// (páginas de wiki) são comparados pelo hash do conteúdo.
+	UpdatedAt time.Time
+}
+
+// meta é o payload extra do item (ver IngestText)
+func (it connectorItem) meta() map[string]any {
+	fields := map[string]any{
+		"content_type": it.ContentType,
+		"tags":         anyList(it.Tags),
+	}
+	if it.Title != "" {
+		fields["title"] = it.Title
+	}
+	if it.URL != "" {
+		fields["url"] = it.URL
+	}
+	if it.Author != "" {
+		fields["author"] = it.Author
+	}
+	for key, value := range it.Fields {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	if !it.CreatedAt.IsZero() {
+		fields["created_at"] = it.CreatedAt.UTC().Format(time.RFC3339)
+		fields["created_ts"] = it.CreatedAt.Unix()
+	}
+	return fields
+}
+
+// text é o texto ingerido: o título abre o documento, para que a busca o
+// encontre também pelo nome
+func (it connectorItem) text() string {
+	if it.Title == "" {
+		return it.Text
+	}
+	return it.Title + "\n\n" + it.Text
+}
+
+func anyList(values []string) []any {
+	out := make([]any, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
+
+// connector lista os itens de uma fonte alterados desde since (zero = todos),
+// em ordem crescente de UpdatedAt, chamando yield para cada um. Um erro de
+// yield interrompe a listagem e é devolvido.
+type connector interface {
+	// Name identifica a fonte no arquivo de estado (ex: github:dono/repo)
+	Name() string
+	Fetch(ctx context.Context, since time.Time, yield func(connectorItem) error) error
+}
+
+// connectorState é o progresso de uma fonte entre as execuções
+type connectorState struct {
+	// Cursor é o maior updated_at já ingerido
+	Cursor time.Time `json:"cursor,omitzero"`
+	// Hashes guardam o conteúdo dos itens sem data, pelo DocID
+	Hashes map[string]string `json:"hashes,omitempty"`
+	// LastSuccess é o fim da última sincronização sem erro; LastError é o
+	// erro da última tentativa, vazio se ela deu certo (ver freshness)
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	LastAttempt time.Time `json:"last_attempt,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// loadConnectorStates lê o arquivo de estado (inexistente = tudo do zero)
+func loadConnectorStates(path string) (map[string]connectorState, error) {
+	states := map[string]connectorState{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return states, nil
+}
+
+// saveConnectorStates grava o arquivo de estado (via temporário + rename)
+func saveConnectorStates(path string, states map[string]connectorState) error {
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// syncStats resume uma sincronização
+type syncStats struct {
+	Ingested int
+	Skipped  int
+}
+
+// syncConnector ingere os itens novos ou alterados da fonte e avança o
+// cursor item a item: se um item falha, a sincronização para ali e a
+// próxima recomeça dele. O cursor é inclusivo (as APIs usam >=), então o
+// último item pode ser reingerido, o que não muda nada na base.
+func (e *AlanaEngine) syncConnector(ctx context.Context, c connector, state *connectorState) (syncStats, error) {
+	var stats syncStats
+	if state.Hashes == nil {
+		state.Hashes = map[string]string{}
+	}
+
+	err := c.Fetch(ctx, state.Cursor, func(it connectorItem) error {
+		text := it.text()
+		hash := ""
+		if it.UpdatedAt.IsZero() {
+			sum := sha256.Sum256([]byte(text))
+			hash = hex.EncodeToString(sum[:])
+			if state.Hashes[it.DocID] == hash {
+				stats.Skipped++
+				return nil
+			}
+		}
+
+		err := e.IngestText(ctx, it.DocID, text, it.meta())
+		if errors.Is(err, errEmptyDocument) {
+			// Issue sem descrição nem título: nada a buscar
+			stats.Skipped++
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", it.DocID, err)
+		} else {
+			stats.Ingested++
+			connectorLog.InfoContext(ctx, "Item ingerido", "doc", it.DocID)
+		}
+
+		if hash != "" {
+			state.Hashes[it.DocID] = hash
+		}
+		if it.UpdatedAt.After(state.Cursor) {
+			state.Cursor = it.UpdatedAt
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// runSync implementa `alana sync [-full] [-every 15m] [fonte...]`: ingere
+// issues, pull/merge requests, tickets, artigos e páginas de wiki de sistemas
+// externos, só o que mudou desde a última execução. Fontes:
+//
+//	github:dono/repo     issues, PRs e wiki (ALANA_GITHUB_TOKEN; a wiki vem por git clone)
+//	gitlab:grupo/projeto issues, MRs e wiki (ALANA_GITLAB_TOKEN; ALANA_GITLAB_URL, padrão https://gitlab.com)
+//	jira:PROJ            issues do projeto (ALANA_JIRA_URL, ALANA_JIRA_EMAIL, ALANA_JIRA_TOKEN)
+//	zendesk:subdominio   tickets e artigos da central de ajuda (ALANA_ZENDESK_EMAIL, ALANA_ZENDESK_TOKEN)
+//	web:URL              páginas de um sitemap ou feed RSS/Atom (ALANA_CRAWL_ALLOW, ALANA_CRAWL_DELAY,
+//	                     ALANA_CRAWL_MAX_PAGES; ver webConnector)
+//
+// Os rótulos viram tags; status, responsável, produto, prioridade e projeto
+// vão para o payload com índice, para o filtro fields do /ask. Sem fontes na
+// linha de comando, sincroniza as de config/sources.yaml (ver loadSources).
+// Com -every, repete a sincronização nesse intervalo até SIGINT/SIGTERM.
+// Itens apagados na fonte continuam na base.
+func runSync(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	full := fs.Bool("full", false, "ignora os cursores e reingere tudo (só na primeira rodada)")
+	wiki := fs.Bool("wiki", true, "inclui as páginas de wiki")
+	every := fs.Duration("every", 0, "repete a sincronização neste intervalo (0 = uma vez)")
+	statePath := fs.String("state", connectorStatePath(), "arquivo com os cursores de cada fonte")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	specs := fs.Args()
+	if len(specs) == 0 {
+		configured, err := loadSources(sourcesPath())
+		if err != nil {
+			return err
+		}
+		specs = configured.specs()
+	}
+	if len(specs) == 0 {
+		return errors.New("uso: alana sync [-full] [-every 15m] github:dono/repo | gitlab:grupo/projeto | jira:PROJ | zendesk:subdominio | web:https://site/sitemap.xml ... (ou as fontes de config/sources.yaml)")
+	}
+	if err := engine.writable(); err != nil {
+		return err
+	}
+
+	var sources []connector
+	for _, spec := range specs {
+		c, err := newConnector(spec, *wiki)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, c)
+	}
+
+	// Sem índice, o filtro por esses campos varreria a collection inteira
+	for _, field := range connectorFields {
+		if err := engine.ensureFieldIndex(ctx, field, qdrant.FieldType_FieldTypeKeyword); err != nil {
+			return err
+		}
+	}
+
+	if *every <= 0 {
+		return engine.syncAll(ctx, sources, *statePath, *full)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ticker := time.NewTicker(*every)
+	defer ticker.Stop()
+	for {
+		// Uma rodada com erro não encerra o agendamento: a próxima recomeça
+		// do cursor salvo
+		if err := engine.syncAll(ctx, sources, *statePath, *full); err != nil {
+			connectorLog.Error("Erro na sincronização", "err", err)
+		}
+		*full = false
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// syncAll sincroniza cada fonte e grava os cursores depois de cada uma
+func (e *AlanaEngine) syncAll(ctx context.Context, sources []connector, statePath string, full bool) error {
+	states, err := loadConnectorStates(statePath)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, c := range sources {
+		if ctx.Err() != nil {
+			break
+		}
+		state := states[c.Name()]
+		if full {
+			state = connectorState{}
+		}
+		connectorLog.InfoContext(ctx, "Sincronizando", "connector", c.Name(), "since", cursorLabel(state.Cursor))
+		stats, err := e.syncConnector(ctx, c, &state)
+		state.LastAttempt = time.Now().UTC()
+		if err != nil {
+			state.LastError = err.Error()
+		} else {
+			state.LastSuccess, state.LastError = state.LastAttempt, ""
+		}
+		// O progresso vale mesmo com erro: os itens já ingeridos não voltam
+		states[c.Name()] = state
+		if saveErr := saveConnectorStates(statePath, states); saveErr != nil {
+			errs = append(errs, saveErr)
+		}
+		connectorLog.InfoContext(ctx, "Sincronização concluída", "connector", c.Name(), "ingested", stats.Ingested, "skipped", stats.Skipped)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func cursorLabel(t time.Time) string {
+	if t.IsZero() {
+		return "o início"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// connectorStatePath lê ALANA_CONNECTOR_STATE (padrão ./data/connectors.json)
+func connectorStatePath() string {
+	if path := os.Getenv("ALANA_CONNECTOR_STATE"); path != "" {
+		return path
+	}
+	return defaultConnectorState
+}
+
+// newConnector cria o conector de uma fonte (tipo:projeto)
+func newConnector(spec string, wiki bool) (connector, error) {
+	kind, project, _ := strings.Cut(spec, ":")
+	if project == "" {
+		return nil, fmt.Errorf("fonte inválida %q (use tipo:projeto)", spec)
+	}
+	switch kind {
+	case "github":
+		return newGitHubConnector(project, wiki)
+	case "gitlab":
+		return newGitLabConnector(project, wiki)
+	case "jira":
+		return newJiraConnector(project)
+	case "zendesk":
+		return newZendeskConnector(project)
+	case "web":
+		return newWebConnector(project)
+	}
+	return nil, fmt.Errorf("fonte desconhecida %q (use github, gitlab, jira, zendesk ou web)", kind)
+}
+
+// getConnectorJSON faz um GET autenticado na API da fonte, com os reenvios
+// do providerHTTP e de withRetry, e decodifica o JSON em out. Devolve os
+// cabeçalhos (paginação).
+func getConnectorJSON(ctx context.Context, url string, header http.Header, out any) (http.Header, error) {
+	return withRetry(ctx, retries, "connector", func(ctx context.Context) (http.Header, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := providerHTTP.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, readStatusError("connector error", resp)
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<20)).Decode(out); err != nil {
+			return nil, err
+		}
+		return resp.Header, nil
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Base de conhecimento vazia
+// ==============================
+
+// emptyCorpusReply é a resposta quando a collection não existe ou ainda não
+// tem nenhum trecho publicado: sem isso, o LLM receberia um contexto vazio e
+// inventaria a resposta
+const emptyCorpusReply = "A base de conhecimento está vazia: nenhum documento foi indexado ainda. " +
+	"Coloque os arquivos em data/raw e rode a ingestão (go run ./orchestrator) antes de perguntar."
+
+// corpusGaugeTTL é por quanto tempo o /readyz reaproveita o tamanho do corpus
+const corpusGaugeTTL = 30 * time.Second
+
+// corpusInfo é o tamanho da base de conhecimento de uma collection
+type corpusInfo struct {
+	Collection string `json:"collection"`
+	// Collections são todas as contadas, com collections_by_type
+	Collections []string `json:"collections,omitempty"`
+	Exists      bool     `json:"exists"`
+	// Points são os trechos publicados (sem os de ingestões em andamento)
+	Points uint64 `json:"points"`
+	Empty  bool   `json:"empty"`
+}
+
+// corpusSize conta os trechos publicados da collection e das separadas por
+// tipo (ver searchCollections). Uma collection que não existe tem zero.
+func (e *AlanaEngine) corpusSize(ctx context.Context) (corpusInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	info := corpusInfo{Collection: e.collection}
+	collections := e.searchCollections(SearchFilter{})
+	if len(collections) > 1 {
+		info.Collections = collections
+	}
+	for _, c := range collections {
+		exists, err := e.client.CollectionExists(ctx, c)
+		if err != nil {
+			return info, err
+		}
+		if !exists {
+			continue
+		}
+		info.Exists = true
+		points, err := e.client.Count(ctx, &qdrant.CountPoints{
+			CollectionName: c,
+			Filter:         visibleFilter(),
+			Exact:          qdrant.PtrOf(true),
+		})
+		if err != nil {
+			return info, err
+		}
+		info.Points += points
+	}
+	info.Empty = info.Points == 0
+	return info, nil
+}
+
+// emptyCorpusAnswer é a resposta de base vazia, se a collection não existe
+// ou não tem trechos. Só é consultada quando a busca falha ou não acha nada;
+// com o Qdrant fora do ar, ok é false e a pergunta segue o caminho normal.
+func (e *AlanaEngine) emptyCorpusAnswer(ctx context.Context) (Answer, bool) {
+	info, err := e.corpusSize(ctx)
+	if err != nil || !info.Empty {
+		return Answer{}, false
+	}
+	engineLog.WarnContext(ctx, "Base de conhecimento vazia", "collection", e.collection, "exists", info.Exists)
+	return Answer{Text: emptyCorpusReply, EmptyCorpus: true}, true
+}
+
+// noResultsAnswer responde quando a busca não achou nenhum trecho: base
+// vazia, ou abstenção (filtros ou corte excluíram tudo), nunca uma geração
+// com o contexto vazio
+func (e *AlanaEngine) noResultsAnswer(ctx context.Context) Answer {
+	if answer, ok := e.emptyCorpusAnswer(ctx); ok {
+		return answer
+	}
+	return Answer{Text: abstainReply, Abstained: true}
+}
+
+// corpusGauge guarda o tamanho do corpus para o /readyz, que os
+// balanceadores consultam a cada poucos segundos
+type corpusGauge struct {
+	mu   sync.Mutex
+	at   time.Time
+	info corpusInfo
+	ok   bool
+}
+
+// get devolve o tamanho do corpus, contado de novo a cada corpusGaugeTTL.
+// ok é false se o Qdrant não respondeu.
+func (g *corpusGauge) get(ctx context.Context, e *AlanaEngine) (corpusInfo, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if time.Since(g.at) < corpusGaugeTTL {
+		return g.info, g.ok
+	}
+	info, err := e.corpusSize(ctx)
+	g.at, g.info, g.ok = time.Now(), info, err == nil
+	return g.info, g.ok
+}
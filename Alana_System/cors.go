@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==============================
+// CORS e cabeçalhos de segurança
+// ==============================
+
+// corsPolicy libera a API para frontends em outra origem sem precisar de um
+// proxy reverso na frente. É lida do ambiente:
+//
+//	ALANA_CORS_ORIGINS       origens permitidas, separadas por vírgula ("*" = qualquer uma; vazio = CORS desligado)
+//	ALANA_CORS_METHODS       métodos permitidos (padrão: GET, POST, OPTIONS)
+//	ALANA_CORS_HEADERS       cabeçalhos permitidos (padrão: Authorization, Content-Type, X-API-Key, X-Request-ID)
+//	ALANA_CORS_CREDENTIALS   "true" libera cookies/Authorization do navegador (recusado com "*")
+//	ALANA_CORS_MAX_AGE       cache do preflight (padrão: 10m)
+type corsPolicy struct {
+	origins     map[string]bool
+	anyOrigin   bool
+	methods     string
+	headers     string
+	credentials bool
+	maxAge      string
+}
+
+// errCORSAnyOriginCredentials recusa "*" com credenciais: qualquer site
+// poderia fazer pedidos com as credenciais do navegador (Basic do /admin,
+// chaves de API)
+var errCORSAnyOriginCredentials = errors.New(`ALANA_CORS_ORIGINS="*" não vale com ALANA_CORS_CREDENTIALS=true: liste as origens permitidas`)
+
+func corsPolicyFromEnv() (*corsPolicy, error) {
+	p := &corsPolicy{
+		origins: map[string]bool{},
+		methods: "GET, POST, OPTIONS",
+		headers: "Authorization, Content-Type, X-API-Key, X-Request-ID",
+		maxAge:  "600",
+	}
+	for _, origin := range splitList(os.Getenv("ALANA_CORS_ORIGINS")) {
+		if origin == "*" {
+			p.anyOrigin = true
+			continue
+		}
+		p.origins[strings.TrimSuffix(origin, "/")] = true
+	}
+	if methods := splitList(os.Getenv("ALANA_CORS_METHODS")); len(methods) > 0 {
+		p.methods = strings.ToUpper(strings.Join(methods, ", "))
+	}
+	if headers := splitList(os.Getenv("ALANA_CORS_HEADERS")); len(headers) > 0 {
+		p.headers = strings.Join(headers, ", ")
+	}
+	p.credentials, _ = strconv.ParseBool(os.Getenv("ALANA_CORS_CREDENTIALS"))
+	if d, err := time.ParseDuration(os.Getenv("ALANA_CORS_MAX_AGE")); err == nil && d >= 0 {
+		p.maxAge = strconv.Itoa(int(d.Seconds()))
+	}
+	if p.anyOrigin && p.credentials {
+		return nil, errCORSAnyOriginCredentials
+	}
+	return p, nil
+}
+
+func (p *corsPolicy) enabled() bool { return p.anyOrigin || len(p.origins) > 0 }
+
+// allowOrigin devolve o valor de Access-Control-Allow-Origin para a origem.
+// Só as origens listadas são ecoadas; "*" nunca vem com credenciais (ver
+// corsPolicyFromEnv).
+func (p *corsPolicy) allowOrigin(origin string) (string, bool) {
+	switch {
+	case origin == "":
+		return "", false
+	case p.origins[origin]:
+		return origin, true
+	case p.anyOrigin && !p.credentials:
+		return "*", true
+	}
+	return "", false
+}
+
+// wrap responde aos preflights e acrescenta os cabeçalhos CORS às respostas
+// de origens permitidas. Origens não permitidas recebem a resposta sem
+// cabeçalhos CORS (o navegador bloqueia a leitura).
+func (p *corsPolicy) wrap(next http.Handler) http.Handler {
+	if !p.enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		allowed, ok := p.allowOrigin(origin)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if !ok {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.Set("Access-Control-Allow-Origin", allowed)
+		if p.credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			h.Set("Access-Control-Expose-Headers", "Retry-After, X-Request-ID")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+		if !slices.Contains(splitList(p.methods), method) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		h.Set("Access-Control-Allow-Methods", p.methods)
+		h.Set("Access-Control-Allow-Headers", p.headers)
+		h.Set("Access-Control-Max-Age", p.maxAge)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// securityHeaders acrescenta os cabeçalhos padrão de uma API JSON: nada de
+// sniffing de tipo, de frames nem de referrer. ALANA_HSTS_MAX_AGE (ex: 8760h)
+// liga o Strict-Transport-Security quando a API é servida por HTTPS.
+func securityHeaders(next http.Handler) http.Handler {
+	var hsts string
+	if d, err := time.ParseDuration(os.Getenv("ALANA_HSTS_MAX_AGE")); err == nil && d > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(d.Seconds())) + "; includeSubDomains"
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+		h.Set("Cache-Control", "no-store")
+		if hsts != "" {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
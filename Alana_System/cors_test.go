@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// corsServer monta a política do ambiente em volta de um handler que marca
+// se o pedido chegou nele
+func corsServer(t *testing.T, env map[string]string) (http.Handler, *bool) {
+	t.Helper()
+	for _, k := range []string{"ALANA_CORS_ORIGINS", "ALANA_CORS_METHODS", "ALANA_CORS_HEADERS", "ALANA_CORS_CREDENTIALS", "ALANA_CORS_MAX_AGE"} {
+		t.Setenv(k, env[k])
+	}
+	policy, err := corsPolicyFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reached := new(bool)
+	return policy.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*reached = true
+		w.WriteHeader(http.StatusOK)
+	})), reached
+}
+
+func TestCORS(t *testing.T) {
+	listed := map[string]string{
+		"ALANA_CORS_ORIGINS":     "https://app.example.com, https://admin.example.com/",
+		"ALANA_CORS_CREDENTIALS": "true",
+		"ALANA_CORS_MAX_AGE":     "1h",
+	}
+	anyOrigin := map[string]string{"ALANA_CORS_ORIGINS": "*"}
+
+	cases := []struct {
+		name        string
+		env         map[string]string
+		method      string
+		origin      string
+		preflight   string
+		status      int
+		reached     bool
+		allowOrigin string
+		credentials string
+	}{
+		{"origem listada", listed, http.MethodPost, "https://app.example.com", "", http.StatusOK, true, "https://app.example.com", "true"},
+		{"origem listada com barra na config", listed, http.MethodGet, "https://admin.example.com", "", http.StatusOK, true, "https://admin.example.com", "true"},
+		{"origem não listada", listed, http.MethodPost, "https://evil.example.com", "", http.StatusOK, true, "", ""},
+		{"sem Origin", listed, http.MethodGet, "", "", http.StatusOK, true, "", ""},
+		{"preflight listado", listed, http.MethodOptions, "https://app.example.com", "POST", http.StatusNoContent, false, "https://app.example.com", "true"},
+		{"preflight não listado", listed, http.MethodOptions, "https://evil.example.com", "POST", http.StatusForbidden, false, "", ""},
+		{"preflight com método não permitido", listed, http.MethodOptions, "https://app.example.com", "DELETE", http.StatusMethodNotAllowed, false, "https://app.example.com", "true"},
+		{"preflight com método em minúsculas", listed, http.MethodOptions, "https://app.example.com", "post", http.StatusNoContent, false, "https://app.example.com", "true"},
+		{"OPTIONS sem preflight", listed, http.MethodOptions, "https://app.example.com", "", http.StatusOK, true, "https://app.example.com", "true"},
+		{"* sem credenciais", anyOrigin, http.MethodPost, "https://qualquer.example.com", "", http.StatusOK, true, "*", ""},
+		{"* no preflight", anyOrigin, http.MethodOptions, "https://qualquer.example.com", "GET", http.StatusNoContent, false, "*", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler, reached := corsServer(t, tc.env)
+			req := httptest.NewRequest(tc.method, "/v1/ask", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.preflight != "" {
+				req.Header.Set("Access-Control-Request-Method", tc.preflight)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			h := rec.Header()
+			if rec.Code != tc.status || *reached != tc.reached {
+				t.Errorf("status %d, chegou ao handler %v; esperado %d, %v", rec.Code, *reached, tc.status, tc.reached)
+			}
+			if got := h.Get("Access-Control-Allow-Origin"); got != tc.allowOrigin {
+				t.Errorf("Allow-Origin %q, esperado %q", got, tc.allowOrigin)
+			}
+			if got := h.Get("Access-Control-Allow-Credentials"); got != tc.credentials {
+				t.Errorf("Allow-Credentials %q, esperado %q", got, tc.credentials)
+			}
+			if !slices.Contains(h.Values("Vary"), "Origin") {
+				t.Errorf("sem Vary: Origin: %v", h.Values("Vary"))
+			}
+			if tc.status == http.StatusNoContent {
+				if h.Get("Access-Control-Allow-Methods") == "" || h.Get("Access-Control-Allow-Headers") == "" {
+					t.Errorf("preflight sem métodos ou cabeçalhos: %v", h)
+				}
+			} else if h.Get("Access-Control-Allow-Methods") != "" {
+				t.Errorf("Allow-Methods fora de um preflight aceito: %v", h)
+			}
+		})
+	}
+}
+
+func TestCORSPreflightConfig(t *testing.T) {
+	handler, _ := corsServer(t, map[string]string{
+		"ALANA_CORS_ORIGINS": "https://app.example.com",
+		"ALANA_CORS_METHODS": "get, put",
+		"ALANA_CORS_HEADERS": "Content-Type, X-Tenant",
+		"ALANA_CORS_MAX_AGE": "90s",
+	})
+	req := httptest.NewRequest(http.MethodOptions, "/v1/ask", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	h := rec.Header()
+	if rec.Code != http.StatusNoContent || h.Get("Access-Control-Allow-Methods") != "GET, PUT" ||
+		h.Get("Access-Control-Allow-Headers") != "Content-Type, X-Tenant" || h.Get("Access-Control-Max-Age") != "90" {
+		t.Errorf("preflight %d: %v", rec.Code, h)
+	}
+}
+
+// "*" com credenciais é recusado na partida
+func TestCORSAnyOriginWithCredentials(t *testing.T) {
+	t.Setenv("ALANA_CORS_ORIGINS", "https://app.example.com,*")
+	t.Setenv("ALANA_CORS_CREDENTIALS", "true")
+	if _, err := corsPolicyFromEnv(); !errors.Is(err, errCORSAnyOriginCredentials) {
+		t.Errorf("esperado errCORSAnyOriginCredentials, veio %v", err)
+	}
+}
+
+// Sem ALANA_CORS_ORIGINS, wrap não mexe no handler
+func TestCORSDisabled(t *testing.T) {
+	handler, reached := corsServer(t, nil)
+	req := httptest.NewRequest(http.MethodOptions, "/v1/ask", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if !*reached || len(rec.Header().Values("Vary")) != 0 || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("CORS desligado mexeu no pedido: chegou %v, %v", *reached, rec.Header())
+	}
+}
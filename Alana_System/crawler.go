@@ -0,0 +1,576 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"alana_system/schema"
+)
+
+// ==============================
+// Conector web (sitemap e RSS/Atom)
+// ==============================
+
+const (
+	// crawlerUserAgent identifica o crawler nos logs dos sites e no robots.txt
+	crawlerUserAgent       = "AlanaCrawler/1.0 (+https://github.com/alciviny/Alana_LLM)"
+	defaultCrawlDelay      = time.Second
+	defaultCrawlMaxPages   = 500
+	maxSitemapDepth        = 3
+	maxCrawlPageBytes      = 10 << 20
+	maxRobotsCrawlDelay    = time.Minute
+	crawlerRobotsAgentName = "alanacrawler"
+)
+
+// webConnector mantém um site de documentação indexado a partir de um
+// sitemap (ou índice de sitemaps) ou de um feed RSS/Atom: cada URL listada é
+// baixada e convertida em texto. Ajustes pelo ambiente:
+//
+//	ALANA_CRAWL_ALLOW      prefixos de URL permitidos, separados por vírgula
+//	                       (padrão: o esquema e o host da fonte)
+//	ALANA_CRAWL_DELAY      pausa entre duas requisições (padrão 1s); um
+//	                       Crawl-delay maior no robots.txt vence
+//	ALANA_CRAWL_MAX_PAGES  páginas baixadas por rodada (padrão 500)
+//
+// O robots.txt de cada host é respeitado. O lastmod do sitemap (ou a data do
+// item do feed) é o UpdatedAt: páginas sem data são baixadas a cada rodada e
+// só reingeridas se o texto mudou. O limite de páginas pega as mais antigas
+// primeiro, então a rodada seguinte continua de onde o cursor parou.
+type webConnector struct {
+	source   string
+	allow    []string
+	delay    time.Duration
+	maxPages int
+
+	robots map[string]*robotsRules
+	last   time.Time
+}
+
+func newWebConnector(source string) (*webConnector, error) {
+	u, err := url.Parse(source)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("fonte web inválida %q (use web:https://site/sitemap.xml ou o endereço de um feed)", source)
+	}
+	c := &webConnector{
+		source:   source,
+		allow:    splitList(os.Getenv("ALANA_CRAWL_ALLOW")),
+		delay:    defaultCrawlDelay,
+		maxPages: defaultCrawlMaxPages,
+		robots:   map[string]*robotsRules{},
+	}
+	if len(c.allow) == 0 {
+		c.allow = []string{u.Scheme + "://" + u.Host + "/"}
+	}
+	if v := os.Getenv("ALANA_CRAWL_DELAY"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("ALANA_CRAWL_DELAY: duração inválida %q", v)
+		}
+		c.delay = d
+	}
+	if v := os.Getenv("ALANA_CRAWL_MAX_PAGES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("ALANA_CRAWL_MAX_PAGES: inteiro positivo inválido %q", v)
+		}
+		c.maxPages = n
+	}
+	return c, nil
+}
+
+func (c *webConnector) Name() string { return "web:" + c.source }
+
+// crawlEntry é uma URL listada pelo sitemap ou pelo feed
+type crawlEntry struct {
+	URL       string
+	Title     string
+	UpdatedAt time.Time
+}
+
+func (c *webConnector) Fetch(ctx context.Context, since time.Time, yield func(connectorItem) error) error {
+	entries, err := c.listEntries(ctx, c.source, since, 0)
+	if err != nil {
+		return err
+	}
+
+	// Uma URL pode aparecer mais de uma vez (feeds, sitemaps sobrepostos):
+	// vale a data mais recente
+	byURL := map[string]crawlEntry{}
+	for _, e := range entries {
+		if !c.allowed(e.URL) {
+			continue
+		}
+		if prev, ok := byURL[e.URL]; ok && !e.UpdatedAt.After(prev.UpdatedAt) {
+			continue
+		}
+		byURL[e.URL] = e
+	}
+	entries = entries[:0]
+	for _, e := range byURL {
+		if e.UpdatedAt.IsZero() || !e.UpdatedAt.Before(since) {
+			entries = append(entries, e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i].UpdatedAt, entries[j].UpdatedAt
+		if a.IsZero() || b.IsZero() {
+			if a.IsZero() == b.IsZero() {
+				return entries[i].URL < entries[j].URL
+			}
+			return b.IsZero()
+		}
+		if a.Equal(b) {
+			return entries[i].URL < entries[j].URL
+		}
+		return a.Before(b)
+	})
+	if len(entries) > c.maxPages {
+		connectorLog.Warn("Fila do crawler maior que o limite da rodada", "crawler", c.Name(), "queued", len(entries), "max_pages", c.maxPages)
+		entries = entries[:c.maxPages]
+	}
+
+	for _, e := range entries {
+		robots, err := c.robotsFor(ctx, e.URL)
+		if err != nil {
+			return err
+		}
+		if !robots.allows(e.URL) {
+			continue
+		}
+		title, text, err := c.fetchPage(ctx, e.URL)
+		if errors.Is(err, errPageSkipped) {
+			connectorLog.Info("Página ignorada", "url", e.URL, "reason", err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.URL, err)
+		}
+		if title == "" {
+			title = e.Title
+		}
+		if err := yield(connectorItem{
+			DocID:       "web:" + e.URL,
+			Title:       title,
+			Text:        text,
+			URL:         e.URL,
+			ContentType: schema.ContentWebPage,
+			UpdatedAt:   e.UpdatedAt,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allowed diz se a URL está sob um dos prefixos de ALANA_CRAWL_ALLOW
+func (c *webConnector) allowed(raw string) bool {
+	for _, prefix := range c.allow {
+		if strings.HasPrefix(raw, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ------------------------------
+// Sitemap e feeds
+// ------------------------------
+
+// feedDocument cobre os quatro formatos aceitos; só os campos do elemento
+// raiz lido vêm preenchidos
+type feedDocument struct {
+	XMLName xml.Name
+	// <urlset> e <sitemapindex>
+	URLs     []sitemapURL `xml:"url"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+	// <rss><channel><item>
+	Items []struct {
+		Title   string `xml:"title"`
+		Link    string `xml:"link"`
+		PubDate string `xml:"pubDate"`
+		Updated string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	} `xml:"channel>item"`
+	// <feed><entry> (Atom)
+	Entries []struct {
+		Title string `xml:"title"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Updated   string `xml:"updated"`
+		Published string `xml:"published"`
+	} `xml:"entry"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// listEntries lê a fonte e devolve as URLs listadas. Os sitemaps de um
+// índice são seguidos até maxSitemapDepth, pulando os que não mudaram desde
+// since.
+func (c *webConnector) listEntries(ctx context.Context, source string, since time.Time, depth int) ([]crawlEntry, error) {
+	body, _, err := c.get(ctx, source)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	var doc feedDocument
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("%s: não é um sitemap nem um feed: %w", source, err)
+	}
+
+	var entries []crawlEntry
+	switch doc.XMLName.Local {
+	case "urlset":
+		for _, u := range doc.URLs {
+			entries = append(entries, crawlEntry{URL: strings.TrimSpace(u.Loc), UpdatedAt: parseFeedTime(u.LastMod)})
+		}
+	case "sitemapindex":
+		if depth >= maxSitemapDepth {
+			return nil, fmt.Errorf("%s: índice de sitemaps aninhado demais", source)
+		}
+		for _, s := range doc.Sitemaps {
+			if mod := parseFeedTime(s.LastMod); !mod.IsZero() && mod.Before(since) {
+				continue
+			}
+			nested, err := c.listEntries(ctx, strings.TrimSpace(s.Loc), since, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, nested...)
+		}
+	case "rss":
+		for _, it := range doc.Items {
+			entries = append(entries, crawlEntry{
+				URL:       strings.TrimSpace(it.Link),
+				Title:     strings.TrimSpace(it.Title),
+				UpdatedAt: parseFeedTime(cmp.Or(it.Updated, it.PubDate)),
+			})
+		}
+	case "feed":
+		for _, e := range doc.Entries {
+			link := ""
+			for _, l := range e.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					link = l.Href
+					break
+				}
+			}
+			entries = append(entries, crawlEntry{
+				URL:       strings.TrimSpace(link),
+				Title:     strings.TrimSpace(e.Title),
+				UpdatedAt: parseFeedTime(cmp.Or(e.Updated, e.Published)),
+			})
+		}
+	default:
+		return nil, fmt.Errorf("%s: formato <%s> desconhecido (use sitemap, RSS ou Atom)", source, doc.XMLName.Local)
+	}
+
+	out := entries[:0]
+	for _, e := range entries {
+		if e.URL != "" {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// feedTimeLayouts são os formatos de data de sitemaps (W3C), RSS (RFC 822) e Atom
+var feedTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04-07:00",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+}
+
+// parseFeedTime lê a data; vazia ou num formato desconhecido = zero
+func parseFeedTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range feedTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// ------------------------------
+// Páginas
+// ------------------------------
+
+// errPageSkipped marca páginas puladas sem interromper a sincronização
+// (removidas, ou que não são HTML)
+var errPageSkipped = errors.New("página ignorada")
+
+// fetchPage baixa a página e devolve o título e o texto
+func (c *webConnector) fetchPage(ctx context.Context, raw string) (title, text string, err error) {
+	body, header, err := c.get(ctx, raw)
+	var status *httpStatusError
+	if errors.As(err, &status) && (status.Status == http.StatusNotFound || status.Status == http.StatusGone) {
+		return "", "", fmt.Errorf("%w: %d %s", errPageSkipped, status.Status, http.StatusText(status.Status))
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type")); mediaType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return "", "", fmt.Errorf("%w: conteúdo %s", errPageSkipped, mediaType)
+	}
+	title, text = pageToText(string(body))
+	return title, text, nil
+}
+
+var (
+	pageTitle = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title\s*>`)
+	// pageMain é o conteúdo principal, quando a página o marca
+	pageMain = regexp.MustCompile(`(?is)<(main|article)\b[^>]*>(.*)</(main|article)\s*>`)
+	// pageChrome são menus, cabeçalhos e rodapés, repetidos em todas as páginas
+	pageChrome = regexp.MustCompile(`(?is)<(nav|header|footer|aside|noscript|svg|form)\b.*?</(nav|header|footer|aside|noscript|svg|form)\s*>`)
+	pageHead   = regexp.MustCompile(`(?is)<head\b.*?</head\s*>`)
+)
+
+// pageToText converte a página pelo htmlToText, ficando com o <main> (ou
+// <article>) quando existe e tirando a navegação do site
+func pageToText(page string) (title, text string) {
+	if m := pageTitle.FindStringSubmatch(page); m != nil {
+		title = strings.Join(strings.Fields(html.UnescapeString(m[1])), " ")
+	}
+	page = pageHead.ReplaceAllString(page, "")
+	if m := pageMain.FindStringSubmatch(page); m != nil {
+		page = m[2]
+	}
+	page = pageChrome.ReplaceAllString(page, "")
+	return title, htmlToText(page)
+}
+
+// get faz um GET respeitando a pausa entre requisições, com os reenvios do
+// providerHTTP e de withRetry. Sitemaps .gz são descompactados.
+func (c *webConnector) get(ctx context.Context, raw string) ([]byte, http.Header, error) {
+	type page struct {
+		body   []byte
+		header http.Header
+	}
+	p, err := withRetry(ctx, retries, "crawler", func(ctx context.Context) (page, error) {
+		if err := c.wait(ctx); err != nil {
+			return page{}, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, raw, nil)
+		if err != nil {
+			return page{}, err
+		}
+		req.Header.Set("User-Agent", crawlerUserAgent)
+
+		resp, err := providerHTTP.Do(req)
+		if err != nil {
+			return page{}, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return page{}, readStatusError("crawler error", resp)
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxCrawlPageBytes))
+		if err != nil {
+			return page{}, err
+		}
+		return page{body, resp.Header}, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if bytes.HasPrefix(p.body, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(p.body))
+		if err != nil {
+			return nil, nil, err
+		}
+		defer zr.Close()
+		if p.body, err = io.ReadAll(io.LimitReader(zr, maxCrawlPageBytes)); err != nil {
+			return nil, nil, err
+		}
+	}
+	return p.body, p.header, nil
+}
+
+// wait espera a pausa de cortesia desde a requisição anterior
+func (c *webConnector) wait(ctx context.Context) error {
+	if !c.last.IsZero() {
+		timer := time.NewTimer(time.Until(c.last.Add(c.delay)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	c.last = time.Now()
+	return nil
+}
+
+// ------------------------------
+// robots.txt
+// ------------------------------
+
+// robotsRules são as regras do robots.txt de um host para o crawler
+type robotsRules struct {
+	rules []robotsRule
+}
+
+type robotsRule struct {
+	pattern *regexp.Regexp
+	length  int
+	allow   bool
+}
+
+// robotsFor lê (uma vez por host) o robots.txt do host da URL. Sem
+// robots.txt, tudo é permitido; um Crawl-delay maior aumenta a pausa.
+func (c *webConnector) robotsFor(ctx context.Context, raw string) (*robotsRules, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	origin := u.Scheme + "://" + u.Host
+	if r, ok := c.robots[origin]; ok {
+		return r, nil
+	}
+
+	body, _, err := c.get(ctx, origin+"/robots.txt")
+	var status *httpStatusError
+	if errors.As(err, &status) && status.Status >= 400 && status.Status < 500 {
+		body, err = nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s/robots.txt: %w", origin, err)
+	}
+	rules, delay := parseRobots(body)
+	if delay > c.delay {
+		c.delay = min(delay, maxRobotsCrawlDelay)
+	}
+	c.robots[origin] = rules
+	return rules, nil
+}
+
+// parseRobots lê os grupos do robots.txt que valem para o crawler: o do
+// próprio nome, ou o de "*" se não houver um específico
+func parseRobots(body []byte) (*robotsRules, time.Duration) {
+	type group struct {
+		rules []robotsRule
+		delay time.Duration
+	}
+	var own, all *group
+	var current []*group
+	inAgents := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = nil
+			}
+			inAgents = true
+			agent := strings.ToLower(value)
+			switch {
+			case agent == "*":
+				if all == nil {
+					all = &group{}
+				}
+				current = append(current, all)
+			case strings.Contains(crawlerRobotsAgentName, agent) || strings.Contains(agent, crawlerRobotsAgentName):
+				if own == nil {
+					own = &group{}
+				}
+				current = append(current, own)
+			}
+		case "allow", "disallow":
+			inAgents = false
+			if value == "" {
+				continue
+			}
+			rule := robotsRule{pattern: robotsPattern(value), length: len(value), allow: key == "allow"}
+			for _, g := range current {
+				g.rules = append(g.rules, rule)
+			}
+		case "crawl-delay":
+			inAgents = false
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+				for _, g := range current {
+					g.delay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		default:
+			inAgents = false
+		}
+	}
+
+	g := own
+	if g == nil {
+		g = all
+	}
+	if g == nil {
+		return &robotsRules{}, 0
+	}
+	return &robotsRules{rules: g.rules}, g.delay
+}
+
+// robotsPattern converte o caminho do robots.txt (com * e $) em regexp
+func robotsPattern(path string) *regexp.Regexp {
+	anchored := strings.HasSuffix(path, "$")
+	path = strings.TrimSuffix(path, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(path), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allows aplica a regra mais específica (a mais longa) que casa com o
+// caminho; no empate, Allow vence
+func (r *robotsRules) allows(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	best, allowed := -1, true
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > best || (rule.length == best && rule.allow) {
+			best, allowed = rule.length, rule.allow
+		}
+	}
+	return allowed
+}
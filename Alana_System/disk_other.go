@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// freeDiskBytes não é implementado fora de sistemas Unix
+func freeDiskBytes(string) (uint64, error) {
+	return 0, errDiskUnsupported
+}
@@ -0,0 +1,496 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"alana_system/schema"
+	"alana_system/sidecarproto"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Diagnóstico do ambiente
+// ==============================
+
+const (
+	// Faixa de versões do servidor Qdrant suportada pelo go-client v1.16
+	minQdrantMinor = 15
+	maxQdrantMinor = 17
+
+	// Espaço livre mínimo em ./data (manifestos, grafo, logs, checkpoints)
+	minFreeDiskBytes = 1 << 30
+	dataDir          = "./data"
+
+	// Resposta de teste do gerador: curta, para custar pouco
+	generationProbeQuestion = "Responda apenas: ok"
+	generationProbeTokens   = 5
+	// generationProbeTimeout cobre o primeiro pedido a um LLM local, que
+	// pode carregar o modelo
+	generationProbeTimeout = time.Minute
+
+	// Python do processor.py: a versão da imagem (Dockerfile.processor)
+	minPythonMajor     = 3
+	minPythonMinor     = 11
+	pythonProbeTimeout = 15 * time.Second
+)
+
+// pythonProbeScript imprime a versão do Python e os módulos que faltam para
+// o processor.py (PDF, áudio, embeddings, Qdrant), sem importá-los (o torch
+// leva segundos)
+var pythonProbeScript = fmt.Sprintf(`import importlib.util, json, sys
+mods = ["numpy", "torch", "sentence_transformers", "qdrant_client", "pdfplumber", "whisper"]
+print(json.dumps({
+    "version": sys.version.split()[0],
+    "ok": sys.version_info >= (%d, %d),
+    "missing": [m for m in mods if importlib.util.find_spec(m) is None],
+}))`, minPythonMajor, minPythonMinor)
+
+var errDiskUnsupported = errors.New("disk usage not supported on this platform")
+
+type checkStatus int
+
+const (
+	checkOK checkStatus = iota
+	checkWarn
+	checkFail
+)
+
+func (s checkStatus) icon() string {
+	switch s {
+	case checkOK:
+		return "✅"
+	case checkWarn:
+		return "⚠️ "
+	}
+	return "❌"
+}
+
+func (s checkStatus) String() string {
+	switch s {
+	case checkOK:
+		return "ok"
+	case checkWarn:
+		return "warn"
+	}
+	return "fail"
+}
+
+// checkResult é o resultado de uma verificação, com a correção sugerida
+type checkResult struct {
+	Status checkStatus
+	Detail string
+	Fix    string
+}
+
+type doctorCheck struct {
+	Name string
+	Run  func(ctx context.Context, e *AlanaEngine) checkResult
+}
+
+// sidecarHealth é a resposta de GET /health do sidecar
+type sidecarHealth struct {
+	Status         string `json:"status"`
+	EmbeddingModel string `json:"embedding_model"`
+	EmbeddingDim   int    `json:"embedding_dim"`
+	LLMModel       string `json:"llm_model"`
+	// Protocol é nil nos sidecars anteriores ao cabeçalho de versão (v1)
+	Protocol *sidecarproto.Health `json:"protocol"`
+}
+
+// runDoctor implementa `alana doctor`: verifica as dependências do ambiente e
+// imprime como corrigir cada problema encontrado.
+func runDoctor(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	serveAddr := fs.String("addr", "127.0.0.1:8080", "endereço que o `alana serve` vai usar")
+	generate := fs.Bool("generate", false, "gera uma resposta de teste também nos provedores pagos (openai, anthropic), gastando alguns tokens")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	checks := []doctorCheck{
+		{"Binário", checkBinary},
+		{"Qdrant", checkQdrantVersion},
+		{"Collection", checkCollection},
+		{"Schema de payload", checkPayloadSchema},
+		{"Sidecar", checkSidecar},
+		{"Embedder", checkEmbedder},
+		{"Gerador", checkGenerator(*generate)},
+		{"Python (ingestor)", checkPython},
+		{"Disco", checkDisk},
+		{"Porta do serve", checkPort(*serveAddr)},
+	}
+
+	fmt.Println("Alana doctor")
+	fmt.Println()
+
+	failures := 0
+	for _, c := range checks {
+		r := c.Run(ctx, engine)
+		fmt.Printf("%s %-18s %s\n", r.Status.icon(), c.Name, r.Detail)
+		if r.Fix != "" {
+			fmt.Printf("   → %s\n", r.Fix)
+		}
+		if r.Status == checkFail {
+			failures++
+		}
+	}
+
+	fmt.Println()
+	if failures > 0 {
+		return fmt.Errorf("%d verificações falharam", failures)
+	}
+	fmt.Println("Tudo pronto.")
+	return nil
+}
+
+func checkBinary(context.Context, *AlanaEngine) checkResult {
+	return checkResult{
+		Status: checkOK,
+		Detail: fmt.Sprintf("%s %s/%s, schema de payload v%d", runtime.Version(), runtime.GOOS, runtime.GOARCH, schema.Version),
+	}
+}
+
+func checkQdrantVersion(ctx context.Context, e *AlanaEngine) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	reply, err := e.client.HealthCheck(ctx)
+	if err != nil {
+		return checkResult{
+			Status: checkFail,
+			Detail: fmt.Sprintf("sem resposta em %s (%v)", e.qdrantAddr, err),
+			Fix:    "suba o Qdrant (docker run -p 6333:6333 -p 6334:6334 qdrant/qdrant) ou ajuste ALANA_QDRANT_ADDR",
+		}
+	}
+
+	v, err := qdrant.ParseVersion(reply.GetVersion())
+	if err != nil {
+		return checkResult{Status: checkWarn, Detail: fmt.Sprintf("versão desconhecida %q", reply.GetVersion())}
+	}
+	if v.Major != 1 || v.Minor < minQdrantMinor || v.Minor > maxQdrantMinor {
+		return checkResult{
+			Status: checkWarn,
+			Detail: fmt.Sprintf("versão %s fora da faixa testada (1.%d–1.%d)", reply.GetVersion(), minQdrantMinor, maxQdrantMinor),
+			Fix:    fmt.Sprintf("use uma imagem qdrant/qdrant:v1.%d.x", maxQdrantMinor-1),
+		}
+	}
+	return checkResult{Status: checkOK, Detail: "versão " + reply.GetVersion()}
+}
+
+func checkCollection(ctx context.Context, e *AlanaEngine) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	info, err := e.client.GetCollectionInfo(ctx, e.collection)
+	if err != nil {
+		return checkResult{
+			Status: checkFail,
+			Detail: fmt.Sprintf("collection %s indisponível (%v)", e.collection, err),
+			Fix:    "rode a ingestão (go run ./orchestrator) ou `alana collections create` para criar a collection",
+		}
+	}
+	return checkResult{
+		Status: checkOK,
+		Detail: fmt.Sprintf("%s com %d pontos, dimensão %d", e.collection, info.GetPointsCount(), collectionDim(info)),
+	}
+}
+
+func checkPayloadSchema(ctx context.Context, e *AlanaEngine) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	outdated, err := e.client.Count(ctx, &qdrant.CountPoints{
+		CollectionName: e.collection,
+		Filter: &qdrant.Filter{
+			MustNot: []*qdrant.Condition{qdrant.NewMatchInt("schema_version", schema.Version)},
+		},
+		Exact: qdrant.PtrOf(true),
+	})
+	if err != nil {
+		return checkResult{Status: checkFail, Detail: fmt.Sprintf("contagem falhou (%v)", err)}
+	}
+	if outdated == 0 {
+		return checkResult{Status: checkOK, Detail: fmt.Sprintf("todos os pontos no schema v%d", schema.Version)}
+	}
+
+	newer, err := e.client.Count(ctx, &qdrant.CountPoints{
+		CollectionName: e.collection,
+		Filter: &qdrant.Filter{
+			Must: []*qdrant.Condition{qdrant.NewRange("schema_version", &qdrant.Range{Gt: qdrant.PtrOf(float64(schema.Version))})},
+		},
+		Exact: qdrant.PtrOf(true),
+	})
+	if err == nil && newer > 0 {
+		return checkResult{
+			Status: checkFail,
+			Detail: fmt.Sprintf("%d pontos gravados por um schema mais novo que v%d", newer, schema.Version),
+			Fix:    "atualize este binário para a versão usada na ingestão",
+		}
+	}
+
+	return checkResult{
+		Status: checkWarn,
+		Detail: fmt.Sprintf("%d pontos fora do schema v%d", outdated, schema.Version),
+		Fix:    "rode `alana migrate-payload`",
+	}
+}
+
+func checkSidecar(ctx context.Context, e *AlanaEngine) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	health, err := getSidecarHealth(ctx)
+	if err != nil {
+		return checkResult{
+			Status: checkFail,
+			Detail: fmt.Sprintf("sem resposta em %s (%v)", sidecarURL, err),
+			Fix:    "suba o sidecar (python bridge.py) ou ajuste ALANA_SIDECAR_URL",
+		}
+	}
+
+	detail := fmt.Sprintf("embedding %s (dim %d), LLM %s", health.EmbeddingModel, health.EmbeddingDim, health.LLMModel)
+	protocol := sidecarproto.Health{Version: sidecarproto.MinVersion, MinVersion: sidecarproto.MinVersion}
+	if health.Protocol != nil {
+		protocol = *health.Protocol
+	}
+	if protocol.MinVersion > sidecarproto.Version {
+		return checkResult{
+			Status: checkFail,
+			Detail: detail + fmt.Sprintf(", contrato v%d+ (o alana entende até a v%d)", protocol.MinVersion, sidecarproto.Version),
+			Fix:    "atualize o alana ou use um sidecar compatível",
+		}
+	}
+	detail += fmt.Sprintf(", contrato v%d", min(protocol.Version, sidecarproto.Version))
+
+	info, err := e.client.GetCollectionInfo(ctx, e.collection)
+	if err == nil && health.EmbeddingDim > 0 {
+		if dim := collectionDim(info); dim != 0 && dim != uint64(health.EmbeddingDim) {
+			return checkResult{
+				Status: checkFail,
+				Detail: detail + fmt.Sprintf(" ≠ dimensão %d da collection", dim),
+				Fix:    "use o mesmo modelo de embedding da ingestão ou reindexe a collection",
+			}
+		}
+	}
+	return checkResult{Status: checkOK, Detail: detail}
+}
+
+// checkEmbedder gera o embedding de uma frase de teste no provedor da
+// collection (o /embed do sidecar, OpenAI ou Ollama) e confere a dimensão
+func checkEmbedder(ctx context.Context, e *AlanaEngine) checkResult {
+	emb := e.collections.embedding(e.collection)
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	vector, err := emb.embedder().Embed(ctx, "alana doctor", "")
+	if err != nil {
+		fix := "confira ALANA_OPENAI_URL/ALANA_OPENAI_API_KEY ou ALANA_OLLAMA_URL (e se o modelo foi baixado: ollama pull)"
+		if emb.Provider == "sidecar" {
+			fix = "confira o log do sidecar: o modelo de embedding pode não ter carregado"
+		}
+		return checkResult{
+			Status: checkFail,
+			Detail: fmt.Sprintf("%s sem resposta (%v)", emb.Provider, err),
+			Fix:    fix,
+		}
+	}
+	detail := fmt.Sprintf("%s, dim %d", emb.Provider, len(vector))
+	info, err := e.client.GetCollectionInfo(ctx, e.collection)
+	if err == nil {
+		if dim := collectionDim(info); dim != 0 && dim != uint64(len(vector)) {
+			return checkResult{
+				Status: checkFail,
+				Detail: detail + fmt.Sprintf(" ≠ dimensão %d da collection", dim),
+				Fix:    "use o mesmo modelo de embedding da ingestão ou reindexe a collection",
+			}
+		}
+	}
+	return checkResult{Status: checkOK, Detail: detail}
+}
+
+// checkGenerator confere o provedor de geração padrão: a chave de API e,
+// com uma resposta curta de teste, se ele responde. Nos provedores pagos a
+// resposta de teste só vai com -generate, para o doctor não gastar tokens.
+func checkGenerator(probe bool) func(context.Context, *AlanaEngine) checkResult {
+	return func(ctx context.Context, _ *AlanaEngine) checkResult {
+		req := generationRequest(generationProbeQuestion, "", generationOverride{}, "")
+		gen, err := generatorFor(req.Provider)
+		if err != nil {
+			return checkResult{Status: checkFail, Detail: err.Error()}
+		}
+		detail := req.Provider
+		if req.Model != "" {
+			detail += ", " + req.Model
+		}
+		switch g := gen.(type) {
+		case *openAIGenerator:
+			if g.apiKey == "" && g.url == defaultOpenAIURL {
+				return checkResult{Status: checkFail, Detail: detail + " sem chave de API", Fix: "defina ALANA_OPENAI_API_KEY (ou OPENAI_API_KEY)"}
+			}
+		case *anthropicGenerator:
+			if g.apiKey == "" {
+				return checkResult{Status: checkFail, Detail: detail + " sem chave de API", Fix: "defina ALANA_ANTHROPIC_API_KEY (ou ANTHROPIC_API_KEY)"}
+			}
+		}
+		if !probe && req.Provider != "sidecar" && req.Provider != "ollama" {
+			return checkResult{Status: checkOK, Detail: detail + " (sem resposta de teste; use -generate)"}
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, generationProbeTimeout)
+		defer cancel()
+		req.MaxTokens = generationProbeTokens
+		start := time.Now()
+		if _, err := gen.Generate(ctx, req); err != nil {
+			fix := "confira a URL e o modelo do provedor (seção generation de config/alana.yaml)"
+			if req.Provider == "sidecar" {
+				fix = "confira o log do sidecar: o LLM pode não ter carregado (caminho do modelo .gguf)"
+			}
+			return checkResult{Status: checkFail, Detail: fmt.Sprintf("%s sem resposta (%v)", detail, err), Fix: fix}
+		}
+		return checkResult{Status: checkOK, Detail: fmt.Sprintf("%s, respondeu em %s", detail, time.Since(start).Round(100*time.Millisecond))}
+	}
+}
+
+// checkPython confere o que o orchestrator precisa para rodar o
+// processor.py: o interpretador (ALANA_PYTHON) com as dependências ou, com ALANA_PROCESSOR_IMAGE, o runtime de containers
+// e a imagem
+func checkPython(ctx context.Context, _ *AlanaEngine) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, pythonProbeTimeout)
+	defer cancel()
+
+	if image := os.Getenv("ALANA_PROCESSOR_IMAGE"); image != "" {
+		containerRuntime := cmp.Or(os.Getenv("ALANA_CONTAINER_RUNTIME"), "docker")
+		if _, err := exec.LookPath(containerRuntime); err != nil {
+			return checkResult{
+				Status: checkFail,
+				Detail: fmt.Sprintf("%s não encontrado (ALANA_PROCESSOR_IMAGE=%s)", containerRuntime, image),
+				Fix:    "instale o runtime ou ajuste ALANA_CONTAINER_RUNTIME",
+			}
+		}
+		if err := exec.CommandContext(ctx, containerRuntime, "image", "inspect", image).Run(); err != nil {
+			return checkResult{
+				Status: checkWarn,
+				Detail: fmt.Sprintf("imagem %s não está no %s", image, containerRuntime),
+				Fix:    fmt.Sprintf("%s build -f Dockerfile.processor -t %s .", containerRuntime, image),
+			}
+		}
+		return checkResult{Status: checkOK, Detail: fmt.Sprintf("imagem %s (%s)", image, containerRuntime)}
+	}
+
+	python := cmp.Or(os.Getenv("ALANA_PYTHON"), "python")
+	path, err := exec.LookPath(python)
+	if err != nil {
+		return checkResult{
+			Status: checkFail,
+			Detail: python + " não encontrado",
+			Fix:    "instale o Python 3 ou aponte ALANA_PYTHON para o interpretador (ex: .venv/bin/python)",
+		}
+	}
+	out, err := exec.CommandContext(ctx, path, "-c", pythonProbeScript).Output()
+	var probe struct {
+		Version string   `json:"version"`
+		OK      bool     `json:"ok"`
+		Missing []string `json:"missing"`
+	}
+	if err == nil {
+		err = json.Unmarshal(out, &probe)
+	}
+	if err != nil {
+		return checkResult{Status: checkFail, Detail: fmt.Sprintf("%s não rodou (%v)", path, err)}
+	}
+	detail := fmt.Sprintf("%s %s", path, probe.Version)
+	if !probe.OK {
+		return checkResult{
+			Status: checkFail,
+			Detail: detail + fmt.Sprintf(", o processor.py precisa do %d.%d+", minPythonMajor, minPythonMinor),
+			Fix:    "aponte ALANA_PYTHON para um Python mais novo ou use ALANA_PROCESSOR_IMAGE",
+		}
+	}
+	if len(probe.Missing) > 0 {
+		return checkResult{
+			Status: checkFail,
+			Detail: detail + ", faltam " + strings.Join(probe.Missing, ", "),
+			Fix:    path + " -m pip install -r requirements.txt openai-whisper",
+		}
+	}
+	return checkResult{Status: checkOK, Detail: detail}
+}
+
+func checkDisk(context.Context, *AlanaEngine) checkResult {
+	free, err := freeDiskBytes(dataDir)
+	if errors.Is(err, errDiskUnsupported) {
+		return checkResult{Status: checkWarn, Detail: "verificação de espaço não suportada neste sistema"}
+	}
+	if err != nil {
+		return checkResult{
+			Status: checkFail,
+			Detail: fmt.Sprintf("%s inacessível (%v)", dataDir, err),
+			Fix:    "crie o diretório: mkdir -p data/raw",
+		}
+	}
+	if free < minFreeDiskBytes {
+		return checkResult{
+			Status: checkWarn,
+			Detail: fmt.Sprintf("%.1f GiB livres em %s", float64(free)/(1<<30), dataDir),
+			Fix:    "libere espaço: manifestos, grafo de referências e logs ficam em ./data",
+		}
+	}
+	return checkResult{Status: checkOK, Detail: fmt.Sprintf("%.1f GiB livres em %s", float64(free)/(1<<30), dataDir)}
+}
+
+func checkPort(addr string) func(context.Context, *AlanaEngine) checkResult {
+	return func(context.Context, *AlanaEngine) checkResult {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return checkResult{
+				Status: checkWarn,
+				Detail: fmt.Sprintf("%s ocupado", addr),
+				Fix:    "pare o processo que usa a porta ou rode `alana serve -addr` com outra",
+			}
+		}
+		l.Close()
+		return checkResult{Status: checkOK, Detail: addr + " livre"}
+	}
+}
+
+// collectionDim devolve a dimensão do vetor padrão (sem nome) da collection
+func collectionDim(info *qdrant.CollectionInfo) uint64 {
+	return info.GetConfig().GetParams().GetVectorsConfig().GetParams().GetSize()
+}
+
+func getSidecarHealth(ctx context.Context) (sidecarHealth, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sidecarURL+"/health", nil)
+	if err != nil {
+		return sidecarHealth{}, err
+	}
+	sidecarproto.Offer(req.Header)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return sidecarHealth{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return sidecarHealth{}, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var out sidecarHealth
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return sidecarHealth{}, err
+	}
+	return out, nil
+}
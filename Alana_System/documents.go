@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+
+	"alana_system/manifest"
+)
+
+// ==============================
+// Estado dos documentos
+// ==============================
+
+type documentsResponse struct {
+	Documents []manifest.Document `json:"documents"`
+}
+
+// handleDocuments implementa GET /v1/documents[?status=ingested|failed|ingesting]
+// a partir do manifesto gravado pelo orchestrator.
+func (s *server) handleDocuments(w http.ResponseWriter, r *http.Request) {
+	docs, err := s.manifest.List(r.Context())
+	if err != nil {
+		serverLog.ErrorContext(r.Context(), "Erro ao ler o manifesto", "err", err)
+		writeError(w, http.StatusInternalServerError, "falha ao ler o manifesto")
+		return
+	}
+
+	status := manifest.Status(r.URL.Query().Get("status"))
+	out := make([]manifest.Document, 0, len(docs))
+	for _, d := range docs {
+		if status == "" || d.Status == status {
+			out = append(out, d)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, documentsResponse{Documents: out})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// ==============================
+// Rascunho e refinamento
+// ==============================
+
+// draftThenRefine gera a resposta com dois modelos ao mesmo tempo: o
+// rascunho, do modelo rápido (opts.Draft), vai para stream.Token à medida que
+// é gerado; a resposta do modelo principal é gerada em silêncio. Quando ela
+// termina, se o texto difere do rascunho, stream.Correction recebe a versão
+// final, que é a devolvida. Se só o principal falhar, o rascunho fica como
+// resposta.
+func draftThenRefine(
+	ctx context.Context,
+	question, contextText string,
+	results []SearchResult,
+	opts askOptions,
+	deadline time.Time,
+	stream *answerStream,
+) (Answer, error) {
+
+	type generated struct {
+		answer Answer
+		err    error
+	}
+	refined := make(chan generated, 1)
+	go func() {
+		a, err := generateWithinBudget(ctx, question, contextText, results, opts, deadline, nil)
+		refined <- generated{a, err}
+	}()
+
+	draftOpts := opts
+	draftOpts.Override = *opts.Draft
+	draft, draftErr := generateWithinBudget(ctx, question, contextText, results, draftOpts, deadline, stream.Token)
+	if draftErr != nil && ctx.Err() == nil {
+		engineLog.WarnContext(ctx, "Rascunho falhou; aguardando o modelo principal", "err", draftErr)
+	}
+
+	final := <-refined
+	if final.err != nil {
+		if draftErr != nil {
+			return Answer{}, final.err
+		}
+		engineLog.WarnContext(ctx, "Refinamento falhou; mantendo o rascunho", "err", final.err)
+		return draft, nil
+	}
+
+	if (draftErr != nil || !sameAnswer(draft.Text, final.answer.Text)) && stream.Correction != nil {
+		stream.Correction(final.answer.Text)
+	}
+	return final.answer, nil
+}
+
+// sameAnswer compara ignorando diferenças de espaço e quebra de linha
+func sameAnswer(a, b string) bool {
+	return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ==============================
+// Provedores de embedding
+// ==============================
+
+// Embedder gera o vetor de um texto. dtype é o datatype da collection
+// (vecenc.Float32 ou vecenc.Float16, vazio = float32): o sidecar transfere o
+// vetor nele; os outros provedores só falam float32, e o Qdrant converte.
+type Embedder interface {
+	Embed(ctx context.Context, text, dtype string) ([]float32, error)
+}
+
+// defaultEmbeddingProvider é o embedding_provider do config (ALANA_EMBEDDING_PROVIDER);
+// main aplica config.Load por cima do padrão
+var defaultEmbeddingProvider = "sidecar"
+
+const (
+	defaultOpenAIURL            = "https://api.openai.com/v1"
+	defaultOpenAIEmbeddingModel = "text-embedding-3-small"
+	defaultOllamaURL            = "http://127.0.0.1:11434"
+	defaultOllamaEmbeddingModel = "nomic-embed-text"
+)
+
+// embedder monta o Embedder da collection. Sem embedding_url, a base vem do
+// provedor: ALANA_OPENAI_URL (qualquer API compatível com a da OpenAI),
+// ALANA_OLLAMA_URL ou o sidecar. Sem embedding_model, o OpenAI usa
+// text-embedding-3-small, o Ollama nomic-embed-text e o sidecar o modelo
+// carregado nele.
+func (emb collectionEmbedding) embedder() Embedder {
+	switch emb.Provider {
+	case "openai":
+		e := &openAIEmbedder{url: emb.URL, model: emb.Model, apiKey: os.Getenv("ALANA_OPENAI_API_KEY")}
+		if e.url == "" {
+			e.url = envOr("ALANA_OPENAI_URL", defaultOpenAIURL)
+		}
+		if e.model == "" {
+			e.model = defaultOpenAIEmbeddingModel
+		}
+		if e.apiKey == "" {
+			e.apiKey = os.Getenv("OPENAI_API_KEY")
+		}
+		return e
+	case "ollama":
+		e := &ollamaEmbedder{url: emb.URL, model: emb.Model}
+		if e.url == "" {
+			e.url = envOr("ALANA_OLLAMA_URL", defaultOllamaURL)
+		}
+		if e.model == "" {
+			e.model = defaultOllamaEmbeddingModel
+		}
+		return e
+	}
+	return &sidecarEmbedder{url: emb.URL, model: emb.Model}
+}
+
+// envOr lê a variável de ambiente, com fallback se vazia
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return strings.TrimRight(v, "/")
+	}
+	return fallback
+}
+
+// sidecarEmbedder chama o /embed do bridge.py
+type sidecarEmbedder struct {
+	url   string
+	model string
+}
+
+func (s *sidecarEmbedder) Embed(ctx context.Context, text, dtype string) ([]float32, error) {
+	return getEmbeddingAt(ctx, s.url, s.model, dtype, text)
+}
+
+// openAIEmbedder chama POST {url}/embeddings da OpenAI (ou de um servidor
+// compatível: vLLM, LiteLLM, LocalAI...)
+type openAIEmbedder struct {
+	url    string
+	model  string
+	apiKey string
+}
+
+func (o *openAIEmbedder) Embed(ctx context.Context, text, _ string) ([]float32, error) {
+	header := http.Header{}
+	if o.apiKey != "" {
+		header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+	var out struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	body := map[string]any{"model": o.model, "input": text}
+	if err := postEmbedding(ctx, o.url+"/embeddings", header, body, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Data) == 0 {
+		return nil, errors.New("embed error: resposta sem embedding")
+	}
+	return out.Data[0].Embedding, nil
+}
+
+// ollamaEmbedder chama POST {url}/api/embeddings do Ollama
+type ollamaEmbedder struct {
+	url   string
+	model string
+}
+
+func (o *ollamaEmbedder) Embed(ctx context.Context, text, _ string) ([]float32, error) {
+	var out struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	body := map[string]any{"model": o.model, "prompt": text}
+	if err := postEmbedding(ctx, o.url+"/api/embeddings", http.Header{}, body, &out); err != nil {
+		return nil, err
+	}
+	if len(out.Embedding) == 0 {
+		return nil, fmt.Errorf("embed error: o Ollama não devolveu vetor (o modelo %s gera embeddings?)", o.model)
+	}
+	return out.Embedding, nil
+}
+
+// postEmbedding faz o POST JSON de um provedor externo, com os reenvios do
+// providerHTTP e de withRetry
+func postEmbedding(ctx context.Context, url string, header http.Header, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	_, err = withRetry(ctx, retries, "embed", func(ctx context.Context) (struct{}, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+		if err != nil {
+			return struct{}{}, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := providerHTTP.Do(req)
+		if err != nil {
+			return struct{}{}, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return struct{}{}, readStatusError("embed error", resp)
+		}
+		return struct{}{}, json.NewDecoder(resp.Body).Decode(out)
+	})
+	return err
+}
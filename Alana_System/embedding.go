@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Failover do embedder
+// ==============================
+
+// embeddingFallback é um segundo sidecar (com outro modelo de embedding, ou o
+// mesmo em outra máquina) usado quando o /embed principal falha. Os vetores
+// dele só servem para a collection indexada pelo mesmo modelo: durante uma
+// migração, é a collection do dual-write (ALANA_DUAL_WRITE_COLLECTION).
+type embeddingFallback struct {
+	url        string
+	collection string
+}
+
+// embeddingFallbackFromEnv lê a configuração do fallback. Devolve nil se
+// ALANA_EMBED_FALLBACK_URL estiver vazio.
+//
+//	ALANA_EMBED_FALLBACK_URL          sidecar do fallback (ex: http://127.0.0.1:8001)
+//	ALANA_EMBED_FALLBACK_COLLECTION   collection buscada com os vetores do fallback
+//	                                  (padrão: ALANA_DUAL_WRITE_COLLECTION, senão a principal)
+func embeddingFallbackFromEnv(primaryCollection string) *embeddingFallback {
+	url := os.Getenv("ALANA_EMBED_FALLBACK_URL")
+	if url == "" {
+		return nil
+	}
+
+	collection := os.Getenv("ALANA_EMBED_FALLBACK_COLLECTION")
+	if collection == "" {
+		collection = os.Getenv("ALANA_DUAL_WRITE_COLLECTION")
+	}
+	if collection == "" {
+		collection = primaryCollection
+	}
+	return &embeddingFallback{url: url, collection: collection}
+}
+
+// embedQuery gera o vetor da pergunta com o embedder da collection (ver
+// collectionRegistry.embedding) e devolve o engine que deve buscá-lo: o
+// próprio, ou um apontando para a collection do fallback se o embedder
+// principal falhou. A dimensão do vetor é conferida com a da collection, para
+// que um modelo trocado no config vire erro claro e não busca sem sentido. O
+// vetor vem do sidecar no formato binário do datatype da collection (ver
+// vecenc). O fallback é sempre um sidecar.
+func (e *AlanaEngine) embedQuery(ctx context.Context, question string) ([]float32, *AlanaEngine, error) {
+	info, err := e.vectorInfo(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	vector, err := e.collections.embedding(e.collection).embedder().Embed(ctx, question, info.dtype)
+	if err == nil {
+		if err := e.checkVectorDim(info, vector); err != nil {
+			return nil, nil, err
+		}
+		return vector, e, nil
+	}
+	if e.fallback == nil || ctx.Err() != nil {
+		return nil, nil, err
+	}
+
+	engineLog.WarnContext(ctx, "Embedder principal falhou; usando o fallback", "err", err)
+	target := e.withCollection(e.fallback.collection)
+	targetInfo, infoErr := target.vectorInfo(ctx)
+	if infoErr != nil {
+		return nil, nil, errors.Join(err, fmt.Errorf("fallback: %w", infoErr))
+	}
+	vector, fallbackErr := getEmbeddingAt(ctx, e.fallback.url, "", targetInfo.dtype, question)
+	if fallbackErr != nil {
+		return nil, nil, errors.Join(err, fmt.Errorf("fallback: %w", fallbackErr))
+	}
+	if dimErr := target.checkVectorDim(targetInfo, vector); dimErr != nil {
+		return nil, nil, errors.Join(err, fmt.Errorf("fallback: %w", dimErr))
+	}
+	return vector, target, nil
+}
+
+// vectorInfo é o que o engine precisa saber do vetor denso de uma collection
+type vectorInfo struct {
+	dim uint64
+	// dtype é o datatype em que o Qdrant guarda o vetor (vecenc.Float32 ou
+	// vecenc.Float16), o mesmo pedido ao sidecar
+	dtype string
+}
+
+// vectorInfo lê a dimensão e o datatype do vetor da collection. São lidos do
+// Qdrant uma vez por collection e guardados.
+func (e *AlanaEngine) vectorInfo(ctx context.Context) (vectorInfo, error) {
+	if v, ok := e.vectorInfos.Load(e.collection); ok {
+		return v.(vectorInfo), nil
+	}
+
+	info, err := withRetry(ctx, retries, "qdrant collection info", func(ctx context.Context) (*qdrant.CollectionInfo, error) {
+		ctx, cancel := context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+		return e.client.GetCollectionInfo(ctx, e.collection)
+	})
+	if err != nil {
+		return vectorInfo{}, err
+	}
+	v := vectorInfo{dim: collectionDim(info), dtype: collectionDatatype(info)}
+	e.vectorInfos.Store(e.collection, v)
+	return v, nil
+}
+
+// checkVectorDim confere o vetor com a dimensão da collection
+func (e *AlanaEngine) checkVectorDim(info vectorInfo, vector []float32) error {
+	if info.dim != uint64(len(vector)) {
+		return fmt.Errorf("embedding dimension %d does not match collection %s (dimension %d)",
+			len(vector), e.collection, info.dim)
+	}
+	return nil
+}
+
+// withCollection devolve uma cópia do engine apontando para outra collection.
+// A cópia busca só nela, sem as collections por tipo (ver searchCollections).
+func (e *AlanaEngine) withCollection(collection string) *AlanaEngine {
+	if collection == e.collection {
+		return e
+	}
+	c := *e
+	c.collection = collection
+	c.byType = nil
+	return &c
+}
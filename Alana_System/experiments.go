@@ -0,0 +1,151 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"alana_system/render"
+)
+
+// ==============================
+// Experimentos de prompt e busca
+// ==============================
+
+// maxTemplateRunes limita o prompt_template de um experimento
+const maxTemplateRunes = 8000
+
+// experimentRequest é o corpo do POST /v1/experiments/query: uma pergunta e
+// a configuração inteira do pipeline, em linha. Os campos ausentes vêm do
+// perfil (ou da collection), como no /ask.
+type experimentRequest struct {
+	Question string `json:"question"`
+	Profile  string `json:"profile,omitempty"`
+	// PromptTemplate usa os marcadores {context} e {question}
+	PromptTemplate *string        `json:"prompt_template,omitempty"`
+	TopK           *uint64        `json:"top_k,omitempty"`
+	ScoreThreshold *float32       `json:"score_threshold,omitempty"`
+	Rerank         *bool          `json:"rerank,omitempty"`
+	Hybrid         *bool          `json:"hybrid,omitempty"`
+	Rewrite        *string        `json:"rewrite,omitempty"`
+	Provider       string         `json:"provider,omitempty"`
+	Model          string         `json:"model,omitempty"`
+	Filter         *filterRequest `json:"filter,omitempty"`
+}
+
+// experimentConfig é a configuração efetiva do experimento (pedido + perfil
+// + padrões), para a interface mostrar o que de fato rodou
+type experimentConfig struct {
+	PromptTemplate string  `json:"prompt_template"`
+	TopK           uint64  `json:"top_k"`
+	ScoreThreshold float32 `json:"score_threshold"`
+	Rerank         bool    `json:"rerank"`
+	Hybrid         bool    `json:"hybrid"`
+	Rewrite        string  `json:"rewrite,omitempty"`
+	Provider       string  `json:"provider"`
+	Model          string  `json:"model,omitempty"`
+}
+
+type experimentResponse struct {
+	askResponse
+	Config    experimentConfig `json:"config"`
+	LatencyMS int64            `json:"latency_ms"`
+}
+
+func (req experimentRequest) validate() error {
+	ask := askRequest{
+		Question: req.Question, Profile: req.Profile, Provider: req.Provider, Model: req.Model,
+		TopK: req.TopK, ScoreThreshold: req.ScoreThreshold, Rewrite: req.Rewrite, Filter: req.Filter,
+	}
+	if err := ask.validate(); err != nil {
+		return err
+	}
+	if req.PromptTemplate == nil {
+		return nil
+	}
+	if err := validateText("prompt_template", *req.PromptTemplate, true, maxTemplateRunes); err != nil {
+		return err
+	}
+	if !strings.Contains(*req.PromptTemplate, "{context}") {
+		return invalidField("prompt_template", "invalid_value", "prompt_template precisa do marcador {context}")
+	}
+	return nil
+}
+
+// dryRun é o engine sem nada que grave: sem registro de uso, caches de
+// respostas nem memória de conversas. A busca e a geração são as mesmas.
+func (e *AlanaEngine) dryRun() *AlanaEngine {
+	c := *e
+	c.usage, c.answers, c.semantic, c.memory = nil, nil, nil, nil
+	return &c
+}
+
+// handleExperiment implementa POST /v1/experiments/query: roda uma pergunta
+// com uma configuração em linha (prompt, topK, modelo, corte), sem gravar
+// nada, para testar prompts e ajustes de busca antes de levá-los para
+// config/collections.yaml. Só para chaves privilegiadas (ALANA_PRIVILEGED_KEYS):
+// o prompt é livre e o modelo pode ser qualquer um.
+func (s *server) handleExperiment(w http.ResponseWriter, r *http.Request) {
+	if !s.policy.privilegedKeys[requestAPIKey(r)] {
+		writeError(w, http.StatusForbidden, "api key sem permissão")
+		return
+	}
+	var req experimentRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	settings := retrievalSettings{
+		PromptTemplate: req.PromptTemplate, TopK: req.TopK, ScoreThreshold: req.ScoreThreshold,
+		Rerank: req.Rerank, Hybrid: req.Hybrid, Rewrite: req.Rewrite,
+	}
+	opts, err := s.engine.collections.askOptions(s.engine.collection, req.Profile, settings)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts.Filter, _ = req.Filter.searchFilter() // já validado
+	opts.Cache = cacheBypass
+	opts.Viewer = s.access.viewer(requestAPIKey(r))
+	if req.Provider != "" || req.Model != "" {
+		override, err := s.authorizeOverride(r, generationOverride{Provider: req.Provider, Model: req.Model})
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errOverrideForbidden) {
+				status = http.StatusForbidden
+			}
+			writeError(w, status, err.Error())
+			return
+		}
+		opts.Override = override
+	}
+
+	start := time.Now()
+	answer, err := s.engine.dryRun().Ask(r.Context(), req.Question, opts)
+	if err != nil {
+		serverLog.ErrorContext(r.Context(), "Erro em /v1/experiments/query", "err", err)
+		writeError(w, http.StatusBadGateway, "falha ao executar o experimento")
+		return
+	}
+	writeJSON(w, http.StatusOK, experimentResponse{
+		askResponse: newAskResponse(answer, render.Markdown),
+		Config: experimentConfig{
+			PromptTemplate: cmp.Or(opts.PromptTemplate, defaultPromptTemplate),
+			TopK:           opts.TopK,
+			ScoreThreshold: opts.ScoreThreshold,
+			Rerank:         opts.Rerank,
+			Hybrid:         opts.Hybrid,
+			Rewrite:        opts.Rewrite,
+			Provider:       cmp.Or(opts.Override.Provider, defaultGenerationProvider),
+			Model:          opts.Override.Model,
+		},
+		LatencyMS: time.Since(start).Milliseconds(),
+	})
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"alana_system/assemble"
+	"alana_system/render"
+)
+
+// ==============================
+// Exportação (resposta + trechos citados)
+// ==============================
+
+// Formatos do pacote exportado
+const (
+	exportMarkdown = "markdown"
+	exportPDF      = "pdf"
+)
+
+// sourceBaseURLFromEnv é a URL onde os documentos originais ficam acessíveis
+// (ALANA_SOURCE_BASE_URL); sem ela, o pacote cita só o caminho do arquivo
+func sourceBaseURLFromEnv() string {
+	return strings.TrimRight(os.Getenv("ALANA_SOURCE_BASE_URL"), "/")
+}
+
+// sourceLink aponta para o documento de origem, na página do trecho
+func sourceLink(baseURL string, r SearchResult) string {
+	if baseURL == "" {
+		return r.Source
+	}
+	segments := strings.Split(path.Clean("/"+r.Source), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	link := baseURL + strings.Join(segments, "/")
+	if r.Page > 0 {
+		link += fmt.Sprintf("#page=%d", r.Page)
+	}
+	return link
+}
+
+// exportBundle monta o pacote em markdown: a pergunta, a resposta como o
+// modelo a escreveu e, para cada fonte, o rótulo usado nas citações, o link
+// e o trecho inteiro
+func exportBundle(question string, answer Answer, baseURL string, created time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", oneLine(question))
+	fmt.Fprintf(&b, "_Gerado pela Alana em %s_\n\n", created.UTC().Format("2006-01-02 15:04 UTC"))
+	if answer.Truncated {
+		b.WriteString("_Resposta parcial: o orçamento de tempo acabou antes do fim._\n\n")
+	}
+	fmt.Fprintf(&b, "## Resposta\n\n%s\n\n", strings.TrimSpace(answer.Text))
+
+	if len(answer.Sources) == 0 {
+		return b.String()
+	}
+	b.WriteString("## Fontes\n\n")
+	for i, r := range answer.Sources {
+		c := assembleChunks([]SearchResult{r})[0]
+		fmt.Fprintf(&b, "### [%d] %s/Pág %d\n\n", i+1, assemble.Label(c), r.Page)
+		fmt.Fprintf(&b, "%s | score %.2f\n\n", sourceLink(baseURL, r), r.Score)
+		for _, line := range strings.Split(strings.TrimSpace(r.Text), "\n") {
+			fmt.Fprintf(&b, "> %s\n", line)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// exportFile devolve o conteúdo do pacote no formato pedido, com o tipo MIME
+// e a extensão do arquivo
+func exportFile(format, question, md string) ([]byte, string, string) {
+	if format == exportPDF {
+		return render.PDF(md, oneLine(question)), "application/pdf", ".pdf"
+	}
+	return []byte(md), "text/markdown; charset=utf-8", ".md"
+}
+
+func parseExportFormat(s string) (string, error) {
+	switch f := strings.ToLower(strings.TrimSpace(s)); f {
+	case "", "md", exportMarkdown:
+		return exportMarkdown, nil
+	case exportPDF:
+		return f, nil
+	}
+	return "", fmt.Errorf("formato de exportação desconhecido %q (use markdown ou pdf)", s)
+}
+
+// handleAskExport implementa POST /ask/export?as=markdown|pdf: o mesmo corpo
+// do /ask, com a resposta e os trechos citados num arquivo para anexar a um
+// chamado ou relatório. A exportação nunca devolve pedido de esclarecimento.
+func (s *server) handleAskExport(w http.ResponseWriter, r *http.Request) {
+	format, err := parseExportFormat(r.URL.Query().Get("as"))
+	if err != nil {
+		writeRequestError(w, invalidField("as", "invalid_value", "%v", err))
+		return
+	}
+	call, ok := s.parseAsk(w, r)
+	if !ok {
+		return
+	}
+	if call.req.Draft {
+		writeRequestError(w, invalidField("draft", "invalid_value", "o rascunho só existe com streaming; use /ask/stream"))
+		return
+	}
+	call.opts.Clarify = false
+
+	start := time.Now()
+	answer, err := s.answer(r.Context(), call)
+	s.recent.add("/ask/export", call.question, call.opts, answer, time.Since(start), err)
+	if err != nil {
+		serverLog.ErrorContext(r.Context(), "Erro em /ask/export", "err", err)
+		writeError(w, http.StatusBadGateway, "falha ao gerar resposta")
+		return
+	}
+
+	md := exportBundle(call.question, answer, sourceBaseURLFromEnv(), time.Now())
+	data, contentType, ext := exportFile(format, call.question, md)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"alana-%s%s\"", start.UTC().Format("20060102-150405"), ext))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// runExport implementa `alana export [-as markdown|pdf] [-o arquivo]
+// [-profile P] [-report] <pergunta...>`. Sem -o, o markdown sai no stdout.
+func runExport(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	as := fs.String("as", exportMarkdown, "formato: markdown ou pdf")
+	out := fs.String("o", "", "arquivo de saída (obrigatório para pdf)")
+	profile := fs.String("profile", "", "perfil de config/collections.yaml")
+	report := fs.Bool("report", false, "escreve um relatório em seções em vez de responder")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("uso: export [-as markdown|pdf] [-o arquivo] [-profile P] [-report] <pergunta...>")
+	}
+	format, err := parseExportFormat(*as)
+	if err != nil {
+		return err
+	}
+	if format == exportPDF && *out == "" {
+		return errors.New("-as pdf precisa de -o <arquivo>")
+	}
+
+	question := strings.Join(fs.Args(), " ")
+	opts, err := engine.collections.askOptions(engine.collection, *profile, retrievalSettings{})
+	if err != nil {
+		return err
+	}
+	opts.TokenLimit = engine.models.contextTokenLimit("")
+	opts.Clarify = false
+
+	var answer Answer
+	if *report {
+		answer, err = engine.WriteReport(ctx, question, opts)
+	} else {
+		answer, err = engine.Ask(ctx, question, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	md := exportBundle(question, answer, sourceBaseURLFromEnv(), time.Now())
+	data, _, _ := exportFile(format, question, md)
+	if *out == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		return err
+	}
+	cliLog.Info("Resposta exportada", "file", *out, "sources", len(answer.Sources))
+	return nil
+}
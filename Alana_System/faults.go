@@ -0,0 +1,200 @@
+//go:build faults
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"alana_system/logging"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ==============================
+// Injeção de falhas (build com -tags faults)
+// ==============================
+
+// Compilado só com `go build -tags faults`: o binário normal não tem nada
+// disto (ver faults_off.go). Com a tag, ALANA_FAULTS liga as falhas por alvo:
+//
+//	ALANA_FAULTS="sidecar:error=0.1,delay=0.2@500ms,malformed=0.05;qdrant:error=0.05,delay=0.1@2s"
+//
+// Alvos: sidecar (todas as chamadas de providerHTTP: sidecar, TTS, reranker)
+// e qdrant (chamadas gRPC). Cada falha tem a sua taxa (0..1), sorteada a cada
+// chamada:
+//
+//   - error: sidecar responde 503 (passa pelos reenvios do adaptiveTransport);
+//     qdrant devolve Unavailable
+//   - delay: segura a chamada pela duração depois do @ (ou até o contexto
+//     acabar), exercitando timeouts e orçamentos de latência
+//   - malformed: sidecar responde 200 com um corpo que não é JSON válido
+//     (só sidecar)
+
+// faultTarget são as taxas de um alvo
+type faultTarget struct {
+	errorRate     float64
+	delayRate     float64
+	delay         time.Duration
+	malformedRate float64
+}
+
+// faultsFromEnv lê ALANA_FAULTS uma única vez. Um valor inválido encerra o
+// processo: um teste de caos com a configuração errada não testa nada.
+var faultsFromEnv = sync.OnceValue(func() map[string]faultTarget {
+	spec := strings.TrimSpace(os.Getenv("ALANA_FAULTS"))
+	if spec == "" {
+		return nil
+	}
+	targets, err := parseFaults(spec)
+	if err != nil {
+		logging.Fatal(engineLog, "ALANA_FAULTS inválido", "err", err)
+	}
+	engineLog.Warn("Injeção de falhas ligada", "faults", spec)
+	return targets
+})
+
+func parseFaults(spec string) (map[string]faultTarget, error) {
+	targets := map[string]faultTarget{}
+	for _, part := range strings.Split(spec, ";") {
+		name, rules, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok || (name != "sidecar" && name != "qdrant") {
+			return nil, fmt.Errorf("alvo inválido em %q (sidecar ou qdrant)", part)
+		}
+		var t faultTarget
+		for _, rule := range strings.Split(rules, ",") {
+			kind, value, _ := strings.Cut(strings.TrimSpace(rule), "=")
+			rateStr, durStr, hasDur := strings.Cut(value, "@")
+			rate, err := strconv.ParseFloat(rateStr, 64)
+			if err != nil || rate < 0 || rate > 1 {
+				return nil, fmt.Errorf("%s: taxa inválida em %q (esperado 0..1)", name, rule)
+			}
+			switch {
+			case kind == "error" && !hasDur:
+				t.errorRate = rate
+			case kind == "malformed" && !hasDur && name == "sidecar":
+				t.malformedRate = rate
+			case kind == "delay" && hasDur:
+				if t.delay, err = time.ParseDuration(durStr); err != nil {
+					return nil, fmt.Errorf("%s: duração inválida em %q", name, rule)
+				}
+				t.delayRate = rate
+			default:
+				return nil, fmt.Errorf("%s: falha desconhecida %q", name, rule)
+			}
+		}
+		targets[name] = t
+	}
+	return targets, nil
+}
+
+// hit sorteia uma falha com a taxa informada
+func hit(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// wait segura a chamada pela duração ou até o contexto acabar
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// faultTransport injeta as falhas do alvo sidecar nas chamadas HTTP
+func faultTransport(next http.RoundTripper) http.RoundTripper {
+	return faultRoundTripper{next: next}
+}
+
+type faultRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (f faultRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	t, ok := faultsFromEnv()["sidecar"]
+	if !ok {
+		return f.next.RoundTrip(req)
+	}
+	if hit(t.delayRate) {
+		if err := wait(req.Context(), t.delay); err != nil {
+			return nil, err
+		}
+	}
+	if hit(t.errorRate) {
+		return injectedResponse(req, http.StatusServiceUnavailable, "falha injetada (ALANA_FAULTS)"), nil
+	}
+	if hit(t.malformedRate) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return injectedResponse(req, http.StatusOK, `{"malformed": [`), nil
+	}
+	return f.next.RoundTrip(req)
+}
+
+func injectedResponse(req *http.Request, code int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// qdrantFaultOptions injeta as falhas do alvo qdrant nas chamadas gRPC. O
+// health check da conexão fica de fora, para que o cliente chegue a ser
+// criado.
+func qdrantFaultOptions() []grpc.DialOption {
+	if _, ok := faultsFromEnv()["qdrant"]; !ok {
+		return nil
+	}
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			if err := qdrantFault(ctx, method); err != nil {
+				return err
+			}
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}),
+		grpc.WithChainStreamInterceptor(func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			if err := qdrantFault(ctx, method); err != nil {
+				return nil, err
+			}
+			return streamer(ctx, desc, cc, method, opts...)
+		}),
+	}
+}
+
+func qdrantFault(ctx context.Context, method string) error {
+	if strings.HasSuffix(method, "/HealthCheck") {
+		return nil
+	}
+	t := faultsFromEnv()["qdrant"]
+	if hit(t.delayRate) {
+		if err := wait(ctx, t.delay); err != nil {
+			return status.FromContextError(err).Err()
+		}
+	}
+	if hit(t.errorRate) {
+		return status.Error(codes.Unavailable, "falha injetada (ALANA_FAULTS)")
+	}
+	return nil
+}
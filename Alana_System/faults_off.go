@@ -0,0 +1,19 @@
+//go:build !faults
+
+package main
+
+import (
+	"net/http"
+
+	"google.golang.org/grpc"
+)
+
+// faultTransport não injeta nada fora do build com -tags faults (ver faults.go)
+func faultTransport(next http.RoundTripper) http.RoundTripper {
+	return next
+}
+
+// qdrantFaultOptions não injeta nada fora do build com -tags faults
+func qdrantFaultOptions() []grpc.DialOption {
+	return nil
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ==============================
+// Linguagem de filtro
+// ==============================
+//
+// filter.query (e o -filter da linha de comando, e o ?filter= do /search)
+// escreve o SearchFilter numa linha, sem o JSON do filtro:
+//
+//	source:contratos.pdf AND tag:2024 AND page>10
+//	type:pdf,note date>=2024-03 NOT source:rascunho.pdf
+//	status:open OR status:pending
+//
+// Cada condição é campo, operador e valor. Os campos são source (file_name),
+// type (content_type), tag, date (created_at do documento), page e os
+// metadados dos conectores (ver connectorFields). source, type, tag e os
+// conectores usam ":" (ou "=") e aceitam vários valores separados por
+// vírgula; date e page aceitam também >, >=, < e <=. Valores com espaço vão
+// entre aspas. Condições seguidas valem juntas (AND, explícito ou não); OR só
+// junta condições do mesmo campo, e NOT (ou "-") só vale para source: é o que
+// o SearchFilter sabe guardar. Parênteses não são aceitos.
+
+// filterQueryField é o campo de erro do filtro em texto
+const filterQueryField = "filter.query"
+
+// filterCondition é uma condição da expressão
+type filterCondition struct {
+	field  string
+	op     string
+	values []string
+	negate bool
+}
+
+// applyFilterQuery compila a expressão e soma as condições a f. Um campo que
+// já está em f (pelos campos do JSON) não pode voltar na expressão.
+func applyFilterQuery(f *SearchFilter, expr string) error {
+	groups, err := parseFilterQuery(expr)
+	if err != nil {
+		return invalidField(filterQueryField, "invalid_value", "%v", err)
+	}
+	for _, group := range groups {
+		if err := applyFilterGroup(f, group); err != nil {
+			return invalidField(filterQueryField, "invalid_value", "%v", err)
+		}
+	}
+	return nil
+}
+
+// parseFilterQuery lê a expressão: uma lista (AND) de grupos de condições
+// ligadas por OR
+func parseFilterQuery(expr string) ([][]filterCondition, error) {
+	tokens, err := filterTokens(expr)
+	if err != nil {
+		return nil, err
+	}
+	var groups [][]filterCondition
+	joinOr, negate := false, false
+	for i, tok := range tokens {
+		switch strings.ToUpper(tok) {
+		case "AND":
+			if len(groups) == 0 || joinOr || negate || i == len(tokens)-1 {
+				return nil, fmt.Errorf("AND fora do lugar")
+			}
+			continue
+		case "OR":
+			if len(groups) == 0 || joinOr || negate || i == len(tokens)-1 {
+				return nil, fmt.Errorf("OR fora do lugar")
+			}
+			joinOr = true
+			continue
+		case "NOT":
+			if negate || i == len(tokens)-1 {
+				return nil, fmt.Errorf("NOT fora do lugar")
+			}
+			negate = true
+			continue
+		}
+
+		cond, err := parseFilterCondition(tok)
+		if err != nil {
+			return nil, err
+		}
+		cond.negate = cond.negate || negate
+		if joinOr {
+			groups[len(groups)-1] = append(groups[len(groups)-1], cond)
+		} else {
+			groups = append(groups, []filterCondition{cond})
+		}
+		joinOr, negate = false, false
+	}
+	return groups, nil
+}
+
+// filterTokens divide a expressão nos espaços fora das aspas
+func filterTokens(expr string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range expr {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case !quoted && (r == '(' || r == ')'):
+			return nil, fmt.Errorf("parênteses não são aceitos; use OR entre condições do mesmo campo")
+		case !quoted && unicode.IsSpace(r):
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("aspas sem fechar")
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("expressão vazia")
+	}
+	return tokens, nil
+}
+
+// parseFilterCondition lê campo, operador e valor(es) de um token
+func parseFilterCondition(tok string) (filterCondition, error) {
+	var cond filterCondition
+	if rest, ok := strings.CutPrefix(tok, "-"); ok {
+		cond.negate, tok = true, rest
+	}
+	i := strings.IndexAny(tok, ":=<>")
+	if i <= 0 {
+		return cond, fmt.Errorf("condição inválida %q (use campo:valor, ex: tag:2024)", tok)
+	}
+	cond.field, cond.op = strings.ToLower(tok[:i]), tok[i:i+1]
+	rest := tok[i+1:]
+	if (cond.op == ">" || cond.op == "<") && strings.HasPrefix(rest, "=") {
+		cond.op, rest = cond.op+"=", rest[1:]
+	}
+	if cond.op == "=" {
+		cond.op = ":"
+	}
+
+	for _, v := range splitFilterValues(rest) {
+		v = strings.TrimSpace(v)
+		if unq, err := strconv.Unquote(v); err == nil {
+			v = unq
+		} else if strings.Contains(v, `"`) {
+			return cond, fmt.Errorf("aspas inválidas em %q", tok)
+		}
+		if v == "" {
+			return cond, fmt.Errorf("condição sem valor %q", tok)
+		}
+		cond.values = append(cond.values, v)
+	}
+	if cond.op != ":" && len(cond.values) > 1 {
+		return cond, fmt.Errorf("%s%s aceita um valor só", cond.field, cond.op)
+	}
+	return cond, nil
+}
+
+// splitFilterValues divide os valores nas vírgulas fora das aspas
+// (tag:"a,b",c são dois valores)
+func splitFilterValues(s string) []string {
+	var values []string
+	quoted := false
+	start := 0
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			values = append(values, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(values, s[start:])
+}
+
+// applyFilterGroup grava um grupo (condições ligadas por OR) no filtro
+func applyFilterGroup(f *SearchFilter, group []filterCondition) error {
+	field := group[0].field
+	var values []string
+	for _, cond := range group {
+		if cond.field != field {
+			return fmt.Errorf("OR só junta condições do mesmo campo (%s e %s)", field, cond.field)
+		}
+		if cond.negate && field != "source" {
+			return fmt.Errorf("NOT só vale para source")
+		}
+		if cond.negate && len(group) > 1 {
+			return fmt.Errorf("NOT não combina com OR")
+		}
+		if cond.op != ":" && field != "date" && field != "page" {
+			return fmt.Errorf("%s só aceita \":\" (comparações só em date e page)", field)
+		}
+		if cond.op != ":" && len(group) > 1 {
+			return fmt.Errorf("comparações não combinam com OR")
+		}
+		values = append(values, cond.values...)
+	}
+
+	cond := group[0]
+	set := func(dst *[]string) error {
+		if len(*dst) > 0 {
+			return fmt.Errorf("%s aparece em mais de uma condição; use %s:a,b para qualquer um dos valores", field, field)
+		}
+		*dst = values
+		return nil
+	}
+	switch field {
+	case "source", "file":
+		if cond.negate {
+			f.Exclude = append(f.Exclude, values...)
+			return nil
+		}
+		return set(&f.Sources)
+	case "type":
+		return set(&f.ContentTypes)
+	case "tag":
+		return set(&f.Tags)
+	case "date":
+		return applyDateCondition(f, cond.op, values)
+	case "page":
+		return applyPageCondition(f, cond.op, values)
+	}
+	if !slices.Contains(connectorFields, field) {
+		return fmt.Errorf("campo desconhecido %q (use source, type, tag, date, page, %s)", field, strings.Join(connectorFields, ", "))
+	}
+	if f.Fields == nil {
+		f.Fields = map[string][]string{}
+	}
+	dst := f.Fields[field]
+	if err := set(&dst); err != nil {
+		return err
+	}
+	f.Fields[field] = dst
+	return nil
+}
+
+// applyDateCondition grava date no After/Before. A data vale pelo período
+// que ela escreve: date:2024 é o ano inteiro, date>2024 começa em 2025.
+func applyDateCondition(f *SearchFilter, op string, values []string) error {
+	if len(values) > 1 {
+		return fmt.Errorf("date aceita um valor só")
+	}
+	start, err := parseFilterDate(values[0])
+	if err != nil {
+		return err
+	}
+	end := filterPeriodEnd(values[0], start)
+	setBound := func(dst *time.Time, t time.Time) error {
+		if !dst.IsZero() {
+			return fmt.Errorf("date limitada mais de uma vez do mesmo lado")
+		}
+		*dst = t
+		return nil
+	}
+	switch op {
+	case ":":
+		if err := setBound(&f.After, start); err != nil {
+			return err
+		}
+		return setBound(&f.Before, end)
+	case ">=":
+		return setBound(&f.After, start)
+	case ">":
+		return setBound(&f.After, end)
+	case "<":
+		return setBound(&f.Before, start)
+	case "<=":
+		return setBound(&f.Before, end)
+	}
+	return fmt.Errorf("operador desconhecido %q", op)
+}
+
+// filterPeriodEnd é o fim (exclusivo) do período escrito em s: o ano, o mês,
+// o dia ou o segundo
+func filterPeriodEnd(s string, start time.Time) time.Time {
+	switch len(strings.TrimSpace(s)) {
+	case len("2006"):
+		return start.AddDate(1, 0, 0)
+	case len("2006-01"):
+		return start.AddDate(0, 1, 0)
+	case len("2006-01-02"):
+		return start.AddDate(0, 0, 1)
+	}
+	return start.Add(time.Second)
+}
+
+// applyPageCondition grava page no MinPage/MaxPage (inclusivos)
+func applyPageCondition(f *SearchFilter, op string, values []string) error {
+	if len(values) > 1 {
+		return fmt.Errorf("page aceita um valor só")
+	}
+	page, err := strconv.Atoi(values[0])
+	if err != nil || page < 0 {
+		return fmt.Errorf("page deve ser um inteiro, veio %q", values[0])
+	}
+	setBound := func(dst *int, p int) error {
+		if *dst != 0 {
+			return fmt.Errorf("page limitada mais de uma vez do mesmo lado")
+		}
+		if p < 1 {
+			return fmt.Errorf("page fora do intervalo (as páginas começam em 1)")
+		}
+		*dst = p
+		return nil
+	}
+	switch op {
+	case ":":
+		if err := setBound(&f.MinPage, page); err != nil {
+			return err
+		}
+		return setBound(&f.MaxPage, page)
+	case ">=":
+		return setBound(&f.MinPage, page)
+	case ">":
+		return setBound(&f.MinPage, page+1)
+	case "<":
+		return setBound(&f.MaxPage, page-1)
+	case "<=":
+		return setBound(&f.MaxPage, page)
+	}
+	return fmt.Errorf("operador desconhecido %q", op)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFilterConditionValues(t *testing.T) {
+	cases := []struct {
+		tok  string
+		want []string
+	}{
+		{`tag:a,b`, []string{"a", "b"}},
+		{`tag:"a,b"`, []string{"a,b"}},
+		{`tag:"a,b",c`, []string{"a,b", "c"}},
+		{`source:"relatório anual, 2024.pdf","x.pdf"`, []string{"relatório anual, 2024.pdf", "x.pdf"}},
+	}
+	for _, tc := range cases {
+		cond, err := parseFilterCondition(tc.tok)
+		if err != nil {
+			t.Errorf("%s: %v", tc.tok, err)
+			continue
+		}
+		if !reflect.DeepEqual(cond.values, tc.want) {
+			t.Errorf("%s: valores %q, esperado %q", tc.tok, cond.values, tc.want)
+		}
+	}
+
+	for _, tok := range []string{`tag:"a,b`, `tag:a,`, `tag:a"b`} {
+		if _, err := parseFilterCondition(tok); err == nil {
+			t.Errorf("%s: esperado erro", tok)
+		}
+	}
+}
+
+func TestApplyFilterQueryQuotedComma(t *testing.T) {
+	var f SearchFilter
+	if err := applyFilterQuery(&f, `tag:"a,b" AND source:"c d.pdf"`); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(f.Tags, []string{"a,b"}) || !reflect.DeepEqual(f.Sources, []string{"c d.pdf"}) {
+		t.Errorf("filtro %+v", f)
+	}
+}
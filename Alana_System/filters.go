@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Filtros de metadados
+// ==============================
+
+// SearchFilter restringe a busca pelos metadados gravados no payload pelo
+// orchestrator (ver enrich.go), todos com índice no Qdrant. Cada campo vazio
+// não filtra; listas casam com qualquer um dos valores. O valor zero busca na
+// collection inteira.
+type SearchFilter struct {
+	// Sources são nomes de arquivo (file_name)
+	Sources []string
+	// Exclude são nomes de arquivo que nunca entram, mesmo casando com o resto
+	Exclude []string
+	// ContentTypes são tipos de documento (content_type: pdf, audio, note, text)
+	ContentTypes []string
+	Tags         []string
+	// After (inclusivo) e Before (exclusivo) limitam a data do documento
+	// (created_ts, dos metadados do arquivo). Com um deles, documentos sem
+	// data ficam de fora.
+	After  time.Time
+	Before time.Time
+	// MinPage e MaxPage limitam a página do trecho (page_number), inclusivos;
+	// zero não limita
+	MinPage int
+	MaxPage int
+	// Fields casa os campos de metadados dos conectores (ver
+	// connectorFields) com qualquer um dos valores, ex: status → [open, pending]
+	Fields map[string][]string
+}
+
+// connectorFields são os campos que os conectores de tickets gravam no
+// payload (com índice keyword, criado pelo `alana sync`) e que o filtro aceita
+var connectorFields = []string{"status", "assignee", "product", "priority", "project"}
+
+// qdrantFilter é o visibleFilter com as condições do filtro
+func (f SearchFilter) qdrantFilter() *qdrant.Filter {
+	filter := visibleFilter()
+	if len(f.Sources) > 0 {
+		filter.Must = append(filter.Must, qdrant.NewMatchKeywords("file_name", f.Sources...))
+	}
+	if len(f.Exclude) > 0 {
+		filter.MustNot = append(filter.MustNot, qdrant.NewMatchKeywords("file_name", f.Exclude...))
+	}
+	if len(f.ContentTypes) > 0 {
+		filter.Must = append(filter.Must, qdrant.NewMatchKeywords("content_type", f.ContentTypes...))
+	}
+	if len(f.Tags) > 0 {
+		filter.Must = append(filter.Must, qdrant.NewMatchKeywords("tags", f.Tags...))
+	}
+	if !f.After.IsZero() || !f.Before.IsZero() {
+		r := &qdrant.Range{}
+		if !f.After.IsZero() {
+			r.Gte = qdrant.PtrOf(float64(f.After.Unix()))
+		}
+		if !f.Before.IsZero() {
+			r.Lt = qdrant.PtrOf(float64(f.Before.Unix()))
+		}
+		filter.Must = append(filter.Must, qdrant.NewRange("created_ts", r))
+	}
+	if f.MinPage > 0 || f.MaxPage > 0 {
+		r := &qdrant.Range{}
+		if f.MinPage > 0 {
+			r.Gte = qdrant.PtrOf(float64(f.MinPage))
+		}
+		if f.MaxPage > 0 {
+			r.Lte = qdrant.PtrOf(float64(f.MaxPage))
+		}
+		filter.Must = append(filter.Must, qdrant.NewRange("page_number", r))
+	}
+	keys := make([]string, 0, len(f.Fields))
+	for key := range f.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if values := f.Fields[key]; len(values) > 0 {
+			filter.Must = append(filter.Must, qdrant.NewMatchKeywords(key, values...))
+		}
+	}
+	return filter
+}
+
+// filterRequest é o campo filter do /ask e do /search
+type filterRequest struct {
+	Sources      []string `json:"sources,omitempty"`
+	ContentTypes []string `json:"content_types,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	// After e Before são datas (2024, 2024-03-01 ou RFC3339)
+	After  string `json:"after,omitempty"`
+	Before string `json:"before,omitempty"`
+	// Fields filtra pelos metadados dos conectores (status, assignee, product...)
+	Fields map[string][]string `json:"fields,omitempty"`
+	// Query é o filtro escrito na linguagem de filtro (ver applyFilterQuery),
+	// somado aos campos acima
+	Query string `json:"query,omitempty"`
+}
+
+// searchFilter converte e valida o filtro do pedido (nil = sem filtro)
+func (req *filterRequest) searchFilter() (SearchFilter, error) {
+	if req == nil {
+		return SearchFilter{}, nil
+	}
+	f := SearchFilter{Sources: req.Sources, ContentTypes: req.ContentTypes, Tags: req.Tags}
+	for key, values := range req.Fields {
+		if !slices.Contains(connectorFields, key) {
+			return SearchFilter{}, invalidField("filter.fields."+key, "unknown_field",
+				"campo desconhecido (use %s)", strings.Join(connectorFields, ", "))
+		}
+		if f.Fields == nil {
+			f.Fields = map[string][]string{}
+		}
+		f.Fields[key] = values
+	}
+	for _, d := range []struct {
+		field string
+		value string
+		dst   *time.Time
+	}{{"filter.after", req.After, &f.After}, {"filter.before", req.Before, &f.Before}} {
+		if d.value == "" {
+			continue
+		}
+		t, err := parseFilterDate(d.value)
+		if err != nil {
+			return SearchFilter{}, invalidField(d.field, "invalid_value", "%v", err)
+		}
+		*d.dst = t
+	}
+	if req.Query != "" {
+		if err := applyFilterQuery(&f, req.Query); err != nil {
+			return SearchFilter{}, err
+		}
+	}
+	if f.MinPage > 0 && f.MaxPage > 0 && f.MinPage > f.MaxPage {
+		return SearchFilter{}, invalidField(filterQueryField, "out_of_range", "o intervalo de páginas está vazio (%d a %d)", f.MinPage, f.MaxPage)
+	}
+	if !f.After.IsZero() && !f.Before.IsZero() && !f.After.Before(f.Before) {
+		return SearchFilter{}, invalidField("filter.before", "out_of_range", "filter.before deve ser depois de filter.after")
+	}
+	return f, nil
+}
+
+var filterDateLayouts = []string{time.RFC3339, "2006-01-02", "2006-01", "2006"}
+
+// parseFilterDate lê uma data do filtro; sem fuso, é UTC (como o created_at
+// gravado pelo orchestrator)
+func parseFilterDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range filterDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("data inválida %q (use 2024, 2024-03, 2024-03-01 ou RFC3339)", s)
+}
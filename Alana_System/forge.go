@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"alana_system/schema"
+)
+
+// ==============================
+// Conectores GitHub e GitLab
+// ==============================
+
+// forgePageSize é o tamanho de página pedido às duas APIs (o máximo delas)
+const forgePageSize = 100
+
+// wikiExtensions são os formatos de página de wiki ingeridos como texto
+var wikiExtensions = map[string]bool{
+	".md": true, ".markdown": true, ".rst": true, ".adoc": true, ".asciidoc": true,
+	".org": true, ".textile": true, ".mediawiki": true, ".wiki": true, ".txt": true,
+}
+
+// sortItems ordena por UpdatedAt crescente, com os itens sem data (wiki) no
+// fim: o cursor só avança depois que todo o anterior entrou
+func sortItems(items []connectorItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i].UpdatedAt, items[j].UpdatedAt
+		if a.IsZero() || b.IsZero() {
+			return !a.IsZero() && b.IsZero()
+		}
+		return a.Before(b)
+	})
+}
+
+func yieldAll(items []connectorItem, yield func(connectorItem) error) error {
+	sortItems(items)
+	for _, it := range items {
+		if err := yield(it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ------------------------------
+// GitHub
+// ------------------------------
+
+// githubConnector lê issues e PRs pela API REST (/repos/{dono}/{repo}/issues
+// traz os dois) e a wiki por git clone, já que o GitHub não tem API de wiki.
+// ALANA_GITHUB_URL aponta para um GitHub Enterprise (padrão
+// https://api.github.com); o token vem de ALANA_GITHUB_TOKEN ou GITHUB_TOKEN.
+type githubConnector struct {
+	repo    string
+	baseURL string
+	token   string
+	wiki    bool
+}
+
+func newGitHubConnector(repo string, wiki bool) (*githubConnector, error) {
+	if owner, name, ok := strings.Cut(repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("repositório GitHub inválido %q (use dono/repo)", repo)
+	}
+	c := &githubConnector{
+		repo:    repo,
+		baseURL: strings.TrimRight(os.Getenv("ALANA_GITHUB_URL"), "/"),
+		token:   os.Getenv("ALANA_GITHUB_TOKEN"),
+		wiki:    wiki,
+	}
+	if c.baseURL == "" {
+		c.baseURL = "https://api.github.com"
+	}
+	if c.token == "" {
+		c.token = os.Getenv("GITHUB_TOKEN")
+	}
+	return c, nil
+}
+
+func (c *githubConnector) Name() string { return "github:" + c.repo }
+
+func (c *githubConnector) header() http.Header {
+	h := http.Header{"X-Github-Api-Version": {"2022-11-28"}}
+	if c.token != "" {
+		h.Set("Authorization", "Bearer "+c.token)
+	}
+	return h
+}
+
+type githubIssue struct {
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	HTMLURL   string    `json:"html_url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	User      struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	// PullRequest só vem preenchido quando a issue é um PR
+	PullRequest *struct{} `json:"pull_request"`
+}
+
+func (c *githubConnector) Fetch(ctx context.Context, since time.Time, yield func(connectorItem) error) error {
+	q := url.Values{
+		"state":     {"all"},
+		"sort":      {"updated"},
+		"direction": {"asc"},
+		"per_page":  {fmt.Sprint(forgePageSize)},
+	}
+	if !since.IsZero() {
+		q.Set("since", since.UTC().Format(time.RFC3339))
+	}
+
+	var items []connectorItem
+	next := c.baseURL + "/repos/" + c.repo + "/issues?" + q.Encode()
+	for next != "" {
+		var page []githubIssue
+		header, err := getConnectorJSON(ctx, next, c.header(), &page)
+		if err != nil {
+			return err
+		}
+		for _, issue := range page {
+			items = append(items, c.issueItem(issue))
+		}
+		next = nextLink(header.Get("Link"))
+	}
+
+	if c.wiki {
+		pages, err := c.wikiPages(ctx)
+		if err != nil {
+			// Repositório sem wiki (ou wiki privada sem token): segue só com as issues
+			connectorLog.Warn("Wiki ignorada", "repo", c.repo, "err", err)
+		}
+		items = append(items, pages...)
+	}
+	return yieldAll(items, yield)
+}
+
+func (c *githubConnector) issueItem(issue githubIssue) connectorItem {
+	it := connectorItem{
+		DocID:       fmt.Sprintf("%s#%d", c.Name(), issue.Number),
+		Title:       issue.Title,
+		Text:        issue.Body,
+		URL:         issue.HTMLURL,
+		Author:      issue.User.Login,
+		ContentType: schema.ContentIssue,
+		CreatedAt:   issue.CreatedAt,
+		UpdatedAt:   issue.UpdatedAt,
+	}
+	if issue.PullRequest != nil {
+		it.ContentType = schema.ContentPullRequest
+	}
+	for _, l := range issue.Labels {
+		it.Tags = append(it.Tags, l.Name)
+	}
+	return it
+}
+
+// wikiPages clona a wiki (git clone --depth 1) num diretório temporário e lê
+// as páginas. O token vai pelo ambiente do git, fora da linha de comando.
+func (c *githubConnector) wikiPages(ctx context.Context) ([]connectorItem, error) {
+	var repo struct {
+		HTMLURL string `json:"html_url"`
+		HasWiki bool   `json:"has_wiki"`
+	}
+	if _, err := getConnectorJSON(ctx, c.baseURL+"/repos/"+c.repo, c.header(), &repo); err != nil {
+		return nil, err
+	}
+	if !repo.HasWiki {
+		return nil, nil
+	}
+
+	dir, err := os.MkdirTemp("", "alana-wiki-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := exec.CommandContext(ctx, "git", "clone", "--quiet", "--depth", "1", repo.HTMLURL+".wiki.git", dir)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if c.token != "" {
+		basic := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + c.token))
+		cmd.Env = append(cmd.Env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+basic,
+		)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("git clone: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	var items []connectorItem
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if !wikiExtensions[ext] {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		slug := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		items = append(items, connectorItem{
+			DocID:       c.Name() + "/wiki/" + slug,
+			Title:       strings.ReplaceAll(slug, "-", " "),
+			Text:        string(content),
+			URL:         repo.HTMLURL + "/wiki/" + url.PathEscape(slug),
+			ContentType: schema.ContentWiki,
+		})
+		return nil
+	})
+	return items, err
+}
+
+// linkNext acha o rel="next" do cabeçalho Link (RFC 8288)
+var linkNext = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+func nextLink(header string) string {
+	if m := linkNext.FindStringSubmatch(header); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// ------------------------------
+// GitLab
+// ------------------------------
+
+// gitlabConnector lê issues, merge requests e wiki pela API v4. ALANA_GITLAB_URL
+// aponta para uma instância própria (padrão https://gitlab.com); o token
+// (PRIVATE-TOKEN) vem de ALANA_GITLAB_TOKEN.
+type gitlabConnector struct {
+	project string
+	baseURL string
+	token   string
+	wiki    bool
+}
+
+func newGitLabConnector(project string, wiki bool) (*gitlabConnector, error) {
+	if !strings.Contains(project, "/") {
+		return nil, fmt.Errorf("projeto GitLab inválido %q (use grupo/projeto)", project)
+	}
+	c := &gitlabConnector{
+		project: strings.Trim(project, "/"),
+		baseURL: strings.TrimRight(os.Getenv("ALANA_GITLAB_URL"), "/"),
+		token:   os.Getenv("ALANA_GITLAB_TOKEN"),
+		wiki:    wiki,
+	}
+	if c.baseURL == "" {
+		c.baseURL = "https://gitlab.com"
+	}
+	return c, nil
+}
+
+func (c *gitlabConnector) Name() string { return "gitlab:" + c.project }
+
+func (c *gitlabConnector) header() http.Header {
+	h := http.Header{}
+	if c.token != "" {
+		h.Set("Private-Token", c.token)
+	}
+	return h
+}
+
+// api é a URL de um recurso do projeto (o caminho vai codificado, grupo%2Fprojeto)
+func (c *gitlabConnector) api(resource string, q url.Values) string {
+	return c.baseURL + "/api/v4/projects/" + url.PathEscape(c.project) + "/" + resource + "?" + q.Encode()
+}
+
+type gitlabIssue struct {
+	IID         int       `json:"iid"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	WebURL      string    `json:"web_url"`
+	Labels      []string  `json:"labels"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Author      struct {
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+type gitlabWikiPage struct {
+	Slug    string `json:"slug"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+func (c *gitlabConnector) Fetch(ctx context.Context, since time.Time, yield func(connectorItem) error) error {
+	var items []connectorItem
+	// Issues (#) e merge requests (!) são listas separadas; juntas e
+	// ordenadas, o cursor comum não pula nada se uma delas falhar
+	for _, kind := range []struct{ resource, sep, contentType string }{
+		{"issues", "#", schema.ContentIssue},
+		{"merge_requests", "!", schema.ContentPullRequest},
+	} {
+		q := url.Values{
+			"scope":    {"all"},
+			"state":    {"all"},
+			"order_by": {"updated_at"},
+			"sort":     {"asc"},
+			"per_page": {fmt.Sprint(forgePageSize)},
+		}
+		if !since.IsZero() {
+			q.Set("updated_after", since.UTC().Format(time.RFC3339))
+		}
+		for page := "1"; page != ""; {
+			q.Set("page", page)
+			var issues []gitlabIssue
+			header, err := getConnectorJSON(ctx, c.api(kind.resource, q), c.header(), &issues)
+			if err != nil {
+				return err
+			}
+			for _, issue := range issues {
+				items = append(items, connectorItem{
+					DocID:       fmt.Sprintf("%s%s%d", c.Name(), kind.sep, issue.IID),
+					Title:       issue.Title,
+					Text:        issue.Description,
+					URL:         issue.WebURL,
+					Author:      issue.Author.Username,
+					Tags:        issue.Labels,
+					ContentType: kind.contentType,
+					CreatedAt:   issue.CreatedAt,
+					UpdatedAt:   issue.UpdatedAt,
+				})
+			}
+			page = header.Get("X-Next-Page")
+		}
+	}
+
+	if c.wiki {
+		// A API de wiki não pagina nem tem data: vem tudo, e o hash decide
+		var pages []gitlabWikiPage
+		if _, err := getConnectorJSON(ctx, c.api("wikis", url.Values{"with_content": {"1"}}), c.header(), &pages); err != nil {
+			connectorLog.Warn("Wiki ignorada", "project", c.project, "err", err)
+		}
+		for _, p := range pages {
+			items = append(items, connectorItem{
+				DocID:       c.Name() + "/wiki/" + p.Slug,
+				Title:       p.Title,
+				Text:        p.Content,
+				URL:         c.baseURL + "/" + c.project + "/-/wikis/" + p.Slug,
+				ContentType: schema.ContentWiki,
+			})
+		}
+	}
+	return yieldAll(items, yield)
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"alana_system/yamlite"
+)
+
+// ==============================
+// Frescor das fontes (SLA de sincronização)
+// ==============================
+
+const (
+	// defaultSourcesPath é lido quando ALANA_SOURCES não está definida
+	defaultSourcesPath = "config/sources.yaml"
+	// freshnessCheckInterval é a frequência da verificação no serve
+	freshnessCheckInterval = time.Minute
+)
+
+// sourcesConfig são as fontes de `alana sync` com o intervalo máximo
+// aceitável entre duas sincronizações bem-sucedidas (sla) e o webhook que
+// recebe os alertas (genérico ou do Slack):
+//
+//	alert_webhook: https://hooks.slack.com/services/...
+//	sources:
+//	  github:dono/repo:
+//	    sla: 2h
+//	  "web:https://docs.exemplo.com/sitemap.xml":
+//	    sla: 24h
+//
+// Uma fonte sem sla é sincronizada, mas nunca fica atrasada.
+type sourcesConfig struct {
+	AlertWebhook string
+	SLAs         map[string]time.Duration
+}
+
+// sourcesPath lê ALANA_SOURCES (padrão config/sources.yaml)
+func sourcesPath() string {
+	if path := os.Getenv("ALANA_SOURCES"); path != "" {
+		return path
+	}
+	return defaultSourcesPath
+}
+
+// loadSources lê o arquivo de fontes (inexistente = nenhuma fonte)
+func loadSources(path string) (*sourcesConfig, error) {
+	cfg := &sourcesConfig{SLAs: map[string]time.Duration{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc, err := yamlite.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if hook, ok := doc["alert_webhook"].(string); ok && hook != "" {
+		if err := validateWebhook(hook); err != nil {
+			return nil, fmt.Errorf("%s: alert_webhook: %w", path, err)
+		}
+		cfg.AlertWebhook = hook
+	}
+	sources, _ := doc["sources"].(map[string]any)
+	for spec, raw := range sources {
+		if kind, project, _ := strings.Cut(spec, ":"); kind == "" || project == "" {
+			return nil, fmt.Errorf("%s: sources.%s: fonte inválida (use tipo:projeto)", path, spec)
+		}
+		var sla time.Duration
+		if fields, ok := raw.(map[string]any); ok && fields["sla"] != nil {
+			sla, err = time.ParseDuration(fmt.Sprint(fields["sla"]))
+			if err != nil || sla <= 0 {
+				return nil, fmt.Errorf("%s: sources.%s.sla: duração inválida %v (ex: 2h, 30m)", path, spec, fields["sla"])
+			}
+		} else if raw != nil {
+			return nil, fmt.Errorf("%s: sources.%s: esperado um mapa (sla: 2h)", path, spec)
+		}
+		cfg.SLAs[spec] = sla
+	}
+	return cfg, nil
+}
+
+// specs são as fontes configuradas, em ordem
+func (c *sourcesConfig) specs() []string {
+	return slices.Sorted(maps.Keys(c.SLAs))
+}
+
+// sourceFreshness é o estado de uma fonte frente ao seu SLA
+type sourceFreshness struct {
+	Source      string        `json:"source"`
+	SLA         time.Duration `json:"-"`
+	LastSuccess time.Time     `json:"last_success,omitzero"`
+	LastError   string        `json:"last_error,omitempty"`
+	// Stale marca a fonte sem sincronização bem-sucedida dentro do SLA
+	// (inclusive a que nunca sincronizou)
+	Stale bool `json:"stale"`
+}
+
+// age é o tempo desde a última sincronização bem-sucedida (zero se nunca houve)
+func (f sourceFreshness) age(now time.Time) time.Duration {
+	if f.LastSuccess.IsZero() {
+		return 0
+	}
+	return now.Sub(f.LastSuccess)
+}
+
+// freshnessReport cruza as fontes configuradas com o arquivo de estado do
+// sync. Fontes que só aparecem no estado entram sem SLA.
+func freshnessReport(cfg *sourcesConfig, states map[string]connectorState, now time.Time) []sourceFreshness {
+	names := map[string]bool{}
+	for name := range cfg.SLAs {
+		names[name] = true
+	}
+	for name := range states {
+		names[name] = true
+	}
+
+	var report []sourceFreshness
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		state := states[name]
+		f := sourceFreshness{
+			Source:      name,
+			SLA:         cfg.SLAs[name],
+			LastSuccess: state.LastSuccess,
+			LastError:   state.LastError,
+		}
+		f.Stale = f.SLA > 0 && (f.LastSuccess.IsZero() || now.Sub(f.LastSuccess) > f.SLA)
+		report = append(report, f)
+	}
+	return report
+}
+
+// loadFreshness lê as fontes e o estado atuais e monta o relatório
+func loadFreshness(now time.Time) (*sourcesConfig, []sourceFreshness, error) {
+	cfg, err := loadSources(sourcesPath())
+	if err != nil {
+		return nil, nil, err
+	}
+	states, err := loadConnectorStates(connectorStatePath())
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, freshnessReport(cfg, states, now), nil
+}
+
+// ------------------------------
+// Alertas
+// ------------------------------
+
+// freshnessAlert é o corpo enviado aos webhooks genéricos. Webhooks do Slack
+// recebem só {"text": ...}.
+type freshnessAlert struct {
+	Source string `json:"source"`
+	// Status é "stale" (SLA estourado) ou "recovered" (voltou a sincronizar)
+	Status      string    `json:"status"`
+	SLA         string    `json:"sla"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+func sendFreshnessAlert(ctx context.Context, hook string, f sourceFreshness, now time.Time) error {
+	a := freshnessAlert{
+		Source:      f.Source,
+		Status:      "recovered",
+		SLA:         f.SLA.String(),
+		LastSuccess: f.LastSuccess,
+		LastError:   redactForLog(f.LastError),
+		Time:        now.UTC(),
+	}
+	if f.Stale {
+		a.Status = "stale"
+	}
+	var payload any = a
+	if isSlackWebhook(hook) {
+		payload = map[string]string{"text": freshnessText(f, now)}
+	}
+	return postWebhook(ctx, hook, payload)
+}
+
+// freshnessText descreve o alerta em mrkdwn do Slack
+func freshnessText(f sourceFreshness, now time.Time) string {
+	if !f.Stale {
+		return fmt.Sprintf(":white_check_mark: Fonte *%s* voltou a sincronizar", f.Source)
+	}
+	var b strings.Builder
+	if f.LastSuccess.IsZero() {
+		fmt.Fprintf(&b, ":warning: Fonte *%s* nunca sincronizou (SLA %s)", f.Source, f.SLA)
+	} else {
+		fmt.Fprintf(&b, ":warning: Fonte *%s* sem sincronizar há %s (SLA %s)", f.Source, f.age(now).Round(time.Minute), f.SLA)
+	}
+	if f.LastError != "" {
+		fmt.Fprintf(&b, "; último erro: %s", truncateRunes(redactForLog(f.LastError), 500))
+	}
+	return b.String()
+}
+
+// freshnessMonitor avisa uma vez quando uma fonte estoura o SLA e outra
+// quando ela volta a sincronizar
+type freshnessMonitor struct {
+	alerted map[string]bool
+}
+
+func newFreshnessMonitor() *freshnessMonitor {
+	return &freshnessMonitor{alerted: map[string]bool{}}
+}
+
+// check relê as fontes e o estado (o sync roda em outro processo) e envia os
+// alertas das fontes que mudaram de situação. Um alerta que falha é
+// reenviado na próxima verificação.
+func (m *freshnessMonitor) check(ctx context.Context, now time.Time) error {
+	cfg, report, err := loadFreshness(now)
+	if err != nil {
+		return err
+	}
+	for _, f := range report {
+		if f.Stale == m.alerted[f.Source] {
+			continue
+		}
+		if f.Stale {
+			last := "nunca"
+			if !f.LastSuccess.IsZero() {
+				last = f.LastSuccess.Format(time.RFC3339)
+			}
+			monitorLog.Warn("Fonte fora do SLA de frescor", "source", f.Source, "sla", f.SLA, "last_sync", last)
+		} else {
+			monitorLog.Info("Fonte voltou a sincronizar", "source", f.Source)
+		}
+		if cfg.AlertWebhook != "" {
+			if err := sendFreshnessAlert(ctx, cfg.AlertWebhook, f, now); err != nil {
+				monitorLog.Error("Erro ao notificar", "webhook", redactWebhook(cfg.AlertWebhook), "err", err)
+				continue
+			}
+		}
+		m.alerted[f.Source] = f.Stale
+	}
+	return nil
+}
+
+// freshnessLoop verifica os SLAs a cada freshnessCheckInterval até ctx acabar
+func freshnessLoop(ctx context.Context, m *freshnessMonitor) {
+	ticker := time.NewTicker(freshnessCheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := m.check(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			monitorLog.Warn("Erro na verificação de frescor das fontes", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runFreshness implementa `alana freshness [-alert]`: mostra a última
+// sincronização de cada fonte frente ao SLA e falha se alguma estiver
+// atrasada (para o cron ou o monitoramento de quem não roda o serve). Com
+// -alert, avisa o alert_webhook de cada fonte atrasada.
+func runFreshness(ctx context.Context, _ *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("freshness", flag.ContinueOnError)
+	alert := fs.Bool("alert", false, "envia o alerta das fontes atrasadas ao alert_webhook")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	cfg, report, err := loadFreshness(now)
+	if err != nil {
+		return err
+	}
+	if len(report) == 0 {
+		fmt.Printf("Nenhuma fonte em %s nem em %s\n", sourcesPath(), connectorStatePath())
+		return nil
+	}
+
+	stale := 0
+	for _, f := range report {
+		icon, last := "✅", "nunca"
+		if f.Stale {
+			icon = "⚠️ "
+			stale++
+		}
+		if !f.LastSuccess.IsZero() {
+			last = fmt.Sprintf("há %s", f.age(now).Round(time.Second))
+		}
+		sla := "sem SLA"
+		if f.SLA > 0 {
+			sla = "SLA " + f.SLA.String()
+		}
+		fmt.Printf("%s %-50s %-20s %s\n", icon, f.Source, last, sla)
+		if f.LastError != "" {
+			fmt.Printf("   → último erro: %s\n", truncateRunes(f.LastError, 200))
+		}
+		if f.Stale && *alert && cfg.AlertWebhook != "" {
+			if err := sendFreshnessAlert(ctx, cfg.AlertWebhook, f, now); err != nil {
+				monitorLog.Error("Erro ao notificar", "webhook", redactWebhook(cfg.AlertWebhook), "err", err)
+			}
+		}
+	}
+	if stale > 0 {
+		return fmt.Errorf("%d fontes fora do SLA", stale)
+	}
+	return nil
+}
+
+// ------------------------------
+// Métricas
+// ------------------------------
+
+// handleMetrics implementa GET /metrics no formato texto do Prometheus, com o
+// frescor de cada fonte e a última rodada das canárias
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	_, report, err := loadFreshness(now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var b strings.Builder
+	gauge := func(name, help string, value func(sourceFreshness) float64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, f := range report {
+			fmt.Fprintf(&b, "%s{source=\"%s\"} %g\n", name, promLabel(f.Source), value(f))
+		}
+	}
+	gauge("alana_source_last_success_timestamp_seconds", "Fim da última sincronização bem-sucedida (0 = nunca).",
+		func(f sourceFreshness) float64 {
+			if f.LastSuccess.IsZero() {
+				return 0
+			}
+			return float64(f.LastSuccess.Unix())
+		})
+	gauge("alana_source_sla_seconds", "Intervalo máximo entre sincronizações (0 = sem SLA).",
+		func(f sourceFreshness) float64 { return f.SLA.Seconds() })
+	gauge("alana_source_stale", "1 se a fonte está fora do SLA.",
+		func(f sourceFreshness) float64 { return boolGauge(f.Stale) })
+	gauge("alana_source_last_sync_failed", "1 se a última tentativa de sincronização falhou.",
+		func(f sourceFreshness) float64 { return boolGauge(f.LastError != "") })
+	if s.canaries != nil {
+		writeCanaryMetrics(&b, s.canaries)
+	}
+	if s.sidecar != nil {
+		writeSidecarMetrics(&b, s.sidecar)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, b.String())
+}
+
+// promLabel escapa o valor do rótulo (\\, \" e \n, os escapes do formato)
+var promLabel = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
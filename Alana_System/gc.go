@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"alana_system/manifest"
+	"alana_system/schema"
+	"alana_system/textstore"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Coleta de pontos órfãos
+// ==============================
+
+// orphanKind é o motivo de um ponto ser considerado órfão
+type orphanKind string
+
+const (
+	// orphanUnknown: o documento do ponto não está no manifesto
+	orphanUnknown orphanKind = "documento desconhecido"
+	// orphanSuperseded: ponto publicado de uma versão anterior à do manifesto
+	orphanSuperseded orphanKind = "versão substituída"
+	// orphanStaging: ponto em staging de uma ingestão que não está em andamento
+	// (crash do worker ou rollback que falhou)
+	orphanStaging orphanKind = "staging abandonado"
+)
+
+// runGC implementa `alana gc [-delete] [-min-age D]`: cruza os pontos do
+// Qdrant com o manifesto e lista os órfãos (documento desconhecido, versão
+// substituída, staging abandonado). Com -delete, apaga-os.
+//
+// Os pontos são ligados ao manifesto pelo file_name (nome do arquivo, sem o
+// diretório). Pontos de ingestões mais novas que -min-age (a versão carrega o
+// horário da ingestão) são ignorados, para não disputar com o orchestrator;
+// pontos gravados pelo IngestText e pelos conectores (ver schema.Direct)
+// não têm entrada no manifesto e também ficam de fora.
+func runGC(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	del := fs.Bool("delete", false, "apaga os pontos órfãos (sem isso, só relata)")
+	minAge := fs.Duration("min-age", time.Hour, "ignora ingestões mais novas que isso")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *del {
+		if err := engine.writable(); err != nil {
+			return err
+		}
+		if err := engine.confirmDestructive("gc -delete"); err != nil {
+			return err
+		}
+	}
+
+	docs, err := loadManifestIndex(ctx)
+	if err != nil {
+		return err
+	}
+	if len(docs) == 0 && *del {
+		return errors.New("manifesto vazio: recusando apagar (todos os pontos seriam órfãos)")
+	}
+
+	cutoff := time.Now().Add(-*minAge)
+	orphans := map[orphanKind][]*qdrant.PointId{}
+	// offloaded são os órfãos com o texto no text store
+	var offloaded []string
+	byDoc := map[string]map[orphanKind]int{}
+	scanned := 0
+
+	cliLog.Info("Procurando pontos órfãos")
+	err = engine.scrollPages(ctx, nil, nil, false, func(points []*qdrant.RetrievedPoint, _ *qdrant.PointId) error {
+		for _, p := range points {
+			scanned++
+			kind, ok := classifyPoint(p.GetPayload(), docs, cutoff)
+			if !ok {
+				continue
+			}
+			orphans[kind] = append(orphans[kind], p.GetId())
+			if p.GetPayload()["text_offloaded"].GetBoolValue() {
+				offloaded = append(offloaded, pointIDString(p.GetId()))
+			}
+			fileName := p.GetPayload()["file_name"].GetStringValue()
+			if byDoc[fileName] == nil {
+				byDoc[fileName] = map[orphanKind]int{}
+			}
+			byDoc[fileName][kind]++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, ids := range orphans {
+		total += len(ids)
+	}
+	fmt.Printf("%d pontos verificados, %d órfãos\n", scanned, total)
+	kinds := []orphanKind{orphanUnknown, orphanSuperseded, orphanStaging}
+	for _, kind := range kinds {
+		if n := len(orphans[kind]); n > 0 {
+			fmt.Printf("   %-24s %d\n", kind, n)
+		}
+	}
+	for _, fileName := range slices.Sorted(maps.Keys(byDoc)) {
+		for _, kind := range kinds {
+			if n := byDoc[fileName][kind]; n > 0 {
+				fmt.Printf("   - %s: %d (%s)\n", fileName, n, kind)
+			}
+		}
+	}
+
+	if total == 0 || !*del {
+		if total > 0 {
+			cliLog.Info("Rode com -delete para apagá-los")
+		}
+		return nil
+	}
+
+	deleted := 0
+	for _, ids := range orphans {
+		for batch := range slices.Chunk(ids, payloadBatchLen) {
+			if err := engine.deletePoints(ctx, batch); err != nil {
+				return fmt.Errorf("apagados %d de %d: %w", deleted, total, err)
+			}
+			deleted += len(batch)
+		}
+	}
+	cliLog.Info("Pontos órfãos apagados", "n", deleted)
+
+	released, err := textstore.Release(ctx, engine.texts, engine.client, offloaded)
+	if err != nil {
+		return fmt.Errorf("text store: %w", err)
+	}
+	if released > 0 {
+		cliLog.Info("Textos apagados do text store", "n", released)
+	}
+	return nil
+}
+
+// loadManifestIndex agrupa as entradas do manifesto pelo nome do arquivo,
+// que é como os pontos identificam o documento (file_name)
+func loadManifestIndex(ctx context.Context) (map[string][]manifest.Document, error) {
+	store, err := manifest.Open(ctx, os.Getenv("ALANA_MANIFEST"))
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	list, err := store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := map[string][]manifest.Document{}
+	for _, d := range list {
+		name := filepath.Base(filepath.FromSlash(d.Source))
+		docs[name] = append(docs[name], d)
+	}
+	return docs, nil
+}
+
+// classifyPoint decide se o ponto é órfão e por quê
+func classifyPoint(payload map[string]*qdrant.Value, docs map[string][]manifest.Document, cutoff time.Time) (orphanKind, bool) {
+	if schema.Direct(payload["content_type"].GetStringValue()) {
+		return "", false
+	}
+
+	version := payload["ingest_version"].GetStringValue()
+	if version != "" {
+		// Ingestões recentes podem ainda não ter chegado ao manifesto
+		if t, ok := ingestVersionTime(version); !ok || t.After(cutoff) {
+			return "", false
+		}
+	}
+
+	entries, known := docs[payload["file_name"].GetStringValue()]
+	if !known {
+		return orphanUnknown, true
+	}
+
+	staging := payload["staging"].GetBoolValue()
+	for _, d := range entries {
+		if d.Version == version && (!staging || d.Status == manifest.StatusIngesting) {
+			return "", false
+		}
+		// Sem a versão publicada no manifesto (ingestão em andamento ou que
+		// falhou), os pontos publicados continuam valendo
+		if !staging && d.Status != manifest.StatusIngested {
+			return "", false
+		}
+	}
+
+	if staging {
+		return orphanStaging, true
+	}
+	return orphanSuperseded, true
+}
+
+// ingestVersionTime lê o horário embutido na versão (UnixNano em base 36,
+// ver newIngestVersion no orchestrator)
+func ingestVersionTime(version string) (time.Time, bool) {
+	n, err := strconv.ParseInt(version, 36, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, n), true
+}
+
+func (e *AlanaEngine) deletePoints(ctx context.Context, ids []*qdrant.PointId) error {
+	if err := e.writable(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	_, err := e.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: e.collection,
+		Wait:           qdrant.PtrOf(true),
+		Points:         qdrant.NewPointsSelector(ids...),
+	})
+	if err != nil {
+		return fmt.Errorf("qdrant delete failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"alana_system/config"
+	"alana_system/sidecarproto"
+)
+
+// ==============================
+// Provedores de geração
+// ==============================
+
+// Generator gera a resposta de um GenerateRequest: de uma vez (Generate) ou
+// repassando cada pedaço a onToken à medida que chega (Stream). Stream não
+// reenvia: os tokens já repassados não voltam atrás.
+type Generator interface {
+	Generate(ctx context.Context, req GenerateRequest) (string, error)
+	Stream(ctx context.Context, req GenerateRequest, onToken func(string)) error
+}
+
+// defaultGenerationProvider e generationOptions vêm do config
+// (generation_provider e a seção generation); main aplica config.Load por
+// cima do padrão
+var (
+	defaultGenerationProvider = "sidecar"
+	generationOptions         = map[string]config.GenerationOptions{}
+)
+
+const (
+	defaultOpenAIGenerationModel    = "gpt-4o-mini"
+	defaultOllamaGenerationModel    = "llama3"
+	defaultAnthropicURL             = "https://api.anthropic.com"
+	defaultAnthropicGenerationModel = "claude-3-5-haiku-latest"
+	anthropicVersion                = "2023-06-01"
+	// defaultMaxAnswerTokens é o max_tokens do sidecar, obrigatório na Anthropic
+	defaultMaxAnswerTokens = 1024
+	// defaultPromptTemplate é o mesmo do llm_engine.py
+	defaultPromptTemplate = "Contexto: {context}\n\nPergunta: {question}\nResposta:"
+)
+
+// generationRequest monta o pedido do provedor escolhido (vazio = o do
+// config), com as opções dele. O modelo do override vence o do config, e os
+// guardrails da implantação vão na frente do template (ver guardrails).
+func generationRequest(query, contextText string, override generationOverride, promptTemplate string) GenerateRequest {
+	provider := cmp.Or(override.Provider, defaultGenerationProvider)
+	opts := generationOptions[provider]
+	return GenerateRequest{
+		Query:          query,
+		Context:        contextText,
+		Provider:       provider,
+		Model:          cmp.Or(override.Model, opts.Model),
+		PromptTemplate: promptGuardrails.compose(promptTemplate),
+		Temperature:    opts.Temperature,
+		MaxTokens:      opts.MaxTokens,
+	}
+}
+
+// generatorFor monta o Generator do provedor. Sem url no config, a base vem
+// de ALANA_OPENAI_URL (qualquer API compatível com a da OpenAI),
+// ALANA_OLLAMA_URL, ALANA_ANTHROPIC_URL ou do sidecar.
+func generatorFor(provider string) (Generator, error) {
+	url := generationOptions[provider].URL
+	switch provider {
+	case "sidecar":
+		return &sidecarGenerator{url: cmp.Or(url, sidecarURL)}, nil
+	case "openai":
+		return &openAIGenerator{
+			url:    cmp.Or(url, envOr("ALANA_OPENAI_URL", defaultOpenAIURL)),
+			apiKey: cmp.Or(os.Getenv("ALANA_OPENAI_API_KEY"), os.Getenv("OPENAI_API_KEY")),
+		}, nil
+	case "ollama":
+		return &ollamaGenerator{url: cmp.Or(url, envOr("ALANA_OLLAMA_URL", defaultOllamaURL))}, nil
+	case "anthropic":
+		return &anthropicGenerator{
+			url:    cmp.Or(url, envOr("ALANA_ANTHROPIC_URL", defaultAnthropicURL)),
+			apiKey: cmp.Or(os.Getenv("ALANA_ANTHROPIC_API_KEY"), os.Getenv("ANTHROPIC_API_KEY")),
+		}, nil
+	}
+	return nil, fmt.Errorf("provedor de geração %q desconhecido (use %s)", provider, strings.Join(config.GenerationProviders, ", "))
+}
+
+// buildPrompt substitui {context} e {question} numa única passada, como o
+// build_prompt do sidecar: chaves no contexto ou na pergunta ficam intactas.
+// Marcadores ausentes no template são acrescentados no fim.
+func buildPrompt(query, contextText, template string) string {
+	template = cmp.Or(template, defaultPromptTemplate)
+	if !strings.Contains(template, "{context}") {
+		template += "\n\nContexto: {context}"
+	}
+	if !strings.Contains(template, "{question}") {
+		template += "\n\nPergunta: {question}"
+	}
+	return strings.NewReplacer("{context}", contextText, "{question}", query).Replace(template)
+}
+
+// chatMessages é a conversa de uma só mensagem enviada às APIs de chat
+func chatMessages(req GenerateRequest) []map[string]string {
+	return []map[string]string{{"role": "user", "content": buildPrompt(req.Query, req.Context, req.PromptTemplate)}}
+}
+
+// ==============================
+// Sidecar
+// ==============================
+
+// sidecarGenerator chama /generate e /generate/stream do bridge.py, que
+// monta o prompt com o template
+type sidecarGenerator struct {
+	url string
+}
+
+func (s *sidecarGenerator) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	var out GenerateResponse
+	if err := postGeneration(ctx, s.url+"/generate", sidecarHeader(), req, &out); err != nil {
+		return "", err
+	}
+	return out.Answer, nil
+}
+
+// sidecarHeader anuncia a versão do contrato que o Go entende (ver
+// sidecarproto): sidecars mais novos respondem no formato dela
+func sidecarHeader() http.Header {
+	h := http.Header{}
+	sidecarproto.Offer(h)
+	return h
+}
+
+// streamChunk é uma linha NDJSON de /generate/stream
+type streamChunk = sidecarproto.StreamChunk
+
+func (s *sidecarGenerator) Stream(ctx context.Context, req GenerateRequest, onToken func(string)) error {
+	body, err := openGenerationStream(ctx, s.url+"/generate/stream", sidecarHeader(), req)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	dec := json.NewDecoder(body)
+	for {
+		var chunk streamChunk
+		if err := dec.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if chunk.Done {
+			return nil
+		}
+		onToken(chunk.Token)
+	}
+}
+
+// ==============================
+// OpenAI (e compatíveis)
+// ==============================
+
+// openAIGenerator chama POST {url}/chat/completions da OpenAI (ou de um
+// servidor compatível: vLLM, LiteLLM, LocalAI...)
+type openAIGenerator struct {
+	url    string
+	apiKey string
+}
+
+func (o *openAIGenerator) request(req GenerateRequest, stream bool) (http.Header, map[string]any) {
+	header := http.Header{}
+	if o.apiKey != "" {
+		header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+	body := map[string]any{
+		"model":    cmp.Or(req.Model, defaultOpenAIGenerationModel),
+		"messages": chatMessages(req),
+		"stream":   stream,
+	}
+	if req.Temperature != nil {
+		body["temperature"] = *req.Temperature
+	}
+	if req.MaxTokens > 0 {
+		body["max_tokens"] = req.MaxTokens
+	}
+	return header, body
+}
+
+func (o *openAIGenerator) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	header, body := o.request(req, false)
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := postGeneration(ctx, o.url+"/chat/completions", header, body, &out); err != nil {
+		return "", err
+	}
+	if len(out.Choices) == 0 {
+		return "", errors.New("generate error: resposta sem choices")
+	}
+	return strings.TrimSpace(out.Choices[0].Message.Content), nil
+}
+
+// Stream lê os eventos SSE "data: {...}" até "data: [DONE]"
+func (o *openAIGenerator) Stream(ctx context.Context, req GenerateRequest, onToken func(string)) error {
+	header, body := o.request(req, true)
+	stream, err := openGenerationStream(ctx, o.url+"/chat/completions", header, body)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	return readSSE(stream, func(_, data string) (bool, error) {
+		if data == "[DONE]" {
+			return true, nil
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, err
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			onToken(chunk.Choices[0].Delta.Content)
+		}
+		return false, nil
+	})
+}
+
+// ==============================
+// Ollama
+// ==============================
+
+// ollamaGenerator chama POST {url}/api/chat do Ollama
+type ollamaGenerator struct {
+	url string
+}
+
+// ollamaChatChunk é a resposta do /api/chat (uma linha NDJSON no streaming)
+type ollamaChatChunk struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done  bool   `json:"done"`
+	Error string `json:"error"`
+}
+
+func (o *ollamaGenerator) request(req GenerateRequest, stream bool) map[string]any {
+	options := map[string]any{}
+	if req.Temperature != nil {
+		options["temperature"] = *req.Temperature
+	}
+	if req.MaxTokens > 0 {
+		options["num_predict"] = req.MaxTokens
+	}
+	return map[string]any{
+		"model":    cmp.Or(req.Model, defaultOllamaGenerationModel),
+		"messages": chatMessages(req),
+		"stream":   stream,
+		"options":  options,
+	}
+}
+
+func (o *ollamaGenerator) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	var out ollamaChatChunk
+	if err := postGeneration(ctx, o.url+"/api/chat", http.Header{}, o.request(req, false), &out); err != nil {
+		return "", err
+	}
+	if out.Error != "" {
+		return "", fmt.Errorf("generate error: %s", out.Error)
+	}
+	return strings.TrimSpace(out.Message.Content), nil
+}
+
+func (o *ollamaGenerator) Stream(ctx context.Context, req GenerateRequest, onToken func(string)) error {
+	body, err := openGenerationStream(ctx, o.url+"/api/chat", http.Header{}, o.request(req, true))
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	dec := json.NewDecoder(body)
+	for {
+		var chunk ollamaChatChunk
+		if err := dec.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if chunk.Error != "" {
+			return fmt.Errorf("generate stream error: %s", chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			onToken(chunk.Message.Content)
+		}
+		if chunk.Done {
+			return nil
+		}
+	}
+}
+
+// ==============================
+// Anthropic
+// ==============================
+
+// anthropicGenerator chama POST {url}/v1/messages da Anthropic
+type anthropicGenerator struct {
+	url    string
+	apiKey string
+}
+
+func (a *anthropicGenerator) request(req GenerateRequest, stream bool) (http.Header, map[string]any) {
+	header := http.Header{}
+	header.Set("x-api-key", a.apiKey)
+	header.Set("anthropic-version", anthropicVersion)
+	body := map[string]any{
+		"model":      cmp.Or(req.Model, defaultAnthropicGenerationModel),
+		"messages":   chatMessages(req),
+		"max_tokens": cmp.Or(req.MaxTokens, defaultMaxAnswerTokens),
+		"stream":     stream,
+	}
+	if req.Temperature != nil {
+		// A Anthropic aceita temperatura só até 1
+		body["temperature"] = min(*req.Temperature, 1)
+	}
+	return header, body
+}
+
+func (a *anthropicGenerator) Generate(ctx context.Context, req GenerateRequest) (string, error) {
+	header, body := a.request(req, false)
+	var out struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := postGeneration(ctx, a.url+"/v1/messages", header, body, &out); err != nil {
+		return "", err
+	}
+	var answer strings.Builder
+	for _, block := range out.Content {
+		if block.Type == "text" {
+			answer.WriteString(block.Text)
+		}
+	}
+	return strings.TrimSpace(answer.String()), nil
+}
+
+// Stream lê os eventos SSE: o texto chega em content_block_delta e a
+// resposta termina em message_stop
+func (a *anthropicGenerator) Stream(ctx context.Context, req GenerateRequest, onToken func(string)) error {
+	header, body := a.request(req, true)
+	stream, err := openGenerationStream(ctx, a.url+"/v1/messages", header, body)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	return readSSE(stream, func(event, data string) (bool, error) {
+		switch event {
+		case "message_stop":
+			return true, nil
+		case "error":
+			return false, fmt.Errorf("generate stream error: %s", data)
+		case "content_block_delta":
+			var chunk struct {
+				Delta struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				} `json:"delta"`
+			}
+			if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+				return false, err
+			}
+			if chunk.Delta.Type == "text_delta" && chunk.Delta.Text != "" {
+				onToken(chunk.Delta.Text)
+			}
+		}
+		return false, nil
+	})
+}
+
+// ==============================
+// HTTP
+// ==============================
+
+// postGeneration faz o POST JSON de geração, com os reenvios do providerHTTP
+// e de withRetry, e decodifica a resposta em out
+func postGeneration(ctx context.Context, url string, header http.Header, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	_, err = withRetry(ctx, retries, "generate", func(ctx context.Context) (struct{}, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+		if err != nil {
+			return struct{}{}, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := providerHTTP.Do(req)
+		if err != nil {
+			return struct{}{}, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return struct{}{}, readStatusError("generate error", resp)
+		}
+		return struct{}{}, json.NewDecoder(resp.Body).Decode(out)
+	})
+	return err
+}
+
+// openGenerationStream faz o POST JSON de geração em streaming, sem
+// reenvio, e devolve o corpo da resposta. Cancelar ctx interrompe a geração.
+func openGenerationStream(ctx context.Context, url string, header http.Header, body any) (io.ReadCloser, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := providerHTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, readStatusError("generate stream error", resp)
+	}
+	return resp.Body, nil
+}
+
+// readSSE lê um stream de Server-Sent Events e chama fn com o tipo (event:)
+// e o conteúdo (data:) de cada evento, até fn devolver done ou erro. O fim do
+// corpo antes disso é um erro.
+func readSSE(r io.Reader, fn func(event, data string) (done bool, err error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 {
+				done, err := fn(event, strings.Join(data, "\n"))
+				if done || err != nil {
+					return err
+				}
+			}
+			event, data = "", nil
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
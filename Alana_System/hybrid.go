@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"alana_system/lexical"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Busca híbrida (vetorial + palavra-chave)
+// ==============================
+
+// rrfK é a constante da Reciprocal Rank Fusion: cada lista soma 1/(rrfK+rank)
+// ao trecho. 60 é o valor do artigo original e o padrão do Qdrant.
+const rrfK = 60
+
+// hasSparseVector diz se a collection tem o vetor esparso (lexical.VectorName)
+// gravado pela ingestão. Collections criadas antes da busca híbrida não têm;
+// nelas a busca continua só vetorial. Lido do Qdrant uma vez por collection.
+func (e *AlanaEngine) hasSparseVector(ctx context.Context) (bool, error) {
+	if v, ok := e.sparseVectors.Load(e.collection); ok {
+		return v.(bool), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	info, err := e.client.GetCollectionInfo(ctx, e.collection)
+	if err != nil {
+		return false, err
+	}
+	_, ok := info.GetConfig().GetParams().GetSparseVectorsConfig().GetMap()[lexical.VectorName]
+	e.sparseVectors.Store(e.collection, ok)
+	return ok, nil
+}
+
+// hybridVectors monta os vetores de um trecho para o upsert: o denso (sem
+// nome) e o esparso com os termos do texto
+func hybridVectors(dense []float32, text string) *qdrant.Vectors {
+	indices, values := lexical.Document(text)
+	return qdrant.NewVectorsMap(map[string]*qdrant.Vector{
+		"":                 qdrant.NewVectorDense(dense),
+		lexical.VectorName: qdrant.NewVectorSparse(indices, values),
+	})
+}
+
+// hybridSearch junta a busca vetorial (dense, já feita) com a busca por
+// palavra-chave no vetor esparso, combinando as duas listas por RRF. Os
+// trechos encontrados só pelas palavras-chave não passam pelo limiar de
+// similaridade: são justamente os que a busca vetorial não valoriza (siglas,
+// códigos, nomes próprios). O Score de cada resultado continua sendo a
+// similaridade vetorial, para que os cortes de abstenção valham como antes.
+func (e *AlanaEngine) hybridSearch(
+	ctx context.Context,
+	question string,
+	vector []float32,
+	dense []SearchResult,
+	limit uint64,
+	filter SearchFilter,
+) ([]SearchResult, error) {
+
+	lexicalHits, err := e.keywordSearch(ctx, question, limit, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	fused := map[string]float64{}
+	byID := map[string]SearchResult{}
+	for rank, r := range dense {
+		fused[r.ID] += 1 / float64(rrfK+rank+1)
+		byID[r.ID] = r
+	}
+	var missing []*qdrant.PointId
+	for rank, r := range lexicalHits {
+		fused[r.ID] += 1 / float64(rrfK+rank+1)
+		if _, ok := byID[r.ID]; !ok {
+			byID[r.ID] = r
+			missing = append(missing, qdrant.NewID(r.ID))
+		}
+	}
+
+	// Os trechos só lexicais chegam com o score esparso: troca pela
+	// similaridade vetorial
+	scores, err := e.scorePoints(ctx, vector, missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range missing {
+		r := byID[id.GetUuid()]
+		r.Score = scores[r.ID]
+		byID[r.ID] = r
+	}
+
+	results := make([]SearchResult, 0, len(byID))
+	for _, r := range byID {
+		results = append(results, r)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if fused[results[i].ID] != fused[results[j].ID] {
+			return fused[results[i].ID] > fused[results[j].ID]
+		}
+		return results[i].Score > results[j].Score
+	})
+	if uint64(len(results)) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// keywordSearch busca os trechos pelos termos da pergunta no vetor esparso.
+// O Qdrant aplica o IDF da collection sobre os pesos gravados na ingestão.
+func (e *AlanaEngine) keywordSearch(ctx context.Context, question string, limit uint64, filter SearchFilter) ([]SearchResult, error) {
+	indices, values := lexical.Query(question)
+	if len(indices) == 0 {
+		return nil, nil
+	}
+
+	req := &qdrant.QueryPoints{
+		CollectionName: e.collection,
+		Query:          qdrant.NewQuerySparse(indices, values),
+		Using:          qdrant.PtrOf(lexical.VectorName),
+		Filter:         filter.qdrantFilter(),
+		Limit:          &limit,
+		WithPayload:    qdrant.NewWithPayload(true),
+	}
+	points, err := withRetry(ctx, retries, "qdrant keyword query", func(ctx context.Context) ([]*qdrant.ScoredPoint, error) {
+		ctx, cancel := context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+		return e.client.Query(ctx, req)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("qdrant keyword query failed: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	results := make([]SearchResult, 0, len(points))
+	for _, p := range points {
+		results = append(results, resultFromPayload(p.GetId(), p.GetPayload(), p.GetScore()))
+	}
+	if err := e.loadTexts(ctx, results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
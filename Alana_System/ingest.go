@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"alana_system/chunker"
+	"alana_system/chunkid"
+	"alana_system/schema"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Ingestão programática de texto
+// ==============================
+
+var errEmptyDocument = errors.New("documento sem texto")
+
+// reservedPayloadKeys são os campos do payload controlados pela ingestão;
+// meta não pode sobrescrevê-los.
+var reservedPayloadKeys = []string{
+	"original_id", "page_number", "text", "file_name",
+	"schema_version", "staging", "ingest_version", "text_offloaded", "text_codec", "provenance",
+}
+
+type ChunkRequest struct {
+	Source string `json:"source"`
+	Text   string `json:"text"`
+	Model  string `json:"model,omitempty"`
+}
+
+type ChunkItem struct {
+	ChunkID string    `json:"chunk_id"`
+	Page    int       `json:"page_number"`
+	Text    string    `json:"text"`
+	Vector  []float32 `json:"vector"`
+}
+
+type ChunkResponse struct {
+	Chunks []ChunkItem `json:"chunks"`
+}
+
+// IngestText grava um texto cru (ticket de suporte, transcrição de chat...)
+// na base sem passar por data/raw. docID faz o papel do nome do arquivo:
+// identifica o documento nas fontes e uma nova chamada com o mesmo docID
+// substitui a versão anterior. meta entra no payload de todos os chunks
+// (ex: title, author, tags; acl restringe quem vê os trechos, ver
+// accessPolicy).
+//
+// A limpeza, o chunking e o embedding são os do sidecar (/chunk), iguais aos
+// do run_ingestion.py; a troca de versão segue o mesmo staging + commit do
+// orchestrator, então as buscas nunca veem o documento pela metade. O texto
+// fica sempre no payload, sem compressão nem armazenamento externo.
+func (e *AlanaEngine) IngestText(ctx context.Context, docID, text string, meta map[string]any) error {
+	if err := e.writable(); err != nil {
+		return err
+	}
+	if strings.TrimSpace(docID) == "" {
+		return errors.New("docID é obrigatório")
+	}
+	if strings.TrimSpace(text) == "" {
+		return errEmptyDocument
+	}
+	for _, key := range reservedPayloadKeys {
+		if _, ok := meta[key]; ok {
+			return fmt.Errorf("meta não pode definir o campo reservado %q", key)
+		}
+	}
+
+	chunks, err := chunkText(ctx, e.collections.embedding(e.collection), docID, text)
+	if err != nil {
+		return err
+	}
+	if len(chunks.Chunks) == 0 {
+		return errEmptyDocument
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	ids := make([]*qdrant.PointId, 0, len(chunks.Chunks))
+	for _, c := range chunks.Chunks {
+		ids = append(ids, qdrant.NewID(chunkid.PointID(c.ChunkID)))
+	}
+	// Chunks que já existem têm o mesmo conteúdo (o ID deriva dele): são
+	// regravados já publicados, para não sumirem das buscas até o commit
+	existing, err := e.client.Get(ctx, &qdrant.GetPoints{CollectionName: e.collection, Ids: ids})
+	if err != nil {
+		return fmt.Errorf("qdrant get failed: %w", err)
+	}
+	published := map[string]bool{}
+	for _, p := range existing {
+		published[p.GetId().GetUuid()] = true
+	}
+
+	// Collections com o vetor esparso recebem também os termos de cada chunk
+	// (busca híbrida, ver hybridSearch)
+	hybrid, err := e.hasSparseVector(ctx)
+	if err != nil {
+		return fmt.Errorf("qdrant collection info failed: %w", err)
+	}
+
+	version := strconv.FormatInt(time.Now().UnixNano(), 36)
+	points := make([]*qdrant.PointStruct, 0, len(chunks.Chunks))
+	for i, c := range chunks.Chunks {
+		fields := map[string]any{
+			"content_type": schema.ContentText,
+			"tags":         []any{},
+		}
+		for k, v := range meta {
+			fields[k] = v
+		}
+		fields["original_id"] = c.ChunkID
+		fields["page_number"] = c.Page
+		fields["text"] = c.Text
+		fields["file_name"] = docID
+		fields["schema_version"] = schema.Version
+		fields["staging"] = !published[ids[i].GetUuid()]
+		fields["ingest_version"] = version
+
+		payload, err := qdrant.TryValueMap(fields)
+		if err != nil {
+			return fmt.Errorf("invalid payload: %w", err)
+		}
+		vectors := qdrant.NewVectors(c.Vector...)
+		if hybrid {
+			vectors = hybridVectors(c.Vector, c.Text)
+		}
+		points = append(points, &qdrant.PointStruct{
+			Id:      ids[i],
+			Vectors: vectors,
+			Payload: payload,
+		})
+	}
+
+	_, err = e.client.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: e.collection,
+		Wait:           qdrant.PtrOf(true),
+		Points:         points,
+	})
+	if err != nil {
+		e.discardVersion(docID, version)
+		return fmt.Errorf("qdrant upsert failed: %w", err)
+	}
+
+	if err := e.commitVersion(ctx, docID, version); err != nil {
+		e.discardVersion(docID, version)
+		return err
+	}
+	return nil
+}
+
+// commitVersion publica a versão e remove os chunks das versões anteriores
+// do documento numa única chamada em lote (como o commitDocument do
+// orchestrator).
+func (e *AlanaEngine) commitVersion(ctx context.Context, fileName, version string) error {
+	if err := e.writable(); err != nil {
+		return err
+	}
+	current := &qdrant.Filter{
+		Must: []*qdrant.Condition{
+			qdrant.NewMatchKeyword("file_name", fileName),
+			qdrant.NewMatchKeyword("ingest_version", version),
+		},
+	}
+	stale := &qdrant.Filter{
+		Must:    []*qdrant.Condition{qdrant.NewMatchKeyword("file_name", fileName)},
+		MustNot: []*qdrant.Condition{qdrant.NewMatchKeyword("ingest_version", version)},
+	}
+
+	_, err := e.client.UpdateBatch(ctx, &qdrant.UpdateBatchPoints{
+		CollectionName: e.collection,
+		Wait:           qdrant.PtrOf(true),
+		Operations: []*qdrant.PointsUpdateOperation{
+			qdrant.NewPointsUpdateSetPayload(&qdrant.PointsUpdateOperation_SetPayload{
+				Payload:        qdrant.NewValueMap(map[string]any{"staging": false}),
+				PointsSelector: qdrant.NewPointsSelectorFilter(current),
+			}),
+			qdrant.NewPointsUpdateDeletePoints(&qdrant.PointsUpdateOperation_DeletePoints{
+				Points: qdrant.NewPointsSelectorFilter(stale),
+			}),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("qdrant commit failed: %w", err)
+	}
+	return nil
+}
+
+// discardVersion apaga os chunks em staging de uma ingestão que falhou. Usa
+// um contexto próprio: o do pedido pode ter sido a causa da falha.
+func (e *AlanaEngine) discardVersion(fileName, version string) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	_, err := e.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: e.collection,
+		Wait:           qdrant.PtrOf(true),
+		Points: qdrant.NewPointsSelectorFilter(&qdrant.Filter{
+			Must: []*qdrant.Condition{
+				qdrant.NewMatchKeyword("file_name", fileName),
+				qdrant.NewMatchKeyword("ingest_version", version),
+				qdrant.NewMatchBool("staging", true),
+			},
+		}),
+	})
+	if err != nil {
+		engineLog.WarnContext(ctx, "Falha ao descartar a ingestão", "version", version, "file", fileName, "err", err)
+	}
+}
+
+// chunkText chama o endpoint /chunk do sidecar da collection, que vetoriza
+// com o mesmo modelo usado nas perguntas. Com outro provedor de embedding, a
+// limpeza e o chunking são os do pacote chunker (os mesmos chunks e IDs do
+// Python) e cada chunk é vetorizado pelo Embedder da collection.
+func chunkText(ctx context.Context, emb collectionEmbedding, source, text string) (ChunkResponse, error) {
+	if emb.Provider != "sidecar" {
+		return chunkTextLocal(ctx, emb.embedder(), source, text)
+	}
+
+	body, err := json.Marshal(ChunkRequest{Source: source, Text: text, Model: emb.Model})
+	if err != nil {
+		return ChunkResponse{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, emb.URL+"/chunk", bytes.NewBuffer(body))
+	if err != nil {
+		return ChunkResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := providerHTTP.Do(req)
+	if err != nil {
+		return ChunkResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		return ChunkResponse{}, fmt.Errorf("chunk error: %s", string(raw))
+	}
+
+	var out ChunkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return ChunkResponse{}, err
+	}
+	return out, nil
+}
+
+// chunkTextLocal é o chunkText sem o sidecar
+func chunkTextLocal(ctx context.Context, embedder Embedder, source, text string) (ChunkResponse, error) {
+	chunks, err := chunker.Split([]chunker.Page{{Number: 1, Text: chunker.Clean(text)}}, source, chunker.DefaultOptions())
+	if err != nil {
+		return ChunkResponse{}, err
+	}
+
+	out := ChunkResponse{Chunks: make([]ChunkItem, 0, len(chunks))}
+	for _, c := range chunks {
+		vector, err := embedder.Embed(ctx, c.Text, "")
+		if err != nil {
+			return ChunkResponse{}, fmt.Errorf("chunk %s: %w", c.ID, err)
+		}
+		out.Chunks = append(out.Chunks, ChunkItem{ChunkID: c.ID, Page: c.Page, Text: c.Text, Vector: vector})
+	}
+	return out, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ==============================
+// Logs JSONL
+// ==============================
+
+// jsonlLog acrescenta registros JSON, um por linha, a um arquivo; é seguro
+// para uso concorrente. Com maxBytes > 0, o arquivo é rotacionado para
+// "<path>.1" quando passa do limite (só a geração anterior é mantida).
+type jsonlLog struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+}
+
+func newJSONLLog(path string) *jsonlLog {
+	return &jsonlLog{path: path}
+}
+
+func (l *jsonlLog) append(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	if err := l.rotate(int64(len(line) + 1)); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// rotate move o arquivo atual para "<path>.1" se a próxima linha estourar o limite
+func (l *jsonlLog) rotate(next int64) error {
+	if l.maxBytes <= 0 {
+		return nil
+	}
+	info, err := os.Stat(l.path)
+	if err != nil || info.Size()+next <= l.maxBytes {
+		return nil
+	}
+	return os.Rename(l.path, l.path+".1")
+}
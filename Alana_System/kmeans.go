@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+)
+
+// ==============================
+// K-means (cosseno)
+// ==============================
+
+// kMeans agrupa os vetores por similaridade de cosseno. A inicialização é
+// k-means++ com semente fixa, para que o mesmo corpus gere os mesmos tópicos.
+// Devolve o cluster de cada vetor e os centróides (normalizados).
+func kMeans(vectors [][]float32, k, maxIter int, seed int64) ([]int, [][]float32) {
+	if len(vectors) == 0 || k <= 0 {
+		return nil, nil
+	}
+	if k > len(vectors) {
+		k = len(vectors)
+	}
+
+	points := make([][]float32, len(vectors))
+	for i, v := range vectors {
+		points[i] = normalized(v)
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	centroids := initCentroids(points, k, rng)
+	assign := make([]int, len(points))
+	for i := range assign {
+		assign[i] = -1
+	}
+
+	for iter := 0; iter < maxIter; iter++ {
+		changed := false
+		for i, p := range points {
+			best, bestSim := 0, float32(-2)
+			for c, centroid := range centroids {
+				if sim := dot(p, centroid); sim > bestSim {
+					best, bestSim = c, sim
+				}
+			}
+			if assign[i] != best {
+				assign[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		dim := len(points[0])
+		sums := make([][]float32, k)
+		counts := make([]int, k)
+		for c := range sums {
+			sums[c] = make([]float32, dim)
+		}
+		for i, p := range points {
+			c := assign[i]
+			counts[c]++
+			for d, x := range p {
+				sums[c][d] += x
+			}
+		}
+		for c := range centroids {
+			// Cluster vazio mantém o centróide anterior
+			if counts[c] > 0 {
+				centroids[c] = normalized(sums[c])
+			}
+		}
+	}
+
+	return assign, centroids
+}
+
+// initCentroids escolhe os centróides iniciais pelo critério do k-means++
+func initCentroids(points [][]float32, k int, rng *rand.Rand) [][]float32 {
+	centroids := make([][]float32, 0, k)
+	centroids = append(centroids, points[rng.Intn(len(points))])
+
+	dist := make([]float64, len(points))
+	for len(centroids) < k {
+		var total float64
+		for i, p := range points {
+			nearest := math.MaxFloat64
+			for _, c := range centroids {
+				if d := 1 - float64(dot(p, c)); d < nearest {
+					nearest = d
+				}
+			}
+			dist[i] = nearest * nearest
+			total += dist[i]
+		}
+
+		// Todos os pontos coincidem com algum centróide: não há o que espalhar
+		if total == 0 {
+			centroids = append(centroids, points[rng.Intn(len(points))])
+			continue
+		}
+
+		target := rng.Float64() * total
+		chosen := len(points) - 1
+		for i, d := range dist {
+			target -= d
+			if target <= 0 {
+				chosen = i
+				break
+			}
+		}
+		centroids = append(centroids, points[chosen])
+	}
+
+	return centroids
+}
+
+func dot(a, b []float32) float32 {
+	var s float32
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
+
+func normalized(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	out := make([]float32, len(v))
+	if norm == 0 {
+		return out
+	}
+	inv := float32(1 / math.Sqrt(norm))
+	for i, x := range v {
+		out[i] = x * inv
+	}
+	return out
+}
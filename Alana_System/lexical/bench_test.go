@@ -0,0 +1,23 @@
+package lexical
+
+import (
+	"strings"
+	"testing"
+)
+
+// chunkText é um trecho típico da ingestão (~800 caracteres, com acentos)
+var chunkText = strings.Repeat("A política de reembolso cobre produtos com defeito em até 30 dias; ", 12)
+
+func BenchmarkDocument(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		Document(chunkText)
+	}
+}
+
+func BenchmarkQuery(b *testing.B) {
+	b.ReportAllocs()
+	for b.Loop() {
+		Query("Qual é o prazo de reembolso de produtos com defeito?")
+	}
+}
@@ -0,0 +1,115 @@
+// Package lexical gera os vetores esparsos da busca por palavra-chave (BM25)
+// gravados ao lado do vetor denso no Qdrant. O Qdrant guarda o vetor com o
+// modificador IDF, então aqui entra só a parte de frequência do BM25; a
+// raridade de cada termo na collection é calculada por ele na busca.
+//
+// A ingestão em Python gera os mesmos vetores
+// (src/alana_system/memory/sparse.py): tokenização, hash e pesos precisam
+// continuar idênticos nos dois lados, senão a busca não encontra os termos.
+package lexical
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// VectorName é o nome do vetor esparso na collection
+const VectorName = "bm25"
+
+// Parâmetros do BM25. avgDocTokens é fixo (o tamanho médio dos chunks é
+// estável) para que o peso de um chunk não dependa do resto da collection.
+const (
+	k1           = 1.2
+	b            = 0.75
+	avgDocTokens = 256
+)
+
+// Tokens quebra o texto em termos: sequências de letras e dígitos, em
+// minúsculas. Todo o resto separa termos.
+func Tokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), isSeparator)
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// terms percorre os mesmos termos de Tokens sem montar a lista
+func terms(text string) iter.Seq[string] {
+	lower := strings.ToLower(text)
+	return func(yield func(string) bool) {
+		start := -1
+		for i, r := range lower {
+			if !isSeparator(r) {
+				if start < 0 {
+					start = i
+				}
+				continue
+			}
+			if start >= 0 {
+				if !yield(lower[start:i]) {
+					return
+				}
+				start = -1
+			}
+		}
+		if start >= 0 {
+			yield(lower[start:])
+		}
+	}
+}
+
+// FNV-1a de 32 bits, calculado direto sobre a string (o hash/fnv alocaria um
+// hash e uma cópia do termo a cada chamada)
+const (
+	fnvOffset32 = 2166136261
+	fnvPrime32  = 16777619
+)
+
+// Index é a posição do termo no vetor esparso (FNV-1a de 32 bits)
+func Index(term string) uint32 {
+	h := uint32(fnvOffset32)
+	for i := 0; i < len(term); i++ {
+		h ^= uint32(term[i])
+		h *= fnvPrime32
+	}
+	return h
+}
+
+// Document devolve o vetor de um trecho: para cada termo, a parte de
+// frequência do BM25. Os índices saem em ordem crescente.
+func Document(text string) ([]uint32, []float32) {
+	tf := map[uint32]float64{}
+	count := 0
+	for t := range terms(text) {
+		tf[Index(t)]++
+		count++
+	}
+	norm := k1 * (1 - b + b*float64(count)/avgDocTokens)
+
+	indices := slices.Sorted(maps.Keys(tf))
+	values := make([]float32, len(indices))
+	for i, idx := range indices {
+		f := tf[idx]
+		values[i] = float32(f * (k1 + 1) / (f + norm))
+	}
+	return indices, values
+}
+
+// Query devolve o vetor de uma pergunta: peso 1 para cada termo distinto
+// (termos repetidos na pergunta não contam mais)
+func Query(text string) ([]uint32, []float32) {
+	seen := map[uint32]bool{}
+	for t := range terms(text) {
+		seen[Index(t)] = true
+	}
+	indices := slices.Sorted(maps.Keys(seen))
+	values := make([]float32, len(indices))
+	for i := range values {
+		values[i] = 1
+	}
+	return indices, values
+}
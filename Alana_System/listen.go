@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ==============================
+// Listener do serve
+// ==============================
+
+// systemdFirstFD é o primeiro descritor passado pelo systemd (SD_LISTEN_FDS_START)
+const systemdFirstFD = 3
+
+// listen abre o listener do `alana serve` a partir do -addr:
+//
+//	127.0.0.1:8080        TCP
+//	unix:/run/alana.sock  socket Unix, com as permissões de mode
+//	systemd               socket recebido por ativação do systemd (.socket)
+//
+// Com socket Unix ou systemd nenhuma porta TCP é aberta; o proxy reverso
+// local fala direto com o socket.
+func listen(addr string, mode fs.FileMode) (net.Listener, error) {
+	if addr == "systemd" {
+		return systemdListener()
+	}
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+
+	// Socket de uma execução anterior que não saiu limpa
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s existe e não é um socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s já está em uso por outro processo", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// systemdListener usa o socket passado pelo systemd (LISTEN_PID/LISTEN_FDS).
+// As variáveis são apagadas para não vazarem para os processos filhos
+// (whisper.cpp, piper).
+func systemdListener() (net.Listener, error) {
+	pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	fds, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	if pid != os.Getpid() || fds < 1 {
+		return nil, errors.New("nenhum socket recebido do systemd (LISTEN_PID/LISTEN_FDS ausentes)")
+	}
+	if fds > 1 {
+		return nil, fmt.Errorf("o systemd passou %d sockets; configure só um ListenStream", fds)
+	}
+
+	f := os.NewFile(systemdFirstFD, "systemd-socket")
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("socket do systemd: %w", err)
+	}
+	return ln, nil
+}
+
+// listenURL descreve o listener para o log de início
+func listenURL(ln net.Listener) string {
+	if ln.Addr().Network() == "unix" {
+		return "unix:" + ln.Addr().String()
+	}
+	return "http://" + ln.Addr().String()
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"alana_system/lexical"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ==============================
+// Índice BM25 local (Qdrant fora do ar)
+// ==============================
+
+// defaultLocalIndexRefresh é de quanto em quanto tempo o serve reconstrói o
+// índice local a partir do Qdrant
+const defaultLocalIndexRefresh = time.Hour
+
+// Parâmetros do BM25 local. Aqui o IDF é calculado sobre o índice inteiro,
+// diferente do vetor esparso (ver lexical), em que o Qdrant calcula.
+const (
+	localK1 = 1.2
+	localB  = 0.75
+)
+
+// localChunk é um trecho guardado no índice local: o texto completo (mesmo
+// os que ficam no text store) e os metadados dos filtros
+type localChunk struct {
+	ID          string            `json:"id"`
+	Source      string            `json:"file_name"`
+	Title       string            `json:"title,omitempty"`
+	Page        int               `json:"page_number"`
+	Text        string            `json:"text"`
+	ContentType string            `json:"content_type,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	CreatedAt   string            `json:"created_at,omitempty"`
+	CreatedTS   float64           `json:"created_ts,omitempty"`
+	Fields      map[string]string `json:"fields,omitempty"`
+	ACL         []string          `json:"acl,omitempty"`
+}
+
+// localIndexFile é o formato do arquivo (JSON com gzip)
+type localIndexFile struct {
+	Collection string       `json:"collection"`
+	BuiltAt    time.Time    `json:"built_at"`
+	Chunks     []localChunk `json:"chunks"`
+}
+
+type localPosting struct {
+	chunk int32
+	tf    float32
+}
+
+// localIndex é um índice BM25 em memória dos trechos de uma collection. Ele
+// atende a busca só por palavra-chave quando o Qdrant está fora do ar: as
+// respostas saem piores, mas saem, marcadas como degraded. É imutável depois
+// de montado; a reconstrução troca o índice inteiro.
+type localIndex struct {
+	localIndexFile
+	postings map[string][]localPosting
+	lengths  []int
+	avgLen   float64
+}
+
+// newLocalIndex monta as listas invertidas dos trechos
+func newLocalIndex(f localIndexFile) *localIndex {
+	idx := &localIndex{localIndexFile: f, postings: map[string][]localPosting{}, lengths: make([]int, len(f.Chunks))}
+	total := 0
+	for i, c := range f.Chunks {
+		tf := map[string]float32{}
+		for _, t := range lexical.Tokens(c.Text) {
+			tf[t]++
+			idx.lengths[i]++
+		}
+		total += idx.lengths[i]
+		for t, n := range tf {
+			idx.postings[t] = append(idx.postings[t], localPosting{chunk: int32(i), tf: n})
+		}
+	}
+	if len(f.Chunks) > 0 {
+		idx.avgLen = float64(total) / float64(len(f.Chunks))
+	}
+	return idx
+}
+
+// search devolve os topK trechos com maior BM25 que passam pelo filtro. O
+// Score é o BM25 (não uma similaridade de 0 a 1).
+func (idx *localIndex) search(question string, topK uint64, filter SearchFilter) []SearchResult {
+	n := float64(len(idx.Chunks))
+	scores := map[int32]float64{}
+	seen := map[string]bool{}
+	for _, t := range lexical.Tokens(question) {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		postings := idx.postings[t]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, p := range postings {
+			tf := float64(p.tf)
+			norm := localK1 * (1 - localB + localB*float64(idx.lengths[p.chunk])/idx.avgLen)
+			scores[p.chunk] += idf * tf * (localK1 + 1) / (tf + norm)
+		}
+	}
+
+	hits := make([]int32, 0, len(scores))
+	for i := range scores {
+		if filter.matchesLocal(idx.Chunks[i]) {
+			hits = append(hits, i)
+		}
+	}
+	slices.SortFunc(hits, func(a, b int32) int {
+		if scores[a] != scores[b] {
+			if scores[a] > scores[b] {
+				return -1
+			}
+			return 1
+		}
+		return int(a - b)
+	})
+	if uint64(len(hits)) > topK {
+		hits = hits[:topK]
+	}
+
+	results := make([]SearchResult, 0, len(hits))
+	for _, i := range hits {
+		c := idx.Chunks[i]
+		results = append(results, SearchResult{
+			ID:          c.ID,
+			Source:      c.Source,
+			Title:       c.Title,
+			Text:        c.Text,
+			Page:        c.Page,
+			Score:       float32(scores[i]),
+			ContentType: c.ContentType,
+			Tags:        c.Tags,
+			CreatedAt:   c.CreatedAt,
+			ACL:         c.ACL,
+			Degraded:    true,
+		})
+	}
+	return results
+}
+
+// matchesLocal aplica o filtro a um trecho do índice local, com a mesma
+// semântica do qdrantFilter
+func (f SearchFilter) matchesLocal(c localChunk) bool {
+	if len(f.Sources) > 0 && !slices.Contains(f.Sources, c.Source) {
+		return false
+	}
+	if slices.Contains(f.Exclude, c.Source) {
+		return false
+	}
+	if len(f.ContentTypes) > 0 && !slices.Contains(f.ContentTypes, c.ContentType) {
+		return false
+	}
+	if len(f.Tags) > 0 && !slices.ContainsFunc(c.Tags, func(t string) bool { return slices.Contains(f.Tags, t) }) {
+		return false
+	}
+	if !f.After.IsZero() || !f.Before.IsZero() {
+		if c.CreatedTS == 0 {
+			return false
+		}
+		if !f.After.IsZero() && c.CreatedTS < float64(f.After.Unix()) {
+			return false
+		}
+		if !f.Before.IsZero() && c.CreatedTS >= float64(f.Before.Unix()) {
+			return false
+		}
+	}
+	if (f.MinPage > 0 && c.Page < f.MinPage) || (f.MaxPage > 0 && c.Page > f.MaxPage) {
+		return false
+	}
+	for key, values := range f.Fields {
+		if len(values) > 0 && !slices.Contains(values, c.Fields[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// loadLocalIndex lê o índice salvo em path
+func loadLocalIndex(path string) (*localIndex, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	var f localIndexFile
+	if err := json.NewDecoder(gz).Decode(&f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return newLocalIndex(f), nil
+}
+
+// save grava o índice em path (num arquivo temporário renomeado no fim, para
+// que uma gravação interrompida não estrague o índice anterior)
+func (idx *localIndex) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	gz := gzip.NewWriter(tmp)
+	err = json.NewEncoder(gz).Encode(idx.localIndexFile)
+	err = errors.Join(err, gz.Close(), tmp.Close())
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// buildLocalIndex lê todos os trechos visíveis da collection (com o texto
+// do text store, se houver)
+func (e *AlanaEngine) buildLocalIndex(ctx context.Context) (*localIndex, error) {
+	f := localIndexFile{Collection: e.collection, BuiltAt: time.Now().UTC()}
+	err := e.scrollPoints(ctx, false, func(points []*qdrant.RetrievedPoint) error {
+		page := make([]SearchResult, len(points))
+		for i, p := range points {
+			page[i] = resultFromPayload(p.GetId(), p.GetPayload(), 0)
+		}
+		textCtx, cancel := context.WithTimeout(ctx, e.timeout)
+		err := e.loadTexts(textCtx, page)
+		cancel()
+		if err != nil {
+			return err
+		}
+		for i, p := range points {
+			r, payload := page[i], p.GetPayload()
+			c := localChunk{
+				ID: r.ID, Source: r.Source, Title: r.Title, Page: r.Page, Text: r.Text,
+				ContentType: r.ContentType, Tags: r.Tags, CreatedAt: r.CreatedAt, ACL: r.ACL,
+				CreatedTS: payload["created_ts"].GetDoubleValue(),
+			}
+			if ts, ok := payload["created_ts"].GetKind().(*qdrant.Value_IntegerValue); ok {
+				c.CreatedTS = float64(ts.IntegerValue)
+			}
+			for _, key := range connectorFields {
+				if v := payload[key].GetStringValue(); v != "" {
+					if c.Fields == nil {
+						c.Fields = map[string]string{}
+					}
+					c.Fields[key] = v
+				}
+			}
+			f.Chunks = append(f.Chunks, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newLocalIndex(f), nil
+}
+
+// localFallback guarda o índice local usado quando o Qdrant cai. O serve o
+// carrega do disco na partida (para funcionar mesmo se o Qdrant já estiver
+// fora do ar) e o reconstrói a cada refresh enquanto o Qdrant responde.
+type localFallback struct {
+	path    string
+	refresh time.Duration
+	index   atomic.Pointer[localIndex]
+}
+
+// localFallbackFromEnv lê a configuração do índice local. Devolve nil se
+// ALANA_LOCAL_INDEX estiver vazio.
+//
+//	ALANA_LOCAL_INDEX           arquivo do índice (ex: data/local_index.json.gz)
+//	ALANA_LOCAL_INDEX_REFRESH   intervalo de reconstrução (padrão 1h)
+func localFallbackFromEnv() (*localFallback, error) {
+	path := os.Getenv("ALANA_LOCAL_INDEX")
+	if path == "" {
+		return nil, nil
+	}
+	l := &localFallback{path: path, refresh: defaultLocalIndexRefresh}
+	if raw := os.Getenv("ALANA_LOCAL_INDEX_REFRESH"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("ALANA_LOCAL_INDEX_REFRESH inválido (%q)", raw)
+		}
+		l.refresh = d
+	}
+	return l, nil
+}
+
+// load lê o índice salvo, se houver e for da collection do engine
+func (l *localFallback) load(collection string) {
+	idx, err := loadLocalIndex(l.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return
+	case err != nil:
+		engineLog.Warn("Índice local ilegível, será reconstruído", "err", err)
+		return
+	case idx.Collection != collection:
+		engineLog.Warn("Índice local é de outra collection; será reconstruído", "index_collection", idx.Collection, "collection", collection)
+		return
+	}
+	l.index.Store(idx)
+	engineLog.Info("Índice local carregado", "chunks", len(idx.Chunks), "built_at", idx.BuiltAt)
+}
+
+// run reconstrói o índice agora, se o salvo tiver mais de um refresh, e
+// depois a cada refresh, até ctx ser cancelado
+func (l *localFallback) run(ctx context.Context, engine *AlanaEngine) {
+	wait := time.Duration(0)
+	if idx := l.index.Load(); idx != nil {
+		wait = max(l.refresh-time.Since(idx.BuiltAt), 0)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = l.refresh
+
+		start := time.Now()
+		idx, err := engine.buildLocalIndex(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				engineLog.Warn("Falha ao reconstruir o índice local (mantendo o anterior)", "err", err)
+			}
+			continue
+		}
+		l.index.Store(idx)
+		if err := idx.save(l.path); err != nil {
+			engineLog.Warn("Falha ao salvar o índice local", "err", err)
+		}
+		engineLog.Info("Índice local reconstruído", "chunks", len(idx.Chunks), "elapsed", time.Since(start))
+	}
+}
+
+// qdrantUnavailable diz se err é o Qdrant fora do ar (sem conexão ou sem
+// resposta no prazo), o caso em que vale cair no índice local
+func qdrantUnavailable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	s, ok := status.FromError(err)
+	return ok && (s.Code() == codes.Unavailable || s.Code() == codes.DeadlineExceeded)
+}
+
+// retrieveLocal é o retrieve no índice local: só palavra-chave, sem
+// score_threshold (o BM25 não é uma similaridade) nem busca híbrida. O
+// re-ranking e os documentos fixados continuam valendo.
+func (e *AlanaEngine) retrieveLocal(ctx context.Context, idx *localIndex, question string, opts askOptions) ([]SearchResult, error) {
+	candidates := opts.TopK
+	if opts.Rerank {
+		candidates *= rerankCandidates
+	}
+	results := idx.search(question, candidates, opts.Filter)
+	e.recordUsage(usageRetrieved, results)
+
+	var err error
+	if opts.Rerank {
+		results, err = rerankResults(ctx, e.reranker, question, results, opts.TopK, opts.Cutoffs.Rerank)
+		if err != nil {
+			return nil, fmt.Errorf("rerank: %w", err)
+		}
+	}
+	if len(opts.Pin) > 0 {
+		filter := opts.Filter
+		filter.Sources, filter.Exclude = opts.Pin, nil
+		pinned := idx.search(question, opts.TopK, filter)
+		seen := make(map[string]bool, len(pinned))
+		for i := range pinned {
+			pinned[i].Pinned = true
+			seen[pinned[i].ID] = true
+		}
+		for _, r := range results {
+			if !seen[r.ID] {
+				pinned = append(pinned, r)
+			}
+		}
+		results = pinned
+	}
+	return results, nil
+}
+
+// runLocalIndex implementa `alana local-index [build]`: reconstrói o
+// índice local do fallback (ALANA_LOCAL_INDEX) a partir do Qdrant, como o
+// serve faz a cada ALANA_LOCAL_INDEX_REFRESH
+func runLocalIndex(ctx context.Context, engine *AlanaEngine, args []string) error {
+	if len(args) > 1 || (len(args) == 1 && args[0] != "build") {
+		return errors.New("uso: alana local-index [build]")
+	}
+	path := os.Getenv("ALANA_LOCAL_INDEX")
+	if path == "" {
+		return errors.New("defina ALANA_LOCAL_INDEX com o arquivo do índice local")
+	}
+	start := time.Now()
+	idx, err := engine.buildLocalIndex(ctx)
+	if err != nil {
+		return err
+	}
+	if err := idx.save(path); err != nil {
+		return err
+	}
+	cliLog.Info("Índice local gravado", "collection", engine.collection, "chunks", len(idx.Chunks), "terms", len(idx.postings),
+		"file", path, "elapsed", time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,165 @@
+// Package logging configura o log estruturado (log/slog) do serve, da linha
+// de comando e do orchestrator: nível e formato escolhidos na inicialização
+// (-log-level, -log-format ou ALANA_LOG_LEVEL, ALANA_LOG_FORMAT), um logger
+// por componente (engine, server, sidecar, ingestor...) e o ID do pedido
+// HTTP em cada linha registrada com o contexto do pedido.
+//
+// Os formatos são "pretty" (padrão, uma linha legível por evento, com os
+// ícones de sempre) e "json" (uma linha JSON por evento, para agregadores).
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// FormatPretty é o formato legível (padrão)
+	FormatPretty = "pretty"
+	// FormatJSON é uma linha JSON por evento
+	FormatJSON = "json"
+)
+
+// Options configuram o log; os campos vazios usam os padrões (info,
+// pretty, saída de erro)
+type Options struct {
+	Level  string
+	Format string
+	// Output recebe as linhas (ex: o orchestrator passa um writer que não
+	// atropela a barra de progresso)
+	Output io.Writer
+}
+
+var (
+	level slog.LevelVar
+	// current é o handler configurado; os loggers dos componentes o
+	// consultam a cada evento, então podem ser criados antes do Setup
+	current atomic.Pointer[slog.Handler]
+)
+
+func init() {
+	var h slog.Handler = newPrettyHandler(os.Stderr, &level)
+	current.Store(&h)
+	slog.SetDefault(slog.New(forwardHandler{}))
+}
+
+// Setup aplica o nível e o formato. Também vale para o slog e o log padrão:
+// o que ainda usar log.Printf sai no mesmo formato, no nível info.
+func Setup(opts Options) error {
+	lvl, err := ParseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	out := opts.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	var h slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", FormatPretty:
+		h = newPrettyHandler(out, &level)
+	case FormatJSON:
+		h = slog.NewJSONHandler(out, &slog.HandlerOptions{Level: &level})
+	default:
+		return fmt.Errorf("formato de log desconhecido %q (use %s ou %s)", opts.Format, FormatPretty, FormatJSON)
+	}
+	level.Set(lvl)
+	current.Store(&h)
+	return nil
+}
+
+// ParseLevel lê debug, info, warn ou error (vazio = info)
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("nível de log desconhecido %q (use debug, info, warn ou error)", s)
+}
+
+// For devolve o logger de um componente: cada linha leva component=<nome>
+func For(component string) *slog.Logger {
+	return slog.New(forwardHandler{}).With("component", component)
+}
+
+// Fatal registra o erro e encerra o processo com status 1
+func Fatal(l *slog.Logger, msg string, args ...any) {
+	l.Error(msg, args...)
+	os.Exit(1)
+}
+
+// ==============================
+// ID do pedido
+// ==============================
+
+type requestIDKey struct{}
+
+// WithRequestID guarda o ID do pedido no contexto; as linhas registradas
+// com esse contexto (InfoContext, ErrorContext...) levam request_id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID é o ID do pedido guardado no contexto (vazio = nenhum)
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// ==============================
+// Handler de encaminhamento
+// ==============================
+
+// forwardHandler aplica os atributos e grupos do logger sobre o handler
+// configurado no momento do evento
+type forwardHandler struct {
+	ops []func(slog.Handler) slog.Handler
+}
+
+func (f forwardHandler) handler() slog.Handler {
+	h := *current.Load()
+	for _, op := range f.ops {
+		h = op(h)
+	}
+	return h
+}
+
+func (f forwardHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= level.Level()
+}
+
+func (f forwardHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r = r.Clone()
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return f.handler().Handle(ctx, r)
+}
+
+func (f forwardHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return f.with(func(h slog.Handler) slog.Handler { return h.WithAttrs(attrs) })
+}
+
+func (f forwardHandler) WithGroup(name string) slog.Handler {
+	return f.with(func(h slog.Handler) slog.Handler { return h.WithGroup(name) })
+}
+
+func (f forwardHandler) with(op func(slog.Handler) slog.Handler) forwardHandler {
+	ops := make([]func(slog.Handler) slog.Handler, len(f.ops), len(f.ops)+1)
+	copy(ops, f.ops)
+	return forwardHandler{ops: append(ops, op)}
+}
@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// setup troca a saída pelo buffer e volta ao padrão no fim do teste
+func setup(t *testing.T, opts Options) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	opts.Output = &buf
+	if err := Setup(opts); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Setup(Options{}) })
+	return &buf
+}
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]slog.Level{
+		"":        slog.LevelInfo,
+		"info":    slog.LevelInfo,
+		"DEBUG":   slog.LevelDebug,
+		" warn ":  slog.LevelWarn,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+	}
+	for in, want := range cases {
+		got, err := ParseLevel(in)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; esperado %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel(verbose) deveria falhar")
+	}
+}
+
+func TestSetupRejectsUnknownFormat(t *testing.T) {
+	if err := Setup(Options{Format: "xml"}); err == nil {
+		t.Error("formato xml deveria falhar")
+	}
+}
+
+// Os loggers criados antes do Setup (as variáveis de pacote) seguem o
+// handler configurado depois
+func TestJSONComponentAndRequestID(t *testing.T) {
+	l := For("engine")
+	buf := setup(t, Options{Format: FormatJSON})
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	l.InfoContext(ctx, "Busca concluída", "results", 3)
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("linha não é JSON: %q", buf.String())
+	}
+	want := map[string]any{"level": "INFO", "msg": "Busca concluída", "component": "engine", "request_id": "abc123", "results": float64(3)}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s = %v, esperado %v", k, line[k], v)
+		}
+	}
+}
+
+func TestLevelFilter(t *testing.T) {
+	buf := setup(t, Options{Level: "warn", Format: FormatJSON})
+	l := For("engine")
+	l.Info("some")
+	l.Debug("some também")
+	l.Warn("fica")
+	if n := strings.Count(buf.String(), "\n"); n != 1 || !strings.Contains(buf.String(), "fica") {
+		t.Errorf("esperada só a linha warn, veio %q", buf.String())
+	}
+}
+
+func TestPretty(t *testing.T) {
+	buf := setup(t, Options{Level: "debug"})
+	l := For("server").With("worker", 2)
+	l.Error("Falha ao publicar", "path", "data/raw/a b.pdf", "err", errors.New("timeout"))
+
+	got := buf.String()
+	for _, want := range []string{"❌ [server] Falha ao publicar", " worker=2", ` path="data/raw/a b.pdf"`, " err=timeout"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("saída %q sem %q", got, want)
+		}
+	}
+	if strings.Contains(got, "component=") {
+		t.Errorf("o componente deveria ir entre colchetes: %q", got)
+	}
+}
+
+func TestPrettyMultiline(t *testing.T) {
+	buf := setup(t, Options{})
+	For("ingestor").Warn("Saída do Python", "output", "linha 1\nlinha 2\n")
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 4 || !strings.HasSuffix(lines[0], "Saída do Python") ||
+		strings.TrimSpace(lines[1]) != "output:" || strings.TrimSpace(lines[3]) != "linha 2" {
+		t.Errorf("bloco de várias linhas inesperado: %q", buf.String())
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	if id := RequestID(context.Background()); id != "" {
+		t.Errorf("sem ID: %q", id)
+	}
+	if id := RequestID(WithRequestID(context.Background(), "x")); id != "x" {
+		t.Errorf("ID = %q, esperado x", id)
+	}
+}
@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// prettyHandler escreve uma linha legível por evento:
+//
+//	14:03:07 ⚠️  [engine] Cache de respostas indisponível err="dial tcp: ..."
+type prettyHandler struct {
+	out   io.Writer
+	level slog.Leveler
+	mu    *sync.Mutex
+	// component vem do logger (For); attrs são os demais With, já formatados
+	component string
+	attrs     string
+	groups    string
+}
+
+func newPrettyHandler(out io.Writer, level slog.Leveler) *prettyHandler {
+	return &prettyHandler{out: out, level: level, mu: &sync.Mutex{}}
+}
+
+func (h *prettyHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+// levelIcons são os ícones que as mensagens já usavam antes do slog
+func levelIcon(l slog.Level) string {
+	switch {
+	case l >= slog.LevelError:
+		return "❌"
+	case l >= slog.LevelWarn:
+		return "⚠️ "
+	case l >= slog.LevelInfo:
+		return "· "
+	}
+	return "🔍"
+}
+
+func (h *prettyHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	if !r.Time.IsZero() {
+		b.WriteString(r.Time.Format(time.TimeOnly))
+		b.WriteByte(' ')
+	}
+	b.WriteString(levelIcon(r.Level))
+	b.WriteByte(' ')
+	if h.component != "" {
+		b.WriteString("[" + h.component + "] ")
+	}
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	// Textos de várias linhas (ex: a saída do processor.py) vão depois da
+	// linha, recuados, em vez de entre aspas com \n
+	var blocks strings.Builder
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Value.Kind() == slog.KindString && strings.Contains(strings.TrimSpace(a.Value.String()), "\n") {
+			fmt.Fprintf(&blocks, "    %s%s:\n", h.groups, a.Key)
+			for _, line := range strings.Split(strings.TrimRight(a.Value.String(), "\n"), "\n") {
+				blocks.WriteString("      " + line + "\n")
+			}
+			return true
+		}
+		writeAttr(&b, h.groups, a)
+		return true
+	})
+	b.WriteByte('\n')
+	b.WriteString(blocks.String())
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.out, b.String())
+	return err
+}
+
+func (h *prettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	var b strings.Builder
+	for _, a := range attrs {
+		if a.Key == "component" && h.groups == "" {
+			c.component = a.Value.String()
+			continue
+		}
+		writeAttr(&b, h.groups, a)
+	}
+	c.attrs += b.String()
+	return &c
+}
+
+func (h *prettyHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := *h
+	c.groups += name + "."
+	return &c
+}
+
+// writeAttr escreve " chave=valor", com o valor entre aspas se precisar
+func writeAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, g := range a.Value.Group() {
+			writeAttr(b, prefix+a.Key+".", g)
+		}
+		return
+	}
+	var v string
+	switch a.Value.Kind() {
+	case slog.KindDuration:
+		v = a.Value.Duration().Round(time.Millisecond).String()
+	case slog.KindTime:
+		v = a.Value.Time().Format(time.RFC3339)
+	default:
+		v = a.Value.String()
+		if err, ok := a.Value.Any().(error); ok {
+			v = err.Error()
+		}
+	}
+	if v == "" || strings.ContainsAny(v, " =\"\n\t") {
+		v = strconv.Quote(v)
+	}
+	fmt.Fprintf(b, " %s%s=%s", prefix, a.Key, v)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"alana_system/logging"
+)
+
+// ==============================
+// Loggers dos componentes
+// ==============================
+
+// Cada componente registra com o próprio logger (component=<nome> em cada
+// linha); o nível e o formato vêm de -log-level e -log-format (ver
+// parseGlobalFlags). Nos handlers HTTP, use as variantes ...Context com o
+// contexto do pedido para a linha levar o request_id.
+var (
+	// engineLog é o pipeline de perguntas: busca, caches, geração e índices
+	engineLog = logging.For("engine")
+	// serverLog são os handlers e o ciclo de vida do serve
+	serverLog = logging.For("server")
+	// sidecarLog é o sidecar Python: supervisão e chamadas
+	sidecarLog = logging.For("sidecar")
+	// monitorLog são as verificações periódicas (canárias, frescor das
+	// fontes, perguntas salvas, reaper de collections)
+	monitorLog = logging.For("monitor")
+	// connectorLog são os conectores e crawlers de fontes externas
+	connectorLog = logging.For("connector")
+	// cliLog são os comandos de linha de comando (batch, warmup...)
+	cliLog = logging.For("cli")
+)
+
+// ==============================
+// ID do pedido
+// ==============================
+
+// requestIDHeader leva o ID do pedido, nos dois sentidos
+const requestIDHeader = "X-Request-ID"
+
+// requestIDHandler dá a cada pedido um ID: o do cliente (ou do proxy na
+// frente), se vier em X-Request-ID e for razoável, ou um novo. O ID volta no
+// cabeçalho da resposta e vai no contexto, para os logs do pedido.
+func requestIDHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			var b [8]byte
+			rand.Read(b[:])
+			id = hex.EncodeToString(b[:])
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID aceita IDs curtos de letras, dígitos, "-", "_" e ".": o ID
+// vai para os logs e não pode quebrar a linha nem inflá-la
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,223 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// jsonStore mantém o manifesto em memória e regrava o arquivo inteiro a cada
+// mudança (via arquivo temporário + rename). As leituras recarregam o arquivo
+// se outro processo o alterou, então a API enxerga o que o orchestrator
+// grava na mesma máquina. Réplicas devem usar o backend Postgres.
+type jsonStore struct {
+	mu          sync.Mutex
+	path        string
+	docs        map[string]Document
+	collections map[string]Collection
+	modTime     time.Time
+}
+
+// jsonFile é o formato do arquivo. Manifestos antigos são só a lista de
+// documentos, e continuam sendo lidos.
+type jsonFile struct {
+	Documents   []Document   `json:"documents"`
+	Collections []Collection `json:"collections"`
+}
+
+func openJSON(path string) (*jsonStore, error) {
+	if path == "" {
+		return nil, errors.New("manifest: json backend requires a path")
+	}
+
+	s := &jsonStore{path: path, docs: map[string]Document{}, collections: map[string]Collection{}}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload relê o arquivo se ele mudou desde a última leitura/escrita; deve ser
+// chamado com s.mu travado (ou antes de o store ser compartilhado)
+func (s *jsonStore) reload() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	var file jsonFile
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(data, &file.Documents)
+	} else {
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return fmt.Errorf("manifest: invalid %s: %w", s.path, err)
+	}
+
+	s.docs = make(map[string]Document, len(file.Documents))
+	for _, d := range file.Documents {
+		s.docs[d.Source] = d
+	}
+	s.collections = make(map[string]Collection, len(file.Collections))
+	for _, c := range file.Collections {
+		s.collections[c.Name] = c
+	}
+	s.modTime = info.ModTime()
+	return nil
+}
+
+func (s *jsonStore) Get(_ context.Context, source string) (Document, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return Document{}, false, err
+	}
+	d, ok := s.docs[source]
+	return d, ok, nil
+}
+
+func (s *jsonStore) Put(_ context.Context, doc Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	s.docs[doc.Source] = doc
+	return s.save()
+}
+
+func (s *jsonStore) List(context.Context) ([]Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s.sorted(), nil
+}
+
+func (s *jsonStore) Delete(_ context.Context, source string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	if _, ok := s.docs[source]; !ok {
+		return nil
+	}
+	delete(s.docs, source)
+	return s.save()
+}
+
+func (s *jsonStore) PutCollection(_ context.Context, c Collection) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	s.collections[c.Name] = c
+	return s.save()
+}
+
+func (s *jsonStore) ListCollections(context.Context) ([]Collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s.sortedCollections(), nil
+}
+
+func (s *jsonStore) DeleteCollection(_ context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return err
+	}
+	delete(s.collections, name)
+	for source, d := range s.docs {
+		if d.Collection == name {
+			delete(s.docs, source)
+		}
+	}
+	return s.save()
+}
+
+func (s *jsonStore) Close() error { return nil }
+
+func (s *jsonStore) sorted() []Document {
+	docs := make([]Document, 0, len(s.docs))
+	for _, d := range s.docs {
+		docs = append(docs, d)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].Source < docs[j].Source })
+	return docs
+}
+
+func (s *jsonStore) sortedCollections() []Collection {
+	collections := make([]Collection, 0, len(s.collections))
+	for _, c := range s.collections {
+		collections = append(collections, c)
+	}
+	sort.Slice(collections, func(i, j int) bool { return collections[i].Name < collections[j].Name })
+	return collections
+}
+
+// save grava o arquivo; deve ser chamado com s.mu travado e depois de
+// reload, já que regrava documentos e coleções: uma cópia velha traria de
+// volta o que outro processo apagou (ou apagaria o que ele gravou)
+func (s *jsonStore) save() error {
+	data, err := json.MarshalIndent(jsonFile{Documents: s.sorted(), Collections: s.sortedCollections()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+
+	// Temporário com nome único: orchestrator e API gravam o mesmo manifesto
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
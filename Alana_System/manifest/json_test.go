@@ -0,0 +1,73 @@
+package manifest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Orchestrator e API abrem o mesmo arquivo: uma gravação de um não pode
+// desfazer a do outro com a cópia em memória velha
+func TestJSONStoreConcurrentProcesses(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "manifest.json")
+
+	orchestrator, err := openJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := orchestrator.Put(ctx, Document{Source: "a.md", Collection: "tmp"}); err != nil {
+		t.Fatal(err)
+	}
+
+	api, err := openJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := api.PutCollection(ctx, Collection{Name: "keep", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.DeleteCollection(ctx, "tmp"); err != nil {
+		t.Fatal(err)
+	}
+
+	// O orchestrator ainda tem a.md em memória
+	if err := orchestrator.Put(ctx, Document{Source: "b.md"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := openJSON(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := reader.Get(ctx, "a.md"); ok {
+		t.Error("a.md, apagado pelo reaper, voltou ao manifesto")
+	}
+	if _, ok, _ := reader.Get(ctx, "b.md"); !ok {
+		t.Error("b.md não foi gravado")
+	}
+	collections, err := reader.ListCollections(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collections) != 1 || collections[0].Name != "keep" {
+		t.Errorf("collections = %+v, want só keep", collections)
+	}
+
+	if err := orchestrator.Delete(ctx, "b.md"); err != nil {
+		t.Fatal(err)
+	}
+	if collections, _ := reader.ListCollections(ctx); len(collections) != 1 {
+		t.Errorf("Delete apagou as collections gravadas pela API: %+v", collections)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("sobraram temporários: %v", entries)
+	}
+}
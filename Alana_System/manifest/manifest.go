@@ -0,0 +1,132 @@
+// Package manifest guarda o estado de ingestão de cada documento (hash do
+// conteúdo, versão publicada, status e último erro) e a validade das
+// collections efêmeras. O orchestrator escreve e a API lê, então os dois
+// precisam apontar para o mesmo backend:
+//
+//	json:<arquivo>     arquivo local (padrão: json:./data/manifest.json)
+//	postgres:<dsn>     tabela compartilhada entre réplicas
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultSpec é o backend usado quando nada é configurado
+const DefaultSpec = "json:./data/manifest.json"
+
+// Status é o estado de ingestão de um documento
+type Status string
+
+const (
+	StatusIngesting Status = "ingesting"
+	StatusIngested  Status = "ingested"
+	StatusFailed    Status = "failed"
+)
+
+// Document é uma entrada do manifesto, identificada pela fonte (caminho
+// relativo a data/raw, como em chunkid.Source).
+type Document struct {
+	Source      string    `json:"source"`
+	Type        string    `json:"type"`
+	ContentHash string    `json:"content_hash"`
+	Version     string    `json:"version"`
+	Status      Status    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	// Collection é a collection em que o documento foi ingerido (vazio nas
+	// entradas anteriores ao registro de collections)
+	Collection string `json:"collection,omitempty"`
+	// ModTime e Size são os do arquivo quando ContentHash foi calculado: se
+	// não mudaram, o orchestrator pula o arquivo sem ler o conteúdo
+	ModTime time.Time `json:"mod_time,omitzero"`
+	Size    int64     `json:"size,omitempty"`
+	// ChunkIDs são os chunk_ids publicados pela última ingestão (original_id
+	// no payload; ver chunkid)
+	ChunkIDs []string `json:"chunk_ids,omitempty"`
+	// Provenance é como a última ingestão processou o documento (nil nas
+	// entradas anteriores ao registro de proveniência)
+	Provenance *Provenance `json:"provenance,omitempty"`
+}
+
+// Provenance é a proveniência dos chunks de um documento: gravada no payload
+// de cada chunk (campo provenance) e, a do documento, no manifesto. O
+// extrator (processor.py ou o Go das notas) grava Extractor, Chunker,
+// EmbedModel e EmbeddedAt; o orchestrator completa o resto ao publicar.
+type Provenance struct {
+	// Source é o caminho do documento, como em chunkid.Source
+	Source string `json:"source,omitempty"`
+	// Extractor é quem leu o arquivo (pdfplumber, whisper, notes...) e
+	// ExtractorVersion a versão dele
+	Extractor        string `json:"extractor,omitempty"`
+	ExtractorVersion string `json:"extractor_version,omitempty"`
+	// Runner é onde o extrator rodou: go, host ou container:<imagem>
+	Runner     string         `json:"runner,omitempty"`
+	Chunker    *ChunkerParams `json:"chunker,omitempty"`
+	EmbedModel string         `json:"embed_model,omitempty"`
+	// Enrichers são as etapas do orchestrator aplicadas ao payload
+	Enrichers     []string  `json:"enrichers,omitempty"`
+	IngestVersion string    `json:"ingest_version,omitempty"`
+	EmbeddedAt    time.Time `json:"embedded_at,omitzero"`
+	IngestedAt    time.Time `json:"ingested_at,omitzero"`
+}
+
+// ChunkerParams são os parâmetros do chunking (ver chunker.Options)
+type ChunkerParams struct {
+	MaxChars     int  `json:"max_chars"`
+	OverlapChars int  `json:"overlap_chars"`
+	Sentences    bool `json:"sentences"`
+}
+
+// Unchanged diz se o arquivo com esse ModTime e Size é o mesmo da última
+// ingestão concluída, sem precisar do hash
+func (d Document) Unchanged(modTime time.Time, size int64) bool {
+	return d.Status == StatusIngested && !d.ModTime.IsZero() && d.ModTime.Equal(modTime) && d.Size == size
+}
+
+// Collection é uma collection efêmera (uploads de uma sessão, experimentos):
+// depois de ExpiresAt, o reaper da API apaga a collection no Qdrant e as
+// entradas dela no manifesto. Collections sem entrada aqui não expiram.
+type Collection struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Expired diz se a collection já venceu em now
+func (c Collection) Expired(now time.Time) bool {
+	return !c.ExpiresAt.After(now)
+}
+
+// Store é um backend do manifesto; as implementações são seguras para uso
+// concorrente.
+type Store interface {
+	Get(ctx context.Context, source string) (Document, bool, error)
+	Put(ctx context.Context, doc Document) error
+	List(ctx context.Context) ([]Document, error)
+	Delete(ctx context.Context, source string) error
+
+	PutCollection(ctx context.Context, c Collection) error
+	ListCollections(ctx context.Context) ([]Collection, error)
+	// DeleteCollection remove a collection e os documentos ingeridos nela
+	DeleteCollection(ctx context.Context, name string) error
+
+	Close() error
+}
+
+// Open abre o backend descrito por spec (vazio = DefaultSpec)
+func Open(ctx context.Context, spec string) (Store, error) {
+	if spec == "" {
+		spec = DefaultSpec
+	}
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "json":
+		return openJSON(arg)
+	case "postgres":
+		return openPostgres(ctx, arg)
+	}
+	return nil, fmt.Errorf("manifest: unknown backend %q", kind)
+}
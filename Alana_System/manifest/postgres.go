@@ -0,0 +1,219 @@
+package manifest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	// Registra o driver "pgx" no database/sql para os dois binários (o
+	// alana e o orchestrator importam este pacote)
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// PostgresDriver é o driver database/sql usado pelos backends Postgres do
+// Alana (manifesto, text store e locks de ingestão): o do pgx, registrado
+// pelo import acima
+var PostgresDriver = "pgx"
+
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS alana_manifest (
+	source       TEXT PRIMARY KEY,
+	type         TEXT NOT NULL,
+	content_hash TEXT NOT NULL,
+	version      TEXT NOT NULL,
+	status       TEXT NOT NULL,
+	error        TEXT NOT NULL DEFAULT '',
+	updated_at   TIMESTAMPTZ NOT NULL
+)`
+
+// postgresMigrations acrescentam as colunas e tabelas criadas depois da
+// tabela original
+var postgresMigrations = []string{
+	`ALTER TABLE alana_manifest ADD COLUMN IF NOT EXISTS mod_time TIMESTAMPTZ`,
+	`ALTER TABLE alana_manifest ADD COLUMN IF NOT EXISTS size BIGINT NOT NULL DEFAULT 0`,
+	`ALTER TABLE alana_manifest ADD COLUMN IF NOT EXISTS chunk_ids TEXT NOT NULL DEFAULT '[]'`,
+	`ALTER TABLE alana_manifest ADD COLUMN IF NOT EXISTS collection TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE alana_manifest ADD COLUMN IF NOT EXISTS provenance TEXT NOT NULL DEFAULT 'null'`,
+	`CREATE TABLE IF NOT EXISTS alana_collections (
+	name       TEXT PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+)`,
+}
+
+// postgresStore guarda o manifesto numa tabela compartilhada, para que
+// réplicas do orchestrator e a API vejam o mesmo estado.
+type postgresStore struct {
+	db *sql.DB
+}
+
+func openPostgres(ctx context.Context, dsn string) (*postgresStore, error) {
+	db, err := sql.Open(PostgresDriver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, postgresSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("manifest: create table: %w", err)
+	}
+	for _, m := range postgresMigrations {
+		if _, err := db.ExecContext(ctx, m); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("manifest: migrate table: %w", err)
+		}
+	}
+	return &postgresStore{db: db}, nil
+}
+
+func (s *postgresStore) Get(ctx context.Context, source string) (Document, bool, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+postgresColumns+`
+		FROM alana_manifest WHERE source = $1`, source)
+
+	d, err := scanDocument(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Document{}, false, nil
+	}
+	if err != nil {
+		return Document{}, false, err
+	}
+	return d, true, nil
+}
+
+func (s *postgresStore) Put(ctx context.Context, d Document) error {
+	if d.ChunkIDs == nil {
+		d.ChunkIDs = []string{}
+	}
+	chunkIDs, err := json.Marshal(d.ChunkIDs)
+	if err != nil {
+		return err
+	}
+	provenance, err := json.Marshal(d.Provenance)
+	if err != nil {
+		return err
+	}
+	modTime := sql.NullTime{Time: d.ModTime, Valid: !d.ModTime.IsZero()}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO alana_manifest (`+postgresColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (source) DO UPDATE SET
+			type = EXCLUDED.type,
+			content_hash = EXCLUDED.content_hash,
+			version = EXCLUDED.version,
+			status = EXCLUDED.status,
+			error = EXCLUDED.error,
+			updated_at = EXCLUDED.updated_at,
+			mod_time = EXCLUDED.mod_time,
+			size = EXCLUDED.size,
+			chunk_ids = EXCLUDED.chunk_ids,
+			collection = EXCLUDED.collection,
+			provenance = EXCLUDED.provenance`,
+		d.Source, d.Type, d.ContentHash, d.Version, string(d.Status), d.Error, d.UpdatedAt,
+		modTime, d.Size, string(chunkIDs), d.Collection, string(provenance))
+	return err
+}
+
+func (s *postgresStore) List(ctx context.Context) ([]Document, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+postgresColumns+`
+		FROM alana_manifest ORDER BY source`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []Document
+	for rows.Next() {
+		d, err := scanDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+func (s *postgresStore) Delete(ctx context.Context, source string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM alana_manifest WHERE source = $1`, source)
+	return err
+}
+
+func (s *postgresStore) PutCollection(ctx context.Context, c Collection) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO alana_collections (name, created_at, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (name) DO UPDATE SET
+			created_at = EXCLUDED.created_at,
+			expires_at = EXCLUDED.expires_at`,
+		c.Name, c.CreatedAt, c.ExpiresAt)
+	return err
+}
+
+func (s *postgresStore) ListCollections(ctx context.Context) ([]Collection, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT name, created_at, expires_at
+		FROM alana_collections ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collections []Collection
+	for rows.Next() {
+		var c Collection
+		if err := rows.Scan(&c.Name, &c.CreatedAt, &c.ExpiresAt); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
+
+// DeleteCollection apaga a collection e os documentos dela numa transação
+func (s *postgresStore) DeleteCollection(ctx context.Context, name string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM alana_manifest WHERE collection = $1`, name); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM alana_collections WHERE name = $1`, name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *postgresStore) Close() error {
+	return s.db.Close()
+}
+
+const postgresColumns = `source, type, content_hash, version, status, error, updated_at, mod_time, size, chunk_ids, collection, provenance`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDocument(row rowScanner) (Document, error) {
+	var d Document
+	var status, chunkIDs, provenance string
+	var modTime sql.NullTime
+	err := row.Scan(&d.Source, &d.Type, &d.ContentHash, &d.Version, &status, &d.Error, &d.UpdatedAt,
+		&modTime, &d.Size, &chunkIDs, &d.Collection, &provenance)
+	if err != nil {
+		return d, err
+	}
+	d.Status = Status(status)
+	d.ModTime = modTime.Time
+	if err := json.Unmarshal([]byte(chunkIDs), &d.ChunkIDs); err != nil {
+		return d, fmt.Errorf("manifest: chunk_ids de %s: %w", d.Source, err)
+	}
+	if err := json.Unmarshal([]byte(provenance), &d.Provenance); err != nil {
+		return d, fmt.Errorf("manifest: provenance de %s: %w", d.Source, err)
+	}
+	return d, nil
+}
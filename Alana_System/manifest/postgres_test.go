@@ -0,0 +1,15 @@
+package manifest
+
+import (
+	"database/sql"
+	"slices"
+	"testing"
+)
+
+// Sem o driver registrado, todo manifesto postgres: falha só na execução
+// (sql: unknown driver)
+func TestPostgresDriverRegistered(t *testing.T) {
+	if !slices.Contains(sql.Drivers(), PostgresDriver) {
+		t.Fatalf("driver %q não registrado (registrados: %v)", PostgresDriver, sql.Drivers())
+	}
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"alana_system/chunkid"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Memória das conversas (conversa como corpus)
+// ==============================
+
+const (
+	// memoryVectorName é o vetor nomeado dos turnos; os pontos de
+	// configuração do usuário não têm vetor
+	memoryVectorName = "turn"
+	// memoryRecallTurns é quantos turnos antigos entram no contexto
+	memoryRecallTurns = 3
+	// memoryMinScore é a similaridade mínima de um turno lembrado
+	memoryMinScore = 0.6
+)
+
+// Tipos de ponto da collection de memória (campo kind)
+const (
+	memoryKindTurn     = "turn"
+	memoryKindSettings = "settings"
+)
+
+// chatMemory indexa os turnos das conversas de cada usuário numa collection
+// própria do Qdrant, para que uma pergunta ("como falamos mês passado...")
+// recupere discussões de outras sessões. Só vale para pedidos com user_id,
+// e cada usuário pode desligar a memória ou apagá-la (ver /v1/memory).
+//
+// No serve, a memória de um user_id fica sob a chave de API do pedido (ver
+// memoryOwner): o user_id vem do cliente, mas só a chave que o usou alcança
+// os turnos dele.
+type chatMemory struct {
+	client     *qdrant.Client
+	collection string
+	timeout    time.Duration
+	// ready indica que a collection já existe (criada no primeiro turno,
+	// com a dimensão do embedder)
+	ready atomic.Bool
+}
+
+// openChatMemory abre a memória de ALANA_CHAT_MEMORY: vazio desliga,
+// "qdrant:<collection>" guarda os turnos nessa collection
+func openChatMemory(spec string, client *qdrant.Client) (*chatMemory, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "":
+		return nil, nil
+	case "qdrant":
+		if arg == "" {
+			return nil, errors.New("chat memory: qdrant precisa de uma collection")
+		}
+		return &chatMemory{client: client, collection: arg, timeout: 10 * time.Second}, nil
+	}
+	return nil, fmt.Errorf("chat memory: backend desconhecido %q", kind)
+}
+
+// exists diz se a collection já foi criada (nada foi lembrado ainda se não)
+func (m *chatMemory) exists(ctx context.Context) (bool, error) {
+	if m.ready.Load() {
+		return true, nil
+	}
+	ok, err := m.client.CollectionExists(ctx, m.collection)
+	if err != nil {
+		return false, fmt.Errorf("chat memory: %w", err)
+	}
+	m.ready.Store(ok)
+	return ok, nil
+}
+
+// ensure cria a collection com a dimensão dos vetores do embedder
+func (m *chatMemory) ensure(ctx context.Context, dim int) error {
+	if ok, err := m.exists(ctx); ok || err != nil {
+		return err
+	}
+	err := m.client.CreateCollection(ctx, &qdrant.CreateCollection{
+		CollectionName: m.collection,
+		VectorsConfig: qdrant.NewVectorsConfigMap(map[string]*qdrant.VectorParams{
+			memoryVectorName: {Size: uint64(dim), Distance: qdrant.Distance_Cosine},
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("chat memory: criar %s: %w", m.collection, err)
+	}
+	for _, field := range []string{"user_id", "session_id", "kind"} {
+		_, err := m.client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName: m.collection,
+			FieldName:      field,
+			FieldType:      qdrant.FieldType_FieldTypeKeyword.Enum(),
+		})
+		if err != nil {
+			return fmt.Errorf("chat memory: índice %s: %w", field, err)
+		}
+	}
+	m.ready.Store(true)
+	return nil
+}
+
+// userFilter seleciona os pontos do usuário de um tipo
+func userFilter(userID, kind string) *qdrant.Filter {
+	return &qdrant.Filter{Must: []*qdrant.Condition{
+		qdrant.NewMatchKeyword("user_id", userID),
+		qdrant.NewMatchKeyword("kind", kind),
+	}}
+}
+
+func (m *chatMemory) settingsID(userID string) *qdrant.PointId {
+	return qdrant.NewID(chunkid.PointID("memory-settings:" + userID))
+}
+
+// enabled diz se o usuário mantém a memória ligada (padrão: ligada)
+func (m *chatMemory) enabled(ctx context.Context, userID string) (bool, error) {
+	if ok, err := m.exists(ctx); !ok || err != nil {
+		return true, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	points, err := m.client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: m.collection,
+		Ids:            []*qdrant.PointId{m.settingsID(userID)},
+		WithPayload:    qdrant.NewWithPayloadInclude("disabled"),
+	})
+	if err != nil {
+		return false, fmt.Errorf("chat memory: %w", err)
+	}
+	return len(points) == 0 || !points[0].GetPayload()["disabled"].GetBoolValue(), nil
+}
+
+// setEnabled liga ou desliga a memória do usuário. Desligar não apaga os
+// turnos já lembrados (ver forget): só deixa de gravar e de consultar. A
+// collection precisa existir (ver setMemoryEnabled).
+func (m *chatMemory) setEnabled(ctx context.Context, userID string, enabled bool) error {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	_, err := m.client.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: m.collection,
+		Wait:           qdrant.PtrOf(true),
+		Points: []*qdrant.PointStruct{{
+			Id:      m.settingsID(userID),
+			Vectors: qdrant.NewVectorsMap(map[string]*qdrant.Vector{}),
+			Payload: qdrant.NewValueMap(map[string]any{
+				"user_id":  userID,
+				"kind":     memoryKindSettings,
+				"disabled": !enabled,
+			}),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("chat memory: %w", err)
+	}
+	return nil
+}
+
+// count é quantos turnos do usuário estão guardados
+func (m *chatMemory) count(ctx context.Context, userID string) (uint64, error) {
+	if ok, err := m.exists(ctx); !ok || err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	n, err := m.client.Count(ctx, &qdrant.CountPoints{
+		CollectionName: m.collection,
+		Filter:         userFilter(userID, memoryKindTurn),
+		Exact:          qdrant.PtrOf(true),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("chat memory: %w", err)
+	}
+	return n, nil
+}
+
+// forget apaga todos os turnos lembrados do usuário e devolve quantos eram.
+// A configuração (ligada/desligada) continua valendo.
+func (m *chatMemory) forget(ctx context.Context, userID string) (uint64, error) {
+	n, err := m.count(ctx, userID)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	_, err = m.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: m.collection,
+		Wait:           qdrant.PtrOf(true),
+		Points:         qdrant.NewPointsSelectorFilter(userFilter(userID, memoryKindTurn)),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("chat memory: %w", err)
+	}
+	return n, nil
+}
+
+// setMemoryEnabled liga ou desliga a memória do usuário, criando a
+// collection (com a dimensão do vetor da collection principal, a mesma do
+// embedder) se for preciso guardar a configuração
+func (e *AlanaEngine) setMemoryEnabled(ctx context.Context, userID string, enabled bool) error {
+	m := e.memory
+	ok, err := m.exists(ctx)
+	if err != nil || (!ok && enabled) {
+		// Sem collection, a memória já está ligada por padrão
+		return err
+	}
+	if !ok {
+		info, err := e.vectorInfo(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.ensure(ctx, int(info.dim)); err != nil {
+			return err
+		}
+	}
+	return m.setEnabled(ctx, userID, enabled)
+}
+
+// embedTurn gera o vetor de um texto da memória com o embedder da
+// collection principal (sem o fallback, que pode ter outra dimensão)
+func (e *AlanaEngine) embedTurn(ctx context.Context, text string) ([]float32, error) {
+	return e.collections.embedding(e.collection).embedder().Embed(ctx, text, "")
+}
+
+// recallTurns busca nas conversas anteriores do usuário, fora da sessão
+// atual (que já vem no histórico), os turnos parecidos com a pergunta
+func (e *AlanaEngine) recallTurns(ctx context.Context, userID, sessionID, question string) ([]ChatTurn, error) {
+	m := e.memory
+	if m == nil || userID == "" {
+		return nil, nil
+	}
+	if ok, err := m.exists(ctx); !ok || err != nil {
+		return nil, err
+	}
+	if enabled, err := m.enabled(ctx, userID); !enabled || err != nil {
+		return nil, err
+	}
+
+	vector, err := e.embedTurn(ctx, question)
+	if err != nil {
+		return nil, fmt.Errorf("chat memory: embedding: %w", err)
+	}
+	filter := userFilter(userID, memoryKindTurn)
+	if sessionID != "" {
+		filter.MustNot = append(filter.MustNot, qdrant.NewMatchKeyword("session_id", sessionID))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	points, err := e.client.Query(ctx, &qdrant.QueryPoints{
+		CollectionName: m.collection,
+		Query:          qdrant.NewQueryDense(vector),
+		Using:          qdrant.PtrOf(memoryVectorName),
+		Filter:         filter,
+		Limit:          qdrant.PtrOf(uint64(memoryRecallTurns)),
+		ScoreThreshold: qdrant.PtrOf(float32(memoryMinScore)),
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chat memory: %w", err)
+	}
+
+	turns := make([]ChatTurn, 0, len(points))
+	for _, p := range points {
+		payload := p.GetPayload()
+		t, _ := time.Parse(time.RFC3339, payload["time"].GetStringValue())
+		turns = append(turns, ChatTurn{
+			Question: payload["question"].GetStringValue(),
+			Answer:   payload["answer"].GetStringValue(),
+			Time:     t,
+		})
+	}
+	return turns, nil
+}
+
+// rememberAnswer guarda a pergunta respondida na memória do usuário.
+// Esclarecimentos, abstenções e conversa fiada (sem fontes) não entram.
+// Falhas só são registradas: a resposta já foi dada.
+func (e *AlanaEngine) rememberAnswer(ctx context.Context, userID, sessionID, question string, a Answer) {
+	m := e.memory
+	if m == nil || userID == "" || a.Clarification || a.Abstained || len(a.Sources) == 0 || e.writable() != nil {
+		return
+	}
+	if err := e.rememberTurn(ctx, userID, sessionID, question, a.Text); err != nil {
+		engineLog.WarnContext(ctx, "Erro ao guardar o turno na memória de conversas", "err", err)
+	}
+}
+
+func (e *AlanaEngine) rememberTurn(ctx context.Context, userID, sessionID, question, answer string) error {
+	m := e.memory
+	if enabled, err := m.enabled(ctx, userID); !enabled || err != nil {
+		return err
+	}
+
+	if utf8.RuneCountInString(answer) > chatAnswerRunes {
+		answer = string([]rune(answer)[:chatAnswerRunes]) + "…"
+	}
+	vector, err := e.embedTurn(ctx, question+"\n"+answer)
+	if err != nil {
+		return fmt.Errorf("embedding: %w", err)
+	}
+	if err := m.ensure(ctx, len(vector)); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	_, err = e.client.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: m.collection,
+		Wait:           qdrant.PtrOf(true),
+		Points: []*qdrant.PointStruct{{
+			Id: qdrant.NewID(chunkid.PointID(fmt.Sprintf("memory:%s:%s:%d", userID, sessionID, now.UnixNano()))),
+			Vectors: qdrant.NewVectorsMap(map[string]*qdrant.Vector{
+				memoryVectorName: qdrant.NewVectorDense(vector),
+			}),
+			Payload: qdrant.NewValueMap(map[string]any{
+				"user_id":    userID,
+				"session_id": sessionID,
+				"kind":       memoryKindTurn,
+				"question":   question,
+				"answer":     answer,
+				"time":       now.Format(time.RFC3339),
+			}),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("qdrant upsert failed: %w", err)
+	}
+	return nil
+}
+
+// errMemoryNoKey recusa user_id e /v1/memory sem chave de API: sem ela não
+// há como saber de quem é a memória
+var errMemoryNoKey = errors.New("memória de conversas exige uma chave de API (Authorization: Bearer ou X-API-Key)")
+
+// memoryOwner é o dono da memória de um pedido do serve: o SHA-256 da chave
+// de API (o mesmo hash das chaves do acl.yaml) e o user_id, separados por
+// "/". Uma chave nunca lê, desliga nem apaga a memória dos usuários de outra.
+func memoryOwner(apiKey, userID string) (string, error) {
+	if apiKey == "" {
+		return "", errMemoryNoKey
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return memoryOwnerOf(hex.EncodeToString(sum[:]), userID), nil
+}
+
+// memoryOwnerOf é o memoryOwner a partir do hash da chave
+func memoryOwnerOf(keyHash, userID string) string {
+	return strings.ToLower(keyHash) + "/" + userID
+}
+
+// recalledHistory escreve os turnos lembrados de outras conversas para o
+// início do contexto, com a data de cada um
+func recalledHistory(turns []ChatTurn) string {
+	if len(turns) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Conversas anteriores do usuário relacionadas (use se a pergunta se referir a elas):\n\n")
+	for _, t := range turns {
+		fmt.Fprintf(&b, "Em %s\nUsuário: %s\nAlana: %s\n\n", t.Time.Format("2006-01-02"), oneLine(t.Question), oneLine(t.Answer))
+	}
+	return b.String()
+}
+
+// runMemory implementa `alana memory <status|enable|disable|forget> USER`:
+// os controles de privacidade da memória de conversas pelo terminal. Com
+// -key-hash, USER é um usuário do serve que usou essa chave (ver memoryOwner).
+func runMemory(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("memory", flag.ContinueOnError)
+	keyHash := fs.String("key-hash", "", "SHA-256 (hex) da chave de API dona do usuário no serve (vazio = usuário do terminal)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("uso: alana memory [-key-hash HASH] <status|enable|disable|forget> USER")
+	}
+	if engine.memory == nil {
+		return errors.New("memória de conversas desligada (ALANA_CHAT_MEMORY)")
+	}
+	action, userID := fs.Arg(0), fs.Arg(1)
+	if *keyHash != "" {
+		if _, err := hex.DecodeString(*keyHash); err != nil || len(*keyHash) != sha256.Size*2 {
+			return fmt.Errorf("-key-hash: %q não é um SHA-256 em hex", *keyHash)
+		}
+		userID = memoryOwnerOf(*keyHash, userID)
+	}
+	if action != "status" {
+		if err := engine.writable(); err != nil {
+			return err
+		}
+	}
+
+	switch action {
+	case "status":
+		enabled, err := engine.memory.enabled(ctx, userID)
+		if err != nil {
+			return err
+		}
+		n, err := engine.memory.count(ctx, userID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Memória de %s: %s, %d turnos guardados\n", userID, map[bool]string{true: "ligada", false: "desligada"}[enabled], n)
+	case "enable", "disable":
+		if err := engine.setMemoryEnabled(ctx, userID, action == "enable"); err != nil {
+			return err
+		}
+		cliLog.Info("Memória "+map[bool]string{true: "ligada", false: "desligada"}[action == "enable"], "user", userID)
+	case "forget":
+		n, err := engine.memory.forget(ctx, userID)
+		if err != nil {
+			return err
+		}
+		cliLog.Info("Turnos apagados", "user", userID, "n", n)
+	default:
+		return fmt.Errorf("ação desconhecida %q (use status, enable, disable ou forget)", action)
+	}
+	return nil
+}
+
+// ==============================
+// /v1/memory
+// ==============================
+
+// memoryStatus é a resposta de /v1/memory/{user}
+type memoryStatus struct {
+	UserID  string `json:"user_id"`
+	Enabled bool   `json:"enabled"`
+	Turns   uint64 `json:"turns"`
+	// Deleted é quantos turnos o DELETE apagou
+	Deleted uint64 `json:"deleted,omitempty"`
+}
+
+// memoryRequest é o corpo do PUT /v1/memory/{user}
+type memoryRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// handleMemory implementa os controles de privacidade da memória de
+// conversas: GET mostra o estado, PUT {"enabled": false} desliga (sem apagar)
+// e DELETE apaga tudo o que foi lembrado do usuário. O usuário é o da chave
+// de API do pedido (ver memoryOwner); sem chave, 401.
+func (s *server) handleMemory(w http.ResponseWriter, r *http.Request) {
+	m := s.engine.memory
+	if m == nil {
+		writeError(w, http.StatusNotFound, "memória de conversas desligada")
+		return
+	}
+	userID := r.PathValue("user")
+	if err := validateText("user", userID, true, maxNameRunes); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	owner, err := memoryOwner(requestAPIKey(r), userID)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var deleted uint64
+	switch r.Method {
+	case http.MethodPut:
+		var req memoryRequest
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		if req.Enabled == nil {
+			writeRequestError(w, invalidField("enabled", "required", "enabled é obrigatório"))
+			return
+		}
+		err = s.engine.setMemoryEnabled(r.Context(), owner, *req.Enabled)
+	case http.MethodDelete:
+		deleted, err = m.forget(r.Context(), owner)
+	}
+	if err != nil {
+		serverLog.ErrorContext(r.Context(), "Erro na memória de conversas", "err", err)
+		writeError(w, http.StatusBadGateway, "memória de conversas indisponível")
+		return
+	}
+
+	status := memoryStatus{UserID: userID, Deleted: deleted}
+	if status.Enabled, err = m.enabled(r.Context(), owner); err == nil {
+		status.Turns, err = m.count(r.Context(), owner)
+	}
+	if err != nil {
+		serverLog.ErrorContext(r.Context(), "Erro na memória de conversas", "err", err)
+		writeError(w, http.StatusBadGateway, "memória de conversas indisponível")
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"alana_system/schema"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Migração do schema de payload
+// ==============================
+
+const migrateCheckpointPath = "./data/migrate_payload.json"
+
+// migrateCheckpoint guarda o progresso para retomar uma migração interrompida
+type migrateCheckpoint struct {
+	SchemaVersion int    `json:"schema_version"`
+	Offset        string `json:"offset"`
+	Scanned       int    `json:"scanned"`
+	Patched       int    `json:"patched"`
+}
+
+// runMigratePayload implementa `alana migrate-payload`: percorre todos os
+// pontos (inclusive os em staging), calcula os campos do schema atual que
+// faltam e que podem ser deduzidos do payload existente, e grava os patches em
+// lotes. O progresso é salvo a cada página; rodar de novo retoma de onde parou.
+func runMigratePayload(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("migrate-payload", flag.ContinueOnError)
+	checkpointPath := fs.String("checkpoint", migrateCheckpointPath, "arquivo de progresso")
+	restart := fs.Bool("restart", false, "ignora o progresso salvo e recomeça do início")
+	embeddingModel := fs.String("embedding-model", "", "preenche embedding_model onde estiver ausente")
+	dryRun := fs.Bool("dry-run", false, "só conta os pontos que seriam alterados")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*dryRun {
+		if err := engine.confirmDestructive("migrate-payload"); err != nil {
+			return err
+		}
+	}
+
+	cp := migrateCheckpoint{SchemaVersion: schema.Version}
+	if !*restart && !*dryRun {
+		saved, err := loadMigrateCheckpoint(*checkpointPath)
+		if err != nil {
+			return err
+		}
+		if saved != nil && saved.SchemaVersion == schema.Version {
+			cp = *saved
+			fmt.Printf("↩️  Retomando migração: %d pontos já verificados\n", cp.Scanned)
+		}
+	}
+
+	var offset *qdrant.PointId
+	if cp.Offset != "" {
+		offset = pointIDFromString(cp.Offset)
+	}
+
+	cliLog.Info("Migrando payloads", "schema_version", schema.Version)
+	err := engine.scrollPages(ctx, nil, offset, false, func(points []*qdrant.RetrievedPoint, next *qdrant.PointId) error {
+		var ops []*qdrant.PointsUpdateOperation
+		for _, p := range points {
+			patch := payloadPatch(p.GetPayload(), *embeddingModel)
+			if len(patch) == 0 {
+				continue
+			}
+			payload, err := qdrant.TryValueMap(patch)
+			if err != nil {
+				return fmt.Errorf("invalid payload for %s: %w", pointIDString(p.GetId()), err)
+			}
+			ops = append(ops, qdrant.NewPointsUpdateSetPayload(&qdrant.PointsUpdateOperation_SetPayload{
+				Payload:        payload,
+				PointsSelector: qdrant.NewPointsSelector(p.GetId()),
+			}))
+		}
+
+		if len(ops) > 0 && !*dryRun {
+			if err := engine.updateBatch(ctx, ops); err != nil {
+				return err
+			}
+		}
+
+		cp.Scanned += len(points)
+		cp.Patched += len(ops)
+		cp.Offset = pointIDString(next)
+		fmt.Printf("   %d pontos verificados | %d alterados\n", cp.Scanned, cp.Patched)
+
+		if *dryRun {
+			return nil
+		}
+		return saveMigrateCheckpoint(*checkpointPath, cp)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !*dryRun {
+		if err := os.Remove(*checkpointPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	cliLog.Info("Migração concluída", "scanned", cp.Scanned, "patched", cp.Patched)
+	return nil
+}
+
+// payloadPatch devolve apenas os campos que faltam ao payload. Campos que não
+// podem ser deduzidos (ex: author) ficam para a próxima ingestão do documento.
+func payloadPatch(payload map[string]*qdrant.Value, embeddingModel string) map[string]any {
+	patch := map[string]any{}
+
+	if v, ok := payload["schema_version"]; !ok || v.GetIntegerValue() < schema.Version {
+		patch["schema_version"] = schema.Version
+	}
+
+	if _, ok := payload["content_type"]; !ok {
+		if ct := schema.ContentType(payload["file_name"].GetStringValue()); ct != "" {
+			patch["content_type"] = ct
+		}
+	}
+
+	if _, ok := payload["tags"]; !ok {
+		patch["tags"] = []any{}
+	}
+
+	if _, ok := payload["embedding_model"]; !ok && embeddingModel != "" {
+		patch["embedding_model"] = embeddingModel
+	}
+
+	return patch
+}
+
+// updateBatch aplica várias operações numa única chamada ao Qdrant
+func (e *AlanaEngine) updateBatch(ctx context.Context, ops []*qdrant.PointsUpdateOperation) error {
+	if err := e.writable(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	_, err := e.client.UpdateBatch(ctx, &qdrant.UpdateBatchPoints{
+		CollectionName: e.collection,
+		Wait:           qdrant.PtrOf(true),
+		Operations:     ops,
+	})
+	if err != nil {
+		return fmt.Errorf("qdrant update batch failed: %w", err)
+	}
+	return nil
+}
+
+// pointIDFromString é o inverso de pointIDString
+func pointIDFromString(s string) *qdrant.PointId {
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return qdrant.NewIDNum(n)
+	}
+	return qdrant.NewID(s)
+}
+
+func loadMigrateCheckpoint(path string) (*migrateCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cp migrateCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+	return &cp, nil
+}
+
+func saveMigrateCheckpoint(path string, cp migrateCheckpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
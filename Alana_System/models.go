@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"alana_system/assemble"
+	"alana_system/tokenizer"
+	"alana_system/yamlite"
+)
+
+// ==============================
+// Registro de modelos
+// ==============================
+
+const (
+	modelsConfigPath = "config/models.yaml"
+
+	// defaultLLMModel é o modelo carregado pelo sidecar (MODEL_PATH no bridge.py)
+	defaultLLMModel = "Meta-Llama-3-8B-Instruct-Q4_K_M.gguf"
+
+	// promptReserveTokens cobre o template do prompt e a pergunta
+	promptReserveTokens = 256
+
+	// defaultTokenizerDir guarda os vocabulários <nome>.tiktoken: os do
+	// tiktoken (https://openaipublic.blob.core.windows.net/encodings/o200k_base.tiktoken,
+	// idem cl100k_base) e o tokenizer.model do Llama 3 como llama3.tiktoken
+	defaultTokenizerDir = "./data/tokenizers"
+
+	// approxCharsPerToken é a aproximação usada sem tokenizer (a mesma do
+	// assemble.DefaultOptions)
+	approxCharsPerToken = 3
+)
+
+// modelSpec descreve os limites e o preço (USD por milhão de tokens) de um
+// modelo de geração. Modelos locais têm preço zero.
+type modelSpec struct {
+	ContextWindow   int
+	MaxOutputTokens int
+	InputPrice      float64
+	OutputPrice     float64
+	// TokenLimit fixa o orçamento de contexto; zero calcula pelo modelo
+	TokenLimit int
+	// Tokenizer é o vocabulário BPE do modelo (ex: o200k_base), lido de
+	// ALANA_TOKENIZER_DIR; vazio aproxima os tokens por caracteres
+	Tokenizer string
+}
+
+// maxContextTokens é o maior contexto que cabe na janela do modelo depois de
+// reservar a saída e o prompt
+func (m modelSpec) maxContextTokens() int {
+	return max(m.ContextWindow-m.MaxOutputTokens-promptReserveTokens, 0)
+}
+
+var builtinModels = map[string]modelSpec{
+	// n_ctx=4096 e max_tokens=1024 no LLMEngine do sidecar
+	"Meta-Llama-3-8B-Instruct-Q4_K_M.gguf":   {ContextWindow: 4096, MaxOutputTokens: 1024, Tokenizer: "llama3"},
+	"Meta-Llama-3.1-8B-Instruct-Q4_K_M.gguf": {ContextWindow: 8192, MaxOutputTokens: 1024, Tokenizer: "llama3"},
+	"Mistral-7B-Instruct-v0.3-Q4_K_M.gguf":   {ContextWindow: 8192, MaxOutputTokens: 1024},
+	"gpt-4o-mini":                            {ContextWindow: 128000, MaxOutputTokens: 16384, InputPrice: 0.15, OutputPrice: 0.60, Tokenizer: "o200k_base"},
+	"gpt-4o":                                 {ContextWindow: 128000, MaxOutputTokens: 16384, InputPrice: 2.50, OutputPrice: 10.00, Tokenizer: "o200k_base"},
+}
+
+// modelRegistry resolve os limites do modelo ativo ou de um override
+type modelRegistry struct {
+	active string
+	specs  map[string]modelSpec
+	// draft é o modelo rápido do rascunho no /ask/stream (nil = desligado)
+	draft *generationOverride
+
+	// tokenizers guarda os vocabulários já lidos, pelo nome (nil = ausente)
+	mu         sync.Mutex
+	tokenizers map[string]*tokenizer.BPE
+}
+
+func newModelRegistry() *modelRegistry {
+	r := &modelRegistry{active: defaultLLMModel, specs: map[string]modelSpec{}, tokenizers: map[string]*tokenizer.BPE{}}
+	for name, spec := range builtinModels {
+		r.specs[name] = spec
+	}
+	return r
+}
+
+// loadOverrides aplica config/models.yaml, se existir:
+//
+//	active_model: Meta-Llama-3-8B-Instruct-Q4_K_M.gguf
+//	draft_model: gpt-4o-mini   # opcional, ver draftThenRefine
+//	draft_provider: openai
+//	models:
+//	  Meta-Llama-3-8B-Instruct-Q4_K_M.gguf:
+//	    context_window: 8192
+//	    max_output_tokens: 1024
+//	    token_limit: 4000
+//	    input_price: 0
+//	    output_price: 0
+//	    tokenizer: llama3   # data/tokenizers/llama3.tiktoken
+func (r *modelRegistry) loadOverrides(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	doc, err := yamlite.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if active, ok := doc["active_model"].(string); ok && active != "" {
+		r.active = active
+	}
+	if draft, ok := doc["draft_model"].(string); ok && draft != "" {
+		provider, _ := doc["draft_provider"].(string)
+		r.draft = &generationOverride{Provider: provider, Model: draft}
+	}
+
+	models, _ := doc["models"].(map[string]any)
+	for name, raw := range models {
+		fields, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: models.%s must be a mapping", path, name)
+		}
+		spec := r.specs[name]
+		for key, value := range fields {
+			if err := setModelField(&spec, key, value); err != nil {
+				return fmt.Errorf("%s: models.%s.%s: %w", path, name, key, err)
+			}
+		}
+		r.specs[name] = spec
+	}
+
+	r.warnLimits()
+	return nil
+}
+
+func setModelField(spec *modelSpec, key string, value any) error {
+	switch key {
+	case "context_window", "max_output_tokens", "token_limit":
+		n, ok := value.(int64)
+		if !ok || n < 0 {
+			return errors.New("must be a non-negative integer")
+		}
+		switch key {
+		case "context_window":
+			spec.ContextWindow = int(n)
+		case "max_output_tokens":
+			spec.MaxOutputTokens = int(n)
+		default:
+			spec.TokenLimit = int(n)
+		}
+	case "tokenizer":
+		name, ok := value.(string)
+		if !ok || name == "" || filepath.Base(name) != name {
+			return errors.New("must be a vocabulary name (ex: cl100k_base)")
+		}
+		spec.Tokenizer = name
+	case "input_price", "output_price":
+		var f float64
+		switch v := value.(type) {
+		case int64:
+			f = float64(v)
+		case float64:
+			f = v
+		default:
+			return errors.New("must be a number")
+		}
+		if key == "input_price" {
+			spec.InputPrice = f
+		} else {
+			spec.OutputPrice = f
+		}
+	default:
+		return errors.New("unknown field")
+	}
+	return nil
+}
+
+// warnLimits avisa quando a configuração pede mais contexto do que o modelo comporta
+func (r *modelRegistry) warnLimits() {
+	for name, spec := range r.specs {
+		if spec.TokenLimit > 0 && spec.ContextWindow > 0 && spec.TokenLimit > spec.maxContextTokens() {
+			engineLog.Warn("token_limit excede o contexto do modelo; usando o máximo",
+				"model", name, "token_limit", spec.TokenLimit, "max", spec.maxContextTokens())
+		}
+	}
+	if _, ok := r.specs[r.active]; !ok {
+		engineLog.Warn("Modelo ativo não está no registro; usando limites padrão", "model", r.active)
+	}
+}
+
+// spec devolve os limites do modelo (o ativo, se model for vazio). Modelos
+// desconhecidos usam os limites do modelo padrão do sidecar.
+func (r *modelRegistry) spec(model string) modelSpec {
+	if model == "" {
+		model = r.active
+	}
+	if s, ok := r.specs[filepath.Base(model)]; ok {
+		return s
+	}
+	return builtinModels[defaultLLMModel]
+}
+
+// contextTokenLimit é o tokenLimit do AssembleContext para o modelo
+func (r *modelRegistry) contextTokenLimit(model string) int {
+	s := r.spec(model)
+	limit := s.maxContextTokens()
+	if s.TokenLimit > 0 && (limit == 0 || s.TokenLimit < limit) {
+		return s.TokenLimit
+	}
+	if limit == 0 {
+		// Modelo sem context_window na configuração
+		return builtinModels[defaultLLMModel].maxContextTokens()
+	}
+	return limit
+}
+
+// tokenizer devolve o vocabulário do modelo para o assemble, ou nil para a
+// aproximação por caracteres (modelo sem tokenizer, ou arquivo ausente).
+// Cada vocabulário é lido uma vez; a falha é avisada uma vez.
+func (r *modelRegistry) tokenizer(model string) assemble.Tokenizer {
+	name := r.spec(model).Tokenizer
+	if name == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	bpe, ok := r.tokenizers[name]
+	if !ok {
+		path := filepath.Join(tokenizerDir(), name+".tiktoken")
+		var err error
+		bpe, err = tokenizer.Load(path, tokenizer.PatternFor(name))
+		if err != nil {
+			engineLog.Warn("Tokenizer indisponível; contando tokens por caracteres", "tokenizer", name, "err", err)
+		}
+		r.tokenizers[name] = bpe
+	}
+	if bpe == nil {
+		return nil
+	}
+	return bpe
+}
+
+// countTokens conta os tokens do texto no vocabulário do modelo, ou pela
+// aproximação por caracteres
+func (r *modelRegistry) countTokens(model, text string) int {
+	if tok := r.tokenizer(model); tok != nil {
+		return tok.Count(text)
+	}
+	return len(text) / approxCharsPerToken
+}
+
+// tokenizerDir lê ALANA_TOKENIZER_DIR (padrão ./data/tokenizers)
+func tokenizerDir() string {
+	if dir := os.Getenv("ALANA_TOKENIZER_DIR"); dir != "" {
+		return dir
+	}
+	return defaultTokenizerDir
+}
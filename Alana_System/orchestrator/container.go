@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// ==============================
+// processor.py num container
+// ==============================
+
+// containerWorkdir é onde o diretório Alana_System é montado no container
+const containerWorkdir = "/work"
+
+// containerEnvPrefixes são as variáveis repassadas ao container: as do
+// Alana (collection, Qdrant, dual-write...) e as do Hugging Face (token,
+// cache dos modelos)
+var containerEnvPrefixes = []string{"ALANA_", "HF_"}
+
+// containerSpec roda o processor.py numa imagem com as dependências pesadas
+// (Whisper, Tesseract, torch), em vez do Python do host. O diretório atual é
+// montado em containerWorkdir, então os caminhos relativos (data/raw/...)
+// valem dentro do container; raízes fora dele (-allow) precisam de um
+// -container-mounts.
+type containerSpec struct {
+	// runtime é o CLI compatível com `docker run` (docker, podman)
+	runtime string
+	image   string
+	// network é a rede do container: host (padrão) para alcançar o Qdrant e o
+	// sidecar pelos mesmos endereços do host
+	network string
+	// mounts são volumes extras, no formato do -v (host:container[:ro])
+	mounts []string
+	cpus   string
+	memory string
+}
+
+// containerSeq numera os containers da execução, para interrompê-los pelo nome
+var containerSeq atomic.Int64
+
+// processorRunner monta o comando do processor.py: o interpretador do host
+// (-python) ou, com container != nil, um `docker run` da imagem
+type processorRunner struct {
+	python    string
+	container *containerSpec
+}
+
+// command é o processor.py com args. Cancelar ctx (ou kill) interrompe o
+// processo; num container, também o container, que sobreviveria ao CLI.
+func (r processorRunner) command(ctx context.Context, args ...string) (*exec.Cmd, error) {
+	if r.container == nil {
+		cmd := exec.CommandContext(ctx, r.python, append([]string{"processor.py"}, args...)...)
+		cmd.Dir = "."
+		detach(cmd)
+		return cmd, nil
+	}
+
+	c := r.container
+	workdir, err := filepath.Abs(".")
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("alana-processor-%d-%d", os.Getpid(), containerSeq.Add(1))
+	run := []string{"run", "--rm", "-i", "--init", "--name", name,
+		"--network", c.network,
+		"-v", workdir + ":" + containerWorkdir,
+		"-w", containerWorkdir,
+	}
+	for _, m := range c.mounts {
+		run = append(run, "-v", m)
+	}
+	if c.cpus != "" {
+		run = append(run, "--cpus", c.cpus)
+	}
+	if c.memory != "" {
+		run = append(run, "--memory", c.memory)
+	}
+	// -e NOME (sem valor) copia a variável do ambiente do CLI
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		for _, prefix := range containerEnvPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				run = append(run, "-e", key)
+				break
+			}
+		}
+	}
+	run = append(run, c.image, "python", "processor.py")
+
+	cmd := exec.CommandContext(ctx, c.runtime, append(run, args...)...)
+	cmd.Dir = "."
+	detach(cmd)
+	cmd.Cancel = func() error {
+		exec.Command(c.runtime, "kill", name).Run()
+		return cmd.Process.Kill()
+	}
+	return cmd, nil
+}
+
+// name é onde o processor.py roda, para a proveniência: host ou
+// container:<imagem>
+func (r processorRunner) name() string {
+	if r.container != nil {
+		return "container:" + r.container.image
+	}
+	return "host"
+}
+
+// path é o caminho do documento como o processor.py o vê: no container
+// (Linux), sempre com "/"
+func (r processorRunner) path(relative string) string {
+	if r.container != nil {
+		return filepath.ToSlash(relative)
+	}
+	return relative
+}
+
+// killCommand interrompe um processo criado por processorRunner.command
+// (com o container, se houver)
+func killCommand(cmd *exec.Cmd) {
+	cmd.Cancel()
+}
+
+// containerFromFlags monta o containerSpec das flags -container-*. Sem
+// imagem, o processor.py roda direto no host.
+func containerFromFlags(image, runtime, network, mounts, cpus, memory string) (*containerSpec, error) {
+	if image == "" {
+		return nil, nil
+	}
+	if _, err := exec.LookPath(runtime); err != nil {
+		return nil, fmt.Errorf("-container-runtime %s: %w", runtime, err)
+	}
+	c := &containerSpec{runtime: runtime, image: image, network: network, cpus: cpus, memory: memory}
+	for _, m := range strings.Split(mounts, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			c.mounts = append(c.mounts, m)
+		}
+	}
+	return c, nil
+}
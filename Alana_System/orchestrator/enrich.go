@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+
+	"alana_system/schema"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Enriquecimento de payload
+// ==============================
+
+// metadataIndexes são os índices usados para filtrar por metadados
+var metadataIndexes = map[string]qdrant.FieldType{
+	"author":       qdrant.FieldType_FieldTypeKeyword,
+	"tags":         qdrant.FieldType_FieldTypeKeyword,
+	"content_type": qdrant.FieldType_FieldTypeKeyword,
+	"created_ts":   qdrant.FieldType_FieldTypeInteger,
+	"page_number":  qdrant.FieldType_FieldTypeInteger,
+}
+
+// enricher complementa o payload dos chunks gravados pelo processor.py com
+// dados que o Go extrai do arquivo original.
+type enricher struct {
+	stores typedStores
+	// mirror recebe os mesmos metadados (collection do dual-write, ou nil)
+	mirror       *pointStore
+	refs         *referenceGraph
+	allowedRoots []string
+}
+
+// enrich grava metadados do documento, links externos e referências a outros
+// documentos no payload e registra as referências no grafo.
+func (e *enricher) enrich(ctx context.Context, task Task) error {
+	// Metadados ilegíveis (front matter malformado, PDF corrompido) não
+	// impedem os links e as referências: o documento segue sem eles
+	meta, err := extractMetadata(task)
+	if err != nil {
+		ingestLog.Warn("Erro ao extrair metadados", "path", task.Path, "err", err)
+		meta = DocumentMetadata{}
+	}
+
+	links, err := extractLinks(task)
+	if err != nil {
+		return err
+	}
+	references := e.addReferences(task, links)
+
+	fields := meta.payload()
+	fields["links"] = anyList(links.External)
+	fields["references"] = anyList(references)
+	fields["content_type"] = schema.ContentType(task.Path)
+	fields["schema_version"] = schema.Version
+	if _, ok := fields["tags"]; !ok {
+		fields["tags"] = []any{}
+	}
+
+	if err := e.stores.forTask(task).setPayload(ctx, filepath.Base(task.Path), fields); err != nil {
+		return err
+	}
+	if e.mirror != nil {
+		return e.mirror.setPayload(ctx, filepath.Base(task.Path), fields)
+	}
+	return nil
+}
+
+// collectReferences registra no grafo as referências de um documento que não
+// foi reingerido: o grafo é refeito a cada execução
+func (e *enricher) collectReferences(task Task) error {
+	links, err := extractLinks(task)
+	if err != nil {
+		return err
+	}
+	e.addReferences(task, links)
+	return nil
+}
+
+// addReferences registra no grafo as referências internas resolvidas e
+// devolve os nomes dos arquivos referenciados
+func (e *enricher) addReferences(task Task, links DocumentLinks) []string {
+	var references []string
+	for _, target := range links.Internal {
+		path, ok := resolveReference(task.Path, target, e.allowedRoots)
+		if !ok {
+			continue
+		}
+		references = append(references, filepath.Base(path))
+		e.refs.add(task.Path, []string{path})
+	}
+	return references
+}
+
+// anyList converte []string no formato aceito por qdrant.NewValue
+func anyList(values []string) []any {
+	out := make([]any, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"alana_system/manifest"
+)
+
+// registerEphemeral marca a collection como efêmera: vence ttl depois desta
+// execução (ingerir de novo renova o prazo). O reaper do `alana serve` (ou
+// `alana reap`) apaga a collection e as entradas dela no manifesto.
+func registerEphemeral(ctx context.Context, docs manifest.Store, collection string, ttl time.Duration) error {
+	now := time.Now().UTC()
+	c := manifest.Collection{Name: collection, CreatedAt: now, ExpiresAt: now.Add(ttl)}
+
+	existing, err := docs.ListCollections(ctx)
+	if err != nil {
+		return err
+	}
+	for _, e := range existing {
+		if e.Name == collection {
+			c.CreatedAt = e.CreatedAt
+			c.ExpiresAt = maxTime(e.ExpiresAt, c.ExpiresAt)
+		}
+	}
+
+	if err := docs.PutCollection(ctx, c); err != nil {
+		return err
+	}
+	ingestLog.Info("Collection efêmera", "collection", collection, "expires_at", c.ExpiresAt.Format(time.RFC3339))
+	return nil
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"alana_system/manifest"
+	"alana_system/textstore"
+)
+
+// ==============================
+// Ingestão incremental
+// ==============================
+
+// unchanged consulta o manifesto antes de processar o arquivo. Arquivos com o
+// mesmo mtime e tamanho da última ingestão concluída são pulados sem ler o
+// conteúdo; se só o mtime mudou (touch, cópia), o hash decide e o manifesto é
+// atualizado. Devolve o hash calculado (vazio se pulou sem hash).
+func (p *pipeline) unchanged(ctx context.Context, workerID int, source string, task Task, info os.FileInfo) (string, bool, error) {
+	modTime, size := info.ModTime().UTC(), info.Size()
+
+	prev, found, err := p.manifest.Get(ctx, source)
+	if err != nil {
+		// Sem o manifesto não dá para saber: reingere
+		workerLog(workerID).Warn("Erro ao consultar o manifesto, reingerindo", "source", source, "err", err)
+		found = false
+	}
+	// Ingerido em outra collection (ex: uma efêmera): não está nesta
+	found = found && p.sameCollection(prev, p.stores.forTask(task))
+	if !p.force && found && prev.Unchanged(modTime, size) {
+		p.skip(workerID, task)
+		return "", true, nil
+	}
+
+	hash, err := fileHash(task.Path)
+	if err != nil {
+		return "", false, err
+	}
+	if !p.force && found && prev.Status == manifest.StatusIngested && prev.ContentHash == hash {
+		prev.ModTime, prev.Size = modTime, size
+		p.record(context.WithoutCancel(ctx), workerID, prev)
+		p.skip(workerID, task)
+		return hash, true, nil
+	}
+	return hash, false, nil
+}
+
+// skip mantém no grafo de referências um documento que não foi reingerido
+func (p *pipeline) skip(workerID int, task Task) {
+	workerLog(workerID).Info("Inalterado, pulando", "path", task.Path)
+	if err := p.enr.collectReferences(task); err != nil {
+		workerLog(workerID).Warn("Erro ao extrair referências", "path", task.Path, "err", err)
+	}
+}
+
+// purge apaga do Qdrant (e do manifesto e do text store) os documentos cujo
+// arquivo não existe mais. Só deve rodar depois de uma descoberta completa.
+func (p *pipeline) purge(ctx context.Context) error {
+	docs, err := p.manifest.List(ctx)
+	if err != nil {
+		return fmt.Errorf("listar manifesto: %w", err)
+	}
+
+	// Os pontos são apagados por file_name (o nome sem diretório): se outro
+	// documento existente tem o mesmo nome, os pontos são dele
+	present := map[string]bool{}
+	var missing []manifest.Document
+	for _, d := range docs {
+		if p.documentStore(d) == nil {
+			continue
+		}
+		if p.exists(d.Source) {
+			present[filepath.Base(filepath.FromSlash(d.Source))] = true
+		} else {
+			missing = append(missing, d)
+		}
+	}
+
+	for _, d := range missing {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		fileName := filepath.Base(filepath.FromSlash(d.Source))
+		if present[fileName] {
+			ingestLog.Warn("Arquivo removido, mas o nome ainda existe em outro caminho: mantendo os pontos", "source", d.Source, "file", fileName)
+		} else {
+			store := p.documentStore(d)
+			var offloaded []string
+			if p.texts != nil {
+				if offloaded, err = store.offloadedIDs(ctx, fileName); err != nil {
+					ingestLog.Error("Erro ao listar os textos do documento", "source", d.Source, "err", err)
+					continue
+				}
+			}
+			if err := store.deleteDocument(ctx, fileName); err != nil {
+				ingestLog.Error("Erro ao apagar os pontos", "source", d.Source, "err", err)
+				continue
+			}
+			if p.mirror != nil {
+				if err := p.mirror.deleteDocument(ctx, fileName); err != nil {
+					ingestLog.Error("Erro ao apagar os pontos do dual-write", "source", d.Source, "err", err)
+				}
+			}
+			if _, err := textstore.Release(ctx, p.texts, store.client, offloaded); err != nil {
+				ingestLog.Error("Erro ao apagar os textos do text store", "source", d.Source, "err", err)
+			}
+		}
+		if err := p.manifest.Delete(ctx, d.Source); err != nil {
+			ingestLog.Error("Erro ao remover do manifesto", "source", d.Source, "err", err)
+			continue
+		}
+		ingestLog.Info("Documento removido", "source", d.Source, "chunks", len(d.ChunkIDs))
+	}
+	return nil
+}
+
+// sameCollection diz se o documento do manifesto foi ingerido na collection
+// store desta execução
+func (p *pipeline) sameCollection(d manifest.Document, store *pointStore) bool {
+	return p.documentStore(d) == store
+}
+
+// documentStore é a collection da ingestão onde o documento do manifesto foi
+// gravado, ou nil se é outra (ex: uma efêmera). Entradas sem collection são
+// anteriores ao registro e valem para a principal desta execução.
+func (p *pipeline) documentStore(d manifest.Document) *pointStore {
+	if d.Collection == "" {
+		return p.stores.main
+	}
+	return p.stores.forCollection(d.Collection)
+}
+
+// exists diz se a fonte do manifesto ainda existe: relativa a rawDir, ou o
+// caminho fora dele gravado por chunkid.Source. Erros que não sejam "não
+// existe" (permissão, disco) contam como existente: na dúvida, não apaga.
+func (p *pipeline) exists(source string) bool {
+	path := filepath.FromSlash(source)
+	for _, candidate := range []string{filepath.Join(p.rawDir, path), path} {
+		if _, err := os.Stat(candidate); !errors.Is(err, fs.ErrNotExist) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,77 +1,557 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"sync"
-	"syscall"
+	"time"
+
+	"alana_system/chunker"
+	"alana_system/chunkid"
+	"alana_system/config"
+	"alana_system/logging"
+	"alana_system/manifest"
+	"alana_system/schema"
+	"alana_system/textstore"
+
+	"github.com/qdrant/go-client/qdrant"
 )
 
 type Task struct {
-	Path string
-	Type string
+	Path string `json:"path"`
+	Type string `json:"type"`
 }
 
+var referenceGraphPath = filepath.Join("data", "reference_graph.json")
+
 func main() {
+	followLinks := flag.Bool("follow-links", false, "ingere também documentos referenciados pelos arquivos descobertos")
+	allow := flag.String("allow", "", "raízes extras permitidas ao seguir links (separadas por vírgula)")
+	manifestSpec := flag.String("manifest", os.Getenv("ALANA_MANIFEST"), "manifesto de ingestão: json:<arquivo> ou postgres:<dsn>")
+	lockSpec := flag.String("lock", os.Getenv("ALANA_INGEST_LOCK"), "lock entre instâncias: file:<dir compartilhado> ou postgres:<dsn> (vazio = sem lock)")
+	env := flag.String("env", "", "ambiente de config/alana.yaml (vazio = ALANA_ENV)")
+	force := flag.Bool("force", false, "reingere todos os arquivos, mesmo os inalterados desde a última ingestão")
+	purge := flag.Bool("purge", true, "apaga do Qdrant os documentos cujo arquivo foi removido")
+	yesProd := flag.Bool("yes-prod", false, "confirma o -purge e o -ttl num ambiente protegido")
+	ttl := flag.Duration("ttl", 0, "collection efêmera: apagada pela API depois desse tempo (0 = não expira)")
+	watchMode := flag.Bool("watch", false, "continua rodando e ingere os arquivos novos ou alterados em data/raw")
+	showProgress := flag.Bool("progress", true, "mostra a barra de progresso quando a saída de erro é um terminal")
+	verbose := flag.Bool("verbose", false, "mostra sempre a saída do processor.py (sem a barra, ela já aparece)")
+	jsonReport := flag.String("json", "", "grava o resumo da ingestão em JSON nesse arquivo (- = saída padrão)")
+	python := flag.String("python", cmp.Or(os.Getenv("ALANA_PYTHON"), "python"), "interpretador do processor.py: nome no PATH ou caminho (ex: C:\\Python312\\python.exe, .venv/bin/python)")
+	containerImage := flag.String("container-image", os.Getenv("ALANA_PROCESSOR_IMAGE"), "roda o processor.py nessa imagem em vez do Python do host (vazio = no host)")
+	containerRuntime := flag.String("container-runtime", cmp.Or(os.Getenv("ALANA_CONTAINER_RUNTIME"), "docker"), "CLI dos containers: docker ou podman")
+	containerNetwork := flag.String("container-network", cmp.Or(os.Getenv("ALANA_PROCESSOR_NETWORK"), "host"), "rede do container do processor.py")
+	containerMounts := flag.String("container-mounts", os.Getenv("ALANA_PROCESSOR_MOUNTS"), "volumes extras do container (host:container[:ro], separados por vírgula)")
+	containerCPUs := flag.String("container-cpus", os.Getenv("ALANA_PROCESSOR_CPUS"), "limite de CPUs de cada container (ex: 2)")
+	containerMemory := flag.String("container-memory", os.Getenv("ALANA_PROCESSOR_MEMORY"), "limite de memória de cada container (ex: 4g)")
+	pythonWorkers := flag.Bool("python-workers", true, "mantém um processor.py por worker, com os modelos carregados, em vez de um processo por arquivo")
+	resume := flag.Bool("resume", false, "retoma a ingestão interrompida, pulando os arquivos que ela já concluiu")
+	shutdownTimeout := flag.Duration("shutdown-timeout", defaultShutdownTimeout, "quanto o Ctrl+C espera os arquivos em andamento terminarem")
+	debounce := flag.Duration("watch-debounce", 2*time.Second, "tempo sem eventos antes de ingerir um arquivo alterado (-watch)")
+	chunking := chunker.DefaultOptions()
+	nativeNotes := flag.Bool("native-notes", true, "processa .txt/.md em Go, sem o processor.py (sem extração de entidades)")
+	flag.IntVar(&chunking.MaxChars, "chunk-size", chunking.MaxChars, "tamanho máximo dos chunks das notas (caracteres)")
+	flag.IntVar(&chunking.OverlapChars, "chunk-overlap", chunking.OverlapChars, "sobreposição entre chunks das notas (caracteres)")
+	flag.BoolVar(&chunking.Sentences, "chunk-sentences", chunking.Sentences, "corta os parágrafos longos no fim de frase (os IDs deixam de bater com os do processor.py)")
+	logLevel := flag.String("log-level", os.Getenv("ALANA_LOG_LEVEL"), "nível do log: debug, info (padrão), warn ou error")
+	logFormat := flag.String("log-format", os.Getenv("ALANA_LOG_FORMAT"), "formato do log: pretty (padrão) ou json")
+	flag.Parse()
+	if err := logging.Setup(logging.Options{Level: *logLevel, Format: *logFormat, Output: progressWriter{}}); err != nil {
+		logging.Fatal(ingestLog, "Opções de log inválidas", "err", err)
+	}
+
+	// ctx para a descoberta e a fila; workCtx interrompe os arquivos em
+	// andamento. O primeiro Ctrl+C cancela só ctx: os workers terminam o que
+	// começaram (até -shutdown-timeout) e o que ficou na fila vai para
+	// pendingTasksPath. O segundo, ou o prazo, cancela workCtx.
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	workCtx, abort := context.WithCancel(context.Background())
+	defer abort()
 
-	// Captura Ctrl+C
 	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(sig, shutdownSignals...)
 	go func() {
 		<-sig
-		fmt.Println("\n⛔ Cancelando ingestão...")
+		ingestLog.Warn("Parando: esperando os arquivos em andamento (Ctrl+C de novo interrompe)", "timeout", *shutdownTimeout)
 		cancel()
+		select {
+		case <-sig:
+		case <-time.After(*shutdownTimeout):
+		}
+		ingestLog.Warn("Interrompendo os arquivos em andamento")
+		abort()
 	}()
 
-	// AJUSTE: Caminho relativo para quem está dentro de Alana_System
-	rawDir := "./data/raw" 
-	numWorkers := 4
+	cfg, err := config.Load(*env)
+	if err != nil {
+		logging.Fatal(ingestLog, "Erro na configuração", "err", err)
+	}
+	// As variáveis do ambiente escolhido (manifesto, lock, text store...)
+	// valem para as flags não informadas e para os processos Python
+	cfg.ExportVars()
+	*manifestSpec = cmp.Or(*manifestSpec, os.Getenv("ALANA_MANIFEST"))
+	*lockSpec = cmp.Or(*lockSpec, os.Getenv("ALANA_INGEST_LOCK"))
+	if cfg.Env != "" {
+		ingestLog.Info("Ambiente", "env", cfg.Env, "qdrant", cfg.QdrantAddr)
+	}
+	// O processor.py lê a collection e o host do Qdrant do ambiente herdado
+	// (a de cada documento vai no pedido, ver typedStores)
+	host, port, _ := cfg.QdrantHostPort()
+	os.Setenv("ALANA_COLLECTION", cfg.Collection)
+	os.Setenv("ALANA_QDRANT_HOST", host)
+
+	// Relativo a Alana_System, com o separador do sistema
+	rawDir := filepath.Join("data", "raw")
+	numWorkers := cfg.Workers
+
+	allowedRoots := []string{rawDir}
+	for _, root := range strings.Split(*allow, ",") {
+		if root = strings.TrimSpace(root); root != "" {
+			allowedRoots = append(allowedRoots, root)
+		}
+	}
+
+	qdrantClient, err := qdrant.NewClient(&qdrant.Config{
+		Host: host,
+		Port: port,
+	})
+	if err != nil {
+		logging.Fatal(ingestLog, "Erro ao conectar no Qdrant", "err", err)
+	}
+	defer qdrantClient.Close()
+
+	stores := newTypedStores(qdrantClient, cfg)
+	for docType, store := range stores.byType {
+		ingestLog.Info("Collection separada por tipo", "type", docType, "collection", store.collection)
+	}
+
+	// Dual-write: o processor.py também grava na collection do modelo novo
+	// (mesmas variáveis de ambiente), e ela é publicada junto com a principal.
+	// Os tipos separados por collections_by_type vão todos para ela.
+	var mirror *pointStore
+	if c := os.Getenv("ALANA_DUAL_WRITE_COLLECTION"); c != "" && os.Getenv("ALANA_DUAL_WRITE_MODEL") != "" {
+		mirror = newPointStore(qdrantClient, c)
+		ingestLog.Info("Dual-write ligado", "collection", c)
+	}
+
+	enr := &enricher{
+		stores:       stores,
+		mirror:       mirror,
+		refs:         newReferenceGraph(),
+		allowedRoots: allowedRoots,
+	}
+
+	indexes := map[string]qdrant.FieldType{
+		"ingest_version": qdrant.FieldType_FieldTypeKeyword,
+		"staging":        qdrant.FieldType_FieldTypeBool,
+	}
+	for field, fieldType := range metadataIndexes {
+		indexes[field] = fieldType
+	}
+	for _, store := range stores.all() {
+		if err := store.ensureIndexes(ctx, indexes); err != nil {
+			ingestLog.Warn("Não foi possível criar índices de payload", "collection", store.collection, "err", err)
+		}
+	}
+	if mirror != nil {
+		if err := mirror.ensureIndexes(ctx, indexes); err != nil {
+			ingestLog.Warn("Não foi possível criar índices de payload do dual-write", "err", err)
+		}
+	}
+
+	locks, err := newSourceLocker(*lockSpec)
+	if err != nil {
+		logging.Fatal(ingestLog, "Erro ao configurar o lock entre instâncias", "err", err)
+	}
+	defer locks.Close()
+
+	docs, err := manifest.Open(ctx, *manifestSpec)
+	if err != nil {
+		logging.Fatal(ingestLog, "Erro ao abrir o manifesto", "err", err)
+	}
+	defer docs.Close()
+
+	texts, err := textstore.Open(ctx, os.Getenv("ALANA_TEXT_STORE"))
+	if err != nil {
+		logging.Fatal(ingestLog, "Erro ao abrir o text store", "err", err)
+	}
+	if texts != nil {
+		defer texts.Close()
+	}
+
+	if *ttl > 0 {
+		if err := cfg.Confirm("definir validade da collection", *yesProd); err != nil {
+			logging.Fatal(ingestLog, "Validade da collection não confirmada", "err", err)
+		}
+		for _, store := range stores.all() {
+			if err := registerEphemeral(ctx, docs, store.collection, *ttl); err != nil {
+				logging.Fatal(ingestLog, "Erro ao registrar a validade da collection", "collection", store.collection, "err", err)
+			}
+		}
+	}
+
+	notes, err := newNoteIngester(*nativeNotes, stores.forType(schema.ContentNote), rawDir, cfg.SidecarURL, chunking)
+	if err != nil {
+		logging.Fatal(ingestLog, "Erro na configuração do chunking", "err", err)
+	}
+
+	journal, err := openJournal(ingestJournalPath, rawDir, *resume)
+	if err != nil {
+		logging.Fatal(ingestLog, "Erro no diário da ingestão", "err", err)
+	}
+
+	container, err := containerFromFlags(*containerImage, *containerRuntime, *containerNetwork, *containerMounts, *containerCPUs, *containerMemory)
+	if err != nil {
+		logging.Fatal(ingestLog, "Erro na configuração do container", "err", err)
+	}
+	if container != nil {
+		ingestLog.Info("processor.py em container", "image", container.image, "runtime", container.runtime)
+	}
+
+	live := *showProgress && isTerminal(os.Stderr)
+	p := &pipeline{rawDir: rawDir, stores: stores, mirror: mirror, enr: enr, notes: notes, locks: locks, manifest: docs, texts: texts, force: *force, runner: processorRunner{python: *python, container: container}, verbose: *verbose || !live, journal: journal}
+	if *pythonWorkers {
+		p.workers = newPyPool(p.runner, p.verbose)
+	}
+	ingestProgress = newProgress(numWorkers, live)
 
 	tasks := make(chan Task, 100)
 	var wg sync.WaitGroup
+	pending := &pendingTasks{}
 
 	// Workers
 	for i := 1; i <= numWorkers; i++ {
 		wg.Add(1)
-		go worker(ctx, i, tasks, &wg)
+		go worker(ctx, workCtx, i, tasks, &wg, p, pending)
 	}
 
 	// Descoberta de arquivos
-	if err := discoverFiles(ctx, rawDir, tasks); err != nil {
-		fmt.Println("Erro na descoberta:", err)
+	var followRoots []string
+	if *followLinks {
+		followRoots = allowedRoots
+	}
+	discoverErr := discoverFiles(ctx, rawDir, tasks, followRoots)
+	if discoverErr != nil {
+		ingestLog.Error("Erro na descoberta", "err", discoverErr)
+	}
+
+	// purgeRemoved apaga os documentos cujo arquivo sumiu (ver pipeline.purge)
+	purgeRemoved := func() {
+		if !*purge || ctx.Err() != nil {
+			return
+		}
+		if err := cfg.Confirm("remover documentos apagados", *yesProd); err != nil {
+			ingestLog.Warn("Purge ignorado", "err", err)
+		} else if err := p.purge(ctx); err != nil {
+			ingestLog.Error("Erro ao remover documentos apagados", "err", err)
+		}
+	}
+
+	if *watchMode && discoverErr == nil {
+		purgeRemoved()
+		ingestLog.Info("Observando (Ctrl+C para sair)", "dir", rawDir)
+		if err := watch(ctx, rawDir, *debounce, tasks, purgeRemoved); err != nil {
+			ingestLog.Error("Erro no modo watch", "err", err)
+		}
 	}
 
 	close(tasks)
 	wg.Wait()
+	if p.workers != nil {
+		p.workers.close()
+	}
+	ingestProgress.close()
+
+	// O que não foi descoberto antes do Ctrl+C não entra: a próxima execução
+	// o encontra de novo
+	for task := range tasks {
+		pending.add(task)
+	}
+	complete := ctx.Err() == nil && discoverErr == nil && pending.len() == 0
+	if err := journal.close(complete); err != nil {
+		ingestLog.Error("Erro ao fechar o diário da ingestão", "err", err)
+	}
+	if err := pending.save(pendingTasksPath, time.Now()); err != nil {
+		ingestLog.Error("Erro ao gravar os arquivos pendentes", "err", err)
+	} else if n := pending.len(); n > 0 {
+		ingestLog.Warn("Arquivos não concluídos gravados para a próxima execução", "files", n, "path", pendingTasksPath)
+	}
+
+	// Só com a descoberta completa dá para saber o que foi removido
+	if !*watchMode && discoverErr == nil {
+		purgeRemoved()
+	}
+
+	if err := enr.refs.save(referenceGraphPath); err != nil {
+		ingestLog.Error("Erro ao gravar o grafo de referências", "err", err)
+	}
+
+	now := time.Now()
+	fmt.Print("\n" + ingestProgress.summary(now))
+	if *jsonReport != "" {
+		if err := ingestProgress.writeReport(*jsonReport, now); err != nil {
+			ingestLog.Error("Erro ao gravar o relatório", "err", err)
+		}
+	}
 
-	fmt.Println("✅ Ingestão concluída pelo Orquestrador Go")
+	ingestLog.Info("Ingestão concluída pelo Orquestrador Go")
 }
 
-func worker(ctx context.Context, id int, tasks <-chan Task, wg *sync.WaitGroup) {
+// worker ingere as tarefas da fila até ela fechar ou ctx ser cancelado. O
+// arquivo em andamento usa workCtx, que só é cancelado quando o desligamento
+// desiste de esperar; se for interrompido, vai para pending.
+func worker(ctx, workCtx context.Context, id int, tasks <-chan Task, wg *sync.WaitGroup, p *pipeline, pending *pendingTasks) {
 	defer wg.Done()
 
 	for {
 		select {
 		case <-ctx.Done():
-			fmt.Printf("[Worker %d] Cancelado\n", id)
+			workerLog(id).Info("Parado")
 			return
 		case task, ok := <-tasks:
 			if !ok {
 				return
 			}
-			processTask(id, task)
+			if ctx.Err() != nil {
+				pending.add(task)
+				continue
+			}
+			// O diário guarda o arquivo como estava antes da ingestão
+			info, _ := os.Stat(task.Path)
+			if info != nil && p.journal.completed(task.Path, info) {
+				workerLog(id).Info("Já concluído na execução interrompida, pulando", "path", task.Path)
+				ingestProgress.finish(id, task.Path, ingestResult{Outcome: outcomeSkipped}, 0)
+				continue
+			}
+			start := time.Now()
+			res := p.ingest(workCtx, id, task)
+			ingestProgress.finish(id, task.Path, res, time.Since(start))
+			if res.Outcome == outcomeFailed && workCtx.Err() != nil {
+				pending.add(task)
+				continue
+			}
+			if err := p.journal.record(task, info, res); err != nil {
+				workerLog(id).Error("Erro ao gravar no diário da ingestão", "path", task.Path, "err", err)
+			}
+		}
+	}
+}
+
+// pipeline reúne o que os workers compartilham para ingerir um documento
+type pipeline struct {
+	rawDir string
+	// stores são a collection principal e as de cada tipo de documento
+	stores typedStores
+	// mirror é a collection do dual-write (nil se desligado). Falhas nela não
+	// derrubam a ingestão: a collection principal continua sendo a fonte.
+	mirror *pointStore
+	enr    *enricher
+	// notes ingere .txt/.md sem o processor.py (nil = tudo pelo Python)
+	notes    *noteIngester
+	locks    sourceLocker
+	manifest manifest.Store
+	// texts é o text store (ALANA_TEXT_STORE) de onde o purge apaga os
+	// textos dos documentos removidos; nil = texto no payload
+	texts textstore.Store
+	// force reingere mesmo os arquivos inalterados (ver unchanged)
+	force bool
+	// runner roda o processor.py no host (-python) ou num container
+	runner processorRunner
+	// workers são os processor.py persistentes (nil = um processo por arquivo)
+	workers *pyPool
+	// journal anota os arquivos terminados, para o -resume (ver ingestJournal)
+	journal *ingestJournal
+	// verbose mostra sempre a saída do processor.py; sem ele, só quando
+	// falha (a barra de progresso já mostra o andamento)
+	verbose bool
+}
+
+// ingest processa um documento de forma transacional: os chunks novos só
+// ficam visíveis depois que o processor.py e o enriquecimento terminam.
+// Commit e rollback ignoram o cancelamento para não deixar lixo em staging.
+// Se outra instância já estiver ingerindo a mesma fonte, o documento é pulado,
+// assim como os arquivos inalterados desde a última ingestão (ver unchanged).
+// O resultado alimenta o progresso (ver progress).
+func (p *pipeline) ingest(ctx context.Context, workerID int, task Task) ingestResult {
+	source := chunkid.Source(p.rawDir, task.Path)
+	held, release, ok, err := p.locks.TryLock(ctx, source)
+	if err != nil {
+		workerLog(workerID).Error("Erro ao obter o lock", "source", source, "err", err)
+		return ingestResult{Outcome: outcomeFailed, Err: err}
+	}
+	if !ok {
+		workerLog(workerID).Info("Já está sendo ingerido por outra instância, pulando", "source", source)
+		return ingestResult{Outcome: outcomeSkipped}
+	}
+	defer release()
+	// Perder o lock no meio cancela o processamento (ver sourceLocker)
+	ctx = held
+
+	fileName := filepath.Base(task.Path)
+	version := newIngestVersion()
+	store := p.stores.forTask(task)
+	finalCtx := context.WithoutCancel(ctx)
+
+	info, err := os.Stat(task.Path)
+	if err != nil {
+		workerLog(workerID).Error("Erro ao ler o arquivo", "path", task.Path, "err", err)
+		return ingestResult{Outcome: outcomeFailed, Err: err}
+	}
+	hash, skip, err := p.unchanged(ctx, workerID, source, task, info)
+	if err != nil {
+		workerLog(workerID).Error("Erro ao ler o arquivo", "path", task.Path, "err", err)
+		return ingestResult{Outcome: outcomeFailed, Err: err}
+	}
+	if skip {
+		return ingestResult{Outcome: outcomeSkipped}
+	}
+
+	// Os pontos da versão anterior (arquivo alterado) saem no commitDocument
+	doc := manifest.Document{
+		Source:      source,
+		Collection:  store.collection,
+		Type:        task.Type,
+		ContentHash: hash,
+		Version:     version,
+		Status:      manifest.StatusIngesting,
+		ModTime:     info.ModTime().UTC(),
+		Size:        info.Size(),
+	}
+	moved := p.previousStore(ctx, source, store)
+	p.record(finalCtx, workerID, doc)
+
+	rollback := func(err error) ingestResult {
+		if err := store.rollbackDocument(finalCtx, fileName, version); err != nil {
+			workerLog(workerID).Error("Erro no rollback", "path", task.Path, "err", err)
+		}
+		if p.mirror != nil {
+			if err := p.mirror.rollbackDocument(finalCtx, fileName, version); err != nil {
+				workerLog(workerID).Error("Erro no rollback do dual-write", "path", task.Path, "err", err)
+			}
+		}
+		doc.Status, doc.Error = manifest.StatusFailed, err.Error()
+		p.record(finalCtx, workerID, doc)
+		return ingestResult{Outcome: outcomeFailed, Err: err}
+	}
+
+	runner, err := p.process(ctx, workerID, task, store, version)
+	if err != nil {
+		return rollback(err)
+	}
+
+	// A proveniência do extrator já está nos chunks; o orchestrator completa
+	// com o que só ele sabe
+	prov := manifest.Provenance{Source: source, Runner: runner, IngestVersion: version}
+	if err := p.enr.enrich(ctx, task); err != nil {
+		workerLog(workerID).Warn("Erro ao enriquecer o payload", "path", task.Path, "err", err)
+	} else {
+		prov.Enrichers = enricherSteps
+	}
+
+	// Daqui em diante tudo usa finalCtx: sem o lock, outra réplica pode
+	// estar publicando a mesma fonte, então a versão não é publicada
+	if errors.Is(context.Cause(ctx), errLockLost) {
+		workerLog(workerID).Error("Lock perdido antes de publicar", "path", task.Path)
+		return rollback(errLockLost)
+	}
+	prov.IngestedAt = time.Now().UTC()
+	if err := store.setProvenance(finalCtx, fileName, version, prov); err != nil {
+		workerLog(workerID).Warn("Erro ao gravar a proveniência", "path", task.Path, "err", err)
+	}
+	if p.mirror != nil {
+		if err := p.mirror.setProvenance(finalCtx, fileName, version, prov); err != nil {
+			workerLog(workerID).Warn("Erro ao gravar a proveniência do dual-write", "path", task.Path, "err", err)
+		}
+	}
+
+	if err := store.commitDocument(finalCtx, fileName, version); err != nil {
+		workerLog(workerID).Error("Erro ao publicar", "path", task.Path, "err", err)
+		doc.Status, doc.Error = manifest.StatusFailed, err.Error()
+		p.record(finalCtx, workerID, doc)
+		return ingestResult{Outcome: outcomeFailed, Err: err}
+	}
+	if p.mirror != nil {
+		if err := p.mirror.commitDocument(finalCtx, fileName, version); err != nil {
+			workerLog(workerID).Error("Erro ao publicar o dual-write", "path", task.Path, "err", err)
+		}
+	}
+
+	if moved != nil {
+		if err := moved.deleteDocument(finalCtx, fileName); err != nil {
+			workerLog(workerID).Warn("Erro ao apagar os pontos da collection anterior", "path", task.Path, "collection", moved.collection, "err", err)
 		}
 	}
+
+	if doc.ChunkIDs, err = store.chunkIDs(finalCtx, fileName, version); err != nil {
+		workerLog(workerID).Warn("Erro ao listar os chunks", "path", task.Path, "err", err)
+	}
+	if doc.Provenance, err = store.provenance(finalCtx, fileName, version); err != nil {
+		workerLog(workerID).Warn("Erro ao ler a proveniência", "path", task.Path, "err", err)
+	}
+	doc.Status = manifest.StatusIngested
+	p.record(finalCtx, workerID, doc)
+	return ingestResult{Outcome: outcomeIngested, Chunks: len(doc.ChunkIDs)}
+}
+
+// previousStore é a collection da ingestão anterior do documento, se ele
+// mudou de collection (collections_by_type alterado): os pontos de lá saem
+// depois que a nova versão é publicada. Collections fora da ingestão (ex: uma
+// efêmera) ficam como estão.
+func (p *pipeline) previousStore(ctx context.Context, source string, store *pointStore) *pointStore {
+	prev, found, err := p.manifest.Get(ctx, source)
+	if err != nil || !found {
+		return nil
+	}
+	if moved := p.documentStore(prev); moved != store {
+		return moved
+	}
+	return nil
+}
+
+// record grava o estado do documento no manifesto. Falhas aqui não
+// interrompem a ingestão, só deixam o manifesto desatualizado.
+func (p *pipeline) record(ctx context.Context, workerID int, doc manifest.Document) {
+	doc.UpdatedAt = time.Now().UTC()
+	if err := p.manifest.Put(ctx, doc); err != nil {
+		workerLog(workerID).Warn("Erro ao atualizar o manifesto", "source", doc.Source, "err", err)
+	}
 }
 
-func discoverFiles(ctx context.Context, root string, tasks chan<- Task) error {
-	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+// fileHash é o SHA-256 do conteúdo do arquivo
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// discoverFiles percorre root enfileirando os arquivos suportados. Se
+// followRoots não for vazio, segue também as referências internas dos
+// documentos (dentro dessas raízes) e enfileira os arquivos referenciados.
+func discoverFiles(ctx context.Context, root string, tasks chan<- Task, followRoots []string) error {
+	queued := map[string]bool{}
+	var discovered []Task
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -79,54 +559,137 @@ func discoverFiles(ctx context.Context, root string, tasks chan<- Task) error {
 			return nil
 		}
 
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+		task, ok := taskForPath(path)
+		if !ok {
+			return nil
+		}
+		queued[filepath.Clean(path)] = true
+		discovered = append(discovered, task)
+
+		return enqueue(ctx, tasks, task)
+	})
+	if err != nil || len(followRoots) == 0 {
+		return err
+	}
+
+	// Busca em largura pelas referências internas
+	for len(discovered) > 0 {
+		current := discovered[0]
+		discovered = discovered[1:]
+
+		links, err := extractLinks(current)
+		if err != nil {
+			ingestLog.Warn("Erro ao extrair links", "path", current.Path, "err", err)
+			continue
 		}
 
-		switch filepath.Ext(path) {
-		case ".pdf":
-			tasks <- Task{Path: path, Type: "PDF"}
-		case ".mp3", ".wav", ".m4a":
-			tasks <- Task{Path: path, Type: "Audio"}
-		case ".txt", ".md":
-			tasks <- Task{Path: path, Type: "Note"}
+		for _, target := range links.Internal {
+			path, ok := resolveReference(current.Path, target, followRoots)
+			if !ok || queued[path] {
+				continue
+			}
+			task, ok := taskForPath(path)
+			if !ok {
+				continue
+			}
+			queued[path] = true
+			discovered = append(discovered, task)
+
+			ingestLog.Info("Seguindo referência", "from", current.Path, "path", path)
+			if err := enqueue(ctx, tasks, task); err != nil {
+				return err
+			}
 		}
+	}
+
+	return nil
+}
+
+// taskForPath classifica o arquivo pela extensão
+func taskForPath(path string) (Task, bool) {
+	switch filepath.Ext(path) {
+	case ".pdf":
+		return Task{Path: path, Type: "PDF"}, true
+	case ".mp3", ".wav", ".m4a":
+		return Task{Path: path, Type: "Audio"}, true
+	case ".txt", ".md":
+		return Task{Path: path, Type: "Note"}, true
+	}
+	return Task{}, false
+}
 
+func enqueue(ctx context.Context, tasks chan<- Task, task Task) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case tasks <- task:
+		ingestProgress.queue()
 		return nil
-	})
+	}
+}
+
+// process grava os chunks do documento em staging: as notas em Go quando
+// possível (ver noteIngester), o resto pelo processor.py. Devolve onde o
+// documento foi processado (Provenance.Runner).
+func (p *pipeline) process(ctx context.Context, workerID int, task Task, store *pointStore, version string) (string, error) {
+	native, reason := p.notes.native(task, p.mirror)
+	if !native {
+		if reason != "" && p.notes != nil {
+			workerLog(workerID).Info("Processando pelo Python", "path", task.Path, "reason", reason)
+		}
+		if p.workers != nil {
+			return p.runner.name(), p.workers.process(ctx, workerID, task, store.collection, version)
+		}
+		return p.runner.name(), processTask(ctx, workerID, p.runner, task, store.collection, version, p.verbose)
+	}
+
+	workerLog(workerID).Info("Processando em Go", "type", task.Type, "path", task.Path)
+	n, err := p.notes.ingest(ctx, task, version)
+	if err != nil {
+		workerLog(workerID).Error("Erro ao processar", "path", task.Path, "err", err)
+		return "go", err
+	}
+	workerLog(workerID).Info("Processado", "path", task.Path, "chunks", n)
+	return "go", nil
 }
 
-func processTask(workerID int, task Task) {
-	fmt.Printf("[Worker %d] Processando %s: %s\n", workerID, task.Type, task.Path)
+func processTask(ctx context.Context, workerID int, runner processorRunner, task Task, collection, ingestVersion string, verbose bool) error {
+	workerLog(workerID).Info("Processando", "type", task.Type, "path", task.Path)
 
 	// AJUSTE: O diretório de trabalho agora é o atual (.)
-	alanaSystemDir := "." 
+	alanaSystemDir := "."
 
 	// Torna o caminho do arquivo relativo ao diretório atual
 	relativePath, err := filepath.Rel(alanaSystemDir, task.Path)
 	if err != nil {
-		fmt.Printf("[Worker %d] Erro ao criar caminho relativo: %v\n", workerID, err)
-		return
+		workerLog(workerID).Error("Erro ao criar o caminho relativo", "path", task.Path, "err", err)
+		return err
 	}
 
-	cmd := exec.Command(
-		"python",
-		"processor.py", 
+	cmd, err := runner.command(ctx,
 		"--type", task.Type,
-		"--path", relativePath,
+		"--path", runner.path(relativePath),
+		"--collection", collection,
+		"--ingest-version", ingestVersion,
 	)
-	cmd.Dir = alanaSystemDir 
+	if err != nil {
+		return err
+	}
 
 	output, err := cmd.CombinedOutput()
-	
-	// AJUSTE: Mostrar sempre a saída do Python para debug (ajuda a ver o progresso do Whisper)
-	if len(output) > 0 {
-		fmt.Printf("[Worker %d] Saída do Python:\n%s\n", workerID, string(output))
+
+	// Com a barra de progresso, a saída do Python só aparece se falhar (ou
+	// com -verbose, para ver o progresso do Whisper)
+	if len(output) > 0 && (verbose || err != nil) {
+		workerLog(workerID).Info("Saída do Python", "path", task.Path, "output", string(output))
 	}
 
 	if err != nil {
-		fmt.Printf("[Worker %d] Erro crítico no Worker: %v\n", workerID, err)
+		workerLog(workerID).Error("Erro crítico no Worker", "path", task.Path, "err", err)
+		if line := lastLine(string(output)); line != "" {
+			err = fmt.Errorf("%w: %s", err, line)
+		}
 	}
-}
\ No newline at end of file
+
+	return err
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ==============================
+// Diário da ingestão (-resume)
+// ==============================
+
+// ingestJournalPath guarda, uma linha JSON por arquivo, o que a execução
+// atual já terminou. Uma execução completa o apaga; se ela for interrompida
+// (Ctrl+C, queda, kill), `ingestor -resume` pula o que já estava pronto.
+var ingestJournalPath = filepath.Join("data", "ingest_journal.jsonl")
+
+// journalEntry é uma linha do diário. A primeira (Root) identifica a
+// execução; as outras, um arquivo terminado. ModTime e Size são os do
+// arquivo quando foi ingerido: se ele mudou depois, o -resume o ingere de
+// novo.
+type journalEntry struct {
+	Root    string    `json:"root,omitempty"`
+	Started time.Time `json:"started,omitzero"`
+
+	Path    string `json:"path,omitempty"`
+	Outcome string `json:"outcome,omitempty"`
+	Chunks  int    `json:"chunks,omitempty"`
+	ModTime int64  `json:"mod_time,omitempty"`
+	Size    int64  `json:"size,omitempty"`
+}
+
+// ingestJournal é o diário da execução atual. done são os arquivos
+// terminados na execução interrompida (só com -resume).
+type ingestJournal struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	done map[string]journalEntry
+}
+
+// openJournal começa o diário da execução. Com resume, lê o da execução
+// interrompida (se for do mesmo diretório) e continua escrevendo nele; sem,
+// começa um novo.
+func openJournal(path, root string, resume bool) (*ingestJournal, error) {
+	j := &ingestJournal{path: path, done: map[string]journalEntry{}}
+	if resume {
+		started, err := j.load(root)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			ingestLog.Info("Nenhuma ingestão interrompida para retomar: começando do zero")
+		case err != nil:
+			return nil, err
+		default:
+			ingestLog.Info("Retomando a ingestão interrompida", "started", started.Local().Format(time.DateTime), "done", len(j.done))
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				return nil, err
+			}
+			j.f = f
+			// Fecha uma linha que a queda tenha deixado pela metade (linhas
+			// vazias são ignoradas no load)
+			if _, err := f.WriteString("\n"); err != nil {
+				f.Close()
+				return nil, err
+			}
+			return j, nil
+		}
+	}
+
+	if _, err := os.Stat(path); err == nil && !resume {
+		ingestLog.Warn("Descartando o diário de uma ingestão interrompida (use -resume para retomá-la)")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	j.f = f
+	if err := j.write(journalEntry{Root: root, Started: time.Now().UTC()}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// load lê o diário de uma execução anterior. A última linha pode ter sido
+// cortada pela queda: linhas inválidas são ignoradas. Só contam como
+// concluídos os arquivos ingeridos ou pulados; os que falharam são tentados
+// de novo.
+func (j *ingestJournal) load(root string) (time.Time, error) {
+	f, err := os.Open(j.path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	if !sc.Scan() {
+		return time.Time{}, os.ErrNotExist
+	}
+	var head journalEntry
+	if err := json.Unmarshal(sc.Bytes(), &head); err != nil || head.Root == "" {
+		return time.Time{}, fmt.Errorf("%s: cabeçalho inválido", j.path)
+	}
+	if head.Root != root {
+		return time.Time{}, fmt.Errorf("%s é de uma ingestão de %s, não de %s (rode sem -resume)", j.path, head.Root, root)
+	}
+	for sc.Scan() {
+		var e journalEntry
+		if json.Unmarshal(sc.Bytes(), &e) != nil || e.Path == "" {
+			continue
+		}
+		if e.Outcome == outcomeFailed.String() {
+			delete(j.done, e.Path)
+			continue
+		}
+		j.done[e.Path] = e
+	}
+	return head.Started, sc.Err()
+}
+
+// completed diz se o arquivo terminou na execução interrompida e não mudou
+// desde então
+func (j *ingestJournal) completed(path string, info os.FileInfo) bool {
+	if j == nil {
+		return false
+	}
+	j.mu.Lock()
+	e, ok := j.done[path]
+	j.mu.Unlock()
+	return ok && e.ModTime == info.ModTime().UnixNano() && e.Size == info.Size()
+}
+
+// record anota um arquivo terminado. Cada linha vai para o disco antes do
+// próximo arquivo, para sobreviver a uma queda.
+func (j *ingestJournal) record(task Task, info os.FileInfo, res ingestResult) error {
+	if j == nil {
+		return nil
+	}
+	e := journalEntry{Path: task.Path, Outcome: res.Outcome.String(), Chunks: res.Chunks}
+	if info != nil {
+		e.ModTime, e.Size = info.ModTime().UnixNano(), info.Size()
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.write(e)
+}
+
+// write grava uma linha (com j.mu, ou antes de o diário ser compartilhado)
+func (j *ingestJournal) write(e journalEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+// close fecha o diário; numa execução completa, ele é apagado
+func (j *ingestJournal) close(complete bool) error {
+	if j == nil {
+		return nil
+	}
+	if err := j.f.Close(); err != nil {
+		return err
+	}
+	if complete {
+		return os.Remove(j.path)
+	}
+	return nil
+}
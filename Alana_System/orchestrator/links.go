@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ==============================
+// Links e referências entre documentos
+// ==============================
+
+var (
+	markdownLinkPattern = regexp.MustCompile(`\[[^\]]*\]\(\s*<?([^)\s>]+)>?(?:\s+"[^"]*")?\s*\)`)
+	wikiLinkPattern     = regexp.MustCompile(`\[\[([^\]|#]+)(?:#[^\]|]*)?(?:\|[^\]]*)?\]\]`)
+	bareURLPattern      = regexp.MustCompile(`https?://[^\s<>()\[\]"']+`)
+	pdfURIPattern       = regexp.MustCompile(`/URI\s*\(([^)]+)\)`)
+)
+
+// maxLinkLine é o maior pedaço de linha examinado de uma vez nas notas;
+// linhas maiores (transcrições sem quebra) são lidas em pedaços
+const maxLinkLine = 1 << 20
+
+// DocumentLinks separa os links de um documento em externos (URLs) e
+// referências a outros arquivos (caminhos relativos ou wiki links).
+type DocumentLinks struct {
+	External []string
+	Internal []string
+}
+
+// extractLinks lê o arquivo original e extrai links conforme o tipo
+func extractLinks(task Task) (DocumentLinks, error) {
+	var targets []string
+	var err error
+	switch task.Type {
+	case "PDF":
+		var raw []byte
+		raw, err = os.ReadFile(task.Path)
+		// Anotações de link de PDFs não comprimidos: /URI (https://...)
+		for _, m := range pdfURIPattern.FindAllSubmatch(raw, -1) {
+			targets = append(targets, string(m[1]))
+		}
+	case "Note":
+		targets, err = noteLinkTargets(task.Path)
+	}
+	if err != nil {
+		return DocumentLinks{}, err
+	}
+
+	var links DocumentLinks
+	seen := map[string]bool{}
+	for _, t := range targets {
+		t = strings.TrimRight(t, ".,;:")
+		if t == "" || seen[t] || strings.HasPrefix(t, "#") {
+			continue
+		}
+		seen[t] = true
+
+		if isExternalLink(t) {
+			links.External = append(links.External, t)
+		} else {
+			links.Internal = append(links.Internal, t)
+		}
+	}
+
+	return links, nil
+}
+
+// noteLinkTargets procura links na nota linha a linha, com memória limitada
+// mesmo em arquivos de vários GB. Os padrões não atravessam linhas.
+func noteLinkTargets(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), maxLinkLine)
+	sc.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if advance == 0 && token == nil && len(data) >= maxLinkLine {
+			return len(data), data, nil
+		}
+		return advance, token, err
+	})
+
+	var targets []string
+	for sc.Scan() {
+		line := sc.Text()
+		for _, m := range markdownLinkPattern.FindAllStringSubmatch(line, -1) {
+			targets = append(targets, m[1])
+		}
+		for _, m := range wikiLinkPattern.FindAllStringSubmatch(line, -1) {
+			targets = append(targets, strings.TrimSpace(m[1]))
+		}
+		targets = append(targets, bareURLPattern.FindAllString(line, -1)...)
+	}
+	return targets, sc.Err()
+}
+
+func isExternalLink(target string) bool {
+	lower := strings.ToLower(target)
+	for _, prefix := range []string{"http://", "https://", "mailto:", "ftp://"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveReference converte uma referência interna num caminho de arquivo,
+// desde que ele exista e esteja dentro de uma das raízes permitidas.
+func resolveReference(fromPath, target string, allowedRoots []string) (string, bool) {
+	target, _, _ = strings.Cut(target, "#")
+	target = strings.TrimPrefix(target, "file://")
+	if target == "" {
+		return "", false
+	}
+
+	candidates := []string{filepath.Join(filepath.Dir(fromPath), filepath.FromSlash(target))}
+	// Wiki links costumam omitir a extensão
+	if filepath.Ext(target) == "" {
+		candidates = append(candidates, candidates[0]+".md", candidates[0]+".txt")
+	}
+
+	for _, c := range candidates {
+		info, err := os.Stat(c)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if withinRoots(c, allowedRoots) {
+			return filepath.Clean(c), true
+		}
+	}
+	return "", false
+}
+
+// withinRoots impede que links saiam do conjunto de fontes permitido
+func withinRoots(path string, roots []string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, root := range roots {
+		rootAbs, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(rootAbs, abs)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ==============================
+// Grafo de referências
+// ==============================
+
+type referenceEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// referenceGraph acumula as arestas "documento A referencia B" de toda a
+// ingestão. É compartilhado entre os workers.
+type referenceGraph struct {
+	mu    sync.Mutex
+	edges map[referenceEdge]bool
+}
+
+func newReferenceGraph() *referenceGraph {
+	return &referenceGraph{edges: map[referenceEdge]bool{}}
+}
+
+func (g *referenceGraph) add(from string, to []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, t := range to {
+		g.edges[referenceEdge{From: filepath.ToSlash(from), To: filepath.ToSlash(t)}] = true
+	}
+}
+
+// save grava o grafo em JSON (nós + arestas), ordenado para gerar diffs estáveis
+func (g *referenceGraph) save(path string) error {
+	g.mu.Lock()
+	edges := make([]referenceEdge, 0, len(g.edges))
+	for e := range g.edges {
+		edges = append(edges, e)
+	}
+	g.mu.Unlock()
+
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+
+	nodeSet := map[string]bool{}
+	for _, e := range edges {
+		nodeSet[e.From] = true
+		nodeSet[e.To] = true
+	}
+	nodes := make([]string, 0, len(nodeSet))
+	for n := range nodeSet {
+		nodes = append(nodes, n)
+	}
+	sort.Strings(nodes)
+
+	data, err := json.MarshalIndent(map[string]any{
+		"nodes": nodes,
+		"edges": edges,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"alana_system/manifest"
+)
+
+// ==============================
+// Lock entre instâncias
+// ==============================
+
+// sourceLocker garante que só uma réplica do orchestrator ingira uma mesma
+// fonte por vez, evitando upserts duplicados e corridas no commit.
+type sourceLocker interface {
+	// TryLock tenta obter o lock sem esperar. Se ok, a ingestão roda com
+	// held, cancelado (causa errLockLost) se o lock se perder no meio, e
+	// release deve ser chamado ao terminar.
+	TryLock(ctx context.Context, source string) (held context.Context, release func(), ok bool, err error)
+	Close() error
+}
+
+// errLockLost é a causa do cancelamento de uma ingestão cujo lock passou a
+// outra réplica (ou cuja conexão do advisory lock caiu)
+var errLockLost = errors.New("lock da fonte perdido para outra réplica")
+
+// newSourceLocker interpreta o -lock:
+//
+//	""                 sem lock (uma única instância)
+//	file:<dir>         arquivos de lock num diretório compartilhado (ex: NFS)
+//	postgres:<dsn>     advisory locks do Postgres
+func newSourceLocker(spec string) (sourceLocker, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "":
+		return noLocker{}, nil
+	case "file":
+		if arg == "" {
+			return nil, errors.New("file lock requires a directory")
+		}
+		return newFileLocker(arg, fileLockTTL)
+	case "postgres":
+		return newPostgresLocker(arg)
+	}
+	return nil, fmt.Errorf("unknown lock backend %q", kind)
+}
+
+type noLocker struct{}
+
+func (noLocker) TryLock(ctx context.Context, _ string) (context.Context, func(), bool, error) {
+	return ctx, func() {}, true, nil
+}
+func (noLocker) Close() error { return nil }
+
+// ==============================
+// Lock por arquivo
+// ==============================
+
+// fileLockTTL é a validade de um lock sem renovação; um lock vencido (réplica
+// que morreu no meio da ingestão) pode ser tomado por outra instância.
+const fileLockTTL = 2 * time.Minute
+
+type fileLock struct {
+	Source string `json:"source"`
+	// Owner identifica a aquisição: a réplica (host:pid) e um nonce, para
+	// que renovar e liberar só mexam no próprio lock
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+type fileLocker struct {
+	dir     string
+	ttl     time.Duration
+	replica string
+}
+
+func newFileLocker(dir string, ttl time.Duration) (*fileLocker, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	return &fileLocker{dir: dir, ttl: ttl, replica: fmt.Sprintf("%s:%d", host, os.Getpid())}, nil
+}
+
+func (l *fileLocker) path(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return filepath.Join(l.dir, hex.EncodeToString(sum[:16])+".lock")
+}
+
+func (l *fileLocker) TryLock(ctx context.Context, source string) (context.Context, func(), bool, error) {
+	path := l.path(source)
+	nonce := lockNonce()
+	owner := l.replica + ":" + nonce
+
+	ok, err := l.create(path, source, owner)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if !ok {
+		if ok, err = l.takeOver(path, source, owner, nonce); err != nil || !ok {
+			return nil, nil, false, err
+		}
+	}
+
+	// Renova o lock enquanto a ingestão durar; perdido, cancela a ingestão
+	held, cancel := context.WithCancelCause(ctx)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if !l.renew(path, source, owner, nonce) {
+					ingestLog.Warn("Lock tomado por outra réplica (renovação atrasada); cancelando a ingestão", "source", source)
+					cancel(errLockLost)
+					return
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			close(stop)
+			wg.Wait()
+			cancel(context.Canceled)
+			l.remove(path, owner, nonce)
+		})
+	}
+	return held, release, true, nil
+}
+
+// lockNonce distingue as aquisições de uma mesma réplica
+func lockNonce() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (l *fileLocker) create(path, source, owner string) (bool, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	return true, json.NewEncoder(f).Encode(fileLock{Source: source, Owner: owner, Expires: time.Now().Add(l.ttl)})
+}
+
+// Tomar, renovar e liberar o lock nunca leem o arquivo no lugar e depois
+// agem sobre ele: entre a leitura e a ação outra réplica podia trocá-lo.
+// Em vez disso, o arquivo é primeiro movido para um nome único (claim): o
+// rename é atômico, então só uma réplica tira aquele arquivo do lugar (as
+// outras recebem ErrNotExist), e quem o moveu confere o conteúdo com o
+// arquivo já fora do alcance das outras. Se não era o esperado, ele volta
+// com link, que não sobrescreve: se outra réplica criou um lock no meio
+// tempo, fica o dela, e o dono do arquivo devolvido descobre na renovação
+// seguinte que o perdeu (e cancela a ingestão).
+
+// claim move o lock para path+suffix e devolve o conteúdo movido. ok é
+// false se não havia lock.
+func claim(path, suffix string) (claimed string, data []byte, ok bool, err error) {
+	claimed = path + suffix
+	if err := os.Rename(path, claimed); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil, false, nil
+		}
+		return "", nil, false, err
+	}
+	data, err = os.ReadFile(claimed)
+	if err != nil {
+		putBack(claimed, path)
+		return "", nil, false, err
+	}
+	return claimed, data, true, nil
+}
+
+// putBack devolve o arquivo movido por claim ao lugar, se ninguém criou outro
+// lock nesse meio tempo; devolve false se o lugar já estava ocupado
+func putBack(claimed, path string) bool {
+	err := os.Link(claimed, path)
+	os.Remove(claimed)
+	return err == nil
+}
+
+// takeOver toma um lock vencido (réplica que morreu no meio da ingestão).
+// Apagar e recriar não serve: duas réplicas que viram o mesmo lock vencido
+// podiam apagar uma o lock novo da outra e ficar as duas com ele. Quem move
+// o arquivo (claim) confere que moveu mesmo o vencido que leu, e não o lock
+// novo de outra réplica criado entre a leitura e o rename (nesse caso o
+// devolve), e só então cria o seu com O_EXCL.
+func (l *fileLocker) takeOver(path, source, owner, nonce string) (bool, error) {
+	seen, expired := l.expired(path)
+	if !expired {
+		return false, nil
+	}
+	claimed, moved, ok, err := claim(path, ".stale-"+nonce)
+	if err != nil || !ok {
+		return false, err
+	}
+	if !bytes.Equal(moved, seen) {
+		putBack(claimed, path)
+		return false, nil
+	}
+	os.Remove(claimed)
+	return l.create(path, source, owner)
+}
+
+// renew estende a validade do lock, se ele ainda é desta aquisição: o lock é
+// movido (claim), conferido, reescrito fora do lugar e devolvido com link.
+// Devolve false se outra réplica o tomou (a renovação atrasou mais que o TTL)
+// ou criou um lock enquanto ele estava fora do lugar; nos dois casos quem
+// segura o lock agora é a outra réplica.
+func (l *fileLocker) renew(path, source, owner, nonce string) bool {
+	claimed, data, ok, err := claim(path, ".renew-"+nonce)
+	if err != nil {
+		// Falha de E/S passageira (ex: NFS): o lock continua no lugar e a
+		// próxima renovação tenta de novo
+		return true
+	}
+	if !ok {
+		return false
+	}
+	if lockOwner(data) != owner {
+		putBack(claimed, path)
+		return false
+	}
+	if data, err := json.Marshal(fileLock{Source: source, Owner: owner, Expires: time.Now().Add(l.ttl)}); err == nil {
+		os.WriteFile(claimed, data, 0o644)
+	}
+	return putBack(claimed, path)
+}
+
+// remove apaga o lock, se ele ainda é desta aquisição
+func (l *fileLocker) remove(path, owner, nonce string) {
+	claimed, data, ok, err := claim(path, ".release-"+nonce)
+	if err != nil || !ok {
+		return
+	}
+	if lockOwner(data) != owner {
+		putBack(claimed, path)
+		return
+	}
+	os.Remove(claimed)
+}
+
+// lockOwner é o dono gravado no lock (vazio se não dá para ler)
+func lockOwner(data []byte) string {
+	var lock fileLock
+	if json.Unmarshal(data, &lock) != nil {
+		return ""
+	}
+	return lock.Owner
+}
+
+// expired lê o lock e diz se ele venceu. Devolve também o conteúdo lido,
+// conferido por takeOver depois do rename.
+func (l *fileLocker) expired(path string) ([]byte, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var lock fileLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		// Arquivo ainda sendo escrito ou corrompido: usa a data de modificação
+		info, statErr := os.Stat(path)
+		return data, statErr == nil && time.Since(info.ModTime()) > l.ttl
+	}
+	return data, time.Now().After(lock.Expires)
+}
+
+func (l *fileLocker) Close() error { return nil }
+
+// ==============================
+// Advisory lock do Postgres
+// ==============================
+
+// postgresLocker usa pg_try_advisory_lock, que vale para a sessão: cada lock
+// segura uma conexão dedicada até ser liberado. Se a réplica morrer, o
+// Postgres encerra a sessão e libera o lock sozinho.
+type postgresLocker struct {
+	db *sql.DB
+}
+
+// newPostgresLocker abre o banco com o driver do manifesto (o pgx,
+// registrado pelo pacote manifest) e confere a conexão: um DSN errado falha
+// na partida, e não no primeiro documento
+func newPostgresLocker(dsn string) (*postgresLocker, error) {
+	db, err := sql.Open(manifest.PostgresDriver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("postgres lock: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("postgres lock: %w", err)
+	}
+	return &postgresLocker{db: db}, nil
+}
+
+// pgLockCheck é o intervalo em que a conexão de um advisory lock é
+// conferida: se ela cair, o Postgres já liberou o lock
+const pgLockCheck = 30 * time.Second
+
+func (l *postgresLocker) TryLock(ctx context.Context, source string) (context.Context, func(), bool, error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	var ok bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", source).Scan(&ok); err != nil {
+		conn.Close()
+		return nil, nil, false, err
+	}
+	if !ok {
+		conn.Close()
+		return nil, nil, false, nil
+	}
+
+	// Sessão encerrada (Postgres reiniciado, conexão derrubada) é lock
+	// perdido: cancela a ingestão
+	held, cancel := context.WithCancelCause(ctx)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(pgLockCheck)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				pingCtx, done := context.WithTimeout(context.Background(), 10*time.Second)
+				err := conn.PingContext(pingCtx)
+				done()
+				if err != nil {
+					ingestLog.Warn("Conexão do advisory lock caiu; cancelando a ingestão", "source", source, "err", err)
+					cancel(errLockLost)
+					return
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			close(stop)
+			wg.Wait()
+			cancel(context.Canceled)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			conn.ExecContext(ctx, "SELECT pg_advisory_unlock(hashtext($1))", source)
+			conn.Close()
+		})
+	}
+	return held, release, true, nil
+}
+
+func (l *postgresLocker) Close() error {
+	return l.db.Close()
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testFileLocker(t *testing.T, dir string, ttl time.Duration, replica string) *fileLocker {
+	t.Helper()
+	l, err := newFileLocker(dir, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.replica = replica
+	return l
+}
+
+func readLock(t *testing.T, path string) fileLock {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lock fileLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		t.Fatal(err)
+	}
+	return lock
+}
+
+func writeLock(t *testing.T, path string, lock fileLock) {
+	t.Helper()
+	data, err := json.Marshal(lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Duas réplicas no mesmo diretório: só uma segura a fonte, e a outra entra
+// depois do release
+func TestFileLockTwoHolders(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	a := testFileLocker(t, dir, time.Minute, "a:1")
+	b := testFileLocker(t, dir, time.Minute, "b:2")
+
+	_, releaseA, ok, err := a.TryLock(ctx, "doc.pdf")
+	if err != nil || !ok {
+		t.Fatalf("A: ok=%v err=%v", ok, err)
+	}
+	if _, _, ok, err := b.TryLock(ctx, "doc.pdf"); err != nil || ok {
+		t.Fatalf("B pegou o lock de A: ok=%v err=%v", ok, err)
+	}
+	if _, release, ok, _ := b.TryLock(ctx, "outro.pdf"); !ok {
+		t.Fatal("B não pegou uma fonte livre")
+	} else {
+		release()
+	}
+
+	releaseA()
+	releaseA() // idempotente
+	_, releaseB, ok, err := b.TryLock(ctx, "doc.pdf")
+	if err != nil || !ok {
+		t.Fatalf("B depois do release: ok=%v err=%v", ok, err)
+	}
+	defer releaseB()
+	if owner := readLock(t, b.path("doc.pdf")).Owner; owner[:4] != "b:2:" {
+		t.Errorf("dono %q, esperado a aquisição de B", owner)
+	}
+}
+
+// Um lock vencido (réplica morta) é tomado; um válido não
+func TestFileLockStaleTakeover(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	b := testFileLocker(t, dir, time.Minute, "b:2")
+	path := b.path("doc.pdf")
+
+	writeLock(t, path, fileLock{Source: "doc.pdf", Owner: "a:1:viva", Expires: time.Now().Add(time.Minute)})
+	if _, _, ok, _ := b.TryLock(ctx, "doc.pdf"); ok {
+		t.Fatal("B tomou um lock ainda válido")
+	}
+
+	writeLock(t, path, fileLock{Source: "doc.pdf", Owner: "a:1:morta", Expires: time.Now().Add(-time.Second)})
+	_, release, ok, err := b.TryLock(ctx, "doc.pdf")
+	if err != nil || !ok {
+		t.Fatalf("B não tomou o lock vencido: ok=%v err=%v", ok, err)
+	}
+	defer release()
+	if owner := readLock(t, path).Owner; owner == "a:1:morta" {
+		t.Error("o lock vencido continuou no lugar")
+	}
+	leftovers, _ := os.ReadDir(dir)
+	if len(leftovers) != 1 {
+		t.Errorf("arquivos no diretório: %v", leftovers)
+	}
+}
+
+// Várias réplicas vendo o mesmo lock vencido: só uma fica com ele
+func TestFileLockConcurrentTakeover(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := testFileLocker(t, dir, time.Minute, "x").path("doc.pdf")
+
+	for round := range 20 {
+		writeLock(t, path, fileLock{Source: "doc.pdf", Owner: "morta", Expires: time.Now().Add(-time.Second)})
+
+		var holders atomic.Int32
+		var wg sync.WaitGroup
+		releases := make(chan func(), 8)
+		for i := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				l := testFileLocker(t, dir, time.Minute, "r:"+string(rune('a'+i)))
+				if _, release, ok, err := l.TryLock(ctx, "doc.pdf"); err == nil && ok {
+					holders.Add(1)
+					releases <- release
+				}
+			}()
+		}
+		wg.Wait()
+		close(releases)
+		if n := holders.Load(); n != 1 {
+			t.Fatalf("rodada %d: %d réplicas com o lock", round, n)
+		}
+		for release := range releases {
+			release()
+		}
+	}
+}
+
+// O arquivo tirado do lugar volta só se o lugar continua livre
+func TestClaimPutBack(t *testing.T) {
+	dir := t.TempDir()
+	l := testFileLocker(t, dir, time.Minute, "a:1")
+	path := l.path("doc.pdf")
+	writeLock(t, path, fileLock{Source: "doc.pdf", Owner: "a:1:x"})
+
+	claimed, data, ok, err := claim(path, ".test")
+	if err != nil || !ok || lockOwner(data) != "a:1:x" {
+		t.Fatalf("claim: ok=%v err=%v data=%s", ok, err, data)
+	}
+	if _, _, ok, _ := claim(path, ".outra"); ok {
+		t.Fatal("duas réplicas moveram o mesmo lock")
+	}
+	writeLock(t, path, fileLock{Source: "doc.pdf", Owner: "b:2:y"})
+	if putBack(claimed, path) {
+		t.Fatal("putBack sobrescreveu o lock de outra réplica")
+	}
+	if owner := readLock(t, path).Owner; owner != "b:2:y" {
+		t.Errorf("dono %q, esperado b:2:y", owner)
+	}
+	if _, err := os.Stat(claimed); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("o arquivo movido ficou para trás: %v", err)
+	}
+}
+
+// A renovação estende a validade; se outra réplica tomou o lock, ela devolve
+// false sem mexer no lock alheio, a ingestão é cancelada com errLockLost e o
+// release não apaga o lock da outra réplica
+func TestFileLockRenew(t *testing.T) {
+	dir := t.TempDir()
+	l := testFileLocker(t, dir, time.Minute, "a:1")
+	path := l.path("doc.pdf")
+
+	writeLock(t, path, fileLock{Source: "doc.pdf", Owner: "a:1:n", Expires: time.Now().Add(time.Second)})
+	if !l.renew(path, "doc.pdf", "a:1:n", "n") {
+		t.Fatal("renovação do próprio lock falhou")
+	}
+	if exp := readLock(t, path).Expires; time.Until(exp) < 50*time.Second {
+		t.Errorf("validade não foi estendida: %v", exp)
+	}
+
+	writeLock(t, path, fileLock{Source: "doc.pdf", Owner: "b:2:m", Expires: time.Now().Add(time.Minute)})
+	before, _ := os.ReadFile(path)
+	if l.renew(path, "doc.pdf", "a:1:n", "n") {
+		t.Fatal("renovou o lock de outra réplica")
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Errorf("lock alheio alterado:\n%s\n%s", before, after)
+	}
+	os.Remove(path)
+	if l.renew(path, "doc.pdf", "a:1:n", "n") {
+		t.Fatal("renovou um lock que não existe mais")
+	}
+}
+
+func TestFileLockLostCancelsIngestion(t *testing.T) {
+	dir := t.TempDir()
+	l := testFileLocker(t, dir, 60*time.Millisecond, "a:1")
+	path := l.path("doc.pdf")
+
+	held, release, ok, err := l.TryLock(context.Background(), "doc.pdf")
+	if err != nil || !ok {
+		t.Fatalf("ok=%v err=%v", ok, err)
+	}
+	writeLock(t, path, fileLock{Source: "doc.pdf", Owner: "b:2:m", Expires: time.Now().Add(time.Minute)})
+
+	select {
+	case <-held.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("a ingestão não foi cancelada com o lock perdido")
+	}
+	if cause := context.Cause(held); !errors.Is(cause, errLockLost) {
+		t.Errorf("causa %v, esperado errLockLost", cause)
+	}
+	release()
+	if owner := readLock(t, path).Owner; owner != "b:2:m" {
+		t.Errorf("release apagou o lock da outra réplica (dono %q)", owner)
+	}
+}
+
+// Enquanto o lock é renovado, a ingestão segue; o release cancela o contexto
+// e apaga o arquivo
+func TestFileLockHeldWhileRenewed(t *testing.T) {
+	dir := t.TempDir()
+	l := testFileLocker(t, dir, 60*time.Millisecond, "a:1")
+
+	held, release, ok, err := l.TryLock(context.Background(), "doc.pdf")
+	if err != nil || !ok {
+		t.Fatalf("ok=%v err=%v", ok, err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := held.Err(); err != nil {
+		t.Fatalf("contexto cancelado com o lock em dia: %v", context.Cause(held))
+	}
+	release()
+	if held.Err() == nil {
+		t.Error("release não cancelou o contexto")
+	}
+	if _, err := os.Stat(l.path("doc.pdf")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock não foi apagado: %v", err)
+	}
+}
+
+// O advisory lock precisa de um Postgres: ALANA_TEST_POSTGRES=<dsn>
+func TestPostgresLockTwoHolders(t *testing.T) {
+	dsn := os.Getenv("ALANA_TEST_POSTGRES")
+	if dsn == "" {
+		t.Skip("ALANA_TEST_POSTGRES não definido")
+	}
+	ctx := context.Background()
+	a, err := newPostgresLocker(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := newPostgresLocker(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	source := "lock-test-" + lockNonce()
+	held, releaseA, ok, err := a.TryLock(ctx, source)
+	if err != nil || !ok {
+		t.Fatalf("A: ok=%v err=%v", ok, err)
+	}
+	if _, _, ok, err := b.TryLock(ctx, source); err != nil || ok {
+		t.Fatalf("B pegou o lock de A: ok=%v err=%v", ok, err)
+	}
+	releaseA()
+	if held.Err() == nil {
+		t.Error("release não cancelou o contexto")
+	}
+	_, releaseB, ok, err := b.TryLock(ctx, source)
+	if err != nil || !ok {
+		t.Fatalf("B depois do release: ok=%v err=%v", ok, err)
+	}
+	releaseB()
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"alana_system/yamlite"
+)
+
+// ==============================
+// Metadados de documento
+// ==============================
+
+// DocumentMetadata são os campos padronizados gravados no payload de todos os
+// chunks do documento (filtros, exibição da citação e recência).
+type DocumentMetadata struct {
+	Title     string
+	Author    string
+	CreatedAt time.Time
+	Tags      []string
+}
+
+// payload converte os metadados nos campos do Qdrant. Campos vazios são
+// omitidos para não sobrescrever valores gravados por outras etapas.
+func (m DocumentMetadata) payload() map[string]any {
+	fields := map[string]any{}
+	if m.Title != "" {
+		fields["title"] = m.Title
+	}
+	if m.Author != "" {
+		fields["author"] = m.Author
+	}
+	if !m.CreatedAt.IsZero() {
+		fields["created_at"] = m.CreatedAt.UTC().Format(time.RFC3339)
+		fields["created_ts"] = m.CreatedAt.Unix()
+	}
+	if len(m.Tags) > 0 {
+		fields["tags"] = anyList(m.Tags)
+	}
+	return fields
+}
+
+// maxFrontMatterBytes limita a leitura das notas: o front matter fica no
+// início e as notas podem ter vários GB (exportações, transcrições)
+const maxFrontMatterBytes = 1 << 20
+
+// extractMetadata lê os metadados embutidos no arquivo conforme o tipo
+func extractMetadata(task Task) (DocumentMetadata, error) {
+	if task.Type == "Note" {
+		raw, err := readHead(task.Path, maxFrontMatterBytes)
+		if err != nil {
+			return DocumentMetadata{}, err
+		}
+		return frontMatterMetadata(raw)
+	}
+
+	raw, err := os.ReadFile(task.Path)
+	if err != nil {
+		return DocumentMetadata{}, err
+	}
+
+	switch task.Type {
+	case "PDF":
+		return pdfMetadata(raw), nil
+	case "Audio":
+		return id3Metadata(raw), nil
+	}
+	return DocumentMetadata{}, nil
+}
+
+// readHead lê no máximo n bytes do início do arquivo
+func readHead(path string, n int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, n))
+}
+
+// ==============================
+// Markdown (front matter YAML)
+// ==============================
+
+func frontMatterMetadata(raw []byte) (DocumentMetadata, error) {
+	text := strings.TrimPrefix(string(raw), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if !strings.HasPrefix(text, "---\n") {
+		return DocumentMetadata{}, nil
+	}
+
+	end := strings.Index(text[4:], "\n---")
+	if end < 0 {
+		return DocumentMetadata{}, nil
+	}
+
+	fields, err := yamlite.Parse([]byte(text[4 : 4+end]))
+	if err != nil {
+		return DocumentMetadata{}, fmt.Errorf("front matter: %w", err)
+	}
+
+	var m DocumentMetadata
+	m.Title = stringField(fields, "title")
+	m.Author = stringField(fields, "author", "authors")
+	for _, key := range []string{"date", "created", "created_at"} {
+		if t, ok := parseDate(stringField(fields, key)); ok {
+			m.CreatedAt = t
+			break
+		}
+	}
+
+	switch tags := fields["tags"].(type) {
+	case []any:
+		for _, t := range tags {
+			m.Tags = append(m.Tags, fmt.Sprint(t))
+		}
+	case string:
+		for _, t := range strings.Split(tags, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				m.Tags = append(m.Tags, t)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// stringField devolve a primeira chave presente; listas viram "a, b"
+func stringField(fields map[string]any, keys ...string) string {
+	for _, key := range keys {
+		switch v := fields[key].(type) {
+		case nil:
+			continue
+		case []any:
+			parts := make([]string, 0, len(v))
+			for _, item := range v {
+				parts = append(parts, fmt.Sprint(item))
+			}
+			return strings.Join(parts, ", ")
+		default:
+			return strings.TrimSpace(fmt.Sprint(v))
+		}
+	}
+	return ""
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"02/01/2006",
+	"2006",
+}
+
+func parseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ==============================
+// PDF (XMP e dicionário Info)
+// ==============================
+
+var (
+	xmpTitlePattern   = regexp.MustCompile(`(?s)<dc:title>.*?<rdf:li[^>]*>(.*?)</rdf:li>`)
+	xmpCreatorPattern = regexp.MustCompile(`(?s)<dc:creator>.*?<rdf:li[^>]*>(.*?)</rdf:li>`)
+	xmpCreatePattern  = regexp.MustCompile(`<xmp:CreateDate>([^<]+)</xmp:CreateDate>|xmp:CreateDate="([^"]+)"`)
+	pdfDatePattern    = regexp.MustCompile(`^D:(\d{4})(\d{2})?(\d{2})?(\d{2})?(\d{2})?(\d{2})?`)
+)
+
+// pdfMetadata prefere o XMP (que costuma vir sem compressão) e completa com o
+// dicionário Info do trailer.
+func pdfMetadata(raw []byte) DocumentMetadata {
+	var m DocumentMetadata
+
+	if match := xmpTitlePattern.FindSubmatch(raw); match != nil {
+		m.Title = xmlText(match[1])
+	}
+	if match := xmpCreatorPattern.FindSubmatch(raw); match != nil {
+		m.Author = xmlText(match[1])
+	}
+	if match := xmpCreatePattern.FindSubmatch(raw); match != nil {
+		value := string(match[1])
+		if value == "" {
+			value = string(match[2])
+		}
+		if t, ok := parseDate(value); ok {
+			m.CreatedAt = t
+		}
+	}
+
+	if m.Title == "" {
+		m.Title = pdfInfoString(raw, "Title")
+	}
+	if m.Author == "" {
+		m.Author = pdfInfoString(raw, "Author")
+	}
+	if m.CreatedAt.IsZero() {
+		if match := pdfDatePattern.FindStringSubmatch(pdfInfoString(raw, "CreationDate")); match != nil {
+			m.CreatedAt = pdfDate(match)
+		}
+	}
+
+	return m
+}
+
+func xmlText(b []byte) string {
+	r := strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'")
+	return strings.TrimSpace(r.Replace(string(b)))
+}
+
+// pdfInfoString lê /Key (texto) ou /Key <hex> do dicionário Info
+func pdfInfoString(raw []byte, key string) string {
+	marker := []byte("/" + key)
+	idx := bytes.Index(raw, marker)
+	for idx >= 0 {
+		rest := bytes.TrimLeft(raw[idx+len(marker):], " \r\n\t")
+		if len(rest) > 0 && rest[0] == '(' {
+			return decodePDFText(pdfLiteralString(rest[1:]))
+		}
+		if len(rest) > 0 && rest[0] == '<' && (len(rest) < 2 || rest[1] != '<') {
+			end := bytes.IndexByte(rest, '>')
+			if end > 0 {
+				digits := string(bytes.Join(bytes.Fields(rest[1:end]), nil))
+				if len(digits)%2 == 1 {
+					digits += "0"
+				}
+				if decoded, err := hex.DecodeString(digits); err == nil {
+					return decodePDFText(decoded)
+				}
+			}
+		}
+
+		next := bytes.Index(raw[idx+len(marker):], marker)
+		if next < 0 {
+			break
+		}
+		idx += len(marker) + next
+	}
+	return ""
+}
+
+// pdfLiteralString lê uma string literal de PDF até o ")" que a fecha,
+// tratando parênteses aninhados e escapes.
+func pdfLiteralString(b []byte) []byte {
+	var out []byte
+	depth := 0
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c == '\\' && i+1 < len(b):
+			i++
+			switch b[i] {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				j := i
+				for j < len(b) && j < i+3 && b[j] >= '0' && b[j] <= '7' {
+					j++
+				}
+				n, _ := strconv.ParseUint(string(b[i:j]), 8, 8)
+				out = append(out, byte(n))
+				i = j - 1
+			default:
+				out = append(out, b[i])
+			}
+		case c == '(':
+			depth++
+			out = append(out, c)
+		case c == ')':
+			if depth == 0 {
+				return out
+			}
+			depth--
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// decodePDFText trata o BOM UTF-16BE; o resto é PDFDocEncoding (~Latin-1)
+func decodePDFText(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		return strings.TrimSpace(decodeUTF16(b[2:], binary.BigEndian))
+	}
+	return strings.TrimSpace(latin1(b))
+}
+
+func pdfDate(match []string) time.Time {
+	part := func(i, def int) int {
+		if match[i] == "" {
+			return def
+		}
+		n, _ := strconv.Atoi(match[i])
+		return n
+	}
+	return time.Date(part(1, 1970), time.Month(part(2, 1)), part(3, 1), part(4, 0), part(5, 0), part(6, 0), 0, time.UTC)
+}
+
+// ==============================
+// Áudio (ID3v2 e ID3v1)
+// ==============================
+
+func id3Metadata(raw []byte) DocumentMetadata {
+	if m, ok := id3v2Metadata(raw); ok {
+		return m
+	}
+
+	// ID3v1: 128 bytes no fim do arquivo
+	if len(raw) >= 128 && string(raw[len(raw)-128:len(raw)-125]) == "TAG" {
+		tag := raw[len(raw)-128:]
+		m := DocumentMetadata{
+			Title:  strings.TrimRight(latin1(tag[3:33]), "\x00 "),
+			Author: strings.TrimRight(latin1(tag[33:63]), "\x00 "),
+		}
+		if t, ok := parseDate(strings.TrimRight(string(tag[93:97]), "\x00 ")); ok {
+			m.CreatedAt = t
+		}
+		return m
+	}
+
+	return DocumentMetadata{}
+}
+
+func id3v2Metadata(raw []byte) (DocumentMetadata, bool) {
+	if len(raw) < 10 || string(raw[:3]) != "ID3" {
+		return DocumentMetadata{}, false
+	}
+
+	version := raw[3]
+	if version != 3 && version != 4 {
+		return DocumentMetadata{}, false
+	}
+	size := syncsafe(raw[6:10])
+	r := bytes.NewReader(raw[10:min(len(raw), 10+size)])
+
+	var m DocumentMetadata
+	header := make([]byte, 10)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil || header[0] == 0 {
+			break
+		}
+
+		id := string(header[:4])
+		frameSize := int(binary.BigEndian.Uint32(header[4:8]))
+		if version == 4 {
+			frameSize = syncsafe(header[4:8])
+		}
+		if frameSize <= 0 || frameSize > r.Len() {
+			break
+		}
+
+		data := make([]byte, frameSize)
+		if _, err := io.ReadFull(r, data); err != nil {
+			break
+		}
+
+		switch id {
+		case "TIT2":
+			m.Title = id3Text(data)
+		case "TPE1":
+			m.Author = id3Text(data)
+		case "TDRC", "TYER":
+			if t, ok := parseDate(id3Text(data)); ok {
+				m.CreatedAt = t
+			}
+		}
+	}
+
+	return m, m.Title != "" || m.Author != "" || !m.CreatedAt.IsZero()
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+// id3Text decodifica um frame de texto conforme o byte de encoding
+func id3Text(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	body := data[1:]
+	var s string
+	switch data[0] {
+	case 1: // UTF-16 com BOM
+		if len(body) >= 2 && body[0] == 0xFF && body[1] == 0xFE {
+			s = decodeUTF16(body[2:], binary.LittleEndian)
+		} else if len(body) >= 2 {
+			s = decodeUTF16(body[2:], binary.BigEndian)
+		}
+	case 2: // UTF-16BE
+		s = decodeUTF16(body, binary.BigEndian)
+	case 3: // UTF-8
+		s = string(body)
+	default: // ISO-8859-1
+		s = latin1(body)
+	}
+
+	// Frames com vários valores usam \x00 como separador
+	s, _, _ = strings.Cut(s, "\x00")
+	return strings.TrimSpace(s)
+}
+
+func decodeUTF16(b []byte, order binary.ByteOrder) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, order.Uint16(b[i:]))
+	}
+	return string(utf16.Decode(units))
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"alana_system/chunker"
+	"alana_system/chunkid"
+	"alana_system/lexical"
+	"alana_system/manifest"
+	"alana_system/sidecarproto"
+	"alana_system/vecenc"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Notas (.txt/.md) sem o processor.py
+// ==============================
+//
+// As notas são limpas, divididas (pacote chunker), vetorizadas pelo sidecar
+// (/embed/batch) e gravadas em staging aqui mesmo, com o mesmo payload e os
+// mesmos IDs do vector_store.py (desde que -chunk-sentences continue
+// desligado). PDFs e áudios continuam no processor.py.
+//
+// O caminho Python continua sendo usado para as notas quando há algo que só
+// ele faz: texto fora do payload ou comprimido (ALANA_TEXT_STORE,
+// ALANA_TEXT_COMPRESSION), dual-write e arquivos grandes (lidos em streaming
+// pelo NoteExtractor). A extração de entidades para o grafo também é só do
+// Python: com -native-notes=false as notas voltam a passar por ele.
+
+const (
+	// nativeNoteMaxBytes é o maior arquivo lido inteiro na memória (o mesmo
+	// STREAM_THRESHOLD_BYTES do processor.py)
+	nativeNoteMaxBytes = 64 << 20
+	// embedBatchSize é quantos trechos vão em cada chamada ao /embed/batch
+	embedBatchSize = 64
+	// upsertBatchSize é quantos pontos vão em cada upsert (como o vector_store.py)
+	upsertBatchSize = 100
+)
+
+// noteIngester grava as notas direto no Qdrant
+type noteIngester struct {
+	store      *pointStore
+	rawDir     string
+	sidecarURL string
+	chunking   chunker.Options
+	// datatype é o das collections criadas aqui (ALANA_VECTOR_DATATYPE)
+	datatype string
+	http     *http.Client
+}
+
+// native diz se a nota pode ser ingerida sem o processor.py e, se não, por quê
+func (n *noteIngester) native(task Task, mirror *pointStore) (bool, string) {
+	switch {
+	case task.Type != "Note":
+		return false, ""
+	case n == nil:
+		return false, "desligado (-native-notes=false)"
+	case os.Getenv("ALANA_TEXT_STORE") != "" || os.Getenv("ALANA_TEXT_COMPRESSION") != "":
+		return false, "texto fora do payload ou comprimido"
+	case mirror != nil:
+		return false, "dual-write ligado"
+	}
+	info, err := os.Stat(task.Path)
+	if err == nil && info.Size() > nativeNoteMaxBytes {
+		return false, "arquivo grande (streaming no Python)"
+	}
+	return true, ""
+}
+
+// ingest grava os chunks da nota com staging=true e a versão informada
+// (chunks que já existem só recebem a versão, como no _stage_existing do
+// vector_store.py). A publicação é do pipeline, como no caminho Python.
+func (n *noteIngester) ingest(ctx context.Context, task Task, version string) (int, error) {
+	data, err := os.ReadFile(task.Path)
+	if err != nil {
+		return 0, err
+	}
+	text := chunker.Clean(chunker.NormalizeNote(string(data)))
+	chunks, err := chunker.Split([]chunker.Page{{Number: 1, Text: text}}, chunkid.Source(n.rawDir, task.Path), n.chunking)
+	if err != nil {
+		return 0, err
+	}
+	if len(chunks) == 0 {
+		return 0, nil
+	}
+
+	// Os vetores vêm do sidecar no datatype da collection e são decodificados
+	// num único slab, reaproveitado de um lote para o outro: o upsert termina
+	// antes do lote seguinte, e o cliente do Qdrant não copia os vetores.
+	dtype, err := n.store.vectorDatatype(ctx, n.datatype)
+	if err != nil {
+		return 0, err
+	}
+	var slab []float32
+	fileName := filepath.Base(task.Path)
+	for start := 0; start < len(chunks); start += upsertBatchSize {
+		batch := chunks[start:min(start+upsertBatchSize, len(chunks))]
+		var vectors [][]float32
+		var model string
+		vectors, slab, model, err = n.embed(ctx, batch, dtype, slab[:0])
+		if err != nil {
+			return 0, err
+		}
+		if start == 0 {
+			if err := n.store.ensureCollection(ctx, len(vectors[0]), n.datatype); err != nil {
+				return 0, err
+			}
+		}
+		if err := n.store.stageChunks(ctx, fileName, version, batch, vectors, n.provenance(model)); err != nil {
+			return 0, err
+		}
+	}
+	return len(chunks), nil
+}
+
+type embedBatchRequest struct {
+	Texts    []string `json:"texts"`
+	Encoding string   `json:"encoding,omitempty"`
+}
+
+// embedBatchResponse traz os vetores em Vectors (JSON) ou concatenados em Data
+// (ver vecenc), conforme o sidecar entendeu o Encoding
+type embedBatchResponse struct {
+	Vectors [][]float32 `json:"vectors"`
+	Data    string      `json:"data,omitempty"`
+	Dtype   string      `json:"dtype,omitempty"`
+	Dim     int         `json:"dim,omitempty"`
+	// Model é o modelo de embedding usado (sidecars antigos não informam)
+	Model string `json:"model,omitempty"`
+}
+
+// embed vetoriza os trechos no sidecar, em lotes de embedBatchSize, no
+// formato binário dtype. Os componentes são acrescentados a slab e os vetores
+// devolvidos são fatias dele; o slab (talvez realocado) volta para ser
+// reaproveitado. model é o modelo de embedding informado pelo sidecar.
+func (n *noteIngester) embed(ctx context.Context, chunks []chunker.Chunk, dtype string, slab []float32) (vectors [][]float32, _ []float32, model string, err error) {
+	dim := 0
+	for start := 0; start < len(chunks); start += embedBatchSize {
+		batch := chunks[start:min(start+embedBatchSize, len(chunks))]
+		texts := make([]string, len(batch))
+		for i, c := range batch {
+			texts[i] = c.Text
+		}
+		body, err := json.Marshal(embedBatchRequest{Texts: texts, Encoding: dtype})
+		if err != nil {
+			return nil, nil, "", err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.sidecarURL+"/embed/batch", bytes.NewReader(body))
+		if err != nil {
+			return nil, nil, "", err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		sidecarproto.Offer(req.Header)
+		resp, err := n.http.Do(req)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("sidecar embed: %w", err)
+		}
+		var out embedBatchResponse
+		if resp.StatusCode != http.StatusOK {
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+			resp.Body.Close()
+			return nil, nil, "", fmt.Errorf("sidecar embed: status %d: %s", resp.StatusCode, msg)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&out)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("sidecar embed: %w", err)
+		}
+
+		before := len(slab)
+		if out.Data != "" {
+			if slab, err = vecenc.Decode(slab, out.Data, out.Dtype); err != nil {
+				return nil, nil, "", fmt.Errorf("sidecar embed: %w", err)
+			}
+			if out.Dim <= 0 || (len(slab)-before) != out.Dim*len(batch) {
+				return nil, nil, "", fmt.Errorf("sidecar embed: %d componentes para %d trechos de dimensão %d", len(slab)-before, len(batch), out.Dim)
+			}
+		} else {
+			// Sidecar sem o formato binário
+			if len(out.Vectors) != len(batch) {
+				return nil, nil, "", fmt.Errorf("sidecar embed: %d vetores para %d trechos", len(out.Vectors), len(batch))
+			}
+			out.Dim = len(out.Vectors[0])
+			for _, v := range out.Vectors {
+				if len(v) != out.Dim {
+					return nil, nil, "", fmt.Errorf("sidecar embed: vetores com dimensões diferentes")
+				}
+				slab = append(slab, v...)
+			}
+		}
+		if dim != 0 && out.Dim != dim {
+			return nil, nil, "", fmt.Errorf("sidecar embed: dimensão mudou de %d para %d", dim, out.Dim)
+		}
+		dim = out.Dim
+		model = out.Model
+	}
+
+	// As fatias saem só no fim: o append pode ter realocado o slab
+	vectors = make([][]float32, len(chunks))
+	for i := range vectors {
+		vectors[i] = slab[i*dim : (i+1)*dim : (i+1)*dim]
+	}
+	return vectors, slab, model, nil
+}
+
+// vectorDatatype é o datatype do vetor da collection, ou o configurado se ela
+// ainda não existe (será criada com ele, ver ensureCollection)
+func (s *pointStore) vectorDatatype(ctx context.Context, configured string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return "", fmt.Errorf("qdrant collection exists failed: %w", err)
+	}
+	if !exists {
+		return configured, nil
+	}
+	info, err := s.client.GetCollectionInfo(ctx, s.collection)
+	if err != nil {
+		return "", fmt.Errorf("qdrant collection info failed: %w", err)
+	}
+	if info.GetConfig().GetParams().GetVectorsConfig().GetParams().GetDatatype() == qdrant.Datatype_Float16 {
+		return vecenc.Float16, nil
+	}
+	return vecenc.Float32, nil
+}
+
+// ensureCollection cria a collection como o vector_store.py (vetor denso
+// COSINE no datatype informado e vetor esparso da busca híbrida) se ela ainda
+// não existir, e confere a dimensão se existir
+func (s *pointStore) ensureCollection(ctx context.Context, dim int, datatype string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	exists, err := s.client.CollectionExists(ctx, s.collection)
+	if err != nil {
+		return fmt.Errorf("qdrant collection exists failed: %w", err)
+	}
+	if exists {
+		info, err := s.client.GetCollectionInfo(ctx, s.collection)
+		if err != nil {
+			return fmt.Errorf("qdrant collection info failed: %w", err)
+		}
+		if size := info.GetConfig().GetParams().GetVectorsConfig().GetParams().GetSize(); size != uint64(dim) {
+			return fmt.Errorf("embedding com dimensão %d, collection %s tem %d", dim, s.collection, size)
+		}
+		return nil
+	}
+
+	err = s.client.CreateCollection(ctx, &qdrant.CreateCollection{
+		CollectionName: s.collection,
+		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
+			Size:     uint64(dim),
+			Distance: qdrant.Distance_Cosine,
+			Datatype: qdrantDatatype(datatype).Enum(),
+		}),
+		SparseVectorsConfig: qdrant.NewSparseVectorsConfig(map[string]*qdrant.SparseVectorParams{
+			lexical.VectorName: {Modifier: qdrant.Modifier_Idf.Enum()},
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("qdrant create collection failed: %w", err)
+	}
+	ingestLog.Info("Collection criada", "collection", s.collection, "dim", dim, "datatype", datatype)
+	return s.ensureIndexes(ctx, map[string]qdrant.FieldType{
+		"text":      qdrant.FieldType_FieldTypeText,
+		"file_name": qdrant.FieldType_FieldTypeKeyword,
+	})
+}
+
+func qdrantDatatype(datatype string) qdrant.Datatype {
+	if datatype == vecenc.Float16 {
+		return qdrant.Datatype_Float16
+	}
+	return qdrant.Datatype_Float32
+}
+
+// hasSparseVector diz se a collection tem o vetor esparso da busca híbrida
+func (s *pointStore) hasSparseVector(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	info, err := s.client.GetCollectionInfo(ctx, s.collection)
+	if err != nil {
+		return false, fmt.Errorf("qdrant collection info failed: %w", err)
+	}
+	_, ok := info.GetConfig().GetParams().GetSparseVectorsConfig().GetMap()[lexical.VectorName]
+	return ok, nil
+}
+
+// stageChunks grava um lote de chunks da versão em staging. Os que já existem
+// (mesmo ID = mesmo conteúdo) só recebem a versão: sobrescrevê-los com
+// staging=true os esconderia das buscas até o commit.
+func (s *pointStore) stageChunks(ctx context.Context, fileName, version string, chunks []chunker.Chunk, vectors [][]float32, prov manifest.Provenance) error {
+	hybrid, err := s.hasSparseVector(ctx)
+	if err != nil {
+		return err
+	}
+	provenance, err := provenancePayload(prov)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	ids := make([]*qdrant.PointId, len(chunks))
+	for i, c := range chunks {
+		ids[i] = qdrant.NewID(chunkid.PointID(c.ID))
+	}
+	existing, err := s.client.Get(ctx, &qdrant.GetPoints{CollectionName: s.collection, Ids: ids})
+	if err != nil {
+		return fmt.Errorf("qdrant get failed: %w", err)
+	}
+	staged := map[string]bool{}
+	var restage []*qdrant.PointId
+	for _, p := range existing {
+		staged[p.GetId().GetUuid()] = true
+		restage = append(restage, p.GetId())
+	}
+
+	var ops []*qdrant.PointsUpdateOperation
+	if len(restage) > 0 {
+		ops = append(ops, qdrant.NewPointsUpdateSetPayload(&qdrant.PointsUpdateOperation_SetPayload{
+			Payload:        qdrant.NewValueMap(map[string]any{"ingest_version": version}),
+			PointsSelector: qdrant.NewPointsSelectorIDs(restage),
+		}))
+	}
+
+	var points []*qdrant.PointStruct
+	for i, c := range chunks {
+		if staged[ids[i].GetUuid()] {
+			continue
+		}
+		vector := qdrant.NewVectors(vectors[i]...)
+		if hybrid {
+			indices, values := lexical.Document(c.Text)
+			vector = qdrant.NewVectorsMap(map[string]*qdrant.Vector{
+				"":                 qdrant.NewVectorDense(vectors[i]),
+				lexical.VectorName: qdrant.NewVectorSparse(indices, values),
+			})
+		}
+		points = append(points, &qdrant.PointStruct{
+			Id:      ids[i],
+			Vectors: vector,
+			Payload: qdrant.NewValueMap(map[string]any{
+				"original_id":    c.ID,
+				"page_number":    c.Page,
+				"file_name":      fileName,
+				"text":           c.Text,
+				"staging":        true,
+				"ingest_version": version,
+				"provenance":     provenance,
+			}),
+		})
+	}
+	if len(points) > 0 {
+		ops = append(ops, qdrant.NewPointsUpdateUpsert(&qdrant.PointsUpdateOperation_PointStructList{Points: points}))
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	_, err = s.client.UpdateBatch(ctx, &qdrant.UpdateBatchPoints{
+		CollectionName: s.collection,
+		Wait:           qdrant.PtrOf(true),
+		Operations:     ops,
+	})
+	if err != nil {
+		return fmt.Errorf("qdrant upsert failed: %w", err)
+	}
+	return nil
+}
+
+// newNoteIngester monta o caminho nativo das notas (nil se desligado)
+func newNoteIngester(enabled bool, store *pointStore, rawDir, sidecarURL string, opts chunker.Options) (*noteIngester, error) {
+	if !enabled {
+		return nil, nil
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	datatype, err := vecenc.DatatypeForCollection(store.collection, os.Getenv("ALANA_VECTOR_DATATYPE"))
+	if err != nil {
+		return nil, fmt.Errorf("ALANA_VECTOR_DATATYPE: %w", err)
+	}
+	return &noteIngester{
+		store:      store,
+		rawDir:     rawDir,
+		sidecarURL: sidecarURL,
+		chunking:   opts,
+		datatype:   datatype,
+		http:       &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
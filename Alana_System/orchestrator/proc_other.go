@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// shutdownSignals iniciam o desligamento gracioso (ver shutdown.go)
+var shutdownSignals = []os.Signal{os.Interrupt}
+
+// detach não faz nada fora do Unix e do Windows: o processor.py recebe o
+// Ctrl+C junto com o ingestor
+func detach(cmd *exec.Cmd) {}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// shutdownSignals iniciam o desligamento gracioso (ver shutdown.go)
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// detach põe o processo num grupo próprio: o Ctrl+C do terminal chega só ao
+// ingestor, que decide quando interromper o processor.py (ver shutdown.go)
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// shutdownSignals iniciam o desligamento gracioso (ver shutdown.go). No
+// Windows, o Go entrega Ctrl+C, Ctrl+Break e o fechamento do console como
+// os.Interrupt; não há SIGTERM.
+var shutdownSignals = []os.Signal{os.Interrupt}
+
+// detach cria o processo num grupo de console próprio: como no Unix, o
+// Ctrl+C chega só ao ingestor, que decide quando interromper o processor.py
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"alana_system/logging"
+)
+
+// ==============================
+// Progresso da ingestão
+// ==============================
+
+const (
+	// progressInterval é a frequência com que a barra é redesenhada
+	progressInterval = 200 * time.Millisecond
+	// progressBarWidth é a largura da barra em caracteres
+	progressBarWidth = 30
+)
+
+// outcome é o resultado da ingestão de um arquivo
+type outcome int
+
+const (
+	outcomeIngested outcome = iota
+	// outcomeSkipped: inalterado desde a última ingestão ou com outra
+	// instância ingerindo
+	outcomeSkipped
+	outcomeFailed
+)
+
+func (o outcome) String() string {
+	switch o {
+	case outcomeIngested:
+		return "ingested"
+	case outcomeSkipped:
+		return "skipped"
+	}
+	return "failed"
+}
+
+// ingestResult é o que um worker reporta ao terminar um arquivo
+type ingestResult struct {
+	Outcome outcome
+	Chunks  int
+	Err     error
+}
+
+// workerStats são os números de um worker
+type workerStats struct {
+	ID      int           `json:"id"`
+	Files   int           `json:"files"`
+	Skipped int           `json:"skipped"`
+	Failed  int           `json:"failed"`
+	Chunks  int           `json:"chunks"`
+	Busy    time.Duration `json:"-"`
+	BusyMS  int64         `json:"busy_ms"`
+}
+
+// ingestFailure é um arquivo que falhou, para o resumo e o relatório
+type ingestFailure struct {
+	Path   string `json:"path"`
+	Worker int    `json:"worker"`
+	Error  string `json:"error"`
+}
+
+// progress acompanha a ingestão: arquivos na fila, ingeridos, pulados e com
+// falha, chunks criados e o tempo ocupado de cada worker. Com live, desenha
+// uma barra na última linha do terminal (stderr), redesenhada a cada
+// progressInterval; os logs passam por progressWriter, que apaga a barra,
+// escreve e a desenha de novo, para os dois não se misturarem.
+type progress struct {
+	mu       sync.Mutex
+	start    time.Time
+	queued   int
+	done     int
+	workers  map[int]*workerStats
+	failures []ingestFailure
+
+	live  bool
+	term  io.Writer
+	drawn bool
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// ingestProgress é o progresso da execução atual (nil antes do main criá-lo;
+// progressWriter funciona sem ele)
+var ingestProgress *progress
+
+func newProgress(workers int, live bool) *progress {
+	p := &progress{start: time.Now(), workers: map[int]*workerStats{}, live: live, term: os.Stderr, stop: make(chan struct{})}
+	for id := 1; id <= workers; id++ {
+		p.workers[id] = &workerStats{ID: id}
+	}
+	if live {
+		p.wg.Add(1)
+		go p.loop()
+	}
+	return p
+}
+
+// isTerminal diz se f é um terminal (e não um arquivo ou pipe)
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ingestLog é o logger do orchestrator (component=ingestor)
+var ingestLog = logging.For("ingestor")
+
+// workerLog é o logger de um worker: cada linha leva worker=<id>
+func workerLog(id int) *slog.Logger {
+	return ingestLog.With("worker", id)
+}
+
+// progressWriter é a saída dos logs (ver logging.Options): escreve sem
+// atropelar a barra de progresso
+type progressWriter struct{}
+
+func (progressWriter) Write(b []byte) (int, error) {
+	p := ingestProgress
+	if p == nil || !p.live {
+		return os.Stderr.Write(b)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+	n, err := p.term.Write(b)
+	p.draw()
+	return n, err
+}
+
+func (p *progress) queue() {
+	p.mu.Lock()
+	p.queued++
+	p.mu.Unlock()
+}
+
+func (p *progress) finish(workerID int, path string, res ingestResult, elapsed time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	w := p.worker(workerID)
+	w.Busy += elapsed
+	p.done++
+	switch res.Outcome {
+	case outcomeIngested:
+		w.Files++
+		w.Chunks += res.Chunks
+	case outcomeSkipped:
+		w.Skipped++
+	case outcomeFailed:
+		w.Failed++
+		msg := "erro desconhecido"
+		if res.Err != nil {
+			msg = res.Err.Error()
+		}
+		p.failures = append(p.failures, ingestFailure{Path: path, Worker: workerID, Error: msg})
+	}
+}
+
+func (p *progress) worker(id int) *workerStats {
+	w, ok := p.workers[id]
+	if !ok {
+		w = &workerStats{ID: id}
+		p.workers[id] = w
+	}
+	return w
+}
+
+// close para a barra e a apaga
+func (p *progress) close() {
+	if !p.live {
+		return
+	}
+	close(p.stop)
+	p.wg.Wait()
+	p.mu.Lock()
+	p.clear()
+	p.mu.Unlock()
+}
+
+func (p *progress) loop() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			p.clear()
+			p.draw()
+			p.mu.Unlock()
+		}
+	}
+}
+
+// clear apaga a barra (com p.mu)
+func (p *progress) clear() {
+	if p.drawn {
+		fmt.Fprint(p.term, "\r\033[K")
+		p.drawn = false
+	}
+}
+
+// draw desenha a barra (com p.mu)
+func (p *progress) draw() {
+	fmt.Fprint(p.term, p.bar(time.Now()))
+	p.drawn = true
+}
+
+// bar é a linha de progresso: barra, contagem, falhas, chunks, tempo e a
+// estimativa do que falta (pela média até aqui)
+func (p *progress) bar(now time.Time) string {
+	t := p.totals()
+	filled := 0
+	if p.queued > 0 {
+		filled = progressBarWidth * p.done / p.queued
+	}
+	elapsed := now.Sub(p.start)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s%s] %d/%d arquivos", strings.Repeat("█", filled), strings.Repeat("░", progressBarWidth-filled), p.done, p.queued)
+	if t.Failed > 0 {
+		fmt.Fprintf(&b, " · %d falhas", t.Failed)
+	}
+	fmt.Fprintf(&b, " · %d chunks · %s", t.Chunks, formatElapsed(elapsed))
+	if p.done > 0 && p.done < p.queued {
+		eta := time.Duration(float64(elapsed) / float64(p.done) * float64(p.queued-p.done))
+		fmt.Fprintf(&b, " · falta ~%s", formatElapsed(eta))
+	}
+	return b.String()
+}
+
+// totals soma os workers (com p.mu)
+func (p *progress) totals() workerStats {
+	var t workerStats
+	for _, w := range p.workers {
+		t.Files += w.Files
+		t.Skipped += w.Skipped
+		t.Failed += w.Failed
+		t.Chunks += w.Chunks
+		t.Busy += w.Busy
+	}
+	return t
+}
+
+func (p *progress) sortedWorkers() []*workerStats {
+	ids := slices.Sorted(maps.Keys(p.workers))
+	out := make([]*workerStats, len(ids))
+	for i, id := range ids {
+		out[i] = p.workers[id]
+	}
+	return out
+}
+
+// summary é a tabela final, por worker e total, seguida das falhas
+func (p *progress) summary(now time.Time) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 Resumo da ingestão: %d arquivos em %s\n\n", p.queued, formatElapsed(now.Sub(p.start)))
+	fmt.Fprintf(&b, "%-8s %9s %8s %7s %8s %10s\n", "Worker", "Ingeridos", "Pulados", "Falhas", "Chunks", "Ocupado")
+	row := func(name string, w workerStats) {
+		fmt.Fprintf(&b, "%-8s %9d %8d %7d %8d %10s\n", name, w.Files, w.Skipped, w.Failed, w.Chunks, w.Busy.Round(100*time.Millisecond))
+	}
+	for _, w := range p.sortedWorkers() {
+		row(fmt.Sprint(w.ID), *w)
+	}
+	row("Total", p.totals())
+	if len(p.failures) > 0 {
+		b.WriteString("\n❌ Falhas:\n")
+		for _, f := range p.failures {
+			fmt.Fprintf(&b, "   %s: %s\n", f.Path, firstLine(f.Error))
+		}
+	}
+	return b.String()
+}
+
+// progressReport é o relatório do -json
+type progressReport struct {
+	Started   time.Time       `json:"started"`
+	Finished  time.Time       `json:"finished"`
+	ElapsedMS int64           `json:"elapsed_ms"`
+	Queued    int             `json:"queued"`
+	Ingested  int             `json:"ingested"`
+	Skipped   int             `json:"skipped"`
+	Failed    int             `json:"failed"`
+	Chunks    int             `json:"chunks"`
+	Workers   []workerStats   `json:"workers"`
+	Failures  []ingestFailure `json:"failures"`
+}
+
+func (p *progress) report(now time.Time) progressReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t := p.totals()
+	r := progressReport{
+		Started:   p.start.UTC(),
+		Finished:  now.UTC(),
+		ElapsedMS: now.Sub(p.start).Milliseconds(),
+		Queued:    p.queued,
+		Ingested:  t.Files,
+		Skipped:   t.Skipped,
+		Failed:    t.Failed,
+		Chunks:    t.Chunks,
+		Failures:  append([]ingestFailure{}, p.failures...),
+	}
+	for _, w := range p.sortedWorkers() {
+		ws := *w
+		ws.BusyMS = ws.Busy.Milliseconds()
+		r.Workers = append(r.Workers, ws)
+	}
+	return r
+}
+
+// writeReport grava o relatório em JSON ("-" = saída padrão)
+func (p *progress) writeReport(path string, now time.Time) error {
+	data, err := json.MarshalIndent(p.report(now), "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// formatElapsed mostra uma duração como mm:ss (ou h:mm:ss)
+func formatElapsed(d time.Duration) string {
+	s := int(d.Round(time.Second).Seconds())
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%02d:%02d", s/60, s%60)
+}
+
+// firstLine é a primeira linha de uma mensagem (erros do Python vêm com a
+// saída inteira)
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+// lastLine é a última linha não vazia da saída (o erro, num traceback do
+// Python)
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"alana_system/chunker"
+	"alana_system/manifest"
+	"alana_system/schema"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Proveniência dos chunks
+// ==============================
+
+// enricherSteps são as etapas do enricher registradas na proveniência
+var enricherSteps = []string{"metadata", "links", "references"}
+
+// buildRevision é o commit do binário (vcs.revision), a versão do extrator
+// das notas em Go
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" && len(s.Value) >= 12 {
+			return s.Value[:12]
+		}
+	}
+	return "dev"
+}
+
+// chunkerParams são as opções do chunking no formato da proveniência
+func chunkerParams(opts chunker.Options) *manifest.ChunkerParams {
+	return &manifest.ChunkerParams{MaxChars: opts.MaxChars, OverlapChars: opts.OverlapChars, Sentences: opts.Sentences}
+}
+
+// provenancePayload converte a proveniência no formato do payload
+func provenancePayload(p manifest.Provenance) (map[string]any, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// setProvenance completa a proveniência gravada pelo extrator nos chunks da
+// versão em staging (os campos de p que não são vazios; os outros ficam)
+func (s *pointStore) setProvenance(ctx context.Context, fileName, version string, p manifest.Provenance) error {
+	fields, err := provenancePayload(p)
+	if err != nil {
+		return err
+	}
+	payload, err := qdrant.TryValueMap(fields)
+	if err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	_, err = s.client.SetPayload(ctx, &qdrant.SetPayloadPoints{
+		CollectionName: s.collection,
+		Wait:           qdrant.PtrOf(true),
+		Payload:        payload,
+		Key:            qdrant.PtrOf(schema.ProvenanceField),
+		PointsSelector: qdrant.NewPointsSelectorFilter(versionFilter(fileName, version)),
+	})
+	if err != nil {
+		return fmt.Errorf("qdrant set payload failed: %w", err)
+	}
+	return nil
+}
+
+// provenance lê a proveniência de um chunk da versão: todos os chunks de
+// uma ingestão têm a mesma, a menos do EmbeddedAt dos reaproveitados
+func (s *pointStore) provenance(ctx context.Context, fileName, version string) (*manifest.Provenance, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	resp, err := s.client.GetPointsClient().Scroll(ctx, &qdrant.ScrollPoints{
+		CollectionName: s.collection,
+		Filter:         versionFilter(fileName, version),
+		Limit:          qdrant.PtrOf(uint32(1)),
+		WithPayload:    qdrant.NewWithPayloadInclude(schema.ProvenanceField),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("qdrant scroll failed: %w", err)
+	}
+	if len(resp.GetResult()) == 0 {
+		return nil, nil
+	}
+	value, ok := resp.GetResult()[0].GetPayload()[schema.ProvenanceField]
+	if !ok {
+		return nil, nil
+	}
+	data, err := json.Marshal(schema.Plain(value))
+	if err != nil {
+		return nil, err
+	}
+	var p manifest.Provenance
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("proveniência inválida: %w", err)
+	}
+	return &p, nil
+}
+
+// provenance é a proveniência gravada pelo extrator das notas em Go
+func (n *noteIngester) provenance(embedModel string) manifest.Provenance {
+	return manifest.Provenance{
+		Extractor:        "notes-go",
+		ExtractorVersion: buildRevision(),
+		Chunker:          chunkerParams(n.chunking),
+		EmbedModel:       embedModel,
+		EmbeddedAt:       time.Now().UTC(),
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==============================
+// Workers Python persistentes
+// ==============================
+
+const (
+	// pyStopTimeout é quanto um worker Python tem para sair depois que a
+	// entrada dele é fechada
+	pyStopTimeout = 10 * time.Second
+	// pyStderrLines é quantas linhas do stderr ficam guardadas para explicar
+	// um worker que morreu
+	pyStderrLines = 20
+)
+
+// pyRequest é um pedido ao `processor.py --serve` (uma linha JSON)
+type pyRequest struct {
+	ID            int    `json:"id"`
+	Type          string `json:"type"`
+	Path          string `json:"path"`
+	Collection    string `json:"collection,omitempty"`
+	IngestVersion string `json:"ingest_version,omitempty"`
+}
+
+// pyReply é a resposta a um pedido, ou o {"ready": true} do início
+type pyReply struct {
+	ID    int    `json:"id"`
+	Ready bool   `json:"ready"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error"`
+}
+
+// pyPool mantém um `processor.py --serve` por worker Go, iniciado no
+// primeiro documento que precisa do Python: os modelos (Whisper, embedding)
+// são carregados uma vez por processo, e não a cada arquivo. Um processo que
+// morre (ou é interrompido pelo cancelamento) é iniciado de novo no próximo
+// documento.
+type pyPool struct {
+	runner  processorRunner
+	verbose bool
+	mu      sync.Mutex
+	workers map[int]*pyWorker
+}
+
+func newPyPool(runner processorRunner, verbose bool) *pyPool {
+	return &pyPool{runner: runner, verbose: verbose, workers: map[int]*pyWorker{}}
+}
+
+// process grava os chunks do documento em staging, na collection informada,
+// pelo worker Python do worker Go workerID
+func (p *pyPool) process(ctx context.Context, workerID int, task Task, collection, version string) error {
+	p.mu.Lock()
+	w := p.workers[workerID]
+	p.mu.Unlock()
+
+	if w == nil || w.dead() {
+		workerLog(workerID).Info("Iniciando o worker Python (carrega os modelos uma vez)")
+		var err error
+		if w, err = startPyWorker(ctx, workerID, p.runner, p.verbose); err != nil {
+			return err
+		}
+		p.mu.Lock()
+		p.workers[workerID] = w
+		p.mu.Unlock()
+	}
+
+	workerLog(workerID).Info("Processando", "type", task.Type, "path", task.Path)
+	relativePath, err := filepath.Rel(".", task.Path)
+	if err != nil {
+		return err
+	}
+	err = w.call(ctx, pyRequest{Type: task.Type, Path: p.runner.path(relativePath), Collection: collection, IngestVersion: version})
+	if err != nil {
+		workerLog(workerID).Error("Erro crítico no Worker", "path", task.Path, "err", err)
+	}
+	return err
+}
+
+// close encerra os workers Python: fecha a entrada de cada um e espera
+// (até pyStopTimeout) que terminem
+func (p *pyPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, w := range p.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.stop()
+		}()
+	}
+	wg.Wait()
+	clear(p.workers)
+}
+
+// pyWorker é um `processor.py --serve`. Atende um pedido por vez (o do seu
+// worker Go).
+type pyWorker struct {
+	id     int
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	exited chan struct{}
+	nextID int
+
+	mu     sync.Mutex
+	stderr []string
+}
+
+// startPyWorker inicia o processo e espera o {"ready": true}, enviado
+// depois que os modelos carregam
+func startPyWorker(ctx context.Context, id int, runner processorRunner, verbose bool) (*pyWorker, error) {
+	// O processo vive além de ctx (é interrompido por kill)
+	cmd, err := runner.command(context.Background(), "--serve")
+	if err != nil {
+		return nil, err
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	w := &pyWorker{id: id, cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout), exited: make(chan struct{})}
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		sc := bufio.NewScanner(stderr)
+		sc.Buffer(make([]byte, 64<<10), 1<<20)
+		for sc.Scan() {
+			w.remember(sc.Text())
+			if verbose {
+				workerLog(id).Info("Python", "line", sc.Text())
+			}
+		}
+	}()
+	go func() {
+		<-stderrDone
+		cmd.Wait()
+		close(w.exited)
+	}()
+
+	reply, err := w.read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("worker Python não iniciou: %w", err)
+	}
+	if !reply.Ready {
+		w.kill()
+		return nil, errors.New("worker Python não iniciou: resposta inesperada")
+	}
+	return w, nil
+}
+
+// call envia um pedido e espera a resposta. Cancelar ctx mata o processo: o
+// documento fica pela metade em staging, e o ingest faz o rollback.
+func (w *pyWorker) call(ctx context.Context, req pyRequest) error {
+	w.nextID++
+	req.ID = w.nextID
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if _, err := w.stdin.Write(append(data, '\n')); err != nil {
+		return w.crashed(err)
+	}
+	reply, err := w.read(ctx)
+	if err != nil {
+		return err
+	}
+	if reply.ID != req.ID {
+		w.kill()
+		return fmt.Errorf("worker Python respondeu o pedido %d em vez do %d", reply.ID, req.ID)
+	}
+	if !reply.OK {
+		workerLog(w.id).Warn("Saída do Python", "output", strings.TrimSpace(reply.Error))
+		return fmt.Errorf("processor.py: %s", lastLine(reply.Error))
+	}
+	return nil
+}
+
+// read espera a próxima linha de resposta
+func (w *pyWorker) read(ctx context.Context) (pyReply, error) {
+	type result struct {
+		line []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		line, err := w.stdout.ReadBytes('\n')
+		done <- result{line, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return pyReply{}, w.crashed(r.err)
+		}
+		var reply pyReply
+		if err := json.Unmarshal(r.line, &reply); err != nil {
+			w.kill()
+			return pyReply{}, fmt.Errorf("resposta inválida do worker Python: %w", err)
+		}
+		return reply, nil
+	case <-ctx.Done():
+		w.kill()
+		<-done
+		return pyReply{}, ctx.Err()
+	}
+}
+
+// crashed explica a falha de um worker que morreu com as últimas linhas do
+// stderr dele
+func (w *pyWorker) crashed(err error) error {
+	w.kill()
+	w.mu.Lock()
+	tail := strings.Join(w.stderr, "\n")
+	w.mu.Unlock()
+	if tail == "" {
+		return fmt.Errorf("worker Python morreu: %w", err)
+	}
+	return fmt.Errorf("worker Python morreu: %w: %s\n%s", err, lastLine(tail), tail)
+}
+
+func (w *pyWorker) remember(line string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stderr = append(w.stderr, line)
+	if len(w.stderr) > pyStderrLines {
+		w.stderr = w.stderr[len(w.stderr)-pyStderrLines:]
+	}
+}
+
+func (w *pyWorker) dead() bool {
+	select {
+	case <-w.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *pyWorker) kill() {
+	if !w.dead() {
+		killCommand(w.cmd)
+	}
+	<-w.exited
+}
+
+// stop fecha a entrada (o processor.py sai do laço) e espera o processo
+func (w *pyWorker) stop() {
+	w.stdin.Close()
+	select {
+	case <-w.exited:
+	case <-time.After(pyStopTimeout):
+		w.kill()
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ==============================
+// Desligamento gracioso
+// ==============================
+
+// pendingTasksPath guarda os arquivos que ficaram para trás quando a
+// ingestão foi interrompida, para a próxima execução retomar
+var pendingTasksPath = filepath.Join("data", "ingest_pending.json")
+
+// defaultShutdownTimeout é quanto o Ctrl+C espera os arquivos em andamento
+// terminarem antes de interrompê-los
+const defaultShutdownTimeout = 2 * time.Minute
+
+// pendingTasks são as tarefas não concluídas por causa do desligamento: as
+// que estavam na fila e as interrompidas no meio (com rollback, ver ingest)
+type pendingTasks struct {
+	mu    sync.Mutex
+	tasks []Task
+}
+
+func (p *pendingTasks) add(task Task) {
+	p.mu.Lock()
+	p.tasks = append(p.tasks, task)
+	p.mu.Unlock()
+}
+
+func (p *pendingTasks) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.tasks)
+}
+
+// pendingFile é o formato de pendingTasksPath
+type pendingFile struct {
+	Interrupted time.Time `json:"interrupted"`
+	Tasks       []Task    `json:"tasks"`
+}
+
+// save grava as pendências; sem nenhuma, apaga o arquivo de uma execução
+// anterior (ela foi concluída agora)
+func (p *pendingTasks) save(path string, now time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.tasks) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(pendingFile{Interrupted: now.UTC(), Tasks: p.tasks}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"alana_system/config"
+	"alana_system/schema"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Acesso aos pontos de um documento
+// ==============================
+
+// pointStore opera sobre os chunks já gravados pelo processor.py. Os chunks
+// de um documento são localizados pelo campo file_name.
+type pointStore struct {
+	client     *qdrant.Client
+	collection string
+	timeout    time.Duration
+}
+
+func newPointStore(client *qdrant.Client, collection string) *pointStore {
+	return &pointStore{
+		client:     client,
+		collection: collection,
+		timeout:    10 * time.Second,
+	}
+}
+
+func documentFilter(fileName string) *qdrant.Filter {
+	return &qdrant.Filter{
+		Must: []*qdrant.Condition{qdrant.NewMatchKeyword("file_name", fileName)},
+	}
+}
+
+// versionFilter seleciona os chunks de uma versão do documento
+func versionFilter(fileName, version string) *qdrant.Filter {
+	return &qdrant.Filter{
+		Must: []*qdrant.Condition{
+			qdrant.NewMatchKeyword("file_name", fileName),
+			qdrant.NewMatchKeyword("ingest_version", version),
+		},
+	}
+}
+
+// setPayload aplica os campos a todos os chunks do documento
+func (s *pointStore) setPayload(ctx context.Context, fileName string, fields map[string]any) error {
+	payload, err := qdrant.TryValueMap(fields)
+	if err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err = s.client.SetPayload(ctx, &qdrant.SetPayloadPoints{
+		CollectionName: s.collection,
+		Wait:           qdrant.PtrOf(true),
+		Payload:        payload,
+		PointsSelector: qdrant.NewPointsSelectorFilter(documentFilter(fileName)),
+	})
+	if err != nil {
+		return fmt.Errorf("qdrant set payload failed: %w", err)
+	}
+	return nil
+}
+
+// ensureIndexes cria os índices de payload que ainda não existem
+func (s *pointStore) ensureIndexes(ctx context.Context, indexes map[string]qdrant.FieldType) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	info, err := s.client.GetCollectionInfo(ctx, s.collection)
+	if err != nil {
+		return fmt.Errorf("qdrant collection info failed: %w", err)
+	}
+
+	for field, fieldType := range indexes {
+		if _, ok := info.GetPayloadSchema()[field]; ok {
+			continue
+		}
+		_, err := s.client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName: s.collection,
+			Wait:           qdrant.PtrOf(true),
+			FieldName:      field,
+			FieldType:      fieldType.Enum(),
+		})
+		if err != nil {
+			return fmt.Errorf("qdrant create index %s failed: %w", field, err)
+		}
+	}
+	return nil
+}
+
+// ==============================
+// Ingestão transacional por documento
+// ==============================
+//
+// O processor.py grava os chunks novos com staging=true e ingest_version=V
+// (chunks que já existem, com o mesmo conteúdo, só recebem a versão nova).
+// Depois do sucesso, commitDocument torna a versão V visível e apaga as
+// versões antigas numa única chamada em lote, então as buscas nunca veem um
+// documento misturando o chunking antigo e o novo.
+
+// newIngestVersion gera a versão de uma ingestão de documento
+func newIngestVersion() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// commitDocument publica a versão e remove os chunks das versões anteriores
+func (s *pointStore) commitDocument(ctx context.Context, fileName, version string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	current := &qdrant.Filter{
+		Must: []*qdrant.Condition{
+			qdrant.NewMatchKeyword("file_name", fileName),
+			qdrant.NewMatchKeyword("ingest_version", version),
+		},
+	}
+	stale := &qdrant.Filter{
+		Must:    []*qdrant.Condition{qdrant.NewMatchKeyword("file_name", fileName)},
+		MustNot: []*qdrant.Condition{qdrant.NewMatchKeyword("ingest_version", version)},
+	}
+
+	_, err := s.client.UpdateBatch(ctx, &qdrant.UpdateBatchPoints{
+		CollectionName: s.collection,
+		Wait:           qdrant.PtrOf(true),
+		Operations: []*qdrant.PointsUpdateOperation{
+			qdrant.NewPointsUpdateSetPayload(&qdrant.PointsUpdateOperation_SetPayload{
+				Payload:        qdrant.NewValueMap(map[string]any{"staging": false}),
+				PointsSelector: qdrant.NewPointsSelectorFilter(current),
+			}),
+			qdrant.NewPointsUpdateDeletePoints(&qdrant.PointsUpdateOperation_DeletePoints{
+				Points: qdrant.NewPointsSelectorFilter(stale),
+			}),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("qdrant commit failed: %w", err)
+	}
+	return nil
+}
+
+// rollbackDocument descarta os chunks em staging de uma ingestão que falhou.
+// Os chunks publicados continuam intactos.
+func (s *pointStore) rollbackDocument(ctx context.Context, fileName, version string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: s.collection,
+		Wait:           qdrant.PtrOf(true),
+		Points: qdrant.NewPointsSelectorFilter(&qdrant.Filter{
+			Must: []*qdrant.Condition{
+				qdrant.NewMatchKeyword("file_name", fileName),
+				qdrant.NewMatchKeyword("ingest_version", version),
+				qdrant.NewMatchBool("staging", true),
+			},
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("qdrant rollback failed: %w", err)
+	}
+	return nil
+}
+
+// chunkIDs lista os chunk_ids (original_id) da versão publicada do documento
+func (s *pointStore) chunkIDs(ctx context.Context, fileName, version string) ([]string, error) {
+	filter := versionFilter(fileName, version)
+
+	var ids []string
+	var offset *qdrant.PointId
+	for {
+		pageCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		resp, err := s.client.GetPointsClient().Scroll(pageCtx, &qdrant.ScrollPoints{
+			CollectionName: s.collection,
+			Filter:         filter,
+			Offset:         offset,
+			Limit:          qdrant.PtrOf(uint32(256)),
+			WithPayload:    qdrant.NewWithPayloadInclude("original_id"),
+		})
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("qdrant scroll failed: %w", err)
+		}
+		for _, p := range resp.GetResult() {
+			ids = append(ids, p.GetPayload()["original_id"].GetStringValue())
+		}
+		if offset = resp.GetNextPageOffset(); offset == nil {
+			break
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// offloadedIDs lista os pontos do documento com o texto no text store
+// (text_offloaded), para apagá-los junto com os pontos
+func (s *pointStore) offloadedIDs(ctx context.Context, fileName string) ([]string, error) {
+	filter := documentFilter(fileName)
+	filter.Must = append(filter.Must, qdrant.NewMatchBool("text_offloaded", true))
+
+	var ids []string
+	var offset *qdrant.PointId
+	for {
+		pageCtx, cancel := context.WithTimeout(ctx, s.timeout)
+		resp, err := s.client.GetPointsClient().Scroll(pageCtx, &qdrant.ScrollPoints{
+			CollectionName: s.collection,
+			Filter:         filter,
+			Offset:         offset,
+			Limit:          qdrant.PtrOf(uint32(256)),
+			WithPayload:    qdrant.NewWithPayload(false),
+		})
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("qdrant scroll failed: %w", err)
+		}
+		for _, p := range resp.GetResult() {
+			ids = append(ids, p.GetId().GetUuid())
+		}
+		if offset = resp.GetNextPageOffset(); offset == nil {
+			return ids, nil
+		}
+	}
+}
+
+// deleteDocument apaga todos os chunks do documento (publicados ou não)
+func (s *pointStore) deleteDocument(ctx context.Context, fileName string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.client.Delete(ctx, &qdrant.DeletePoints{
+		CollectionName: s.collection,
+		Wait:           qdrant.PtrOf(true),
+		Points:         qdrant.NewPointsSelectorFilter(documentFilter(fileName)),
+	})
+	if err != nil {
+		return fmt.Errorf("qdrant delete failed: %w", err)
+	}
+	return nil
+}
+
+// ==============================
+// Collections por tipo de documento
+// ==============================
+
+// typedStores são as collections da ingestão: a principal e as de cada tipo
+// de documento separado em collections_by_type (ver config.CollectionsByType)
+type typedStores struct {
+	main *pointStore
+	// byType é a collection de cada content_type separado (pdf, audio, note)
+	byType map[string]*pointStore
+}
+
+// newTypedStores monta um pointStore por collection, compartilhado entre os
+// tipos que apontam para a mesma
+func newTypedStores(client *qdrant.Client, cfg config.Config) typedStores {
+	stores := typedStores{main: newPointStore(client, cfg.Collection), byType: map[string]*pointStore{}}
+	for _, docType := range config.DocumentTypes {
+		if collection := cfg.TypeCollection(docType); collection != cfg.Collection {
+			store := stores.forCollection(collection)
+			if store == nil {
+				store = newPointStore(client, collection)
+			}
+			stores.byType[docType] = store
+		}
+	}
+	return stores
+}
+
+// forTask é a collection do tipo do arquivo
+func (s typedStores) forTask(task Task) *pointStore {
+	return s.forType(schema.ContentType(task.Path))
+}
+
+// forType é a collection do content_type (a principal se não for separado)
+func (s typedStores) forType(contentType string) *pointStore {
+	if store, ok := s.byType[contentType]; ok {
+		return store
+	}
+	return s.main
+}
+
+// forCollection é o store da collection, ou nil se ela não é da ingestão
+func (s typedStores) forCollection(collection string) *pointStore {
+	if s.main.collection == collection {
+		return s.main
+	}
+	for _, store := range s.byType {
+		if store.collection == collection {
+			return store
+		}
+	}
+	return nil
+}
+
+// all são as collections da ingestão, a principal primeiro e sem repetir
+func (s typedStores) all() []*pointStore {
+	stores := []*pointStore{s.main}
+	for _, docType := range config.DocumentTypes {
+		if store, ok := s.byType[docType]; ok && !slices.Contains(stores, store) {
+			stores = append(stores, store)
+		}
+	}
+	return stores
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ==============================
+// Modo watch
+// ==============================
+
+// watchPollInterval é o intervalo da varredura nos sistemas sem inotify
+// (ver watch_other.go)
+const watchPollInterval = 2 * time.Second
+
+// watch mantém o orchestrator rodando depois da primeira ingestão: os
+// arquivos criados ou alterados em root são enfileirados quando ficam
+// debounce sem novos eventos (cópias longas e editores que gravam várias
+// vezes geram um único ingest). Se algum arquivo sumiu, chama onRemove.
+// Volta quando ctx é cancelado.
+func watch(ctx context.Context, root string, debounce time.Duration, tasks chan<- Task, onRemove func()) error {
+	changes := make(chan string, 64)
+	errc := make(chan error, 1)
+	go func() { errc <- watchTree(ctx, root, changes) }()
+
+	ticker := time.NewTicker(max(debounce/4, 50*time.Millisecond))
+	defer ticker.Stop()
+
+	pending := map[string]time.Time{}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errc:
+			return err
+		case path := <-changes:
+			pending[path] = time.Now()
+		case now := <-ticker.C:
+			removed := false
+			for path, last := range pending {
+				if now.Sub(last) < debounce {
+					continue
+				}
+				delete(pending, path)
+
+				if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+					removed = true
+					continue
+				}
+				task, ok := taskForPath(path)
+				if !ok {
+					continue
+				}
+				ingestLog.Info("Alterado, enfileirando", "path", path)
+				// A fila é pequena: com os workers ocupados, o watch espera
+				// (os eventos seguintes aguardam no canal changes)
+				if err := enqueue(ctx, tasks, task); err != nil {
+					return nil
+				}
+			}
+			if removed && onRemove != nil {
+				onRemove()
+			}
+		}
+	}
+}
+
+// emitFiles manda para changes todos os arquivos suportados em root (um
+// diretório novo, ou a árvore inteira depois de perder eventos). O
+// manifesto filtra os que não mudaram.
+func emitFiles(ctx context.Context, root string, changes chan<- string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if _, ok := taskForPath(path); !ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case changes <- path:
+			return nil
+		}
+	})
+}
@@ -0,0 +1,111 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// watchMask são os eventos observados em cada diretório. Arquivos entram
+// quando terminam de ser gravados (IN_CLOSE_WRITE) ou são movidos para lá,
+// não no IN_CREATE, para não ingerir um arquivo pela metade.
+const watchMask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM |
+	syscall.IN_CREATE | syscall.IN_DELETE
+
+// watchTree observa root e seus subdiretórios com inotify e manda para
+// changes o caminho de cada arquivo criado, alterado ou removido
+func watchTree(ctx context.Context, root string, changes chan<- string) error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("inotify: %w", err)
+	}
+	// Não bloqueante, o fd entra no poller do runtime: Close desbloqueia o Read
+	f := os.NewFile(uintptr(fd), "inotify")
+	defer f.Close()
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	dirs := map[int32]string{}
+	addTree := func(dir string) error {
+		return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return err
+			}
+			wd, err := syscall.InotifyAddWatch(fd, path, watchMask)
+			if err != nil {
+				return fmt.Errorf("inotify %s: %w", path, err)
+			}
+			dirs[int32(wd)] = path
+			return nil
+		})
+	}
+	if err := addTree(root); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("inotify: %w", err)
+		}
+
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameStart := off + syscall.SizeofInotifyEvent
+			off = nameStart + int(ev.Len)
+
+			if ev.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				// Eventos perdidos: revarre tudo
+				if err := emitFiles(ctx, root, changes); err != nil {
+					return nil
+				}
+				continue
+			}
+			if ev.Mask&syscall.IN_IGNORED != 0 {
+				delete(dirs, ev.Wd)
+				continue
+			}
+			dir, ok := dirs[ev.Wd]
+			if !ok || ev.Len == 0 {
+				continue
+			}
+			path := filepath.Join(dir, string(bytes.TrimRight(buf[nameStart:off], "\x00")))
+
+			isDir := ev.Mask&syscall.IN_ISDIR != 0
+			if isDir && ev.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				// Diretório novo (ou movido para cá): observa e ingere o conteúdo
+				if err := addTree(path); err != nil {
+					ingestLog.Warn("Não foi possível observar o diretório novo", "err", err)
+				}
+				if err := emitFiles(ctx, path, changes); err != nil {
+					return nil
+				}
+				continue
+			}
+			// Arquivo criado ainda em gravação: espera o IN_CLOSE_WRITE. Um
+			// diretório removido segue adiante, para o purge dos arquivos dele.
+			if ev.Mask&syscall.IN_CREATE != 0 || isDir && ev.Mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM) == 0 {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case changes <- path:
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+//go:build !linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// fileState é o que a varredura compara entre uma passada e outra
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// watchTree varre root a cada watchPollInterval (sem inotify) e manda para
+// changes o caminho de cada arquivo criado, alterado ou removido
+func watchTree(ctx context.Context, root string, changes chan<- string) error {
+	seen, err := scanTree(root)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := scanTree(root)
+		if err != nil {
+			return err
+		}
+		var changed []string
+		for path, st := range current {
+			if prev, ok := seen[path]; !ok || prev != st {
+				changed = append(changed, path)
+			}
+		}
+		for path := range seen {
+			if _, ok := current[path]; !ok {
+				changed = append(changed, path)
+			}
+		}
+		seen = current
+
+		for _, path := range changed {
+			select {
+			case <-ctx.Done():
+				return nil
+			case changes <- path:
+			}
+		}
+	}
+}
+
+// scanTree lê o mtime e o tamanho dos arquivos suportados em root
+func scanTree(root string) (map[string]fileState, error) {
+	files := map[string]fileState{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if _, ok := taskForPath(path); !ok {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// Removido durante a varredura
+			return nil
+		} else if err != nil {
+			return err
+		}
+		files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"alana_system/config"
+)
+
+// ==============================
+// Override de provedor/modelo por pedido
+// ==============================
+
+var (
+	errOverrideForbidden = errors.New("api key sem permissão para override de modelo")
+	errOverrideNotListed = errors.New("provedor/modelo fora da allowlist")
+)
+
+// overridePolicy decide quem pode trocar o modelo de geração e para quais
+// modelos. É lida do ambiente:
+//
+//	ALANA_PRIVILEGED_KEYS        chaves de API autorizadas (separadas por vírgula)
+//	ALANA_GENERATION_ALLOWLIST   pares "provedor/modelo" permitidos (separados por vírgula;
+//	                             sem provedor, vale o generation_provider do config)
+type overridePolicy struct {
+	privilegedKeys map[string]bool
+	allowed        map[generationOverride]bool
+}
+
+func overridePolicyFromEnv() *overridePolicy {
+	p := &overridePolicy{
+		privilegedKeys: map[string]bool{},
+		allowed:        map[generationOverride]bool{},
+	}
+	for _, key := range splitList(os.Getenv("ALANA_PRIVILEGED_KEYS")) {
+		p.privilegedKeys[key] = true
+	}
+	for _, entry := range splitList(os.Getenv("ALANA_GENERATION_ALLOWLIST")) {
+		provider, model, ok := strings.Cut(entry, "/")
+		if !ok {
+			provider, model = defaultGenerationProvider, entry
+		}
+		p.allowed[generationOverride{Provider: provider, Model: model}] = true
+	}
+	return p
+}
+
+// authorize valida o override pedido com a chave informada. Provedor vazio
+// significa o generation_provider do config.
+func (p *overridePolicy) authorize(apiKey string, o generationOverride) (generationOverride, error) {
+	if o.Provider == "" {
+		o.Provider = defaultGenerationProvider
+	}
+	if apiKey == "" || !p.privilegedKeys[apiKey] {
+		return o, errOverrideForbidden
+	}
+	if !p.allowed[o] {
+		return o, errOverrideNotListed
+	}
+	if !slices.Contains(config.GenerationProviders, o.Provider) {
+		return o, fmt.Errorf("provedor %q não disponível", o.Provider)
+	}
+	return o, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// ==============================
+// Log de auditoria
+// ==============================
+
+const auditLogPath = "./data/audit.log"
+
+// auditEntry é uma linha JSON do log de auditoria. A chave de API nunca é
+// gravada, só a sua impressão digital.
+type auditEntry struct {
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	KeyID    string    `json:"key_id,omitempty"`
+	Provider string    `json:"provider,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Allowed  bool      `json:"allowed"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+// keyFingerprint identifica uma chave no log sem expô-la
+func keyFingerprint(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:6])
+}
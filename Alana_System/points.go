@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Operações em lote sobre pontos
+// ==============================
+
+const (
+	scrollPageSize  = 256
+	payloadBatchLen = 256
+)
+
+// visibleFilter esconde os chunks em staging (ingestões ainda não publicadas
+// pelo orchestrator), somando as exclusões extras recebidas.
+func visibleFilter(mustNot ...*qdrant.Condition) *qdrant.Filter {
+	return &qdrant.Filter{
+		MustNot: append([]*qdrant.Condition{qdrant.NewMatchBool("staging", true)}, mustNot...),
+	}
+}
+
+// scrollPoints percorre todos os pontos visíveis da collection em páginas,
+// chamando fn para cada página. Cada página tem o seu próprio timeout.
+func (e *AlanaEngine) scrollPoints(
+	ctx context.Context,
+	withVectors bool,
+	fn func(points []*qdrant.RetrievedPoint) error,
+) error {
+	return e.scrollPages(ctx, visibleFilter(), nil, withVectors,
+		func(points []*qdrant.RetrievedPoint, _ *qdrant.PointId) error {
+			return fn(points)
+		})
+}
+
+// scrollPages percorre os pontos que casam com filter a partir de offset
+// (nil = início). fn recebe também o offset da próxima página (nil na última),
+// o que permite retomar uma varredura interrompida.
+func (e *AlanaEngine) scrollPages(
+	ctx context.Context,
+	filter *qdrant.Filter,
+	offset *qdrant.PointId,
+	withVectors bool,
+	fn func(points []*qdrant.RetrievedPoint, next *qdrant.PointId) error,
+) error {
+
+	limit := uint32(scrollPageSize)
+
+	for {
+		pageCtx, cancel := context.WithTimeout(ctx, e.timeout)
+		points, next, err := e.client.ScrollAndOffset(pageCtx, &qdrant.ScrollPoints{
+			CollectionName: e.collection,
+			Filter:         filter,
+			Offset:         offset,
+			Limit:          &limit,
+			WithPayload:    qdrant.NewWithPayload(true),
+			WithVectors:    qdrant.NewWithVectors(withVectors),
+		})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("qdrant scroll failed: %w", err)
+		}
+
+		if len(points) > 0 {
+			if err := fn(points, next); err != nil {
+				return err
+			}
+		}
+
+		if next == nil {
+			return nil
+		}
+		offset = next
+	}
+}
+
+// denseVector extrai o vetor denso (sem nome) de um ponto. Em collections
+// com o vetor esparso da busca híbrida, o denso vem no mapa, com nome "".
+func denseVector(v *qdrant.VectorsOutput) []float32 {
+	out := v.GetVector()
+	if named, ok := v.GetVectors().GetVectors()[""]; ok {
+		out = named
+	}
+	if d := out.GetDense(); d != nil {
+		return d.GetData()
+	}
+	return out.GetData()
+}
+
+// setPayload grava os campos em todos os pontos informados, em lotes
+func (e *AlanaEngine) setPayload(ctx context.Context, ids []*qdrant.PointId, fields map[string]any) error {
+	if err := e.writable(); err != nil {
+		return err
+	}
+	payload, err := qdrant.TryValueMap(fields)
+	if err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	for start := 0; start < len(ids); start += payloadBatchLen {
+		end := min(start+payloadBatchLen, len(ids))
+
+		batchCtx, cancel := context.WithTimeout(ctx, e.timeout)
+		_, err := e.client.SetPayload(batchCtx, &qdrant.SetPayloadPoints{
+			CollectionName: e.collection,
+			Wait:           qdrant.PtrOf(true),
+			Payload:        payload,
+			PointsSelector: qdrant.NewPointsSelector(ids[start:end]...),
+		})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("qdrant set payload failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ensureFieldIndex cria o índice de payload se ele ainda não existir
+func (e *AlanaEngine) ensureFieldIndex(ctx context.Context, field string, fieldType qdrant.FieldType) error {
+	if err := e.writable(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	info, err := e.client.GetCollectionInfo(ctx, e.collection)
+	if err != nil {
+		return fmt.Errorf("qdrant collection info failed: %w", err)
+	}
+	if _, ok := info.GetPayloadSchema()[field]; ok {
+		return nil
+	}
+
+	_, err = e.client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+		CollectionName: e.collection,
+		Wait:           qdrant.PtrOf(true),
+		FieldName:      field,
+		FieldType:      fieldType.Enum(),
+	})
+	if err != nil {
+		return fmt.Errorf("qdrant create index failed: %w", err)
+	}
+	return nil
+}
+
+// offloadedPointIDs lista os pontos da collection com o texto no text store
+// (text_offloaded), para apagá-los junto com ela
+func (e *AlanaEngine) offloadedPointIDs(ctx context.Context, collection string) ([]string, error) {
+	filter := &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatchBool("text_offloaded", true)}}
+	limit := uint32(scrollPageSize)
+
+	var ids []string
+	var offset *qdrant.PointId
+	for {
+		pageCtx, cancel := context.WithTimeout(ctx, e.timeout)
+		points, next, err := e.client.ScrollAndOffset(pageCtx, &qdrant.ScrollPoints{
+			CollectionName: collection,
+			Filter:         filter,
+			Offset:         offset,
+			Limit:          &limit,
+			WithPayload:    qdrant.NewWithPayload(false),
+		})
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("qdrant scroll failed: %w", err)
+		}
+		for _, p := range points {
+			ids = append(ids, pointIDString(p.GetId()))
+		}
+		if next == nil {
+			return ids, nil
+		}
+		offset = next
+	}
+}
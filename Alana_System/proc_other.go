@@ -0,0 +1,17 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+func detachProcess(cmd *exec.Cmd) {}
+
+// terminateProcess mata o processo: sem grupos de processo nem SIGTERM, não
+// há como pedir que ele saia
+func terminateProcess(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+func killProcess(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
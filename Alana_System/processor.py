@@ -1,6 +1,9 @@
+import os
 import sys
+import json
 import argparse
 import logging
+import traceback
 from pathlib import Path
 
 # Garante que o Python encontre o pacote alana_system
@@ -10,34 +13,111 @@ from alana_system.ingestion.pdf_loader import PDFDocument
 from alana_system.ingestion.audio_loader import AudioDocument
 from run_ingestion import IngestionPipeline
 
-def main():
-    parser = argparse.ArgumentParser()
-    parser.add_argument("--type", required=True, choices=["PDF", "Audio", "Note"])
-    parser.add_argument("--path", required=True)
-    args = parser.parse_args()
+RAW_DIR = Path("data/raw")
+
+# Notas maiores que isso são processadas em streaming (memória limitada).
+# As menores continuam no caminho normal, que mantém os IDs dos chunks.
+STREAM_THRESHOLD_BYTES = 64 * 1024 * 1024
+
+
+def source_path_for(path: Path) -> str:
+    """Caminho relativo a data/raw com "/", mesmo formato do chunkid.Source do Go."""
+    try:
+        return path.resolve().relative_to(RAW_DIR.resolve()).as_posix()
+    except ValueError:
+        return path.as_posix()
 
-    # Inicializa o pipeline (reutilizando sua lógica atual)
-    pipeline = IngestionPipeline(
+
+def new_pipeline() -> IngestionPipeline:
+    return IngestionPipeline(
         raw_dir="data/raw",
-        collection_name="alana_knowledge_base"
+        # O orchestrator repassa a collection configurada (ALANA_COLLECTION)
+        collection_name=os.environ.get("ALANA_COLLECTION", "alana_knowledge_base")
     )
 
-    path = Path(args.path)
-    
-    if args.type == "PDF":
+
+def process(pipeline: IngestionPipeline, doc_type: str, path: Path, ingest_version=None, collection=None):
+    # A collection do tipo (collections_by_type); sem ela, a de ALANA_COLLECTION
+    pipeline.use_collection(collection)
+    if doc_type == "PDF":
         print(f"--- Processando PDF: {path.name} ---")
         pages = pipeline.pdf_extractor.extract(path)
-        pipeline._process_pages(pages, path.name, source="pdf")
-    
-    elif args.type == "Audio":
+        pipeline._process_document_pages(pages, path.name, source="pdf",
+                                          source_path=source_path_for(path), ingest_version=ingest_version)
+
+    elif doc_type == "Audio":
         print(f"--- Processando Áudio: {path.name} ---")
         pages = pipeline.audio_transcriber.transcribe(path)
-        pipeline._process_pages(pages, path.name, source="audio")
+        pipeline._process_document_pages(pages, path.name, source="audio",
+                                          source_path=source_path_for(path), ingest_version=ingest_version)
 
-    elif args.type == "Note":
+    elif doc_type == "Note":
         print(f"--- Processando Nota: {path.name} ---")
-        pages = pipeline.note_extractor.extract(path)
-        pipeline._process_pages(pages, path.name, source="note")
+        if path.stat().st_size > STREAM_THRESHOLD_BYTES:
+            sections = pipeline.note_extractor.iter_sections(path)
+            pipeline._process_document_stream(sections, path.name, source="note",
+                                              source_path=source_path_for(path), ingest_version=ingest_version)
+        else:
+            pages = pipeline.note_extractor.extract(path)
+            pipeline._process_document_pages(pages, path.name, source="note",
+                                              source_path=source_path_for(path), ingest_version=ingest_version)
+
+    else:
+        raise ValueError(f"tipo desconhecido: {doc_type!r}")
+
+
+def serve():
+    """
+    Modo worker persistente (--serve), usado pelo orchestrator Go: carrega os
+    modelos uma vez e processa um documento por linha JSON da entrada padrão
+    ({"id", "type", "path", "collection", "ingest_version"}), respondendo uma linha por
+    pedido ({"id", "ok", "error"}). As respostas vão pelo stdout original; o
+    resto (prints, logs, bibliotecas em C) passa a ir para o stderr.
+    """
+    proto = os.fdopen(os.dup(sys.stdout.fileno()), "w", buffering=1, encoding="utf-8")
+    sys.stdout.flush()
+    os.dup2(sys.stderr.fileno(), sys.stdout.fileno())
+
+    def reply(message: dict):
+        proto.write(json.dumps(message) + "\n")
+
+    pipeline = new_pipeline()
+    reply({"ready": True})
+
+    for line in sys.stdin:
+        if not line.strip():
+            continue
+        req = None
+        try:
+            req = json.loads(line)
+            process(pipeline, req["type"], Path(req["path"]), req.get("ingest_version"), req.get("collection"))
+        except Exception:
+            reply({"id": req.get("id") if isinstance(req, dict) else None, "ok": False, "error": traceback.format_exc()})
+        else:
+            reply({"id": req.get("id"), "ok": True})
+        finally:
+            sys.stdout.flush()
+
+
+def main():
+    parser = argparse.ArgumentParser()
+    parser.add_argument("--serve", action="store_true",
+                        help="worker persistente: pedidos em JSON pela entrada padrão (ver serve)")
+    parser.add_argument("--type", choices=["PDF", "Audio", "Note"])
+    parser.add_argument("--path")
+    parser.add_argument("--collection", default=None,
+                        help="collection de destino (padrão: ALANA_COLLECTION)")
+    parser.add_argument("--ingest-version", default=None,
+                        help="grava os chunks em staging com esta versão (publicada pelo orchestrator)")
+    args = parser.parse_args()
+
+    if args.serve:
+        serve()
+        return
+    if not args.type or not args.path:
+        parser.error("--type e --path são obrigatórios (ou use --serve)")
+
+    process(new_pipeline(), args.type, Path(args.path), args.ingest_version, args.collection)
 
 if __name__ == "__main__":
-    main()
\ No newline at end of file
+    main()
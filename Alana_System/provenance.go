@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Proveniência das respostas
+// ==============================
+//
+// Com ALANA_PROVENANCE_KEY, cada resposta do /ask (e do /ask/stream e do
+// /v1/query/audio) termina com um trailer legível por máquina, um comentário
+// HTML que o Markdown e o HTML não mostram:
+//
+//	<!-- alana-provenance {"v":1,"model":"sidecar","corpus":"...","sha256":"...","sig":"..."} -->
+//
+// O trailer leva o modelo, a versão do corpus (os trechos usados), a hora, a
+// versão do binário e o hash do texto, assinados com HMAC-SHA256. Uma cópia
+// da resposta pode ser conferida em POST /v1/provenance/verify: o texto
+// alterado muda o hash, o trailer alterado invalida a assinatura, e uma
+// resposta cujos trechos saíram da collection (documento reingerido ou
+// apagado) é velha.
+
+const (
+	provenanceVersion = 1
+	provenancePrefix  = "<!-- alana-provenance "
+	provenanceSuffix  = " -->"
+	// minProvenanceKeyBytes é o tamanho mínimo da chave do HMAC
+	minProvenanceKeyBytes = 16
+	// maxVerifyRunes limita o texto enviado ao /v1/provenance/verify. As
+	// respostas longas (geração por seções) passam do maxRequestBytes, então
+	// o endpoint aceita um corpo maior: maxVerifyBytes cobre as runas em
+	// UTF-8 com folga para os escapes do JSON.
+	maxVerifyRunes = 200_000
+	maxVerifyBytes = 1 << 20
+)
+
+// answerProvenance é o conteúdo do trailer
+type answerProvenance struct {
+	Version int `json:"v"`
+	// Model é o provedor de geração, com o modelo se não for o padrão dele
+	Model      string `json:"model"`
+	Collection string `json:"collection"`
+	// Corpus é a versão do corpus da resposta: o hash dos IDs dos trechos
+	// recuperados. Os IDs mudam com o conteúdo (ver chunkid), então uma
+	// re-ingestão que muda os documentos muda a versão.
+	Corpus string    `json:"corpus"`
+	Chunks []string  `json:"chunks"`
+	Time   time.Time `json:"ts"`
+	// Orchestrator é o commit do binário que respondeu
+	Orchestrator string `json:"orchestrator"`
+	// ContentHash é o SHA-256 do texto da resposta, sem o trailer (ver
+	// provenanceContent)
+	ContentHash string `json:"sha256"`
+	Signature   string `json:"sig,omitempty"`
+}
+
+// provenanceSigner assina e confere os trailers
+type provenanceSigner struct {
+	key []byte
+}
+
+// provenanceSignerFromEnv lê ALANA_PROVENANCE_KEY (vazio = sem trailer)
+func provenanceSignerFromEnv() (*provenanceSigner, error) {
+	key := os.Getenv("ALANA_PROVENANCE_KEY")
+	if key == "" {
+		return nil, nil
+	}
+	if len(key) < minProvenanceKeyBytes {
+		return nil, fmt.Errorf("ALANA_PROVENANCE_KEY precisa de pelo menos %d bytes", minProvenanceKeyBytes)
+	}
+	return &provenanceSigner{key: []byte(key)}, nil
+}
+
+// sign calcula a assinatura do trailer (sem o campo sig)
+func (p *provenanceSigner) sign(prov answerProvenance) string {
+	prov.Signature = ""
+	data, _ := json.Marshal(prov)
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// stamp acrescenta o trailer ao texto da resposta e o devolve também à parte
+func (p *provenanceSigner) stamp(text, collection, model string, sources []SearchResult, now time.Time) (string, *answerProvenance) {
+	chunks := make([]string, 0, len(sources))
+	for _, s := range sources {
+		chunks = append(chunks, s.ID)
+	}
+	prov := &answerProvenance{
+		Version:      provenanceVersion,
+		Model:        model,
+		Collection:   collection,
+		Corpus:       corpusVersion(chunks),
+		Chunks:       chunks,
+		Time:         now.UTC().Truncate(time.Second),
+		Orchestrator: buildRevision(),
+		ContentHash:  contentHash(text),
+	}
+	prov.Signature = p.sign(*prov)
+	trailer, _ := json.Marshal(prov)
+	return text + "\n\n" + provenancePrefix + string(trailer) + provenanceSuffix, prov
+}
+
+// corpusVersion é o hash dos IDs dos trechos, sem depender da ordem
+func corpusVersion(chunks []string) string {
+	sorted := slices.Clone(chunks)
+	slices.Sort(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// contentHash é o SHA-256 do texto normalizado (ver provenanceContent)
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(provenanceContent(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// provenanceContent normaliza o que copiar e colar costuma mudar sem mudar
+// a resposta: as quebras de linha do Windows e os espaços nas pontas
+func provenanceContent(text string) string {
+	return strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+}
+
+// splitProvenance separa o texto do último trailer. ok é false se não há
+// trailer; um trailer cortado (sem o fim do comentário) volta vazio, e a
+// verificação o trata como malformado.
+func splitProvenance(text string) (content, trailer string, ok bool) {
+	i := strings.LastIndex(text, provenancePrefix)
+	if i < 0 {
+		return text, "", false
+	}
+	rest := strings.TrimSpace(text[i+len(provenancePrefix):])
+	trailer, closed := strings.CutSuffix(rest, strings.TrimSpace(provenanceSuffix))
+	if !closed {
+		trailer = ""
+	}
+	return text[:i], strings.TrimSpace(trailer), true
+}
+
+// buildRevision é o commit do binário (vcs.revision)
+func buildRevision() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" && len(s.Value) >= 12 {
+			return s.Value[:12]
+		}
+	}
+	return "dev"
+}
+
+// stampAnswer acrescenta o trailer à resposta, se a proveniência está ligada.
+// Perguntas de esclarecimento não são respostas e ficam sem.
+func (s *server) stampAnswer(resp *askResponse, opts askOptions, answer Answer) {
+	if s.provenance == nil || answer.Clarification {
+		return
+	}
+	req := generationRequest("", "", opts.Override, "")
+	model := req.Provider
+	if req.Model != "" {
+		model += "/" + req.Model
+	}
+	resp.Answer, resp.Provenance = s.provenance.stamp(resp.Answer, s.engine.collection, model, answer.Sources, time.Now())
+}
+
+// ==============================
+// Verificação
+// ==============================
+
+// Estados de uma verificação
+const (
+	provenanceValid     = "valid"
+	provenanceStale     = "stale"
+	provenanceTampered  = "tampered"
+	provenanceForged    = "forged"
+	provenanceMissing   = "missing"
+	provenanceMalformed = "malformed"
+)
+
+type verifyProvenanceRequest struct {
+	// Answer é a resposta copiada, com o trailer
+	Answer string `json:"answer"`
+}
+
+type provenanceVerification struct {
+	// Status é valid, stale (autêntica, mas com trechos que saíram da
+	// collection), tampered (texto alterado), forged (trailer alterado ou
+	// de outra chave), missing (sem trailer) ou malformed
+	Status string `json:"status"`
+	// Authentic diz se o texto e o trailer são os que o serve gerou
+	Authentic bool `json:"authentic"`
+	Stale     bool `json:"stale"`
+	// MissingChunks são os trechos da resposta que não existem mais
+	MissingChunks []string          `json:"missing_chunks,omitempty"`
+	Provenance    *answerProvenance `json:"provenance,omitempty"`
+}
+
+// verify confere o texto e o trailer. Não consulta o Qdrant: a resposta
+// autêntica ainda precisa de staleChunks para saber se está velha.
+func (p *provenanceSigner) verify(text string) provenanceVerification {
+	content, trailer, ok := splitProvenance(text)
+	if !ok {
+		return provenanceVerification{Status: provenanceMissing}
+	}
+	var prov answerProvenance
+	if err := json.Unmarshal([]byte(trailer), &prov); err != nil || prov.Version != provenanceVersion {
+		return provenanceVerification{Status: provenanceMalformed}
+	}
+	out := provenanceVerification{Provenance: &prov}
+	switch {
+	case !hmac.Equal([]byte(p.sign(prov)), []byte(prov.Signature)):
+		out.Status = provenanceForged
+	case contentHash(content) != prov.ContentHash:
+		out.Status = provenanceTampered
+	default:
+		out.Status, out.Authentic = provenanceValid, true
+	}
+	return out
+}
+
+// staleChunks devolve os trechos da lista que não existem mais na
+// collection (nem nas separadas por tipo ou na do fallback, de onde a
+// resposta pode ter vindo)
+func (e *AlanaEngine) staleChunks(ctx context.Context, collection string, ids []string) ([]string, error) {
+	missing := ids
+	collections := []string{collection}
+	if collection == e.collection {
+		collections = e.searchCollections(SearchFilter{})
+	}
+	if e.fallback != nil && !slices.Contains(collections, e.fallback.collection) {
+		collections = append(collections, e.fallback.collection)
+	}
+	for _, c := range collections {
+		if len(missing) == 0 {
+			break
+		}
+		found, err := e.withCollection(c).existingChunks(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		missing = slices.DeleteFunc(slices.Clone(missing), func(id string) bool { return found[id] })
+	}
+	return missing, nil
+}
+
+// existingChunks diz quais dos IDs existem na collection. Uma collection que
+// não existe mais não tem nenhum.
+func (e *AlanaEngine) existingChunks(ctx context.Context, ids []string) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	exists, err := e.client.CollectionExists(ctx, e.collection)
+	if err != nil || !exists {
+		return map[string]bool{}, err
+	}
+	pointIDs := make([]*qdrant.PointId, len(ids))
+	for i, id := range ids {
+		pointIDs[i] = qdrant.NewID(id)
+	}
+	points, err := e.client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: e.collection,
+		Ids:            pointIDs,
+		WithPayload:    qdrant.NewWithPayload(false),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("qdrant get failed: %w", err)
+	}
+	found := make(map[string]bool, len(points))
+	for _, p := range points {
+		found[pointIDString(p.GetId())] = true
+	}
+	return found, nil
+}
+
+// handleVerifyProvenance implementa POST /v1/provenance/verify: confere se
+// uma resposta copiada é a que o serve gerou e se ainda vale para o corpus
+// atual
+func (s *server) handleVerifyProvenance(w http.ResponseWriter, r *http.Request) {
+	if s.provenance == nil {
+		writeError(w, http.StatusNotFound, "proveniência desligada (ALANA_PROVENANCE_KEY)")
+		return
+	}
+	var req verifyProvenanceRequest
+	if err := decodeJSONLimit(w, r, &req, maxVerifyBytes); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	if err := validateText("answer", req.Answer, true, maxVerifyRunes); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	out := s.provenance.verify(req.Answer)
+	if out.Authentic {
+		missing, err := s.engine.staleChunks(r.Context(), out.Provenance.Collection, out.Provenance.Chunks)
+		if err != nil {
+			serverLog.ErrorContext(r.Context(), "Erro ao conferir os trechos da resposta", "err", err)
+			writeError(w, http.StatusBadGateway, "falha ao conferir os trechos da resposta")
+			return
+		}
+		if len(missing) > 0 {
+			out.Status, out.Stale, out.MissingChunks = provenanceStale, true, missing
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
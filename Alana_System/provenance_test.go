@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProvenanceVerify(t *testing.T) {
+	signer := &provenanceSigner{key: []byte("chave-de-teste-com-32-bytes-aqui")}
+	other := &provenanceSigner{key: []byte("outra-chave-com-pelo-menos-16")}
+	sources := []SearchResult{{ID: "c2"}, {ID: "c1"}}
+	now := time.Date(2026, 3, 4, 5, 6, 7, 890, time.UTC)
+	answer := "As férias são de 30 dias.\nVer o manual [1]."
+	stamped, prov := signer.stamp(answer, "alana_knowledge_base", "sidecar", sources, now)
+	foreign, _ := other.stamp(answer, "alana_knowledge_base", "sidecar", sources, now)
+	content, trailer, _ := splitProvenance(stamped)
+
+	// edited altera o trailer (sem reassinar) e mantém o texto
+	edited := func(edit func(string) string) string {
+		return content + provenancePrefix + edit(trailer) + provenanceSuffix
+	}
+
+	cases := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"válida", stamped, provenanceValid},
+		{"CRLF", strings.ReplaceAll(stamped, "\n", "\r\n"), provenanceValid},
+		{"espaços nas pontas", "\n  " + stamped + "  \n\n", provenanceValid},
+		{"sem a linha em branco antes do trailer", answer + provenancePrefix + trailer + provenanceSuffix, provenanceValid},
+		{"texto alterado", strings.Replace(stamped, "30 dias", "45 dias", 1), provenanceTampered},
+		{"texto acrescentado", "Resumo: " + stamped, provenanceTampered},
+		{"modelo trocado", edited(func(s string) string { return strings.Replace(s, `"model":"sidecar"`, `"model":"openai"`, 1) }), provenanceForged},
+		{"hash trocado", edited(func(s string) string { return strings.Replace(s, prov.ContentHash, contentHash("outro texto"), 1) }), provenanceForged},
+		{"assinatura removida", edited(func(s string) string { return strings.Replace(s, `"sig":"`+prov.Signature+`"`, `"sig":""`, 1) }), provenanceForged},
+		{"outra chave", foreign, provenanceForged},
+		{"sem trailer", answer, provenanceMissing},
+		{"vazio", "", provenanceMissing},
+		{"json quebrado", content + provenancePrefix + `{"v":1,"model":` + provenanceSuffix, provenanceMalformed},
+		{"versão desconhecida", edited(func(s string) string { return strings.Replace(s, `"v":1`, `"v":2`, 1) }), provenanceMalformed},
+		{"trailer cortado", strings.TrimSuffix(stamped, provenanceSuffix), provenanceMalformed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := signer.verify(tc.text)
+			if got.Status != tc.want {
+				t.Fatalf("status %q, esperado %q", got.Status, tc.want)
+			}
+			if got.Authentic != (tc.want == provenanceValid) {
+				t.Errorf("authentic = %v com status %q", got.Authentic, got.Status)
+			}
+		})
+	}
+
+	got := signer.verify(stamped)
+	if p := got.Provenance; p == nil || p.Corpus != corpusVersion([]string{"c1", "c2"}) || !p.Time.Equal(now.Truncate(time.Second)) || p.Collection != "alana_knowledge_base" {
+		t.Errorf("trailer decodificado: %+v", got.Provenance)
+	}
+}
+
+func TestSplitProvenance(t *testing.T) {
+	cases := []struct {
+		text             string
+		content, trailer string
+		ok               bool
+	}{
+		{"só texto", "só texto", "", false},
+		{"a\n\n<!-- alana-provenance {} -->", "a\n\n", "{}", true},
+		// o último trailer vale: um trailer citado no meio do texto é texto
+		{"a <!-- alana-provenance {\"x\":1} --> b\n<!-- alana-provenance {} -->\n", "a <!-- alana-provenance {\"x\":1} --> b\n", "{}", true},
+		{"a <!-- alana-provenance {}", "a ", "", true},
+	}
+	for _, tc := range cases {
+		content, trailer, ok := splitProvenance(tc.text)
+		if content != tc.content || trailer != tc.trailer || ok != tc.ok {
+			t.Errorf("splitProvenance(%q) = %q, %q, %v", tc.text, content, trailer, ok)
+		}
+	}
+}
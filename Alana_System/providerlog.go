@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================
+// Log de depuração do provedor
+// ==============================
+
+const (
+	providerLogPath = "./data/provider_debug.log"
+
+	// Limites de tamanho: por campo gravado e do arquivo antes de rotacionar
+	providerLogMaxField = 16 << 10
+	providerLogMaxFile  = 50 << 20
+)
+
+// providerLog grava prompts e respostas completos do sidecar para depurar
+// respostas ruins. Desligado por padrão; ALANA_PROVIDER_LOG=1 liga na
+// inicialização e POST /debug/provider-log liga/desliga em tempo de execução.
+// Segredos e dados pessoais são mascarados antes de gravar.
+var providerLog = newProviderLogger(providerLogPath)
+
+type providerLogger struct {
+	enabled atomic.Bool
+	out     *jsonlLog
+}
+
+type providerLogEntry struct {
+	Time      time.Time `json:"time"`
+	Endpoint  string    `json:"endpoint"`
+	Model     string    `json:"model,omitempty"`
+	Query     string    `json:"query"`
+	Context   string    `json:"context"`
+	Answer    string    `json:"answer,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMS int64     `json:"latency_ms"`
+}
+
+func newProviderLogger(path string) *providerLogger {
+	p := &providerLogger{out: &jsonlLog{path: path, maxBytes: providerLogMaxFile}}
+	enabled, _ := strconv.ParseBool(os.Getenv("ALANA_PROVIDER_LOG"))
+	p.enabled.Store(enabled)
+	return p
+}
+
+// record grava uma chamada ao provedor, se o log estiver ligado
+func (p *providerLogger) record(endpoint string, req GenerateRequest, answer string, err error, latency time.Duration) {
+	if !p.enabled.Load() {
+		return
+	}
+
+	entry := providerLogEntry{
+		Time:      time.Now().UTC(),
+		Endpoint:  endpoint,
+		Model:     req.Model,
+		Query:     redactForLog(req.Query),
+		Context:   redactForLog(req.Context),
+		Answer:    redactForLog(answer),
+		LatencyMS: latency.Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = redactForLog(err.Error())
+	}
+
+	if err := p.out.append(entry); err != nil {
+		engineLog.Error("Erro ao gravar log do provedor", "err", err)
+	}
+}
+
+// ==============================
+// Mascaramento
+// ==============================
+
+var redactions = []struct {
+	label   string
+	pattern *regexp.Regexp
+}{
+	{"SECRET", regexp.MustCompile(`(?i)\b(api[_-]?key|token|secret|password|senha)\s*[:=]\s*\S+`)},
+	{"SECRET", regexp.MustCompile(`(?i)\bbearer\s+[a-z0-9._\-]{16,}`)},
+	{"SECRET", regexp.MustCompile(`\b(sk|pk|rk)-[A-Za-z0-9_\-]{16,}\b`)},
+	{"SECRET", regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`)},
+	{"EMAIL", regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+	{"CPF", regexp.MustCompile(`\b\d{3}\.?\d{3}\.?\d{3}-?\d{2}\b`)},
+	{"CNPJ", regexp.MustCompile(`\b\d{2}\.?\d{3}\.?\d{3}/?\d{4}-?\d{2}\b`)},
+	{"CARD", regexp.MustCompile(`\b(?:\d[ -]?){13,19}\b`)},
+	{"PHONE", regexp.MustCompile(`(?:\+55\s?)?\(?\b\d{2}\)?\s?9?\d{4}-?\d{4}\b`)},
+}
+
+// redactForLog mascara segredos e dados pessoais e limita o tamanho do texto
+func redactForLog(s string) string {
+	for _, r := range redactions {
+		s = r.pattern.ReplaceAllString(s, "[REDACTED:"+r.label+"]")
+	}
+	return truncateRunes(s, providerLogMaxField)
+}
+
+// handleProviderLog implementa POST /debug/provider-log {"enabled": bool}.
+// Como o log grava prompts completos, só chaves privilegiadas podem mudá-lo.
+func (s *server) handleProviderLog(w http.ResponseWriter, r *http.Request) {
+	apiKey := requestAPIKey(r)
+	if !s.policy.privilegedKeys[apiKey] {
+		writeError(w, http.StatusForbidden, "api key sem permissão")
+		return
+	}
+
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	providerLog.enabled.Store(req.Enabled)
+	if err := s.audit.append(auditEntry{
+		Time:    time.Now().UTC(),
+		Action:  "provider_log_toggle",
+		KeyID:   keyFingerprint(apiKey),
+		Allowed: true,
+		Reason:  "enabled=" + strconv.FormatBool(req.Enabled),
+	}); err != nil {
+		serverLog.ErrorContext(r.Context(), "Erro ao gravar auditoria", "err", err)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]bool{"enabled": req.Enabled})
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"context"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==============================
+// Cliente HTTP dos provedores (rate limit + concorrência adaptativa)
+// ==============================
+
+const (
+	// Limites de requisições simultâneas por host de provedor
+	providerInitialConcurrency = 4
+	providerMinConcurrency     = 1
+	providerMaxConcurrency     = 32
+
+	// providerMaxRetries é quantas vezes um 429/503 é reenviado depois da pausa
+	providerMaxRetries = 5
+
+	// providerDefaultBackoff é a pausa quando o provedor não informa quando tentar de novo
+	providerDefaultBackoff = 2 * time.Second
+	providerMaxBackoff     = 2 * time.Minute
+)
+
+// providerHTTP é o cliente das chamadas aos provedores (sidecar, TTS). Todas
+// as goroutines compartilham o estado por host: um 429 pausa o host inteiro e
+// as requisições esperam na fila em vez de insistir, o que evita tempestades
+// de 429 nos jobs em lote (topics, conflicts, saved check). faultTransport
+// fica por baixo, para que as falhas injetadas passem pelos reenvios, e
+// traceTransport no meio, com um span e um traceparent por tentativa.
+var providerHTTP = &http.Client{Transport: newAdaptiveTransport(traceTransport{faultTransport(http.DefaultTransport)})}
+
+// adaptiveTransport limita as requisições simultâneas por host com AIMD:
+// cada sucesso aumenta o limite aos poucos, cada 429 o corta pela metade e
+// pausa o host até o reset informado pelo provedor.
+type adaptiveTransport struct {
+	next http.RoundTripper
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+type hostLimiter struct {
+	mu          sync.Mutex
+	limit       float64
+	inflight    int
+	pausedUntil time.Time
+	// waiting são as requisições na fila, à espera de slot ou do fim da pausa
+	waiting int
+	// remaining é a última cota informada pelo provedor ("requests", "tokens")
+	remaining map[string]string
+	// wake é fechado (e trocado) a cada mudança de estado, acordando a fila
+	wake chan struct{}
+}
+
+func newAdaptiveTransport(next http.RoundTripper) *adaptiveTransport {
+	return &adaptiveTransport{next: next, hosts: map[string]*hostLimiter{}}
+}
+
+func (t *adaptiveTransport) host(name string) *hostLimiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.hosts[name]
+	if !ok {
+		h = &hostLimiter{limit: providerInitialConcurrency, remaining: map[string]string{}, wake: make(chan struct{})}
+		t.hosts[name] = h
+	}
+	return h
+}
+
+func (t *adaptiveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h := t.host(req.URL.Host)
+
+	for attempt := 0; ; attempt++ {
+		if err := h.acquire(req.Context()); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.next.RoundTrip(req)
+		if err != nil {
+			h.release()
+			return nil, err
+		}
+
+		h.observeQuota(resp)
+		pause, limited := rateLimitPause(resp)
+		if !limited {
+			h.succeeded(pause)
+			// O slot fica ocupado até o corpo ser fechado (streaming incluso)
+			resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: h.release}
+			return resp, nil
+		}
+
+		h.throttled(pause)
+		engineLog.WarnContext(req.Context(), "Provedor limitou as requisições; pausando",
+			"host", req.URL.Host, "status", resp.StatusCode, "pause", pause, "concurrency", h.currentLimit())
+
+		if attempt >= providerMaxRetries || !replayable(req) {
+			resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: h.release}
+			return resp, nil
+		}
+
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+		resp.Body.Close()
+		h.release()
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// replayable indica se a requisição pode ser reenviada (corpo regravável)
+func replayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// acquire espera a pausa do host e um slot livre
+func (h *hostLimiter) acquire(ctx context.Context) error {
+	queued := false
+	defer func() {
+		if queued {
+			h.mu.Lock()
+			h.waiting--
+			h.mu.Unlock()
+		}
+	}()
+
+	for {
+		h.mu.Lock()
+		wake := h.wake
+		wait := time.Until(h.pausedUntil)
+		if wait <= 0 && h.inflight < int(h.limit) {
+			h.inflight++
+			h.mu.Unlock()
+			return nil
+		}
+		if !queued {
+			queued = true
+			h.waiting++
+		}
+		h.mu.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-wake:
+		case <-expired:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+func (h *hostLimiter) release() {
+	h.mu.Lock()
+	h.inflight--
+	h.broadcast()
+	h.mu.Unlock()
+}
+
+// succeeded aumenta o limite em ~1 por "rodada" de requisições. Se o
+// provedor avisou que a cota acabou (pause > 0), pausa antes do 429.
+func (h *hostLimiter) succeeded(pause time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.limit = min(h.limit+1/h.limit, providerMaxConcurrency)
+	if pause > 0 {
+		h.pauseFor(pause)
+	}
+	h.broadcast()
+}
+
+// throttled corta o limite pela metade e pausa o host
+func (h *hostLimiter) throttled(pause time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.limit = max(h.limit/2, providerMinConcurrency)
+	h.pauseFor(pause)
+}
+
+func (h *hostLimiter) pauseFor(d time.Duration) {
+	if until := time.Now().Add(min(d, providerMaxBackoff)); until.After(h.pausedUntil) {
+		h.pausedUntil = until
+	}
+}
+
+func (h *hostLimiter) currentLimit() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return int(h.limit)
+}
+
+// observeQuota guarda a cota restante informada pelo provedor
+func (h *hostLimiter) observeQuota(resp *http.Response) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, kind := range []string{"requests", "tokens"} {
+		for _, prefix := range []string{"x-ratelimit-", "anthropic-ratelimit-"} {
+			if v := resp.Header.Get(prefix + "remaining-" + kind); v != "" {
+				h.remaining[kind] = v
+			}
+		}
+	}
+}
+
+// providerHostStatus é o estado de um host de provedor (painel /admin)
+type providerHostStatus struct {
+	Host              string `json:"host"`
+	Concurrency       int    `json:"concurrency"`
+	Inflight          int    `json:"inflight"`
+	Waiting           int    `json:"waiting"`
+	PausedMS          int64  `json:"paused_ms,omitempty"`
+	RemainingRequests string `json:"remaining_requests,omitempty"`
+	RemainingTokens   string `json:"remaining_tokens,omitempty"`
+}
+
+// status devolve o estado de cada host, em ordem alfabética
+func (t *adaptiveTransport) status() []providerHostStatus {
+	t.mu.Lock()
+	names := slices.Sorted(maps.Keys(t.hosts))
+	hosts := make([]*hostLimiter, len(names))
+	for i, name := range names {
+		hosts[i] = t.hosts[name]
+	}
+	t.mu.Unlock()
+
+	out := make([]providerHostStatus, len(hosts))
+	for i, h := range hosts {
+		h.mu.Lock()
+		out[i] = providerHostStatus{
+			Host:              names[i],
+			Concurrency:       int(h.limit),
+			Inflight:          h.inflight,
+			Waiting:           h.waiting,
+			PausedMS:          max(time.Until(h.pausedUntil), 0).Milliseconds(),
+			RemainingRequests: h.remaining["requests"],
+			RemainingTokens:   h.remaining["tokens"],
+		}
+		h.mu.Unlock()
+	}
+	return out
+}
+
+// broadcast acorda a fila; deve ser chamado com h.mu travado
+func (h *hostLimiter) broadcast() {
+	close(h.wake)
+	h.wake = make(chan struct{})
+}
+
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+// rateLimitPause lê os cabeçalhos de rate limit da resposta. limited indica
+// 429/503; pause é quanto esperar antes da próxima requisição (também em
+// respostas de sucesso, quando a cota restante chegou a zero).
+//
+// Formatos aceitos: Retry-After (segundos ou data HTTP), x-ratelimit-*
+// (OpenAI: remaining/reset como "1s", "6m0s") e anthropic-ratelimit-*
+// (reset em RFC 3339).
+func rateLimitPause(resp *http.Response) (pause time.Duration, limited bool) {
+	limited = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+
+	if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		return d, limited
+	}
+
+	for _, kind := range []string{"requests", "tokens"} {
+		for _, prefix := range []string{"x-ratelimit-", "anthropic-ratelimit-"} {
+			remaining := resp.Header.Get(prefix + "remaining-" + kind)
+			reset := resp.Header.Get(prefix + "reset-" + kind)
+			if reset == "" || (!limited && remaining != "0") {
+				continue
+			}
+			if d, ok := parseReset(reset); ok {
+				pause = max(pause, d)
+			}
+		}
+	}
+
+	if limited && pause == 0 {
+		pause = providerDefaultBackoff
+	}
+	return pause, limited
+}
+
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(secs * float64(time.Second)), true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+func parseReset(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if d, err := time.ParseDuration(v); err == nil {
+		return d, true
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(secs * float64(time.Second)), true
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
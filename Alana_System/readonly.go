@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ==============================
+// Modo somente leitura
+// ==============================
+
+// errReadOnly é devolvido por toda escrita na collection em modo somente leitura
+var errReadOnly = errors.New("modo somente leitura: escrita na collection desabilitada")
+
+// writable recusa escritas quando o engine está em modo somente leitura.
+// Todas as escritas no Qdrant (upsert, delete, payload, índices) passam por aqui.
+func (e *AlanaEngine) writable() error {
+	if e.readOnly {
+		return errReadOnly
+	}
+	return nil
+}
+
+// confirmDestructive recusa um comando destrutivo (apagar pontos, reescrever
+// payloads) no ambiente protegido escolhido com --env, a menos que --yes-prod
+// tenha sido passado
+func (e *AlanaEngine) confirmDestructive(operation string) error {
+	return e.env.Confirm(operation, e.yesProd)
+}
+
+// globalFlags são as opções que vêm antes do subcomando:
+//
+//	alana [--read-only] [--env NOME] [--yes-prod] [filtros] <subcomando | pergunta>
+//
+// --read-only (ou ALANA_READ_ONLY=true) serve uma réplica pública de consulta:
+// o serve não expõe /admin nem /debug e toda escrita na collection é recusada,
+// enquanto a ingestão roda em outro lugar.
+//
+// --env (ou ALANA_ENV) escolhe o ambiente de config/alana.yaml; --yes-prod
+// confirma os comandos destrutivos num ambiente protegido.
+//
+// Os filtros (--source, --type e --tag, listas separadas por vírgula, e
+// --after/--before) restringem a pergunta feita direto na linha de comando,
+// como o campo filter do /ask (ver SearchFilter); --top-k e
+// --score-threshold ajustam a busca dela, como top_k e score_threshold.
+type globalFlags struct {
+	readOnly bool
+	env      string
+	yesProd  bool
+	filter   SearchFilter
+	topK     uint64
+	// scoreThreshold é nil sem --score-threshold: vale o de config/alana.yaml,
+	// que só é lido depois das flags
+	scoreThreshold *float32
+	// logLevel e logFormat configuram o log (ver logging.Setup)
+	logLevel  string
+	logFormat string
+}
+
+// searchOptions são as opções da busca da pergunta feita na linha de comando
+func (g globalFlags) searchOptions() SearchOptions {
+	opts := defaultSearchOptions()
+	opts.Filter = g.filter
+	if g.topK > 0 {
+		opts.TopK = g.topK
+	}
+	if g.scoreThreshold != nil {
+		opts.ScoreThreshold = *g.scoreThreshold
+	}
+	return opts
+}
+
+// parseGlobalFlags lê as opções globais e devolve o resto dos argumentos
+func parseGlobalFlags(args []string) (globalFlags, []string, error) {
+	var g globalFlags
+	g.readOnly, _ = strconv.ParseBool(os.Getenv("ALANA_READ_ONLY"))
+
+	fs := flag.NewFlagSet("alana", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&g.readOnly, "read-only", g.readOnly, "recusa escritas e desliga os endpoints de administração")
+	fs.StringVar(&g.env, "env", "", "ambiente de config/alana.yaml (vazio = ALANA_ENV)")
+	fs.BoolVar(&g.yesProd, "yes-prod", false, "confirma comandos destrutivos num ambiente protegido")
+	fs.StringVar(&g.logLevel, "log-level", os.Getenv("ALANA_LOG_LEVEL"), "nível do log: debug, info (padrão), warn ou error")
+	fs.StringVar(&g.logFormat, "log-format", os.Getenv("ALANA_LOG_FORMAT"), "formato do log: pretty (padrão) ou json")
+	var filter filterRequest
+	fs.Func("source", "arquivos (file_name) em que buscar, separados por vírgula", func(s string) error {
+		filter.Sources = append(filter.Sources, splitList(s)...)
+		return nil
+	})
+	fs.Func("type", "tipos de documento (pdf, audio, note, text), separados por vírgula", func(s string) error {
+		filter.ContentTypes = append(filter.ContentTypes, splitList(s)...)
+		return nil
+	})
+	fs.Func("tag", "tags, separadas por vírgula", func(s string) error {
+		filter.Tags = append(filter.Tags, splitList(s)...)
+		return nil
+	})
+	fs.Func("field", "metadado dos conectores, campo=valor1,valor2 (ex: status=open); repetível", func(s string) error {
+		key, values, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("use campo=valor: %q", s)
+		}
+		if filter.Fields == nil {
+			filter.Fields = map[string][]string{}
+		}
+		key = strings.TrimSpace(key)
+		filter.Fields[key] = append(filter.Fields[key], splitList(values)...)
+		return nil
+	})
+	fs.StringVar(&filter.Query, "filter", "", "filtro em texto, ex: 'source:contrato.pdf AND tag:2024 AND page>10'")
+	fs.StringVar(&filter.After, "after", "", "só documentos a partir desta data (2024, 2024-03 ou 2024-03-01)")
+	fs.StringVar(&filter.Before, "before", "", "só documentos antes desta data")
+	fs.Uint64Var(&g.topK, "top-k", 0, "trechos recuperados (0 = 5)")
+	fs.Func("score-threshold", "similaridade mínima, de 0 a 1 (0 = sem mínimo; padrão: o de config/alana.yaml)", func(s string) error {
+		t, err := strconv.ParseFloat(s, 32)
+		if err != nil || t < 0 || t > 1 {
+			return fmt.Errorf("use um número de 0 a 1: %q", s)
+		}
+		threshold := float32(t)
+		g.scoreThreshold = &threshold
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		return g, nil, err
+	}
+	var err error
+	if g.filter, err = filter.searchFilter(); err != nil {
+		return g, nil, err
+	}
+	return g, fs.Args(), nil
+}
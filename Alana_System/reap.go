@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"alana_system/manifest"
+	"alana_system/textstore"
+)
+
+// ==============================
+// Collections efêmeras
+// ==============================
+
+// defaultReapInterval é o intervalo do reaper do serve
+const defaultReapInterval = 5 * time.Minute
+
+// reapIntervalFromEnv lê ALANA_REAP_INTERVAL (duração Go; 0 desliga o reaper
+// do serve)
+func reapIntervalFromEnv() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("ALANA_REAP_INTERVAL")); err == nil && d >= 0 {
+		return d
+	}
+	return defaultReapInterval
+}
+
+// reapCollections apaga as collections efêmeras vencidas (ver `orchestrator
+// -ttl`): primeiro no Qdrant (e os textos delas no text store), depois no
+// manifesto, para que uma falha no
+// meio seja refeita na próxima passada. Devolve os nomes apagados.
+//
+// A collection servida por esta instância nunca é apagada, mesmo vencida.
+func reapCollections(ctx context.Context, engine *AlanaEngine, docs manifest.Store, now time.Time) ([]string, error) {
+	collections, err := docs.ListCollections(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listar collections do manifesto: %w", err)
+	}
+
+	var reaped []string
+	for _, c := range collections {
+		if !c.Expired(now) {
+			continue
+		}
+		if c.Name == engine.collection {
+			monitorLog.Warn("Collection vencida é a servida por esta instância: mantendo", "collection", c.Name, "expired_at", c.ExpiresAt)
+			continue
+		}
+
+		exists, err := engine.client.CollectionExists(ctx, c.Name)
+		if err != nil {
+			return reaped, fmt.Errorf("collection %s: %w", c.Name, err)
+		}
+		var offloaded []string
+		if exists {
+			if engine.texts != nil {
+				if offloaded, err = engine.offloadedPointIDs(ctx, c.Name); err != nil {
+					return reaped, fmt.Errorf("collection %s: %w", c.Name, err)
+				}
+			}
+			if err := engine.client.DeleteCollection(ctx, c.Name); err != nil {
+				return reaped, fmt.Errorf("apagar collection %s: %w", c.Name, err)
+			}
+		}
+		// Os textos ficariam órfãos para sempre: sem a collection, não há
+		// mais como saber quais eram dela
+		if _, err := textstore.Release(ctx, engine.texts, engine.client, offloaded); err != nil {
+			monitorLog.Warn("Erro ao apagar os textos da collection do text store", "collection", c.Name, "err", err)
+		}
+		if err := docs.DeleteCollection(ctx, c.Name); err != nil {
+			return reaped, fmt.Errorf("remover %s do manifesto: %w", c.Name, err)
+		}
+		engine.forgetCollection(c.Name)
+		reaped = append(reaped, c.Name)
+	}
+	return reaped, nil
+}
+
+// reapLoop roda reapCollections a cada interval até ctx ser cancelado
+func reapLoop(ctx context.Context, engine *AlanaEngine, docs manifest.Store, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		reaped, err := reapCollections(ctx, engine, docs, time.Now())
+		if err != nil && ctx.Err() == nil {
+			monitorLog.Warn("Erro no reaper de collections", "err", err)
+		}
+		for _, name := range reaped {
+			monitorLog.Info("Collection efêmera vencida e apagada", "collection", name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runReap implementa `alana reap`: apaga uma vez as collections efêmeras
+// vencidas, para quem não roda o serve (ex: num cron)
+func runReap(ctx context.Context, engine *AlanaEngine, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("uso: alana reap")
+	}
+	if err := engine.writable(); err != nil {
+		return err
+	}
+	if err := engine.confirmDestructive("reap"); err != nil {
+		return err
+	}
+
+	docs, err := manifest.Open(ctx, os.Getenv("ALANA_MANIFEST"))
+	if err != nil {
+		return err
+	}
+	defer docs.Close()
+
+	reaped, err := reapCollections(ctx, engine, docs, time.Now())
+	for _, name := range reaped {
+		cliLog.Info("Collection apagada", "collection", name)
+	}
+	if err == nil && len(reaped) == 0 {
+		cliLog.Info("Nenhuma collection vencida")
+	}
+	return err
+}
+
+// forgetCollection descarta o que o engine guardou da collection apagada
+// (uma nova com o mesmo nome pode ter outra dimensão)
+func (e *AlanaEngine) forgetCollection(name string) {
+	e.vectorInfos.Delete(name)
+	e.sparseVectors.Delete(name)
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==============================
+// Cliente Redis (RESP)
+// ==============================
+
+// redisDialTimeout limita a conexão com o Redis
+const redisDialTimeout = 5 * time.Second
+
+// errRedisNil é a resposta nula do Redis (chave inexistente no GET)
+var errRedisNil = errors.New("redis: nil")
+
+// redisClient fala o protocolo RESP numa única conexão, o bastante para os
+// comandos simples do cache semântico (GET, SET, SCAN) sem trazer um driver.
+// Os comandos são serializados; a conexão é refeita se cair.
+type redisClient struct {
+	addr     string
+	password string
+	db       int
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// newRedisClient lê uma URL redis://[:senha@]host:porta[/db]
+func newRedisClient(raw string) (*redisClient, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if u.Scheme != "redis" || u.Host == "" {
+		return nil, fmt.Errorf("redis: use redis://[:senha@]host:porta[/db], veio %q", raw)
+	}
+	c := &redisClient{addr: u.Host}
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		c.addr = net.JoinHostPort(u.Host, "6379")
+	}
+	if u.User != nil {
+		c.password, _ = u.User.Password()
+		if c.password == "" {
+			c.password = u.User.Username()
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("redis: banco inválido %q", db)
+		}
+	}
+	return c, nil
+}
+
+// do envia um comando e devolve a resposta: string, int64, []any ou nil
+func (c *redisClient) do(ctx context.Context, args ...string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := c.roundTrip(ctx, args)
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) {
+		// Conexão caída (Redis reiniciado): tenta de novo numa nova
+		c.close()
+		if err := c.connect(ctx); err != nil {
+			return nil, err
+		}
+		reply, err = c.roundTrip(ctx, args)
+	}
+	if err != nil {
+		var replyErr redisError
+		if !errors.As(err, &replyErr) {
+			c.close()
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (c *redisClient) connect(ctx context.Context) error {
+	d := net.Dialer{Timeout: redisDialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	c.conn, c.rd = conn, bufio.NewReader(conn)
+	if c.password != "" {
+		if _, err := c.roundTrip(ctx, []string{"AUTH", c.password}); err != nil {
+			c.close()
+			return err
+		}
+	}
+	if c.db != 0 {
+		if _, err := c.roundTrip(ctx, []string{"SELECT", strconv.Itoa(c.db)}); err != nil {
+			c.close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *redisClient) roundTrip(ctx context.Context, args []string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisDialTimeout)
+	}
+	c.conn.SetDeadline(deadline)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+// redisError é uma resposta de erro do Redis (-ERR ...)
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+func (c *redisClient) read() (any, error) {
+	line, err := c.rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: resposta vazia")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: resposta inesperada %q", line)
+}
+
+func (c *redisClient) close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn, c.rd = nil, nil
+	}
+}
+
+// Close fecha a conexão
+func (c *redisClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.close()
+	return nil
+}
+
+// get devolve o valor da chave (errRedisNil se não existe)
+func (c *redisClient) get(ctx context.Context, key string) (string, error) {
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil {
+		return "", err
+	}
+	s, ok := reply.(string)
+	if !ok {
+		return "", errRedisNil
+	}
+	return s, nil
+}
+
+// set grava o valor com validade ttl
+func (c *redisClient) set(ctx context.Context, key, value string, ttl time.Duration) error {
+	_, err := c.do(ctx, "SET", key, value, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// scan percorre as chaves que casam com pattern
+func (c *redisClient) scan(ctx context.Context, pattern string, fn func(key string) error) error {
+	cursor := "0"
+	for {
+		reply, err := c.do(ctx, "SCAN", cursor, "MATCH", pattern, "COUNT", "100")
+		if err != nil {
+			return err
+		}
+		parts, ok := reply.([]any)
+		if !ok || len(parts) != 2 {
+			return errors.New("redis: resposta do SCAN inesperada")
+		}
+		cursor, _ = parts[0].(string)
+		keys, _ := parts[1].([]any)
+		for _, k := range keys {
+			if key, ok := k.(string); ok {
+				if err := fn(key); err != nil {
+					return err
+				}
+			}
+		}
+		if cursor == "0" || cursor == "" {
+			return nil
+		}
+	}
+}
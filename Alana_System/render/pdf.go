@@ -0,0 +1,236 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ==============================
+// PDF
+// ==============================
+
+// Página A4 em pontos e a área útil
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 50
+	pdfBodySize   = 10
+)
+
+// Fontes padrão do PDF (não precisam ser embutidas)
+const (
+	pdfRegular = "F1"
+	pdfBold    = "F2"
+	pdfItalic  = "F3"
+	pdfMono    = "F4"
+)
+
+var pdfFonts = []struct{ name, base string }{
+	{pdfRegular, "Helvetica"},
+	{pdfBold, "Helvetica-Bold"},
+	{pdfItalic, "Helvetica-Oblique"},
+	{pdfMono, "Courier"},
+}
+
+// PDF converte o markdown num PDF A4 só com texto, nas fontes padrão do
+// formato (sem embutir nada). A ênfase de linha vira texto puro, como no
+// Plain; títulos saem em negrito, citações em itálico e código em Courier.
+// Caracteres fora do WinAnsi (o Latin-1 do PDF) viram "?".
+func PDF(md, title string) []byte {
+	w := &pdfWriter{}
+	blocks(md, w)
+	return w.document(title)
+}
+
+// pdfLine é uma linha já quebrada, com a fonte e o recuo
+type pdfLine struct {
+	font   string
+	size   float64
+	indent float64
+	text   string
+	// gap é o espaço extra antes da linha (entre blocos)
+	gap float64
+}
+
+type pdfWriter struct {
+	lines []pdfLine
+	list  string
+	n     int
+}
+
+// add quebra o texto na largura disponível. A largura de cada caractere é
+// aproximada pela média da fonte, o que basta para texto corrido.
+func (w *pdfWriter) add(font string, size, indent float64, text string, gap float64) {
+	avg := 0.5
+	if font == pdfMono {
+		avg = 0.6 // Courier é monoespaçada
+	} else if font == pdfBold {
+		avg = 0.55
+	}
+	width := int((pdfPageWidth - 2*pdfMargin - indent) / (size * avg))
+
+	for _, para := range strings.Split(text, "\n") {
+		for _, line := range wrap(para, width, font == pdfMono) {
+			w.lines = append(w.lines, pdfLine{font: font, size: size, indent: indent, text: line, gap: gap})
+			gap = 0
+		}
+	}
+}
+
+// wrap quebra s em linhas de até width runas, nos espaços; palavras maiores
+// que a linha são cortadas. Em código os espaços iniciais são mantidos.
+func wrap(s string, width int, keepIndent bool) []string {
+	if !keepIndent {
+		s = strings.Join(strings.Fields(s), " ")
+	}
+	if s == "" {
+		return []string{""}
+	}
+
+	var lines []string
+	for utf8.RuneCountInString(s) > width {
+		runes := []rune(s)
+		cut := strings.LastIndex(string(runes[:width+1]), " ")
+		if cut <= 0 || keepIndent {
+			cut = len(string(runes[:width]))
+		}
+		lines = append(lines, strings.TrimRight(s[:cut], " "))
+		s = strings.TrimLeft(s[cut:], " ")
+	}
+	return append(lines, s)
+}
+
+func (w *pdfWriter) paragraph(lines []string) {
+	w.add(pdfRegular, pdfBodySize, 0, inline(strings.Join(lines, " "), inlinePlain{}), pdfBodySize)
+}
+
+func (w *pdfWriter) heading(level int, text string) {
+	size := max(pdfBodySize+1, 20-2*float64(level))
+	w.add(pdfBold, size, 0, inline(text, inlinePlain{}), size)
+}
+
+func (w *pdfWriter) rule() {}
+
+func (w *pdfWriter) quote(lines []string) {
+	w.add(pdfItalic, pdfBodySize, 18, inline(strings.Join(lines, " "), inlinePlain{}), pdfBodySize)
+}
+
+func (w *pdfWriter) code(lines []string) {
+	w.add(pdfMono, pdfBodySize-1, 12, strings.Join(lines, "\n"), pdfBodySize)
+}
+
+func (w *pdfWriter) item(list, text string) {
+	gap := 0.0
+	if w.list != list {
+		w.list, w.n, gap = list, 0, pdfBodySize
+	}
+	w.n++
+	marker := "•"
+	if list == "ol" {
+		marker = strconv.Itoa(w.n) + "."
+	}
+	w.add(pdfRegular, pdfBodySize, 12, marker+" "+inline(text, inlinePlain{}), gap)
+}
+
+func (w *pdfWriter) endList() { w.list = "" }
+
+func (w *pdfWriter) String() string { return "" }
+
+// document distribui as linhas em páginas e escreve o arquivo: catálogo,
+// árvore de páginas, fontes e, por página, o objeto e o stream de conteúdo
+func (w *pdfWriter) document(title string) []byte {
+	var pages []string
+	var content strings.Builder
+	y := float64(pdfPageHeight - pdfMargin)
+	for _, l := range w.lines {
+		lead := l.size * 1.4
+		if y-l.gap-lead < pdfMargin {
+			pages = append(pages, content.String())
+			content.Reset()
+			y = pdfPageHeight - pdfMargin
+		} else {
+			y -= l.gap
+		}
+		y -= lead
+		fmt.Fprintf(&content, "BT /%s %g Tf %g %g Td (%s) Tj ET\n", l.font, l.size, pdfMargin+l.indent, y, pdfString(l.text))
+	}
+	pages = append(pages, content.String())
+
+	// Objetos: 1 catálogo, 2 páginas, 3 info, depois as fontes e, por fim,
+	// página e conteúdo alternados
+	firstPage := 4 + len(pdfFonts)
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		fmt.Sprintf("<< /Title (%s) /Producer (Alana) >>", pdfString(title)),
+	)
+	var fonts []string
+	for i, f := range pdfFonts {
+		objects = append(objects, fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", f.base))
+		fonts = append(fonts, fmt.Sprintf("/%s %d 0 R", f.name, 4+i))
+	}
+	for i, stream := range pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << %s >> >> /Contents %d 0 R >>",
+				pdfPageWidth, pdfPageHeight, strings.Join(fonts, " "), firstPage+2*i+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(stream), stream),
+		)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
+
+// winAnsiExtra são os caracteres do WinAnsi fora do Latin-1 (0x80–0x9F)
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// pdfString codifica s em WinAnsi para uma string literal do PDF; bytes fora
+// do ASCII saem em octal para o arquivo continuar em texto
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		c, ok := winAnsiExtra[r]
+		switch {
+		case ok:
+		case r == '\t':
+			c = ' '
+		case r >= 0x20 && r < 0x7f, r >= 0xa0 && r <= 0xff:
+			c = byte(r)
+		default:
+			c = '?'
+		}
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c >= 0x80:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,452 @@
+// Package render converte a resposta em markdown do modelo para o formato
+// pedido pelo cliente: HTML sanitizado ou texto puro. O HTML é montado
+// escapando todo o texto e gerando só um conjunto fixo de tags (parágrafos,
+// títulos, listas, citações em bloco, código, ênfase e links http/https/mailto);
+// HTML cru vindo do modelo nunca passa adiante.
+//
+// Citações do tipo [n] (n = posição da fonte na resposta, a partir de 1) ou
+// [rótulo da fonte...] viram âncoras <a class="citation" href="#source-n"> no
+// HTML e são mantidas como estão no texto puro. Footnotes normaliza as
+// citações para [n], no estilo de notas de rodapé.
+package render
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Format é o formato da resposta entregue ao cliente
+type Format string
+
+const (
+	Markdown Format = "markdown"
+	HTML     Format = "html"
+	Plain    Format = "plain"
+)
+
+// ParseFormat valida o formato pedido; vazio é Markdown (a resposta como veio)
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case "", Markdown:
+		return Markdown, nil
+	case HTML, Plain:
+		return f, nil
+	}
+	return "", fmt.Errorf("formato desconhecido %q (use markdown, html ou plain)", s)
+}
+
+// Answer converte a resposta. sources são os rótulos das fontes na ordem da
+// resposta (ex: "manual.pdf/Pág 3"), usados para reconhecer as citações.
+func Answer(md string, f Format, sources []string) string {
+	switch f {
+	case HTML:
+		return blocks(md, &htmlWriter{cites: citer(sources)})
+	case Plain:
+		return blocks(md, &plainWriter{})
+	}
+	return md
+}
+
+var (
+	headingPattern   = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	rulePattern      = regexp.MustCompile(`^(?:-{3,}|\*{3,}|_{3,})$`)
+	unorderedPattern = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	orderedPattern   = regexp.MustCompile(`^\d{1,9}[.)]\s+(.*)$`)
+)
+
+// writer recebe os blocos reconhecidos
+type writer interface {
+	paragraph(lines []string)
+	heading(level int, text string)
+	rule()
+	quote(lines []string)
+	code(lines []string)
+	// item acrescenta um item à lista "ul" ou "ol", abrindo-a se preciso
+	item(list, text string)
+	endList()
+	String() string
+}
+
+// blocks percorre o markdown linha a linha. Listas aninhadas são achatadas.
+func blocks(md string, w writer) string {
+	lines := strings.Split(strings.ReplaceAll(md, "\r\n", "\n"), "\n")
+
+	var para, quote []string
+	flush := func() {
+		if len(para) > 0 {
+			w.paragraph(para)
+			para = nil
+		}
+		if len(quote) > 0 {
+			w.quote(quote)
+			quote = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+
+		if strings.HasPrefix(line, "```") {
+			flush()
+			w.endList()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			w.code(code)
+			continue
+		}
+
+		if m := headingPattern.FindStringSubmatch(line); m != nil {
+			flush()
+			w.endList()
+			w.heading(len(m[1]), m[2])
+			continue
+		}
+
+		switch {
+		case line == "":
+			flush()
+			w.endList()
+		case rulePattern.MatchString(line):
+			flush()
+			w.endList()
+			w.rule()
+		case unorderedPattern.MatchString(line):
+			flush()
+			w.item("ul", unorderedPattern.FindStringSubmatch(line)[1])
+		case orderedPattern.MatchString(line):
+			flush()
+			w.item("ol", orderedPattern.FindStringSubmatch(line)[1])
+		case strings.HasPrefix(line, ">"):
+			if len(para) > 0 {
+				w.paragraph(para)
+				para = nil
+			}
+			w.endList()
+			quote = append(quote, strings.TrimSpace(strings.TrimPrefix(line, ">")))
+		default:
+			if len(quote) > 0 {
+				w.quote(quote)
+				quote = nil
+			}
+			w.endList()
+			para = append(para, line)
+		}
+	}
+	flush()
+	w.endList()
+	return strings.TrimSpace(w.String())
+}
+
+// ==============================
+// HTML
+// ==============================
+
+type htmlWriter struct {
+	b     strings.Builder
+	list  string
+	cites func(ref string) (int, bool)
+}
+
+func (w *htmlWriter) paragraph(lines []string) {
+	fmt.Fprintf(&w.b, "<p>%s</p>\n", w.inline(strings.Join(lines, "\n")))
+}
+
+func (w *htmlWriter) heading(level int, text string) {
+	fmt.Fprintf(&w.b, "<h%d>%s</h%d>\n", level, w.inline(text), level)
+}
+
+func (w *htmlWriter) rule() { w.b.WriteString("<hr>\n") }
+
+func (w *htmlWriter) quote(lines []string) {
+	fmt.Fprintf(&w.b, "<blockquote><p>%s</p></blockquote>\n", w.inline(strings.Join(lines, "\n")))
+}
+
+func (w *htmlWriter) code(lines []string) {
+	fmt.Fprintf(&w.b, "<pre><code>%s</code></pre>\n", html.EscapeString(strings.Join(lines, "\n")))
+}
+
+func (w *htmlWriter) item(list, text string) {
+	if w.list != list {
+		w.endList()
+		fmt.Fprintf(&w.b, "<%s>\n", list)
+		w.list = list
+	}
+	fmt.Fprintf(&w.b, "<li>%s</li>\n", w.inline(text))
+}
+
+func (w *htmlWriter) endList() {
+	if w.list != "" {
+		fmt.Fprintf(&w.b, "</%s>\n", w.list)
+		w.list = ""
+	}
+}
+
+func (w *htmlWriter) String() string { return w.b.String() }
+
+func (w *htmlWriter) inline(s string) string {
+	return inline(s, inlineHTML{cites: w.cites})
+}
+
+type inlineHTML struct {
+	cites func(ref string) (int, bool)
+}
+
+func (inlineHTML) text(s string) string   { return html.EscapeString(s) }
+func (inlineHTML) code(s string) string   { return "<code>" + html.EscapeString(s) + "</code>" }
+func (inlineHTML) strong(s string) string { return "<strong>" + s + "</strong>" }
+func (inlineHTML) em(s string) string     { return "<em>" + s + "</em>" }
+
+func (inlineHTML) link(label, url string) string {
+	if !safeURL(url) {
+		return label
+	}
+	return `<a href="` + html.EscapeString(url) + `">` + label + "</a>"
+}
+
+func (h inlineHTML) citation(ref string) (string, bool) {
+	n, ok := h.cites(ref)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf(`<a class="citation" href="#source-%d">[%s]</a>`, n, html.EscapeString(ref)), true
+}
+
+// safeURL aceita só esquemas que não executam nada no navegador
+func safeURL(url string) bool {
+	lower := strings.ToLower(strings.TrimSpace(url))
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "mailto:")
+}
+
+// citer reconhece [n] e [rótulo...] entre as fontes
+func citer(sources []string) func(ref string) (int, bool) {
+	return func(ref string) (int, bool) {
+		ref = strings.TrimSpace(ref)
+		if n, err := strconv.Atoi(ref); err == nil {
+			return n, n >= 1 && n <= len(sources)
+		}
+		lower := strings.ToLower(ref)
+		for i, s := range sources {
+			if s != "" && strings.HasPrefix(lower, strings.ToLower(s)) {
+				return i + 1, true
+			}
+		}
+		return 0, false
+	}
+}
+
+// ==============================
+// Notas de rodapé
+// ==============================
+
+// Footnotes reescreve as citações reconhecidas ([n] ou [rótulo...]) como [n]
+// e devolve os números citados na ordem da primeira citação. Código (em
+// linha ou em bloco) e links [texto](url) ficam intactos.
+func Footnotes(md string, sources []string) (string, []int) {
+	cites := citer(sources)
+	seen := map[int]bool{}
+	var cited []int
+
+	lines := strings.Split(md, "\n")
+	fenced := false
+	for li, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+
+		var b strings.Builder
+		for i := 0; i < len(line); {
+			switch line[i] {
+			case '`':
+				if end := strings.IndexByte(line[i+1:], '`'); end >= 0 {
+					b.WriteString(line[i : i+end+2])
+					i += end + 2
+					continue
+				}
+			case '[':
+				end := strings.IndexByte(line[i+1:], ']')
+				if end < 0 {
+					break
+				}
+				after := i + 2 + end
+				if after < len(line) && line[after] == '(' {
+					break
+				}
+				if n, ok := cites(line[i+1 : i+1+end]); ok {
+					if !seen[n] {
+						seen[n] = true
+						cited = append(cited, n)
+					}
+					fmt.Fprintf(&b, "[%d]", n)
+					i = after
+					continue
+				}
+			}
+			b.WriteByte(line[i])
+			i++
+		}
+		lines[li] = b.String()
+	}
+	return strings.Join(lines, "\n"), cited
+}
+
+// ==============================
+// Texto puro
+// ==============================
+
+type plainWriter struct {
+	b    strings.Builder
+	list string
+	n    int
+}
+
+func (w *plainWriter) paragraph(lines []string) {
+	w.b.WriteString(inline(strings.Join(lines, "\n"), inlinePlain{}) + "\n\n")
+}
+
+func (w *plainWriter) heading(_ int, text string) {
+	w.b.WriteString(inline(text, inlinePlain{}) + "\n\n")
+}
+
+func (w *plainWriter) rule() {}
+
+func (w *plainWriter) quote(lines []string) { w.paragraph(lines) }
+
+func (w *plainWriter) code(lines []string) {
+	w.b.WriteString(strings.Join(lines, "\n") + "\n\n")
+}
+
+func (w *plainWriter) item(list, text string) {
+	if w.list != list {
+		w.endList()
+		w.list, w.n = list, 0
+	}
+	w.n++
+	marker := "-"
+	if list == "ol" {
+		marker = strconv.Itoa(w.n) + "."
+	}
+	w.b.WriteString(marker + " " + inline(text, inlinePlain{}) + "\n")
+}
+
+func (w *plainWriter) endList() {
+	if w.list != "" {
+		w.b.WriteString("\n")
+		w.list = ""
+	}
+}
+
+func (w *plainWriter) String() string { return w.b.String() }
+
+type inlinePlain struct{}
+
+func (inlinePlain) text(s string) string   { return s }
+func (inlinePlain) code(s string) string   { return s }
+func (inlinePlain) strong(s string) string { return s }
+func (inlinePlain) em(s string) string     { return s }
+
+func (inlinePlain) link(label, url string) string {
+	if label == url {
+		return url
+	}
+	return label + " (" + url + ")"
+}
+
+func (inlinePlain) citation(string) (string, bool) { return "", false }
+
+// ==============================
+// Elementos de linha
+// ==============================
+
+type inlineWriter interface {
+	text(s string) string
+	code(s string) string
+	strong(s string) string
+	em(s string) string
+	link(label, url string) string
+	// citation devolve a âncora da citação; false mantém o texto literal
+	citation(ref string) (string, bool)
+}
+
+// inline converte código, links, citações e ênfase de uma linha (ou
+// parágrafo). Delimitadores sem par ficam como texto.
+func inline(s string, w inlineWriter) string {
+	var b strings.Builder
+	var plain strings.Builder
+	emit := func(v string) {
+		b.WriteString(w.text(plain.String()))
+		plain.Reset()
+		b.WriteString(v)
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch c {
+		case '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end >= 0 {
+				emit(w.code(s[i+1 : i+1+end]))
+				i += end + 2
+				continue
+			}
+
+		case '[':
+			end := strings.IndexByte(s[i+1:], ']')
+			if end < 0 {
+				break
+			}
+			ref := s[i+1 : i+1+end]
+			after := i + 2 + end
+			if after < len(s) && s[after] == '(' {
+				if close := strings.IndexByte(s[after+1:], ')'); close >= 0 {
+					url := strings.TrimSpace(s[after+1 : after+1+close])
+					emit(w.link(inline(ref, w), url))
+					i = after + close + 2
+					continue
+				}
+			}
+			if anchor, ok := w.citation(ref); ok {
+				emit(anchor)
+				i = after
+				continue
+			}
+
+		case '*', '_':
+			// "_" só abre ênfase no início de palavra (snake_case fica intacto)
+			if c == '_' && i > 0 && isWordByte(s[i-1]) {
+				break
+			}
+			delim := string(c)
+			if strings.HasPrefix(s[i:], delim+delim) {
+				delim += delim
+			}
+			rest := s[i+len(delim):]
+			end := strings.Index(rest, delim)
+			if end > 0 && rest[0] != ' ' && rest[end-1] != ' ' {
+				content := inline(rest[:end], w)
+				if len(delim) == 2 {
+					emit(w.strong(content))
+				} else {
+					emit(w.em(content))
+				}
+				i += len(delim)*2 + end
+				continue
+			}
+		}
+		plain.WriteByte(c)
+		i++
+	}
+	emit("")
+	return b.String()
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
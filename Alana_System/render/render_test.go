@@ -0,0 +1,92 @@
+package render
+
+import (
+	"strings"
+	"testing"
+)
+
+// O HTML vai direto para a página do cliente: nada vindo do modelo pode
+// virar tag, atributo ou URL executável
+func TestAnswerHTMLSanitizes(t *testing.T) {
+	sources := []string{"manual.pdf/Pág 3"}
+	cases := []struct {
+		name string
+		md   string
+		want string
+	}{
+		{"script", `<script>alert(1)</script>`, `<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>`},
+		{"tag em lista", `- <img src=x onerror=alert(1)>`, "<ul>\n<li>&lt;img src=x onerror=alert(1)&gt;</li>\n</ul>"},
+		{"script em código", "```\n<script>x</script>\n```", `<pre><code>&lt;script&gt;x&lt;/script&gt;</code></pre>`},
+		{"javascript", `[clique](javascript:alert(1))`, `<p>clique)</p>`},
+		{"javascript maiúsculo", `[clique](  JaVaScRiPt:alert)`, `<p>clique</p>`},
+		{"data", `[x](data:text/html;base64,PHNjcmlwdD4=)`, `<p>x</p>`},
+		{"vbscript", `[x](vbscript:msgbox)`, `<p>x</p>`},
+		{"https", `[site](https://exemplo.com/a?b=1&c=2)`, `<p><a href="https://exemplo.com/a?b=1&amp;c=2">site</a></p>`},
+		{"mailto", `[fale](mailto:a@b.com)`, `<p><a href="mailto:a@b.com">fale</a></p>`},
+		{"aspas no href", `[x](https://a.com/" onclick="alert(1))`, `<p><a href="https://a.com/&#34; onclick=&#34;alert(1">x</a>)</p>`},
+		{"aspas simples no href", `[x](https://a.com/' onmouseover='y)`, `<p><a href="https://a.com/&#39; onmouseover=&#39;y">x</a></p>`},
+		{"tag no rótulo", `[<b onclick=x>oi</b>](https://a.com)`, `<p><a href="https://a.com">&lt;b onclick=x&gt;oi&lt;/b&gt;</a></p>`},
+		{"ênfase aninhada", `**negrito _e <i>itálico</i>_**`, `<p><strong>negrito <em>e &lt;i&gt;itálico&lt;/i&gt;</em></strong></p>`},
+		{"link dentro de ênfase", `*[x](javascript:y)*`, `<p><em>x</em></p>`},
+		{"código inline", "use `<b>` e `a&b`", `<p>use <code>&lt;b&gt;</code> e <code>a&amp;b</code></p>`},
+		{"título", `# <h1>título</h1>`, `<h1>&lt;h1&gt;título&lt;/h1&gt;</h1>`},
+		{"citação em bloco", `> "aspas" & <tag>`, `<blockquote><p>&#34;aspas&#34; &amp; &lt;tag&gt;</p></blockquote>`},
+		{"citação", `Veja [1].`, `<p>Veja <a class="citation" href="#source-1">[1]</a>.</p>`},
+		{"citação por rótulo", `Veja [manual.pdf/Pág 3 "x"].`, `<p>Veja <a class="citation" href="#source-1">[manual.pdf/Pág 3 &#34;x&#34;]</a>.</p>`},
+		{"citação inexistente", `Veja [2].`, `<p>Veja [2].</p>`},
+		{"snake_case", `campo_de_texto`, `<p>campo_de_texto</p>`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Answer(tc.md, HTML, sources); got != tc.want {
+				t.Errorf("Answer(%q)\n  = %q\n  esperado %q", tc.md, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSafeURL(t *testing.T) {
+	cases := map[string]bool{
+		"https://a.com":           true,
+		"HTTP://a.com":            true,
+		" mailto:a@b.com":         true,
+		"javascript:alert(1)":     false,
+		" javascript:alert(1)":    false,
+		"java\tscript:alert(1)":   false,
+		"\x01javascript:alert(1)": false,
+		"data:text/html,x":        false,
+		"vbscript:x":              false,
+		"//evil.com":              false,
+		"/relativo":               false,
+		"":                        false,
+	}
+	for url, want := range cases {
+		if got := safeURL(url); got != want {
+			t.Errorf("safeURL(%q) = %v, esperado %v", url, got, want)
+		}
+	}
+}
+
+func TestAnswerPlain(t *testing.T) {
+	md := "# Título\n\n**Prazo**: 12 meses [1].\n\n- [site](https://a.com)\n- `<b>`"
+	want := "Título\n\nPrazo: 12 meses [1].\n\n- site (https://a.com)\n- <b>"
+	if got := Answer(md, Plain, []string{"manual.pdf"}); got != want {
+		t.Errorf("Answer plain = %q, esperado %q", got, want)
+	}
+}
+
+func TestFootnotes(t *testing.T) {
+	sources := []string{"a.pdf/Pág 1", "b.md"}
+	md := "Segundo [b.md], primeiro [a.pdf/Pág 1] e [2] de novo.\n`[1]` e [link](https://x.com)"
+	got, cited := Footnotes(md, sources)
+	want := "Segundo [2], primeiro [1] e [2] de novo.\n`[1]` e [link](https://x.com)"
+	if got != want {
+		t.Errorf("Footnotes = %q, esperado %q", got, want)
+	}
+	if len(cited) != 2 || cited[0] != 2 || cited[1] != 1 {
+		t.Errorf("citadas = %v, esperado [2 1]", cited)
+	}
+	if !strings.Contains(Answer(got, HTML, sources), `href="#source-2"`) {
+		t.Error("citação normalizada não vira âncora")
+	}
+}
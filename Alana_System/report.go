@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"alana_system/assemble"
+)
+
+// ==============================
+// Relatórios (planejar e depois escrever)
+// ==============================
+
+const (
+	// reportMaxSections limita o roteiro; cada seção é uma busca e uma geração
+	reportMaxSections = 6
+	// reportOutlineTopK é quantos trechos embasam o roteiro
+	reportOutlineTopK = 8
+	// reportMaxSectionTitle descarta linhas do roteiro que não são títulos
+	reportMaxSectionTitle = 120
+)
+
+const reportOutlinePrompt = "Você vai planejar um relatório a partir dos trechos de documentos abaixo.\n\n" +
+	"{context}\n\n" +
+	"Tema do relatório: {question}\n\n" +
+	"Liste de 3 a 6 títulos de seção para o relatório, um por linha, sem numeração e sem nenhum outro texto.\n" +
+	"Seções:"
+
+const reportSectionPrompt = "{context}\n\n" +
+	"{question}\n\n" +
+	"Escreva apenas o texto desta seção, em parágrafos, usando só as fontes acima. " +
+	"Cite as fontes pelo número entre colchetes, por exemplo [1]. Não repita o título da seção.\n" +
+	"Texto:"
+
+// reportBlockFormat identifica cada trecho pelo número da fonte no relatório,
+// que é o que o modelo usa para citar
+const reportBlockFormat = "--- Fonte [%s] | Pág %d | Score %.2f ---\n%s"
+
+var outlineMarker = regexp.MustCompile(`^(?:#+|[-*•]|\d{1,2}[.)])\s*`)
+
+// WriteReport escreve um relatório longo sobre topic em duas fases: gera um
+// roteiro de seções a partir de uma busca pelo tema e depois, para cada
+// seção, busca os trechos dela e gera o texto com o orçamento de contexto
+// inteiro do modelo. As fontes são numeradas uma única vez para o relatório
+// todo ([n] = n-ésima fonte de Answer.Sources), então a mesma fonte tem o
+// mesmo número em todas as seções.
+//
+// opts.Budget não se aplica: cada seção é uma geração completa.
+func (e *AlanaEngine) WriteReport(ctx context.Context, topic string, opts askOptions) (Answer, error) {
+	tokenLimit := opts.TokenLimit
+	if tokenLimit == 0 {
+		tokenLimit = e.models.contextTokenLimit(opts.Override.Model)
+	}
+
+	outlineOpts := opts
+	outlineOpts.TopK = max(opts.TopK, reportOutlineTopK)
+	results, err := e.retrieve(ctx, topic, outlineOpts)
+	if err != nil {
+		if answer, ok := e.emptyCorpusAnswer(ctx); ok {
+			return answer, nil
+		}
+		return Answer{}, err
+	}
+	if opts.Viewer.denies() {
+		return Answer{Text: restrictedReply, Restricted: true}, nil
+	}
+	if len(results) == 0 {
+		return e.noResultsAnswer(ctx), nil
+	}
+	outline, err := getAnswerWith(ctx, topic, e.AssembleContext(results, tokenLimit, opts.Override.Model), opts.Override, reportOutlinePrompt)
+	if err != nil {
+		return Answer{}, fmt.Errorf("outline: %w", err)
+	}
+	sections := parseOutline(outline)
+	if len(sections) == 0 {
+		sections = []string{topic}
+	}
+
+	var report strings.Builder
+	var sources []SearchResult
+	number := map[string]int{} // ID do trecho → número da fonte
+
+	fmt.Fprintf(&report, "# %s\n\n", topic)
+	for _, section := range sections {
+		results, err := e.retrieve(ctx, topic+" — "+section, opts)
+		if err != nil {
+			return Answer{}, fmt.Errorf("section %q: %w", section, err)
+		}
+
+		chunks := assembleChunks(results)
+		for i, r := range results {
+			n, ok := number[r.ID]
+			if !ok {
+				sources = append(sources, r)
+				n = len(sources)
+				number[r.ID] = n
+			}
+			chunks[i].Title = strconv.Itoa(n)
+		}
+
+		ctxOpts := assemble.DefaultOptions(tokenLimit)
+		ctxOpts.Budget = assemble.BudgetSentence
+		ctxOpts.Tokenizer = e.models.tokenizer(opts.Override.Model)
+		ctxOpts.BlockFormat = reportBlockFormat
+		question := fmt.Sprintf("Relatório sobre: %s\nSeção: %s", topic, section)
+
+		text, err := getAnswerWith(ctx, question, assemble.Context(chunks, ctxOpts), opts.Override, reportSectionPrompt)
+		if err != nil {
+			return Answer{}, fmt.Errorf("section %q: generate: %w", section, err)
+		}
+		fmt.Fprintf(&report, "## %s\n\n%s\n\n", section, strings.TrimSpace(text))
+	}
+
+	e.recordUsage(usageCited, sources)
+	return opts.Viewer.annotate(Answer{Text: strings.TrimSpace(report.String()), Sources: sources}), nil
+}
+
+// parseOutline extrai os títulos de seção da resposta do modelo, tolerando
+// numeração, marcadores e linhas em branco
+func parseOutline(outline string) []string {
+	var sections []string
+	seen := map[string]bool{}
+	for _, line := range strings.Split(outline, "\n") {
+		title := strings.TrimSpace(outlineMarker.ReplaceAllString(strings.TrimSpace(line), ""))
+		title = strings.Trim(title, "*_\"“” ")
+		key := strings.ToLower(title)
+		if title == "" || len(title) > reportMaxSectionTitle || seen[key] || strings.HasSuffix(title, ":") {
+			continue
+		}
+		seen[key] = true
+		sections = append(sections, title)
+		if len(sections) == reportMaxSections {
+			break
+		}
+	}
+	return sections
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"alana_system/sidecarproto"
+)
+
+// ==============================
+// Re-ranking (cross-encoder)
+// ==============================
+
+// rerankCandidates multiplica o topK da busca vetorial quando o re-ranking
+// está ligado, para o cross-encoder ter de onde escolher
+const rerankCandidates = 3
+
+// reranker dá a relevância (0..1, a escala dos cortes rerank_threshold e
+// rerank_abstain_threshold) de cada documento para a pergunta, na ordem de docs
+type reranker interface {
+	Relevance(ctx context.Context, query string, docs []string) ([]float32, error)
+}
+
+// rerankerFromEnv escolhe o cross-encoder. Sem configuração, é o do sidecar
+// (/rerank do bridge.py).
+//
+//	ALANA_RERANK_URL, ALANA_RERANK_API_KEY,
+//	ALANA_RERANK_MODEL                    provedor compatível com /v1/rerank
+//	                                      (Cohere, Jina, Infinity...)
+//
+// Trocar de modelo muda a escala da relevância: recalibre os cortes com
+// `calibrate -scorer rerank`.
+func rerankerFromEnv() reranker {
+	if endpoint := os.Getenv("ALANA_RERANK_URL"); endpoint != "" {
+		return httpReranker{
+			url:    strings.TrimRight(endpoint, "/") + "/v1/rerank",
+			apiKey: os.Getenv("ALANA_RERANK_API_KEY"),
+			model:  os.Getenv("ALANA_RERANK_MODEL"),
+		}
+	}
+	return sidecarReranker{}
+}
+
+// rerankResults reordena os resultados pela relevância do cross-encoder e
+// mantém os topK primeiros com relevância de pelo menos minRelevance. Score
+// continua sendo a similaridade vetorial; a relevância fica em Relevance.
+func rerankResults(ctx context.Context, rr reranker, query string, results []SearchResult, topK uint64, minRelevance float32) ([]SearchResult, error) {
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	docs := make([]string, len(results))
+	for i, r := range results {
+		docs[i] = r.Text
+	}
+	scores, err := rr.Relevance(ctx, query, docs)
+	if err != nil {
+		return nil, err
+	}
+
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	ranked := make([]SearchResult, 0, min(uint64(len(order)), topK))
+	for _, i := range order[:min(uint64(len(order)), topK)] {
+		r := results[i]
+		r.Relevance = scores[i]
+		if r.Relevance < minRelevance {
+			break
+		}
+		ranked = append(ranked, r)
+	}
+	return ranked, nil
+}
+
+// relevance leva o logit do cross-encoder para 0..1, a escala dos cortes
+func relevance(logit float64) float32 {
+	return float32(1 / (1 + math.Exp(-logit)))
+}
+
+// sidecarReranker usa o cross-encoder do sidecar, que devolve logits
+type sidecarReranker struct{}
+
+type rerankRequest struct {
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+}
+
+type rerankResponse struct {
+	Scores []float64 `json:"scores"`
+}
+
+func (sidecarReranker) Relevance(ctx context.Context, query string, docs []string) ([]float32, error) {
+	body, err := json.Marshal(rerankRequest{Query: query, Documents: docs})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sidecarURL+"/rerank", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	sidecarproto.Offer(req.Header)
+
+	resp, err := providerHTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("rerank error: %s", string(raw))
+	}
+
+	var out rerankResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	if len(out.Scores) != len(docs) {
+		return nil, fmt.Errorf("rerank returned %d scores for %d documents", len(out.Scores), len(docs))
+	}
+	scores := make([]float32, len(out.Scores))
+	for i, l := range out.Scores {
+		scores[i] = relevance(l)
+	}
+	return scores, nil
+}
+
+// httpReranker chama um provedor com a API /v1/rerank, que já devolve a
+// relevância em 0..1, fora de ordem e com o índice do documento
+type httpReranker struct {
+	url    string
+	apiKey string
+	model  string
+}
+
+type providerRerankRequest struct {
+	Model     string   `json:"model,omitempty"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	TopN      int      `json:"top_n"`
+}
+
+type providerRerankResponse struct {
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float32 `json:"relevance_score"`
+	} `json:"results"`
+}
+
+func (h httpReranker) Relevance(ctx context.Context, query string, docs []string) ([]float32, error) {
+	body, err := json.Marshal(providerRerankRequest{Model: h.model, Query: query, Documents: docs, TopN: len(docs)})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	}
+
+	resp, err := providerHTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("rerank error: %s", string(raw))
+	}
+
+	var out providerRerankResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	if len(out.Results) != len(docs) {
+		return nil, fmt.Errorf("rerank returned %d results for %d documents", len(out.Results), len(docs))
+	}
+	scores := make([]float32, len(docs))
+	seen := make([]bool, len(docs))
+	for _, r := range out.Results {
+		if r.Index < 0 || r.Index >= len(docs) || seen[r.Index] {
+			return nil, fmt.Errorf("rerank returned an invalid document index %d", r.Index)
+		}
+		seen[r.Index] = true
+		scores[r.Index] = r.RelevanceScore
+	}
+	return scores, nil
+}
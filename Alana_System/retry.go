@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ==============================
+// Reenvio com backoff exponencial
+// ==============================
+
+// retryPolicy diz quantas vezes uma chamada ao sidecar ou ao Qdrant é
+// tentada e quanto esperar entre as tentativas. Os 429 já são reenviados
+// pelo providerHTTP (ver adaptiveTransport); aqui entram as falhas
+// transitórias: sidecar reiniciando, conexão caída, Qdrant indisponível.
+type retryPolicy struct {
+	// Attempts é o total de tentativas (1 = sem reenvio)
+	Attempts int
+	// BaseDelay é a espera antes da segunda tentativa; dobra a cada nova
+	// tentativa, até MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// retries é a política das chamadas do engine; main a relê do ambiente
+// depois de config.Load
+var retries = retryPolicyFromEnv()
+
+// retryPolicyFromEnv lê ALANA_RETRY_ATTEMPTS, ALANA_RETRY_BASE_DELAY e
+// ALANA_RETRY_MAX_DELAY (durações Go). Valores inválidos ficam no padrão.
+func retryPolicyFromEnv() retryPolicy {
+	p := retryPolicy{Attempts: 3, BaseDelay: 200 * time.Millisecond, MaxDelay: 5 * time.Second}
+	if n, err := strconv.Atoi(os.Getenv("ALANA_RETRY_ATTEMPTS")); err == nil && n >= 1 {
+		p.Attempts = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("ALANA_RETRY_BASE_DELAY")); err == nil && d > 0 {
+		p.BaseDelay = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("ALANA_RETRY_MAX_DELAY")); err == nil && d > 0 {
+		p.MaxDelay = d
+	}
+	p.MaxDelay = max(p.MaxDelay, p.BaseDelay)
+	return p
+}
+
+// delay é a espera antes da tentativa attempt+1: backoff exponencial com
+// jitter (metade fixa, metade aleatória), para que réplicas que falharam
+// juntas não voltem todas no mesmo instante
+func (p retryPolicy) delay(attempt int) time.Duration {
+	d := p.MaxDelay
+	if attempt-1 < 32 {
+		d = min(p.BaseDelay<<(attempt-1), p.MaxDelay)
+	}
+	if d <= 0 {
+		d = p.MaxDelay
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// withRetry chama fn até dar certo, a falha não ser transitória (ver
+// retryable) ou as tentativas acabarem. Devolve o último erro.
+func withRetry[T any](ctx context.Context, p retryPolicy, op string, fn func(context.Context) (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		v, err := fn(ctx)
+		if err == nil || attempt >= p.Attempts || !retryable(ctx, err) {
+			return v, err
+		}
+
+		wait := p.delay(attempt)
+		engineLog.WarnContext(ctx, "Falhou; tentando de novo", "op", op, "err", err, "attempt", attempt+1, "attempts", p.Attempts, "wait", wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return v, err
+		case <-timer.C:
+		}
+	}
+}
+
+// httpStatusError é uma resposta de erro do sidecar
+type httpStatusError struct {
+	// Op é o prefixo da mensagem ("embed error", "generate error")
+	Op     string
+	Status int
+	Body   string
+}
+
+func (e *httpStatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s: %d %s", e.Op, e.Status, http.StatusText(e.Status))
+	}
+	return fmt.Sprintf("%s: %s", e.Op, e.Body)
+}
+
+// readStatusError monta o httpStatusError de uma resposta fora do 200
+func readStatusError(op string, resp *http.Response) error {
+	raw, _ := io.ReadAll(resp.Body)
+	return &httpStatusError{Op: op, Status: resp.StatusCode, Body: string(raw)}
+}
+
+// retryable separa as falhas transitórias (vale tentar de novo) das fatais.
+// Cancelamento e prazo do pedido nunca são reenviados; um timeout só da
+// tentativa (o e.timeout de cada chamada ao Qdrant) é.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.Status {
+		case http.StatusRequestTimeout, http.StatusBadGateway, http.StatusGatewayTimeout:
+			return true
+		}
+		// 4xx é pedido inválido; 500 é erro do modelo, que se repetiria; 429
+		// e 503 chegam aqui só depois dos reenvios do providerHTTP
+		return false
+	}
+
+	if s, ok := status.FromError(err); ok && s.Code() != codes.Unknown {
+		switch s.Code() {
+		case codes.Unavailable, codes.Aborted, codes.DeadlineExceeded:
+			return true
+		}
+		return false
+	}
+
+	// Timeout da tentativa (prazo próprio ou do http.Client). *url.Error
+	// também é um net.Error, então só o Timeout decide.
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	// Da rede, só as falhas de conexão (sidecar ou Qdrant reiniciando):
+	// discagem, conexão recusada ou derrubada. DNS ("no such host") não
+	// muda na próxima tentativa, e uma resposta cortada (EOF) pode ser de
+	// uma geração que já gastou tokens.
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryable(t *testing.T) {
+	dial := func(err error) error {
+		return &url.Error{Op: "Post", URL: "http://sidecar:8000/embed", Err: &net.OpError{Op: "dial", Net: "tcp", Err: err}}
+	}
+	read := func(err error) error {
+		return &url.Error{Op: "Post", URL: "http://sidecar:8000/generate", Err: &net.OpError{Op: "read", Net: "tcp", Err: err}}
+	}
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		// gRPC (Qdrant)
+		{"grpc unavailable", status.Error(codes.Unavailable, "connection refused"), true},
+		{"grpc aborted", status.Error(codes.Aborted, "conflito"), true},
+		{"grpc deadline", status.Error(codes.DeadlineExceeded, "prazo"), true},
+		{"grpc not found", status.Error(codes.NotFound, "collection"), false},
+		{"grpc invalid argument", status.Error(codes.InvalidArgument, "dim"), false},
+		{"grpc permission", status.Error(codes.PermissionDenied, "api key"), false},
+		{"grpc resource exhausted", status.Error(codes.ResourceExhausted, "cheio"), false},
+		{"grpc internal", status.Error(codes.Internal, "panic"), false},
+
+		// Sidecar (httpStatusError)
+		{"http 408", &httpStatusError{Op: "embed error", Status: http.StatusRequestTimeout}, true},
+		{"http 502", &httpStatusError{Op: "embed error", Status: http.StatusBadGateway}, true},
+		{"http 504", &httpStatusError{Op: "generate error", Status: http.StatusGatewayTimeout}, true},
+		{"http 400", &httpStatusError{Op: "embed error", Status: http.StatusBadRequest}, false},
+		{"http 401", &httpStatusError{Op: "embed error", Status: http.StatusUnauthorized}, false},
+		{"http 429", &httpStatusError{Op: "generate error", Status: http.StatusTooManyRequests}, false},
+		{"http 500", &httpStatusError{Op: "generate error", Status: http.StatusInternalServerError}, false},
+		{"http 503", &httpStatusError{Op: "generate error", Status: http.StatusServiceUnavailable}, false},
+		{"http embrulhado", fmt.Errorf("embed: %w", &httpStatusError{Op: "embed error", Status: http.StatusBadGateway}), true},
+
+		// Rede
+		{"conexão recusada", dial(os.NewSyscallError("connect", syscall.ECONNREFUSED)), true},
+		{"conexão derrubada", read(os.NewSyscallError("read", syscall.ECONNRESET)), true},
+		{"discagem", dial(errors.New("network is unreachable")), true},
+		{"timeout de rede", dial(&timeoutError{}), true},
+		{"dns no such host", dial(&net.DNSError{Err: "no such host", Name: "sidecar", IsNotFound: true}), false},
+		{"dns timeout", dial(&net.DNSError{Err: "i/o timeout", Name: "sidecar", IsTimeout: true}), true},
+		{"leitura cortada", read(errors.New("use of closed network connection")), false},
+		{"EOF", &url.Error{Op: "Post", URL: "http://sidecar:8000/generate", Err: io.EOF}, false},
+		{"EOF inesperado", fmt.Errorf("decode: %w", io.ErrUnexpectedEOF), false},
+		{"erro qualquer", errors.New("json inválido"), false},
+
+		// Cancelamento e prazos
+		{"cancelado", fmt.Errorf("embed: %w", context.Canceled), false},
+		{"prazo da tentativa", &url.Error{Op: "Post", URL: "http://sidecar:8000/embed", Err: context.DeadlineExceeded}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := retryable(context.Background(), tc.err); got != tc.want {
+				t.Errorf("retryable(%v) = %v, esperado %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+// Com o contexto do pedido cancelado ou vencido, nada é reenviado, nem o que
+// seria transitório
+func TestRetryableRequestContextDone(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-expired.Done()
+
+	transient := []error{
+		status.Error(codes.Unavailable, "x"),
+		status.Error(codes.DeadlineExceeded, "x"),
+		&httpStatusError{Op: "embed error", Status: http.StatusBadGateway},
+		context.DeadlineExceeded,
+	}
+	for _, ctx := range []context.Context{canceled, expired} {
+		for _, err := range transient {
+			if retryable(ctx, err) {
+				t.Errorf("retryable com contexto %v reenviou %v", ctx.Err(), err)
+			}
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (*timeoutError) Error() string   { return "i/o timeout" }
+func (*timeoutError) Timeout() bool   { return true }
+func (*timeoutError) Temporary() bool { return true }
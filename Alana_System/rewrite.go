@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ==============================
+// Reescrita da pergunta (antes do embedding)
+// ==============================
+
+// Estratégias de reescrita (askOptions.Rewrite)
+const (
+	// rewriteHyDE gera uma resposta hipotética e a busca junto com a
+	// pergunta: o vetor de um "trecho" parecido com os da base acha o que uma
+	// pergunta vaga não acha (Hypothetical Document Embeddings)
+	rewriteHyDE = "hyde"
+	// rewriteMulti gera reformulações da pergunta e busca todas
+	rewriteMulti = "multi"
+)
+
+// rewriteModes são os valores aceitos de rewrite; "off" desliga a reescrita
+// ligada na collection ou no perfil
+var rewriteModes = []string{rewriteHyDE, rewriteMulti, "off"}
+
+// parseRewriteMode valida o rewrite e devolve o valor de askOptions.Rewrite
+// ("off" vira vazio)
+func parseRewriteMode(mode string) (string, error) {
+	if !slices.Contains(rewriteModes, mode) {
+		return "", fmt.Errorf("rewrite desconhecido %q (use %s)", mode, strings.Join(rewriteModes, ", "))
+	}
+	if mode == "off" {
+		return "", nil
+	}
+	return mode, nil
+}
+
+const (
+	// rewriteMaxQueries é quantas reformulações o multi busca, além da pergunta
+	rewriteMaxQueries = 3
+	// rewriteMaxRunes descarta reformulações que não são perguntas (o modelo
+	// respondeu em vez de reformular)
+	rewriteMaxRunes = 300
+)
+
+const hydePrompt = "{context}Escreva um parágrafo curto, como um trecho de documento, que responda à pergunta abaixo. " +
+	"Não diga que é hipotético e não faça perguntas.\n\n" +
+	"Pergunta: {question}\n" +
+	"Trecho:"
+
+const multiQueryPrompt = "{context}Reescreva a pergunta abaixo de 3 formas diferentes, com outras palavras e sinônimos, " +
+	"para buscar em uma base de documentos. Escreva uma por linha, sem numeração e sem nenhum outro texto.\n\n" +
+	"Pergunta: {question}\n" +
+	"Reformulações:"
+
+// rewriteQuery gera as consultas extras da estratégia opts.Rewrite, com o
+// gerador da pergunta (opts.Override). A etapa é opcional: se o gerador
+// falhar, a busca segue só com a pergunta.
+func (e *AlanaEngine) rewriteQuery(ctx context.Context, question string, opts askOptions) []string {
+	prompt := hydePrompt
+	if opts.Rewrite == rewriteMulti {
+		prompt = multiQueryPrompt
+	}
+
+	ctx, sp := startSpan(ctx, "rewrite", spanInternal)
+	sp.set("alana.rewrite", opts.Rewrite)
+	text, err := getAnswerWith(ctx, question, "", opts.Override, prompt)
+	var queries []string
+	if err == nil {
+		queries = parseRewrite(opts.Rewrite, question, text)
+	}
+	sp.set("alana.queries", len(queries))
+	sp.finish(err)
+	if err != nil {
+		engineLog.WarnContext(ctx, "Reescrita da pergunta falhou, buscando só a original", "rewrite", opts.Rewrite, "err", err)
+	}
+	return queries
+}
+
+// parseRewrite extrai as consultas da resposta do modelo: o parágrafo do
+// HyDE inteiro, ou as reformulações do multi (uma por linha, sem repetir a
+// pergunta)
+func parseRewrite(mode, question, text string) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if mode == rewriteHyDE {
+		return []string{text}
+	}
+
+	var queries []string
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(question)): true}
+	for _, line := range strings.Split(text, "\n") {
+		query := strings.TrimSpace(outlineMarker.ReplaceAllString(strings.TrimSpace(line), ""))
+		query = strings.Trim(query, "*_\"“” ")
+		key := strings.ToLower(query)
+		if query == "" || len([]rune(query)) > rewriteMaxRunes || seen[key] || strings.HasSuffix(query, ":") {
+			continue
+		}
+		seen[key] = true
+		queries = append(queries, query)
+		if len(queries) == rewriteMaxQueries {
+			break
+		}
+	}
+	return queries
+}
+
+// fuseResults combina as listas das consultas por RRF (ver rrfK) e devolve
+// as limit primeiras. Um trecho achado por várias consultas sobe; o Score é
+// a maior similaridade dele entre as consultas, para que os cortes de
+// abstenção valham como numa busca só.
+func fuseResults(lists [][]SearchResult, limit uint64) []SearchResult {
+	fused := map[string]float64{}
+	byID := map[string]SearchResult{}
+	for _, list := range lists {
+		for rank, r := range list {
+			fused[r.ID] += 1 / float64(rrfK+rank+1)
+			if prev, ok := byID[r.ID]; !ok || r.Score > prev.Score {
+				byID[r.ID] = r
+			}
+		}
+	}
+
+	results := make([]SearchResult, 0, len(byID))
+	for _, r := range byID {
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if fused[results[i].ID] != fused[results[j].ID] {
+			return fused[results[i].ID] > fused[results[j].ID]
+		}
+		return results[i].ID < results[j].ID
+	})
+	if uint64(len(results)) > limit {
+		results = results[:limit]
+	}
+	return results
+}
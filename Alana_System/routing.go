@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"alana_system/config"
+)
+
+// ==============================
+// Collections por tipo de documento
+// ==============================
+//
+// Com collections_by_type no config, a ingestão grava PDFs, transcrições de
+// áudio e notas em collections separadas, cada uma com o seu modelo de
+// embedding e os seus ajustes em collections.yaml. A busca escolhe as
+// collections pelo filtro: um pedido com content_types só procura nas dos
+// tipos pedidos; sem ele, procura em todas (e na principal, onde ficam os
+// outros tipos e os conectores). As listas de cada collection são combinadas
+// por reciprocal rank fusion (ver fuseResults), já que os scores de modelos
+// diferentes não são comparáveis.
+
+// searchCollections são as collections de uma busca, a principal primeiro.
+// Sem collections_by_type, só a do engine.
+func (e *AlanaEngine) searchCollections(filter SearchFilter) []string {
+	if len(e.byType) == 0 {
+		return []string{e.collection}
+	}
+	var collections []string
+	add := func(c string) {
+		if !slices.Contains(collections, c) {
+			collections = append(collections, c)
+		}
+	}
+	if len(filter.ContentTypes) == 0 {
+		add(e.collection)
+		for _, docType := range config.DocumentTypes {
+			if c := e.byType[docType]; c != "" {
+				add(c)
+			}
+		}
+		return collections
+	}
+	for _, contentType := range filter.ContentTypes {
+		if c := e.byType[contentType]; c != "" {
+			add(c)
+		} else {
+			add(e.collection)
+		}
+	}
+	return collections
+}
+
+// searchRouted é o searchQuery em cada collection de searchCollections.
+// Devolve uma lista de trechos por collection e o vetor e o engine da
+// primeira que respondeu (usados pelos documentos fixados). Com várias
+// collections, uma que falha (ex: ainda sem nenhum documento do tipo) fica
+// de fora; só é erro se todas falharem.
+func (e *AlanaEngine) searchRouted(ctx context.Context, query string, candidates uint64, opts askOptions) ([]float32, *AlanaEngine, [][]SearchResult, error) {
+	collections := e.searchCollections(opts.Filter)
+	if len(collections) == 1 {
+		vector, target, results, err := e.withCollection(collections[0]).searchQuery(ctx, query, candidates, e.routedOptions(collections[0], opts))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return vector, target, [][]SearchResult{results}, nil
+	}
+
+	var vector []float32
+	var target *AlanaEngine
+	var lists [][]SearchResult
+	var errs []error
+	for _, c := range collections {
+		v, t, results, err := e.withCollection(c).searchQuery(ctx, query, candidates, e.routedOptions(c, opts))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, nil, err
+			}
+			engineLog.WarnContext(ctx, "Busca numa das collections falhou; seguindo com as outras", "collection", c, "err", err)
+			errs = append(errs, fmt.Errorf("%s: %w", c, err))
+			continue
+		}
+		if target == nil {
+			vector, target = v, t
+		}
+		lists = append(lists, results)
+	}
+	if target == nil {
+		return nil, nil, nil, errors.Join(errs...)
+	}
+	return vector, target, lists, nil
+}
+
+// routedOptions são as opções da busca numa collection separada por tipo: o
+// score_threshold e o hybrid dela em collections.yaml valem no lugar dos da
+// principal (outro modelo de embedding, outra escala de scores), e o vetor
+// já calculado pelo cache semântico só serve para a collection dele
+func (e *AlanaEngine) routedOptions(collection string, opts askOptions) askOptions {
+	if collection == e.collection {
+		return opts
+	}
+	if q := opts.embedded; q != nil && q.target.collection != collection {
+		opts.embedded = nil
+	}
+	s := e.collections.collections[collection]
+	if s.ScoreThreshold != nil {
+		opts.ScoreThreshold = *s.ScoreThreshold
+	}
+	if s.Hybrid != nil {
+		opts.Hybrid = *s.Hybrid
+	}
+	return opts
+}
@@ -1,7 +1,10 @@
 import logging
+import os
 import time
+from datetime import datetime, timezone
+from importlib import metadata
 from pathlib import Path
-from typing import List, Optional
+from typing import Any, Dict, Iterable, List, Optional
 import sys
 from concurrent.futures import ThreadPoolExecutor, as_completed
 
@@ -19,8 +22,9 @@ from alana_system.ingestion.note_extractor import NoteExtractor
 from alana_system.ingestion.cleaner import TextCleaner
 
 # Componentes de IA e Memória
-from alana_system.preprocessing.chunker import TextChunker
+from alana_system.preprocessing.chunker import TextChunk, TextChunker
 from alana_system.embeddings.embedder import TextEmbedder
+from alana_system.embeddings.embedding_cache import open_embedding_cache
 from alana_system.memory.vector_store import VectorStore
 from alana_system.memory.graph_store import GraphStore
 from alana_system.inference.llm_engine import LLMEngine
@@ -31,6 +35,25 @@ from alana_system.preprocessing.entity_extractor import (
 
 logger = logging.getLogger(__name__)
 
+# Chunks por lote na ingestão em streaming (_process_document_stream)
+STREAM_BATCH_CHUNKS = 256
+
+# Host do Qdrant (porta HTTP 6333); o orchestrator repassa o do ALANA_QDRANT_ADDR
+QDRANT_HOST = os.environ.get("ALANA_QDRANT_HOST", "localhost")
+
+# Pacote de cada extrator, para a versão registrada na proveniência
+EXTRACTOR_PACKAGES = {"pdf": "pdfplumber", "audio": "openai-whisper"}
+
+
+def package_version(name: Optional[str]) -> str:
+    """Versão instalada do pacote, ou "dev" (notas, pacote ausente)."""
+    if not name:
+        return "dev"
+    try:
+        return metadata.version(name)
+    except metadata.PackageNotFoundError:
+        return "dev"
+
 class IngestionPipeline:
     """Pipeline Omni: Processa PDFs, Áudios e Notas, e extrai conhecimento."""
 
@@ -43,6 +66,7 @@ class IngestionPipeline:
         embedder_device: str = "cpu",
     ):
         self.raw_dir = raw_dir
+        self.whisper_model = whisper_model
 
         # =====================================================
         # Loaders / Extratores de Conteúdo Bruto
@@ -61,10 +85,32 @@ class IngestionPipeline:
         
         # --- Memória Vetorial (RAG) ---
         self.chunker = TextChunker(max_chars=800, overlap_chars=200)
-        self.embedder = TextEmbedder(device=embedder_device)
+        # Com ALANA_EMBED_CACHE, trechos que não mudaram desde a última
+        # ingestão reaproveitam o vetor em vez de voltar para a GPU
+        embed_cache = open_embedding_cache()
+        self.embedder = TextEmbedder(device=embedder_device, cache=embed_cache)
         self.vector_store = VectorStore(
-            collection_name=collection_name, host="localhost", port=6333
+            collection_name=collection_name, host=QDRANT_HOST, port=6333
         )
+        self._stores: Dict[str, VectorStore] = {collection_name: self.vector_store}
+
+        # --- Dual-write (janela de migração de modelo de embedding) ---
+        # Com ALANA_DUAL_WRITE_MODEL e ALANA_DUAL_WRITE_COLLECTION, cada chunk
+        # também é embutido pelo modelo novo e gravado na collection dele. O
+        # orchestrator Go publica/descarta as duas collections juntas.
+        self.dual_embedder: Optional[TextEmbedder] = None
+        self.dual_store: Optional[VectorStore] = None
+        dual_model = os.environ.get("ALANA_DUAL_WRITE_MODEL")
+        dual_collection = os.environ.get("ALANA_DUAL_WRITE_COLLECTION")
+        if dual_model and dual_collection:
+            self.dual_embedder = TextEmbedder(model_name=dual_model, device=embedder_device, cache=embed_cache)
+            self.dual_store = VectorStore(
+                collection_name=dual_collection,
+                host=QDRANT_HOST,
+                port=6333,
+                vector_dim=self.dual_embedder.model.get_sentence_embedding_dimension(),
+            )
+            logger.info(f"Dual-write ligado | modelo={dual_model} collection={dual_collection}")
 
         # --- Memória de Grafo (Knowledge Graph) ---
         self.graph_store = GraphStore()
@@ -74,6 +120,20 @@ class IngestionPipeline:
         logger.info("IngestionPipeline inicializado com EntityExtractor e GraphStore")
 
 
+    def use_collection(self, collection_name: Optional[str]) -> None:
+        """
+        Troca a collection onde os próximos documentos são gravados (o
+        orchestrator separa os tipos com collections_by_type). Cada collection
+        é aberta uma vez e reaproveitada; None mantém a atual.
+        """
+        if not collection_name or collection_name == self.vector_store.collection_name:
+            return
+        if collection_name not in self._stores:
+            self._stores[collection_name] = VectorStore(
+                collection_name=collection_name, host=QDRANT_HOST, port=6333
+            )
+        self.vector_store = self._stores[collection_name]
+
     def run(self) -> None:
         start_time = time.perf_counter()
         logger.info(">>> Iniciando Pipeline de Ingestão Omni <<<")
@@ -142,10 +202,18 @@ class IngestionPipeline:
     # =====================================================
     # Lógica Central de Processamento de Documento
     # =====================================================
-    def _process_document_pages(self, raw_pages: List[PageText], doc_name: str, source: str) -> None:
+    def _process_document_pages(
+        self,
+        raw_pages: List[PageText],
+        doc_name: str,
+        source: str,
+        source_path: Optional[str] = None,
+        ingest_version: Optional[str] = None,
+    ) -> None:
         """
         Lógica unificada para processar páginas.
         Agora com processamento paralelo para a extração de grafos (Entity Extractor).
+        Com ingest_version, os chunks ficam em staging até o orchestrator publicar.
         """
         if not raw_pages:
             logger.warning(f"Documento {doc_name} ({source}) não contém páginas para processar.")
@@ -156,11 +224,58 @@ class IngestionPipeline:
 
         # --- Etapa 1: Dividir em Chunks ---
         logger.info(f"Iniciando chunking para: {doc_name}")
-        chunks = self.chunker.chunk_pages(cleaned_pages, doc_name)
+        chunks = self.chunker.chunk_pages(cleaned_pages, doc_name, source_path=source_path)
         if not chunks:
             logger.warning(f"Nenhum chunk gerado para o documento {doc_name}.")
             return
 
+        self._index_chunks(chunks, doc_name, ingest_version, source)
+        
+        logger.info(f"'{doc_name}' ({source}) concluído com sucesso.")
+
+    def _process_document_stream(
+        self,
+        sections: Iterable[PageText],
+        doc_name: str,
+        source: str,
+        source_path: Optional[str] = None,
+        ingest_version: Optional[str] = None,
+    ) -> None:
+        """
+        Variante de _process_document_pages para arquivos grandes: as seções
+        são limpas, divididas, vetorizadas e gravadas em lotes de
+        STREAM_BATCH_CHUNKS chunks, então a memória não cresce com o arquivo.
+        """
+        logger.info(f"Iniciando ingestão em streaming para: {doc_name}")
+        cleaned = (page for section in sections for page in self.cleaner.clean_pages([section]))
+        chunks = self.chunker.iter_chunks(cleaned, doc_name, source_path=source_path)
+
+        total = 0
+        batch: List[TextChunk] = []
+        for chunk in chunks:
+            batch.append(chunk)
+            if len(batch) >= STREAM_BATCH_CHUNKS:
+                self._index_chunks(batch, doc_name, ingest_version, source)
+                total += len(batch)
+                batch = []
+                logger.info(f"Streaming | {doc_name} | {total} chunks gravados")
+        if batch:
+            self._index_chunks(batch, doc_name, ingest_version, source)
+            total += len(batch)
+
+        if total == 0:
+            logger.warning(f"Nenhum chunk gerado para o documento {doc_name}.")
+            return
+        logger.info(f"'{doc_name}' ({source}) concluído com sucesso | {total} chunks.")
+
+    def _index_chunks(
+        self,
+        chunks: List[TextChunk],
+        doc_name: str,
+        ingest_version: Optional[str],
+        source: str,
+    ) -> None:
+        """Extrai o grafo e grava os vetores de um lote de chunks."""
         # --- Etapa 2: Extração Paralela de Grafo de Conhecimento ---
         logger.info(f"Iniciando extração PARALELA de entidades para {len(chunks)} chunks...")
         
@@ -188,9 +303,32 @@ class IngestionPipeline:
         # --- Etapa 3: Indexação Vetorial (RAG) ---
         logger.info(f"Iniciando indexação vetorial para {len(chunks)} chunks...")
         embedded_chunks = self.embedder.embed_chunks(chunks)
-        self.vector_store.upsert_embeddings(embedded_chunks)
-        
-        logger.info(f"'{doc_name}' ({source}) concluído com sucesso.")
+        self.vector_store.upsert_embeddings(embedded_chunks, ingest_version=ingest_version,
+                                            provenance=self.provenance(source, self.embedder))
+
+        if self.dual_store is not None:
+            dual_chunks = self.dual_embedder.embed_chunks(chunks)
+            self.dual_store.upsert_embeddings(dual_chunks, ingest_version=ingest_version,
+                                              provenance=self.provenance(source, self.dual_embedder))
+
+    def provenance(self, source: str, embedder: TextEmbedder) -> Dict[str, Any]:
+        """
+        Proveniência gravada no payload de cada chunk (campo provenance, ver
+        manifest.Provenance no Go). O orchestrator completa com a fonte, onde
+        o processor.py rodou, os enriquecimentos e a versão publicada.
+        """
+        extractor = {"pdf": "pdfplumber", "audio": f"whisper:{self.whisper_model}"}.get(source, "notes-python")
+        return {
+            "extractor": extractor,
+            "extractor_version": package_version(EXTRACTOR_PACKAGES.get(source)),
+            "chunker": {
+                "max_chars": self.chunker.max_chars,
+                "overlap_chars": self.chunker.overlap_chars,
+                "sentences": False,
+            },
+            "embed_model": embedder.model_name,
+            "embedded_at": datetime.now(timezone.utc).isoformat(),
+        }
 
     def _process_entities(self, text: str, doc_name: str, page_number: int) -> None:
         """
@@ -224,7 +362,7 @@ def main():
 
     # Configuração centralizada
     RAW_DATA_DIR = "data/raw"
-    KNOWLEDGE_BASE_NAME = "alana_knowledge_base"
+    KNOWLEDGE_BASE_NAME = os.environ.get("ALANA_COLLECTION", "alana_knowledge_base")
     WHISPER_MODEL = "small"
     EMBEDDER_DEVICE = "cuda"  # ou "cuda"
     EXTRACTION_MODEL = "models/Meta-Llama-3-8B-Instruct-Q4_K_M.gguf"
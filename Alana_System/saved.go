@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ==============================
+// Perguntas salvas (o que mudou desde a última vez)
+// ==============================
+
+const savedQueriesPath = "./data/saved_queries.json"
+
+// maxDiffWords limita o diff palavra a palavra (O(n·m) em memória)
+const maxDiffWords = 3000
+
+// savedQuery é uma pergunta monitorada com o resultado da última execução
+type savedQuery struct {
+	Question string `json:"question"`
+	Profile  string `json:"profile,omitempty"`
+	// LastRun é a linha de base do `saved check`: a última execução em que
+	// os assinantes foram avisados (ou a primeira)
+	LastRun *savedRun `json:"last_run,omitempty"`
+	// LastDiff é a última execução do `saved diff`, separada de LastRun para
+	// que o diff não esconda do check uma fonte nova
+	LastDiff *savedRun `json:"last_diff,omitempty"`
+	// Subscribers são webhooks (genéricos ou do Slack) avisados quando uma
+	// fonte nova passa a liderar a busca (ver `saved check`)
+	Subscribers []string `json:"subscribers,omitempty"`
+}
+
+type savedRun struct {
+	Time    time.Time     `json:"time"`
+	Answer  string        `json:"answer"`
+	Sources []savedSource `json:"sources"`
+}
+
+// savedSource identifica um trecho usado na resposta. O ID do ponto deriva
+// do conteúdo, então um ID novo no mesmo documento indica texto alterado.
+type savedSource struct {
+	ID     string `json:"id"`
+	Source string `json:"source"`
+	Page   int    `json:"page"`
+}
+
+// runSaved implementa `alana saved`:
+//
+//	saved add [-profile P] <nome> <pergunta...>   salva (ou atualiza) uma pergunta
+//	saved list                                    lista as perguntas salvas
+//	saved rm <nome>                               remove uma pergunta
+//	saved diff <nome>                             roda de novo e mostra o que mudou desde o último diff
+//	saved subscribe <nome> <webhook>              assina as mudanças da pergunta
+//	saved unsubscribe <nome> <webhook>            cancela a assinatura
+//	saved check [-every D]                        notifica os assinantes (ver runSavedCheck)
+func runSaved(ctx context.Context, engine *AlanaEngine, args []string) error {
+	const usage = "uso: saved add [-profile P] <nome> <pergunta...> | saved list | saved rm <nome> | saved diff <nome> | " +
+		"saved subscribe|unsubscribe <nome> <webhook> | saved check [-every D]"
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	queries, err := loadSavedQueries(savedQueriesPath)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("saved add", flag.ContinueOnError)
+		profile := fs.String("profile", "", "perfil de config/collections.yaml")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() < 2 {
+			return errors.New(usage)
+		}
+		name := fs.Arg(0)
+		q, exists := queries[name]
+		question := strings.Join(fs.Args()[1:], " ")
+		// Os assinantes ficam; as execuções anteriores só valem para a
+		// mesma pergunta no mesmo perfil
+		if exists && (q.Question != question || q.Profile != *profile) {
+			q.LastRun, q.LastDiff = nil, nil
+		}
+		q.Question, q.Profile = question, *profile
+		queries[name] = q
+		if err := saveSavedQueries(savedQueriesPath, queries); err != nil {
+			return err
+		}
+		if exists {
+			cliLog.Info("Pergunta atualizada", "name", name)
+		} else {
+			cliLog.Info("Pergunta salva", "name", name)
+		}
+		return nil
+
+	case "list":
+		for _, name := range slices.Sorted(maps.Keys(queries)) {
+			q := queries[name]
+			last := "nunca executada"
+			if run := q.latestRun(); run != nil {
+				last = "última execução " + run.Time.Local().Format("2006-01-02 15:04")
+			}
+			if n := len(q.Subscribers); n > 0 {
+				last += fmt.Sprintf(", %d assinantes", n)
+			}
+			fmt.Printf("%-20s %s (%s)\n", name, q.Question, last)
+		}
+		return nil
+
+	case "rm":
+		if len(args) != 2 {
+			return errors.New(usage)
+		}
+		if _, ok := queries[args[1]]; !ok {
+			return fmt.Errorf("pergunta %q não encontrada", args[1])
+		}
+		delete(queries, args[1])
+		return saveSavedQueries(savedQueriesPath, queries)
+
+	case "diff":
+		if len(args) != 2 {
+			return errors.New(usage)
+		}
+		name := args[1]
+		q, ok := queries[name]
+		if !ok {
+			return fmt.Errorf("pergunta %q não encontrada", name)
+		}
+
+		run, err := engine.runSavedQuery(ctx, q)
+		if err != nil {
+			return err
+		}
+
+		// Sem diff anterior, compara com a linha de base do check (e com o
+		// last_run gravado pelo diff antes de existir last_diff)
+		before := q.LastDiff
+		if before == nil {
+			before = q.LastRun
+		}
+		if before == nil {
+			fmt.Printf("🆕 Primeira execução de %q; nada para comparar.\n\n%s\n", name, run.Answer)
+		} else {
+			fmt.Print(savedRunDiff(name, *before, run))
+		}
+
+		q.LastDiff = &run
+		queries[name] = q
+		return saveSavedQueries(savedQueriesPath, queries)
+
+	case "subscribe", "unsubscribe":
+		if len(args) != 3 {
+			return errors.New(usage)
+		}
+		name, hook := args[1], args[2]
+		q, ok := queries[name]
+		if !ok {
+			return fmt.Errorf("pergunta %q não encontrada", name)
+		}
+		q.Subscribers = slices.DeleteFunc(q.Subscribers, func(s string) bool { return s == hook })
+		if args[0] == "subscribe" {
+			if err := validateWebhook(hook); err != nil {
+				return err
+			}
+			q.Subscribers = append(q.Subscribers, hook)
+		}
+		queries[name] = q
+		return saveSavedQueries(savedQueriesPath, queries)
+
+	case "check":
+		return runSavedCheck(ctx, engine, args[1:])
+	}
+
+	return errors.New(usage)
+}
+
+// latestRun é a execução mais recente, do diff ou do check (nil se nunca rodou)
+func (q savedQuery) latestRun() *savedRun {
+	if q.LastDiff != nil && (q.LastRun == nil || q.LastDiff.Time.After(q.LastRun.Time)) {
+		return q.LastDiff
+	}
+	return q.LastRun
+}
+
+// runSavedQuery executa a pergunta com as opções do perfil salvo
+func (e *AlanaEngine) runSavedQuery(ctx context.Context, q savedQuery) (savedRun, error) {
+	opts, err := e.collections.askOptions(e.collection, q.Profile, retrievalSettings{})
+	if err != nil {
+		return savedRun{}, err
+	}
+
+	answer, err := e.Ask(ctx, q.Question, opts)
+	if err != nil {
+		return savedRun{}, err
+	}
+
+	run := savedRun{Time: time.Now().UTC(), Answer: answer.Text}
+	for _, src := range answer.Sources {
+		run.Sources = append(run.Sources, savedSource{ID: src.ID, Source: src.Source, Page: src.Page})
+	}
+	return run, nil
+}
+
+// savedRunDiff descreve as mudanças nas fontes e na resposta entre duas execuções
+func savedRunDiff(name string, before, after savedRun) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔎 %s: %s → %s\n\n", name,
+		before.Time.Local().Format("2006-01-02 15:04"), after.Time.Local().Format("2006-01-02 15:04"))
+
+	added, removed := diffSources(before.Sources, after.Sources)
+	changedDocs := map[string]bool{}
+	for _, a := range added {
+		for _, r := range removed {
+			if a.Source == r.Source {
+				changedDocs[a.Source] = true
+			}
+		}
+	}
+
+	if len(added) == 0 && len(removed) == 0 {
+		b.WriteString("📚 Fontes: sem mudanças\n")
+	} else {
+		b.WriteString("📚 Fontes:\n")
+		for _, s := range removed {
+			fmt.Fprintf(&b, "   - %s (pág %d) [%s]\n", s.Source, s.Page, s.ID)
+		}
+		for _, s := range added {
+			fmt.Fprintf(&b, "   + %s (pág %d) [%s]\n", s.Source, s.Page, s.ID)
+		}
+		for _, doc := range slices.Sorted(maps.Keys(changedDocs)) {
+			fmt.Fprintf(&b, "   ✏️  %s teve trechos alterados\n", doc)
+		}
+	}
+	b.WriteString("\n")
+
+	if before.Answer == after.Answer {
+		b.WriteString("💬 Resposta: sem mudanças\n")
+		return b.String()
+	}
+	b.WriteString("💬 Resposta ([-removido-] {+adicionado+}):\n\n")
+	b.WriteString(wordDiff(before.Answer, after.Answer))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// diffSources devolve os trechos que entraram e os que saíram da resposta
+func diffSources(before, after []savedSource) (added, removed []savedSource) {
+	inBefore := map[string]bool{}
+	for _, s := range before {
+		inBefore[s.ID] = true
+	}
+	inAfter := map[string]bool{}
+	for _, s := range after {
+		inAfter[s.ID] = true
+		if !inBefore[s.ID] {
+			added = append(added, s)
+		}
+	}
+	for _, s := range before {
+		if !inAfter[s.ID] {
+			removed = append(removed, s)
+		}
+	}
+	return added, removed
+}
+
+// wordDiff marca as palavras removidas e adicionadas (estilo git --word-diff),
+// pela maior subsequência comum. Textos grandes demais são comparados inteiros.
+func wordDiff(before, after string) string {
+	a, b := strings.Fields(before), strings.Fields(after)
+	if len(a) > maxDiffWords || len(b) > maxDiffWords {
+		return "[-" + before + "-]\n{+" + after + "+}"
+	}
+
+	// lcs[i][j] = tamanho da maior subsequência comum de a[i:] e b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []string
+	var del, ins []string
+	flush := func() {
+		if len(del) > 0 {
+			out = append(out, "[-"+strings.Join(del, " ")+"-]")
+			del = nil
+		}
+		if len(ins) > 0 {
+			out = append(out, "{+"+strings.Join(ins, " ")+"+}")
+			ins = nil
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			flush()
+			out = append(out, a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			ins = append(ins, b[j])
+			j++
+		default:
+			del = append(del, a[i])
+			i++
+		}
+	}
+	flush()
+	return strings.Join(out, " ")
+}
+
+func loadSavedQueries(path string) (map[string]savedQuery, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]savedQuery{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	queries := map[string]savedQuery{}
+	if err := json.Unmarshal(data, &queries); err != nil {
+		return nil, fmt.Errorf("invalid saved queries %s: %w", path, err)
+	}
+	return queries, nil
+}
+
+func saveSavedQueries(path string, queries map[string]savedQuery) error {
+	data, err := json.MarshalIndent(queries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
@@ -0,0 +1,97 @@
+// Package schema descreve o payload dos pontos gravados no Qdrant e a sua
+// versão, compartilhado entre o orchestrator (que grava) e o binário de
+// consulta (que migra e valida).
+//
+// Histórico de versões:
+//
+//	1: original_id, page_number, text, file_name (gravados pelo processor.py)
+//	2: + schema_version, content_type, tags
+//
+// Campos opcionais, sem mudança de versão (pontos antigos não os têm):
+//
+//	provenance: como o chunk foi processado (ver manifest.Provenance)
+package schema
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ProvenanceField é o campo do payload com a proveniência do chunk
+const ProvenanceField = "provenance"
+
+// Version é a versão atual do schema de payload
+const Version = 2
+
+// Tipos de conteúdo do campo content_type
+const (
+	ContentPDF   = "pdf"
+	ContentAudio = "audio"
+	ContentNote  = "note"
+	// ContentText é o texto enviado direto pelo IngestText, sem arquivo
+	ContentText = "text"
+	// Itens dos conectores (ver `alana sync`): issues, pull/merge requests,
+	// páginas de wiki, tickets (Jira, Zendesk), artigos da base de
+	// conhecimento (Zendesk Guide) e páginas de sites (sitemap ou feed)
+	ContentIssue       = "issue"
+	ContentPullRequest = "pull_request"
+	ContentWiki        = "wiki"
+	ContentTicket      = "ticket"
+	ContentArticle     = "article"
+	ContentWebPage     = "web_page"
+)
+
+// Direct diz se o content_type é de um documento gravado sem arquivo
+// (IngestText e conectores), que não tem entrada no manifesto
+func Direct(contentType string) bool {
+	switch contentType {
+	case ContentText, ContentIssue, ContentPullRequest, ContentWiki, ContentTicket, ContentArticle, ContentWebPage:
+		return true
+	}
+	return false
+}
+
+// ContentType deduz o content_type pela extensão do arquivo de origem, com
+// as mesmas extensões aceitas pelo orchestrator. Devolve "" se desconhecida.
+func ContentType(fileName string) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".pdf":
+		return ContentPDF
+	case ".mp3", ".wav", ".m4a":
+		return ContentAudio
+	case ".txt", ".md":
+		return ContentNote
+	}
+	return ""
+}
+
+// Plain converte um valor do payload em tipos Go comuns (string, int64,
+// float64, bool, []any, map[string]any, nil), para ser serializado em JSON
+// ou decodificado numa struct
+func Plain(v *qdrant.Value) any {
+	switch k := v.GetKind().(type) {
+	case *qdrant.Value_StringValue:
+		return k.StringValue
+	case *qdrant.Value_IntegerValue:
+		return k.IntegerValue
+	case *qdrant.Value_DoubleValue:
+		return k.DoubleValue
+	case *qdrant.Value_BoolValue:
+		return k.BoolValue
+	case *qdrant.Value_ListValue:
+		out := make([]any, len(k.ListValue.GetValues()))
+		for i, item := range k.ListValue.GetValues() {
+			out[i] = Plain(item)
+		}
+		return out
+	case *qdrant.Value_StructValue:
+		out := make(map[string]any, len(k.StructValue.GetFields()))
+		for key, item := range k.StructValue.GetFields() {
+			out[key] = Plain(item)
+		}
+		return out
+	}
+	return nil
+}
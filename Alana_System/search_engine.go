@@ -4,17 +4,24 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"alana_system/assemble"
+	"alana_system/config"
+	"alana_system/logging"
+	"alana_system/render"
+	"alana_system/sidecarproto"
+	"alana_system/textstore"
+	"alana_system/vecenc"
+
 	"github.com/qdrant/go-client/qdrant"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 // ==============================
@@ -22,14 +29,56 @@ import (
 // ==============================
 
 type SearchResult struct {
-	Text  string
-	Page  int
-	Score float32
+	ID     string
+	Source string
+	Title  string
+	Text   string
+	Page   int
+	Score  float32
+	// Relevance é a relevância do cross-encoder em 0..1 (só com re-ranking)
+	Relevance float32
+	// ContentType, Tags e CreatedAt (RFC 3339) são os metadados do
+	// documento, os mesmos dos filtros (ver SearchFilter)
+	ContentType string
+	Tags        []string
+	CreatedAt   string
+	// Pinned marca os trechos dos documentos fixados no pedido (ver
+	// askOptions.Pin): vão primeiro para o contexto, qualquer que seja o score
+	Pinned bool
+	// Vector é o vetor denso do trecho, só com SearchOptions.WithVectors
+	Vector []float32
+	// ACL são os grupos que podem ver o trecho (vazio = público, ver
+	// accessPolicy)
+	ACL []string
+	// Degraded marca os trechos do índice local (ver localIndex), usado com
+	// o Qdrant fora do ar: Score é o BM25, não a similaridade
+	Degraded bool
+
+	// offloaded indica que o texto está no text store, não no payload
+	offloaded bool
+}
+
+// SearchOptions ajusta uma busca vetorial
+type SearchOptions struct {
+	TopK uint64
+	// ScoreThreshold é a similaridade mínima (zero = sem mínimo)
+	ScoreThreshold float32
+	// Filter restringe a busca pelos metadados (SearchFilter{} = collection
+	// inteira)
+	Filter SearchFilter
+	// WithVectors traz o vetor denso de cada trecho em SearchResult.Vector
+	WithVectors bool
+}
+
+// defaultSearchOptions são os padrões da busca: 5 trechos, com o
+// score_threshold de config/alana.yaml
+func defaultSearchOptions() SearchOptions {
+	return SearchOptions{TopK: 5, ScoreThreshold: defaultScoreThreshold}
 }
 
 // Senior Pattern: Interface
 type VectorSearcher interface {
-	Search(ctx context.Context, vector []float32, topK uint64) ([]SearchResult, error)
+	Search(ctx context.Context, vector []float32, opts SearchOptions) ([]SearchResult, error)
 }
 
 // ==============================
@@ -38,89 +87,129 @@ type VectorSearcher interface {
 
 type EmbedRequest struct {
 	Text string `json:"text"`
+	// Model é o modelo de embedding da collection (vazio = padrão do sidecar)
+	Model string `json:"model,omitempty"`
+	// Encoding pede o vetor no formato binário (ver vecenc)
+	Encoding string `json:"encoding,omitempty"`
 }
 
+// EmbedResponse traz o vetor em Vector (JSON) ou, se o pedido teve Encoding,
+// em Data (base64 do datatype Dtype). Sidecars antigos ignoram o Encoding e
+// respondem em JSON.
 type EmbedResponse struct {
 	Vector []float32 `json:"vector"`
+	Data   string    `json:"data,omitempty"`
+	Dtype  string    `json:"dtype,omitempty"`
 }
 
 type GenerateRequest struct {
-	Query   string `json:"query"`
-	Context string `json:"context"`
+	Query    string `json:"query"`
+	Context  string `json:"context"`
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// PromptTemplate substitui o prompt padrão do sidecar ({context}, {question})
+	PromptTemplate string `json:"prompt_template,omitempty"`
+	// Temperature e MaxTokens vêm da seção generation do config (nil/0 =
+	// padrão do provedor)
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
 }
 
-type GenerateResponse struct {
-	Answer string `json:"answer"`
-}
+// GenerateResponse é a resposta do /generate do sidecar, na versão do
+// contrato negociada (ver sidecarproto)
+type GenerateResponse = sidecarproto.GenerateResponse
 
-// AJUSTE: Forçando IPv4 para evitar erros de conexão no Windows (::1)
-const sidecarURL = "http://127.0.0.1:8000"
+// sidecarURL é a base do sidecar Python; main aplica config.Load por cima
+// do padrão (ALANA_SIDECAR_URL)
+var sidecarURL = config.Default().SidecarURL
 
-// getEmbedding chama o endpoint /embed do sidecar
-func getEmbedding(ctx context.Context, query string) ([]float32, error) {
-	body, err := json.Marshal(EmbedRequest{Text: query})
+// getEmbeddingAt chama o /embed de um sidecar (o da collection ou o
+// fallback), com o modelo informado (vazio = padrão do sidecar), pedindo o
+// vetor no formato binário encoding (vazio = JSON)
+func getEmbeddingAt(ctx context.Context, baseURL, model, encoding, query string) ([]float32, error) {
+	body, err := json.Marshal(EmbedRequest{Text: query, Model: model, Encoding: encoding})
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sidecarURL+"/embed", bytes.NewBuffer(body))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
+	return withRetry(ctx, retries, "embed", func(ctx context.Context) ([]float32, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/embed", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		sidecarproto.Offer(req.Header)
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+		resp, err := providerHTTP.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		raw, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("embed error: %s", string(raw))
-	}
+		if resp.StatusCode != http.StatusOK {
+			return nil, readStatusError("embed error", resp)
+		}
 
-	var out EmbedResponse
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return nil, err
-	}
+		var out EmbedResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			return nil, err
+		}
+		if out.Data != "" {
+			return vecenc.Decode(nil, out.Data, out.Dtype)
+		}
+		return out.Vector, nil
+	})
+}
 
-	return out.Vector, nil
+// generationOverride troca o provedor/modelo de geração de um único pedido.
+// O valor zero usa o provedor e o modelo padrão do config.
+type generationOverride struct {
+	Provider string
+	Model    string
 }
 
-// getAnswer chama o endpoint /generate do sidecar
+// getAnswer gera a resposta com o provedor e o modelo padrão
 func getAnswer(ctx context.Context, query, contextText string) (string, error) {
-	body, err := json.Marshal(GenerateRequest{
-		Query:   query,
-		Context: contextText,
-	})
-	if err != nil {
-		return "", err
-	}
+	return getAnswerWith(ctx, query, contextText, generationOverride{}, "")
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sidecarURL+"/generate", bytes.NewBuffer(body))
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Content-Type", "application/json")
+// getAnswerWith gera a resposta com o provedor do override (vazio = o do
+// config, ver Generator), aplicando o override e o template de prompt
+// informados (vazio = prompt padrão)
+func getAnswerWith(ctx context.Context, query, contextText string, override generationOverride, promptTemplate string) (answer string, err error) {
+	genReq := generationRequest(query, contextText, override, promptTemplate)
+	start := time.Now()
+	defer func() { providerLog.record("/generate", genReq, answer, err, time.Since(start)) }()
 
-	resp, err := http.DefaultClient.Do(req)
+	gen, err := generatorFor(genReq.Provider)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
+	return gen.Generate(ctx, genReq)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		raw, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("generate error: %s", string(raw))
-	}
+// getAnswerStream gera a resposta em streaming e repassa cada pedaço a
+// onToken. Cancelar ctx interrompe a geração no provedor.
+func getAnswerStream(
+	ctx context.Context,
+	query, contextText string,
+	override generationOverride,
+	promptTemplate string,
+	onToken func(string),
+) (err error) {
+	genReq := generationRequest(query, contextText, override, promptTemplate)
+	var streamed strings.Builder
+	start := time.Now()
+	defer func() { providerLog.record("/generate/stream", genReq, streamed.String(), err, time.Since(start)) }()
 
-	var out GenerateResponse
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return "", err
+	gen, err := generatorFor(genReq.Provider)
+	if err != nil {
+		return err
 	}
-
-	return out.Answer, nil
+	return gen.Stream(ctx, genReq, func(token string) {
+		streamed.WriteString(token)
+		onToken(token)
+	})
 }
 
 // ==============================
@@ -130,7 +219,45 @@ func getAnswer(ctx context.Context, query, contextText string) (string, error) {
 type AlanaEngine struct {
 	client     *qdrant.Client
 	collection string
+	// qdrantAddr é o host:porta gRPC do client, para os diagnósticos
+	qdrantAddr string
 	timeout    time.Duration
+	models     *modelRegistry
+	// collections guarda os padrões de recuperação e prompt por collection
+	collections *collectionRegistry
+	// byType é a collection de cada tipo de documento separado da principal
+	// (config.CollectionsByType); a busca procura nelas também (ver
+	// searchCollections)
+	byType map[string]string
+	// texts guarda o texto dos chunks fora do Qdrant; nil = texto no payload
+	texts textstore.Store
+	// fallback é o embedder usado quando o sidecar principal falha (nil = sem fallback)
+	fallback *embeddingFallback
+	// local é o índice BM25 usado quando o Qdrant cai (nil = sem índice local)
+	local *localFallback
+	// usage registra os trechos recuperados e citados (nil = não registra)
+	usage *jsonlLog
+	// readOnly recusa qualquer escrita na collection (ver writable)
+	readOnly bool
+	// env é a configuração carregada; yesProd confirma os comandos
+	// destrutivos num ambiente protegido (ver confirmDestructive)
+	env     config.Config
+	yesProd bool
+	// vectorInfos guarda a dimensão e o datatype do vetor de cada collection
+	// (ver vectorInfo)
+	vectorInfos *sync.Map
+	// sparseVectors guarda se cada collection tem o vetor esparso da busca
+	// híbrida (ver hasSparseVector)
+	sparseVectors *sync.Map
+	// reranker reordena os trechos quando askOptions.Rerank está ligado
+	reranker reranker
+	// memory guarda os turnos das conversas por usuário (nil = desligada)
+	memory *chatMemory
+	// answers guarda as respostas geradas (nil = sem cache, ver openAnswerCache)
+	answers answerCache
+	// semantic reaproveita a resposta de perguntas parecidas (nil = desligado,
+	// ver openSemanticCache)
+	semantic *semanticCache
 }
 
 // Compile-time guarantee
@@ -138,161 +265,338 @@ var _ VectorSearcher = (*AlanaEngine)(nil)
 
 func NewAlanaEngine(client *qdrant.Client, collection string) *AlanaEngine {
 	return &AlanaEngine{
-		client:     client,
-		collection: collection,
-		timeout:    10 * time.Second,
+		client:        client,
+		collection:    collection,
+		qdrantAddr:    config.Default().QdrantAddr,
+		timeout:       10 * time.Second,
+		models:        newModelRegistry(),
+		collections:   newCollectionRegistry(),
+		vectorInfos:   &sync.Map{},
+		sparseVectors: &sync.Map{},
+		reranker:      rerankerFromEnv(),
 	}
 }
 
-// Search executa a busca vetorial REAL usando PointsClient
+// Search executa a busca vetorial REAL usando PointsClient, com o topK, a
+// similaridade mínima e o filtro de opts
 func (e *AlanaEngine) Search(
 	ctx context.Context,
 	vector []float32,
-	topK uint64,
+	opts SearchOptions,
 ) ([]SearchResult, error) {
 
-	ctx, cancel := context.WithTimeout(ctx, e.timeout)
-	defer cancel()
-
-	// AJUSTE: Forçando IPv4 (127.0.0.1) para a conexão gRPC direta ao Qdrant
-	conn, err := grpc.DialContext(ctx, "127.0.0.1:6334", grpc.WithTransportCredentials(insecure.NewCredentials()))
-	if err != nil {
-		return nil, fmt.Errorf("failed to dial qdrant: %w", err)
-	}
-	defer conn.Close()
-
-	pointsClient := qdrant.NewPointsClient(conn)
-
-	scoreThreshold := float32(0.3)
-
-	resp, err := pointsClient.Search(ctx, &qdrant.SearchPoints{
+	// Usa as conexões do cliente injetado (pool com keepalive, ver
+	// newQdrantClient). WaitForReady faz a busca esperar a reconexão, dentro
+	// do timeout, em vez de falhar na hora se a conexão acabou de cair. Cada
+	// tentativa tem o seu timeout (ver withRetry).
+	req := &qdrant.SearchPoints{
 		CollectionName: e.collection,
 		Vector:         vector,
-		Limit:          topK,
+		Filter:         opts.Filter.qdrantFilter(),
+		Limit:          opts.TopK,
 		WithPayload: &qdrant.WithPayloadSelector{
 			SelectorOptions: &qdrant.WithPayloadSelector_Enable{
 				Enable: true,
 			},
 		},
-		ScoreThreshold: &scoreThreshold,
+		WithVectors: qdrant.NewWithVectors(opts.WithVectors),
+	}
+	// Zero não corta nada (nem similaridades negativas)
+	if opts.ScoreThreshold > 0 {
+		req.ScoreThreshold = &opts.ScoreThreshold
+	}
+	resp, err := withRetry(ctx, retries, "qdrant search", func(ctx context.Context) (*qdrant.SearchResponse, error) {
+		ctx, cancel := context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+		return e.client.GetPointsClient().Search(ctx, req, grpc.WaitForReady(true))
 	})
 	if err != nil {
 		return nil, fmt.Errorf("qdrant search failed: %w", err)
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
 	results := make([]SearchResult, 0, len(resp.GetResult()))
 
 	for _, point := range resp.GetResult() {
-		payload := point.GetPayload()
+		r := resultFromPayload(point.GetId(), point.GetPayload(), point.GetScore())
+		if opts.WithVectors {
+			r.Vector = denseVector(point.GetVectors())
+		}
+		results = append(results, r)
+	}
+
+	if err := e.loadTexts(ctx, results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// resultFromPayload converte um ponto do Qdrant no SearchResult do domínio
+func resultFromPayload(id *qdrant.PointId, payload map[string]*qdrant.Value, score float32) SearchResult {
+	r := SearchResult{
+		ID:    pointIDString(id),
+		Score: score,
+	}
 
-		text := ""
-		if v, ok := payload["text"]; ok {
-			text = v.GetStringValue()
+	if v, ok := payload["text"]; ok {
+		text, err := decodeText(v.GetStringValue(), payload["text_codec"].GetStringValue())
+		if err != nil {
+			engineLog.Warn("Texto ilegível", "point", r.ID, "err", err)
 		}
+		r.Text = text
+	}
+	if v, ok := payload["text_offloaded"]; ok {
+		r.offloaded = v.GetBoolValue()
+	}
+	if v, ok := payload["file_name"]; ok {
+		r.Source = v.GetStringValue()
+	}
+	if v, ok := payload["title"]; ok {
+		r.Title = v.GetStringValue()
+	}
+	if v, ok := payload["page_number"]; ok {
+		r.Page = int(v.GetIntegerValue())
+	}
+	r.ContentType = payload["content_type"].GetStringValue()
+	for _, tag := range payload["tags"].GetListValue().GetValues() {
+		r.Tags = append(r.Tags, tag.GetStringValue())
+	}
+	r.CreatedAt = payload["created_at"].GetStringValue()
+	for _, group := range payload["acl"].GetListValue().GetValues() {
+		r.ACL = append(r.ACL, group.GetStringValue())
+	}
 
-		page := 0
-		if v, ok := payload["page_number"]; ok {
-			page = int(v.GetIntegerValue())
+	return r
+}
+
+// loadTexts preenche o texto dos resultados cujo payload não o contém
+// (text_offloaded), buscando-o no text store numa única consulta
+func (e *AlanaEngine) loadTexts(ctx context.Context, results []SearchResult) error {
+	var ids []string
+	for _, r := range results {
+		if r.offloaded {
+			ids = append(ids, r.ID)
 		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if e.texts == nil {
+		return errors.New("chunks with offloaded text but ALANA_TEXT_STORE is not set")
+	}
 
-		results = append(results, SearchResult{
-			Text:  text,
-			Page:  page,
-			Score: point.GetScore(),
-		})
+	texts, err := e.texts.Get(ctx, ids)
+	if err != nil {
+		return fmt.Errorf("text store: %w", err)
 	}
+	for i := range results {
+		if results[i].offloaded {
+			results[i].Text = texts[results[i].ID]
+		}
+	}
+	return nil
+}
 
-	return results, nil
+// pointIDString devolve o ID do ponto como texto (UUID ou numérico)
+func pointIDString(id *qdrant.PointId) string {
+	if id == nil {
+		return ""
+	}
+	if u := id.GetUuid(); u != "" {
+		return u
+	}
+	return fmt.Sprintf("%d", id.GetNum())
 }
 
-// AssembleContext monta o contexto final para o LLM
+// AssembleContext monta o contexto final para o LLM no formato padrão
+// (ver assemble.DefaultOptions), com o orçamento assemble.BudgetSentence
+// medido no tokenizer do modelo (vazio = o ativo)
 func (e *AlanaEngine) AssembleContext(
 	results []SearchResult,
 	tokenLimit int,
+	model string,
 ) string {
+	opts := assemble.DefaultOptions(tokenLimit)
+	opts.Budget = assemble.BudgetSentence
+	opts.Tokenizer = e.models.tokenizer(model)
+	return assemble.Context(assembleChunks(results), opts)
+}
 
-	charLimit := tokenLimit * 3
-
-	var b strings.Builder
-	b.WriteString("Contexto recuperado dos documentos:\n\n")
-
-	for _, r := range results {
-		block := fmt.Sprintf(
-			"--- [Fonte/Pág %d | Score %.2f] ---\n%s\n\n",
-			r.Page,
-			r.Score,
-			r.Text,
-		)
-
-		if b.Len()+len(block) > charLimit {
-			b.WriteString("[Contexto truncado por limite de tokens]")
-			break
-		}
-
-		b.WriteString(block)
+// assembleChunks converte os resultados da busca para o pacote assemble
+func assembleChunks(results []SearchResult) []assemble.Chunk {
+	chunks := make([]assemble.Chunk, len(results))
+	for i, r := range results {
+		chunks[i] = assemble.Chunk{ID: r.ID, Source: r.Source, Title: r.Title, Text: r.Text, Page: r.Page, Score: r.Score, Pinned: r.Pinned}
 	}
-
-	return b.String()
+	return chunks
 }
 
 // ==============================
 // Main
 // ==============================
 
+// Conexão com o Qdrant: um pool criado uma única vez e compartilhado por todas
+// as buscas. O gRPC reconecta sozinho; o keepalive detecta conexões mortas
+// (ex: Qdrant reiniciado) sem esperar a próxima busca falhar.
+const (
+	qdrantPoolSize         = 3
+	qdrantKeepAliveSeconds = 10
+	qdrantKeepAliveTimeout = 2
+)
+
+func newQdrantClient(cfg config.Config) (*qdrant.Client, error) {
+	host, port, err := cfg.QdrantHostPort()
+	if err != nil {
+		return nil, err
+	}
+	return qdrant.NewClient(&qdrant.Config{
+		Host:             host,
+		Port:             port,
+		PoolSize:         qdrantPoolSize,
+		KeepAliveTime:    qdrantKeepAliveSeconds,
+		KeepAliveTimeout: qdrantKeepAliveTimeout,
+		GrpcOptions:      qdrantFaultOptions(),
+	})
+}
+
 func main() {
 	ctx := context.Background()
 
-	// AJUSTE: Forçando IPv4 no host do QdrantClient
-	qdrantClient, err := qdrant.NewClient(&qdrant.Config{
-		Host: "127.0.0.1",
-		Port: 6334,
-	})
+	global, args, err := parseGlobalFlags(os.Args[1:])
+	if err != nil {
+		logging.Fatal(cliLog, "Opções inválidas", "err", err)
+	}
+	if err := logging.Setup(logging.Options{Level: global.logLevel, Format: global.logFormat}); err != nil {
+		logging.Fatal(cliLog, "Opções de log inválidas", "err", err)
+	}
+
+	cfg, err := config.Load(global.env)
 	if err != nil {
-		log.Fatalf("❌ Erro ao conectar no Qdrant: %v", err)
+		logging.Fatal(engineLog, "Erro na configuração", "err", err)
+	}
+	cfg.ExportVars()
+	if cfg.Env != "" {
+		engineLog.Info("Ambiente carregado", "env", cfg.Env, "qdrant", cfg.QdrantAddr)
+	}
+	sidecarURL = cfg.SidecarURL
+	defaultEmbeddingProvider = cfg.EmbeddingProvider
+	defaultGenerationProvider = cfg.GenerationProvider
+	if cfg.Generation != nil {
+		generationOptions = cfg.Generation
+	}
+	retries = retryPolicyFromEnv()
+	promptGuardrails, err = loadGuardrails(guardrailsPath())
+	if err != nil {
+		logging.Fatal(engineLog, "Erro nos guardrails", "err", err)
+	}
+	defaultScoreThreshold = cfg.ScoreThreshold
+	defaultCutoffs = scoreCutoffs{
+		Abstain:       cfg.AbstainThreshold,
+		Rerank:        cfg.RerankThreshold,
+		RerankAbstain: cfg.RerankAbstainThreshold,
 	}
 
-	engine := NewAlanaEngine(qdrantClient, "alana_knowledge_base")
+	qdrantClient, err := newQdrantClient(cfg)
+	if err != nil {
+		logging.Fatal(engineLog, "Erro ao conectar no Qdrant", "err", err)
+	}
 
-	fmt.Println("========================================")
-	fmt.Println("🤖 Alana System (Go Orchestrator)")
-	fmt.Println("========================================")
+	engine := NewAlanaEngine(qdrantClient, cfg.Collection)
+	engine.qdrantAddr = cfg.QdrantAddr
+	engine.byType = cfg.CollectionsByType
+	if err := engine.models.loadOverrides(modelsConfigPath); err != nil {
+		logging.Fatal(engineLog, "Erro no registro de modelos", "err", err)
+	}
+	if err := engine.collections.load(collectionsConfigPath); err != nil {
+		logging.Fatal(engineLog, "Erro no registro de collections", "err", err)
+	}
+	engine.texts, err = textstore.Open(ctx, os.Getenv("ALANA_TEXT_STORE"))
+	if err != nil {
+		logging.Fatal(engineLog, "Erro ao abrir o text store", "err", err)
+	}
+	engine.fallback = embeddingFallbackFromEnv(engine.collection)
+	engine.local, err = localFallbackFromEnv()
+	if err != nil {
+		logging.Fatal(engineLog, "Erro no índice local", "err", err)
+	}
+	engine.memory, err = openChatMemory(os.Getenv("ALANA_CHAT_MEMORY"), qdrantClient)
+	if err != nil {
+		logging.Fatal(engineLog, "Erro na memória de conversas", "err", err)
+	}
+	engine.answers, err = openAnswerCache(os.Getenv("ALANA_ANSWER_CACHE"), answerCacheTTLFromEnv())
+	if err != nil {
+		logging.Fatal(engineLog, "Erro no cache de respostas", "err", err)
+	}
+	engine.semantic, err = openSemanticCache(ctx, os.Getenv("ALANA_SEMANTIC_CACHE"))
+	if err != nil {
+		logging.Fatal(engineLog, "Erro no cache semântico", "err", err)
+	}
+	engine.usage = newUsageLog()
+	engine.readOnly = global.readOnly
+	engine.env = cfg
+	engine.yesProd = global.yesProd
+	if engine.readOnly {
+		engineLog.Info("Modo somente leitura: escritas na collection desabilitadas")
+	}
 
-	question := "Qual o impacto da inteligência artificial no mercado de trabalho?"
-	if len(os.Args) > 1 {
-		question = strings.Join(os.Args[1:], " ")
+	// Subcomandos (ex: `similar <chunk-id>`); qualquer outra coisa é pergunta
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			err := cmd(ctx, engine, args[1:])
+			flushTraces()
+			if err != nil {
+				logging.Fatal(cliLog, "Erro no comando", "command", args[0], "err", err)
+			}
+			return
+		}
 	}
 
-	fmt.Printf("❓ Pergunta: %s\n\n", question)
+	question := "Qual o impacto da inteligência artificial no mercado de trabalho?"
+	if len(args) > 0 {
+		question = strings.Join(args, " ")
+	}
 
-	fmt.Println("🧠 Passo 1: Gerando embedding...")
+	engineLog.Info("Passo 1: gerando embedding", "question", question)
 	start := time.Now()
-	vector, err := getEmbedding(ctx, question)
+	vector, target, err := engine.embedQuery(ctx, question)
 	if err != nil {
-		log.Fatalf("❌ Erro embedding: %v", err)
+		if answer, ok := engine.emptyCorpusAnswer(ctx); ok {
+			fmt.Println(answer.Text)
+			return
+		}
+		logging.Fatal(engineLog, "Erro no embedding", "err", err)
 	}
-	fmt.Printf("   OK (%v)\n\n", time.Since(start))
-
-	fmt.Println("🔍 Passo 2: Buscando no Qdrant...")
+	engineLog.Info("Passo 2: buscando no Qdrant", "embedding_elapsed", time.Since(start))
 	start = time.Now()
-	results, err := engine.Search(ctx, vector, 5)
+	results, err := target.Search(ctx, vector, global.searchOptions())
 	if err != nil {
-		log.Fatalf("❌ Erro busca: %v", err)
+		logging.Fatal(engineLog, "Erro na busca", "err", err)
+	}
+	engineLog.Info("Busca concluída", "elapsed", time.Since(start), "results", len(results))
+	if len(results) == 0 {
+		fmt.Println(target.noResultsAnswer(ctx).Text)
+		return
 	}
-	fmt.Printf("   OK (%v) | %d resultados\n\n", time.Since(start), len(results))
 
-	fmt.Println("📝 Passo 3: Montando contexto...")
-	contextText := engine.AssembleContext(results, 3000)
+	engineLog.Info("Passo 3: montando contexto")
+	contextText := engine.AssembleContext(results, engine.models.contextTokenLimit(""), "")
 
-	fmt.Println("🤖 Passo 4: Gerando resposta...")
+	engineLog.Info("Passo 4: gerando resposta")
+
+	// A resposta aparece enquanto é gerada
 	start = time.Now()
-	answer, err := getAnswer(ctx, question, contextText)
+	var text strings.Builder
+	err = getAnswerStream(ctx, question, contextText, generationOverride{}, "", func(token string) {
+		fmt.Print(token)
+		text.WriteString(token)
+	})
+	fmt.Println()
 	if err != nil {
-		log.Fatalf("❌ Erro geração: %v", err)
+		logging.Fatal(engineLog, "Erro na geração", "err", err)
 	}
-	fmt.Printf("   OK (%v)\n\n", time.Since(start))
-
-	fmt.Println("========================================")
-	fmt.Println("✅ Resposta da Alana:")
-	fmt.Println("========================================")
-	fmt.Println(answer)
-}
\ No newline at end of file
+	answer := Answer{Text: text.String(), Sources: results}
+	fmt.Printf("\n%s", render.Answer(answer.Footnotes(), render.Plain, nil))
+	engineLog.Info("Resposta gerada", "elapsed", time.Since(start))
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"cmp"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==============================
+// Cache semântico de respostas
+// ==============================
+
+const (
+	// defaultSemanticThreshold é a similaridade de cosseno mínima entre a
+	// pergunta e uma já respondida para reaproveitar a resposta
+	defaultSemanticThreshold = 0.95
+	// defaultSemanticCacheSize é quantas respostas o LRU guarda
+	defaultSemanticCacheSize = 1000
+	// defaultSemanticCacheTTL é a validade de uma resposta: uma re-ingestão
+	// não invalida o cache semântico (a chave não inclui os trechos), então
+	// as respostas expiram mais cedo que as do cache de respostas
+	defaultSemanticCacheTTL = 24 * time.Hour
+	// semanticRedisPrefix é o prefixo das chaves no Redis
+	semanticRedisPrefix = "alana:semcache:"
+)
+
+// semanticEntry é uma pergunta respondida: o vetor dela e a resposta, com as
+// fontes da busca que a gerou
+type semanticEntry struct {
+	Scope    string         `json:"scope"`
+	Question string         `json:"question"`
+	Vector   []float32      `json:"vector"`
+	Text     string         `json:"text"`
+	Sources  []SearchResult `json:"sources"`
+	Created  time.Time      `json:"created"`
+}
+
+// semanticCache reaproveita a resposta de uma pergunta parecida (não só
+// igual, como o answerCache): se o embedding da pergunta nova tem cosseno ≥
+// threshold com o de uma recente do mesmo escopo (ver semanticScope), a
+// resposta dela volta sem busca nem geração. As respostas ficam num LRU em
+// memória; com Redis, são gravadas lá também e recarregadas no restart (e
+// compartilhadas entre réplicas do serve, a cada restart).
+type semanticCache struct {
+	threshold float32
+	size      int
+	ttl       time.Duration
+	redis     *redisClient
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// openSemanticCache abre o cache de ALANA_SEMANTIC_CACHE: vazio (desligado),
+// "memory" ou uma URL redis://[:senha@]host:porta[/db]. Os demais knobs são
+// ALANA_SEMANTIC_CACHE_THRESHOLD (padrão 0.95), ALANA_SEMANTIC_CACHE_SIZE
+// (padrão 1000) e ALANA_SEMANTIC_CACHE_TTL (padrão 24h).
+func openSemanticCache(ctx context.Context, spec string) (*semanticCache, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	c := &semanticCache{
+		threshold: defaultSemanticThreshold,
+		size:      defaultSemanticCacheSize,
+		ttl:       defaultSemanticCacheTTL,
+		order:     list.New(),
+		entries:   map[string]*list.Element{},
+	}
+	if raw := os.Getenv("ALANA_SEMANTIC_CACHE_THRESHOLD"); raw != "" {
+		t, err := strconv.ParseFloat(raw, 32)
+		if err != nil || t <= 0 || t > 1 {
+			return nil, fmt.Errorf("semantic cache: ALANA_SEMANTIC_CACHE_THRESHOLD deve estar em (0, 1], veio %q", raw)
+		}
+		c.threshold = float32(t)
+	}
+	if raw := os.Getenv("ALANA_SEMANTIC_CACHE_SIZE"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("semantic cache: ALANA_SEMANTIC_CACHE_SIZE inválido %q", raw)
+		}
+		c.size = n
+	}
+	if raw := os.Getenv("ALANA_SEMANTIC_CACHE_TTL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("semantic cache: ALANA_SEMANTIC_CACHE_TTL inválido %q", raw)
+		}
+		c.ttl = d
+	}
+
+	switch {
+	case spec == "memory":
+	case strings.HasPrefix(spec, "redis://"):
+		client, err := newRedisClient(spec)
+		if err != nil {
+			return nil, fmt.Errorf("semantic cache: %w", err)
+		}
+		c.redis = client
+		// O Redis fora do ar não impede a subida: o cache começa vazio
+		if n, err := c.restore(ctx); err != nil {
+			engineLog.Warn("Cache semântico: Redis indisponível, começando vazio", "err", err)
+		} else {
+			engineLog.Info("Cache semântico: respostas recarregadas do Redis", "answers", n)
+		}
+	default:
+		return nil, fmt.Errorf("semantic cache: backend desconhecido %q (use memory ou redis://...)", spec)
+	}
+	return c, nil
+}
+
+// semanticScope identifica o que, além da pergunta, muda a resposta: a
+// collection, a busca (filtros, fixados, topK, re-ranking, reescrita), a
+// geração (prompt, guardrails, provedor e modelo) e os grupos do chamador,
+// para que uma resposta com fontes restritas não vaze para quem não as vê
+func semanticScope(collection string, opts askOptions) string {
+	filter, _ := json.Marshal(opts.Filter)
+	groups := "*"
+	if opts.Viewer != nil {
+		sorted := slices.Clone(opts.Viewer.groups)
+		slices.Sort(sorted)
+		groups = strings.Join(sorted, ",")
+	}
+	h := sha256.New()
+	for _, f := range []string{
+		collection,
+		string(filter),
+		strings.Join(opts.Pin, ","),
+		strconv.FormatUint(opts.TopK, 10),
+		strconv.FormatFloat(float64(opts.ScoreThreshold), 'g', -1, 32),
+		strconv.FormatBool(opts.Rerank),
+		strconv.FormatBool(opts.Hybrid),
+		opts.Rewrite,
+		opts.PromptTemplate,
+		promptGuardrails.fingerprint(),
+		cmp.Or(opts.Override.Provider, defaultGenerationProvider),
+		opts.Override.Model,
+		groups,
+	} {
+		h.Write([]byte(f))
+		h.Write([]byte{0x1f})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lookup devolve a resposta mais parecida com o vetor no escopo, se passar
+// do threshold, e a similaridade dela
+func (c *semanticCache) lookup(scope string, vector []float32) (*semanticEntry, float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var best *list.Element
+	var bestScore float32
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		entry := el.Value.(*semanticEntry)
+		switch {
+		case time.Since(entry.Created) > c.ttl:
+			c.remove(el)
+		case entry.Scope == scope:
+			if score := cosine(vector, entry.Vector); score >= c.threshold && score > bestScore {
+				best, bestScore = el, score
+			}
+		}
+		el = next
+	}
+	if best == nil {
+		return nil, 0
+	}
+	c.order.MoveToFront(best)
+	entry := *best.Value.(*semanticEntry)
+	entry.Sources = slices.Clone(entry.Sources)
+	return &entry, bestScore
+}
+
+// put guarda a resposta no LRU (e no Redis). A mesma pergunta no mesmo
+// escopo substitui a anterior (ver cacheRefresh).
+func (c *semanticCache) put(ctx context.Context, entry *semanticEntry) {
+	key := semanticEntryKey(entry.Scope, entry.Question)
+	c.insert(key, entry)
+	if c.redis == nil {
+		return
+	}
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = c.redis.set(ctx, semanticRedisPrefix+key, string(data), c.ttl)
+	}
+	if err != nil {
+		engineLog.WarnContext(ctx, "Erro ao gravar no cache semântico (Redis)", "err", err)
+	}
+}
+
+// semanticEntryKey é a chave da resposta no LRU e no Redis
+func semanticEntryKey(scope, question string) string {
+	sum := sha256.Sum256([]byte(scope + "\x1f" + normalizeQuestion(question)))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *semanticCache) insert(key string, entry *semanticEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *semanticCache) remove(el *list.Element) {
+	entry := el.Value.(*semanticEntry)
+	delete(c.entries, semanticEntryKey(entry.Scope, entry.Question))
+	c.order.Remove(el)
+}
+
+// restore carrega no LRU as respostas do Redis (as mais recentes, até size)
+func (c *semanticCache) restore(ctx context.Context) (int, error) {
+	var entries []*semanticEntry
+	err := c.redis.scan(ctx, semanticRedisPrefix+"*", func(key string) error {
+		data, err := c.redis.get(ctx, key)
+		if err == errRedisNil {
+			return nil // expirou entre o SCAN e o GET
+		}
+		if err != nil {
+			return err
+		}
+		var entry semanticEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			engineLog.Warn("Cache semântico: entrada ilegível", "key", key, "err", err)
+			return nil
+		}
+		entries = append(entries, &entry)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	// Da mais velha para a mais nova: as mais novas ficam na frente do LRU
+	slices.SortFunc(entries, func(a, b *semanticEntry) int { return a.Created.Compare(b.Created) })
+	for _, entry := range entries {
+		c.insert(semanticEntryKey(entry.Scope, entry.Question), entry)
+	}
+	return min(len(entries), c.size), nil
+}
+
+// semanticAnswerFor procura uma pergunta parecida já respondida. Devolve
+// também o vetor da pergunta, que a busca reaproveita no caso de não achar
+// (ver askOptions.embedded). O cache é opcional: se o embedding falhar, a
+// pergunta segue pelo caminho normal.
+func (e *AlanaEngine) semanticAnswerFor(ctx context.Context, question string, opts askOptions) (*semanticEntry, *embeddedQuery) {
+	ctx, sp := startSpan(ctx, "semantic_cache", spanInternal)
+	vector, target, err := e.embedQuery(ctx, question)
+	if err != nil {
+		sp.finish(err)
+		engineLog.WarnContext(ctx, "Cache semântico: embedding falhou, seguindo sem o cache", "err", err)
+		return nil, nil
+	}
+	embedded := &embeddedQuery{text: question, vector: vector, target: target}
+	if opts.Cache != cacheUse {
+		sp.finish(nil)
+		return nil, embedded
+	}
+	entry, score := e.semantic.lookup(semanticScope(target.collection, opts), vector)
+	sp.set("alana.hit", entry != nil)
+	sp.set("alana.similarity", float64(score))
+	sp.finish(nil)
+	return entry, embedded
+}
+
+// cacheSemantic guarda a resposta gerada. Ficam de fora as cortadas pelo
+// orçamento de tempo, as do índice local e as que tiveram trechos retidos
+// pela ACL (o aviso depende de quem perguntou).
+func (e *AlanaEngine) cacheSemantic(ctx context.Context, embedded *embeddedQuery, opts askOptions, a Answer) {
+	if a.Truncated || degradedResults(a.Sources) || opts.Viewer.restricted() {
+		return
+	}
+	sources := slices.Clone(a.Sources)
+	for i := range sources {
+		sources[i].Vector = nil
+	}
+	e.semantic.put(ctx, &semanticEntry{
+		Scope:    semanticScope(embedded.target.collection, opts),
+		Question: embedded.text,
+		Vector:   embedded.vector,
+		Text:     a.Text,
+		Sources:  sources,
+		Created:  time.Now().UTC(),
+	})
+}
+
+// embeddedQuery é o vetor de uma pergunta já calculado (pelo cache
+// semântico), para a busca não repetir o embedding
+type embeddedQuery struct {
+	text   string
+	vector []float32
+	target *AlanaEngine
+}
@@ -0,0 +1,768 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	iofs "io/fs"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"alana_system/manifest"
+	"alana_system/render"
+)
+
+// ==============================
+// Servidor HTTP
+// ==============================
+
+// server expõe o pipeline do AlanaEngine por HTTP
+type server struct {
+	engine      *AlanaEngine
+	policy      *overridePolicy
+	audit       *jsonlLog
+	shadow      *shadowRunner
+	transcriber transcriber
+	speaker     speaker
+	manifest    manifest.Store
+	cors        *corsPolicy
+	adminKeys   []string
+	recent      recentQueries
+	clarify     clarifySessions
+	chats       chatStore
+	// canaries é nil quando as canárias não rodam nesta instância
+	canaries *canaryMonitor
+	// sidecar é nil quando o sidecar não é iniciado pelo serve
+	sidecar *sidecarSupervisor
+	// access é nil sem config/acl.yaml: todos os trechos são visíveis
+	access *accessPolicy
+	// provenance é nil sem ALANA_PROVENANCE_KEY: as respostas vão sem trailer
+	provenance *provenanceSigner
+	// corpus é o tamanho da base de conhecimento mostrado no /readyz
+	corpus corpusGauge
+
+	draining atomic.Bool
+}
+
+type askRequest struct {
+	Question string `json:"question"`
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// BudgetMS é o orçamento de latência em milissegundos (0 = sem corte)
+	BudgetMS int `json:"budget_ms,omitempty"`
+	// Speak pede a resposta também em áudio (TTS), com a voz opcional
+	Speak bool   `json:"speak,omitempty"`
+	Voice string `json:"voice,omitempty"`
+	// Profile escolhe um perfil de config/collections.yaml; TopK,
+	// ScoreThreshold, Rerank e Hybrid sobrepõem o perfil e os padrões da
+	// collection
+	Profile        string   `json:"profile,omitempty"`
+	TopK           *uint64  `json:"top_k,omitempty"`
+	ScoreThreshold *float32 `json:"score_threshold,omitempty"`
+	Rerank         *bool    `json:"rerank,omitempty"`
+	Hybrid         *bool    `json:"hybrid,omitempty"`
+	// Rewrite reescreve a pergunta antes da busca: hyde, multi ou off
+	Rewrite *string `json:"rewrite,omitempty"`
+	// Filter restringe a busca pelos metadados (arquivo, tipo, tag, data)
+	Filter *filterRequest `json:"filter,omitempty"`
+	// Pin são arquivos cujos trechos entram sempre no contexto, antes dos
+	// outros (ex: "responda só com o contrato 123" = pin + filter.sources);
+	// Exclude são arquivos que nunca entram
+	Pin     []string `json:"pin,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// Format converte a resposta: markdown (padrão), html (sanitizado) ou plain
+	Format string `json:"format,omitempty"`
+	// Draft transmite primeiro um rascunho do modelo rápido (draft_model de
+	// config/models.yaml) e depois a correção, se houver; só no /ask/stream
+	Draft bool `json:"draft,omitempty"`
+	// Mode "report" escreve um relatório longo em seções (ver WriteReport);
+	// vazio ou "answer" responde a pergunta
+	Mode string `json:"mode,omitempty"`
+	// SessionID identifica a conversa: as perguntas com o mesmo ID recebem o
+	// histórico das anteriores (ver ChatSession) e a resposta a um
+	// esclarecimento (ver askResponse.Clarification). O ID é escolhido pelo
+	// cliente.
+	SessionID string `json:"session_id,omitempty"`
+	// UserID liga a memória de conversas do usuário (ALANA_CHAT_MEMORY):
+	// discussões de outras sessões entram no contexto e esta é guardada.
+	// É escolhido pelo cliente, mas a memória fica sob a chave de API do
+	// pedido (ver memoryOwner): sem chave, o pedido é recusado.
+	UserID string `json:"user_id,omitempty"`
+}
+
+// searchRequest é o corpo do POST /search: só a recuperação, sem geração
+type searchRequest struct {
+	Question       string         `json:"question"`
+	Profile        string         `json:"profile,omitempty"`
+	TopK           *uint64        `json:"top_k,omitempty"`
+	ScoreThreshold *float32       `json:"score_threshold,omitempty"`
+	Rerank         *bool          `json:"rerank,omitempty"`
+	Hybrid         *bool          `json:"hybrid,omitempty"`
+	Rewrite        *string        `json:"rewrite,omitempty"`
+	Filter         *filterRequest `json:"filter,omitempty"`
+	// WithVectors devolve o vetor denso de cada trecho
+	WithVectors bool `json:"with_vectors,omitempty"`
+}
+
+// searchResult é um trecho do /search, com os metadados usados nos filtros
+type searchResult struct {
+	askSource
+	Text        string    `json:"text"`
+	ContentType string    `json:"content_type,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	CreatedAt   string    `json:"created_at,omitempty"`
+	Vector      []float32 `json:"vector,omitempty"`
+}
+
+type searchResponse struct {
+	Results []searchResult `json:"results"`
+	// Degraded e Restricted: ver askResponse
+	Degraded   bool `json:"degraded,omitempty"`
+	Restricted bool `json:"restricted,omitempty"`
+}
+
+// Modos do /ask
+const (
+	askModeAnswer = "answer"
+	askModeReport = "report"
+)
+
+type askSource struct {
+	ID     string  `json:"id"`
+	Source string  `json:"source"`
+	Title  string  `json:"title,omitempty"`
+	Page   int     `json:"page"`
+	Score  float32 `json:"score"`
+}
+
+type askResponse struct {
+	// Answer traz as citações como [n] e as notas de rodapé ao fim (ver
+	// Answer.Footnoted)
+	Answer    string     `json:"answer"`
+	Sources   []Citation `json:"sources"`
+	Truncated bool       `json:"truncated,omitempty"`
+	// Clarification indica que Answer é uma pergunta de esclarecimento: a
+	// resposta do usuário deve voltar em /ask com o mesmo session_id
+	Clarification bool   `json:"clarification,omitempty"`
+	SessionID     string `json:"session_id,omitempty"`
+	// Abstained indica que nada na base passou do corte de abstenção
+	Abstained bool `json:"abstained,omitempty"`
+	// Cached indica que a resposta veio do cache de respostas
+	Cached bool `json:"cached,omitempty"`
+	// Degraded indica que o Qdrant estava fora do ar e os trechos vieram do
+	// índice local, só por palavra-chave
+	Degraded bool `json:"degraded,omitempty"`
+	// Restricted indica que há fontes relevantes que o chamador não pode ver
+	// (ver accessPolicy); com redaction notice, o aviso vem no fim do texto
+	Restricted bool `json:"restricted,omitempty"`
+	// EmptyCorpus indica que a base de conhecimento está vazia: nada foi
+	// ingerido na collection (ver emptyCorpusReply)
+	EmptyCorpus bool `json:"empty_corpus,omitempty"`
+	// Provenance é o trailer acrescentado a Answer (ver provenanceSigner)
+	Provenance *answerProvenance `json:"provenance,omitempty"`
+	*speechOutput
+}
+
+// runServe implementa `alana serve [-addr host:porta | unix:<caminho> | systemd]`.
+// Ver listen para socket Unix e ativação por socket do systemd.
+//
+// No SIGTERM/SIGINT o servidor drena as conexões para permitir restart sem
+// downtime: /readyz passa a responder 503 (o balanceador tira a instância),
+// após -drain-delay novas conexões deixam de ser aceitas, os pedidos em
+// andamento (inclusive streams) têm até -grace para terminar, as execuções em
+// shadow são aguardadas e só então os clientes do Qdrant e do sidecar fecham.
+//
+// Com -sidecar-cmd (ou ALANA_SIDECAR_CMD), o próprio serve inicia e
+// supervisiona o sidecar Python (ver sidecarSupervisor): /readyz só fica
+// pronto quando ele responde, e ele é encerrado depois da drenagem.
+//
+// Com ALANA_LOCAL_INDEX, o serve mantém um índice BM25 local dos trechos
+// (ver localFallback) e, com o Qdrant fora do ar, responde só por
+// palavra-chave, com "degraded": true.
+func runServe(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "endereço HTTP: host:porta, unix:<caminho> ou systemd (ativação por socket)")
+	socketMode := fs.Uint("socket-mode", 0o660, "permissões do socket Unix")
+	grace := fs.Duration("grace", 30*time.Second, "tempo máximo para os pedidos em andamento terminarem")
+	drainDelay := fs.Duration("drain-delay", 0, "tempo com /readyz em 503 antes de parar de aceitar conexões")
+	sidecarCmd := fs.String("sidecar-cmd", os.Getenv("ALANA_SIDECAR_CMD"), "comando que inicia o sidecar Python, supervisionado pelo serve (ex: \"python bridge.py\")")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var sidecar *sidecarSupervisor
+	if *sidecarCmd != "" {
+		var err error
+		if sidecar, err = sidecarSupervisorFromEnv(*sidecarCmd); err != nil {
+			return err
+		}
+	}
+
+	access, err := loadAccessPolicy(aclPath())
+	if err != nil {
+		return fmt.Errorf("controle de acesso: %w", err)
+	}
+	provenance, err := provenanceSignerFromEnv()
+	if err != nil {
+		return err
+	}
+	cors, err := corsPolicyFromEnv()
+	if err != nil {
+		return err
+	}
+
+	ln, err := listen(*addr, iofs.FileMode(*socketMode))
+	if err != nil {
+		return err
+	}
+
+	docs, err := manifest.Open(ctx, os.Getenv("ALANA_MANIFEST"))
+	if err != nil {
+		ln.Close()
+		return err
+	}
+
+	chats, err := openChatStore(ctx, os.Getenv("ALANA_CHAT_STORE"), engine.client)
+	if err != nil {
+		ln.Close()
+		docs.Close()
+		return err
+	}
+
+	s := &server{
+		engine:      engine,
+		policy:      overridePolicyFromEnv(),
+		audit:       newJSONLLog(auditLogPath),
+		shadow:      shadowRunnerFromEnv(engine),
+		transcriber: transcriberFromEnv(),
+		speaker:     speakerFromEnv(),
+		manifest:    docs,
+		cors:        cors,
+		adminKeys:   adminKeysFromEnv(),
+		chats:       chats,
+		sidecar:     sidecar,
+		access:      access,
+		provenance:  provenance,
+	}
+
+	httpServer := &http.Server{
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Réplicas somente leitura não apagam collections: o reaper fica com a
+	// instância que escreve
+	if interval := reapIntervalFromEnv(); interval > 0 && engine.writable() == nil {
+		go reapLoop(ctx, engine, docs, interval)
+	}
+	// Os alertas de frescor também: réplicas avisariam em dobro
+	if engine.writable() == nil {
+		go freshnessLoop(ctx, newFreshnessMonitor())
+	}
+	// E as canárias, pelo mesmo motivo
+	if interval := canaryIntervalFromEnv(); interval > 0 && engine.writable() == nil {
+		s.canaries = newCanaryMonitor(engine)
+		go canaryLoop(ctx, s.canaries, interval)
+	}
+	// O índice local é de cada instância: as réplicas também atendem com o
+	// Qdrant fora do ar
+	if engine.local != nil {
+		engine.local.load(engine.collection)
+		go engine.local.run(ctx, engine)
+	}
+
+	// O sidecar só para depois da drenagem: os pedidos em andamento ainda
+	// precisam dele
+	sidecarCtx, stopSidecar := context.WithCancel(context.Background())
+	sidecarDone := make(chan struct{})
+	if sidecar != nil {
+		go func() {
+			defer close(sidecarDone)
+			sidecar.run(sidecarCtx)
+		}()
+	} else {
+		close(sidecarDone)
+	}
+	defer func() {
+		stopSidecar()
+		<-sidecarDone
+	}()
+
+	errc := make(chan error, 1)
+	go func() {
+		serverLog.Info("Alana ouvindo", "url", listenURL(ln))
+		errc <- httpServer.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	return s.drain(httpServer, *drainDelay, *grace)
+}
+
+// drain encerra o servidor sem derrubar pedidos em andamento
+func (s *server) drain(httpServer *http.Server, drainDelay, grace time.Duration) error {
+	s.draining.Store(true)
+	serverLog.Info("Drenando conexões")
+	time.Sleep(drainDelay)
+
+	graceCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	err := httpServer.Shutdown(graceCtx)
+	if err != nil {
+		serverLog.Warn("Prazo de drenagem esgotado, fechando conexões restantes", "err", err)
+		httpServer.Close()
+	}
+
+	if err := s.shadow.wait(graceCtx); err != nil {
+		serverLog.Warn("Execuções em shadow abandonadas", "err", err)
+	}
+
+	// Os logs JSONL gravam de forma síncrona; com os handlers e o shadow
+	// encerrados, não há mais escrita pendente e os clientes podem fechar.
+	if closeErr := s.manifest.Close(); closeErr != nil {
+		serverLog.Warn("Erro ao fechar o manifesto", "err", closeErr)
+	}
+	if closeErr := s.chats.Close(); closeErr != nil {
+		serverLog.Warn("Erro ao fechar o store de conversas", "err", closeErr)
+	}
+	if s.engine.texts != nil {
+		if closeErr := s.engine.texts.Close(); closeErr != nil {
+			serverLog.Warn("Erro ao fechar o text store", "err", closeErr)
+		}
+	}
+	flushTraces()
+	providerHTTP.CloseIdleConnections()
+	http.DefaultClient.CloseIdleConnections()
+	if closeErr := s.engine.client.Close(); closeErr != nil {
+		serverLog.Warn("Erro ao fechar o cliente do Qdrant", "err", closeErr)
+	}
+
+	serverLog.Info("Servidor encerrado")
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	return err
+}
+
+// handleReady implementa GET /readyz: 503 durante a drenagem e enquanto o
+// sidecar supervisionado não responde
+func (s *server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		writeError(w, http.StatusServiceUnavailable, "draining")
+		return
+	}
+	if s.sidecar != nil && !s.sidecar.up.Load() {
+		writeError(w, http.StatusServiceUnavailable, "sidecar not ready")
+		return
+	}
+	resp := map[string]any{"status": "ready"}
+	// Base vazia não tira a instância do balanceador (ela responde com
+	// emptyCorpusReply), mas fica visível para o deploy e o monitoramento
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+	if corpus, ok := s.corpus.get(ctx, s.engine); ok {
+		resp["corpus"] = corpus
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// healthCheckTimeout limita cada verificação de dependência do /health
+const healthCheckTimeout = 3 * time.Second
+
+// dependencyHealth é o estado de uma dependência do serve
+type dependencyHealth struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// dependencyHealth roda as verificações do `alana doctor` que importam para
+// atender pedidos. ok é false se alguma falhou (avisos não contam).
+func (s *server) dependencyHealth(ctx context.Context) (checks []dependencyHealth, ok bool) {
+	ok = true
+	for _, c := range []doctorCheck{
+		{"Qdrant", checkCollection},
+		{"Sidecar", checkSidecar},
+		{"Disco", checkDisk},
+	} {
+		checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		res := c.Run(checkCtx, s.engine)
+		cancel()
+		checks = append(checks, dependencyHealth{Name: c.Name, Status: res.Status.String(), Detail: res.Detail})
+		ok = ok && res.Status != checkFail
+	}
+	return checks, ok
+}
+
+// handleHealth implementa GET /health: 200 com o estado das dependências,
+// ou 503 se o Qdrant ou o sidecar estiverem fora
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	checks, ok := s.dependencyHealth(r.Context())
+	status, code := "ok", http.StatusOK
+	if !ok {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]any{"status": status, "checks": checks})
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /readyz", s.handleReady)
+	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	mux.HandleFunc("POST /ask", s.handleAsk)
+	mux.HandleFunc("POST /ask/stream", s.handleAskStream)
+	mux.HandleFunc("POST /ask/export", s.handleAskExport)
+	mux.HandleFunc("POST /search", s.handleSearch)
+	mux.HandleFunc("POST /v1/query/audio", s.handleAudioQuery)
+	mux.HandleFunc("GET /v1/documents", s.handleDocuments)
+	mux.HandleFunc("GET /v1/chunks/{id}", s.handleChunk)
+	mux.HandleFunc("GET /v1/chunks/{id}/similar", s.handleSimilar)
+	mux.HandleFunc("GET /v1/memory/{user}", s.handleMemory)
+	mux.HandleFunc("POST /v1/provenance/verify", s.handleVerifyProvenance)
+	// Réplica somente leitura: nada de administração, depuração nem escrita
+	if !s.engine.readOnly {
+		mux.HandleFunc("PUT /v1/memory/{user}", s.handleMemory)
+		mux.HandleFunc("DELETE /v1/memory/{user}", s.handleMemory)
+		mux.HandleFunc("POST /debug/provider-log", s.handleProviderLog)
+		mux.HandleFunc("POST /v1/experiments/query", s.handleExperiment)
+		mux.Handle("/admin/", s.adminHandler())
+		mux.Handle("GET /admin", http.RedirectHandler("/admin/", http.StatusMovedPermanently))
+	}
+	return requestIDHandler(traceHandler(securityHeaders(s.cors.wrap(mux))))
+}
+
+func (s *server) handleAsk(w http.ResponseWriter, r *http.Request) {
+	call, ok := s.parseAsk(w, r)
+	if !ok {
+		return
+	}
+	if call.req.Draft {
+		writeRequestError(w, invalidField("draft", "invalid_value", "o rascunho só existe com streaming; use /ask/stream"))
+		return
+	}
+
+	start := time.Now()
+	answer, err := s.answer(r.Context(), call)
+	s.recent.add("/ask", call.question, call.opts, answer, time.Since(start), err)
+	if err != nil {
+		serverLog.ErrorContext(r.Context(), "Erro em /ask", "err", err)
+		writeError(w, http.StatusBadGateway, "falha ao gerar resposta")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.finishAsk(r, call, answer, time.Since(start)))
+}
+
+// answer responde ao pedido no modo escolhido (resposta ou relatório)
+func (s *server) answer(ctx context.Context, call askCall) (Answer, error) {
+	if call.req.Mode == askModeReport {
+		return s.engine.WriteReport(ctx, call.question, call.opts)
+	}
+	return s.engine.Ask(ctx, call.question, call.opts)
+}
+
+// askCall é um pedido de /ask validado e resolvido
+type askCall struct {
+	req      askRequest
+	opts     askOptions
+	format   render.Format
+	question string
+	// session é a conversa do pedido (nil sem session_id)
+	session *ChatSession
+}
+
+// parseAsk lê, valida e resolve as opções de um pedido de /ask. Em caso de
+// erro já responde ao cliente e devolve false.
+func (s *server) parseAsk(w http.ResponseWriter, r *http.Request) (askCall, bool) {
+	var req askRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return askCall{}, false
+	}
+	if err := req.validate(); err != nil {
+		writeRequestError(w, err)
+		return askCall{}, false
+	}
+	if req.Speak && s.speaker == nil {
+		writeError(w, http.StatusBadRequest, errSpeechDisabled.Error())
+		return askCall{}, false
+	}
+
+	format, _ := render.ParseFormat(req.Format)
+	settings := retrievalSettings{TopK: req.TopK, ScoreThreshold: req.ScoreThreshold, Rerank: req.Rerank, Hybrid: req.Hybrid, Rewrite: req.Rewrite}
+	opts, err := s.engine.collections.askOptions(s.engine.collection, req.Profile, settings)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return askCall{}, false
+	}
+	opts.Filter, _ = req.Filter.searchFilter() // já validado
+	opts.Viewer = s.access.viewer(requestAPIKey(r))
+	opts.Filter.Exclude = append(opts.Filter.Exclude, req.Exclude...)
+	opts.Pin = req.Pin
+	opts.Budget = time.Duration(req.BudgetMS) * time.Millisecond
+	if req.Provider != "" || req.Model != "" {
+		override, err := s.authorizeOverride(r, generationOverride{Provider: req.Provider, Model: req.Model})
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errOverrideForbidden) {
+				status = http.StatusForbidden
+			}
+			writeError(w, status, err.Error())
+			return askCall{}, false
+		}
+		opts.Override = override
+	}
+
+	opts.TokenLimit = s.engine.models.contextTokenLimit(opts.Override.Model)
+	if req.Draft {
+		draft := s.engine.models.draft
+		if draft == nil {
+			writeRequestError(w, invalidField("draft", "unavailable", "nenhum draft_model configurado"))
+			return askCall{}, false
+		}
+		opts.Draft = draft
+		// O mesmo contexto vai para os dois modelos
+		opts.TokenLimit = min(opts.TokenLimit, s.engine.models.contextTokenLimit(draft.Model))
+	}
+
+	// Resposta a um esclarecimento: junta com a pergunta original e responde
+	// (uma única rodada)
+	question := req.Question
+	if req.SessionID != "" {
+		if original, ok := s.clarify.take(sessionKey(requestAPIKey(r), req.SessionID)); ok {
+			question = clarifiedQuestion(original, req.Question)
+			opts.Clarify = false
+		}
+	}
+
+	// Conversa: os turnos anteriores entram no contexto (relatórios não usam)
+	var session *ChatSession
+	if req.SessionID != "" && req.Mode != askModeReport {
+		session, err = s.loadChat(r, req.SessionID)
+		if err != nil {
+			serverLog.ErrorContext(r.Context(), "Erro ao carregar a conversa", "err", err)
+			writeError(w, http.StatusBadGateway, "histórico da conversa indisponível")
+			return askCall{}, false
+		}
+		opts.History = session.History()
+	}
+	opts.SessionID = req.SessionID
+	if req.UserID != "" && s.engine.memory != nil {
+		if opts.UserID, err = memoryOwner(requestAPIKey(r), req.UserID); err != nil {
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return askCall{}, false
+		}
+	}
+
+	return askCall{req: req, opts: opts, format: format, question: question, session: session}, true
+}
+
+// loadChat carrega a conversa session_id da chave de API do pedido; uma
+// sessão nova volta vazia, já com o ID interno (ver sessionKey)
+func (s *server) loadChat(r *http.Request, sessionID string) (*ChatSession, error) {
+	key := sessionKey(requestAPIKey(r), sessionID)
+	session, err := s.chats.Load(r.Context(), key)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		session = &ChatSession{ID: key}
+	}
+	return session, nil
+}
+
+// sessionKey é o ID interno de uma conversa ou esclarecimento do serve: o
+// session_id vem do cliente, então vai junto com o SHA-256 da chave de API
+// (como em memoryOwner). Outra chave com o mesmo session_id cai numa
+// sessão vazia.
+func sessionKey(apiKey, sessionID string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return memoryOwnerOf(hex.EncodeToString(sum[:]), sessionID)
+}
+
+// finishAsk monta a resposta de uma pergunta atendida: shadow, sessão de
+// esclarecimento e áudio
+func (s *server) finishAsk(r *http.Request, call askCall, answer Answer, elapsed time.Duration) askResponse {
+	// Só perguntas no tráfego padrão servem de base para a candidata
+	if call.opts.Override == (generationOverride{}) && !answer.Clarification {
+		s.shadow.maybeRun(call.question, newShadowResult(s.engine, call.opts, answer, elapsed, nil))
+	}
+
+	resp := newAskResponse(answer, call.format)
+	s.stampAnswer(&resp, call.opts, answer)
+	if call.session != nil {
+		resp.SessionID = call.req.SessionID
+		call.session.Add(call.question, answer)
+		if err := s.chats.Save(r.Context(), call.session); err != nil {
+			serverLog.WarnContext(r.Context(), "Erro ao salvar a conversa", "err", err)
+		}
+	}
+	s.engine.rememberAnswer(r.Context(), call.opts.UserID, call.req.SessionID, call.question, answer)
+	if answer.Clarification {
+		resp.SessionID = cmp.Or(call.req.SessionID, newSessionID())
+		if !s.clarify.put(sessionKey(requestAPIKey(r), resp.SessionID), call.question) {
+			serverLog.WarnContext(r.Context(), "Sessões de esclarecimento esgotadas; a próxima resposta será tratada como pergunta nova")
+		}
+	}
+	if call.req.Speak {
+		resp.speechOutput = s.speak(r.Context(), answer.Text, call.req.Voice)
+	}
+	return resp
+}
+
+// handleSearch implementa POST /search: devolve os trechos que embasariam a
+// resposta (com o texto), sem chamar o LLM
+func (s *server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	var req searchRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	// top_k, score_threshold, with_vectors e filter (a linguagem de filtro)
+	// também podem vir na query string; os do corpo têm precedência
+	query := r.URL.Query()
+	params, err := retrievalParams(query)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	req.TopK = cmp.Or(req.TopK, params.TopK)
+	req.ScoreThreshold = cmp.Or(req.ScoreThreshold, params.ScoreThreshold)
+	if v := query.Get("with_vectors"); v != "" && !req.WithVectors {
+		if req.WithVectors, err = strconv.ParseBool(v); err != nil {
+			writeRequestError(w, invalidField("with_vectors", "invalid_type", "with_vectors deve ser true ou false"))
+			return
+		}
+	}
+	if v := query.Get("filter"); v != "" {
+		if req.Filter == nil {
+			req.Filter = &filterRequest{}
+		}
+		req.Filter.Query = cmp.Or(req.Filter.Query, v)
+	}
+	ask := askRequest{Question: req.Question, Profile: req.Profile, TopK: req.TopK, ScoreThreshold: req.ScoreThreshold, Rewrite: req.Rewrite, Filter: req.Filter}
+	if err := ask.validate(); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+
+	settings := retrievalSettings{TopK: req.TopK, ScoreThreshold: req.ScoreThreshold, Rerank: req.Rerank, Hybrid: req.Hybrid, Rewrite: req.Rewrite}
+	opts, err := s.engine.collections.askOptions(s.engine.collection, req.Profile, settings)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts.Filter, _ = req.Filter.searchFilter() // já validado
+	opts.WithVectors = req.WithVectors
+	opts.Viewer = s.access.viewer(requestAPIKey(r))
+
+	results, err := s.engine.retrieve(r.Context(), req.Question, opts)
+	if err != nil {
+		serverLog.ErrorContext(r.Context(), "Erro em /search", "err", err)
+		writeError(w, http.StatusBadGateway, "falha na busca")
+		return
+	}
+	if opts.Viewer.denies() {
+		results = nil
+	}
+
+	resp := searchResponse{
+		Results:    make([]searchResult, 0, len(results)),
+		Degraded:   degradedResults(results),
+		Restricted: opts.Viewer.restricted() && opts.Viewer.redaction != redactSilent,
+	}
+	for _, r := range results {
+		resp.Results = append(resp.Results, searchResult{
+			askSource:   askSource{ID: r.ID, Source: r.Source, Title: r.Title, Page: r.Page, Score: r.Score},
+			Text:        r.Text,
+			ContentType: r.ContentType,
+			Tags:        r.Tags,
+			CreatedAt:   r.CreatedAt,
+			Vector:      r.Vector,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// authorizeOverride valida o override e registra a decisão na auditoria
+func (s *server) authorizeOverride(r *http.Request, requested generationOverride) (generationOverride, error) {
+	apiKey := requestAPIKey(r)
+	override, err := s.policy.authorize(apiKey, requested)
+
+	entry := auditEntry{
+		Time:     time.Now().UTC(),
+		Action:   "generation_override",
+		KeyID:    keyFingerprint(apiKey),
+		Provider: override.Provider,
+		Model:    override.Model,
+		Allowed:  err == nil,
+	}
+	if err != nil {
+		entry.Reason = err.Error()
+	}
+	if auditErr := s.audit.append(entry); auditErr != nil {
+		// Sem auditoria, o override não é aplicado
+		serverLog.ErrorContext(r.Context(), "Erro ao gravar auditoria", "err", auditErr)
+		return override, errors.New("auditoria indisponível")
+	}
+
+	return override, err
+}
+
+// requestAPIKey lê a chave de "Authorization: Bearer <chave>" ou "X-API-Key"
+func requestAPIKey(r *http.Request) string {
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(key)
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// newAskResponse monta a resposta no formato pedido. No HTML, as citações
+// apontam para #source-N, a N-ésima fonte da lista (a partir de 1).
+func newAskResponse(a Answer, format render.Format) askResponse {
+	_, citations := a.Citations()
+	return askResponse{
+		Answer:        render.Answer(a.Footnoted(), format, citationLabels(a.Sources)),
+		Sources:       citations,
+		Truncated:     a.Truncated,
+		Clarification: a.Clarification,
+		Abstained:     a.Abstained,
+		Cached:        a.Cached,
+		Degraded:      a.Degraded,
+		Restricted:    a.Restricted,
+		EmptyCorpus:   a.EmptyCorpus,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		serverLog.Error("Erro ao escrever resposta", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
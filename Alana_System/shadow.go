@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ==============================
+// Shadow mode (configuração candidata)
+// ==============================
+
+const (
+	shadowLogPath     = "./data/shadow.log"
+	shadowTimeout     = 2 * time.Minute
+	shadowConcurrency = 2
+)
+
+// shadowRunner repete uma fração das perguntas com uma configuração
+// candidata, em segundo plano, e grava as duas respostas para comparação
+// offline. O resultado da candidata nunca chega ao usuário.
+type shadowRunner struct {
+	percent float64
+	engine  *AlanaEngine
+	opts    askOptions
+	log     *jsonlLog
+	slots   chan struct{}
+	running sync.WaitGroup
+}
+
+// shadowConfig descreve uma das configurações comparadas
+type shadowConfig struct {
+	Collection string `json:"collection"`
+	TopK       uint64 `json:"top_k"`
+	TokenLimit int    `json:"token_limit"`
+	Provider   string `json:"provider,omitempty"`
+	Model      string `json:"model,omitempty"`
+}
+
+type shadowResult struct {
+	Config    shadowConfig `json:"config"`
+	Answer    string       `json:"answer,omitempty"`
+	Sources   []string     `json:"sources,omitempty"`
+	LatencyMS int64        `json:"latency_ms"`
+	Error     string       `json:"error,omitempty"`
+}
+
+type shadowEntry struct {
+	Time      time.Time    `json:"time"`
+	Question  string       `json:"question"`
+	Primary   shadowResult `json:"primary"`
+	Candidate shadowResult `json:"candidate"`
+}
+
+// shadowRunnerFromEnv monta o runner a partir do ambiente. Devolve nil se o
+// shadow mode estiver desligado (ALANA_SHADOW_PERCENT vazio ou zero).
+//
+//	ALANA_SHADOW_PERCENT       porcentagem das perguntas repetidas (0-100)
+//	ALANA_SHADOW_COLLECTION    collection candidata (ex: outro chunking)
+//	ALANA_SHADOW_MODEL         modelo de geração candidato
+//	ALANA_SHADOW_TOP_K         topK candidato
+//	ALANA_SHADOW_TOKEN_LIMIT   limite de contexto candidato
+func shadowRunnerFromEnv(engine *AlanaEngine) *shadowRunner {
+	percent, _ := strconv.ParseFloat(os.Getenv("ALANA_SHADOW_PERCENT"), 64)
+	if percent <= 0 {
+		return nil
+	}
+
+	// Cópia sem o log de uso nem o cache de respostas: as repetições não
+	// contam no `alana analytics` e a latência medida é a da geração
+	shadow := *engine
+	shadow.usage = nil
+	shadow.answers = nil
+	candidate := &shadow
+	if c := os.Getenv("ALANA_SHADOW_COLLECTION"); c != "" {
+		candidate = NewAlanaEngine(engine.client, c)
+		candidate.models = engine.models
+		candidate.collections = engine.collections
+		candidate.texts = engine.texts
+	}
+
+	// A candidata parte dos padrões da própria collection (sem perfil, não há erro)
+	opts, _ := candidate.collections.askOptions(candidate.collection, "", retrievalSettings{})
+	opts.Override.Model = os.Getenv("ALANA_SHADOW_MODEL")
+	if v, err := strconv.ParseUint(os.Getenv("ALANA_SHADOW_TOP_K"), 10, 64); err == nil && v > 0 {
+		opts.TopK = v
+	}
+	opts.TokenLimit = candidate.models.contextTokenLimit(opts.Override.Model)
+	if v, err := strconv.Atoi(os.Getenv("ALANA_SHADOW_TOKEN_LIMIT")); err == nil && v > 0 {
+		opts.TokenLimit = v
+	}
+
+	return &shadowRunner{
+		percent: min(percent, 100),
+		engine:  candidate,
+		opts:    opts,
+		log:     newJSONLLog(shadowLogPath),
+		slots:   make(chan struct{}, shadowConcurrency),
+	}
+}
+
+// maybeRun sorteia a pergunta e, se escolhida, roda a candidata em segundo
+// plano. Se já houver execuções demais em andamento, a amostra é descartada
+// para não competir com o tráfego real.
+func (s *shadowRunner) maybeRun(question string, primary shadowResult) {
+	if s == nil || rand.Float64()*100 >= s.percent {
+		return
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		return
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		defer func() { <-s.slots }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+		defer cancel()
+
+		start := time.Now()
+		answer, err := s.engine.Ask(ctx, question, s.opts)
+		candidate := newShadowResult(s.engine, s.opts, answer, time.Since(start), err)
+
+		entry := shadowEntry{
+			Time:      time.Now().UTC(),
+			Question:  question,
+			Primary:   primary,
+			Candidate: candidate,
+		}
+		if err := s.log.append(entry); err != nil {
+			serverLog.Error("Erro ao gravar shadow log", "err", err)
+		}
+	}()
+}
+
+// wait aguarda as execuções em andamento até ctx expirar
+func (s *shadowRunner) wait(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newShadowResult(engine *AlanaEngine, opts askOptions, a Answer, latency time.Duration, err error) shadowResult {
+	r := shadowResult{
+		Config: shadowConfig{
+			Collection: engine.collection,
+			TopK:       opts.TopK,
+			TokenLimit: opts.TokenLimit,
+			Provider:   opts.Override.Provider,
+			Model:      opts.Override.Model,
+		},
+		Answer:    a.Text,
+		LatencyMS: latency.Milliseconds(),
+	}
+	for _, src := range a.Sources {
+		r.Sources = append(r.Sources, src.ID)
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
@@ -0,0 +1,85 @@
+// Package sidecarproto descreve o contrato HTTP entre o Go e o sidecar Python
+// (bridge.py) e a sua versão. O Go anuncia a versão mais nova que entende no
+// cabeçalho Header; o sidecar responde na maior versão que os dois entendem
+// (ver Negotiate) e a devolve no mesmo cabeçalho. Assim um sidecar novo pode
+// acrescentar campos sem quebrar orquestradores antigos, que continuam
+// recebendo o formato da versão deles.
+//
+// Histórico de versões:
+//
+//	1: JSON original (/embed, /embed/batch, /rerank, /generate,
+//	   /generate/stream, /chunk, /transcribe); sem cabeçalho = 1
+//	2: + model e usage (opcional) na resposta do /generate e na última
+//	   linha do /generate/stream
+//
+// Campos novos são sempre opcionais: quem lê ignora os desconhecidos, e os
+// fixtures de testdata (lidos também pelo tests/test_protocol.py) garantem
+// que os dois lados continuam de acordo.
+package sidecarproto
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Version é a versão mais nova do contrato que este código entende
+	Version = 2
+	// MinVersion é a mais antiga que ainda é aceita
+	MinVersion = 1
+	// Header leva a versão no pedido (a oferecida) e na resposta (a usada)
+	Header = "X-Alana-Protocol"
+)
+
+// Negotiate devolve a versão usada quando o outro lado oferece offered: a
+// menor entre a oferecida e Version. Sem oferta (ou inválida), vale a 1, o
+// formato de quem ainda não conhecia o cabeçalho.
+func Negotiate(offered string) int {
+	v, err := strconv.Atoi(strings.TrimSpace(offered))
+	if err != nil || v < MinVersion {
+		return MinVersion
+	}
+	return min(v, Version)
+}
+
+// Offer anuncia no pedido a versão mais nova que este código entende
+func Offer(h http.Header) {
+	h.Set(Header, strconv.Itoa(Version))
+}
+
+// Used é a versão com que o sidecar respondeu (1 se ele não conhece o
+// cabeçalho)
+func Used(h http.Header) int {
+	return Negotiate(h.Get(Header))
+}
+
+// Usage é a contagem de tokens de uma geração (v2)
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// GenerateResponse é a resposta do /generate
+type GenerateResponse struct {
+	Answer string `json:"answer"`
+	// Model é o modelo que gerou a resposta (v2)
+	Model string `json:"model,omitempty"`
+	// Usage só vem quando o motor informa os tokens (v2)
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// StreamChunk é uma linha NDJSON do /generate/stream: um pedaço em Token ou,
+// na última, Done (com Model e Usage a partir da v2)
+type StreamChunk struct {
+	Token string `json:"token,omitempty"`
+	Done  bool   `json:"done,omitempty"`
+	Model string `json:"model,omitempty"`
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// Health é o que o /health informa sobre o contrato (ausente = versão 1)
+type Health struct {
+	Version    int `json:"version"`
+	MinVersion int `json:"min_version"`
+}
@@ -0,0 +1,101 @@
+package sidecarproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Os fixtures de testdata são o contrato: tests/test_protocol.py confere
+// que o bridge.py gera exatamente estes JSONs em cada versão
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestNegotiate(t *testing.T) {
+	var cases []struct {
+		Offered string `json:"offered"`
+		Version int    `json:"version"`
+	}
+	if err := json.Unmarshal(readFixture(t, "negotiate.json"), &cases); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range cases {
+		if got := Negotiate(tc.Offered); got != tc.Version {
+			t.Errorf("Negotiate(%q) = %d, esperado %d", tc.Offered, got, tc.Version)
+		}
+	}
+}
+
+func TestOfferUsed(t *testing.T) {
+	h := http.Header{}
+	if got := Used(h); got != 1 {
+		t.Errorf("sem cabeçalho: versão %d, esperado 1", got)
+	}
+	Offer(h)
+	if got := Used(h); got != Version {
+		t.Errorf("depois de Offer: versão %d, esperado %d", got, Version)
+	}
+}
+
+// Todas as versões, inclusive uma futura com campos desconhecidos, precisam
+// ser lidas por este código
+func TestGenerateResponseCompat(t *testing.T) {
+	want := "O prazo de garantia é de 12 meses [1]."
+	cases := []struct {
+		file      string
+		model     string
+		withUsage bool
+	}{
+		{"generate_v1.json", "", false},
+		{"generate_v2.json", "llama-3-8b.Q4_K_M.gguf", true},
+		{"generate_future.json", "llama-3-8b.Q4_K_M.gguf", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.file, func(t *testing.T) {
+			var out GenerateResponse
+			if err := json.Unmarshal(readFixture(t, tc.file), &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.Answer != want || out.Model != tc.model || (out.Usage != nil) != tc.withUsage {
+				t.Errorf("resposta lida como %+v", out)
+			}
+			if tc.withUsage && (out.Usage.PromptTokens != 412 || out.Usage.CompletionTokens != 14) {
+				t.Errorf("usage lido como %+v", *out.Usage)
+			}
+		})
+	}
+}
+
+func TestStreamCompat(t *testing.T) {
+	for _, tc := range []struct{ file, model string }{
+		{"stream_v1.ndjson", ""},
+		{"stream_v2.ndjson", "llama-3-8b.Q4_K_M.gguf"},
+	} {
+		t.Run(tc.file, func(t *testing.T) {
+			var text string
+			var last StreamChunk
+			sc := bufio.NewScanner(bytes.NewReader(readFixture(t, tc.file)))
+			for sc.Scan() {
+				last = StreamChunk{}
+				if err := json.Unmarshal(sc.Bytes(), &last); err != nil {
+					t.Fatal(err)
+				}
+				text += last.Token
+			}
+			if !last.Done || text != "O prazo é de 12 meses." || last.Model != tc.model {
+				t.Errorf("stream lido como %q, última linha %+v", text, last)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"alana_system/chunkid"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// "More like this" (drill-down a partir de um chunk)
+// ==============================
+
+// SimilarChunks devolve os chunks semanticamente mais próximos de um chunk já
+// indexado. Com excludeSameDoc, ignora os chunks do mesmo arquivo de origem.
+//
+// O chunk de partida é procurado em todas as collections da busca (ver
+// searchCollections). Na collection dele, o próprio vetor é o exemplo; nas
+// outras (outro modelo de embedding) o texto dele é embutido com o modelo de
+// cada uma, e as listas são combinadas por RRF, como na busca. A ACL do
+// chamador (nil vê tudo) vai no filtro do Qdrant, então quem tem acesso
+// restrito ainda recebe até topK trechos; um chunk de partida que ele não
+// pode ver é errChunkNotFound.
+func (e *AlanaEngine) SimilarChunks(
+	ctx context.Context,
+	chunkID string,
+	topK uint64,
+	excludeSameDoc bool,
+	v *viewer,
+) ([]SearchResult, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	id := qdrant.NewID(chunkid.PointID(chunkID))
+	collections := e.searchCollections(SearchFilter{})
+	home, start, err := e.findChunk(ctx, collections, id)
+	if err != nil {
+		return nil, err
+	}
+	if home == "" || !v.allows(start.ACL) {
+		return nil, fmt.Errorf("%w: %s", errChunkNotFound, chunkID)
+	}
+
+	mustNot := []*qdrant.Condition{qdrant.NewHasID(id)}
+	if excludeSameDoc && start.Source != "" {
+		mustNot = append(mustNot, qdrant.NewMatchKeyword("file_name", start.Source))
+	}
+	filter := visibleFilter(mustNot...)
+	if cond := v.condition(); cond != nil {
+		filter.Must = append(filter.Must, cond)
+	}
+
+	if len(collections) > 1 {
+		// O texto embutido nas outras collections
+		loaded := []SearchResult{start}
+		if err := e.loadTexts(ctx, loaded); err != nil {
+			return nil, err
+		}
+		start = loaded[0]
+	}
+
+	var lists [][]SearchResult
+	for _, c := range collections {
+		query := &qdrant.QueryPoints{
+			CollectionName: c,
+			Filter:         filter,
+			Limit:          &topK,
+			WithPayload:    qdrant.NewWithPayload(true),
+		}
+		if c == home {
+			// Recommend usa o próprio ponto como exemplo positivo
+			query.Query = qdrant.NewQueryRecommend(&qdrant.RecommendInput{
+				Positive: []*qdrant.VectorInput{qdrant.NewVectorInputID(id)},
+			})
+		} else {
+			vector, target, err := e.withCollection(c).embedQuery(ctx, start.Text)
+			if err != nil {
+				engineLog.WarnContext(ctx, "Similares numa das collections falharam; seguindo com as outras", "collection", c, "err", err)
+				continue
+			}
+			query.CollectionName = target.collection
+			query.Query = qdrant.NewQueryDense(vector)
+		}
+
+		resp, err := e.client.Query(ctx, query)
+		if err != nil {
+			if c == home || ctx.Err() != nil {
+				return nil, fmt.Errorf("qdrant recommend failed: %w", err)
+			}
+			engineLog.WarnContext(ctx, "Similares numa das collections falharam; seguindo com as outras", "collection", c, "err", err)
+			continue
+		}
+		list := make([]SearchResult, 0, len(resp))
+		for _, point := range resp {
+			list = append(list, resultFromPayload(point.GetId(), point.GetPayload(), point.GetScore()))
+		}
+		lists = append(lists, list)
+	}
+
+	results := lists[0]
+	if len(lists) > 1 {
+		results = fuseResults(lists, topK)
+	}
+	if err := e.loadTexts(ctx, results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// findChunk procura o ponto nas collections e devolve a primeira que o tem
+// (vazio se nenhuma). Uma collection que falha (ex: ainda não criada) fica de
+// fora; só é erro se todas falharem.
+func (e *AlanaEngine) findChunk(ctx context.Context, collections []string, id *qdrant.PointId) (string, SearchResult, error) {
+	var errs []error
+	for _, c := range collections {
+		points, err := e.client.Get(ctx, &qdrant.GetPoints{
+			CollectionName: c,
+			Ids:            []*qdrant.PointId{id},
+			WithPayload:    qdrant.NewWithPayload(true),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", SearchResult{}, err
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", c, err))
+			continue
+		}
+		if len(points) > 0 {
+			return c, resultFromPayload(points[0].GetId(), points[0].GetPayload(), 0), nil
+		}
+	}
+	if len(errs) == len(collections) {
+		return "", SearchResult{}, fmt.Errorf("qdrant get failed: %w", errors.Join(errs...))
+	}
+	return "", SearchResult{}, nil
+}
+
+// runSimilar implementa `alana similar [-k N] [-exclude-doc] <chunk-id>`
+func runSimilar(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("similar", flag.ContinueOnError)
+	topK := fs.Uint64("k", 5, "quantidade de chunks similares")
+	excludeDoc := fs.Bool("exclude-doc", false, "ignora chunks do mesmo documento")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("uso: similar [-k N] [-exclude-doc] <chunk-id>")
+	}
+
+	results, err := engine.SimilarChunks(ctx, fs.Arg(0), *topK, *excludeDoc, nil)
+	if err != nil {
+		return err
+	}
+
+	cliLog.Info("Chunks similares", "chunk", fs.Arg(0), "n", len(results))
+	for _, r := range results {
+		fmt.Printf("--- [%s | %s/Pág %d | Score %.2f] ---\n%s\n\n", r.ID, r.Source, r.Page, r.Score, r.Text)
+	}
+
+	return nil
+}
+
+// defaultSimilarTopK é o top_k do /v1/chunks/{id}/similar sem ?top_k=
+const defaultSimilarTopK = 5
+
+// handleSimilar implementa GET /v1/chunks/{id}/similar[?top_k=N][&exclude_doc=true]:
+// o "mais como este" a partir de um trecho citado, com a mesma resposta do
+// /search. O trecho de partida segue a ACL como no /v1/chunks/{id}, e os
+// similares já vêm filtrados pela ACL do chamador (ver SimilarChunks).
+func (s *server) handleSimilar(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := validateText("id", id, true, maxNameRunes); err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	query := r.URL.Query()
+	params, err := retrievalParams(query)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	topK := uint64(defaultSimilarTopK)
+	if params.TopK != nil {
+		topK = *params.TopK
+	}
+	excludeDoc := false
+	if v := query.Get("exclude_doc"); v != "" {
+		if excludeDoc, err = strconv.ParseBool(v); err != nil {
+			writeRequestError(w, invalidField("exclude_doc", "invalid_type", "exclude_doc deve ser true ou false"))
+			return
+		}
+	}
+
+	viewer := s.access.viewer(requestAPIKey(r))
+	results, err := s.engine.SimilarChunks(r.Context(), id, topK, excludeDoc, viewer)
+	if errors.Is(err, errChunkNotFound) {
+		writeError(w, http.StatusNotFound, "chunk não encontrado")
+		return
+	}
+	if err != nil {
+		serverLog.ErrorContext(r.Context(), "Erro em /v1/chunks/{id}/similar", "chunk", id, "err", err)
+		writeError(w, http.StatusBadGateway, "falha na busca")
+		return
+	}
+	resp := searchResponse{Results: make([]searchResult, 0, len(results))}
+	for _, r := range results {
+		resp.Results = append(resp.Results, searchResult{
+			askSource:   askSource{ID: r.ID, Source: r.Source, Title: r.Title, Page: r.Page, Score: r.Score},
+			Text:        r.Text,
+			ContentType: r.ContentType,
+			Tags:        r.Tags,
+			CreatedAt:   r.CreatedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ==============================
+// Conversa fiada (sem RAG)
+// ==============================
+
+// smallTalkMaxWords limita o tamanho de uma mensagem que pode ser conversa
+// fiada; qualquer coisa maior vai para a busca
+const smallTalkMaxWords = 8
+
+// smallTalkKind é a classe de uma mensagem que dispensa a busca
+type smallTalkKind int
+
+const (
+	smallTalkNone smallTalkKind = iota
+	smallTalkGreeting
+	smallTalkThanks
+	smallTalkGoodbye
+	smallTalkMeta
+)
+
+// smallTalkPhrases são as mensagens reconhecidas, já normalizadas (minúsculas,
+// sem acento nem pontuação). A mensagem inteira precisa ser uma delas,
+// opcionalmente seguida de "alana": "oi", "bom dia alana", "valeu".
+var smallTalkPhrases = map[string]smallTalkKind{
+	"oi": smallTalkGreeting, "ola": smallTalkGreeting, "opa": smallTalkGreeting,
+	"e ai": smallTalkGreeting, "eai": smallTalkGreeting, "hey": smallTalkGreeting,
+	"hi": smallTalkGreeting, "hello": smallTalkGreeting,
+	"bom dia": smallTalkGreeting, "boa tarde": smallTalkGreeting, "boa noite": smallTalkGreeting,
+	"oi tudo bem": smallTalkGreeting, "ola tudo bem": smallTalkGreeting, "tudo bem": smallTalkGreeting,
+	"como vai": smallTalkGreeting, "tudo bom": smallTalkGreeting,
+
+	"obrigado": smallTalkThanks, "obrigada": smallTalkThanks, "muito obrigado": smallTalkThanks,
+	"muito obrigada": smallTalkThanks, "valeu": smallTalkThanks, "vlw": smallTalkThanks,
+	"brigado": smallTalkThanks, "thanks": smallTalkThanks, "thank you": smallTalkThanks,
+	"ok obrigado": smallTalkThanks, "ok obrigada": smallTalkThanks, "show": smallTalkThanks,
+
+	"tchau": smallTalkGoodbye, "ate mais": smallTalkGoodbye, "ate logo": smallTalkGoodbye,
+	"falou": smallTalkGoodbye, "bye": smallTalkGoodbye, "adeus": smallTalkGoodbye,
+
+	"quem e voce": smallTalkMeta, "quem e vc": smallTalkMeta, "o que voce faz": smallTalkMeta,
+	"o que voce pode fazer": smallTalkMeta, "o que voce sabe fazer": smallTalkMeta,
+	"o que vc faz": smallTalkMeta, "como voce funciona": smallTalkMeta, "ajuda": smallTalkMeta,
+	"help": smallTalkMeta, "como posso usar": smallTalkMeta, "como te uso": smallTalkMeta,
+	"what can you do": smallTalkMeta, "who are you": smallTalkMeta,
+}
+
+// smallTalkReplies são as respostas de cada classe
+var smallTalkReplies = map[smallTalkKind]string{
+	smallTalkGreeting: "Olá! Sou a Alana. Pergunte o que quiser sobre os documentos da base.",
+	smallTalkThanks:   "Por nada! Se tiver outra dúvida, é só perguntar.",
+	smallTalkGoodbye:  "Até mais!",
+	smallTalkMeta: "Sou a Alana, uma assistente que responde com base nos documentos indexados " +
+		"(PDFs, notas, planilhas e páginas). Faça uma pergunta sobre o conteúdo e eu " +
+		"respondo citando os trechos usados como fonte.",
+}
+
+// classifySmallTalk reconhece saudações, agradecimentos, despedidas e
+// perguntas sobre a própria Alana. Na dúvida devolve smallTalkNone: uma
+// pergunta real respondida por template é pior que uma busca a mais.
+func classifySmallTalk(question string) smallTalkKind {
+	words := strings.Fields(normalizeSmallTalk(question))
+	if len(words) == 0 || len(words) > smallTalkMaxWords {
+		return smallTalkNone
+	}
+	if len(words) > 1 && words[len(words)-1] == "alana" {
+		words = words[:len(words)-1]
+	}
+	return smallTalkPhrases[strings.Join(words, " ")]
+}
+
+// normalizeSmallTalk deixa só letras e dígitos minúsculos, sem acento
+func normalizeSmallTalk(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case strings.ContainsRune("áàâã", r):
+			b.WriteRune('a')
+		case strings.ContainsRune("éê", r):
+			b.WriteRune('e')
+		case r == 'í':
+			b.WriteRune('i')
+		case strings.ContainsRune("óôõ", r):
+			b.WriteRune('o')
+		case r == 'ú' || r == 'ü':
+			b.WriteRune('u')
+		case r == 'ç':
+			b.WriteRune('c')
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return b.String()
+}
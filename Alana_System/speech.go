@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ==============================
+// Respostas faladas (TTS)
+// ==============================
+
+var errSpeechDisabled = errors.New("tts não configurado no servidor")
+
+// speaker converte o texto da resposta em áudio
+type speaker interface {
+	Synthesize(ctx context.Context, text, voice string) (audio []byte, contentType string, err error)
+}
+
+// speakerFromEnv escolhe o motor de TTS. Devolve nil se nenhum estiver
+// configurado.
+//
+//	ALANA_PIPER, ALANA_PIPER_MODEL        binário e voz do Piper (local)
+//	ALANA_TTS_URL, ALANA_TTS_API_KEY,
+//	ALANA_TTS_MODEL                       provedor compatível com /v1/audio/speech
+func speakerFromEnv() speaker {
+	if bin, model := os.Getenv("ALANA_PIPER"), os.Getenv("ALANA_PIPER_MODEL"); bin != "" && model != "" {
+		return piperSpeaker{bin: bin, model: model}
+	}
+	if endpoint := os.Getenv("ALANA_TTS_URL"); endpoint != "" {
+		return httpSpeaker{
+			url:    strings.TrimRight(endpoint, "/") + "/v1/audio/speech",
+			apiKey: os.Getenv("ALANA_TTS_API_KEY"),
+			model:  os.Getenv("ALANA_TTS_MODEL"),
+		}
+	}
+	return nil
+}
+
+// piperSpeaker roda o Piper localmente; o texto vai pelo stdin e o WAV sai
+// pelo stdout. A voz é o modelo .onnx, então o parâmetro voice é ignorado.
+type piperSpeaker struct {
+	bin   string
+	model string
+}
+
+func (p piperSpeaker) Synthesize(ctx context.Context, text, _ string) ([]byte, string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.bin, "--model", p.model, "--output_file", "-")
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, "", fmt.Errorf("piper failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), "audio/wav", nil
+}
+
+// httpSpeaker chama um provedor com a API /v1/audio/speech
+type httpSpeaker struct {
+	url    string
+	apiKey string
+	model  string
+}
+
+type speechRequest struct {
+	Model          string `json:"model,omitempty"`
+	Input          string `json:"input"`
+	Voice          string `json:"voice"`
+	ResponseFormat string `json:"response_format"`
+}
+
+func (h httpSpeaker) Synthesize(ctx context.Context, text, voice string) ([]byte, string, error) {
+	if voice == "" {
+		voice = "alloy"
+	}
+	body, err := json.Marshal(speechRequest{Model: h.model, Input: text, Voice: voice, ResponseFormat: "mp3"})
+	if err != nil {
+		return nil, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+h.apiKey)
+	}
+
+	resp, err := providerHTTP.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		return nil, "", fmt.Errorf("tts error: %s", string(raw))
+	}
+
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "audio/mpeg"
+	}
+	return audio, contentType, nil
+}
+
+// speechOutput é o áudio da resposta, embutido no JSON em base64
+type speechOutput struct {
+	Audio       string `json:"audio,omitempty"`
+	ContentType string `json:"audio_content_type,omitempty"`
+	Error       string `json:"speech_error,omitempty"`
+}
+
+// speak sintetiza a resposta. Falhas no TTS não derrubam o pedido: o texto
+// é devolvido mesmo assim, com o erro em speech_error.
+func (s *server) speak(ctx context.Context, text, voice string) *speechOutput {
+	audio, contentType, err := s.speaker.Synthesize(ctx, text, voice)
+	if err != nil {
+		serverLog.ErrorContext(ctx, "Erro no TTS", "err", err)
+		return &speechOutput{Error: "falha ao sintetizar o áudio"}
+	}
+	return &speechOutput{
+		Audio:       base64.StdEncoding.EncodeToString(audio),
+		ContentType: contentType,
+	}
+}
@@ -0,0 +1,48 @@
+"""
+src/alana_system/api/protocol.py
+
+Versão do contrato HTTP entre o orquestrador Go e o sidecar (ver o pacote Go
+sidecarproto, com o histórico de versões). O Go anuncia no cabeçalho
+X-Alana-Protocol a versão mais nova que entende; o sidecar responde na
+maior versão que os dois entendem, sem os campos que o Go ainda não conhece.
+"""
+from typing import Optional
+
+PROTOCOL_HEADER = "X-Alana-Protocol"
+PROTOCOL_VERSION = 2
+MIN_PROTOCOL_VERSION = 1
+
+# Versão em que cada campo opcional das respostas apareceu
+FIELDS_SINCE = {
+    "model": 2,
+    "usage": 2,
+}
+
+
+def negotiate(offered: Optional[str]) -> int:
+    """
+    Versão usada quando o cliente oferece `offered`: a menor entre a oferecida
+    e PROTOCOL_VERSION. Sem oferta (ou inválida), vale a 1, o formato dos
+    orquestradores que ainda não conheciam o cabeçalho.
+    """
+    try:
+        version = int((offered or "").strip())
+    except ValueError:
+        return MIN_PROTOCOL_VERSION
+    if version < MIN_PROTOCOL_VERSION:
+        return MIN_PROTOCOL_VERSION
+    return min(version, PROTOCOL_VERSION)
+
+
+def shape(payload: dict, version: int) -> dict:
+    """Tira da resposta os campos posteriores à versão e os vazios (None)."""
+    return {
+        key: value
+        for key, value in payload.items()
+        if value is not None and FIELDS_SINCE.get(key, 1) <= version
+    }
+
+
+def health() -> dict:
+    """O que o /health informa sobre o contrato."""
+    return {"version": PROTOCOL_VERSION, "min_version": MIN_PROTOCOL_VERSION}
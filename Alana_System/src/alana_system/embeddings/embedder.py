@@ -12,7 +12,7 @@ Este módulo NÃO:
 """
 
 from dataclasses import dataclass
-from typing import List, Generator
+from typing import List, Generator, Optional
 import logging
 
 import numpy as np
@@ -24,6 +24,7 @@ except ImportError:  # torch é opcional
     torch = None
 
 from ..preprocessing.chunker import TextChunk
+from .embedding_cache import EmbeddingCache, cache_key
 
 logger = logging.getLogger(__name__)
 
@@ -65,9 +66,13 @@ class TextEmbedder:
         batch_size: int = 32,
         normalize: bool = True,
         device: str | None = None,
+        cache: Optional[EmbeddingCache] = None,
     ):
+        self.model_name = model_name
         self.batch_size = batch_size
         self.normalize = normalize
+        # Vetores de trechos já vistos (ver embedding_cache); None = sem cache
+        self.cache = cache
 
         # Auto-detecção de device
         if device is None:
@@ -104,13 +109,7 @@ class TextEmbedder:
         for batch in self._batch_generator(chunks, self.batch_size):
             batch_texts = [c.text for c in batch]
 
-            embeddings = self.model.encode(
-                batch_texts,
-                batch_size=self.batch_size,
-                convert_to_numpy=True,
-                normalize_embeddings=self.normalize,
-                show_progress_bar=False,
-            )
+            embeddings = self.encode_documents(batch_texts)
 
             for chunk, emb in zip(batch, embeddings):
                 embedded_chunks.append(
@@ -139,6 +138,49 @@ class TextEmbedder:
 
         return embedded_chunks
 
+    # --------------------------------------------------------
+
+    def encode_documents(self, texts: List[str]) -> np.ndarray:
+        """
+        Vetoriza trechos de documento (uma linha por texto). Com cache, só os
+        textos que o modelo ainda não vetorizou vão para o encode.
+        """
+        if self.cache is None or not texts:
+            return self._encode(texts)
+
+        keys = [cache_key(self.model_name, self.normalize, t) for t in texts]
+        cached = self.cache.get_many(keys)
+        missing = [i for i, k in enumerate(keys) if k not in cached]
+
+        fresh = self._encode([texts[i] for i in missing]) if missing else None
+        if fresh is not None:
+            self.cache.put_many({
+                keys[i]: np.asarray(fresh[j], dtype=np.float32).tobytes()
+                for j, i in enumerate(missing)
+            })
+
+        dim = fresh.shape[1] if fresh is not None else len(next(iter(cached.values()))) // 4
+        out = np.empty((len(texts), dim), dtype=np.float32)
+        for i, key in enumerate(keys):
+            if key in cached:
+                out[i] = np.frombuffer(cached[key], dtype=np.float32)
+        if fresh is not None:
+            out[missing] = fresh
+
+        logger.debug(
+            f"Cache de embeddings | reaproveitados={len(texts) - len(missing)}/{len(texts)}"
+        )
+        return out
+
+    def _encode(self, texts: List[str]) -> np.ndarray:
+        return self.model.encode(
+            texts,
+            batch_size=self.batch_size,
+            convert_to_numpy=True,
+            normalize_embeddings=self.normalize,
+            show_progress_bar=False,
+        )
+
     # =========================================================
     # Helpers
     # =========================================================
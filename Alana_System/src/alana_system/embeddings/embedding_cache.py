@@ -0,0 +1,103 @@
+"""
+embedding_cache.py
+
+Cache de embeddings da ingestão, indexado pelo hash do conteúdo
+
+Uma re-ingestão (mudança de chunking, de extrator, de versão do pipeline)
+vetoriza de novo todos os trechos, mesmo os que saem com o mesmo texto. Com o
+cache, o vetor de um texto já visto pelo mesmo modelo vem do disco e só os
+trechos novos vão para a GPU. A chave é o SHA-256 de modelo + normalização +
+texto, então trocar o modelo de embedding invalida o cache sozinho.
+
+O backend é um SQLite local (ALANA_EMBED_CACHE=<arquivo>), compartilhado entre
+o processor.py e o sidecar (/embed/batch, usado pelas notas do orchestrator).
+As consultas (embed_query) não passam pelo cache.
+"""
+
+from __future__ import annotations
+
+import hashlib
+import logging
+import os
+import sqlite3
+import threading
+from pathlib import Path
+from typing import Dict, Iterable, Optional
+
+logger = logging.getLogger(__name__)
+
+# Quantas chaves vão em cada SELECT ... IN (o SQLite limita os parâmetros)
+_LOOKUP_BATCH = 500
+
+
+def cache_key(model_name: str, normalize: bool, text: str) -> str:
+    """Chave do vetor de um texto: muda com o modelo e com a normalização."""
+    h = hashlib.sha256()
+    for field in (model_name, "1" if normalize else "0", text):
+        h.update(field.encode("utf-8"))
+        h.update(b"\x1f")
+    return h.hexdigest()
+
+
+class EmbeddingCache:
+    """
+    Vetores float32 (em bytes) por chave, num SQLite. A conexão é usada por
+    várias threads do sidecar, então as operações são serializadas.
+    """
+
+    def __init__(self, path: str):
+        Path(path).parent.mkdir(parents=True, exist_ok=True)
+        self.path = path
+        self._lock = threading.Lock()
+        self.conn = sqlite3.connect(path, check_same_thread=False)
+        self.conn.execute("PRAGMA journal_mode=WAL")
+        self.conn.execute(
+            "CREATE TABLE IF NOT EXISTS embeddings ("
+            " key    TEXT PRIMARY KEY,"
+            " vector BLOB NOT NULL)"
+        )
+        self.conn.commit()
+
+    def get_many(self, keys: Iterable[str]) -> Dict[str, bytes]:
+        keys = list(dict.fromkeys(keys))
+        out: Dict[str, bytes] = {}
+        with self._lock:
+            for i in range(0, len(keys), _LOOKUP_BATCH):
+                batch = keys[i : i + _LOOKUP_BATCH]
+                marks = ",".join("?" * len(batch))
+                rows = self.conn.execute(
+                    f"SELECT key, vector FROM embeddings WHERE key IN ({marks})", batch
+                )
+                out.update((key, bytes(vector)) for key, vector in rows)
+        return out
+
+    def put_many(self, vectors: Dict[str, bytes]) -> None:
+        if not vectors:
+            return
+        with self._lock:
+            self.conn.executemany(
+                "INSERT OR REPLACE INTO embeddings (key, vector) VALUES (?, ?)",
+                vectors.items(),
+            )
+            self.conn.commit()
+
+    def close(self) -> None:
+        with self._lock:
+            self.conn.close()
+
+
+def open_embedding_cache(path: Optional[str] = None) -> Optional[EmbeddingCache]:
+    """
+    Abre o cache de ALANA_EMBED_CACHE (vazio = desligado). O cache é opcional:
+    se não abrir, a ingestão segue vetorizando tudo.
+    """
+    path = path if path is not None else os.environ.get("ALANA_EMBED_CACHE", "")
+    if not path:
+        return None
+    try:
+        cache = EmbeddingCache(path)
+    except sqlite3.Error as e:
+        logger.warning(f"Cache de embeddings indisponível ({path}): {e}")
+        return None
+    logger.info(f"Cache de embeddings ligado | arquivo={path}")
+    return cache
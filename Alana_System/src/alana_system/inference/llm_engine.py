@@ -1,10 +1,38 @@
 import logging
+import re
 import threading
-from typing import Optional
+from typing import Iterator, Optional
 from llama_cpp import Llama
 
 logger = logging.getLogger(__name__)
 
+# Template padrão do prompt de resposta. Templates por collection (definidos
+# no orquestrador Go) usam os mesmos marcadores {context} e {question}.
+DEFAULT_PROMPT_TEMPLATE = "Contexto: {context}\n\nPergunta: {question}\nResposta:"
+
+# Limite padrão da resposta (o mesmo do defaultMaxAnswerTokens do Go)
+DEFAULT_MAX_TOKENS = 1024
+
+
+def build_prompt(query: str, context_text: str, template: Optional[str] = None) -> str:
+    """
+    Monta o prompt substituindo os marcadores numa única passada, em vez de
+    str.format, para que chaves no template, no contexto ou na pergunta não
+    quebrem nem sejam substituídas.
+    """
+    template = template or DEFAULT_PROMPT_TEMPLATE
+    if "{context}" not in template:
+        template += "\n\nContexto: {context}"
+    if "{question}" not in template:
+        template += "\n\nPergunta: {question}"
+    values = {"context": context_text or "", "question": query or ""}
+    return re.sub(r"\{(context|question)\}", lambda m: values[m.group(1)], template)
+
+
+def sampling(temperature: Optional[float]) -> dict:
+    """Só repassa a temperatura quando configurada; senão vale a do llama.cpp."""
+    return {} if temperature is None else {"temperature": temperature}
+
 
 class LLMEngine:
     """
@@ -52,7 +80,15 @@ class LLMEngine:
         )
         self._lock = threading.Lock()
 
-    def generate_answer(self, query: str = None, context_text: str = None, messages: list = None) -> str:
+    def generate_answer(
+        self,
+        query: str = None,
+        context_text: str = None,
+        messages: list = None,
+        prompt_template: Optional[str] = None,
+        temperature: Optional[float] = None,
+        max_tokens: Optional[int] = None,
+    ) -> str:
         try:
             with self._lock:
                 # Se recebermos uma lista de mensagens (usado pelo EntityExtractor)
@@ -64,10 +100,11 @@ class LLMEngine:
                     )
                 else:
                     # Fallback para o modo de busca comum
-                    prompt = f"Contexto: {context_text}\n\nPergunta: {query}\nResposta:"
+                    prompt = build_prompt(query, context_text, prompt_template)
                     output = self.llm.create_chat_completion(
                         messages=[{"role": "user", "content": prompt}],
-                        max_tokens=1024
+                        max_tokens=max_tokens or DEFAULT_MAX_TOKENS,
+                        **sampling(temperature),
                     )
 
             return output["choices"][0]["message"]["content"].strip()
@@ -83,3 +120,29 @@ class LLMEngine:
         except Exception as e:
             logger.error(f"❌ Erro inesperado no LLM Engine: {e}")
             return ""
+
+    def generate_stream(
+        self,
+        query: str,
+        context_text: str,
+        prompt_template: Optional[str] = None,
+        temperature: Optional[float] = None,
+        max_tokens: Optional[int] = None,
+    ) -> Iterator[str]:
+        """
+        Gera a resposta em pedaços, conforme o modelo produz os tokens.
+        Se o consumidor parar de iterar (ex: cliente desconectou), a geração
+        é interrompida e o lock liberado.
+        """
+        prompt = build_prompt(query, context_text, prompt_template)
+        with self._lock:
+            stream = self.llm.create_chat_completion(
+                messages=[{"role": "user", "content": prompt}],
+                max_tokens=max_tokens or DEFAULT_MAX_TOKENS,
+                stream=True,
+                **sampling(temperature),
+            )
+            for part in stream:
+                piece = part["choices"][0]["delta"].get("content")
+                if piece:
+                    yield piece
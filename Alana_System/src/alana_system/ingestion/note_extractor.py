@@ -1,11 +1,16 @@
 import logging
 from pathlib import Path
-from typing import List
+from typing import Iterator, List
 
 from .text_extractor import PageText
 
 logger = logging.getLogger(__name__)
 
+# Tamanho das seções lidas por iter_sections (em caracteres)
+SECTION_CHARS = 1_000_000
+# Tamanho de cada leitura do arquivo
+READ_BLOCK_CHARS = 64 * 1024
+
 
 class NoteExtractor:
     """
@@ -69,6 +74,58 @@ class NoteExtractor:
                 f"Falha na extração da nota: {file_path.name}"
             ) from exc
 
+    def iter_sections(
+        self,
+        file_path: Path,
+        encoding: str = "utf-8",
+        section_chars: int = SECTION_CHARS,
+    ) -> Iterator[PageText]:
+        """
+        Lê a nota em seções de ~section_chars caracteres, sem carregar o
+        arquivo inteiro (exportações e transcrições de vários GB).
+
+        As seções terminam numa fronteira de parágrafo ("\n\n") sempre que
+        possível, então o chunking por parágrafos muda pouco em relação ao
+        extract; só a sobreposição entre o último chunk de uma seção e o
+        primeiro da seguinte se perde. Todas as seções são a página 1.
+        """
+        self._validate_file(file_path)
+
+        try:
+            with file_path.open("r", encoding=encoding) as file:
+                buffer = ""
+                first = True
+                while True:
+                    block = file.read(READ_BLOCK_CHARS)
+                    if first:
+                        block = block.lstrip("\ufeff")
+                        first = False
+                    buffer += block
+
+                    while len(buffer) >= section_chars or (not block and buffer):
+                        cut = len(buffer) if not block else self._section_end(buffer, section_chars)
+                        section = buffer[:cut].strip()
+                        buffer = buffer[cut:]
+                        if section:
+                            yield PageText(page_number=1, text=section, char_count=len(section))
+
+                    if not block:
+                        return
+
+        except UnicodeDecodeError as exc:
+            raise RuntimeError(
+                f"Erro de leitura: codificação incompatível em {file_path.name}"
+            ) from exc
+
+    @staticmethod
+    def _section_end(buffer: str, section_chars: int) -> int:
+        """Fim da seção: último parágrafo, senão última linha, senão o limite."""
+        for sep in ("\n\n", "\n"):
+            pos = buffer.rfind(sep, 0, section_chars)
+            if pos > 0:
+                return pos + len(sep)
+        return section_chars
+
     # =====================================================
     # Internals
     # =====================================================
@@ -0,0 +1,68 @@
+"""
+sparse.py
+Vetor esparso da busca por palavra-chave (BM25)
+
+Espelho do pacote Go `lexical`: tokenização, hash e pesos precisam continuar
+idênticos nos dois lados, senão a busca não encontra os termos gravados aqui.
+
+- Termos: sequências de letras e dígitos, em minúsculas
+- Índice do termo: FNV-1a de 32 bits do UTF-8
+- Peso no trecho: parte de frequência do BM25 (k1=1.2, b=0.75, tamanho
+  médio fixo de 256 termos); o IDF é aplicado pelo Qdrant (Modifier.IDF)
+"""
+
+from __future__ import annotations
+
+import unicodedata
+from collections import Counter
+from typing import List, Tuple
+
+# Nome do vetor esparso na collection
+VECTOR_NAME = "bm25"
+
+K1 = 1.2
+B = 0.75
+AVG_DOC_TOKENS = 256
+
+_FNV_OFFSET = 0x811C9DC5
+_FNV_PRIME = 0x01000193
+
+
+def _is_term_char(ch: str) -> bool:
+    # Mesmo critério de unicode.IsLetter / unicode.IsDigit do Go
+    category = unicodedata.category(ch)
+    return category.startswith("L") or category == "Nd"
+
+
+def tokens(text: str) -> List[str]:
+    """Quebra o texto em termos; todo o resto separa termos."""
+    out: List[str] = []
+    current: List[str] = []
+    for ch in text.lower():
+        if _is_term_char(ch):
+            current.append(ch)
+        elif current:
+            out.append("".join(current))
+            current = []
+    if current:
+        out.append("".join(current))
+    return out
+
+
+def term_index(term: str) -> int:
+    """Posição do termo no vetor esparso (FNV-1a de 32 bits)."""
+    h = _FNV_OFFSET
+    for byte in term.encode("utf-8"):
+        h ^= byte
+        h = (h * _FNV_PRIME) & 0xFFFFFFFF
+    return h
+
+
+def document_vector(text: str) -> Tuple[List[int], List[float]]:
+    """Índices (em ordem crescente) e pesos BM25 de um trecho."""
+    terms = tokens(text)
+    tf = Counter(term_index(t) for t in terms)
+    norm = K1 * (1 - B + B * len(terms) / AVG_DOC_TOKENS)
+    indices = sorted(tf)
+    values = [tf[i] * (K1 + 1) / (tf[i] + norm) for i in indices]
+    return indices, values
@@ -0,0 +1,103 @@
+"""
+text_codec.py
+Compressão do texto dos chunks no payload do Qdrant
+
+O texto comprimido vai em "text" e o codec em "text_codec"; quem lê o payload
+(VectorStore.search e o engine Go) descomprime pelo codec do ponto, então
+collections podem misturar pontos comprimidos e não comprimidos. O payload
+do Qdrant é JSON, sem tipo binário: os bytes comprimidos vão em base64.
+
+A compressão é configurada por collection em ALANA_TEXT_COMPRESSION:
+
+    zstd                                  todas as collections
+    alana_knowledge_base=zstd,outra=none  por collection
+
+Só textos a partir de ALANA_TEXT_COMPRESSION_MIN_BYTES (padrão 512) são
+comprimidos, e só se o resultado ficar menor: em chunks curtos o base64
+anula o ganho. zstd requer o pacote zstandard; zlib continua aceito (e lido)
+para as collections gravadas antes do zstd.
+"""
+
+from __future__ import annotations
+
+import base64
+import os
+import zlib
+from typing import Optional, Tuple
+
+CODEC_NONE = ""
+CODEC_ZSTD = "zstd"
+CODEC_ZLIB = "zlib"
+
+SUPPORTED_CODECS = {CODEC_NONE, CODEC_ZSTD, CODEC_ZLIB}
+
+DEFAULT_MIN_BYTES = 512
+ZSTD_LEVEL = 12
+
+
+def codec_for_collection(collection_name: str, spec: Optional[str] = None) -> str:
+    """Resolve o codec da collection a partir de ALANA_TEXT_COMPRESSION."""
+    if spec is None:
+        spec = os.environ.get("ALANA_TEXT_COMPRESSION", "")
+
+    codec = CODEC_NONE
+    for item in filter(None, (s.strip() for s in spec.split(","))):
+        name, sep, value = item.partition("=")
+        if not sep:
+            codec = name
+        elif name == collection_name:
+            codec = value
+            break
+
+    if codec == "none":
+        codec = CODEC_NONE
+    if codec not in SUPPORTED_CODECS:
+        raise ValueError(f"Codec de texto não suportado: {codec!r} (use zstd, zlib ou none)")
+    return codec
+
+
+def min_compress_bytes() -> int:
+    """Tamanho mínimo (em bytes UTF-8) para comprimir, de ALANA_TEXT_COMPRESSION_MIN_BYTES."""
+    value = os.environ.get("ALANA_TEXT_COMPRESSION_MIN_BYTES", "")
+    return int(value) if value else DEFAULT_MIN_BYTES
+
+
+def encode_text(text: str, codec: str, min_bytes: Optional[int] = None) -> Tuple[str, str]:
+    """
+    Comprime o texto para o payload. Devolve o valor de "text" e o codec
+    usado, vazio se o texto ficou puro (curto demais ou sem ganho).
+    """
+    if not codec:
+        return text, CODEC_NONE
+    if min_bytes is None:
+        min_bytes = min_compress_bytes()
+
+    raw = text.encode("utf-8")
+    if len(raw) < min_bytes:
+        return text, CODEC_NONE
+
+    if codec == CODEC_ZSTD:
+        import zstandard  # dependência opcional, só para este codec
+
+        compressed = zstandard.ZstdCompressor(level=ZSTD_LEVEL).compress(raw)
+    elif codec == CODEC_ZLIB:
+        compressed = zlib.compress(raw, 9)
+    else:
+        raise ValueError(f"Codec de texto desconhecido: {codec!r}")
+
+    value = base64.b64encode(compressed).decode("ascii")
+    if len(value) >= len(raw):
+        return text, CODEC_NONE
+    return value, codec
+
+
+def decode_text(value: str, codec: Optional[str]) -> str:
+    if not codec:
+        return value
+    if codec == CODEC_ZSTD:
+        import zstandard
+
+        return zstandard.ZstdDecompressor().decompress(base64.b64decode(value)).decode("utf-8")
+    if codec == CODEC_ZLIB:
+        return zlib.decompress(base64.b64decode(value)).decode("utf-8")
+    raise ValueError(f"Codec de texto desconhecido: {codec!r}")
@@ -0,0 +1,127 @@
+"""
+text_store.py
+Armazenamento do texto dos chunks fora do Qdrant (payload offloading)
+
+Com um text store configurado, o Qdrant guarda só o vetor e os metadados de
+cada ponto; o texto completo fica aqui, indexado pelo ID do ponto, e é buscado
+depois da busca vetorial. O orchestrator e a API Go leem o mesmo backend
+(ALANA_TEXT_STORE):
+
+    file:<dir>        um arquivo por ponto (ex: file:./data/texts)
+    postgres:<dsn>    tabela alana_chunk_text (requer psycopg)
+"""
+
+from __future__ import annotations
+
+import logging
+import os
+from pathlib import Path
+from typing import Dict, Iterable, Optional
+
+logger = logging.getLogger(__name__)
+
+
+class TextStore:
+    """Interface dos backends de texto."""
+
+    def put_many(self, texts: Dict[str, str]) -> None:
+        raise NotImplementedError
+
+    def get_many(self, ids: Iterable[str]) -> Dict[str, str]:
+        raise NotImplementedError
+
+    def delete_many(self, ids: Iterable[str]) -> None:
+        """Apaga o texto dos pontos; IDs ausentes são ignorados."""
+        raise NotImplementedError
+
+
+class FileTextStore(TextStore):
+    """
+    Um arquivo por ponto, em subdiretórios pelos dois primeiros caracteres do
+    ID para não concentrar milhões de arquivos num diretório só.
+    """
+
+    def __init__(self, root: str):
+        self.root = Path(root)
+        self.root.mkdir(parents=True, exist_ok=True)
+
+    def _path(self, point_id: str) -> Path:
+        return self.root / point_id[:2] / f"{point_id}.txt"
+
+    def put_many(self, texts: Dict[str, str]) -> None:
+        for point_id, text in texts.items():
+            path = self._path(point_id)
+            path.parent.mkdir(parents=True, exist_ok=True)
+            tmp = path.with_suffix(".tmp")
+            tmp.write_text(text, encoding="utf-8")
+            os.replace(tmp, path)
+
+    def get_many(self, ids: Iterable[str]) -> Dict[str, str]:
+        out: Dict[str, str] = {}
+        for point_id in ids:
+            try:
+                out[point_id] = self._path(point_id).read_text(encoding="utf-8")
+            except FileNotFoundError:
+                logger.warning(f"Texto não encontrado no text store | id={point_id}")
+        return out
+
+    def delete_many(self, ids: Iterable[str]) -> None:
+        for point_id in ids:
+            self._path(point_id).unlink(missing_ok=True)
+
+
+class PostgresTextStore(TextStore):
+    """Tabela alana_chunk_text compartilhada entre réplicas."""
+
+    def __init__(self, dsn: str):
+        import psycopg  # dependência opcional, só para este backend
+
+        self.conn = psycopg.connect(dsn, autocommit=True)
+        self.conn.execute(
+            "CREATE TABLE IF NOT EXISTS alana_chunk_text ("
+            " point_id TEXT PRIMARY KEY,"
+            " text     TEXT NOT NULL)"
+        )
+
+    def put_many(self, texts: Dict[str, str]) -> None:
+        with self.conn.cursor() as cur:
+            cur.executemany(
+                "INSERT INTO alana_chunk_text (point_id, text) VALUES (%s, %s) "
+                "ON CONFLICT (point_id) DO UPDATE SET text = EXCLUDED.text",
+                list(texts.items()),
+            )
+
+    def get_many(self, ids: Iterable[str]) -> Dict[str, str]:
+        ids = list(ids)
+        if not ids:
+            return {}
+        rows = self.conn.execute(
+            "SELECT point_id, text FROM alana_chunk_text WHERE point_id = ANY(%s)",
+            (ids,),
+        ).fetchall()
+        return {point_id: text for point_id, text in rows}
+
+    def delete_many(self, ids: Iterable[str]) -> None:
+        ids = list(ids)
+        if ids:
+            self.conn.execute("DELETE FROM alana_chunk_text WHERE point_id = ANY(%s)", (ids,))
+
+
+def open_text_store(spec: Optional[str] = None) -> Optional[TextStore]:
+    """
+    Abre o backend descrito por spec (padrão: ALANA_TEXT_STORE).
+    Devolve None se nada estiver configurado (texto no payload do Qdrant).
+    """
+    if spec is None:
+        spec = os.environ.get("ALANA_TEXT_STORE", "")
+    if not spec:
+        return None
+
+    kind, _, arg = spec.partition(":")
+    if kind == "file":
+        if not arg:
+            raise ValueError("text store file: requer um diretório")
+        return FileTextStore(arg)
+    if kind == "postgres":
+        return PostgresTextStore(arg)
+    raise ValueError(f"Backend de text store desconhecido: {kind!r}")
@@ -11,10 +11,18 @@ GARANTIAS DE SEGURANÇA:
 - Upsert em batches para evitar timeouts
 - Preservação do ID original no payload
 - Indexação explícita de campos do payload (performance em filtros)
+- Upsert em staging por versão de ingestão (publicado pelo orchestrator Go)
+- Texto dos chunks opcionalmente fora do payload (ver text_store.py)
+  ou comprimido nele (ver text_codec.py)
+- Vetor esparso BM25 ao lado do denso para a busca híbrida (ver sparse.py),
+  nas collections criadas com ele
+- Vetor denso em float16 nas collections configuradas em
+  ALANA_VECTOR_DATATYPE (metade da memória; ver datatype_for_collection)
 """
 
 from __future__ import annotations
 
+import os
 import uuid
 import logging
 from typing import List, Dict, Any, Optional
@@ -22,16 +30,52 @@ from typing import List, Dict, Any, Optional
 import numpy as np
 from qdrant_client import QdrantClient
 from qdrant_client.models import (
+    Datatype,
     VectorParams,
     Distance,
     PointStruct,
     Filter,
+    Modifier,
+    SparseVector,
+    SparseVectorParams,
 )
 
 from ..embeddings.embedder import EmbeddedChunk
+from .sparse import VECTOR_NAME as SPARSE_VECTOR, document_vector
+from .text_codec import codec_for_collection, decode_text, encode_text
+from .text_store import TextStore, open_text_store
 
 logger = logging.getLogger(__name__)
 
+DATATYPES = {"float32": Datatype.FLOAT32, "float16": Datatype.FLOAT16}
+
+
+def datatype_for_collection(collection_name: str, spec: Optional[str] = None) -> str:
+    """
+    Resolve o datatype do vetor denso das collections novas a partir de
+    ALANA_VECTOR_DATATYPE, no formato do ALANA_TEXT_COMPRESSION:
+
+        float16                                    todas as collections
+        alana_knowledge_base=float16,outra=float32 por collection
+
+    O orchestrator Go (vecenc.DatatypeForCollection) lê a mesma variável.
+    """
+    if spec is None:
+        spec = os.environ.get("ALANA_VECTOR_DATATYPE", "")
+
+    datatype = "float32"
+    for item in filter(None, (s.strip() for s in spec.split(","))):
+        name, sep, value = item.partition("=")
+        if not sep:
+            datatype = name
+        elif name == collection_name:
+            datatype = value
+            break
+
+    if datatype not in DATATYPES:
+        raise ValueError(f"Datatype de vetor não suportado: {datatype!r} (use float32 ou float16)")
+    return datatype
+
 
 class VectorStore:
     """
@@ -58,10 +102,14 @@ class VectorStore:
         path: Optional[str] = None,
         vector_dim: int = 384,
         distance: Distance = Distance.COSINE,
+        text_store: Optional[TextStore] = None,
     ):
         self.collection_name = collection_name
         self.vector_dim = vector_dim
         self.distance = distance
+        # Sem text store explícito, usa ALANA_TEXT_STORE (vazio = texto no payload)
+        self.text_store = text_store if text_store is not None else open_text_store()
+        self.text_codec = codec_for_collection(collection_name)
 
         if location or path:
             self.client = QdrantClient(location=location, path=path)
@@ -70,11 +118,13 @@ class VectorStore:
 
         self._ensure_collection()
 
-        # Ativa o Full-Text Search no campo de texto
-        self.create_payload_index(
-            field_name="text",
-            field_type="text"
-        )
+        # Ativa o Full-Text Search no campo de texto (sem sentido se o texto
+        # estiver comprimido ou fora do payload)
+        if not self.text_codec and self.text_store is None:
+            self.create_payload_index(
+                field_name="text",
+                field_type="text"
+            )
         self.create_payload_index(
             field_name="file_name",
             field_type="keyword"
@@ -89,18 +139,32 @@ class VectorStore:
 
         if exists:
             logger.info(f"Collection já existe: {self.collection_name}")
+            # Collections criadas antes da busca híbrida não têm o vetor
+            # esparso; nelas só o denso é gravado
+            info = self.client.get_collection(self.collection_name)
+            sparse = info.config.params.sparse_vectors or {}
+            self.hybrid = SPARSE_VECTOR in sparse
+            if not self.hybrid:
+                logger.info("Collection sem vetor esparso: busca híbrida desligada")
             return
 
+        datatype = datatype_for_collection(self.collection_name)
         logger.info(
-            f"Criando collection '{self.collection_name}' | dim={self.vector_dim}"
+            f"Criando collection '{self.collection_name}' | dim={self.vector_dim} | {datatype}"
         )
         self.client.create_collection(
             collection_name=self.collection_name,
             vectors_config=VectorParams(
                 size=self.vector_dim,
                 distance=self.distance,
+                datatype=DATATYPES[datatype],
             ),
+            # O Qdrant calcula o IDF de cada termo na busca
+            sparse_vectors_config={
+                SPARSE_VECTOR: SparseVectorParams(modifier=Modifier.IDF),
+            },
         )
+        self.hybrid = True
 
     # ------------------------------------------------------------------
     # Payload Index
@@ -141,12 +205,21 @@ class VectorStore:
         self,
         chunks: List[EmbeddedChunk],
         batch_size: int = 100,
+        ingest_version: Optional[str] = None,
+        provenance: Optional[Dict[str, Any]] = None,
     ) -> None:
         """
         Insere embeddings em batches.
         Garante:
         - UUID válido e determinístico
         - Dimensão correta do vetor
+
+        Com ingest_version, os pontos novos entram com staging=True (ficam
+        invisíveis para as buscas) e os que já existem apenas recebem a versão.
+        Quem publica ou descarta a versão é o orchestrator Go.
+
+        provenance vai no payload de cada ponto novo (ver
+        IngestionPipeline.provenance).
         """
         if not chunks:
             logger.warning("Nenhum embedding para inserir")
@@ -158,6 +231,7 @@ class VectorStore:
         for i in range(0, total, batch_size):
             batch = chunks[i : i + batch_size]
             points: List[PointStruct] = []
+            texts: Dict[str, str] = {}
 
             for chunk in batch:
                 # -------------------------------
@@ -184,22 +258,53 @@ class VectorStore:
                 payload = {
                     "original_id": chunk.chunk_id,
                     "page_number": chunk.page_number,
-                    "text": chunk.text,
                     "file_name": chunk.source_name,
                 }
+                if self.text_store is not None:
+                    # O texto vai para o text store; text_offloaded avisa quem
+                    # lê o payload que precisa buscá-lo pelo ID do ponto
+                    texts[uuid_id] = chunk.text
+                    payload["text_offloaded"] = True
+                else:
+                    # Chunks curtos ficam puros mesmo com compressão ativa
+                    payload["text"], codec = encode_text(chunk.text, self.text_codec)
+                    if codec:
+                        payload["text_codec"] = codec
+                if ingest_version:
+                    payload["staging"] = True
+                    payload["ingest_version"] = ingest_version
+                if provenance:
+                    payload["provenance"] = dict(provenance)
+
+                vector: Any = chunk.embedding.tolist()
+                if self.hybrid:
+                    indices, values = document_vector(chunk.text)
+                    vector = {
+                        "": vector,
+                        SPARSE_VECTOR: SparseVector(indices=indices, values=values),
+                    }
 
                 points.append(
                     PointStruct(
                         id=uuid_id,
-                        vector=chunk.embedding.tolist(),
+                        vector=vector,
                         payload=payload,
                     )
                 )
 
-            self.client.upsert(
-                collection_name=self.collection_name,
-                points=points,
-            )
+            # O texto é gravado antes do ponto, para que nenhum ponto visível
+            # fique sem texto
+            if texts:
+                self.text_store.put_many(texts)
+
+            if ingest_version:
+                points = self._stage_existing(points, ingest_version)
+
+            if points:
+                self.client.upsert(
+                    collection_name=self.collection_name,
+                    points=points,
+                )
 
             logger.debug(
                 f"Upsert batch concluído | "
@@ -208,6 +313,33 @@ class VectorStore:
 
         logger.info("Upsert finalizado com sucesso")
 
+    def _stage_existing(
+        self,
+        points: List[PointStruct],
+        ingest_version: str,
+    ) -> List[PointStruct]:
+        """
+        Marca com a nova versão os pontos que já estão publicados (mesmo ID =
+        mesmo conteúdo) e devolve só os que precisam ser inseridos.
+        Sobrescrevê-los com staging=True os esconderia antes do commit.
+        """
+        existing = self.client.retrieve(
+            collection_name=self.collection_name,
+            ids=[p.id for p in points],
+            with_payload=False,
+            with_vectors=False,
+        )
+        existing_ids = {str(r.id) for r in existing}
+        if not existing_ids:
+            return points
+
+        self.client.set_payload(
+            collection_name=self.collection_name,
+            payload={"ingest_version": ingest_version},
+            points=list(existing_ids),
+        )
+        return [p for p in points if str(p.id) not in existing_ids]
+
     # ------------------------------------------------------------------
     # Search
     # ------------------------------------------------------------------
@@ -242,14 +374,23 @@ class VectorStore:
         # O resultado agora vem dentro da propriedade .points
         results = response.points
 
+        offloaded: Dict[str, str] = {}
+        if self.text_store is not None:
+            offloaded = self.text_store.get_many(
+                str(r.id) for r in results if r.payload.get("text_offloaded")
+            )
+
         response_list: List[Dict[str, Any]] = []
         for r in results:
+            text = decode_text(r.payload.get("text"), r.payload.get("text_codec"))
+            if r.payload.get("text_offloaded"):
+                text = offloaded.get(str(r.id), "")
             response_list.append(
                 {
                     "score": r.score,
                     "chunk_id": r.payload.get("original_id"),
                     "page_number": r.payload.get("page_number"),
-                    "text": r.payload.get("text"),
+                    "text": text,
                     "file_name": r.payload.get("file_name"),
                 }
             )
@@ -1,6 +1,6 @@
 
-from dataclasses import dataclass
-from typing import List, Tuple
+from dataclasses import dataclass, replace
+from typing import Iterable, Iterator, List, Optional, Tuple
 import hashlib
 import logging
 
@@ -57,20 +57,49 @@ class TextChunker:
     def chunk_pages(
         self,
         pages: List[CleanedPageText],
-        source_name: str
+        source_name: str,
+        source_path: Optional[str] = None,
     ) -> List[TextChunk]:
         """
         Processa múltiplas páginas já limpas.
-        """
-        chunks: List[TextChunk] = []
 
-        for page in pages:
-            page_chunks = self._chunk_single_page(page, source_name)
-            chunks.extend(page_chunks)
+        source_path é o caminho do documento relativo a data/raw (com "/"),
+        usado no ID estável do chunk. Sem ele, usa source_name.
+        """
+        chunks = list(self.iter_chunks(pages, source_name, source_path=source_path))
 
         logger.info(f"Chunking finalizado | total_chunks={len(chunks)}")
         return chunks
 
+    def iter_chunks(
+        self,
+        pages: Iterable[CleanedPageText],
+        source_name: str,
+        source_path: Optional[str] = None,
+    ) -> Iterator[TextChunk]:
+        """
+        Versão incremental do chunk_pages: consome as páginas (ou seções de um
+        arquivo grande) sob demanda e produz os chunks com os mesmos IDs.
+        """
+        # O ID depende da posição do chunk no documento inteiro
+        id_source = source_path or source_name
+        index = 0
+        for page in pages:
+            for chunk in self._chunk_single_page(page, source_name):
+                yield replace(chunk, chunk_id=self.stable_chunk_id(id_source, index, chunk.text))
+                index += 1
+
+    @staticmethod
+    def stable_chunk_id(source_path: str, index: int, text: str) -> str:
+        """
+        ID determinístico do chunk: SHA-256 de "origem \\x1f índice \\x1f hash do conteúdo".
+
+        Deve ser mantido idêntico ao pacote Go alana_system/chunkid.
+        """
+        content_hash = hashlib.sha256(text.encode("utf-8")).hexdigest()
+        key = f"{source_path}\x1f{index}\x1f{content_hash}"
+        return hashlib.sha256(key.encode("utf-8")).hexdigest()
+
     # --------------------------------------------------------
 
     def _chunk_single_page(
@@ -139,11 +168,15 @@ class TextChunker:
                 i += 1
             else:
                 committed = self._commit_chunk(chunks, current_paras, page.page_number, source_name)
+                previous = current_paras
+                current_paras = []
+                current_len = 0
                 if committed:
-                    current_paras, current_len = self._build_overlap(current_paras)
-                else:
-                    current_paras = []
-                    current_len = 0
+                    # A sobreposição só fica se o parágrafo ainda couber depois
+                    # dela; senão ela viraria um chunk sozinha, repetidamente
+                    overlap_paras, overlap_len = self._build_overlap(previous)
+                    if overlap_len + 2 + para_len <= self.max_chars:
+                        current_paras, current_len = overlap_paras, overlap_len
 
         # Commit final
         if current_paras:
@@ -258,14 +291,11 @@ class TextChunker:
         source_name: str
     ) -> TextChunk:
         """
-        Cria um chunk determinístico com hash estável.
+        Cria o chunk. O chunk_id é atribuído em chunk_pages, que conhece a
+        posição do chunk no documento.
         """
-        chunk_id = hashlib.sha256(
-            f"{source_name}:{page_number}:{text}".encode("utf-8")
-        ).hexdigest()
-
         return TextChunk(
-            chunk_id=chunk_id,
+            chunk_id="",
             page_number=page_number,
             text=text,
             char_count=len(text),
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ==============================
+// Respostas em streaming (SSE)
+// ==============================
+
+// handleAskStream implementa POST /ask/stream: o mesmo corpo do /ask, com a
+// resposta em Server-Sent Events para o frontend renderizar enquanto o
+// sidecar gera:
+//
+//	event: sources   {"sources": [...]}        trechos usados, antes da geração
+//	event: token     {"token": "..."}          cada pedaço do texto, em markdown
+//	event: correction {"answer": "..."}        com "draft": true, o texto final
+//	                                           que substitui o rascunho inteiro
+//	event: done      <corpo do /ask>           resposta final (no format pedido)
+//	event: error     {"error": "..."}          falha depois de o stream começar
+//
+// O modo report não tem streaming: use o /ask.
+func (s *server) handleAskStream(w http.ResponseWriter, r *http.Request) {
+	call, ok := s.parseAsk(w, r)
+	if !ok {
+		return
+	}
+	if call.req.Mode == askModeReport {
+		writeRequestError(w, invalidField("mode", "invalid_value", "o modo report não tem streaming; use /ask"))
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Proxies como o nginx seguram a resposta inteira sem isso
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// O cliente desconectar cancela r.Context() e, com ele, a geração no
+	// sidecar; os erros de escrita ficam só no log
+	send := func(event string, v any) {
+		if err := writeSSE(w, rc, event, v); err != nil && r.Context().Err() == nil {
+			serverLog.Warn("Erro ao enviar evento", "event", event, "err", err)
+		}
+	}
+
+	stream := &answerStream{
+		Sources: func(results []SearchResult) {
+			send("sources", map[string]any{"sources": newAskResponse(Answer{Sources: results}, call.format).Sources})
+		},
+		Token:      func(token string) { send("token", map[string]string{"token": token}) },
+		Correction: func(text string) { send("correction", map[string]string{"answer": text}) },
+	}
+
+	start := time.Now()
+	answer, err := s.engine.AskStream(r.Context(), call.question, call.opts, stream)
+	s.recent.add("/ask/stream", call.question, call.opts, answer, time.Since(start), err)
+	if err != nil {
+		serverLog.ErrorContext(r.Context(), "Erro em /ask/stream", "err", err)
+		send("error", map[string]string{"error": "falha ao gerar resposta"})
+		return
+	}
+
+	send("done", s.finishAsk(r, call, answer, time.Since(start)))
+}
+
+// writeSSE escreve um evento e o envia imediatamente
+func writeSSE(w http.ResponseWriter, rc *http.ResponseController, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
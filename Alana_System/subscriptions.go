@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ==============================
+// Assinaturas de perguntas salvas
+// ==============================
+
+const webhookTimeout = 10 * time.Second
+
+// savedNotification é o corpo enviado aos webhooks genéricos. Webhooks do
+// Slack recebem só {"text": ...} com o mesmo conteúdo formatado.
+type savedNotification struct {
+	Query       string        `json:"query"`
+	Question    string        `json:"question"`
+	Answer      string        `json:"answer"`
+	TopSource   savedSource   `json:"top_source"`
+	Sources     []savedSource `json:"sources"`
+	PreviousRun time.Time     `json:"previous_run"`
+	Time        time.Time     `json:"time"`
+}
+
+// runSavedCheck implementa `alana saved check [-every D]`: refaz a busca de
+// cada pergunta assinada e, se o trecho mais relevante não estava entre as
+// fontes da última execução (conteúdo novo mudou a recuperação), gera a
+// resposta atualizada e avisa os assinantes. Sem -every, faz uma passada só
+// (para rodar após a ingestão ou no cron).
+func runSavedCheck(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("saved check", flag.ContinueOnError)
+	every := fs.Duration("every", 0, "repete a verificação nesse intervalo (0 = uma vez)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	for {
+		if err := engine.checkSavedQueries(ctx); err != nil {
+			if *every == 0 {
+				return err
+			}
+			monitorLog.Error("Erro ao verificar perguntas salvas", "err", err)
+		}
+		if *every == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(*every):
+		}
+	}
+}
+
+// checkSavedQueries faz uma passada sobre as perguntas assinadas
+func (e *AlanaEngine) checkSavedQueries(ctx context.Context) error {
+	queries, err := loadSavedQueries(savedQueriesPath)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, name := range slices.Sorted(maps.Keys(queries)) {
+		q := queries[name]
+		if len(q.Subscribers) == 0 {
+			continue
+		}
+
+		run, notify, err := e.checkSavedQuery(ctx, q)
+		if err != nil {
+			monitorLog.Error("Erro na pergunta salva", "query", name, "err", err)
+			continue
+		}
+		if run == nil {
+			monitorLog.Info("Pergunta salva sem fonte nova", "query", name)
+			continue
+		}
+
+		if notify {
+			monitorLog.Info("Pergunta salva com nova fonte principal", "query", name, "source", run.Sources[0].Source, "page", run.Sources[0].Page)
+			n := savedNotification{
+				Query:       name,
+				Question:    q.Question,
+				Answer:      run.Answer,
+				TopSource:   run.Sources[0],
+				Sources:     run.Sources,
+				PreviousRun: q.LastRun.Time,
+				Time:        run.Time,
+			}
+			for _, hook := range q.Subscribers {
+				if err := sendWebhook(ctx, hook, n); err != nil {
+					monitorLog.Error("Erro ao notificar", "webhook", redactWebhook(hook), "err", err)
+				}
+			}
+		} else {
+			fmt.Printf("🆕 %s: primeira execução registrada\n", name)
+		}
+
+		q.LastRun = run
+		queries[name] = q
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	return saveSavedQueries(savedQueriesPath, queries)
+}
+
+// checkSavedQuery decide se a pergunta mudou. Devolve a nova execução (nil se
+// nada mudou) e se os assinantes devem ser avisados; a primeira execução só
+// registra a linha de base.
+func (e *AlanaEngine) checkSavedQuery(ctx context.Context, q savedQuery) (*savedRun, bool, error) {
+	if q.LastRun != nil {
+		opts, err := e.collections.askOptions(e.collection, q.Profile, retrievalSettings{})
+		if err != nil {
+			return nil, false, err
+		}
+		// Só a busca, sem gerar: é barata e basta para detectar fonte nova
+		results, err := e.retrieve(ctx, q.Question, opts)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(results) == 0 || slices.ContainsFunc(q.LastRun.Sources, func(s savedSource) bool { return s.ID == results[0].ID }) {
+			return nil, false, nil
+		}
+	}
+
+	run, err := e.runSavedQuery(ctx, q)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(run.Sources) == 0 {
+		return nil, false, nil
+	}
+	return &run, q.LastRun != nil, nil
+}
+
+// validateWebhook aceita só URLs http(s) absolutas
+func validateWebhook(hook string) error {
+	u, err := url.Parse(hook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook inválido %q: use uma URL http(s)", hook)
+	}
+	return nil
+}
+
+// isSlackWebhook reconhece os incoming webhooks do Slack
+func isSlackWebhook(hook string) bool {
+	u, err := url.Parse(hook)
+	return err == nil && u.Host == "hooks.slack.com"
+}
+
+// redactWebhook esconde o caminho do webhook (que costuma ser o segredo) nos logs
+func redactWebhook(hook string) string {
+	u, err := url.Parse(hook)
+	if err != nil {
+		return "webhook"
+	}
+	return u.Scheme + "://" + u.Host + "/…"
+}
+
+func sendWebhook(ctx context.Context, hook string, n savedNotification) error {
+	var payload any = n
+	if isSlackWebhook(hook) {
+		payload = map[string]string{"text": slackText(n)}
+	}
+	return postWebhook(ctx, hook, payload)
+}
+
+// postWebhook envia o payload em JSON e trata qualquer status fora de 2xx
+// como erro
+func postWebhook(ctx context.Context, hook string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	return nil
+}
+
+// slackText formata a notificação em mrkdwn do Slack
+func slackText(n savedNotification) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, ":bell: *%s* mudou: nova fonte principal *%s* (pág %d)\n", n.Query, n.TopSource.Source, n.TopSource.Page)
+	fmt.Fprintf(&b, "> %s\n\n", n.Question)
+	b.WriteString(truncateRunes(n.Answer, 2500))
+	return b.String()
+}
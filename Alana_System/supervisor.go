@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ==============================
+// Supervisão do sidecar
+// ==============================
+
+const (
+	// defaultSidecarProbeInterval é a frequência do GET /health no sidecar
+	defaultSidecarProbeInterval = 10 * time.Second
+	// sidecarStartProbe é a frequência do /health enquanto o sidecar sobe
+	sidecarStartProbe = time.Second
+	// sidecarProbeFailures é quantas verificações seguidas podem falhar antes
+	// de o sidecar ser reiniciado
+	sidecarProbeFailures = 3
+	// sidecarStartTimeout é quanto o sidecar tem para responder ao /health
+	// depois de iniciar (ele carrega os modelos antes)
+	sidecarStartTimeout = 3 * time.Minute
+	// sidecarStopTimeout é quanto o sidecar tem para sair depois do SIGTERM
+	sidecarStopTimeout = 15 * time.Second
+	// Espera entre reinícios: dobra a cada queda, de sidecarBackoffMin até
+	// sidecarBackoffMax, e volta ao mínimo depois de sidecarStableAfter no ar
+	sidecarBackoffMin  = time.Second
+	sidecarBackoffMax  = time.Minute
+	sidecarStableAfter = time.Minute
+	// sidecarLogLines é quantas linhas da saída ficam guardadas para explicar
+	// uma queda
+	sidecarLogLines = 50
+)
+
+// Políticas de reinício (ALANA_SIDECAR_RESTART)
+const (
+	restartAlways    = "always"
+	restartOnFailure = "on-failure"
+	restartNever     = "never"
+)
+
+// sidecarSupervisor inicia o sidecar Python junto com o `alana serve` e o
+// mantém no ar: verifica o /health a cada probeInterval, reinicia o processo
+// se ele sair ou parar de responder (conforme a política, com espera
+// crescente entre as tentativas) e repassa a saída dele ao log.
+type sidecarSupervisor struct {
+	command       []string
+	policy        string
+	probeInterval time.Duration
+
+	up       atomic.Bool
+	restarts atomic.Int64
+
+	mu   sync.Mutex
+	tail []string
+}
+
+// sidecarSupervisorFromEnv monta o supervisor do comando (ex: "python
+// bridge.py", separado por espaços, sem aspas), com a política de
+// ALANA_SIDECAR_RESTART (always, on-failure ou never; padrão on-failure) e
+// o intervalo de ALANA_SIDECAR_PROBE_INTERVAL (padrão 10s)
+func sidecarSupervisorFromEnv(command string) (*sidecarSupervisor, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, errors.New("comando do sidecar vazio")
+	}
+	s := &sidecarSupervisor{command: fields, policy: envOr("ALANA_SIDECAR_RESTART", restartOnFailure), probeInterval: defaultSidecarProbeInterval}
+	switch s.policy {
+	case restartAlways, restartOnFailure, restartNever:
+	default:
+		return nil, fmt.Errorf("ALANA_SIDECAR_RESTART: política desconhecida %q (use %s, %s ou %s)", s.policy, restartAlways, restartOnFailure, restartNever)
+	}
+	if raw := os.Getenv("ALANA_SIDECAR_PROBE_INTERVAL"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("ALANA_SIDECAR_PROBE_INTERVAL inválido (%q)", raw)
+		}
+		s.probeInterval = d
+	}
+	return s, nil
+}
+
+// run mantém o sidecar no ar até ctx ser cancelado, quando ele é encerrado
+// (SIGTERM e, depois de sidecarStopTimeout, SIGKILL)
+func (s *sidecarSupervisor) run(ctx context.Context) {
+	backoff := sidecarBackoffMin
+	for {
+		started := time.Now()
+		err := s.runOnce(ctx)
+		s.up.Store(false)
+		if ctx.Err() != nil {
+			sidecarLog.Info("Sidecar encerrado")
+			return
+		}
+
+		if err == nil {
+			sidecarLog.Info("Sidecar saiu normalmente")
+		} else {
+			sidecarLog.Error("Sidecar caiu", "err", err, "output", s.lastLines())
+		}
+		if s.policy == restartNever || (err == nil && s.policy == restartOnFailure) {
+			sidecarLog.Warn("Sidecar fora do ar; sem reinício", "policy", s.policy)
+			return
+		}
+
+		if time.Since(started) >= sidecarStableAfter {
+			backoff = sidecarBackoffMin
+		}
+		sidecarLog.Info("Reiniciando o sidecar", "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, sidecarBackoffMax)
+		s.restarts.Add(1)
+	}
+}
+
+// runOnce inicia o processo e o acompanha até ele sair, parar de responder
+// ao /health ou ctx ser cancelado. nil = saiu com status 0.
+func (s *sidecarSupervisor) runOnce(ctx context.Context) error {
+	s.mu.Lock()
+	s.tail = nil
+	s.mu.Unlock()
+
+	cmd := exec.Command(s.command[0], s.command[1:]...)
+	cmd.Env = os.Environ()
+	detachProcess(cmd)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	sidecarLog.Info("Sidecar iniciado", "pid", cmd.Process.Pid, "command", strings.Join(s.command, " "))
+
+	exited := make(chan error, 1)
+	go func() {
+		s.capture(out)
+		exited <- cmd.Wait()
+	}()
+
+	probe := time.NewTimer(sidecarStartProbe)
+	defer probe.Stop()
+	started := time.Now()
+	failures := 0
+	for {
+		select {
+		case err := <-exited:
+			return err
+		case <-ctx.Done():
+			s.stop(cmd, exited)
+			return nil
+		case <-probe.C:
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		_, err := getSidecarHealth(probeCtx)
+		cancel()
+		switch {
+		case err == nil:
+			if !s.up.Swap(true) {
+				sidecarLog.Info("Sidecar pronto", "url", sidecarURL, "elapsed", time.Since(started).Round(time.Second))
+			}
+			failures = 0
+		case !s.up.Load() && time.Since(started) < sidecarStartTimeout:
+			// Ainda carregando os modelos
+		default:
+			failures++
+			s.up.Store(false)
+			sidecarLog.Warn("Sidecar não respondeu ao /health", "failures", failures, "max", sidecarProbeFailures, "err", err)
+			if failures >= sidecarProbeFailures {
+				s.stop(cmd, exited)
+				return fmt.Errorf("sem resposta ao /health em %s", sidecarURL)
+			}
+		}
+		if s.up.Load() {
+			probe.Reset(s.probeInterval)
+		} else {
+			probe.Reset(sidecarStartProbe)
+		}
+	}
+}
+
+// stop encerra o processo (e os filhos dele) e espera a saída
+func (s *sidecarSupervisor) stop(cmd *exec.Cmd, exited <-chan error) {
+	terminateProcess(cmd)
+	select {
+	case <-exited:
+	case <-time.After(sidecarStopTimeout):
+		killProcess(cmd)
+		<-exited
+	}
+}
+
+// capture repassa a saída do sidecar ao log, guardando as últimas linhas
+func (s *sidecarSupervisor) capture(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		line := sc.Text()
+		sidecarLog.Info(line, "stream", "output")
+		s.mu.Lock()
+		s.tail = append(s.tail, line)
+		if len(s.tail) > sidecarLogLines {
+			s.tail = s.tail[len(s.tail)-sidecarLogLines:]
+		}
+		s.mu.Unlock()
+	}
+}
+
+// lastLines são as últimas linhas da saída, para o log da queda
+func (s *sidecarSupervisor) lastLines() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := min(len(s.tail), 5)
+	if n == 0 {
+		return ""
+	}
+	return "\n   " + strings.Join(s.tail[len(s.tail)-n:], "\n   ")
+}
+
+// writeSidecarMetrics acrescenta o estado do sidecar supervisionado ao
+// /metrics
+func writeSidecarMetrics(b *strings.Builder, s *sidecarSupervisor) {
+	b.WriteString("# HELP alana_sidecar_up 1 se o sidecar supervisionado responde ao /health.\n")
+	b.WriteString("# TYPE alana_sidecar_up gauge\n")
+	fmt.Fprintf(b, "alana_sidecar_up %g\n", boolGauge(s.up.Load()))
+	b.WriteString("# HELP alana_sidecar_restarts_total Reinícios do sidecar supervisionado.\n")
+	b.WriteString("# TYPE alana_sidecar_restarts_total counter\n")
+	fmt.Fprintf(b, "alana_sidecar_restarts_total %d\n", s.restarts.Load())
+}
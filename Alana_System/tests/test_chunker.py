@@ -3,6 +3,9 @@ tests/test_chunker.py
 
 Testes para a lógica de chunking semântico.
 """
+import json
+from pathlib import Path
+
 import pytest
 from alana_system.preprocessing.chunker import TextChunker
 from alana_system.ingestion.cleaner import CleanedPageText
@@ -159,3 +162,42 @@ def test_chunk_id_is_deterministic(chunker):
     assert len(chunks1) == 1
     assert len(chunks2) == 1
     assert chunks1[0].chunk_id == chunks2[0].chunk_id
+
+def test_stable_chunk_id_matches_go_scheme():
+    """
+    O ID precisa ser idêntico ao gerado pelo pacote Go alana_system/chunkid
+    (valor de referência calculado com chunkid.New).
+    """
+    chunk_id = TextChunker.stable_chunk_id("sub/relatorio.pdf", 3, "Texto de exemplo.")
+
+    assert chunk_id == "8c46c3d357806128640012d2b8d5479cce7fff27a9d6491969874db9e82c1b41"
+
+
+PARITY_FIXTURE = Path(__file__).resolve().parent.parent / "chunker" / "testdata" / "parity.json"
+
+
+@pytest.mark.parametrize(
+    "doc", json.loads(PARITY_FIXTURE.read_text(encoding="utf-8")), ids=lambda d: d["source"]
+)
+def test_matches_go_chunker(doc):
+    """
+    Os mesmos chunks e IDs do pacote Go chunker com DefaultOptions
+    (chunker_test.go lê o mesmo fixture): as notas podem passar por qualquer
+    um dos dois caminhos sem re-identificar os chunks.
+    """
+    pages = [
+        CleanedPageText(
+            page_number=p["number"],
+            text=p["text"],
+            original_char_count=len(p["text"]),
+            cleaned_char_count=len(p["text"]),
+        )
+        for p in doc["pages"]
+    ]
+    chunks = TextChunker(800, 200, 50).chunk_pages(
+        pages, doc["source"].rsplit("/", 1)[-1], source_path=doc["source"]
+    )
+
+    assert [(c.chunk_id, c.page_number, c.text) for c in chunks] == [
+        (c["id"], c["page"], c["text"]) for c in doc["chunks"]
+    ]
@@ -0,0 +1,48 @@
+"""
+tests/test_embedding_cache.py
+
+Testes do cache de embeddings da ingestão (chave por conteúdo e SQLite).
+"""
+import struct
+
+from alana_system.embeddings.embedding_cache import EmbeddingCache, cache_key, open_embedding_cache
+
+
+def vec(*xs):
+    return struct.pack(f"{len(xs)}f", *xs)
+
+
+def test_cache_key_depends_on_model_normalization_and_text():
+    base = cache_key("minilm", True, "texto")
+    assert base == cache_key("minilm", True, "texto")
+    assert base != cache_key("outro", True, "texto")
+    assert base != cache_key("minilm", False, "texto")
+    assert base != cache_key("minilm", True, "texto ")
+
+
+def test_get_many_returns_only_cached_keys(tmp_path):
+    cache = EmbeddingCache(str(tmp_path / "cache" / "embeddings.sqlite"))
+    cache.put_many({"a": vec(1.0, 2.0), "b": vec(3.0, 4.0)})
+    assert cache.get_many(["a", "x", "b", "a"]) == {"a": vec(1.0, 2.0), "b": vec(3.0, 4.0)}
+    cache.put_many({"a": vec(5.0, 6.0)})
+    assert cache.get_many(["a"]) == {"a": vec(5.0, 6.0)}
+    cache.close()
+
+
+def test_cache_survives_reopen(tmp_path):
+    path = str(tmp_path / "embeddings.sqlite")
+    cache = EmbeddingCache(path)
+    cache.put_many({f"k{i}": vec(float(i)) for i in range(1200)})
+    cache.close()
+
+    reopened = EmbeddingCache(path)
+    got = reopened.get_many(f"k{i}" for i in range(1200))
+    assert len(got) == 1200
+    assert got["k1199"] == vec(1199.0)
+
+
+def test_open_embedding_cache_is_off_without_path(monkeypatch, tmp_path):
+    monkeypatch.delenv("ALANA_EMBED_CACHE", raising=False)
+    assert open_embedding_cache() is None
+    monkeypatch.setenv("ALANA_EMBED_CACHE", str(tmp_path / "e.sqlite"))
+    assert isinstance(open_embedding_cache(), EmbeddingCache)
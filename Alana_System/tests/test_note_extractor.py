@@ -0,0 +1,37 @@
+"""
+tests/test_note_extractor.py
+
+Testes da leitura em seções de notas grandes.
+"""
+from pathlib import Path
+
+from alana_system.ingestion.note_extractor import NoteExtractor
+
+
+def test_iter_sections_breaks_on_paragraphs(tmp_path: Path):
+    """
+    As seções respeitam o limite, terminam em fronteira de parágrafo e,
+    juntas, reproduzem o texto inteiro.
+    """
+    paragraphs = [f"Parágrafo {i}: " + "palavra " * (i % 20) for i in range(500)]
+    text = "\n\n".join(p.strip() for p in paragraphs)
+    note = tmp_path / "export.txt"
+    note.write_text("﻿" + text, encoding="utf-8")
+
+    sections = list(NoteExtractor().iter_sections(note, section_chars=2000))
+
+    assert len(sections) > 1
+    assert all(s.page_number == 1 for s in sections)
+    assert all(s.char_count <= 2000 for s in sections)
+    assert all(s.text.startswith("Parágrafo") for s in sections)
+    assert "\n\n".join(s.text for s in sections) == text
+
+
+def test_iter_sections_splits_text_without_breaks(tmp_path: Path):
+    """Um texto sem quebras de linha é cortado no limite."""
+    note = tmp_path / "transcricao.txt"
+    note.write_text("a" * 5000, encoding="utf-8")
+
+    sections = list(NoteExtractor().iter_sections(note, section_chars=2000))
+
+    assert [s.char_count for s in sections] == [2000, 2000, 1000]
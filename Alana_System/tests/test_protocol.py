@@ -0,0 +1,59 @@
+"""
+tests/test_protocol.py
+
+Compatibilidade do contrato entre o sidecar e o orquestrador Go. Os fixtures
+são os mesmos de sidecarproto/testdata, lidos pelos testes Go: se estes
+testes quebrarem, o bridge.py passou a responder num formato que alguma
+versão do orquestrador não entende.
+"""
+import json
+from pathlib import Path
+
+import pytest
+from alana_system.api.protocol import PROTOCOL_VERSION, negotiate, shape
+
+FIXTURES = Path(__file__).resolve().parent.parent / "sidecarproto" / "testdata"
+
+ANSWER = "O prazo de garantia é de 12 meses [1]."
+MODEL = "llama-3-8b.Q4_K_M.gguf"
+USAGE = {"prompt_tokens": 412, "completion_tokens": 14}
+
+
+def fixture(name):
+    return json.loads((FIXTURES / name).read_text(encoding="utf-8"))
+
+
+def stream_fixture(name):
+    lines = (FIXTURES / name).read_text(encoding="utf-8").splitlines()
+    return [json.loads(line) for line in lines if line.strip()]
+
+
+@pytest.mark.parametrize("case", fixture("negotiate.json"), ids=lambda c: repr(c["offered"]))
+def test_negotiate_matches_go(case):
+    assert negotiate(case["offered"]) == case["version"]
+
+
+def test_negotiate_without_header():
+    assert negotiate(None) == 1
+
+
+@pytest.mark.parametrize("version", [1, 2])
+def test_generate_response(version):
+    payload = {"answer": ANSWER, "model": MODEL, "usage": USAGE}
+    assert shape(payload, version) == fixture(f"generate_v{version}.json")
+
+
+def test_generate_response_without_usage():
+    assert shape({"answer": ANSWER, "model": MODEL, "usage": None}, 2) == {"answer": ANSWER, "model": MODEL}
+
+
+@pytest.mark.parametrize("version", [1, 2])
+def test_stream(version):
+    lines = [{"token": "O prazo"}, {"token": " é de 12 meses."}, {"done": True, "model": MODEL}]
+    assert [shape(line, version) for line in lines] == stream_fixture(f"stream_v{version}.ndjson")
+
+
+def test_current_version_has_fixtures():
+    # Uma versão nova precisa dos seus fixtures (e dos testes Go que os leem)
+    assert (FIXTURES / f"generate_v{PROTOCOL_VERSION}.json").exists()
+    assert (FIXTURES / f"stream_v{PROTOCOL_VERSION}.ndjson").exists()
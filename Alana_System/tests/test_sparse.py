@@ -0,0 +1,31 @@
+"""
+tests/test_sparse.py
+
+Testes do vetor esparso BM25. Os valores esperados foram gerados pelo pacote
+Go `lexical`: se estes testes quebrarem, a busca híbrida do Go deixa de
+encontrar os termos gravados pela ingestão.
+"""
+import pytest
+from alana_system.memory.sparse import document_vector, term_index, tokens
+
+TEXT = "Ação nº 42: o PIX-2024 e a ação!"
+
+
+def test_tokens():
+    assert tokens(TEXT) == ["ação", "nº", "42", "o", "pix", "2024", "e", "a", "ação"]
+    assert tokens("  ...  ") == []
+
+
+def test_term_index_matches_go():
+    assert term_index("ação") == 187489801
+    assert term_index("pix") == 1564535358
+
+
+def test_document_vector_matches_go():
+    indices, values = document_vector(TEXT)
+    assert indices == [
+        187489801, 1109999801, 1564535358, 1596128841,
+        2279835011, 3758891744, 3826002220, 3926667934,
+    ]
+    assert values[0] == pytest.approx(1.8870833)
+    assert values[1:] == pytest.approx([1.6520973] * 7)
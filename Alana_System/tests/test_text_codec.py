@@ -0,0 +1,34 @@
+"""
+Compressão do texto no payload. testdata/text_codec.json é o contrato com o
+engine Go (textcodec_test.go lê o mesmo arquivo).
+"""
+
+import json
+from pathlib import Path
+
+import pytest
+
+from alana_system.memory.text_codec import codec_for_collection, decode_text, encode_text
+
+FIXTURE = Path(__file__).resolve().parent.parent / "testdata" / "text_codec.json"
+
+
+@pytest.mark.parametrize("case", json.loads(FIXTURE.read_text(encoding="utf-8")), ids=lambda c: c["codec"] or "none")
+def test_fixture_roundtrip(case):
+    if case["codec"] == "zstd":
+        pytest.importorskip("zstandard")
+    assert decode_text(case["value"], case["codec"]) == case["text"]
+    assert encode_text(case["text"], case["codec"] or "zstd")[1] == case["codec"]
+
+
+def test_short_text_stays_plain():
+    assert encode_text("Trecho curto.", "zlib") == ("Trecho curto.", "")
+    assert encode_text("Trecho curto.", "zlib", min_bytes=0) == ("Trecho curto.", "")
+
+
+def test_codec_for_collection():
+    assert codec_for_collection("a", "zstd") == "zstd"
+    assert codec_for_collection("a", "zstd,a=none") == ""
+    assert codec_for_collection("b", "a=zlib") == ""
+    with pytest.raises(ValueError):
+        codec_for_collection("a", "lz4")
@@ -1,8 +1,10 @@
 from unittest.mock import MagicMock, patch
+
+import pytest
 from qdrant_client.models import ScoredPoint
 
 from alana_system.embeddings.embedder import TextEmbedder
-from alana_system.memory.vector_store import VectorStore
+from alana_system.memory.vector_store import VectorStore, datatype_for_collection
 
 
 def test_vector_store():
@@ -79,3 +81,14 @@ def test_vector_store():
         mock_client_instance.create_collection.assert_called_once()
         mock_client_instance.upsert.assert_called_once()
         mock_client_instance.search.assert_called_once()
+
+
+def test_datatype_for_collection():
+    """O mesmo formato do ALANA_TEXT_COMPRESSION (e do vecenc.go)."""
+    assert datatype_for_collection("docs", "") == "float32"
+    assert datatype_for_collection("docs", "float16") == "float16"
+    spec = "float16,docs=float32"
+    assert datatype_for_collection("docs", spec) == "float32"
+    assert datatype_for_collection("outra", spec) == "float16"
+    with pytest.raises(ValueError):
+        datatype_for_collection("docs", "int8")
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ==============================
+// Texto comprimido no payload
+// ==============================
+
+// Codecs do campo text_codec, gravado pela ingestão Python conforme
+// ALANA_TEXT_COMPRESSION (ver src/alana_system/memory/text_codec.py). O
+// payload do Qdrant é JSON, então os bytes comprimidos vão em base64. Pontos
+// sem text_codec guardam o texto puro; zlib é o codec das primeiras
+// ingestões, só lido.
+const (
+	textCodecZstd = "zstd"
+	textCodecZlib = "zlib"
+)
+
+// zstdDecoder é compartilhado: DecodeAll é seguro para uso concorrente
+var zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+	return zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+})
+
+// decodeText devolve o texto do payload descomprimido pelo codec do ponto
+func decodeText(value, codec string) (string, error) {
+	switch codec {
+	case "":
+		return value, nil
+	case textCodecZstd:
+		raw, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", err
+		}
+		dec, err := zstdDecoder()
+		if err != nil {
+			return "", err
+		}
+		text, err := dec.DecodeAll(raw, nil)
+		if err != nil {
+			return "", err
+		}
+		return string(text), nil
+	case textCodecZlib:
+		raw, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", err
+		}
+		r, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return "", err
+		}
+		defer r.Close()
+
+		text, err := io.ReadAll(r)
+		if err != nil {
+			return "", err
+		}
+		return string(text), nil
+	}
+	return "", fmt.Errorf("unknown text codec %q", codec)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testdata/text_codec.json é gravado pelo text_codec.py (ver
+// tests/test_text_codec.py): o engine precisa ler o que a ingestão grava
+func TestDecodeTextFixtures(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "text_codec.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []struct {
+		Codec string `json:"codec"`
+		Value string `json:"value"`
+		Text  string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range cases {
+		got, err := decodeText(tc.Value, tc.Codec)
+		if err != nil {
+			t.Errorf("codec %q: %v", tc.Codec, err)
+			continue
+		}
+		if got != tc.Text {
+			t.Errorf("codec %q: texto %q, esperado %q", tc.Codec, got, tc.Text)
+		}
+	}
+
+	if _, err := decodeText("eA==", "lz4"); err == nil {
+		t.Error("codec desconhecido aceito")
+	}
+}
@@ -0,0 +1,50 @@
+package textstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// fileStore lê um arquivo por ponto, em subdiretórios pelos dois primeiros
+// caracteres do ID
+type fileStore struct {
+	root string
+}
+
+func (s fileStore) path(id string) string {
+	return filepath.Join(s.root, id[:min(2, len(id))], id+".txt")
+}
+
+func (s fileStore) Get(ctx context.Context, ids []string) (map[string]string, error) {
+	out := make(map[string]string, len(ids))
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(s.path(id))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out[id] = string(data)
+	}
+	return out, nil
+}
+
+func (s fileStore) Delete(ctx context.Context, ids []string) error {
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fileStore) Close() error { return nil }
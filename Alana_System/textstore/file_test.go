@@ -0,0 +1,34 @@
+package textstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStoreDelete(t *testing.T) {
+	ctx := context.Background()
+	s := fileStore{root: t.TempDir()}
+	for _, id := range []string{"ab01", "ab02"} {
+		path := s.path(id)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("texto "+id), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// IDs ausentes são ignorados
+	if err := s.Delete(ctx, []string{"ab01", "cd99"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(ctx, []string{"ab01", "ab02"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := got["ab01"]; ok || got["ab02"] != "texto ab02" {
+		t.Errorf("depois do Delete: %v", got)
+	}
+}
@@ -0,0 +1,86 @@
+package textstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"alana_system/manifest"
+)
+
+// postgresStore lê (e apaga) a tabela alana_chunk_text criada pela ingestão. Usa o
+// mesmo driver database/sql do manifesto (manifest.PostgresDriver, o pgx,
+// registrado pelo import do pacote manifest).
+type postgresStore struct {
+	db *sql.DB
+}
+
+func openPostgres(ctx context.Context, dsn string) (*postgresStore, error) {
+	db, err := sql.Open(manifest.PostgresDriver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("textstore: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("textstore: %w", err)
+	}
+	return &postgresStore{db: db}, nil
+}
+
+// maxQueryIDs limita os parâmetros por consulta (o Postgres aceita até 65535)
+const maxQueryIDs = 1000
+
+func (s *postgresStore) Get(ctx context.Context, ids []string) (map[string]string, error) {
+	out := make(map[string]string, len(ids))
+	for start := 0; start < len(ids); start += maxQueryIDs {
+		if err := s.get(ctx, ids[start:min(start+maxQueryIDs, len(ids))], out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (s *postgresStore) get(ctx context.Context, ids []string, out map[string]string) error {
+	in, args := inList(ids)
+	rows, err := s.db.QueryContext(ctx, "SELECT point_id, text FROM alana_chunk_text WHERE point_id IN ("+in+")", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, text string
+		if err := rows.Scan(&id, &text); err != nil {
+			return err
+		}
+		out[id] = text
+	}
+	return rows.Err()
+}
+
+func (s *postgresStore) Delete(ctx context.Context, ids []string) error {
+	for start := 0; start < len(ids); start += maxQueryIDs {
+		in, args := inList(ids[start:min(start+maxQueryIDs, len(ids))])
+		if _, err := s.db.ExecContext(ctx, "DELETE FROM alana_chunk_text WHERE point_id IN ("+in+")", args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inList monta "$1, $2, ..." e os argumentos. IN em vez de ANY($1) para não
+// depender de suporte a arrays no driver.
+func inList(ids []string) (string, []any) {
+	placeholders := make([]string, len(ids))
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+	return strings.Join(placeholders, ", "), args
+}
+
+func (s *postgresStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,23 @@
+package textstore
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// O backend postgres: precisa chegar até a conexão: sem o driver registrado
+// ele falhava antes, com sql: unknown driver
+func TestOpenPostgresUsesRegisteredDriver(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := Open(ctx, "postgres:postgres://alana@127.0.0.1:1/alana?connect_timeout=1")
+	if err == nil {
+		t.Fatal("esperado erro de conexão na porta 1")
+	}
+	if strings.Contains(err.Error(), "unknown driver") {
+		t.Fatalf("driver não registrado: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package textstore
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// Release apaga o texto dos pontos que já não existem em nenhuma collection.
+// Deve ser chamado depois de apagar os pontos no Qdrant (gc, purge, reaper):
+// os IDs dos pontos não dependem da collection (ver chunkid), então o mesmo
+// documento na collection principal e numa efêmera, ou no dual-write, divide
+// o mesmo texto. Devolve quantos textos foram apagados.
+func Release(ctx context.Context, s Store, client *qdrant.Client, ids []string) (int, error) {
+	if s == nil || len(ids) == 0 {
+		return 0, nil
+	}
+	collections, err := client.ListCollections(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	live := map[string]bool{}
+	for _, collection := range collections {
+		for batch := range slices.Chunk(ids, maxQueryIDs) {
+			pointIDs := make([]*qdrant.PointId, len(batch))
+			for i, id := range batch {
+				pointIDs[i] = qdrant.NewID(id)
+			}
+			points, err := client.Get(ctx, &qdrant.GetPoints{
+				CollectionName: collection,
+				Ids:            pointIDs,
+				WithPayload:    qdrant.NewWithPayload(false),
+			})
+			if err != nil {
+				return 0, err
+			}
+			for _, p := range points {
+				live[strings.ToLower(p.GetId().GetUuid())] = true
+			}
+		}
+	}
+
+	dead := slices.DeleteFunc(slices.Clone(ids), func(id string) bool { return live[strings.ToLower(id)] })
+	if err := s.Delete(ctx, dead); err != nil {
+		return 0, err
+	}
+	return len(dead), nil
+}
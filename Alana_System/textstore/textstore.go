@@ -0,0 +1,47 @@
+// Package textstore lê (e apaga) o texto dos chunks guardado fora do Qdrant
+// (payload offloading). Com ALANA_TEXT_STORE configurado, a ingestão Python grava só o
+// vetor e os metadados no Qdrant, com text_offloaded=true, e o texto completo
+// aqui, pelo ID do ponto:
+//
+//	file:<dir>         um arquivo por ponto (<dir>/<2 primeiros chars>/<id>.txt)
+//	postgres:<dsn>     tabela alana_chunk_text
+//
+// O formato precisa bater com src/alana_system/memory/text_store.py.
+package textstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Store é um backend de texto; as implementações são seguras para uso
+// concorrente.
+type Store interface {
+	// Get devolve o texto dos pontos encontrados; IDs ausentes ficam de fora
+	Get(ctx context.Context, ids []string) (map[string]string, error)
+	// Delete apaga o texto dos pontos; IDs ausentes são ignorados. Os IDs
+	// dos pontos não dependem da collection: quem apaga precisa conferir que
+	// nenhuma outra collection ainda tem o ponto.
+	Delete(ctx context.Context, ids []string) error
+	Close() error
+}
+
+// Open abre o backend descrito por spec. Devolve nil se spec for vazio
+// (texto no payload do Qdrant).
+func Open(ctx context.Context, spec string) (Store, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "file":
+		if arg == "" {
+			return nil, fmt.Errorf("textstore: file backend requires a directory")
+		}
+		return fileStore{root: arg}, nil
+	case "postgres":
+		return openPostgres(ctx, arg)
+	}
+	return nil, fmt.Errorf("textstore: unknown backend %q", kind)
+}
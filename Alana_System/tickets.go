@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"alana_system/schema"
+)
+
+// ==============================
+// Conectores Jira e Zendesk
+// ==============================
+
+// ------------------------------
+// Jira
+// ------------------------------
+
+// jiraTimeLayout é o formato das datas da API do Jira (2024-03-01T12:00:00.000+0000)
+const jiraTimeLayout = "2006-01-02T15:04:05.000-0700"
+
+// jiraFields são os campos pedidos à busca (o resto da issue não interessa)
+const jiraFields = "summary,description,status,assignee,reporter,labels,components,priority,created,updated"
+
+// jiraConnector lê as issues de um projeto pela busca JQL da API REST v2
+// (descrição em texto, não em ADF). ALANA_JIRA_URL é a instância (ex:
+// https://empresa.atlassian.net); com ALANA_JIRA_EMAIL a autenticação é
+// básica com o token de API (Cloud), sem ele ALANA_JIRA_TOKEN vai como
+// Bearer (token pessoal do Server/Data Center).
+type jiraConnector struct {
+	project string
+	baseURL string
+	header  http.Header
+}
+
+func newJiraConnector(project string) (*jiraConnector, error) {
+	if strings.ContainsAny(project, `/" `) {
+		return nil, fmt.Errorf("projeto Jira inválido %q (use a chave, ex: jira:PROJ)", project)
+	}
+	baseURL := strings.TrimRight(os.Getenv("ALANA_JIRA_URL"), "/")
+	if baseURL == "" {
+		return nil, errors.New("defina ALANA_JIRA_URL (ex: https://empresa.atlassian.net)")
+	}
+	c := &jiraConnector{project: project, baseURL: baseURL, header: http.Header{}}
+	token := os.Getenv("ALANA_JIRA_TOKEN")
+	if email := os.Getenv("ALANA_JIRA_EMAIL"); email != "" {
+		c.header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(email+":"+token)))
+	} else if token != "" {
+		c.header.Set("Authorization", "Bearer "+token)
+	}
+	return c, nil
+}
+
+func (c *jiraConnector) Name() string { return "jira:" + c.project }
+
+// jiraTime aceita o formato do Jira e o RFC3339
+type jiraTime struct{ time.Time }
+
+func (t *jiraTime) UnmarshalJSON(data []byte) error {
+	s, err := strconv.Unquote(string(data))
+	if err != nil || s == "" {
+		return nil
+	}
+	for _, layout := range []string{jiraTimeLayout, time.RFC3339} {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			t.Time = parsed
+			return nil
+		}
+	}
+	return fmt.Errorf("data do Jira inválida %q", s)
+}
+
+type jiraNamed struct {
+	Name        string `json:"name"`
+	Key         string `json:"key"`
+	DisplayName string `json:"displayName"`
+}
+
+type jiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary     string      `json:"summary"`
+		Description string      `json:"description"`
+		Status      *jiraNamed  `json:"status"`
+		Assignee    *jiraNamed  `json:"assignee"`
+		Reporter    *jiraNamed  `json:"reporter"`
+		Priority    *jiraNamed  `json:"priority"`
+		Labels      []string    `json:"labels"`
+		Components  []jiraNamed `json:"components"`
+		Created     jiraTime    `json:"created"`
+		Updated     jiraTime    `json:"updated"`
+	} `json:"fields"`
+}
+
+// jiraSearchPage cobre as duas paginações: startAt/total (/search, Server e
+// Data Center) e nextPageToken (/search/jql, Cloud)
+type jiraSearchPage struct {
+	Issues        []jiraIssue `json:"issues"`
+	StartAt       int         `json:"startAt"`
+	Total         int         `json:"total"`
+	NextPageToken string      `json:"nextPageToken"`
+	IsLast        bool        `json:"isLast"`
+}
+
+func (c *jiraConnector) Fetch(ctx context.Context, since time.Time, yield func(connectorItem) error) error {
+	jql := fmt.Sprintf("project = %q", c.project)
+	if !since.IsZero() {
+		// A JQL compara datas no fuso do usuário do token e só até o minuto;
+		// em minutos relativos (-90m) não há fuso. A folga de um minuto é
+		// descartada abaixo, pelo updated de cada issue.
+		minutes := int(time.Since(since).Minutes()) + 1
+		jql += fmt.Sprintf(" AND updated >= -%dm", minutes)
+	}
+	jql += " ORDER BY updated ASC"
+
+	// O Jira Cloud removeu o /search em favor do /search/jql
+	endpoint := c.baseURL + "/rest/api/2/search"
+	if strings.HasSuffix(hostOf(c.baseURL), ".atlassian.net") {
+		endpoint += "/jql"
+	}
+	q := url.Values{"jql": {jql}, "fields": {jiraFields}, "maxResults": {fmt.Sprint(forgePageSize)}}
+
+	var items []connectorItem
+	for {
+		var page jiraSearchPage
+		if _, err := getConnectorJSON(ctx, endpoint+"?"+q.Encode(), c.header, &page); err != nil {
+			return err
+		}
+		for _, issue := range page.Issues {
+			if issue.Fields.Updated.Before(since) {
+				continue
+			}
+			items = append(items, c.issueItem(issue))
+		}
+
+		switch {
+		case page.NextPageToken != "" && !page.IsLast:
+			q.Set("nextPageToken", page.NextPageToken)
+		case page.NextPageToken == "" && len(page.Issues) > 0 && page.StartAt+len(page.Issues) < page.Total:
+			q.Set("startAt", fmt.Sprint(page.StartAt+len(page.Issues)))
+		default:
+			return yieldAll(items, yield)
+		}
+	}
+}
+
+func (c *jiraConnector) issueItem(issue jiraIssue) connectorItem {
+	f := issue.Fields
+	it := connectorItem{
+		DocID:       "jira:" + issue.Key,
+		Title:       issue.Key + ": " + f.Summary,
+		Text:        f.Description,
+		URL:         c.baseURL + "/browse/" + issue.Key,
+		Tags:        f.Labels,
+		ContentType: schema.ContentTicket,
+		CreatedAt:   f.Created.Time,
+		UpdatedAt:   f.Updated.Time,
+		Fields: map[string]string{
+			"status":   jiraName(f.Status),
+			"assignee": jiraName(f.Assignee),
+			"priority": jiraName(f.Priority),
+			"project":  c.project,
+		},
+	}
+	if f.Reporter != nil {
+		it.Author = jiraName(f.Reporter)
+	}
+	// Componentes fazem o papel de produto; todos viram tags também
+	for i, comp := range f.Components {
+		if i == 0 {
+			it.Fields["product"] = comp.Name
+		}
+		it.Tags = append(it.Tags, comp.Name)
+	}
+	return it
+}
+
+func jiraName(n *jiraNamed) string {
+	switch {
+	case n == nil:
+		return ""
+	case n.DisplayName != "":
+		return n.DisplayName
+	case n.Name != "":
+		return n.Name
+	}
+	return n.Key
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// ------------------------------
+// Zendesk
+// ------------------------------
+
+// zendeskConnector lê os tickets (exportação incremental por cursor) e os
+// artigos da central de ajuda (exportação incremental de artigos) de uma
+// conta. A autenticação é por token de API: ALANA_ZENDESK_EMAIL e
+// ALANA_ZENDESK_TOKEN. ALANA_ZENDESK_URL troca o endereço padrão
+// (https://<subdomínio>.zendesk.com), ex: domínio próprio;
+// ALANA_ZENDESK_PRODUCT_FIELD é o ID do campo personalizado de produto dos
+// tickets (o Zendesk não tem um nativo).
+type zendeskConnector struct {
+	subdomain    string
+	baseURL      string
+	header       http.Header
+	productField int64
+}
+
+func newZendeskConnector(subdomain string) (*zendeskConnector, error) {
+	if strings.ContainsAny(subdomain, "/.:") {
+		return nil, fmt.Errorf("conta Zendesk inválida %q (use o subdomínio, ex: zendesk:empresa)", subdomain)
+	}
+	c := &zendeskConnector{
+		subdomain: subdomain,
+		baseURL:   strings.TrimRight(os.Getenv("ALANA_ZENDESK_URL"), "/"),
+		header:    http.Header{},
+	}
+	if c.baseURL == "" {
+		c.baseURL = "https://" + subdomain + ".zendesk.com"
+	}
+	if email, token := os.Getenv("ALANA_ZENDESK_EMAIL"), os.Getenv("ALANA_ZENDESK_TOKEN"); email != "" && token != "" {
+		c.header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(email+"/token:"+token)))
+	}
+	if v := os.Getenv("ALANA_ZENDESK_PRODUCT_FIELD"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ALANA_ZENDESK_PRODUCT_FIELD inválido %q: %w", v, err)
+		}
+		c.productField = id
+	}
+	return c, nil
+}
+
+func (c *zendeskConnector) Name() string { return "zendesk:" + c.subdomain }
+
+type zendeskUser struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+type zendeskTicket struct {
+	ID           int64     `json:"id"`
+	Subject      string    `json:"subject"`
+	Description  string    `json:"description"`
+	Status       string    `json:"status"`
+	Priority     string    `json:"priority"`
+	AssigneeID   int64     `json:"assignee_id"`
+	RequesterID  int64     `json:"requester_id"`
+	Tags         []string  `json:"tags"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	CustomFields []struct {
+		ID    int64 `json:"id"`
+		Value any   `json:"value"`
+	} `json:"custom_fields"`
+}
+
+type zendeskArticle struct {
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	HTMLURL   string    `json:"html_url"`
+	AuthorID  int64     `json:"author_id"`
+	Labels    []string  `json:"label_names"`
+	Draft     bool      `json:"draft"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (c *zendeskConnector) Fetch(ctx context.Context, since time.Time, yield func(connectorItem) error) error {
+	// A exportação incremental exige start_time > 0
+	start := max(since.Unix(), 1)
+
+	var items []connectorItem
+	tickets, err := c.tickets(ctx, start)
+	if err != nil {
+		return err
+	}
+	items = append(items, tickets...)
+	articles, err := c.articles(ctx, start)
+	if err != nil {
+		return err
+	}
+	items = append(items, articles...)
+	return yieldAll(items, yield)
+}
+
+// tickets percorre /api/v2/incremental/tickets/cursor com os usuários
+// (responsável, solicitante) carregados junto
+func (c *zendeskConnector) tickets(ctx context.Context, start int64) ([]connectorItem, error) {
+	q := url.Values{"start_time": {fmt.Sprint(start)}, "include": {"users"}}
+	var items []connectorItem
+	for {
+		var page struct {
+			Tickets     []zendeskTicket `json:"tickets"`
+			Users       []zendeskUser   `json:"users"`
+			AfterCursor string          `json:"after_cursor"`
+			EndOfStream bool            `json:"end_of_stream"`
+		}
+		if _, err := getConnectorJSON(ctx, c.baseURL+"/api/v2/incremental/tickets/cursor.json?"+q.Encode(), c.header, &page); err != nil {
+			return nil, err
+		}
+		users := map[int64]string{}
+		for _, u := range page.Users {
+			users[u.ID] = u.Name
+		}
+		for _, t := range page.Tickets {
+			// Tickets apagados também aparecem na exportação
+			if t.Status == "deleted" {
+				continue
+			}
+			items = append(items, c.ticketItem(t, users))
+		}
+		if page.EndOfStream || page.AfterCursor == "" {
+			return items, nil
+		}
+		q = url.Values{"cursor": {page.AfterCursor}, "include": {"users"}}
+	}
+}
+
+func (c *zendeskConnector) ticketItem(t zendeskTicket, users map[int64]string) connectorItem {
+	it := connectorItem{
+		DocID:       fmt.Sprintf("%s/tickets/%d", c.Name(), t.ID),
+		Title:       fmt.Sprintf("#%d: %s", t.ID, t.Subject),
+		Text:        t.Description,
+		URL:         fmt.Sprintf("%s/agent/tickets/%d", c.baseURL, t.ID),
+		Author:      users[t.RequesterID],
+		Tags:        t.Tags,
+		ContentType: schema.ContentTicket,
+		CreatedAt:   t.CreatedAt,
+		UpdatedAt:   t.UpdatedAt,
+		Fields: map[string]string{
+			"status":   t.Status,
+			"assignee": users[t.AssigneeID],
+			"priority": t.Priority,
+		},
+	}
+	for _, f := range t.CustomFields {
+		if c.productField != 0 && f.ID == c.productField && f.Value != nil {
+			it.Fields["product"] = fmt.Sprint(f.Value)
+		}
+	}
+	return it
+}
+
+// articles percorre /api/v2/help_center/incremental/articles (artigos
+// publicados; rascunhos ficam de fora)
+func (c *zendeskConnector) articles(ctx context.Context, start int64) ([]connectorItem, error) {
+	q := url.Values{"start_time": {fmt.Sprint(start)}, "include": {"users"}}
+	next := c.baseURL + "/api/v2/help_center/incremental/articles.json?" + q.Encode()
+	var items []connectorItem
+	for next != "" {
+		var page struct {
+			Articles []zendeskArticle `json:"articles"`
+			Users    []zendeskUser    `json:"users"`
+			NextPage string           `json:"next_page"`
+		}
+		if _, err := getConnectorJSON(ctx, next, c.header, &page); err != nil {
+			return nil, err
+		}
+		users := map[int64]string{}
+		for _, u := range page.Users {
+			users[u.ID] = u.Name
+		}
+		for _, a := range page.Articles {
+			if a.Draft {
+				continue
+			}
+			items = append(items, connectorItem{
+				DocID:       fmt.Sprintf("%s/articles/%d", c.Name(), a.ID),
+				Title:       a.Title,
+				Text:        htmlToText(a.Body),
+				URL:         a.HTMLURL,
+				Author:      users[a.AuthorID],
+				Tags:        a.Labels,
+				ContentType: schema.ContentArticle,
+				CreatedAt:   a.CreatedAt,
+				UpdatedAt:   a.UpdatedAt,
+			})
+		}
+		// Sem artigos novos a API devolve a mesma página adiante
+		if len(page.Articles) == 0 || page.NextPage == next {
+			break
+		}
+		next = page.NextPage
+	}
+	return items, nil
+}
+
+var (
+	// htmlBlock são as tags que quebram linha no texto
+	htmlBlock = regexp.MustCompile(`(?i)<\s*(br|/p|/div|/li|/h[1-6]|/tr|/pre|/blockquote)\s*/?>`)
+	htmlTag   = regexp.MustCompile(`(?s)<[^>]*>`)
+	// htmlDrop são blocos sem texto legível
+	htmlDrop   = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)\s*>`)
+	blankLines = regexp.MustCompile(`\n[ \t]*\n(\s*\n)+`)
+)
+
+// htmlToText tira as tags do corpo dos artigos, mantendo as quebras de
+// parágrafo para o chunking
+func htmlToText(s string) string {
+	s = htmlDrop.ReplaceAllString(s, "")
+	s = htmlBlock.ReplaceAllString(s, "\n")
+	s = htmlTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(blankLines.ReplaceAllString(s, "\n\n"))
+}
@@ -0,0 +1,365 @@
+// Package tokenizer conta tokens com os vocabulários BPE no formato do
+// tiktoken (uma linha "base64 rank" por token): cl100k_base (GPT-3.5/4),
+// o200k_base (GPT-4o) e o tokenizer.model do Llama 3, que usa o mesmo
+// formato e a mesma pré-tokenização do cl100k_base.
+//
+// A pré-tokenização reproduz as expressões regulares do tiktoken à mão: as
+// originais usam lookahead (\s+(?!\S)), que o regexp do Go não tem. Tokens
+// especiais (<|endoftext|>...) não são reconhecidos: no texto dos documentos
+// eles são só texto.
+package tokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Pattern é a regra de pré-tokenização de um vocabulário
+type Pattern int
+
+const (
+	// PatternCL100K é a do cl100k_base e do Llama 3
+	PatternCL100K Pattern = iota
+	// PatternO200K é a do o200k_base
+	PatternO200K
+)
+
+// PatternFor escolhe a pré-tokenização pelo nome do vocabulário
+func PatternFor(name string) Pattern {
+	if strings.HasPrefix(name, "o200k") {
+		return PatternO200K
+	}
+	return PatternCL100K
+}
+
+// maxCachedPieces limita o cache de pedaços já codificados; o mesmo texto é
+// medido várias vezes na montagem do contexto (busca binária do corte)
+const maxCachedPieces = 1 << 16
+
+// BPE é um vocabulário carregado. Seguro para uso concorrente.
+type BPE struct {
+	ranks   map[string]int
+	tokens  map[int]string
+	pattern Pattern
+
+	mu    sync.Mutex
+	cache map[string]int
+}
+
+// Load lê o arquivo de ranks (ex: cl100k_base.tiktoken)
+func Load(path string, pattern Pattern) (*BPE, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	bpe, err := Parse(f, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return bpe, nil
+}
+
+// Parse lê os ranks no formato do tiktoken
+func Parse(r io.Reader, pattern Pattern) (*BPE, error) {
+	ranks := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		token, rank, ok := bytes.Cut(line, []byte(" "))
+		if !ok {
+			return nil, fmt.Errorf("linha %d: esperado \"base64 rank\"", n)
+		}
+		raw, err := base64.StdEncoding.DecodeString(string(token))
+		if err != nil {
+			return nil, fmt.Errorf("linha %d: %w", n, err)
+		}
+		id, err := strconv.Atoi(string(rank))
+		if err != nil {
+			return nil, fmt.Errorf("linha %d: rank inválido %q", n, rank)
+		}
+		ranks[string(raw)] = id
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	// Todo byte precisa ser um token, senão há texto impossível de codificar
+	for b := range 256 {
+		if _, ok := ranks[string([]byte{byte(b)})]; !ok {
+			return nil, fmt.Errorf("vocabulário sem o byte 0x%02x", b)
+		}
+	}
+	tokens := make(map[int]string, len(ranks))
+	for token, id := range ranks {
+		tokens[id] = token
+	}
+	return &BPE{ranks: ranks, tokens: tokens, pattern: pattern, cache: map[string]int{}}, nil
+}
+
+// Encode devolve os IDs dos tokens de text
+func (t *BPE) Encode(text string) []int {
+	var ids []int
+	split(text, t.pattern, func(piece string) {
+		if id, ok := t.ranks[piece]; ok {
+			ids = append(ids, id)
+			return
+		}
+		bounds := append(t.merge(piece), len(piece))
+		for i := 0; i+1 < len(bounds); i++ {
+			ids = append(ids, t.ranks[piece[bounds[i]:bounds[i+1]]])
+		}
+	})
+	return ids
+}
+
+// Decode junta os bytes dos tokens de volta no texto
+func (t *BPE) Decode(ids []int) (string, error) {
+	var b strings.Builder
+	for _, id := range ids {
+		token, ok := t.tokens[id]
+		if !ok {
+			return "", fmt.Errorf("token %d fora do vocabulário", id)
+		}
+		b.WriteString(token)
+	}
+	return b.String(), nil
+}
+
+// Count devolve o número de tokens de text
+func (t *BPE) Count(text string) int {
+	n := 0
+	split(text, t.pattern, func(piece string) {
+		n += t.countPiece(piece)
+	})
+	return n
+}
+
+func (t *BPE) countPiece(piece string) int {
+	if _, ok := t.ranks[piece]; ok {
+		return 1
+	}
+	t.mu.Lock()
+	n, ok := t.cache[piece]
+	t.mu.Unlock()
+	if ok {
+		return n
+	}
+
+	n = len(t.merge(piece))
+	t.mu.Lock()
+	if len(t.cache) >= maxCachedPieces {
+		clear(t.cache)
+	}
+	t.cache[piece] = n
+	t.mu.Unlock()
+	return n
+}
+
+// merge aplica o byte-pair encoding num pedaço: junta repetidamente o par
+// vizinho de menor rank até nenhum par estar no vocabulário. Devolve as
+// fronteiras dos tokens.
+func (t *BPE) merge(piece string) []int {
+	// bounds[i] é o início do i-ésimo token; o último é len(piece)
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, at := -1, -1
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := t.ranks[piece[bounds[i]:bounds[i+2]]]; ok && (best < 0 || rank < best) {
+				best, at = rank, i
+			}
+		}
+		if at < 0 {
+			break
+		}
+		bounds = append(bounds[:at+1], bounds[at+2:]...)
+	}
+	return bounds[:len(bounds)-1]
+}
+
+// ==============================
+// Pré-tokenização
+// ==============================
+
+// split divide o texto nos pedaços da expressão do vocabulário. As
+// alternativas são tentadas na ordem da expressão original:
+//
+//	cl100k: (?i:'s|'t|'re|'ve|'m|'ll|'d) | [^\r\n\p{L}\p{N}]?\p{L}+ | \p{N}{1,3} |
+//	        ' '?[^\s\p{L}\p{N}]+[\r\n]* | \s*[\r\n]+ | \s+(?!\S) | \s+
+//	o200k:  [^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|...)? |
+//	        [^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|...)? |
+//	        \p{N}{1,3} | ' '?[^\s\p{L}\p{N}]+[\r\n/]* | \s*[\r\n]+ | \s+(?!\S) | \s+
+func split(s string, p Pattern, yield func(string)) {
+	for i := 0; i < len(s); {
+		n := 0
+		if p == PatternO200K {
+			n = matchO200K(s, i)
+		} else {
+			n = matchCL100K(s, i)
+		}
+		if n == 0 {
+			// Nenhuma alternativa casa (não acontece com texto válido): um
+			// caractere por vez
+			_, n = utf8.DecodeRuneInString(s[i:])
+		}
+		yield(s[i : i+n])
+		i += n
+	}
+}
+
+func matchCL100K(s string, i int) int {
+	if n := contraction(s, i); n > 0 {
+		return n
+	}
+	// [^\r\n\p{L}\p{N}]?\p{L}+
+	if n := optionalPrefix(s, i, func(j int) int { return runWhile(s, j, unicode.IsLetter) }); n > 0 {
+		return n
+	}
+	return matchTail(s, i, "\r\n")
+}
+
+func matchO200K(s string, i int) int {
+	// [^\r\n\p{L}\p{N}]?U*L+(contração)?
+	if n := optionalPrefix(s, i, func(j int) int { return upperThenLower(s, j) }); n > 0 {
+		return n + contraction(s, i+n)
+	}
+	// [^\r\n\p{L}\p{N}]?U+L*(contração)?
+	if n := optionalPrefix(s, i, func(j int) int {
+		u := runWhile(s, j, isUpperish)
+		if u == 0 {
+			return 0
+		}
+		return u + runWhile(s, j+u, isLowerish)
+	}); n > 0 {
+		return n + contraction(s, i+n)
+	}
+	return matchTail(s, i, "\r\n/")
+}
+
+// matchTail são as alternativas comuns aos dois vocabulários depois das
+// palavras: números, pontuação (seguida de trail*) e espaços
+func matchTail(s string, i int, trail string) int {
+	// \p{N}{1,3}
+	if n := runWhileMax(s, i, unicode.IsNumber, 3); n > 0 {
+		return n
+	}
+	// ' '?[^\s\p{L}\p{N}]+[trail]*
+	j := i
+	if s[j] == ' ' {
+		j++
+	}
+	if n := runWhile(s, j, isPunct); n > 0 {
+		j += n
+		for j < len(s) && strings.IndexByte(trail, s[j]) >= 0 {
+			j++
+		}
+		return j - i
+	}
+
+	ws := runWhile(s, i, unicode.IsSpace)
+	if ws == 0 {
+		return 0
+	}
+	// \s*[\r\n]+: até a última quebra de linha da sequência de espaços
+	if k := strings.LastIndexAny(s[i:i+ws], "\r\n"); k >= 0 {
+		return k + 1
+	}
+	// \s+(?!\S): deixa o último espaço para a palavra seguinte
+	if i+ws < len(s) {
+		_, last := utf8.DecodeLastRuneInString(s[i : i+ws])
+		if ws > last {
+			return ws - last
+		}
+	}
+	// \s+
+	return ws
+}
+
+// optionalPrefix casa [^\r\n\p{L}\p{N}]? seguido de body, tentando primeiro
+// com o prefixo (a expressão é gulosa) e depois sem ele
+func optionalPrefix(s string, i int, body func(j int) int) int {
+	r, size := utf8.DecodeRuneInString(s[i:])
+	if r != '\r' && r != '\n' && !unicode.IsLetter(r) && !unicode.IsNumber(r) && i+size < len(s) {
+		if n := body(i + size); n > 0 {
+			return size + n
+		}
+	}
+	return body(i)
+}
+
+// upperThenLower casa U*L+ com o retrocesso da expressão: Lm, Lo e M estão
+// nas duas classes, então se L+ não sobrar nada, o último caractere de U*
+// pode passar para L+
+func upperThenLower(s string, j int) int {
+	u := runWhile(s, j, isUpperish)
+	if l := runWhile(s, j+u, isLowerish); l > 0 {
+		return u + l
+	}
+	if u > 0 {
+		if r, _ := utf8.DecodeLastRuneInString(s[j : j+u]); isLowerish(r) {
+			return u
+		}
+	}
+	return 0
+}
+
+// contraction casa (?i:'s|'t|'re|'ve|'m|'ll|'d)
+func contraction(s string, i int) int {
+	if i >= len(s) || s[i] != '\'' {
+		return 0
+	}
+	rest := strings.ToLower(s[i+1 : min(i+3, len(s))])
+	switch {
+	case strings.HasPrefix(rest, "re"), strings.HasPrefix(rest, "ve"), strings.HasPrefix(rest, "ll"):
+		return 3
+	case strings.HasPrefix(rest, "s"), strings.HasPrefix(rest, "t"), strings.HasPrefix(rest, "m"), strings.HasPrefix(rest, "d"):
+		return 2
+	}
+	return 0
+}
+
+func runWhile(s string, i int, ok func(rune) bool) int {
+	return runWhileMax(s, i, ok, -1)
+}
+
+// runWhileMax mede em bytes a sequência de até limit caracteres (-1 = sem
+// limite) a partir de i que satisfazem ok
+func runWhileMax(s string, i int, ok func(rune) bool, limit int) int {
+	j := i
+	for count := 0; j < len(s) && count != limit; count++ {
+		r, size := utf8.DecodeRuneInString(s[j:])
+		if !ok(r) {
+			break
+		}
+		j += size
+	}
+	return j - i
+}
+
+func isPunct(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// isUpperish é [\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]
+func isUpperish(r rune) bool {
+	return unicode.In(r, unicode.Lu, unicode.Lt, unicode.Lm, unicode.Lo, unicode.M)
+}
+
+// isLowerish é [\p{Ll}\p{Lm}\p{Lo}\p{M}]
+func isLowerish(r rune) bool {
+	return unicode.In(r, unicode.Ll, unicode.Lm, unicode.Lo, unicode.M)
+}
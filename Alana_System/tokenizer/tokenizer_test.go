@@ -0,0 +1,150 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testMerges completa testVocab, um vocabulário pequeno no formato do
+// tiktoken: os 256 bytes (ranks 0-255, então o ID de um byte é o próprio
+// byte) e estes merges, inclusive de caracteres de dois bytes
+var testMerges = []string{
+	256: "th",
+	257: "the",
+	258: "he",
+	259: "ã",
+	260: "ç",
+	261: "ão",
+	262: " c",
+	263: " ca",
+	264: "at",
+	265: " cat",
+}
+
+func testVocab(t *testing.T, pattern Pattern) *BPE {
+	t.Helper()
+	var b strings.Builder
+	for id := range 256 {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(id)}), id)
+	}
+	for id, token := range testMerges[256:] {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), 256+id)
+	}
+	bpe, err := Parse(strings.NewReader(b.String()), pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bpe
+}
+
+func TestEncodeGolden(t *testing.T) {
+	bpe := testVocab(t, PatternCL100K)
+	cases := []struct {
+		text string
+		ids  []int
+	}{
+		{"", nil},
+		{"the", []int{257}},
+		{"the cat", []int{257, 265}},
+		{"the  cat", []int{257, ' ', 265}},
+		// "ç" e "ã" são dois bytes cada; os merges juntam os bytes e depois "ão"
+		{"ação", []int{'a', 260, 261}},
+		{"😀", []int{0xf0, 0x9f, 0x98, 0x80}},
+		// o par de menor rank mais à esquerda é juntado primeiro
+		{"hehe123456", []int{258, 258, '1', '2', '3', '4', '5', '6'}},
+		{"I'm", []int{'I', '\'', 'm'}},
+	}
+	for _, tc := range cases {
+		ids := bpe.Encode(tc.text)
+		if !slices.Equal(ids, tc.ids) {
+			t.Errorf("Encode(%q) = %v, esperado %v", tc.text, ids, tc.ids)
+		}
+		if n := bpe.Count(tc.text); n != len(tc.ids) {
+			t.Errorf("Count(%q) = %d, esperado %d", tc.text, n, len(tc.ids))
+		}
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	texts := []string{
+		"",
+		"the cat sat on the mat",
+		"Não há ação sem decisão. Çà et là!",
+		"日本語のテキスト、中文文本",
+		"emoji 😀👍🏽 e bandeira 🇧🇷",
+		"linhas\r\n\r\n  recuadas\t\tcom tabs   \n",
+		"números 1234567 e 3.14159",
+		"I'M sure you'LL see they've",
+		"bytes inválidos \xff\xfe no meio",
+	}
+	for _, pattern := range []Pattern{PatternCL100K, PatternO200K} {
+		bpe := testVocab(t, pattern)
+		for _, text := range texts {
+			ids := bpe.Encode(text)
+			got, err := bpe.Decode(ids)
+			if err != nil {
+				t.Fatalf("Decode(%v): %v", ids, err)
+			}
+			if got != text {
+				t.Errorf("padrão %d: Decode(Encode(%q)) = %q", pattern, text, got)
+			}
+			if n := bpe.Count(text); n != len(ids) {
+				t.Errorf("padrão %d: Count(%q) = %d, Encode deu %d tokens", pattern, text, n, len(ids))
+			}
+		}
+	}
+}
+
+func TestDecodeUnknownToken(t *testing.T) {
+	bpe := testVocab(t, PatternCL100K)
+	if _, err := bpe.Decode([]int{257, 999}); err == nil {
+		t.Error("token fora do vocabulário aceito")
+	}
+}
+
+func TestParseRejectsMissingByte(t *testing.T) {
+	var b strings.Builder
+	for id := range 255 {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(id)}), id)
+	}
+	if _, err := Parse(strings.NewReader(b.String()), PatternCL100K); err == nil || !strings.Contains(err.Error(), "0xff") {
+		t.Errorf("vocabulário sem o byte 0xff: %v", err)
+	}
+	if _, err := Parse(strings.NewReader("dGhl\n"), PatternCL100K); err == nil || !strings.Contains(err.Error(), "linha 1") {
+		t.Errorf("linha sem rank: %v", err)
+	}
+}
+
+// A pré-tokenização segue as expressões do tiktoken
+func TestSplit(t *testing.T) {
+	cases := []struct {
+		pattern Pattern
+		text    string
+		pieces  []string
+	}{
+		{PatternCL100K, "hello world", []string{"hello", " world"}},
+		{PatternCL100K, "I'm here", []string{"I", "'m", " here"}},
+		{PatternCL100K, "I'M ok", []string{"I", "'M", " ok"}},
+		{PatternCL100K, "HelloWorld", []string{"HelloWorld"}},
+		{PatternCL100K, "  hi", []string{" ", " hi"}},
+		{PatternCL100K, "1234567", []string{"123", "456", "7"}},
+		{PatternCL100K, "a\n\nb", []string{"a", "\n\n", "b"}},
+		{PatternCL100K, "ok!!\n", []string{"ok", "!!\n"}},
+		{PatternCL100K, "ação é", []string{"ação", " é"}},
+		{PatternCL100K, "x   ", []string{"x", "   "}},
+		{PatternO200K, "HelloWorld", []string{"Hello", "World"}},
+		{PatternO200K, "I'M ok", []string{"I'M", " ok"}},
+		{PatternO200K, "x//\n", []string{"x", "//\n"}},
+		{PatternO200K, "ação é", []string{"ação", " é"}},
+	}
+	for _, tc := range cases {
+		var pieces []string
+		split(tc.text, tc.pattern, func(p string) { pieces = append(pieces, p) })
+		if !slices.Equal(pieces, tc.pieces) {
+			t.Errorf("padrão %d, %q: %q, esperado %q", tc.pattern, tc.text, pieces, tc.pieces)
+		}
+	}
+}
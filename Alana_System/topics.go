@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+// ==============================
+// Tópicos (clusterização + rótulos do LLM)
+// ==============================
+
+const topicLabelPrompt = "Os trechos do contexto pertencem a um mesmo tema. " +
+	"Responda apenas com um rótulo curto (2 a 5 palavras) em português que descreva esse tema, sem pontuação final."
+
+// topicCluster é um grupo de chunks com o rótulo gerado pelo LLM
+type topicCluster struct {
+	ID      int
+	Label   string
+	Members []int
+	Sources map[string]int
+}
+
+// runTopics implementa `alana topics`: agrupa os vetores da collection com
+// k-means, pede ao LLM um rótulo para cada grupo e grava `topic`/`topic_id`
+// no payload, permitindo filtrar por tema.
+func runTopics(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("topics", flag.ContinueOnError)
+	k := fs.Int("k", 8, "quantidade de tópicos")
+	maxIter := fs.Int("iter", 50, "máximo de iterações do k-means")
+	samples := fs.Int("samples", 5, "trechos enviados ao LLM por tópico")
+	dryRun := fs.Bool("dry-run", false, "não grava os rótulos no Qdrant")
+	reportPath := fs.String("report", "", "grava o panorama do corpus em Markdown")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *k < 1 || *samples < 1 || *maxIter < 1 || fs.NArg() > 0 {
+		return errors.New("uso: alana topics [-k N>=1] [-iter N>=1] [-samples N>=1] [-dry-run] [-report arquivo.md]")
+	}
+
+	cliLog.Info("Lendo vetores da collection")
+	var chunks []SearchResult
+	var ids []*qdrant.PointId
+	var vectors [][]float32
+
+	err := engine.scrollPoints(ctx, true, func(points []*qdrant.RetrievedPoint) error {
+		page := make([]SearchResult, 0, len(points))
+		for _, p := range points {
+			v := denseVector(p.GetVectors())
+			if len(v) == 0 {
+				continue
+			}
+			page = append(page, resultFromPayload(p.GetId(), p.GetPayload(), 0))
+			ids = append(ids, p.GetId())
+			vectors = append(vectors, v)
+		}
+		if err := engine.loadTexts(ctx, page); err != nil {
+			return err
+		}
+		chunks = append(chunks, page...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(chunks) == 0 {
+		return errors.New("collection has no vectors")
+	}
+	fmt.Printf("   OK | %d chunks\n\n", len(chunks))
+
+	cliLog.Info("Agrupando em tópicos", "k", *k)
+	assign, centroids := kMeans(vectors, *k, *maxIter, 42)
+
+	clusters := make([]*topicCluster, len(centroids))
+	for c := range clusters {
+		clusters[c] = &topicCluster{ID: c, Sources: map[string]int{}}
+	}
+	for i, c := range assign {
+		clusters[c].Members = append(clusters[c].Members, i)
+		clusters[c].Sources[chunks[i].Source]++
+	}
+
+	cliLog.Info("Rotulando tópicos com o LLM")
+	for _, cl := range clusters {
+		if len(cl.Members) == 0 {
+			continue
+		}
+
+		// Os trechos mais próximos do centróide são os mais representativos
+		members := append([]int(nil), cl.Members...)
+		sims := make(map[int]float32, len(members))
+		for _, i := range members {
+			sims[i] = dot(normalized(vectors[i]), centroids[cl.ID])
+		}
+		sort.Slice(members, func(a, b int) bool {
+			return sims[members[a]] > sims[members[b]]
+		})
+
+		texts := make([]string, 0, *samples)
+		for _, i := range members[:min(*samples, len(members))] {
+			texts = append(texts, chunks[i].Text)
+		}
+
+		label, err := labelTopic(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("label topic %d: %w", cl.ID, err)
+		}
+		cl.Label = label
+		fmt.Printf("   #%d %s (%d chunks)\n", cl.ID, cl.Label, len(cl.Members))
+
+		if *dryRun {
+			continue
+		}
+
+		memberIDs := make([]*qdrant.PointId, 0, len(cl.Members))
+		for _, i := range cl.Members {
+			memberIDs = append(memberIDs, ids[i])
+		}
+		if err := engine.setPayload(ctx, memberIDs, map[string]any{
+			"topic":    cl.Label,
+			"topic_id": int64(cl.ID),
+		}); err != nil {
+			return err
+		}
+	}
+
+	if !*dryRun {
+		if err := engine.ensureFieldIndex(ctx, "topic", qdrant.FieldType_FieldTypeKeyword); err != nil {
+			return err
+		}
+	}
+
+	report := topicsReport(clusters, len(chunks))
+	fmt.Println()
+	fmt.Println(report)
+
+	if *reportPath != "" {
+		if err := os.WriteFile(*reportPath, []byte(report), 0o644); err != nil {
+			return err
+		}
+		cliLog.Info("Panorama gravado", "file", *reportPath)
+	}
+
+	return nil
+}
+
+// labelTopic pede ao LLM um rótulo curto para os trechos de um tópico
+func labelTopic(ctx context.Context, texts []string) (string, error) {
+	var b strings.Builder
+	for i, t := range texts {
+		fmt.Fprintf(&b, "--- Trecho %d ---\n%s\n\n", i+1, truncateRunes(t, 400))
+	}
+
+	answer, err := getAnswer(ctx, topicLabelPrompt, b.String())
+	if err != nil {
+		return "", err
+	}
+
+	label, _, _ := strings.Cut(strings.TrimSpace(answer), "\n")
+	label = strings.Trim(strings.TrimSpace(label), `"'.`)
+	if label == "" {
+		label = "Sem rótulo"
+	}
+	return label, nil
+}
+
+// topicsReport monta o panorama do corpus em Markdown
+func topicsReport(clusters []*topicCluster, total int) string {
+	sorted := append([]*topicCluster(nil), clusters...)
+	sort.Slice(sorted, func(a, b int) bool {
+		return len(sorted[a].Members) > len(sorted[b].Members)
+	})
+
+	var b strings.Builder
+	b.WriteString("# Panorama do corpus\n\n")
+	fmt.Fprintf(&b, "%d chunks em %d tópicos.\n\n", total, len(clusters))
+	b.WriteString("| Tópico | Chunks | % | Principais fontes |\n")
+	b.WriteString("|---|---|---|---|\n")
+
+	for _, cl := range sorted {
+		if len(cl.Members) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "| %s | %d | %.1f%% | %s |\n",
+			cl.Label,
+			len(cl.Members),
+			100*float64(len(cl.Members))/float64(total),
+			strings.Join(topSources(cl.Sources, 3), ", "),
+		)
+	}
+
+	return b.String()
+}
+
+// topSources devolve as n fontes com mais chunks
+func topSources(sources map[string]int, n int) []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(a, b int) bool {
+		if sources[names[a]] != sources[names[b]] {
+			return sources[names[a]] > sources[names[b]]
+		}
+		return names[a] < names[b]
+	})
+	return names[:min(n, len(names))]
+}
+
+// truncateRunes corta o texto em n caracteres sem quebrar UTF-8
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "..."
+}
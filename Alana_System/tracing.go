@@ -0,0 +1,242 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ==============================
+// Tracing (OpenTelemetry)
+// ==============================
+
+const (
+	// tracerBatchSize é quantos spans vão por envio ao coletor
+	tracerBatchSize = 512
+	// tracerQueueSize limita os spans à espera; acima disso são descartados
+	// (o tracing nunca segura uma resposta)
+	tracerQueueSize   = 4096
+	tracerFlushPeriod = 5 * time.Second
+	// tracerShutdownTimeout é o prazo do último envio antes de o processo sair
+	tracerShutdownTimeout = 5 * time.Second
+)
+
+// Tipos de span
+const (
+	spanInternal = trace.SpanKindInternal
+	spanServer   = trace.SpanKindServer
+	spanClient   = trace.SpanKindClient
+)
+
+// tracer manda os spans de cada etapa do RAG (embed → busca → geração) para
+// um coletor OpenTelemetry (Jaeger, Tempo, otel-collector) por OTLP/HTTP,
+// com o SDK e o exportador otlptracehttp do OTel. Lê as variáveis padrão do
+// OTel: OTEL_EXPORTER_OTLP_TRACES_ENDPOINT (URL completa) ou
+// OTEL_EXPORTER_OTLP_ENDPOINT (+ /v1/traces), OTEL_EXPORTER_OTLP_HEADERS
+// (chave=valor,...) e OTEL_SERVICE_NAME (padrão alana). Sem endpoint, ou com
+// OTEL_SDK_DISABLED=true, fica desligado e os spans não custam nada.
+//
+// O contexto do trace segue no cabeçalho traceparent (W3C Trace Context):
+// vem do cliente em `alana serve` e vai para o sidecar em toda chamada do
+// providerHTTP.
+var tracer = newTracerFromEnv()
+
+type spanTracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// traceContext lê e escreve o traceparent (W3C Trace Context)
+var traceContext = propagation.TraceContext{}
+
+func newTracerFromEnv() *spanTracer {
+	if disabled, _ := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED")); disabled {
+		return nil
+	}
+	if os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		return nil
+	}
+
+	// O exportador lê endpoint e cabeçalhos das variáveis do OTel. Cliente
+	// próprio: o envio não passa pelo limite de taxa nem pelo tracing do
+	// providerHTTP.
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithHTTPClient(&http.Client{Timeout: 10 * time.Second}))
+	if err != nil {
+		engineLog.Warn("Tracing desligado: exportador OTLP inválido", "err", err)
+		return nil
+	}
+	return newSpanTracer(exporter, cmp.Or(os.Getenv("OTEL_SERVICE_NAME"), "alana"))
+}
+
+// newSpanTracer monta o tracer sobre um exportador qualquer (os testes usam
+// um coletor httptest)
+func newSpanTracer(exporter sdktrace.SpanExporter, service string) *spanTracer {
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter,
+			sdktrace.WithMaxExportBatchSize(tracerBatchSize),
+			sdktrace.WithMaxQueueSize(tracerQueueSize),
+			sdktrace.WithBatchTimeout(tracerFlushPeriod),
+		),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", service))),
+	)
+	return &spanTracer{provider: provider, tracer: provider.Tracer("alana_system")}
+}
+
+// enabled diz se os spans são exportados
+func (t *spanTracer) enabled() bool { return t != nil }
+
+// shutdown envia os spans pendentes (até o prazo do ctx)
+func (t *spanTracer) shutdown(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	if err := t.provider.Shutdown(ctx); err != nil {
+		engineLog.Warn("Falha ao exportar spans", "err", err)
+	}
+}
+
+// flushTraces envia os spans pendentes antes de o processo sair
+func flushTraces() {
+	ctx, cancel := context.WithTimeout(context.Background(), tracerShutdownTimeout)
+	defer cancel()
+	tracer.shutdown(ctx)
+}
+
+// ==============================
+// Spans
+// ==============================
+
+// span é uma etapa medida. Os métodos aceitam span nil (tracing desligado).
+type span struct {
+	trace.Span
+}
+
+// startSpan abre um span filho do corrente em ctx (ou a raiz de um trace
+// novo). Com o tracing desligado devolve ctx e nil, sem alocar nada.
+func startSpan(ctx context.Context, name string, kind trace.SpanKind) (context.Context, *span) {
+	if !tracer.enabled() {
+		return ctx, nil
+	}
+	ctx, s := tracer.tracer.Start(ctx, name, trace.WithSpanKind(kind))
+	return ctx, &span{s}
+}
+
+// set grava um atributo (string, bool, int ou float64)
+func (s *span) set(key string, value any) {
+	if s == nil {
+		return
+	}
+	var kv attribute.KeyValue
+	switch x := value.(type) {
+	case bool:
+		kv = attribute.Bool(key, x)
+	case int:
+		kv = attribute.Int(key, x)
+	case float64:
+		kv = attribute.Float64(key, x)
+	default:
+		kv = attribute.String(key, fmt.Sprint(x))
+	}
+	s.SetAttributes(kv)
+}
+
+// finish fecha o span, marcando erro se err != nil
+func (s *span) finish(err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.SetStatus(codes.Error, err.Error())
+	}
+	s.End()
+}
+
+// ==============================
+// Propagação HTTP
+// ==============================
+
+// traceTransport abre um span de cliente para cada chamada HTTP de saída e
+// envia o traceparent dele, para o sidecar continuar o mesmo trace
+type traceTransport struct {
+	next http.RoundTripper
+}
+
+func (t traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, s := startSpan(req.Context(), req.Method+" "+req.URL.Path, spanClient)
+	if s == nil {
+		return t.next.RoundTrip(req)
+	}
+	s.set("http.request.method", req.Method)
+	s.set("url.full", req.URL.Redacted())
+
+	req = req.Clone(ctx)
+	traceContext.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.next.RoundTrip(req)
+	spanErr := err
+	if err == nil {
+		s.set("http.response.status_code", resp.StatusCode)
+		if resp.StatusCode >= 500 {
+			spanErr = fmt.Errorf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+		}
+	}
+	s.finish(spanErr)
+	return resp, err
+}
+
+// traceHandler abre o span de servidor de cada pedido, continuando o trace
+// do cliente quando ele manda um traceparent válido
+func traceHandler(next http.Handler) http.Handler {
+	if !tracer.enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := traceContext.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, s := startSpan(ctx, r.Method+" "+r.URL.Path, spanServer)
+		s.set("http.request.method", r.Method)
+		s.set("url.path", r.URL.Path)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(rec, r)
+
+		// O padrão da rota (ex: GET /v1/memory/{user}) agrupa melhor que o caminho
+		if r.Pattern != "" {
+			s.SetName(r.Pattern)
+			s.set("http.route", r.Pattern)
+		}
+		s.set("http.response.status_code", rec.status)
+		var err error
+		if rec.status >= 500 {
+			err = fmt.Errorf("%d %s", rec.status, http.StatusText(rec.status))
+		}
+		s.finish(err)
+	})
+}
+
+// statusRecorder guarda o status da resposta. Unwrap mantém o Flush do
+// http.ResponseController (streaming SSE).
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
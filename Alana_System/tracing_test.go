@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// otlpCollector é um coletor OTLP/HTTP de teste: guarda os pedidos de
+// exportação decodificados com os tipos oficiais do OTLP
+type otlpCollector struct {
+	mu       sync.Mutex
+	requests []*collectortrace.ExportTraceServiceRequest
+	types    []string
+}
+
+func (c *otlpCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.requests = append(c.requests, &req)
+	c.types = append(c.types, r.Header.Get("Content-Type"))
+	c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(nil)
+}
+
+// spans devolve os spans recebidos, com o service.name do recurso
+func (c *otlpCollector) spans() (map[string]*tracepb.Span, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := map[string]*tracepb.Span{}
+	var service string
+	for _, req := range c.requests {
+		for _, rs := range req.ResourceSpans {
+			for _, attr := range rs.GetResource().GetAttributes() {
+				if attr.Key == "service.name" {
+					service = attr.GetValue().GetStringValue()
+				}
+			}
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					out[s.Name] = s
+				}
+			}
+		}
+	}
+	return out, service
+}
+
+// withTestTracer liga o tracing exportando para um coletor httptest
+func withTestTracer(t *testing.T) *otlpCollector {
+	t.Helper()
+	collector := &otlpCollector{}
+	srv := httptest.NewServer(collector)
+	t.Cleanup(srv.Close)
+
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(srv.URL+"/v1/traces"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := tracer
+	tracer = newSpanTracer(exporter, "alana-test")
+	t.Cleanup(func() {
+		tracer.shutdown(context.Background())
+		tracer = previous
+	})
+	return collector
+}
+
+func spanKey(s *tracepb.Span) (traceID, spanID, parentID string) {
+	return hex.EncodeToString(s.TraceId), hex.EncodeToString(s.SpanId), hex.EncodeToString(s.ParentSpanId)
+}
+
+// Um pedido com traceparent gera o span de servidor filho do cliente e o de
+// cliente para o sidecar, que recebe o traceparent do span de cliente; o
+// coletor recebe os dois no formato OTLP
+func TestTraceExportOTLP(t *testing.T) {
+	collector := withTestTracer(t)
+
+	var sidecarParent string
+	sidecar := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sidecarParent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer sidecar.Close()
+	client := &http.Client{Transport: traceTransport{http.DefaultTransport}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/ask", func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodPost, sidecar.URL+"/generate", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		_, sp := startSpan(r.Context(), "ask", spanInternal)
+		sp.set("alana.top_k", 5)
+		sp.set("alana.cached", false)
+		sp.finish(nil)
+	})
+
+	const (
+		clientTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+		clientSpan  = "00f067aa0ba902b7"
+	)
+	before := time.Now()
+	req := httptest.NewRequest(http.MethodPost, "/v1/ask", nil)
+	req.Header.Set("traceparent", "00-"+clientTrace+"-"+clientSpan+"-01")
+	traceHandler(mux).ServeHTTP(httptest.NewRecorder(), req)
+	after := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tracer.shutdown(ctx)
+
+	spans, service := collector.spans()
+	if service != "alana-test" {
+		t.Errorf("service.name = %q", service)
+	}
+	for _, ct := range collector.types {
+		if ct != "application/x-protobuf" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+	}
+	server, outgoing, ask := spans["POST /v1/ask"], spans["POST /generate"], spans["ask"]
+	if server == nil || outgoing == nil || ask == nil {
+		t.Fatalf("spans recebidos: %v", spans)
+	}
+
+	traceID, serverID, parentID := spanKey(server)
+	if traceID != clientTrace || parentID != clientSpan {
+		t.Errorf("span de servidor: trace %s pai %s, esperado %s %s", traceID, parentID, clientTrace, clientSpan)
+	}
+	if server.Kind != tracepb.Span_SPAN_KIND_SERVER || outgoing.Kind != tracepb.Span_SPAN_KIND_CLIENT || ask.Kind != tracepb.Span_SPAN_KIND_INTERNAL {
+		t.Errorf("kinds: %v %v %v", server.Kind, outgoing.Kind, ask.Kind)
+	}
+	outTrace, outID, outParent := spanKey(outgoing)
+	if outTrace != clientTrace || outParent != serverID {
+		t.Errorf("span de cliente: trace %s pai %s, esperado %s %s", outTrace, outParent, clientTrace, serverID)
+	}
+	if want := "00-" + clientTrace + "-" + outID + "-01"; sidecarParent != want {
+		t.Errorf("traceparent no sidecar = %q, esperado %q", sidecarParent, want)
+	}
+	if outgoing.GetStatus().GetCode() != tracepb.Status_STATUS_CODE_ERROR {
+		t.Errorf("502 do sidecar sem status de erro: %v", outgoing.GetStatus())
+	}
+
+	for name, s := range spans {
+		if len(s.TraceId) != 16 || len(s.SpanId) != 8 {
+			t.Errorf("%s: IDs com tamanho errado", name)
+		}
+		start, end := time.Unix(0, int64(s.StartTimeUnixNano)), time.Unix(0, int64(s.EndTimeUnixNano))
+		if start.Before(before) || end.After(after) || end.Before(start) {
+			t.Errorf("%s: início %v fim %v fora de [%v, %v]", name, start, end, before, after)
+		}
+	}
+	attrs := map[string]string{}
+	for _, a := range ask.Attributes {
+		attrs[a.Key] = a.GetValue().String()
+	}
+	if !strings.Contains(attrs["alana.top_k"], "int_value:5") || !strings.Contains(attrs["alana.cached"], "bool_value:false") {
+		t.Errorf("atributos: %v", attrs)
+	}
+}
+
+// O traceparent de entrada é continuado só se for válido; senão o pedido
+// começa um trace novo
+func TestTraceparentRoundTrip(t *testing.T) {
+	withTestTracer(t)
+
+	const (
+		validTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+		validSpan  = "00f067aa0ba902b7"
+	)
+	cases := []struct {
+		name      string
+		header    string
+		continues bool
+		sampled   string
+	}{
+		{"válido", "00-" + validTrace + "-" + validSpan + "-01", true, "01"},
+		{"não amostrado", "00-" + validTrace + "-" + validSpan + "-00", true, "00"},
+		{"maiúsculas", "00-" + strings.ToUpper(validTrace) + "-" + validSpan + "-01", false, "01"},
+		{"sem cabeçalho", "", false, "01"},
+		{"trace zerado", "00-" + strings.Repeat("0", 32) + "-" + validSpan + "-01", false, "01"},
+		{"span zerado", "00-" + validTrace + "-" + strings.Repeat("0", 16) + "-01", false, "01"},
+		{"versão ff", "ff-" + validTrace + "-" + validSpan + "-01", false, "01"},
+		{"trace curto", "00-" + validTrace[:30] + "-" + validSpan + "-01", false, "01"},
+		{"não hex", "00-" + strings.Repeat("z", 32) + "-" + validSpan + "-01", false, "01"},
+		{"faltando partes", "00-" + validTrace + "-01", false, "01"},
+		{"lixo", "qualquer coisa", false, "01"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var outgoing string
+			handler := traceHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				carrier := propagation.MapCarrier{}
+				traceContext.Inject(r.Context(), carrier)
+				outgoing = carrier.Get("traceparent")
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/v1/health", nil)
+			if tc.header != "" {
+				req.Header.Set("traceparent", tc.header)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			parts := strings.Split(outgoing, "-")
+			if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+				t.Fatalf("traceparent de saída malformado: %q", outgoing)
+			}
+			if parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+				t.Fatalf("traceparent de saída zerado: %q", outgoing)
+			}
+			if continued := parts[1] == validTrace; continued != tc.continues {
+				t.Errorf("trace %s, continuar = %v", parts[1], tc.continues)
+			}
+			if parts[2] == validSpan {
+				t.Errorf("o span de servidor reusou o span do cliente: %q", outgoing)
+			}
+			if parts[3] != tc.sampled {
+				t.Errorf("flags %s, esperado %s", parts[3], tc.sampled)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"alana_system/render"
+)
+
+// ==============================
+// Validação dos pedidos da API
+// ==============================
+
+const (
+	// maxRequestBytes limita o corpo JSON dos endpoints (o áudio tem maxAudioBytes)
+	maxRequestBytes = 64 << 10
+	// maxQuestionRunes limita a pergunta; perguntas maiores só servem para
+	// estourar o contexto do modelo
+	maxQuestionRunes = 4000
+	// maxBudgetMS é o maior orçamento de latência aceito (5 minutos)
+	maxBudgetMS = 5 * 60 * 1000
+	// maxTopK é o maior número de trechos pedidos por pergunta
+	maxTopK = 50
+	// maxNameRunes limita profile, provider, model, voice e session_id
+	maxNameRunes = 128
+	// maxPinnedDocs e maxExcludedDocs limitam pin e exclude; maxSourceRunes,
+	// cada nome de arquivo
+	maxPinnedDocs   = 10
+	maxExcludedDocs = 100
+	maxSourceRunes  = 1024
+)
+
+// requestError é um pedido rejeitado, devolvido como
+// {"error": "...", "field": "question", "code": "too_long"}
+type requestError struct {
+	Status  int    `json:"-"`
+	Message string `json:"error"`
+	Field   string `json:"field,omitempty"`
+	Code    string `json:"code"`
+}
+
+func (e *requestError) Error() string { return e.Message }
+
+func invalidField(field, code, format string, args ...any) *requestError {
+	return &requestError{Status: http.StatusBadRequest, Message: fmt.Sprintf(format, args...), Field: field, Code: code}
+}
+
+// writeRequestError responde com o erro estruturado; outros erros viram 400
+func writeRequestError(w http.ResponseWriter, err error) {
+	var re *requestError
+	if !errors.As(err, &re) {
+		re = &requestError{Status: http.StatusBadRequest, Message: err.Error(), Code: "invalid"}
+	}
+	writeJSON(w, re.Status, re)
+}
+
+// decodeJSON lê um único objeto JSON do corpo, com tamanho limitado e sem
+// campos desconhecidos (erros de digitação não são ignorados em silêncio)
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	return decodeJSONLimit(w, r, v, maxRequestBytes)
+}
+
+// decodeJSONLimit é o decodeJSON com outro limite de corpo, para endpoints
+// que recebem textos longos
+func decodeJSONLimit(w http.ResponseWriter, r *http.Request, v any, maxBytes int64) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil && dec.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("conteúdo extra depois do objeto JSON")
+	}
+
+	var tooBig *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &tooBig):
+		return &requestError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Message: fmt.Sprintf("corpo maior que %d bytes", maxBytes),
+			Field:   "body",
+			Code:    "too_large",
+		}
+	case errors.As(err, &typeErr):
+		return invalidField(typeErr.Field, "invalid_type", "%s deve ser do tipo %s", typeErr.Field, typeErr.Type)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return invalidField(field, "unknown_field", "campo desconhecido: %s", field)
+	}
+	return invalidField("body", "invalid_json", "JSON inválido: %v", err)
+}
+
+// validateText confere obrigatoriedade, tamanho e caracteres de controle
+func validateText(field, value string, required bool, maxRunes int) error {
+	if strings.TrimSpace(value) == "" {
+		if required {
+			return invalidField(field, "required", "%s é obrigatório", field)
+		}
+		return nil
+	}
+	if !utf8.ValidString(value) {
+		return invalidField(field, "invalid_utf8", "%s não é UTF-8 válido", field)
+	}
+	if n := utf8.RuneCountInString(value); n > maxRunes {
+		return invalidField(field, "too_long", "%s tem %d caracteres; o máximo é %d", field, n, maxRunes)
+	}
+	if strings.ContainsFunc(value, func(r rune) bool { return unicode.IsControl(r) && r != '\n' && r != '\t' && r != '\r' }) {
+		return invalidField(field, "invalid_characters", "%s contém caracteres de controle", field)
+	}
+	return nil
+}
+
+// validateBudget confere budget_ms
+func validateBudget(ms int) error {
+	if ms < 0 || ms > maxBudgetMS {
+		return invalidField("budget_ms", "out_of_range", "budget_ms deve estar entre 0 e %d", maxBudgetMS)
+	}
+	return nil
+}
+
+// validate confere os campos do /ask antes de qualquer chamada ao pipeline
+func (req askRequest) validate() error {
+	if err := validateText("question", req.Question, true, maxQuestionRunes); err != nil {
+		return err
+	}
+	for _, f := range []struct{ name, value string }{
+		{"provider", req.Provider}, {"model", req.Model}, {"voice", req.Voice}, {"profile", req.Profile}, {"session_id", req.SessionID},
+		{"user_id", req.UserID},
+	} {
+		if err := validateText(f.name, f.value, false, maxNameRunes); err != nil {
+			return err
+		}
+	}
+	if err := validateBudget(req.BudgetMS); err != nil {
+		return err
+	}
+	if req.TopK != nil && (*req.TopK == 0 || *req.TopK > maxTopK) {
+		return invalidField("top_k", "out_of_range", "top_k deve estar entre 1 e %d", maxTopK)
+	}
+	if req.ScoreThreshold != nil && (*req.ScoreThreshold < 0 || *req.ScoreThreshold > 1) {
+		return invalidField("score_threshold", "out_of_range", "score_threshold deve estar entre 0 e 1")
+	}
+	if req.Rewrite != nil {
+		if _, err := parseRewriteMode(*req.Rewrite); err != nil {
+			return invalidField("rewrite", "invalid_value", "%v", err)
+		}
+	}
+	if req.Mode != "" && req.Mode != askModeAnswer && req.Mode != askModeReport {
+		return invalidField("mode", "invalid_value", "mode deve ser %s ou %s", askModeAnswer, askModeReport)
+	}
+	if _, err := render.ParseFormat(req.Format); err != nil {
+		return invalidField("format", "invalid_value", "%v", err)
+	}
+	if _, err := req.Filter.searchFilter(); err != nil {
+		return err
+	}
+	return validateDocumentLists(req.Pin, req.Exclude)
+}
+
+// retrievalParams lê top_k, score_threshold e rewrite da query string: o ajuste da
+// busca nos endpoints sem corpo JSON (áudio) e, no /search, nos pedidos que
+// não os trazem no corpo
+func retrievalParams(query url.Values) (retrievalSettings, error) {
+	var settings retrievalSettings
+	if v := query.Get("top_k"); v != "" {
+		topK, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return settings, invalidField("top_k", "invalid_type", "top_k deve ser um inteiro")
+		}
+		if topK == 0 || topK > maxTopK {
+			return settings, invalidField("top_k", "out_of_range", "top_k deve estar entre 1 e %d", maxTopK)
+		}
+		settings.TopK = &topK
+	}
+	if v := query.Get("score_threshold"); v != "" {
+		t, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return settings, invalidField("score_threshold", "invalid_type", "score_threshold deve ser um número")
+		}
+		if t < 0 || t > 1 {
+			return settings, invalidField("score_threshold", "out_of_range", "score_threshold deve estar entre 0 e 1")
+		}
+		threshold := float32(t)
+		settings.ScoreThreshold = &threshold
+	}
+	if v := query.Get("rewrite"); v != "" {
+		if _, err := parseRewriteMode(v); err != nil {
+			return settings, invalidField("rewrite", "invalid_value", "%v", err)
+		}
+		settings.Rewrite = &v
+	}
+	return settings, nil
+}
+
+// validateDocumentLists confere o pin e o exclude do /ask: nomes de arquivo
+// válidos, dentro dos limites e sem documento nas duas listas
+func validateDocumentLists(pin, exclude []string) error {
+	if len(pin) > maxPinnedDocs {
+		return invalidField("pin", "too_many", "pin aceita até %d documentos", maxPinnedDocs)
+	}
+	if len(exclude) > maxExcludedDocs {
+		return invalidField("exclude", "too_many", "exclude aceita até %d documentos", maxExcludedDocs)
+	}
+	for _, list := range []struct {
+		field string
+		docs  []string
+	}{{"pin", pin}, {"exclude", exclude}} {
+		for i, doc := range list.docs {
+			if err := validateText(fmt.Sprintf("%s[%d]", list.field, i), doc, true, maxSourceRunes); err != nil {
+				return err
+			}
+		}
+	}
+	for _, doc := range pin {
+		if slices.Contains(exclude, doc) {
+			return invalidField("exclude", "conflict", "%s está em pin e em exclude", doc)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// errorCode devolve o field e o code do requestError (vazios se err é nil)
+func errorCode(t *testing.T, err error) (field, code string) {
+	t.Helper()
+	if err == nil {
+		return "", ""
+	}
+	var re *requestError
+	if !errors.As(err, &re) {
+		t.Fatalf("erro sem requestError: %v", err)
+	}
+	return re.Field, re.Code
+}
+
+func TestValidateText(t *testing.T) {
+	cases := []struct {
+		name     string
+		value    string
+		required bool
+		code     string
+	}{
+		{"vazio opcional", "", false, ""},
+		{"vazio obrigatório", "", true, "required"},
+		{"só espaços obrigatório", " \t\n", true, "required"},
+		{"no limite", strings.Repeat("a", 10), true, ""},
+		{"um acima do limite", strings.Repeat("a", 11), true, "too_long"},
+		// o limite é em caracteres, não em bytes
+		{"multibyte no limite", strings.Repeat("ç", 10), true, ""},
+		{"multibyte acima do limite", strings.Repeat("ç", 11), true, "too_long"},
+		{"quebras e tabs", "a\nb\tc\r\nd", true, ""},
+		{"NUL", "a\x00b", true, "invalid_characters"},
+		{"ESC", "a\x1b[31mb", true, "invalid_characters"},
+		{"DEL", "a\x7fb", true, "invalid_characters"},
+		{"controle C1", "a\u0085b", true, "invalid_characters"},
+		{"UTF-8 inválido", "a\xffb", true, "invalid_utf8"},
+	}
+	for _, tc := range cases {
+		field, code := errorCode(t, validateText("question", tc.value, tc.required, 10))
+		if code != tc.code || (code != "" && field != "question") {
+			t.Errorf("%s: field %q code %q, esperado %q", tc.name, field, code, tc.code)
+		}
+	}
+}
+
+func TestAskRequestValidateLimits(t *testing.T) {
+	ptr := func(n uint64) *uint64 { return &n }
+	ok := func() askRequest { return askRequest{Question: "Quantos dias de férias?"} }
+	type edit struct {
+		name  string
+		edit  func(*askRequest)
+		field string
+		code  string
+	}
+	cases := []edit{
+		{"válido", func(*askRequest) {}, "", ""},
+		{"pergunta no limite", func(r *askRequest) { r.Question = strings.Repeat("é", maxQuestionRunes) }, "", ""},
+		{"pergunta acima do limite", func(r *askRequest) { r.Question = strings.Repeat("é", maxQuestionRunes+1) }, "question", "too_long"},
+		{"pergunta vazia", func(r *askRequest) { r.Question = "" }, "question", "required"},
+		{"pergunta com controle", func(r *askRequest) { r.Question = "férias\x00?" }, "question", "invalid_characters"},
+		{"budget no limite", func(r *askRequest) { r.BudgetMS = maxBudgetMS }, "", ""},
+		{"budget acima do limite", func(r *askRequest) { r.BudgetMS = maxBudgetMS + 1 }, "budget_ms", "out_of_range"},
+		{"budget negativo", func(r *askRequest) { r.BudgetMS = -1 }, "budget_ms", "out_of_range"},
+		{"top_k no limite", func(r *askRequest) { r.TopK = ptr(maxTopK) }, "", ""},
+		{"top_k acima do limite", func(r *askRequest) { r.TopK = ptr(maxTopK + 1) }, "top_k", "out_of_range"},
+		{"top_k zero", func(r *askRequest) { r.TopK = ptr(0) }, "top_k", "out_of_range"},
+	}
+
+	// A tabela de campos curtos: cada um no limite, um acima e com controle
+	names := []struct {
+		field string
+		set   func(*askRequest, string)
+	}{
+		{"provider", func(r *askRequest, v string) { r.Provider = v }},
+		{"model", func(r *askRequest, v string) { r.Model = v }},
+		{"voice", func(r *askRequest, v string) { r.Voice = v }},
+		{"profile", func(r *askRequest, v string) { r.Profile = v }},
+		{"session_id", func(r *askRequest, v string) { r.SessionID = v }},
+		{"user_id", func(r *askRequest, v string) { r.UserID = v }},
+	}
+	for _, n := range names {
+		cases = append(cases,
+			edit{n.field + " no limite", func(r *askRequest) { n.set(r, strings.Repeat("ã", maxNameRunes)) }, "", ""},
+			edit{n.field + " acima do limite", func(r *askRequest) { n.set(r, strings.Repeat("ã", maxNameRunes+1)) }, n.field, "too_long"},
+			edit{n.field + " com controle", func(r *askRequest) { n.set(r, "nome\x1b") }, n.field, "invalid_characters"},
+		)
+	}
+
+	docs := func(n int, prefix string) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = fmt.Sprintf("%s-%d.pdf", prefix, i)
+		}
+		return out
+	}
+	cases = append(cases,
+		edit{"pin no limite", func(r *askRequest) { r.Pin = docs(maxPinnedDocs, "pin") }, "", ""},
+		edit{"pin acima do limite", func(r *askRequest) { r.Pin = docs(maxPinnedDocs+1, "pin") }, "pin", "too_many"},
+		edit{"exclude no limite", func(r *askRequest) { r.Exclude = docs(maxExcludedDocs, "ex") }, "", ""},
+		edit{"exclude acima do limite", func(r *askRequest) { r.Exclude = docs(maxExcludedDocs+1, "ex") }, "exclude", "too_many"},
+		edit{"nome de documento no limite", func(r *askRequest) { r.Pin = []string{strings.Repeat("d", maxSourceRunes)} }, "", ""},
+		edit{"nome de documento acima do limite", func(r *askRequest) { r.Pin = []string{"a.pdf", strings.Repeat("d", maxSourceRunes+1)} }, "pin[1]", "too_long"},
+		edit{"nome de documento com controle", func(r *askRequest) { r.Exclude = []string{"a\x00.pdf"} }, "exclude[0]", "invalid_characters"},
+		edit{"nome de documento vazio", func(r *askRequest) { r.Pin = []string{""} }, "pin[0]", "required"},
+		edit{"documento em pin e exclude", func(r *askRequest) { r.Pin, r.Exclude = []string{"a.pdf"}, []string{"a.pdf"} }, "exclude", "conflict"},
+	)
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := ok()
+			tc.edit(&req)
+			field, code := errorCode(t, req.validate())
+			if field != tc.field || code != tc.code {
+				t.Errorf("field %q code %q, esperado %q %q", field, code, tc.field, tc.code)
+			}
+		})
+	}
+}
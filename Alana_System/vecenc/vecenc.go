@@ -0,0 +1,200 @@
+// Package vecenc é o formato binário dos vetores entre o sidecar e o Go. Em
+// JSON, cada componente de um vetor vira um número em texto (um vetor de 1024
+// dimensões passa de 20KB e custa uma conversão por componente); em binário é
+// base64 de floats little-endian, decodificado direto no []float32 final.
+//
+// Float32 é exato. Float16 tem a metade do tamanho e só é pedido para as
+// collections que já guardam os vetores em float16 (datatype do Qdrant), em
+// que a precisão extra seria descartada na gravação de qualquer jeito.
+//
+// O datatype das collections novas vem de ALANA_VECTOR_DATATYPE, no mesmo
+// formato do ALANA_TEXT_COMPRESSION (ver vector_store.py):
+//
+//	float16                                   todas as collections
+//	alana_knowledge_base=float16,outra=float32 por collection
+package vecenc
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+)
+
+// Datatypes dos vetores (o campo encoding dos pedidos ao /embed)
+const (
+	Float32 = "float32"
+	Float16 = "float16"
+)
+
+// size é o tamanho em bytes de um componente
+func size(dtype string) (int, error) {
+	switch dtype {
+	case Float32:
+		return 4, nil
+	case Float16:
+		return 2, nil
+	}
+	return 0, fmt.Errorf("vecenc: datatype desconhecido %q (use float32 ou float16)", dtype)
+}
+
+// scratch guarda os buffers do base64 decodificado entre as chamadas; só os
+// floats saem de Decode
+var scratch = sync.Pool{New: func() any { return new([]byte) }}
+
+// Decode acrescenta a dst os componentes codificados em data (base64) e
+// devolve dst. Com dst de capacidade suficiente, não aloca: lotes podem
+// decodificar num único slab reaproveitado.
+func Decode(dst []float32, data, dtype string) ([]float32, error) {
+	width, err := size(dtype)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := scratch.Get().(*[]byte)
+	defer scratch.Put(buf)
+	raw := (*buf)[:0]
+	if n := base64.StdEncoding.DecodedLen(len(data)); cap(raw) < n {
+		raw = make([]byte, 0, n)
+	}
+	raw, err = base64.StdEncoding.AppendDecode(raw, []byte(data))
+	*buf = raw
+	if err != nil {
+		return nil, fmt.Errorf("vecenc: %w", err)
+	}
+	if len(raw)%width != 0 {
+		return nil, fmt.Errorf("vecenc: %d bytes não formam componentes %s", len(raw), dtype)
+	}
+
+	dst = growFloats(dst, len(raw)/width)
+	for i := 0; i < len(raw); i += width {
+		if width == 4 {
+			dst = append(dst, math.Float32frombits(binary.LittleEndian.Uint32(raw[i:])))
+		} else {
+			dst = append(dst, halfToFloat32(binary.LittleEndian.Uint16(raw[i:])))
+		}
+	}
+	return dst, nil
+}
+
+// Encode codifica vec em base64 no datatype dtype, o inverso de Decode (o
+// que o sidecar faz com numpy). Float16 arredonda para o par mais próximo e
+// leva o que passa do maior float16 para ±Inf.
+func Encode(vec []float32, dtype string) (string, error) {
+	width, err := size(dtype)
+	if err != nil {
+		return "", err
+	}
+	raw := make([]byte, 0, len(vec)*width)
+	for _, f := range vec {
+		if width == 4 {
+			raw = binary.LittleEndian.AppendUint32(raw, math.Float32bits(f))
+		} else {
+			raw = binary.LittleEndian.AppendUint16(raw, float32ToHalf(f))
+		}
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
+
+func growFloats(s []float32, n int) []float32 {
+	if cap(s)-len(s) >= n {
+		return s
+	}
+	grown := make([]float32, len(s), len(s)+n)
+	copy(grown, s)
+	return grown
+}
+
+// halfToFloat32 converte um float16 IEEE 754 (o np.float16 do sidecar)
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h) & 0x3ff
+
+	switch exp {
+	case 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		// Subnormal: normaliza a mantissa
+		exp = 127 - 15 + 1
+		for mant&0x400 == 0 {
+			mant <<= 1
+			exp--
+		}
+		mant &= 0x3ff
+	case 0x1f:
+		// Inf ou NaN
+		return math.Float32frombits(sign | 0xff<<23 | mant<<13)
+	default:
+		exp += 127 - 15
+	}
+	return math.Float32frombits(sign | exp<<23 | mant<<13)
+}
+
+// float32ToHalf converte para float16 IEEE 754 arredondando para o par mais
+// próximo, como o astype(np.float16)
+func float32ToHalf(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	if exp == 0xff {
+		if mant == 0 {
+			return sign | 0x7c00
+		}
+		// NaN: mantém o bit de quiet e o que couber do payload
+		return sign | 0x7e00 | uint16(mant>>13)
+	}
+	e := exp - 127 + 15
+	switch {
+	case e >= 0x1f:
+		return sign | 0x7c00
+	case e < -10:
+		// Menor que metade do menor subnormal: zero
+		return sign
+	case e <= 0:
+		// Subnormal: o bit implícito entra na mantissa, deslocada para o
+		// expoente mínimo
+		mant |= 0x800000
+		shift := uint(14 - e)
+		half := mant >> shift
+		rem, halfway := mant&(1<<shift-1), uint32(1)<<(shift-1)
+		if rem > halfway || rem == halfway && half&1 == 1 {
+			half++
+		}
+		return sign | uint16(half)
+	}
+	// O arredondamento pode subir o expoente (até Inf), que é o certo
+	half := uint16(e)<<10 | uint16(mant>>13)
+	if rem := mant & 0x1fff; rem > 0x1000 || rem == 0x1000 && half&1 == 1 {
+		half++
+	}
+	return sign | half
+}
+
+// DatatypeForCollection resolve o datatype da collection a partir de spec (o
+// valor de ALANA_VECTOR_DATATYPE). Vazio é Float32.
+func DatatypeForCollection(collection, spec string) (string, error) {
+	dtype := Float32
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			dtype = name
+		} else if name == collection {
+			dtype = value
+			break
+		}
+	}
+	if _, err := size(dtype); err != nil {
+		return "", err
+	}
+	return dtype, nil
+}
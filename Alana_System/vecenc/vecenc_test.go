@@ -0,0 +1,195 @@
+package vecenc
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+// halfBits codifica os float16 crus em base64, como o sidecar manda
+func halfBits(hs ...uint16) string {
+	raw := make([]byte, 0, 2*len(hs))
+	for _, h := range hs {
+		raw = binary.LittleEndian.AppendUint16(raw, h)
+	}
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+// Valores conferidos com np.float16(...).view(np.uint16)
+var halfGolden = []struct {
+	name string
+	bits uint16
+	want float32
+}{
+	{"+0", 0x0000, 0},
+	{"-0", 0x8000, float32(math.Copysign(0, -1))},
+	{"1", 0x3c00, 1},
+	{"-2", 0xc000, -2},
+	{"1/3", 0x3555, 0.333251953125},
+	{"menor subnormal", 0x0001, 0x1p-24},
+	{"-menor subnormal", 0x8001, -0x1p-24},
+	{"maior subnormal", 0x03ff, 0x3ffp-24},
+	{"menor normal", 0x0400, 0x1p-14},
+	{"maior finito", 0x7bff, 65504},
+	{"-maior finito", 0xfbff, -65504},
+	{"+Inf", 0x7c00, float32(math.Inf(1))},
+	{"-Inf", 0xfc00, float32(math.Inf(-1))},
+}
+
+func TestDecodeFloat16Golden(t *testing.T) {
+	for _, tc := range halfGolden {
+		got, err := Decode(nil, halfBits(tc.bits), Float16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || math.Float32bits(got[0]) != math.Float32bits(tc.want) {
+			t.Errorf("%s (0x%04x): %v, esperado %v", tc.name, tc.bits, got, tc.want)
+		}
+	}
+	for _, bits := range []uint16{0x7e00, 0xfe00, 0x7c01, 0x7fff} {
+		got, _ := Decode(nil, halfBits(bits), Float16)
+		if f := got[0]; !math.IsNaN(float64(f)) || math.Signbit(float64(f)) != (bits&0x8000 != 0) {
+			t.Errorf("NaN 0x%04x virou %v", bits, f)
+		}
+	}
+}
+
+func TestFloat16RoundTrip(t *testing.T) {
+	for _, tc := range halfGolden {
+		data, err := Encode([]float32{tc.want}, Float16)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data != halfBits(tc.bits) {
+			t.Errorf("Encode(%s) = %q, esperado 0x%04x", tc.name, data, tc.bits)
+		}
+	}
+
+	// Todo float16 que não é NaN volta aos mesmos bits (o sinal do zero
+	// inclusive); NaN continua NaN com o mesmo sinal
+	for h := range 1 << 16 {
+		f := halfToFloat32(uint16(h))
+		back := float32ToHalf(f)
+		if math.IsNaN(float64(f)) {
+			if back&0x7c00 != 0x7c00 || back&0x3ff == 0 || back&0x8000 != uint16(h)&0x8000 {
+				t.Errorf("NaN 0x%04x voltou como 0x%04x", h, back)
+			}
+			continue
+		}
+		if back != uint16(h) {
+			t.Errorf("0x%04x → %v → 0x%04x", h, f, back)
+		}
+	}
+}
+
+// Arredondamento para o par mais próximo, subnormais e estouro
+func TestFloat32ToHalfRounding(t *testing.T) {
+	cases := []struct {
+		in   float32
+		want uint16
+	}{
+		{65519, 0x7bff},          // abaixo da metade do passo: maior finito
+		{65520, 0x7c00},          // metade do passo acima de 65504: Inf
+		{1e10, 0x7c00},           // estouro
+		{-1e10, 0xfc00},          // estouro negativo
+		{0x1p-25, 0x0000},        // metade do menor subnormal: par (zero)
+		{0x1.000002p-25, 0x0001}, // pouco acima da metade: menor subnormal
+		{0x3p-25, 0x0002},        // 1.5 subnormal: par (2)
+		{0x1p-30, 0x0000},        // pequeno demais
+		{1 + 0x1p-11, 0x3c00},    // metade entre 1 e o próximo: par (1)
+		{1 + 0x3p-11, 0x3c02},    // metade entre o ímpar e o par: par
+		{0x1.ffep-15, 0x0400},    // maior subnormal arredondado sobe para o normal
+		{float32(math.NaN()), 0x7e00},
+	}
+	for _, tc := range cases {
+		if got := float32ToHalf(tc.in); got != tc.want {
+			t.Errorf("float32ToHalf(%v) = 0x%04x, esperado 0x%04x", tc.in, got, tc.want)
+		}
+	}
+}
+
+// Valores normais voltam com erro relativo de no máximo meio passo da
+// mantissa de 10 bits (2^-11)
+func TestFloat16ErrorBound(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	vec := make([]float32, 10000)
+	for i := range vec {
+		// log-uniforme no intervalo normal do float16, com sinal
+		f := float32(math.Exp2(rng.Float64()*31 - 14))
+		if rng.IntN(2) == 0 {
+			f = -f
+		}
+		vec[i] = min(max(f, -65504), 65504)
+	}
+	data, err := Encode(vec, Float16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(nil, data, Float16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, f := range vec {
+		if rel := math.Abs(float64(got[i]-f)) / math.Abs(float64(f)); rel > 0x1p-11 {
+			t.Errorf("%v voltou %v: erro relativo %g", f, got[i], rel)
+		}
+	}
+}
+
+func TestFloat32RoundTrip(t *testing.T) {
+	vec := []float32{0, float32(math.Copysign(0, -1)), 1e-45, math.MaxFloat32, float32(math.Inf(-1)), 0.1, -3.5}
+	data, err := Encode(vec, Float32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := make([]float32, 1, 1+len(vec))
+	dst[0] = 42
+	got, err := Decode(dst, data, Float32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1+len(vec) || got[0] != 42 {
+		t.Fatalf("Decode não acrescentou a dst: %v", got)
+	}
+	for i, f := range vec {
+		if math.Float32bits(got[1+i]) != math.Float32bits(f) {
+			t.Errorf("%v voltou %v", f, got[1+i])
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, err := Decode(nil, halfBits(0x3c00), "int8"); err == nil {
+		t.Error("datatype desconhecido aceito")
+	}
+	if _, err := Decode(nil, "não é base64", Float32); err == nil {
+		t.Error("base64 inválido aceito")
+	}
+	if _, err := Decode(nil, halfBits(0x3c00), Float32); err == nil {
+		t.Error("2 bytes aceitos como float32")
+	}
+}
+
+func TestDatatypeForCollection(t *testing.T) {
+	cases := []struct {
+		collection, spec, want string
+	}{
+		{"kb", "", Float32},
+		{"kb", "float16", Float16},
+		{"kb", "kb=float16,outra=float32", Float16},
+		{"outra", "kb=float16,outra=float32", Float32},
+		{"terceira", "float16,kb=float32", Float16},
+		{"kb", "float16, kb=float32", Float32},
+	}
+	for _, tc := range cases {
+		got, err := DatatypeForCollection(tc.collection, tc.spec)
+		if err != nil || got != tc.want {
+			t.Errorf("DatatypeForCollection(%q, %q) = %q, %v, esperado %q", tc.collection, tc.spec, got, err, tc.want)
+		}
+	}
+	if _, err := DatatypeForCollection("kb", "kb=bfloat16"); err == nil {
+		t.Error("datatype desconhecido aceito")
+	}
+}
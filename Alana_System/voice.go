@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"alana_system/render"
+)
+
+// ==============================
+// Perguntas por voz
+// ==============================
+
+// maxAudioBytes limita o tamanho do clipe enviado a /v1/query/audio
+const maxAudioBytes = 25 << 20
+
+// transcriber converte um clipe de áudio em texto
+type transcriber interface {
+	Transcribe(ctx context.Context, audio []byte, filename string) (string, error)
+}
+
+// transcriberFromEnv usa o whisper.cpp local se ALANA_WHISPER_CPP (binário)
+// e ALANA_WHISPER_CPP_MODEL (modelo ggml) estiverem definidos; senão, o sidecar.
+func transcriberFromEnv() transcriber {
+	bin, model := os.Getenv("ALANA_WHISPER_CPP"), os.Getenv("ALANA_WHISPER_CPP_MODEL")
+	if bin != "" && model != "" {
+		return whisperCppTranscriber{bin: bin, model: model}
+	}
+	return sidecarTranscriber{}
+}
+
+// sidecarTranscriber usa o endpoint /transcribe (Whisper) do sidecar
+type sidecarTranscriber struct{}
+
+type transcribeResponse struct {
+	Text string `json:"text"`
+}
+
+func (sidecarTranscriber) Transcribe(ctx context.Context, audio []byte, filename string) (string, error) {
+	endpoint := sidecarURL + "/transcribe?filename=" + url.QueryEscape(filepath.Base(filename))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(audio))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := providerHTTP.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("transcribe error: %s", string(raw))
+	}
+
+	var out transcribeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	return out.Text, nil
+}
+
+// whisperCppTranscriber roda o whisper.cpp localmente. O whisper.cpp só lê
+// WAV 16 kHz; outros formatos devem ser convertidos pelo cliente.
+type whisperCppTranscriber struct {
+	bin   string
+	model string
+}
+
+func (t whisperCppTranscriber) Transcribe(ctx context.Context, audio []byte, filename string) (string, error) {
+	tmp, err := os.CreateTemp("", "alana-voice-*.wav")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(audio); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.bin, "-m", t.model, "-f", tmp.Name(), "-nt", "-np")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("whisper.cpp failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.Join(strings.Fields(string(out)), " "), nil
+}
+
+type audioQueryResponse struct {
+	Transcription string `json:"transcription"`
+	askResponse
+}
+
+// handleAudioQuery implementa POST /v1/query/audio. Aceita multipart (campo
+// "audio") ou o áudio cru no corpo; budget_ms, profile, format, speak e voice
+// podem vir na query string.
+func (s *server) handleAudioQuery(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxAudioBytes)
+
+	audio, filename, err := readAudio(r)
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			writeError(w, http.StatusRequestEntityTooLarge, "áudio maior que o limite")
+			return
+		}
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	for _, field := range []string{"profile", "voice"} {
+		if err := validateText(field, query.Get(field), false, maxNameRunes); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+	}
+	settings, err := retrievalParams(query)
+	if err != nil {
+		writeRequestError(w, err)
+		return
+	}
+	opts, err := s.engine.collections.askOptions(s.engine.collection, query.Get("profile"), settings)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts.Viewer = s.access.viewer(requestAPIKey(r))
+	if v := query.Get("budget_ms"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil {
+			writeRequestError(w, invalidField("budget_ms", "invalid_type", "budget_ms deve ser um inteiro"))
+			return
+		}
+		if err := validateBudget(ms); err != nil {
+			writeRequestError(w, err)
+			return
+		}
+		opts.Budget = time.Duration(ms) * time.Millisecond
+	}
+	format, err := render.ParseFormat(query.Get("format"))
+	if err != nil {
+		writeRequestError(w, invalidField("format", "invalid_value", "%v", err))
+		return
+	}
+	speak, _ := strconv.ParseBool(r.URL.Query().Get("speak"))
+	if speak && s.speaker == nil {
+		writeError(w, http.StatusBadRequest, errSpeechDisabled.Error())
+		return
+	}
+
+	question, err := s.transcriber.Transcribe(r.Context(), audio, filename)
+	if err != nil {
+		serverLog.ErrorContext(r.Context(), "Erro na transcrição", "err", err)
+		writeError(w, http.StatusBadGateway, "falha ao transcrever o áudio")
+		return
+	}
+	if strings.TrimSpace(question) == "" {
+		writeError(w, http.StatusUnprocessableEntity, "nenhuma fala reconhecida no áudio")
+		return
+	}
+
+	start := time.Now()
+	answer, err := s.engine.Ask(r.Context(), question, opts)
+	s.recent.add("/v1/query/audio", question, opts, answer, time.Since(start), err)
+	if err != nil {
+		serverLog.ErrorContext(r.Context(), "Erro em /v1/query/audio", "err", err)
+		writeError(w, http.StatusBadGateway, "falha ao gerar resposta")
+		return
+	}
+
+	resp := audioQueryResponse{
+		Transcription: question,
+		askResponse:   newAskResponse(answer, format),
+	}
+	s.stampAnswer(&resp.askResponse, opts, answer)
+	if speak {
+		resp.speechOutput = s.speak(r.Context(), answer.Text, r.URL.Query().Get("voice"))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// readAudio extrai o clipe e o nome do arquivo do pedido
+func readAudio(r *http.Request) ([]byte, string, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("audio")
+		if err != nil {
+			return nil, "", fmt.Errorf("campo audio ausente: %w", err)
+		}
+		defer file.Close()
+
+		audio, err := io.ReadAll(file)
+		return audio, header.Filename, err
+	}
+
+	audio, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(audio) == 0 {
+		return nil, "", errors.New("áudio vazio")
+	}
+	return audio, "audio" + audioExtension(r.Header.Get("Content-Type")), nil
+}
+
+// audioExtension deduz a extensão pelo Content-Type (o Whisper usa a extensão)
+func audioExtension(contentType string) string {
+	switch strings.TrimSpace(strings.Split(contentType, ";")[0]) {
+	case "audio/mpeg":
+		return ".mp3"
+	case "audio/mp4", "audio/x-m4a":
+		return ".m4a"
+	case "audio/ogg":
+		return ".ogg"
+	case "audio/webm":
+		return ".webm"
+	}
+	return ".wav"
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ==============================
+// Aquecimento do cache (FAQ)
+// ==============================
+
+// defaultFAQPath é lido quando nem o argumento nem ALANA_FAQ são informados
+const defaultFAQPath = "config/faq.txt"
+
+// warmupItem é uma pergunta do FAQ depois de respondida
+type warmupItem struct {
+	Question string
+	Answer   Answer
+	Elapsed  time.Duration
+	Err      error
+}
+
+// status resume o que aconteceu com a pergunta no relatório
+func (w warmupItem) status() string {
+	switch {
+	case w.Err != nil:
+		return "❌ erro"
+	case w.Answer.EmptyCorpus:
+		return "❌ base de conhecimento vazia"
+	case w.Answer.Abstained:
+		return "⚠️  sem resposta na base"
+	case w.Answer.Clarification:
+		return "⚠️  pediu esclarecimento"
+	case w.Answer.Cached:
+		return "♻️  já estava no cache"
+	}
+	return "🆕 gerada"
+}
+
+// loadFAQ lê as perguntas, uma por linha. Linhas vazias e comentários (#)
+// são ignorados, e perguntas repetidas entram uma vez só.
+func loadFAQ(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var questions []string
+	seen := map[string]bool{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		q := strings.TrimSpace(sc.Text())
+		if q == "" || strings.HasPrefix(q, "#") || seen[normalizeQuestion(q)] {
+			continue
+		}
+		seen[normalizeQuestion(q)] = true
+		questions = append(questions, q)
+	}
+	return questions, sc.Err()
+}
+
+// runWarmup implementa `alana warmup [-profile p] [-refresh] [-dry-run]
+// [-out relatorio.md] [faq.txt]`: responde as perguntas do FAQ (padrão
+// ALANA_FAQ ou config/faq.txt), guardando as respostas no cache
+// (ALANA_ANSWER_CACHE) antes que os usuários as façam, e escreve um
+// relatório em markdown para revisão. Rode depois de cada ingestão grande:
+// as respostas cujos trechos mudaram são geradas de novo, as outras já
+// estão no cache. -refresh gera todas de novo; -dry-run só gera o
+// relatório, sem tocar no cache (para revisar antes de publicar).
+func runWarmup(ctx context.Context, engine *AlanaEngine, args []string) error {
+	fs := flag.NewFlagSet("warmup", flag.ContinueOnError)
+	profile := fs.String("profile", "", "perfil de config/collections.yaml")
+	refresh := fs.Bool("refresh", false, "gera de novo as respostas que já estão no cache")
+	dryRun := fs.Bool("dry-run", false, "só gera o relatório, sem ler nem gravar o cache")
+	out := fs.String("out", "", "arquivo do relatório (vazio = saída padrão)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return errors.New("uso: alana warmup [-profile p] [-refresh] [-dry-run] [-out relatorio.md] [faq.txt]")
+	}
+
+	switch {
+	case *dryRun:
+	case engine.answers == nil:
+		return errors.New("cache de respostas desligado: defina ALANA_ANSWER_CACHE=file:<dir> (ou use -dry-run)")
+	case os.Getenv("ALANA_ANSWER_CACHE") == "memory":
+		cliLog.Warn("ALANA_ANSWER_CACHE=memory: as respostas somem ao fim do comando; use file:<dir> para o serve aproveitá-las")
+	}
+
+	path := envOr("ALANA_FAQ", defaultFAQPath)
+	if fs.NArg() == 1 {
+		path = fs.Arg(0)
+	}
+	questions, err := loadFAQ(path)
+	if err != nil {
+		return err
+	}
+	if len(questions) == 0 {
+		return fmt.Errorf("nenhuma pergunta em %s", path)
+	}
+
+	opts, err := engine.collections.askOptions(engine.collection, *profile, retrievalSettings{})
+	if err != nil {
+		return err
+	}
+	opts.TokenLimit = engine.models.contextTokenLimit("")
+	switch {
+	case *dryRun:
+		opts.Cache = cacheBypass
+	case *refresh:
+		opts.Cache = cacheRefresh
+	}
+
+	items := make([]warmupItem, 0, len(questions))
+	for i, q := range questions {
+		start := time.Now()
+		answer, err := engine.Ask(ctx, q, opts)
+		item := warmupItem{Question: q, Answer: answer, Elapsed: time.Since(start), Err: err}
+		items = append(items, item)
+		cliLog.Info("Pergunta aquecida", "n", i+1, "total", len(questions), "status", item.status(), "question", q, "elapsed", item.Elapsed)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	report := warmupReport(items, path, *dryRun, time.Now())
+	if *out == "" {
+		fmt.Print(report)
+	} else if err := os.WriteFile(*out, []byte(report), 0o644); err != nil {
+		return err
+	} else {
+		cliLog.Info("Relatório gravado", "file", *out)
+	}
+
+	failed := 0
+	for _, item := range items {
+		if item.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d de %d perguntas falharam", failed, len(items))
+	}
+	return nil
+}
+
+// warmupReport monta o relatório de revisão: um resumo e, por pergunta, o
+// status e a resposta com as notas de rodapé, como o usuário a veria
+func warmupReport(items []warmupItem, path string, dryRun bool, now time.Time) string {
+	counts := map[string]int{}
+	for _, item := range items {
+		counts[item.status()]++
+	}
+
+	var b strings.Builder
+	b.WriteString("# Aquecimento do cache de respostas\n\n")
+	fmt.Fprintf(&b, "_%s · %d perguntas de %s_", now.UTC().Format("2006-01-02 15:04 UTC"), len(items), path)
+	if dryRun {
+		b.WriteString(" _· simulação, nada foi gravado no cache_")
+	}
+	b.WriteString("\n\n")
+	for _, status := range []string{"🆕 gerada", "♻️  já estava no cache", "⚠️  sem resposta na base", "⚠️  pediu esclarecimento", "❌ erro"} {
+		if counts[status] > 0 {
+			fmt.Fprintf(&b, "- %s: %d\n", status, counts[status])
+		}
+	}
+
+	for i, item := range items {
+		fmt.Fprintf(&b, "\n## %d. %s\n\n", i+1, oneLine(item.Question))
+		fmt.Fprintf(&b, "_%s · %v_\n\n", item.status(), item.Elapsed.Round(time.Millisecond))
+		if item.Err != nil {
+			fmt.Fprintf(&b, "```\n%s\n```\n", redactForLog(item.Err.Error()))
+			continue
+		}
+		fmt.Fprintf(&b, "%s\n", strings.TrimSpace(item.Answer.Footnoted()))
+	}
+	return b.String()
+}
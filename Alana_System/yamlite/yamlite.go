@@ -0,0 +1,110 @@
+// Package yamlite lê o YAML usado pelo Alana (front matter e arquivos de
+// configuração) com o gopkg.in/yaml.v3 e devolve o documento nos tipos que os
+// chamadores já esperam: mapas map[string]any, listas []any, inteiros int64,
+// floats float64, booleanos, strings e nil.
+//
+// As chaves viram sempre string; datas sem aspas continuam strings. Só o
+// primeiro documento é lido.
+package yamlite
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Parse interpreta o documento e devolve o mapa da raiz. Um documento vazio
+// devolve um mapa vazio.
+func Parse(data []byte) (map[string]any, error) {
+	var doc yaml.Node
+	err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&doc)
+	if errors.Is(err, io.EOF) {
+		return map[string]any{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("yamlite: %s", strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	if len(doc.Content) == 0 {
+		return map[string]any{}, nil
+	}
+
+	root := doc.Content[0]
+	value, err := convert(root)
+	if err != nil {
+		return nil, err
+	}
+	switch m := value.(type) {
+	case map[string]any:
+		return m, nil
+	case nil:
+		return map[string]any{}, nil
+	}
+	return nil, fmt.Errorf("yamlite: line %d: document root must be a mapping", root.Line)
+}
+
+// convert leva um nó do yaml.v3 para os tipos do pacote
+func convert(n *yaml.Node) (any, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return convert(n.Alias)
+	case yaml.MappingNode:
+		out := make(map[string]any, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i], n.Content[i+1]
+			if key.Kind == yaml.AliasNode {
+				key = key.Alias
+			}
+			if key.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("yamlite: line %d: mapping keys must be scalars", key.Line)
+			}
+			if _, dup := out[key.Value]; dup {
+				return nil, fmt.Errorf("yamlite: line %d: duplicate key %q", key.Line, key.Value)
+			}
+			v, err := convert(val)
+			if err != nil {
+				return nil, err
+			}
+			out[key.Value] = v
+		}
+		return out, nil
+	case yaml.SequenceNode:
+		out := make([]any, 0, len(n.Content))
+		for _, item := range n.Content {
+			v, err := convert(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case yaml.ScalarNode:
+		return scalar(n)
+	}
+	return nil, fmt.Errorf("yamlite: line %d: unsupported node", n.Line)
+}
+
+func scalar(n *yaml.Node) (any, error) {
+	// Datas ficam como o texto escrito, como antes do yaml.v3
+	if n.ShortTag() == "!!timestamp" {
+		return n.Value, nil
+	}
+	var v any
+	if err := n.Decode(&v); err != nil {
+		return nil, fmt.Errorf("yamlite: line %d: %s", n.Line, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	switch x := v.(type) {
+	case int:
+		return int64(x), nil
+	case uint64:
+		if x > math.MaxInt64 {
+			return nil, fmt.Errorf("yamlite: line %d: integer %s out of range", n.Line, n.Value)
+		}
+		return int64(x), nil
+	}
+	return v, nil
+}
@@ -0,0 +1,134 @@
+package yamlite
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name string
+		doc  string
+		want map[string]any
+	}{
+		{"vazio", "", map[string]any{}},
+		{"só separadores", "---\n...\n", map[string]any{}},
+		{
+			"escalares",
+			"title: Relatório anual\ncount: 3\nratio: 0.5\ndraft: false\nnone: null\ntilde: ~",
+			map[string]any{"title": "Relatório anual", "count": int64(3), "ratio": 0.5, "draft": false, "none": nil, "tilde": nil},
+		},
+		{
+			"aspas",
+			`a: "x: y # não é comentário"` + "\n" + `b: 'it''s'` + "\n" + `c: "linha\nnova"` + "\n" + `d: "42"` + "\n" + `"chave com: dois pontos": v`,
+			map[string]any{"a": "x: y # não é comentário", "b": "it's", "c": "linha\nnova", "d": "42", "chave com: dois pontos": "v"},
+		},
+		{
+			"comentários",
+			"# início\nautor: Ana # fim de linha\nurl: http://a.com/#ancora",
+			map[string]any{"autor": "Ana", "url": "http://a.com/#ancora"},
+		},
+		{
+			"lista inline",
+			`tags: [financeiro, "a, b", 'c', 2, []]`,
+			map[string]any{"tags": []any{"financeiro", "a, b", "c", int64(2), []any{}}},
+		},
+		{"lista inline vazia", "tags: []", map[string]any{"tags": []any{}}},
+		{
+			"lista em bloco",
+			"tags:\n  - um\n  - \"dois\"\n  -\nirmã:\n- x\n- y",
+			map[string]any{"tags": []any{"um", "dois", nil}, "irmã": []any{"x", "y"}},
+		},
+		{
+			"lista de mapas",
+			"autores:\n  - nome: Ana\n    papel: revisão\n  - nome: Bia",
+			map[string]any{"autores": []any{
+				map[string]any{"nome": "Ana", "papel": "revisão"},
+				map[string]any{"nome": "Bia"},
+			}},
+		},
+		{
+			"aninhado",
+			"retrieval:\n  top_k: 5\n  rerank:\n    model: ms-marco\nvazio:",
+			map[string]any{"retrieval": map[string]any{"top_k": int64(5), "rerank": map[string]any{"model": "ms-marco"}}, "vazio": nil},
+		},
+		{"CRLF", "a: 1\r\nb: 2\r\n", map[string]any{"a": int64(1), "b": int64(2)}},
+		{
+			"lista inline aninhada",
+			"k: [a, [b, c], []]",
+			map[string]any{"k": []any{"a", []any{"b", "c"}, []any{}}},
+		},
+		{
+			"mapa inline",
+			`k: {a: 1, b: "x, y", c: [d]}`,
+			map[string]any{"k": map[string]any{"a": int64(1), "b": "x, y", "c": []any{"d"}}},
+		},
+		{
+			"bloco literal",
+			"resumo: |\n  linha um\n  linha dois\nfim: 1",
+			map[string]any{"resumo": "linha um\nlinha dois\n", "fim": int64(1)},
+		},
+		{
+			"bloco dobrado",
+			"resumo: >-\n  linha um\n  linha dois\n",
+			map[string]any{"resumo": "linha um linha dois"},
+		},
+		{
+			"âncora",
+			"base: &b\n  top_k: 3\nfaq: *b",
+			map[string]any{"base": map[string]any{"top_k": int64(3)}, "faq": map[string]any{"top_k": int64(3)}},
+		},
+		{
+			"datas e chaves numéricas",
+			"date: 2024-03-01\n2024: ano",
+			map[string]any{"date": "2024-03-01", "2024": "ano"},
+		},
+		{"só comentário", "# nada", map[string]any{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse([]byte(tc.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Parse(%q)\n  = %#v\n  esperado %#v", tc.doc, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	cases := []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{"tab", "a:\n\tb: 1", "line 2"},
+		{"sem dois pontos", "a: 1\nsó texto", "line 2"},
+		{"indentação", "a: 1\n  b: 2", "line 2"},
+		{"item em mapa", "a: 1\nb:\n  c: 2\n  - d", "line 2"},
+		{"lista inline aberta", "tags: [a, b", "line 1"},
+		{"mapa inline aberto", "k: {a: 1", "line 1"},
+		{"aspas abertas", `a: "abc`, "unexpected end of stream"},
+		{"aspas simples abertas", "a: 'abc", "unexpected end of stream"},
+		{"escape inválido", `a: "\q"`, "unknown escape character"},
+		{"raiz lista", "- a\n- b", "line 1: document root must be a mapping"},
+		{"raiz escalar", "\n\ntexto", "line 3: document root must be a mapping"},
+		{"chave duplicada", "a: 1\na: 2", `line 2: duplicate key "a"`},
+		{"chave composta", "? [a, b]\n: 1", "line 1: mapping keys must be scalars"},
+		{"bloco mal indentado", "a: |\n  um\n dois", "line 2"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Parse([]byte(tc.doc))
+			if err == nil {
+				t.Fatalf("Parse(%q) = %#v, esperado erro", tc.doc, got)
+			}
+			if !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("erro %q, esperado algo com %q", err, tc.err)
+			}
+		})
+	}
+}